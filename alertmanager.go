@@ -0,0 +1,106 @@
+package datapool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// AlertmanagerNotifier is a StalenessNotifier posting alerts to an
+// Alertmanager-compatible API (POST /api/v2/alerts). Stale buckets are sent as
+// firing alerts named AlertName with a "bucket" label; recovered buckets are
+// sent once more with endsAt set so the receiver resolves them.
+type AlertmanagerNotifier struct {
+	// URL is the alerts endpoint, e.g. http://alertmanager:9093/api/v2/alerts.
+	URL string
+
+	// Client sends the requests. http.DefaultClient is used when nil.
+	Client *http.Client
+
+	// AlertName is the alertname label. Defaults to "DatapoolBucketStale".
+	AlertName string
+
+	// Labels are added to every alert, e.g. service or severity.
+	Labels map[string]string
+
+	// GeneratorURL is an optional link back to the service owning the pool.
+	GeneratorURL string
+}
+
+type alertmanagerAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       *time.Time        `json:"endsAt,omitempty"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+// Notify posts alerts to the Alertmanager API.
+func (n *AlertmanagerNotifier) Notify(ctx context.Context, alerts []StalenessAlert) error {
+	name := n.AlertName
+	if name == "" {
+		name = "DatapoolBucketStale"
+	}
+
+	payload := make([]alertmanagerAlert, 0, len(alerts))
+	for _, alert := range alerts {
+		labels := make(map[string]string, len(n.Labels)+2)
+		for k, v := range n.Labels {
+			labels[k] = v
+		}
+		labels["alertname"] = name
+		labels["bucket"] = alert.Bucket
+
+		lastUpdate := "never"
+		if !alert.Updated.IsZero() {
+			lastUpdate = alert.Updated.UTC().Format(time.RFC3339)
+		}
+
+		am := alertmanagerAlert{
+			Labels: labels,
+			Annotations: map[string]string{
+				"summary":           fmt.Sprintf("Bucket %q has not been updated within %v", alert.Bucket, alert.Expected),
+				"expected_interval": alert.Expected.String(),
+				"last_update":       lastUpdate,
+			},
+			StartsAt:     alert.StartsAt.UTC(),
+			GeneratorURL: n.GeneratorURL,
+		}
+		if alert.Resolved() {
+			endsAt := alert.EndsAt.UTC()
+			am.EndsAt = &endsAt
+		}
+		payload = append(payload, am)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("datapool: encode alerts: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("datapool: alertmanager request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("datapool: post alerts: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("datapool: post alerts: unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package datapool
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertmanagerNotifier(t *testing.T) {
+	var received [][]map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/v2/alerts", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var alerts []map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&alerts))
+		received = append(received, alerts)
+	}))
+	defer srv.Close()
+
+	clock := NewManualClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	pool := NewDataPool(WithClock(clock))
+	bucket := pool.Bucket("config")
+	bucket.ExpectUpdates(time.Minute)
+	bucket.Put("v1")
+
+	wd := pool.NewWatchdog(&AlertmanagerNotifier{
+		URL:          srv.URL + "/api/v2/alerts",
+		Labels:       map[string]string{"severity": "warning"},
+		GeneratorURL: "http://service/debug",
+	})
+
+	clock.Advance(2 * time.Minute)
+	require.NoError(t, wd.Check(context.Background()))
+	require.Len(t, received, 1)
+	require.Len(t, received[0], 1)
+
+	firing := received[0][0]
+	assert.Equal(t, map[string]any{
+		"alertname": "DatapoolBucketStale",
+		"bucket":    "config",
+		"severity":  "warning",
+	}, firing["labels"])
+	assert.Equal(t, "2024-01-01T12:01:00Z", firing["startsAt"])
+	assert.NotContains(t, firing, "endsAt")
+	assert.Equal(t, "http://service/debug", firing["generatorURL"])
+	annotations := firing["annotations"].(map[string]any)
+	assert.Equal(t, "1m0s", annotations["expected_interval"])
+	assert.Equal(t, "2024-01-01T12:00:00Z", annotations["last_update"])
+
+	bucket.Put("v2")
+	require.NoError(t, wd.Check(context.Background()))
+	require.Len(t, received, 2)
+	assert.Equal(t, "2024-01-01T12:02:00Z", received[1][0]["endsAt"], "Recovered bucket is resolved")
+}
+
+func TestAlertmanagerNotifierErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad alerts", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	n := &AlertmanagerNotifier{URL: srv.URL}
+	err := n.Notify(context.Background(), []StalenessAlert{{Bucket: "config", Expected: time.Second}})
+	assert.EqualError(t, err, "datapool: post alerts: unexpected status 400 Bad Request")
+}
@@ -0,0 +1,161 @@
+package datapool
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Alias makes alias another name of the bucket named target, creating target
+// if needed, for migrations from one name to another: until the alias is
+// removed with Unalias, Bucket, Lookup and every other lookup by either name
+// resolve to the same bucket, so a write through one name is read through
+// the other. The bucket keeps its name, which updates and Inspect report,
+// and counts once in Len; Rename changes the name. Aliases are not logged to the write-ahead log
+// (see WithWAL), nor shared with a backend.
+//
+// Alias fails if alias already names another bucket or an alias of one, if
+// the pool's NameRules reject either name, or if either is a system bucket.
+func (p *DataPool) Alias(alias, target string) error {
+	if err := p.checkWritable(alias); err != nil {
+		return fmt.Errorf("datapool: alias %q: %w", alias, err)
+	}
+	if err := p.checkWritable(target); err != nil {
+		return fmt.Errorf("datapool: alias %q: %w", alias, err)
+	}
+	if err := checkSameTenant(alias, target); err != nil {
+		return fmt.Errorf("datapool: alias %q: %w", alias, err)
+	}
+	b, err := p.bucket(target)
+	if err != nil {
+		return fmt.Errorf("datapool: alias %q: %w", alias, err)
+	}
+
+	p.renameMu.Lock()
+	defer p.renameMu.Unlock()
+	sh := p.shardFor(alias)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	switch sh.buckets[alias] {
+	case b:
+		return nil
+	case nil:
+	default:
+		return fmt.Errorf("datapool: alias %q: bucket exists", alias)
+	}
+	b.guard.RLock()
+	removed := b.removed
+	b.guard.RUnlock()
+	if removed {
+		return fmt.Errorf("datapool: alias %q: %w: %q", alias, ErrBucketNotFound, target)
+	}
+	sh.buckets[alias] = b
+	b.aliases = append(b.aliases, alias)
+	return nil
+}
+
+// Unalias removes alias, a name given to a bucket with Alias. The bucket is
+// left as it is, under its own name; Bucket(alias) creates a new bucket.
+func (p *DataPool) Unalias(alias string) error {
+	p.renameMu.Lock()
+	defer p.renameMu.Unlock()
+	sh := p.shardFor(alias)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	b := sh.buckets[alias]
+	if b == nil || b.name() == alias {
+		return fmt.Errorf("datapool: unalias %q: not an alias", alias)
+	}
+	delete(sh.buckets, alias)
+	b.aliases = slices.DeleteFunc(b.aliases, func(a string) bool { return a == alias })
+	return nil
+}
+
+// Rename renames the bucket named old to name. The bucket keeps its value,
+// timestamp, settings, watchers, history and aliases, and every Bucket handle
+// to it stays valid, now reporting name, while old no longer resolves to it:
+// Bucket(old) creates a new bucket. Use Alias first to keep both names
+// resolving during a migration. With a write-ahead log, the value is logged
+// under name and old is logged as removed, so the rename survives restarts.
+// Backends, bridges and derived buckets that refer to old are not updated.
+//
+// Rename fails with ErrBucketNotFound if there is no bucket named old, and if
+// old is an alias, if name already names another bucket, if the pool's
+// NameRules reject name, or if either is a system bucket. Renaming a bucket
+// to one of its aliases replaces the alias.
+func (p *DataPool) Rename(old, name string) error {
+	if err := p.checkWritable(name); err != nil {
+		return fmt.Errorf("datapool: rename %q: %w", old, err)
+	}
+
+	p.renameMu.Lock()
+	b := p.find(old)
+	if b == nil {
+		p.renameMu.Unlock()
+		return fmt.Errorf("datapool: rename %q: %w", old, ErrBucketNotFound)
+	}
+	if err := p.rename(b, old, name); err != nil {
+		p.renameMu.Unlock()
+		return fmt.Errorf("datapool: rename %q: %w", old, err)
+	}
+	p.renameMu.Unlock()
+
+	if p.wal != nil {
+		p.logRemove(old)
+		b.guard.RLock()
+		u := Update{Bucket: name, Value: b.current(), Timestamp: b.timestamp, Version: b.version}
+		b.guard.RUnlock()
+		if u.Timestamp != 0 {
+			p.logPut(b, u)
+		}
+	}
+	return nil
+}
+
+// rename moves b from old to name in the shards. It must be called with
+// p.renameMu held.
+func (p *DataPool) rename(b *bucket, old, name string) error {
+	switch {
+	case b.system:
+		return ErrSystemBucket
+	case b.name() != old:
+		return fmt.Errorf("is an alias of %q", b.name())
+	case old == name:
+		return nil
+	}
+	if err := checkSameTenant(old, name); err != nil {
+		return err
+	}
+
+	// Add the new name before dropping the old one, so the bucket resolves
+	// by one name or the other throughout.
+	sh := p.shardFor(name)
+	sh.mu.Lock()
+	if other := sh.buckets[name]; other != nil && other != b {
+		sh.mu.Unlock()
+		return fmt.Errorf("bucket %q exists", name)
+	}
+	sh.buckets[name] = b
+	b.aliases = slices.DeleteFunc(b.aliases, func(a string) bool { return a == name })
+	b.label.Store(&name)
+	sh.mu.Unlock()
+
+	sh = p.shardFor(old)
+	sh.mu.Lock()
+	delete(sh.buckets, old)
+	sh.mu.Unlock()
+	return nil
+}
+
+// dropAliases removes the aliases of b from the shards. It must be called
+// with p.renameMu held.
+func (p *DataPool) dropAliases(b *bucket) {
+	for _, alias := range b.aliases {
+		sh := p.shardFor(alias)
+		sh.mu.Lock()
+		if sh.buckets[alias] == b {
+			delete(sh.buckets, alias)
+		}
+		sh.mu.Unlock()
+	}
+	b.aliases = nil
+}
@@ -0,0 +1,151 @@
+package datapool
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlias(t *testing.T) {
+	pool := NewDataPool()
+	users := pool.Bucket("users")
+	require.NoError(t, pool.Alias("accounts", "users"))
+	require.NoError(t, pool.Alias("accounts", "users"), "Aliasing again is a no-op")
+
+	accounts := pool.Bucket("accounts")
+	ts := accounts.Put("alice")
+	value, got, _ := users.Get(0)
+	assert.Equal(t, "alice", value, "Writes through the alias are read through the name")
+	assert.Equal(t, ts, got)
+	assert.Equal(t, "users", accounts.Name())
+	assert.Equal(t, 1, pool.Len())
+	assert.Len(t, pool.Inspect(), 1, "Aliases are not listed as buckets")
+
+	pool.Bucket("other")
+	assert.Error(t, pool.Alias("other", "users"), "Names of other buckets cannot be aliases")
+	assert.ErrorIs(t, pool.Alias(SystemNamespace+"/x", "users"), ErrSystemBucket)
+
+	require.NoError(t, pool.Unalias("accounts"))
+	assert.Error(t, pool.Unalias("accounts"))
+	assert.Error(t, pool.Unalias("users"), "Names are not aliases")
+	fresh := pool.Bucket("accounts")
+	value, _, _ = fresh.Get(0)
+	assert.Nil(t, value, "An unaliased name resolves to a new bucket")
+}
+
+func TestAliasRemoved(t *testing.T) {
+	pool := NewDataPool()
+	require.NoError(t, pool.Alias("old", "new"))
+	pool.Handle("old").Put(1)
+	pool.Clear()
+
+	b := pool.Bucket("old")
+	value, _, _ := b.Get(0)
+	assert.Nil(t, value, "Removing a bucket removes its aliases")
+	assert.Equal(t, "old", b.Name())
+}
+
+func TestRename(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("v1/config")
+	ts := b.Put("value")
+	require.NoError(t, pool.Alias("config", "v1/config"))
+
+	require.NoError(t, pool.Rename("v1/config", "v2/config"))
+	assert.Equal(t, "v2/config", b.Name(), "Handles follow the bucket")
+	value, got, _ := b.Get(0)
+	assert.Equal(t, "value", value)
+	assert.Equal(t, ts, got, "The value keeps its timestamp")
+
+	ts = b.Put("through the handle")
+	renamed := pool.Bucket("v2/config")
+	value, _, _ = renamed.Get(0)
+	assert.Equal(t, "through the handle", value)
+	alias := pool.Bucket("config")
+	value, _, _ = alias.Get(0)
+	assert.Equal(t, "through the handle", value, "Aliases move with the bucket")
+
+	_, err := pool.Lookup("v1/config")
+	assert.ErrorIs(t, err, ErrBucketNotFound, "The old name no longer resolves")
+	assert.Equal(t, 1, pool.Len())
+
+	assert.ErrorIs(t, pool.Rename("missing", "x"), ErrBucketNotFound)
+	assert.Error(t, pool.Rename("config", "x"), "Aliases cannot be renamed")
+	pool.Bucket("taken")
+	assert.Error(t, pool.Rename("v2/config", "taken"))
+
+	require.NoError(t, pool.Rename("v2/config", "config"), "A bucket can take the name of its alias")
+	assert.Equal(t, "config", b.Name())
+	assert.Equal(t, 2, pool.Len())
+}
+
+func TestRenameWatchers(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("a")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := b.Watch(ctx)
+	require.NoError(t, pool.Rename("a", "b"))
+
+	renamed := pool.Bucket("b")
+	ts := renamed.Put(1)
+	u := <-ch
+	assert.Equal(t, "b", u.Bucket)
+	assert.Equal(t, ts, u.Timestamp)
+}
+
+func TestRenameWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+
+	w := openWAL(t, path)
+	pool := NewDataPool(WithWAL(w))
+	ts := pool.Handle("old").Put("value")
+	require.NoError(t, pool.Rename("old", "new"))
+	require.NoError(t, w.Close())
+
+	restored := NewDataPool(WithWAL(openWAL(t, path)))
+	value, got, _ := restored.Handle("new").Get(0)
+	assert.Equal(t, "value", value)
+	assert.Equal(t, ts, got)
+	_, err := restored.Lookup("old")
+	assert.ErrorIs(t, err, ErrBucketNotFound, "The rename survives a restart")
+}
+
+func TestAliasBatches(t *testing.T) {
+	pool := NewDataPool()
+	require.NoError(t, pool.Alias("accounts", "users"))
+	pool.Handle("accounts").Put("alice")
+
+	results := pool.GetMany([]string{"users", "accounts"}, 0)
+	assert.Equal(t, "alice", results["users"].Value)
+	assert.Equal(t, "alice", results["accounts"].Value, "Results are keyed by the names asked for")
+
+	ts := pool.PutMany(map[string]any{"accounts": "bob", "other": 1})
+	assert.NotZero(t, ts["accounts"])
+	value, _, _ := pool.Handle("users").Get(0)
+	assert.Equal(t, "bob", value)
+
+	ts = pool.PutMany(map[string]any{"accounts": "carol", "users": "dave"})
+	assert.Zero(t, ts["accounts"], "Two names of one bucket in a batch are rejected")
+	assert.Zero(t, ts["users"])
+
+	require.NoError(t, pool.Update(func(tx *Tx) error {
+		tx.Put("users", "erin")
+		tx.Put("accounts", "frank")
+		return nil
+	}))
+	value, _, _ = pool.Handle("users").Get(0)
+	assert.Equal(t, "frank", value, "The later Put of a transaction wins")
+
+	view := pool.SnapshotView()
+	value, _, _ = view.Get("accounts", 0)
+	assert.Equal(t, "frank", value, "Views resolve aliases")
+	assert.Equal(t, []string{"other", "users"}, view.Names())
+
+	staging := NewDataPool()
+	staging.Handle("accounts").Put("staged")
+	assert.Error(t, pool.PublishSwap(staging), "An alias cannot pair with a bucket of its name")
+}
@@ -0,0 +1,230 @@
+package datapool
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// AuditRecord describes a value stored in a pool, for keeping the history of
+// its buckets outside the process (see AuditExporter).
+type AuditRecord struct {
+	Bucket string
+	// Source is the last step of the value's provenance: SourcePut for a
+	// plain Put, SourceReplica for a value received from a peer, and so on.
+	Source    Source
+	Timestamp time.Time
+	Version   uint64
+	// Size is the value's size in bytes, as measured by the pool's Sizer if
+	// it has one.
+	Size int
+	// Peer is the peer name of the pool that recorded the write (see
+	// WithPeerName).
+	Peer string
+}
+
+// AuditSink stores batches of audit records, such as OpenSearchAuditSink.
+// WriteAudit may be called again with records it failed to store, so sinks
+// should store records idempotently where they can.
+type AuditSink interface {
+	WriteAudit(ctx context.Context, records []AuditRecord) error
+}
+
+// AuditOption configures an AuditExporter.
+type AuditOption func(*AuditExporter)
+
+// WithAuditBatchSize sets the most records sent to the sink at once, and the
+// number of pending records that triggers a flush before the interval is up.
+// The default is 500.
+func WithAuditBatchSize(n int) AuditOption {
+	return func(e *AuditExporter) {
+		if n > 0 {
+			e.batchSize = n
+		}
+	}
+}
+
+// WithAuditFlushInterval sets how often pending records are flushed. The
+// default is 5s.
+func WithAuditFlushInterval(d time.Duration) AuditOption {
+	return func(e *AuditExporter) {
+		if d > 0 {
+			e.interval = d
+		}
+	}
+}
+
+// WithAuditBuffer sets the most records kept while the sink is slow or
+// failing; beyond it the oldest records are dropped and counted by Dropped.
+// The default is 10000.
+func WithAuditBuffer(n int) AuditOption {
+	return func(e *AuditExporter) {
+		if n > 0 {
+			e.buffer = n
+		}
+	}
+}
+
+// AuditExporter records the writes of a pool while it runs and ships them to
+// a sink in batches.
+type AuditExporter struct {
+	pool      *DataPool
+	sink      AuditSink
+	batchSize int
+	interval  time.Duration
+	buffer    int
+
+	// flushing serializes flushes, so batches reach the sink in order.
+	flushing sync.Mutex
+
+	mu      sync.Mutex
+	pending []AuditRecord
+	// head counts the records that left pending, shipped or dropped, so a
+	// flush can tell which of the records it sent are still pending.
+	head    uint64
+	dropped uint64
+	full    chan struct{}
+}
+
+// NewAuditExporter returns an AuditExporter shipping the pool's writes to
+// sink once it runs.
+func (p *DataPool) NewAuditExporter(sink AuditSink, opts ...AuditOption) *AuditExporter {
+	e := &AuditExporter{
+		pool:      p,
+		sink:      sink,
+		batchSize: 500,
+		interval:  5 * time.Second,
+		buffer:    10000,
+		full:      make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Run records every value stored in the pool, by Put or any other write
+// including values applied from a backend, and flushes the records every
+// flush interval or once a batch is pending, until ctx is done. It then
+// flushes what is left, within the flush interval, and returns ctx.Err().
+// Sink errors are passed to onError if it is not nil, and the records are
+// retried at the next flush. Writes to system buckets are not recorded.
+func (e *AuditExporter) Run(ctx context.Context, onError func(error)) error {
+	remove := e.pool.hooks.audit.add(e.record)
+	defer remove()
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	flush := func(ctx context.Context) {
+		if err := e.Flush(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			remove()
+			final, cancel := context.WithTimeout(context.WithoutCancel(ctx), e.interval)
+			flush(final)
+			cancel()
+			return ctx.Err()
+		case <-ticker.C:
+			flush(ctx)
+		case <-e.full:
+			flush(ctx)
+		}
+	}
+}
+
+// Flush sends the pending records to the sink, a batch at a time, stopping at
+// the first error. Records that were not stored stay pending.
+func (e *AuditExporter) Flush(ctx context.Context) error {
+	e.flushing.Lock()
+	defer e.flushing.Unlock()
+
+	for {
+		e.mu.Lock()
+		n := min(len(e.pending), e.batchSize)
+		batch := e.pending[:n:n]
+		end := e.head + uint64(n)
+		e.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+
+		if err := e.sink.WriteAudit(ctx, batch); err != nil {
+			return err
+		}
+
+		e.mu.Lock()
+		// Records may have been dropped to make room meanwhile.
+		if end > e.head {
+			e.pending = e.pending[end-e.head:]
+			e.head = end
+		}
+		e.mu.Unlock()
+	}
+}
+
+// Pending returns the number of records waiting to be flushed.
+func (e *AuditExporter) Pending() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.pending)
+}
+
+// Dropped returns how many records were dropped to respect the buffer size.
+func (e *AuditExporter) Dropped() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.dropped
+}
+
+// record queues the record of u.
+func (e *AuditExporter) record(b *bucket, u Update) {
+	src := Source{Kind: SourcePut, At: time.Unix(0, u.Timestamp)}
+	if len(u.Provenance) > 0 {
+		src = u.Provenance[len(u.Provenance)-1]
+	}
+	size := sizeOf
+	if m := e.pool.mem; m != nil {
+		size = m.size
+	}
+	r := AuditRecord{
+		Bucket:    b.name(),
+		Source:    src,
+		Timestamp: time.Unix(0, u.Timestamp),
+		Version:   u.Version,
+		Size:      size(u.Value),
+		Peer:      e.pool.opts.peerName,
+	}
+
+	e.mu.Lock()
+	if len(e.pending) >= e.buffer {
+		e.pending = e.pending[1:]
+		e.head++
+		e.dropped++
+	}
+	e.pending = append(e.pending, r)
+	full := len(e.pending) >= e.batchSize
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// fireAudit passes a stored value to the running audit exporters. It must be
+// called without holding any pool lock.
+func (p *DataPool) fireAudit(b *bucket, u Update) {
+	if b.system {
+		return
+	}
+	for _, h := range p.hooks.audit.snapshot() {
+		h.fn(b, u)
+	}
+}
@@ -0,0 +1,128 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type auditCollector struct {
+	mu      sync.Mutex
+	batches [][]AuditRecord
+	fail    error
+}
+
+func (c *auditCollector) WriteAudit(_ context.Context, records []AuditRecord) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fail != nil {
+		return c.fail
+	}
+	c.batches = append(c.batches, append([]AuditRecord(nil), records...))
+	return nil
+}
+
+func (c *auditCollector) records() []AuditRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var all []AuditRecord
+	for _, batch := range c.batches {
+		all = append(all, batch...)
+	}
+	return all
+}
+
+// runAudit runs e until the test ends, returning once it records writes.
+func runAudit(t *testing.T, pool *DataPool, e *AuditExporter) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Run(ctx, func(err error) { t.Log(err) })
+	}()
+	require.Eventually(t, func() bool { return len(pool.hooks.audit.snapshot()) == 1 }, time.Second, time.Millisecond)
+	stop = func() {
+		cancel()
+		<-done
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func TestAuditExporter(t *testing.T) {
+	sink := &auditCollector{}
+	pool := NewDataPool(WithPeerName("api-1"))
+	e := pool.NewAuditExporter(sink, WithAuditFlushInterval(time.Hour))
+	stop := runAudit(t, pool, e)
+
+	b := pool.Bucket("config")
+	ts := b.Put("v1")
+	b.PutFrom("v2", Source{Kind: SourceLoader, Name: "db"})
+	stats := pool.Bucket(SystemStatsBucket)
+	stats.Get(0)
+	assert.Equal(t, 2, e.Pending(), "System buckets are not audited")
+
+	stop()
+	records := sink.records()
+	require.Len(t, records, 2, "Pending records are flushed when Run stops")
+	assert.Equal(t, AuditRecord{
+		Bucket:    "config",
+		Source:    Source{Kind: SourcePut, At: time.Unix(0, ts)},
+		Timestamp: time.Unix(0, ts),
+		Version:   1,
+		Size:      sizeOf("v1"),
+		Peer:      "api-1",
+	}, records[0])
+	assert.Equal(t, SourceLoader, records[1].Source.Kind)
+	assert.Equal(t, "db", records[1].Source.Name)
+
+	b.Put("v3")
+	assert.Zero(t, e.Pending(), "Writes are recorded only while Run runs")
+}
+
+func TestAuditExporterBatches(t *testing.T) {
+	sink := &auditCollector{}
+	pool := NewDataPool()
+	e := pool.NewAuditExporter(sink, WithAuditBatchSize(3), WithAuditFlushInterval(time.Hour))
+	runAudit(t, pool, e)
+
+	b := pool.Bucket("b")
+	for i := range 7 {
+		b.Put(i)
+	}
+	require.Eventually(t, func() bool { return len(sink.records()) >= 6 }, time.Second, time.Millisecond, "A full batch is flushed at once")
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	for _, batch := range sink.batches {
+		assert.LessOrEqual(t, len(batch), 3)
+	}
+}
+
+func TestAuditExporterRetries(t *testing.T) {
+	sink := &auditCollector{fail: errors.New("down")}
+	pool := NewDataPool()
+	e := pool.NewAuditExporter(sink, WithAuditBuffer(3), WithAuditFlushInterval(time.Hour))
+	runAudit(t, pool, e)
+
+	b := pool.Bucket("b")
+	for i := range 5 {
+		b.Put(i)
+	}
+	assert.Error(t, e.Flush(context.Background()))
+	assert.Equal(t, 3, e.Pending(), "Failed records stay pending")
+	assert.Equal(t, uint64(2), e.Dropped(), "The oldest records are dropped beyond the buffer")
+
+	sink.mu.Lock()
+	sink.fail = nil
+	sink.mu.Unlock()
+	require.NoError(t, e.Flush(context.Background()))
+	assert.Zero(t, e.Pending())
+	records := sink.records()
+	require.Len(t, records, 3)
+	assert.Equal(t, uint64(3), records[0].Version)
+}
@@ -0,0 +1,312 @@
+package datapool
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultBackendTimeout bounds backend calls made on behalf of Get and Put.
+const defaultBackendTimeout = 5 * time.Second
+
+// Backend is shared storage behind a pool, letting several processes work on
+// the same freshness-tracked buckets (see WithBackend). Values are ordered by
+// their timestamps: the newest one wins, wherever it was written.
+//
+// The datapoolredis package provides a Redis implementation; MemoryBackend
+// shares state between pools of one process.
+type Backend interface {
+	// Get returns the value stored for name with its timestamp and
+	// provenance, or an Update with a zero Timestamp if there is none.
+	Get(ctx context.Context, name string) (Update, error)
+
+	// Put stores u.Value for u.Bucket, along with its timestamp and
+	// provenance, unless the backend already holds a value with a newer or
+	// equal timestamp, and tells watchers about it.
+	Put(ctx context.Context, u Update) error
+
+	// Watch calls fn with every value stored afterwards, by any writer, until
+	// ctx is done or the backend fails, and returns the reason it stopped.
+	Watch(ctx context.Context, fn func(Update)) error
+}
+
+// SyncBackend applies values written to the pool's backend by other
+// processes as they arrive, until ctx is done or the backend's Watch fails,
+// and returns the reason it stopped. Without it the pool only reads through
+// to the backend for buckets it holds no value for. SyncBackend returns nil
+// at once if the pool has no backend.
+func (p *DataPool) SyncBackend(ctx context.Context) error {
+	be := p.opts.backend
+	if be == nil {
+		return nil
+	}
+	return be.Watch(ctx, func(u Update) {
+		if isReserved(u.Bucket) {
+			return
+		}
+		p.observeDrift(u)
+		b, err := p.bucket(u.Bucket)
+		if err != nil {
+			p.reportError(u.Bucket, err)
+			return
+		}
+		p.apply(b, u)
+	})
+}
+
+// apply stores a value received from the backend if it is newer than the
+// bucket's, and reports whether it did. Unlike put, it keeps the value's
+// timestamp and provenance and does not write it back.
+func (p *DataPool) apply(b *bucket, u Update) bool {
+	return p.applyIf(b, u, nil)
+}
+
+// applyIf is apply settling u against the bucket's value with resolve, if it
+// is not nil, instead of by timestamp. Empty buckets take u regardless.
+func (p *DataPool) applyIf(b *bucket, u Update, resolve ConflictResolver) bool {
+	u.Bucket = b.name()
+	u.Provenance = capProvenance(u.Provenance)
+
+	b.guard.Lock()
+	if b.removed || !b.accepts(u, resolve) {
+		b.guard.Unlock()
+		return false
+	}
+	p.observe(u.Timestamp)
+	// Versions never go back, and values from other pools keep theirs when
+	// ahead, so a value written after another was read is versioned after it
+	// wherever it was written.
+	u.Version = max(b.store(u.Value, u.Timestamp), u.Version)
+	b.version = u.Version
+	b.fence = max(b.fence, u.Fence)
+	b.provenance = u.Provenance
+	b.schema = u.Schema
+	watchers := b.watchers
+	b.guard.Unlock()
+
+	p.deliverUpdate(b, watchers, u)
+	p.firePut(b.name(), u.Value, u.Timestamp)
+	p.fireAudit(b, u)
+	p.logStored(b, u)
+	p.triggerDerived(b.name())
+	p.checkMemoryPressure(u.Timestamp)
+	return true
+}
+
+// accepts reports whether u replaces the bucket's value. It must be called
+// with b.guard held.
+func (b *bucket) accepts(u Update, resolve ConflictResolver) bool {
+	if resolve == nil || b.timestamp == 0 {
+		return u.Timestamp > b.timestamp
+	}
+	local := Update{
+		Bucket:     b.name(),
+		Value:      b.current(),
+		Timestamp:  b.timestamp,
+		Provenance: b.provenance,
+		Schema:     b.schema,
+		Version:    b.version,
+		Fence:      b.fence,
+	}
+	return resolve(local, u)
+}
+
+// readThrough fetches the named bucket from the backend after a local miss.
+// It returns the stored value, or a zero timestamp if there is none. In
+// offline mode, misses are not read through while the backend is down.
+func (p *DataPool) readThrough(b *bucket) (any, int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.opts.backendTimeout)
+	defer cancel()
+
+	u, err := p.fetch(ctx, b.name(), ConsistencyDefault)
+	if err != nil {
+		// In offline mode, fetch reports errors itself.
+		if !p.offlineMode() {
+			p.reportError(b.name(), err)
+		}
+		return nil, 0
+	}
+	if u.Timestamp == 0 {
+		return nil, 0
+	}
+	p.apply(b, u)
+
+	b.guard.RLock()
+	defer b.guard.RUnlock()
+	value, ts, _ := b.read(p, 0)
+	return value, ts
+}
+
+// fetch reads the named bucket from the backend at level. In offline mode it
+// fails with ErrOffline while the backend is down, takes the pool offline
+// when the call fails, and replays queued writes when it succeeds.
+func (p *DataPool) fetch(ctx context.Context, name string, level Consistency) (Update, error) {
+	offline := p.offlineMode()
+	if offline && !p.backendAvailable() {
+		return Update{}, ErrOffline
+	}
+
+	u, err := p.backendGet(ctx, name, level)
+	if err != nil {
+		if offline {
+			p.backendFailed(name, err)
+		}
+		return Update{}, err
+	}
+	if offline && p.isOffline() {
+		if err := p.replay(ctx); err != nil {
+			p.backendFailed(name, err)
+		}
+	}
+	return u, nil
+}
+
+// writeThrough stores a value Put locally in the backend at level. Its
+// provenance gains a SourceReplica step naming this pool, which pools reading
+// it back from the backend keep. In offline mode, writes are queued while the
+// backend is down and replayed before the next write once it is back.
+//
+// Errors are reported, except at ConsistencyLeader and ConsistencyQuorum,
+// which return them; at ConsistencyLocal the write happens in the background.
+func (p *DataPool) writeThrough(ctx context.Context, u Update, level Consistency) error {
+	chain := u.Provenance
+	if chain == nil {
+		chain = []Source{{Kind: SourcePut, At: time.Unix(0, u.Timestamp)}}
+	}
+	chain = append(chain[:len(chain):len(chain)], Source{Kind: SourceReplica, Name: p.opts.peerName, At: p.opts.clock.Now()})
+	u.Provenance = capProvenance(chain)
+
+	switch level {
+	case ConsistencyLeader, ConsistencyQuorum:
+		ctx, cancel := context.WithTimeout(ctx, p.opts.backendTimeout)
+		defer cancel()
+		return p.push(ctx, u, level)
+	case ConsistencyLocal:
+		// Once the pool is closed, as when Close stores throttled values,
+		// the write completes before Close returns.
+		if !p.goBackground(func() { p.pushReporting(u) }) {
+			p.pushReporting(u)
+		}
+	default:
+		p.pushReporting(u)
+	}
+	return nil
+}
+
+// pushReporting stores u in the backend within the backend timeout,
+// reporting errors.
+func (p *DataPool) pushReporting(u Update) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.opts.backendTimeout)
+	defer cancel()
+
+	// In offline mode, push reports errors itself.
+	if err := p.push(ctx, u, ConsistencyDefault); err != nil && !p.offlineMode() {
+		p.reportError(u.Bucket, err)
+	}
+}
+
+// push stores u in the backend at level. In offline mode it queues u and
+// fails with ErrOffline while the backend is down, and otherwise replays
+// queued writes first; when the call fails, u is queued and the pool taken
+// offline.
+func (p *DataPool) push(ctx context.Context, u Update, level Consistency) error {
+	if !p.offlineMode() {
+		return p.backendPut(ctx, u, level)
+	}
+
+	if !p.backendAvailable() {
+		p.enqueue(u)
+		return ErrOffline
+	}
+	err := p.replay(ctx)
+	if err == nil {
+		err = p.backendPut(ctx, u, level)
+	}
+	if err != nil {
+		p.enqueue(u)
+		p.backendFailed(u.Bucket, err)
+	}
+	return err
+}
+
+// MemoryBackend is a Backend held in memory, shared by the pools it is
+// given to. It is mostly useful for testing code written for a remote backend.
+// The zero MemoryBackend is ready to use.
+type MemoryBackend struct {
+	mu       sync.Mutex
+	values   map[string]Update
+	watchers map[*memoryWatch]struct{}
+}
+
+type memoryWatch struct {
+	updates chan Update
+	done    <-chan struct{}
+}
+
+var _ Backend = (*MemoryBackend)(nil)
+
+// Get implements Backend.
+func (m *MemoryBackend) Get(_ context.Context, name string) (Update, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.values[name], nil
+}
+
+// Put implements Backend. Watchers are sent the value before Put returns.
+func (m *MemoryBackend) Put(ctx context.Context, u Update) error {
+	m.mu.Lock()
+	if m.values[u.Bucket].Timestamp >= u.Timestamp {
+		m.mu.Unlock()
+		return nil
+	}
+	if m.values == nil {
+		m.values = make(map[string]Update)
+	}
+	m.values[u.Bucket] = u
+
+	watchers := make([]*memoryWatch, 0, len(m.watchers))
+	for w := range m.watchers {
+		watchers = append(watchers, w)
+	}
+	m.mu.Unlock()
+
+	// Sending without the lock lets watchers Put in turn; they may then see
+	// updates out of order, which timestamps resolve.
+	for _, w := range watchers {
+		select {
+		case w.updates <- u:
+		case <-w.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Watch implements Backend.
+func (m *MemoryBackend) Watch(ctx context.Context, fn func(Update)) error {
+	w := &memoryWatch{updates: make(chan Update), done: ctx.Done()}
+
+	m.mu.Lock()
+	if m.watchers == nil {
+		m.watchers = make(map[*memoryWatch]struct{})
+	}
+	m.watchers[w] = struct{}{}
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.watchers, w)
+		m.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case u := <-w.updates:
+			fn(u)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendReadThrough(t *testing.T) {
+	backend := &MemoryBackend{}
+	a := NewDataPool(WithBackend(backend))
+	b := NewDataPool(WithBackend(backend))
+
+	ba := a.Bucket("config")
+	ts := ba.Put("v1")
+
+	bb := b.Bucket("config")
+	val, got, fresh := bb.Get(0)
+	assert.Equal(t, "v1", val, "Local misses read through to the backend")
+	assert.Equal(t, ts, got, "The writer's timestamp is kept")
+	assert.True(t, fresh)
+
+	// Later reads are served locally, even if the backend moved on.
+	backend.Put(context.Background(), Update{Bucket: "config", Value: "v2", Timestamp: ts + 1})
+	val, _, _ = bb.Get(0)
+	assert.Equal(t, "v1", val)
+
+	missing := b.Bucket("missing")
+	val, got, _ = missing.Get(0)
+	assert.Nil(t, val)
+	assert.Zero(t, got)
+}
+
+func TestSyncBackend(t *testing.T) {
+	backend := &MemoryBackend{}
+	a := NewDataPool(WithBackend(backend))
+	b := NewDataPool(WithBackend(backend))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	synced := make(chan error, 1)
+	go func() { synced <- b.SyncBackend(ctx) }()
+
+	bb := b.Bucket("config")
+	updates := bb.Watch(ctx)
+
+	// Wait for the sync to subscribe before writing.
+	require.Eventually(t, func() bool {
+		backend.mu.Lock()
+		defer backend.mu.Unlock()
+		return len(backend.watchers) == 1
+	}, time.Second, time.Millisecond)
+
+	ba := a.Bucket("config")
+	ts := ba.Put("v1")
+
+	u := receive(t, updates)
+	assert.Equal(t, "v1", u.Value)
+	assert.Equal(t, ts, u.Timestamp)
+	assert.Equal(t, bb.Provenance(), u.Provenance)
+
+	// Values older than the local one are ignored.
+	assert.False(t, b.apply(bb.b, Update{Value: "stale", Timestamp: ts - 1}))
+	val, _, _ := bb.Get(0)
+	assert.Equal(t, "v1", val)
+
+	cancel()
+	assert.ErrorIs(t, <-synced, context.Canceled)
+}
+
+func TestSyncBackendWithoutBackend(t *testing.T) {
+	pool := NewDataPool()
+	assert.NoError(t, pool.SyncBackend(context.Background()))
+}
+
+func TestBackendStampsAfterRemote(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	backend := &MemoryBackend{}
+	pool := NewDataPool(WithClock(clock), WithBackend(backend))
+
+	// A writer whose clock runs ahead of ours.
+	ahead := time.Unix(2000, 0).UnixNano()
+	require.NoError(t, backend.Put(context.Background(), Update{Bucket: "config", Value: "remote", Timestamp: ahead}))
+
+	b := pool.Bucket("config")
+	_, ts, _ := b.Get(0)
+	assert.Equal(t, ahead, ts)
+	assert.Greater(t, b.Put("local"), ahead, "Local writes stay newer than values seen from the backend")
+}
+
+type failingBackend struct{ err error }
+
+func (f failingBackend) Get(context.Context, string) (Update, error)      { return Update{}, f.err }
+func (f failingBackend) Put(context.Context, Update) error                { return f.err }
+func (f failingBackend) Watch(ctx context.Context, fn func(Update)) error { return f.err }
+
+func TestBackendErrors(t *testing.T) {
+	errDown := errors.New("backend down")
+	type report struct {
+		bucket string
+		err    error
+	}
+	var reports []report
+	pool := NewDataPool(
+		WithBackend(failingBackend{errDown}),
+		WithErrorHandler(func(bucket string, err error) {
+			reports = append(reports, report{bucket, err})
+		}),
+	)
+
+	b := pool.Bucket("config")
+	ts := b.Put("v1")
+	assert.NotZero(t, ts, "Local writes succeed when the backend fails")
+
+	miss := pool.Bucket("miss")
+	val, _, _ := miss.Get(0)
+	assert.Nil(t, val)
+
+	assert.Equal(t, []report{{"config", errDown}, {"miss", errDown}}, reports)
+	assert.ErrorIs(t, pool.SyncBackend(context.Background()), errDown)
+}
+
+func TestBackendBatchWrites(t *testing.T) {
+	backend := &MemoryBackend{}
+	pool := NewDataPool(WithBackend(backend))
+
+	timestamps := pool.PutMany(map[string]any{"a": 1, "b": 2})
+	require.NoError(t, pool.Update(func(tx *Tx) error {
+		tx.Put("c", 3)
+		return nil
+	}))
+
+	for name, want := range map[string]any{"a": 1, "b": 2, "c": 3} {
+		u, err := backend.Get(context.Background(), name)
+		require.NoError(t, err)
+		assert.Equal(t, want, u.Value, name)
+		assert.NotZero(t, u.Timestamp)
+	}
+	u, _ := backend.Get(context.Background(), "a")
+	assert.Equal(t, timestamps["a"], u.Timestamp)
+}
+
+func TestBackendProvenance(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	backend := &MemoryBackend{}
+	a := NewDataPool(WithClock(clock), WithBackend(backend), WithPeerName("a"))
+	b := NewDataPool(WithClock(clock), WithBackend(backend), WithPeerName("b"))
+
+	loaded := Source{Kind: SourceLoader, Name: "rates-api", At: time.Unix(999, 0)}
+	ba := a.Bucket("rates")
+	ts := ba.PutFrom(1.08, loaded)
+	assert.Equal(t, []Source{loaded}, ba.Provenance(), "The writer's own chain has no replica step")
+
+	replicated := []Source{loaded, {Kind: SourceReplica, Name: "a", At: time.Unix(1000, 0)}}
+	u, err := backend.Get(context.Background(), "rates")
+	require.NoError(t, err)
+	assert.Equal(t, replicated, u.Provenance)
+
+	bb := b.Bucket("rates")
+	_, got, _ := bb.Get(0)
+	assert.Equal(t, ts, got)
+	assert.Equal(t, replicated, bb.Provenance())
+
+	// A plain Put replicates as a put step followed by the peer.
+	plain := a.Bucket("plain")
+	ts = plain.Put(1)
+	u, _ = backend.Get(context.Background(), "plain")
+	assert.Equal(t, []Source{
+		{Kind: SourcePut, At: time.Unix(0, ts)},
+		{Kind: SourceReplica, Name: "a", At: time.Unix(1000, 0)},
+	}, u.Provenance)
+}
@@ -0,0 +1,203 @@
+package datapool
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Result is the outcome of reading one bucket in a batch.
+type Result struct {
+	Value     any
+	Timestamp int64
+	// Fresh reports whether Timestamp is newer than the batch's comparison
+	// timestamp.
+	Fresh bool
+	// Err is set, with a nil Value, for a bucket holding an error stored
+	// with PutError: it wraps ErrCachedError and that error, as from GetE.
+	Err error
+}
+
+// GetMany reads the named buckets at a single consistent point: all of them
+// are read-locked together, so no Put or PutMany can land between the reads.
+// The result has an entry for every name, including aliases (see Alias);
+// buckets that do not exist read as empty and are not created.
+func (p *DataPool) GetMany(names []string, since int64) map[string]Result {
+	results := make(map[string]Result, len(names))
+	buckets := make([]*bucket, 0, len(names))
+	found := make(map[string]*bucket, len(names))
+	for _, name := range names {
+		if b := p.find(name); b != nil {
+			if b.system {
+				p.refreshSystem(b)
+			}
+			buckets = append(buckets, b)
+			found[name] = b
+		} else {
+			results[name] = Result{}
+		}
+	}
+	buckets = lockOrder(buckets)
+
+	if p.trackAccess {
+		now := p.now()
+		for _, b := range buckets {
+			b.lastAccess.Store(now)
+			b.hits.Add(1)
+		}
+	}
+
+	read := make(map[*bucket]Result, len(buckets))
+	for _, b := range buckets {
+		b.guard.RLock()
+	}
+	for _, b := range buckets {
+		value, ts, fresh := b.read(p, since)
+		if b.removed {
+			// Evicted between lookup and locking; it no longer exists.
+			value, ts, fresh = nil, 0, false
+		}
+		read[b] = Result{Value: value, Timestamp: ts, Fresh: fresh}
+		if b.cachedErr != nil && ts != 0 {
+			read[b] = Result{Timestamp: ts, Fresh: fresh, Err: b.wrapCachedError()}
+		}
+	}
+	for _, b := range buckets {
+		b.guard.RUnlock()
+	}
+
+	for _, b := range buckets {
+		r := read[b]
+		b.stats.recordRead(r.Timestamp, r.Fresh)
+		p.recordSLO(b, r.Timestamp)
+	}
+	for name, b := range found {
+		r := read[b]
+		if b.copyValues.Load() {
+			r.Value = copyValue(r.Value)
+		}
+		results[name] = r
+	}
+	if m := p.opts.metrics; m != nil {
+		for _, b := range buckets {
+			r := read[b]
+			m.RecordGet(b.name(), r.Timestamp != 0, r.Fresh)
+		}
+	}
+	return results
+}
+
+// PutMany stores all values atomically under a single timestamp, creating
+// buckets as needed: a concurrent GetMany sees either none or all of the new
+// values. It returns the timestamp of every stored value by bucket name;
+// values whose bucket was evicted while the batch was prepared, whose name the
+// pool's NameRules reject, whose bucket is in the SystemNamespace or claimed
+// (see Bucket.Claim) or whose bucket's validator or type (see RegisterType)
+// rejects them get 0, as do names resolving to the same bucket through an
+// alias (see Alias).
+func (p *DataPool) PutMany(values map[string]any) map[string]int64 {
+	timestamps := make(map[string]int64, len(values))
+	buckets := make([]*bucket, 0, len(values))
+	named := make(map[*bucket]string, len(values))
+	clashes := make(map[*bucket]bool)
+	for name := range values {
+		b, err := p.bucket(name)
+		if err != nil {
+			p.reportError(name, err)
+			timestamps[name] = 0
+			continue
+		}
+		if p.rejectSystem(b) {
+			timestamps[name] = 0
+			continue
+		}
+		if other, ok := named[b]; ok {
+			p.reportError(name, fmt.Errorf("datapool: put many: %q and %q name the same bucket", other, name))
+			timestamps[name], timestamps[other] = 0, 0
+			clashes[b] = true
+			continue
+		}
+		named[b] = name
+		buckets = append(buckets, b)
+	}
+	buckets = lockOrder(slices.DeleteFunc(buckets, func(b *bucket) bool { return clashes[b] }))
+
+	watchers := make([][]*watcher, len(buckets))
+	versions := make([]uint64, len(buckets))
+	stored := make([]any, len(buckets))
+	errs := make([]error, len(buckets))
+	end := p.beginCommit()
+	for _, b := range buckets {
+		b.guard.Lock()
+	}
+	ts := p.stamp()
+	now := p.now()
+	for i, b := range buckets {
+		if b.removed {
+			timestamps[named[b]] = 0
+			continue
+		}
+		if errs[i] = b.checkClaim(now, 0); errs[i] != nil {
+			timestamps[named[b]] = 0
+			continue
+		}
+		if stored[i], errs[i] = b.prepare(values[named[b]]); errs[i] != nil {
+			timestamps[named[b]] = 0
+			continue
+		}
+		versions[i] = b.store(stored[i], ts)
+		timestamps[named[b]] = ts
+		watchers[i] = b.watchers
+	}
+	for _, b := range buckets {
+		b.guard.Unlock()
+	}
+	end()
+
+	for i, b := range buckets {
+		if errs[i] != nil {
+			p.reportError(b.name(), errs[i])
+		}
+		if timestamps[named[b]] != 0 {
+			p.notifyPut(context.Background(), b, watchers[i], Update{Bucket: b.name(), Value: stored[i], Timestamp: ts, Version: versions[i]}, ConsistencyDefault)
+		}
+	}
+	p.checkMemoryPressure(ts)
+
+	return timestamps
+}
+
+// beginCommit marks the start of a write of several buckets at once and
+// returns the function marking its end, to call once the buckets are
+// unlocked.
+func (p *DataPool) beginCommit() func() {
+	p.commitsStarted.Add(1)
+	return func() { p.commitsDone.Add(1) }
+}
+
+// commitGeneration returns the number of writes of several buckets started so
+// far, and whether none is in progress. State read from several buckets
+// between two calls returning the same generation, with none in progress,
+// holds none or all of each such write.
+func (p *DataPool) commitGeneration() (uint64, bool) {
+	done := p.commitsDone.Load()
+	started := p.commitsStarted.Load()
+	return started, started == done
+}
+
+// lockOrder sorts buckets by id and drops duplicates. Locks on several buckets
+// must always be taken in this order to avoid deadlocks.
+func lockOrder(buckets []*bucket) []*bucket {
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].id < buckets[j].id
+	})
+
+	out := buckets[:0]
+	for _, b := range buckets {
+		if len(out) == 0 || out[len(out)-1] != b {
+			out = append(out, b)
+		}
+	}
+	return out
+}
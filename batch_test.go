@@ -0,0 +1,108 @@
+package datapool
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMany(t *testing.T) {
+	pool := NewDataPool()
+	a := pool.Bucket("a")
+	tsA := a.Put(1)
+	b := pool.Bucket("b")
+	tsB := b.Put(2)
+	pool.Bucket("empty")
+
+	results := pool.GetMany([]string{"a", "b", "empty", "missing", "a"}, tsA)
+	assert.Equal(t, map[string]Result{
+		"a":       {Value: 1, Timestamp: tsA, Fresh: false},
+		"b":       {Value: 2, Timestamp: tsB, Fresh: true},
+		"empty":   {},
+		"missing": {},
+	}, results)
+	assert.Equal(t, 3, pool.Len(), "GetMany does not create buckets")
+}
+
+func TestPutMany(t *testing.T) {
+	pool := NewDataPool()
+	existing := pool.Bucket("config")
+	existing.Put("old")
+
+	timestamps := pool.PutMany(map[string]any{
+		"config":   "new",
+		"checksum": "abc123",
+	})
+	require.Len(t, timestamps, 2)
+	assert.Equal(t, timestamps["config"], timestamps["checksum"], "One timestamp for the whole batch")
+
+	val, ts, _ := existing.Get(0)
+	assert.Equal(t, "new", val)
+	assert.Equal(t, timestamps["config"], ts)
+
+	checksum := pool.Bucket("checksum")
+	val, _, _ = checksum.Get(0)
+	assert.Equal(t, "abc123", val)
+}
+
+func TestBatchConsistentReadPoint(t *testing.T) {
+	pool := NewDataPool()
+	pool.PutMany(map[string]any{"config": 0, "checksum": 0})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 500; i++ {
+			pool.PutMany(map[string]any{"config": i, "checksum": i})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 500; i++ {
+			results := pool.GetMany([]string{"checksum", "config"}, 0)
+			if !assert.Equal(t, results["config"], results["checksum"], "Reads must not be torn") {
+				return
+			}
+		}
+	}()
+	wg.Wait()
+}
+
+func TestPutManyCallbacks(t *testing.T) {
+	var names []string
+	metrics := newRecordingMetrics()
+	pool := NewDataPool(WithMetrics(metrics), WithPutCallback(func(name string, _ any, _ int64) {
+		names = append(names, name)
+	}))
+
+	pool.PutMany(map[string]any{"a": 1, "b": 2})
+	pool.GetMany([]string{"a", "b"}, 0)
+
+	assert.ElementsMatch(t, []string{"a", "b"}, names)
+	assert.Equal(t, map[string]int{"a": 1, "b": 1}, metrics.puts)
+	assert.Equal(t, map[string]int{"a": 1, "b": 1}, metrics.gets)
+}
+
+func TestLockOrder(t *testing.T) {
+	b0, b1, b2 := &bucket{id: 0}, &bucket{id: 1}, &bucket{id: 2}
+	assert.Equal(t, []*bucket{b0, b1, b2}, lockOrder([]*bucket{b2, b0, b2, b1, b0}))
+}
+
+func BenchmarkGetMany(b *testing.B) {
+	pool := NewDataPool()
+	names := make([]string, 30)
+	values := make(map[string]any, len(names))
+	for i := range names {
+		names[i] = string(rune('a'+i%26)) + string(rune('0'+i/26))
+		values[names[i]] = i
+	}
+	pool.PutMany(values)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pool.GetMany(names, 0)
+	}
+}
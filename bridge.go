@@ -0,0 +1,334 @@
+package datapool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MessageBus is a publish/subscribe transport, such as NATS subjects or Kafka
+// topics, that a Bridge propagates writes over. Adapters are a few lines of
+// code around a client (see the README); MemoryBus connects pools of one
+// process.
+type MessageBus interface {
+	// Publish sends msg to the subscribers of topic.
+	Publish(ctx context.Context, topic string, msg []byte) error
+
+	// Subscribe calls fn with every message published to topic afterwards,
+	// one at a time, until ctx is done or the subscription fails, and
+	// returns the reason it stopped.
+	Subscribe(ctx context.Context, topic string, fn func(msg []byte)) error
+}
+
+// ConflictResolver decides whether a value received by a Bridge replaces the
+// bucket's local value. It is called with the bucket's lock held, so it must
+// not use the pool.
+type ConflictResolver func(local, remote Update) bool
+
+// LastWriterWins is the default ConflictResolver: the value with the later
+// timestamp wins, and on equal timestamps the one with the higher version, so
+// that every pool settles on the same value.
+func LastWriterWins(local, remote Update) bool {
+	if remote.Timestamp != local.Timestamp {
+		return remote.Timestamp > local.Timestamp
+	}
+	return remote.Version > local.Version
+}
+
+// HighestVersionWins is a ConflictResolver keeping the value with the higher
+// version, and on equal versions the later one. Versions grow with every
+// write to a bucket wherever it was written, so this favors the value that
+// has seen more writes even if a peer's clock runs ahead.
+func HighestVersionWins(local, remote Update) bool {
+	if remote.Version != local.Version {
+		return remote.Version > local.Version
+	}
+	return remote.Timestamp > local.Timestamp
+}
+
+// BridgeOption configures a Bridge.
+type BridgeOption func(*Bridge)
+
+// WithConflictResolver sets how a Bridge settles a received value against
+// the bucket's local one. The default is LastWriterWins.
+func WithConflictResolver(resolve ConflictResolver) BridgeOption {
+	return func(br *Bridge) {
+		if resolve != nil {
+			br.resolve = resolve
+		}
+	}
+}
+
+// WithBridgeBuffer sets the most local writes waiting to be published; beyond
+// it writes are not published, and reported with an error wrapping
+// ErrBackpressure. The default is 1024.
+func WithBridgeBuffer(n int) BridgeOption {
+	return func(br *Bridge) {
+		if n > 0 {
+			br.buffer = n
+		}
+	}
+}
+
+// Bridge keeps pools in several processes eventually consistent over a
+// message bus: it publishes the pool's writes to a topic and applies the
+// writes other pools publish to it.
+type Bridge struct {
+	pool    *DataPool
+	bus     MessageBus
+	topic   string
+	resolve ConflictResolver
+	buffer  int
+	// origin tells the bridge's own messages apart when the bus sends them
+	// back.
+	origin string
+}
+
+// bridgeMessage is published for every local write.
+type bridgeMessage struct {
+	Origin     string   `json:"origin"`
+	Bucket     string   `json:"bucket"`
+	Value      []byte   `json:"value"`
+	Codec      string   `json:"codec"`
+	Encoded    bool     `json:"encoded,omitempty"`
+	Timestamp  int64    `json:"timestamp"`
+	Provenance []Source `json:"provenance,omitempty"`
+	Schema     int      `json:"schema,omitempty"`
+	Version    uint64   `json:"version,omitempty"`
+	Fence      uint64   `json:"fence,omitempty"`
+}
+
+// NewBridge returns a Bridge propagating the pool's writes over topic of bus
+// once it runs. Every pool bridged over a topic must use the same codec (see
+// WithCodec).
+func (p *DataPool) NewBridge(bus MessageBus, topic string, opts ...BridgeOption) *Bridge {
+	br := &Bridge{
+		pool:    p,
+		bus:     bus,
+		topic:   topic,
+		resolve: LastWriterWins,
+		buffer:  1024,
+		origin:  strconv.FormatUint(rand.Uint64(), 36),
+	}
+	for _, opt := range opts {
+		opt(br)
+	}
+	return br
+}
+
+// Run publishes every value written to the pool locally, by Put or any other
+// write but values applied from a backend or a bridge, and applies the values
+// other pools publish, until ctx is done or the subscription fails, and
+// returns the reason it stopped. Received values are settled against local
+// ones by the bridge's ConflictResolver and, like values applied from a
+// backend, keep their timestamp, version and provenance and are not written
+// back anywhere. Published values gain a SourceReplica provenance step naming
+// the pool (see WithPeerName), so received values feed drift detection (see
+// WithDriftDetection).
+//
+// Values are encoded with the pool's codec, and received values decoded into
+// the types it decodes into an any, except for Encoded values, which stay
+// encoded. Errors encoding, publishing and decoding values are passed to
+// onError if it is not nil. Writes to system buckets are not propagated.
+func (br *Bridge) Run(ctx context.Context, onError func(error)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	report := func(err error) {
+		if onError != nil {
+			onError(err)
+		}
+	}
+
+	queue := make(chan Update, br.buffer)
+	remove := br.pool.hooks.publish.add(func(b *bucket, u Update) {
+		select {
+		case queue <- u:
+		default:
+			report(fmt.Errorf("%w: bridge queue full, dropped write of %q", ErrBackpressure, b.name()))
+		}
+	})
+	defer remove()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case u := <-queue:
+				if err := br.publish(ctx, u); err != nil && ctx.Err() == nil {
+					report(err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	err := br.bus.Subscribe(ctx, br.topic, func(msg []byte) {
+		if err := br.receive(msg); err != nil {
+			report(err)
+		}
+	})
+	cancel()
+	wg.Wait()
+	return err
+}
+
+// publish sends u to the topic.
+func (br *Bridge) publish(ctx context.Context, u Update) error {
+	p := br.pool
+	codec := p.opts.codec
+	msg := bridgeMessage{
+		Origin:    br.origin,
+		Bucket:    u.Bucket,
+		Codec:     codec.Name(),
+		Timestamp: u.Timestamp,
+		Schema:    u.Schema,
+		Version:   u.Version,
+		Fence:     u.Fence,
+	}
+	if e, ok := u.Value.(Encoded); ok {
+		msg.Value, msg.Codec, msg.Encoded = e.Data, e.Codec.Name(), true
+	} else {
+		data, err := codec.Marshal(u.Value)
+		if err != nil {
+			return fmt.Errorf("datapool: bridge: encode %s value of %q: %w", codec.Name(), u.Bucket, err)
+		}
+		msg.Value = data
+	}
+	chain := u.Provenance
+	if chain == nil {
+		chain = []Source{{Kind: SourcePut, At: time.Unix(0, u.Timestamp)}}
+	}
+	chain = append(chain[:len(chain):len(chain)], Source{Kind: SourceReplica, Name: p.opts.peerName, At: p.opts.clock.Now()})
+	msg.Provenance = capProvenance(chain)
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("datapool: bridge: %q: %w", u.Bucket, err)
+	}
+	if err := br.bus.Publish(ctx, br.topic, data); err != nil {
+		return fmt.Errorf("datapool: bridge: publish %q: %w", u.Bucket, err)
+	}
+	return nil
+}
+
+// receive applies a message published by another pool.
+func (br *Bridge) receive(data []byte) error {
+	var msg bridgeMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return fmt.Errorf("datapool: bridge: bad message: %w", err)
+	}
+	if msg.Origin == br.origin || isReserved(msg.Bucket) {
+		return nil
+	}
+
+	p := br.pool
+	codec := p.opts.codec
+	if msg.Codec != codec.Name() {
+		return fmt.Errorf("datapool: bridge: %q: value encoded with %s, not %s", msg.Bucket, msg.Codec, codec.Name())
+	}
+	u := Update{
+		Bucket:     msg.Bucket,
+		Timestamp:  msg.Timestamp,
+		Provenance: msg.Provenance,
+		Schema:     msg.Schema,
+		Version:    msg.Version,
+		Fence:      msg.Fence,
+	}
+	if msg.Encoded {
+		u.Value = Encoded{Codec: codec, Data: msg.Value}
+	} else if err := codec.Unmarshal(msg.Value, &u.Value); err != nil {
+		return fmt.Errorf("datapool: bridge: decode %s value of %q: %w", codec.Name(), msg.Bucket, err)
+	}
+
+	p.observeDrift(u)
+	b, err := p.bucket(msg.Bucket)
+	if err != nil {
+		return fmt.Errorf("datapool: bridge: %w", err)
+	}
+	p.applyIf(b, u, br.resolve)
+	return nil
+}
+
+// firePublish passes a value written locally to the running bridges. It must
+// be called without holding any pool lock.
+func (p *DataPool) firePublish(b *bucket, u Update) {
+	if b.system {
+		return
+	}
+	for _, h := range p.hooks.publish.snapshot() {
+		h.fn(b, u)
+	}
+}
+
+// MemoryBus is a MessageBus held in memory, connecting the bridges of pools
+// of one process. It is mostly useful for testing code written for a remote
+// bus. The zero MemoryBus is ready to use.
+type MemoryBus struct {
+	mu   sync.Mutex
+	subs map[string]map[*memorySub]struct{}
+}
+
+type memorySub struct {
+	msgs chan []byte
+	done <-chan struct{}
+}
+
+var _ MessageBus = (*MemoryBus)(nil)
+
+// Publish implements MessageBus. Subscribers are sent the message before
+// Publish returns.
+func (m *MemoryBus) Publish(ctx context.Context, topic string, msg []byte) error {
+	m.mu.Lock()
+	subs := make([]*memorySub, 0, len(m.subs[topic]))
+	for s := range m.subs[topic] {
+		subs = append(subs, s)
+	}
+	m.mu.Unlock()
+
+	for _, s := range subs {
+		select {
+		case s.msgs <- msg:
+		case <-s.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Subscribe implements MessageBus.
+func (m *MemoryBus) Subscribe(ctx context.Context, topic string, fn func(msg []byte)) error {
+	s := &memorySub{msgs: make(chan []byte), done: ctx.Done()}
+
+	m.mu.Lock()
+	if m.subs == nil {
+		m.subs = make(map[string]map[*memorySub]struct{})
+	}
+	if m.subs[topic] == nil {
+		m.subs[topic] = make(map[*memorySub]struct{})
+	}
+	m.subs[topic][s] = struct{}{}
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.subs[topic], s)
+		m.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case msg := <-s.msgs:
+			fn(msg)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
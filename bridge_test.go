@@ -0,0 +1,233 @@
+package datapool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingBus counts the messages published on a MemoryBus, and blocks
+// publishing while block is not nil.
+type countingBus struct {
+	MemoryBus
+	mu        sync.Mutex
+	published int
+	block     chan struct{}
+}
+
+func (c *countingBus) Publish(ctx context.Context, topic string, msg []byte) error {
+	c.mu.Lock()
+	c.published++
+	block := c.block
+	c.mu.Unlock()
+	if block != nil {
+		<-block
+	}
+	return c.MemoryBus.Publish(ctx, topic, msg)
+}
+
+func (c *countingBus) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.published
+}
+
+func (m *MemoryBus) subscribers(topic string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.subs[topic])
+}
+
+// runBridge runs br until the test ends, returning once it is subscribed and
+// records writes. Errors are sent to errs if it is not nil.
+func runBridge(t *testing.T, bus *MemoryBus, br *Bridge, errs chan<- error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	subs := bus.subscribers(br.topic)
+	hooks := len(br.pool.hooks.publish.snapshot())
+	go func() {
+		defer close(done)
+		br.Run(ctx, func(err error) {
+			if errs != nil {
+				errs <- err
+			}
+		})
+	}()
+	require.Eventually(t, func() bool {
+		return bus.subscribers(br.topic) > subs && len(br.pool.hooks.publish.snapshot()) > hooks
+	}, time.Second, time.Millisecond)
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestBridge(t *testing.T) {
+	bus := &countingBus{}
+	a := NewDataPool(WithPeerName("api-1"))
+	b := NewDataPool(WithPeerName("api-2"))
+	runBridge(t, &bus.MemoryBus, a.NewBridge(bus, "pools"), nil)
+	runBridge(t, &bus.MemoryBus, b.NewBridge(bus, "pools"), nil)
+
+	ba := a.Bucket("config")
+	ts := ba.Put(map[string]any{"rps": 10.0})
+	bb := b.Bucket("config")
+	require.Eventually(t, func() bool { _, got, _ := bb.Get(0); return got == ts }, time.Second, time.Millisecond)
+	value, _, _ := bb.Get(0)
+	assert.Equal(t, map[string]any{"rps": 10.0}, value)
+	assert.Equal(t, ba.Version(), bb.Version())
+	chain := bb.Provenance()
+	require.Len(t, chain, 2)
+	assert.Equal(t, SourcePut, chain[0].Kind)
+	assert.Equal(t, Source{Kind: SourceReplica, Name: "api-1", At: chain[1].At}, chain[1])
+	assert.Equal(t, 1, bus.count(), "Received values are not published again")
+
+	ts = bb.Put("v2")
+	require.Eventually(t, func() bool { _, got, _ := ba.Get(0); return got == ts }, time.Second, time.Millisecond)
+	value, _, _ = ba.Get(0)
+	assert.Equal(t, "v2", value)
+	assert.Equal(t, 2, bus.count())
+
+	system := a.Bucket(SystemStatsBucket)
+	system.Get(0)
+	assert.Equal(t, 2, bus.count(), "System buckets are not propagated")
+}
+
+// publishAs publishes msg on bus as if from another pool, returning once
+// subscribers are done with it.
+func publishAs(t *testing.T, bus MessageBus, msg bridgeMessage) {
+	for _, msg := range []bridgeMessage{msg, {Bucket: SystemStatsBucket}} {
+		msg.Origin = "other"
+		data, err := json.Marshal(msg)
+		require.NoError(t, err)
+		// Subscribers take the ignored system bucket message once done with
+		// the first one.
+		require.NoError(t, bus.Publish(context.Background(), "pools", data))
+	}
+}
+
+func TestBridgeConflicts(t *testing.T) {
+	bus := &MemoryBus{}
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	runBridge(t, bus, pool.NewBridge(bus, "pools"), nil)
+	b := pool.Bucket("config")
+	ts := b.Put("local")
+
+	publishAs(t, bus, bridgeMessage{Bucket: "config", Value: []byte(`"older"`), Codec: "json", Timestamp: ts - 1, Version: 5})
+	value, _, _ := b.Get(0)
+	assert.Equal(t, "local", value, "The last writer wins")
+
+	publishAs(t, bus, bridgeMessage{Bucket: "config", Value: []byte(`"tie"`), Codec: "json", Timestamp: ts, Version: 5})
+	value, _, _ = b.Get(0)
+	assert.Equal(t, "tie", value, "Ties go to the higher version")
+	assert.Equal(t, uint64(5), b.Version())
+
+	publishAs(t, bus, bridgeMessage{Bucket: "config", Value: []byte(`"newer"`), Codec: "json", Timestamp: ts + 1, Version: 1})
+	value, got, _ := b.Get(0)
+	assert.Equal(t, "newer", value)
+	assert.Equal(t, ts+1, got)
+	assert.Equal(t, uint64(6), b.Version(), "Versions never go back")
+}
+
+func TestBridgeConflictResolver(t *testing.T) {
+	bus := &MemoryBus{}
+	pool := NewDataPool()
+	var calls []Update
+	keepLocal := func(local, remote Update) bool {
+		calls = append(calls, local)
+		return false
+	}
+	runBridge(t, bus, pool.NewBridge(bus, "pools", WithConflictResolver(keepLocal)), nil)
+	b := pool.Bucket("config")
+	ts := b.Put("local")
+
+	publishAs(t, bus, bridgeMessage{Bucket: "config", Value: []byte(`"remote"`), Codec: "json", Timestamp: ts + 1})
+	value, _, _ := b.Get(0)
+	assert.Equal(t, "local", value)
+	require.Len(t, calls, 1)
+	assert.Equal(t, Update{Bucket: "config", Value: "local", Timestamp: ts, Version: 1}, calls[0])
+
+	publishAs(t, bus, bridgeMessage{Bucket: "fresh", Value: []byte(`"remote"`), Codec: "json", Timestamp: ts + 1})
+	fresh := pool.Bucket("fresh")
+	value, _, _ = fresh.Get(0)
+	assert.Equal(t, "remote", value, "Empty buckets take remote values")
+	assert.Len(t, calls, 1)
+
+	local := Update{Timestamp: 2, Version: 1}
+	assert.True(t, HighestVersionWins(local, Update{Timestamp: 1, Version: 2}))
+	assert.False(t, HighestVersionWins(local, Update{Timestamp: 3, Version: 0}))
+	assert.True(t, HighestVersionWins(local, Update{Timestamp: 3, Version: 1}))
+}
+
+func TestBridgeEncoded(t *testing.T) {
+	bus := &MemoryBus{}
+	a := NewDataPool()
+	b := NewDataPool()
+	runBridge(t, bus, a.NewBridge(bus, "pools"), nil)
+	runBridge(t, bus, b.NewBridge(bus, "pools"), nil)
+
+	ba := a.Bucket("config")
+	ts, err := ba.PutEncoded(cloneConfigValue{Name: "a", Hosts: []string{"h1"}})
+	require.NoError(t, err)
+	bb := b.Bucket("config")
+	require.Eventually(t, func() bool { _, got, _ := bb.Get(0); return got == ts }, time.Second, time.Millisecond)
+
+	got, _, _, err := GetAs[cloneConfigValue](&bb, 0)
+	require.NoError(t, err)
+	assert.Equal(t, cloneConfigValue{Name: "a", Hosts: []string{"h1"}}, got, "Encoded values stay encoded")
+}
+
+func TestBridgeErrors(t *testing.T) {
+	bus := &MemoryBus{}
+	errs := make(chan error, 10)
+	pool := NewDataPool()
+	runBridge(t, bus, pool.NewBridge(bus, "pools"), errs)
+
+	publishAs(t, bus, bridgeMessage{Bucket: "config", Value: []byte{0x81}, Codec: "msgpack", Timestamp: 1})
+	err := <-errs
+	assert.ErrorContains(t, err, `"config": value encoded with msgpack, not json`)
+
+	require.NoError(t, bus.Publish(context.Background(), "pools", []byte("{")))
+	assert.ErrorContains(t, <-errs, "bad message")
+
+	events := pool.Bucket("events")
+	events.Put(make(chan int))
+	assert.ErrorContains(t, <-errs, `encode json value of "events"`)
+}
+
+func TestBridgeBackpressure(t *testing.T) {
+	bus := &countingBus{block: make(chan struct{})}
+	errs := make(chan error, 10)
+	pool := NewDataPool()
+	runBridge(t, &bus.MemoryBus, pool.NewBridge(bus, "pools", WithBridgeBuffer(1)), errs)
+	t.Cleanup(func() { close(bus.block) })
+
+	b := pool.Bucket("config")
+	b.Put(1)
+	require.Eventually(t, func() bool { return bus.count() == 1 }, time.Second, time.Millisecond)
+	b.Put(2) // Queued.
+	b.Put(3)
+	err := <-errs
+	assert.True(t, errors.Is(err, ErrBackpressure))
+	assert.ErrorContains(t, err, `dropped write of "config"`)
+}
+
+func TestBridgeIgnoresBackendValues(t *testing.T) {
+	bus := &countingBus{}
+	backend := &MemoryBackend{}
+	pool := NewDataPool(WithBackend(backend))
+	runBridge(t, &bus.MemoryBus, pool.NewBridge(bus, "pools"), nil)
+
+	require.NoError(t, backend.Put(context.Background(), Update{Bucket: "config", Value: "v1", Timestamp: 1}))
+	b := pool.Bucket("config")
+	value, _, _ := b.Get(0)
+	assert.Equal(t, "v1", value)
+	assert.Zero(t, bus.count(), "Values read from the backend are not published")
+}
@@ -0,0 +1,132 @@
+package datapool
+
+import (
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+)
+
+// Sizer returns the approximate number of bytes held by a value, for the
+// memory budget (see WithMemoryBudget). It is called with the bucket's lock
+// held, so it must be quick and must not use the pool.
+type Sizer func(value any) int
+
+// memoryUsage accounts for the bytes held by the values of a pool's buckets.
+type memoryUsage struct {
+	size Sizer
+	used atomic.Int64
+	// enforcing lets one writer at a time evict to respect the budget.
+	enforcing sync.Mutex
+}
+
+// account records value as the bucket's value, nil for none. It must be
+// called with b.guard held for writing.
+func (b *bucket) account(value any) {
+	if b.mem == nil && b.tenant == nil {
+		return
+	}
+	var n int64
+	if value != nil {
+		n = int64(b.sizer()(value))
+	}
+	if b.mem != nil {
+		b.mem.used.Add(n - b.size)
+	}
+	if b.tenant != nil {
+		b.tenant.used.Add(n - b.size - b.reserved)
+		b.reserved = 0
+	}
+	b.size = n
+}
+
+// sizer returns the Sizer measuring the bucket's values.
+func (b *bucket) sizer() Sizer {
+	if b.mem != nil {
+		return b.mem.size
+	}
+	return sizeOf
+}
+
+// MemoryUsage returns the approximate number of bytes held by the values of
+// the pool's buckets, or zero if the pool was created without
+// WithMemoryBudget or WithSizer.
+func (p *DataPool) MemoryUsage() int64 {
+	if p.mem == nil {
+		return 0
+	}
+	return p.mem.used.Load()
+}
+
+// enforceBudget drops the values of the least recently updated buckets while
+// the pool exceeds its memory budget, sparing values stored at or after now.
+func (p *DataPool) enforceBudget(now int64) {
+	budget := p.opts.memoryBudget
+	if budget <= 0 || p.mem.used.Load() <= budget || !p.mem.enforcing.TryLock() {
+		return
+	}
+	defer p.mem.enforcing.Unlock()
+
+	// Victims can change before they are locked, so give up eventually.
+	for attempts := p.Len(); p.mem.used.Load() > budget && attempts > 0; attempts-- {
+		victim, ts := p.pickBudgetVictim(now)
+		if victim == nil {
+			return
+		}
+
+		victim.guard.Lock()
+		dropped := victim.timestamp == ts && !victim.pinned.Load()
+		var value any
+		if dropped {
+			value = victim.current()
+			victim.value = nil
+			victim.cachedErr = nil
+			victim.releaseShared()
+			victim.account(nil)
+			victim.forgetRead()
+			victim.timestamp = 0
+			victim.reschedule()
+			victim.provenance = nil
+			victim.schema = 0
+		}
+		victim.guard.Unlock()
+
+		if dropped {
+			p.fireEvict(victim.name(), value, false)
+		}
+	}
+}
+
+// pickBudgetVictim samples non-empty buckets starting at a random shard and
+// returns the one with the lowest priority and the oldest value stored
+// before now, along with that value's timestamp.
+func (p *DataPool) pickBudgetVictim(now int64) (*bucket, int64) {
+	var victim *bucket
+	var victimPriority Priority
+	var victimTs int64
+
+	sampled := 0
+	start := rand.IntN(len(p.shards))
+	for i := 0; i < len(p.shards) && sampled < evictionSamples; i++ {
+		sh := p.shards[(start+i)%len(p.shards)]
+		sh.mu.RLock()
+		for _, b := range sh.buckets {
+			if b.system || b.pinned.Load() {
+				continue
+			}
+			b.guard.RLock()
+			ts, priority := b.timestamp, b.priority
+			b.guard.RUnlock()
+			if ts == 0 || ts >= now {
+				continue
+			}
+			if victim == nil || priority < victimPriority || (priority == victimPriority && ts < victimTs) {
+				victim, victimPriority, victimTs = b, priority, ts
+			}
+			if sampled++; sampled >= evictionSamples {
+				break
+			}
+		}
+		sh.mu.RUnlock()
+	}
+	return victim, victimTs
+}
@@ -0,0 +1,122 @@
+package datapool
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lenSizer measures byte slices and strings by their length.
+func lenSizer(value any) int {
+	switch v := value.(type) {
+	case []byte:
+		return len(v)
+	case string:
+		return len(v)
+	default:
+		return 0
+	}
+}
+
+func TestMemoryUsage(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock), WithSizer(lenSizer))
+	a := pool.Bucket("a")
+	b := pool.Bucket("b")
+
+	a.Put("12345")
+	b.PutBytes([]byte("123"))
+	assert.Equal(t, int64(8), pool.MemoryUsage())
+
+	a.Put("12")
+	assert.Equal(t, int64(5), pool.MemoryUsage(), "Replaced values are no longer counted")
+
+	a.SetTTL(time.Minute)
+	a.Put("1234")
+	clock.Advance(2 * time.Minute)
+	require.Equal(t, 1, pool.Expire())
+	assert.Equal(t, int64(3), pool.MemoryUsage(), "Expired values are no longer counted")
+
+	pool.Clear()
+	assert.Zero(t, pool.MemoryUsage())
+
+	stats := pool.Bucket(SystemStatsBucket)
+	stats.Get(0)
+	assert.Zero(t, pool.MemoryUsage(), "System buckets are not counted")
+
+	assert.Zero(t, NewDataPool().MemoryUsage(), "Pools without a budget or sizer track nothing")
+}
+
+func TestMemoryBudget(t *testing.T) {
+	var evicted []string
+	pool := NewDataPool(WithMemoryBudget(10), WithSizer(lenSizer))
+	pool.OnEvict(func(name string, value any) {
+		evicted = append(evicted, name)
+	})
+
+	for i := range 3 {
+		b := pool.Bucket(fmt.Sprint(i))
+		b.Put("1234")
+	}
+	assert.Equal(t, []string{"0"}, evicted, "The least recently updated value goes first")
+	assert.Equal(t, int64(8), pool.MemoryUsage())
+	assert.Equal(t, 3, pool.Len(), "Buckets whose value was dropped remain")
+
+	oldest := pool.Bucket("0")
+	val, ts, _ := oldest.Get(0)
+	assert.Nil(t, val)
+	assert.Zero(t, ts)
+
+	// Updating a bucket makes it the most recent.
+	one := pool.Bucket("1")
+	one.Put("1234")
+	big := pool.Bucket("big")
+	big.Put("123456")
+	assert.Equal(t, []string{"0", "2"}, evicted)
+	assert.Equal(t, int64(10), pool.MemoryUsage())
+}
+
+func TestMemoryBudgetKeepsNewValues(t *testing.T) {
+	pool := NewDataPool(WithMemoryBudget(4), WithSizer(lenSizer))
+	b := pool.Bucket("huge")
+	b.Put("123456789")
+
+	val, _, _ := b.Get(0)
+	assert.Equal(t, "123456789", val, "Values just written are kept even over budget")
+
+	other := pool.Bucket("other")
+	other.Put("1")
+	val, _, _ = b.Get(0)
+	assert.Nil(t, val)
+	assert.Equal(t, int64(1), pool.MemoryUsage())
+}
+
+func TestMemoryBudgetPriorities(t *testing.T) {
+	pool := NewDataPool(WithMemoryBudget(8), WithSizer(lenSizer))
+	keep := pool.Bucket("keep")
+	keep.SetPriority(PriorityHigh)
+	keep.Put("1234")
+	cheap := pool.Bucket("cheap")
+	cheap.Put("1234")
+
+	b := pool.Bucket("new")
+	b.Put("1234")
+	val, _, _ := keep.Get(0)
+	assert.Equal(t, "1234", val, "Lower priorities are dropped first")
+	val, _, _ = cheap.Get(0)
+	assert.Nil(t, val)
+}
+
+func TestMemoryBudgetDefaultSizer(t *testing.T) {
+	pool := NewDataPool(WithMemoryBudget(1 << 20))
+	b := pool.Bucket("blob")
+	b.PutBytes(make([]byte, 1000))
+	require.Equal(t, int64(sizeOf(make([]byte, 1000))), pool.MemoryUsage())
+
+	stats := pool.Bucket(SystemStatsBucket)
+	val, _, _ := stats.Get(0)
+	assert.Equal(t, pool.MemoryUsage(), val.(PoolStats).MemoryUsage)
+}
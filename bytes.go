@@ -0,0 +1,157 @@
+package datapool
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// sharedBytes is a byte slice stored with PutBytes, shared by the bucket and
+// the ByteViews reading it. Once the last of them releases it, the buffer goes
+// back to the pool's buffer pool for AllocBytes to reuse.
+type sharedBytes struct {
+	data []byte
+	refs atomic.Int64
+	free *sync.Pool
+}
+
+func (s *sharedBytes) acquire() {
+	s.refs.Add(1)
+}
+
+func (s *sharedBytes) release() {
+	if s.refs.Add(-1) == 0 {
+		data := s.data[:0]
+		s.free.Put(&data)
+	}
+}
+
+// ByteView is a read-only view of a byte slice read with GetBytes. It keeps
+// the slice from being reused until Release is called.
+type ByteView struct {
+	data   []byte
+	shared *sharedBytes
+	once   sync.Once
+}
+
+// Bytes returns the viewed bytes, which must not be modified, nor used after
+// Release. It returns nil after Release.
+func (v *ByteView) Bytes() []byte {
+	return v.data
+}
+
+// Len returns the number of viewed bytes.
+func (v *ByteView) Len() int {
+	return len(v.data)
+}
+
+// Release ends the view. Calling it more than once has no effect.
+func (v *ByteView) Release() {
+	v.once.Do(func() {
+		v.data = nil
+		if v.shared != nil {
+			v.shared.release()
+		}
+	})
+}
+
+// AllocBytes returns a byte slice of length n to fill and store with
+// PutBytes, reusing a buffer released by the pool when one is large enough.
+func (p *DataPool) AllocBytes(n int) []byte {
+	if buf, ok := p.freeBytes.Get().(*[]byte); ok && cap(*buf) >= n {
+		return (*buf)[:n]
+	}
+	return make([]byte, n)
+}
+
+// PutBytes stores data without copying it and returns its timestamp. The
+// bucket takes ownership of data: the caller must not use it afterwards, and
+// once the value is replaced or dropped and every ByteView of it released,
+// its memory is reused by AllocBytes. GetBytes reads it without copying;
+// everything else, including Get, watchers, callbacks and the backend, sees
+// a copy, whether or not the bucket copies values.
+func (b *Bucket) PutBytes(data []byte) int64 {
+	bk := b.resolve("put bytes")
+	if bk == nil {
+		return 0
+	}
+	p := b.pool
+	if p.rejectSystem(bk) {
+		return 0
+	}
+
+	bk.guard.Lock()
+	if bk.removed {
+		bk.guard.Unlock()
+		p.reportError(bk.name(), bk.removedError())
+		return 0
+	}
+	if err := bk.checkClaim(p.now(), 0); err != nil {
+		bk.guard.Unlock()
+		p.reportError(bk.name(), err)
+		return 0
+	}
+	u := Update{Bucket: bk.name(), Value: bytes.Clone(data), Timestamp: p.stamp()}
+	u.Version = bk.store(nil, u.Timestamp)
+	bk.account(data)
+	bk.value = data
+	bk.shared = &sharedBytes{data: data, free: &p.freeBytes}
+	bk.shared.acquire()
+	watchers := bk.watchers
+	bk.guard.Unlock()
+
+	p.notifyPut(context.Background(), bk, watchers, u, ConsistencyDefault)
+	p.checkMemoryPressure(u.Timestamp)
+	return u.Timestamp
+}
+
+// GetBytes is Get for byte slices, returning a view of the bucket's value
+// instead of a copy. The view is nil if the bucket is empty or its value is
+// not a []byte; otherwise the caller must Release it. Values stored with Put
+// are viewed as stored, so they must not be modified either. Unlike Get,
+// GetBytes does not consult the backend or the loader.
+func (b *Bucket) GetBytes(timestamp int64) (*ByteView, int64, bool) {
+	bk := b.resolve("get bytes")
+	if bk == nil {
+		return nil, timestamp, false
+	}
+
+	bk.guard.RLock()
+	defer bk.guard.RUnlock()
+
+	if bk.removed {
+		return nil, timestamp, false
+	}
+	if bk.expiredAt(b.pool) {
+		return nil, 0, false
+	}
+	ts, fresh := bk.timestamp, bk.timestamp > timestamp && !bk.frozen
+	data, ok := bk.value.([]byte)
+	if !ok {
+		return nil, ts, fresh
+	}
+	if bk.shared != nil {
+		bk.shared.acquire()
+	}
+	return &ByteView{data: data, shared: bk.shared}, ts, fresh
+}
+
+// current returns the bucket's value for use outside b.guard. Values stored
+// with PutBytes are copied, since their buffer is reused once they are
+// replaced. It must be called with b.guard held.
+func (b *bucket) current() any {
+	if b.shared != nil {
+		return bytes.Clone(b.shared.data)
+	}
+	return b.value
+}
+
+// releaseShared drops the bucket's reference to a value stored with PutBytes.
+// It must be called with b.guard held for writing.
+func (b *bucket) releaseShared() {
+	if b.shared != nil {
+		b.shared.release()
+		b.shared = nil
+	}
+}
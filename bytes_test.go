@@ -0,0 +1,108 @@
+package datapool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutBytes(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("blob")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := b.Watch(ctx)
+
+	data := []byte("payload")
+	ts := b.PutBytes(data)
+	require.NotZero(t, ts)
+
+	view, got, fresh := b.GetBytes(0)
+	require.NotNil(t, view)
+	defer view.Release()
+	assert.Equal(t, ts, got)
+	assert.True(t, fresh)
+	assert.Equal(t, "payload", string(view.Bytes()))
+	assert.Equal(t, 7, view.Len())
+	assert.Same(t, &data[0], &view.Bytes()[0], "GetBytes does not copy")
+
+	val, _, _ := b.Get(0)
+	assert.Equal(t, []byte("payload"), val)
+	assert.NotSame(t, &data[0], &val.([]byte)[0], "Get copies")
+
+	u := receive(t, updates)
+	assert.Equal(t, []byte("payload"), u.Value)
+	assert.NotSame(t, &data[0], &u.Value.([]byte)[0], "Watchers get a copy")
+}
+
+func TestGetBytesOtherValues(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("value")
+
+	view, ts, _ := b.GetBytes(0)
+	assert.Nil(t, view, "Empty buckets have no view")
+	assert.Zero(t, ts)
+
+	ts = b.Put("text")
+	view, got, _ := b.GetBytes(0)
+	assert.Nil(t, view, "Values that are not byte slices have no view")
+	assert.Equal(t, ts, got)
+
+	b.Put([]byte("plain"))
+	view, _, _ = b.GetBytes(0)
+	require.NotNil(t, view)
+	assert.Equal(t, "plain", string(view.Bytes()), "Byte slices stored with Put are viewed too")
+	view.Release()
+	view.Release()
+	assert.Nil(t, view.Bytes())
+}
+
+func TestPutBytesRecyclesBuffers(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("blob")
+
+	first := pool.AllocBytes(4)
+	copy(first, "abcd")
+	b.PutBytes(first)
+	shared := b.b.shared
+	view, _, _ := b.GetBytes(0)
+	require.NotNil(t, view)
+	assert.Equal(t, int64(2), shared.refs.Load())
+
+	// The replaced value stays intact while it is viewed.
+	b.PutBytes([]byte("efgh"))
+	assert.Equal(t, int64(1), shared.refs.Load())
+	copy(pool.AllocBytes(4), "wxyz")
+	assert.Equal(t, "abcd", string(view.Bytes()))
+
+	view.Release()
+	assert.Zero(t, shared.refs.Load(), "The buffer is released with its last view")
+	assert.Len(t, pool.AllocBytes(2), 2)
+}
+
+func TestPutBytesDroppedValues(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	var expired []any
+	pool.OnExpire(func(_ string, value any) {
+		expired = append(expired, value)
+	})
+	b := pool.Bucket("blob")
+	b.SetTTL(time.Minute)
+	data := []byte("payload")
+	b.PutBytes(data)
+
+	clock.Advance(time.Minute)
+	view, ts, _ := b.GetBytes(0)
+	assert.Nil(t, view, "Expired values have no view")
+	assert.Zero(t, ts)
+
+	require.Equal(t, 1, pool.Expire())
+	require.Len(t, expired, 1)
+	assert.Equal(t, []byte("payload"), expired[0])
+	assert.NotSame(t, &data[0], &expired[0].([]byte)[0], "Callbacks get a copy of dropped values")
+	assert.Nil(t, b.b.shared)
+}
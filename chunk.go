@@ -0,0 +1,147 @@
+package datapool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// DefaultChunkSize is the size of the chunks large encoded values are split
+// into when no other size is configured. It stays well below the 4 MiB gRPC
+// message limit.
+const DefaultChunkSize = 1 << 20
+
+// ErrCorruptChunk is returned when a chunk fails its checksum or arrives out
+// of order.
+var ErrCorruptChunk = errors.New("datapool: corrupt chunk")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Chunk is a piece of an encoded value split by a ChunkWriter, for layers
+// such as network streams that limit the size of a message, and the
+// write-ahead log (see WithWALChunkSize).
+type Chunk struct {
+	// Seq numbers the chunks of a value from zero.
+	Seq  int
+	Data []byte
+	// Checksum is the CRC-32C of Data.
+	Checksum uint32
+	// Last marks the final chunk of the value.
+	Last bool
+}
+
+// Verify reports whether the chunk's data matches its checksum.
+func (c Chunk) Verify() bool {
+	return crc32.Checksum(c.Data, castagnoli) == c.Checksum
+}
+
+// ChunkWriter splits what is written to it into chunks of at most size bytes,
+// passing each to emit as soon as it is full, so an encoder writing a large
+// value never needs the whole encoding in one buffer. Close emits the last
+// chunk.
+type ChunkWriter struct {
+	size int
+	emit func(Chunk) error
+	buf  []byte
+	seq  int
+	err  error
+}
+
+// NewChunkWriter returns a ChunkWriter emitting chunks of size bytes, or
+// DefaultChunkSize if size is zero or less.
+func NewChunkWriter(size int, emit func(Chunk) error) *ChunkWriter {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	return &ChunkWriter{size: size, emit: emit}
+}
+
+// Write implements io.Writer. It fails with the first error returned by emit.
+func (w *ChunkWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		if w.buf == nil {
+			w.buf = make([]byte, 0, w.size)
+		}
+		k := min(len(p), w.size-len(w.buf))
+		w.buf = append(w.buf, p[:k]...)
+		p = p[k:]
+		// The chunk is only emitted once more data arrives, since the last
+		// chunk must be marked as such.
+		if len(w.buf) == w.size && len(p) > 0 {
+			if err := w.flush(false); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close emits the last chunk. The writer must not be used afterwards.
+func (w *ChunkWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.flush(true)
+}
+
+func (w *ChunkWriter) flush(last bool) error {
+	c := Chunk{Seq: w.seq, Data: w.buf, Checksum: crc32.Checksum(w.buf, castagnoli), Last: last}
+	w.seq++
+	// The emitted chunk keeps its data, so later writes need a new buffer.
+	w.buf = nil
+	if err := w.emit(c); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+// ChunkAssembler collects the chunks of a value, checking their order and
+// checksums, and reads the value back without joining them into one buffer.
+// The zero ChunkAssembler is ready to use.
+type ChunkAssembler struct {
+	chunks [][]byte
+	done   bool
+}
+
+// Add adds the next chunk and reports whether it was the last one. It fails
+// with ErrCorruptChunk if the chunk is not the expected one or fails its
+// checksum.
+func (a *ChunkAssembler) Add(c Chunk) (bool, error) {
+	switch {
+	case a.done:
+		return true, fmt.Errorf("%w: chunk %d after the last one", ErrCorruptChunk, c.Seq)
+	case c.Seq != len(a.chunks):
+		return false, fmt.Errorf("%w: got chunk %d, want %d", ErrCorruptChunk, c.Seq, len(a.chunks))
+	case !c.Verify():
+		return false, fmt.Errorf("%w: chunk %d fails its checksum", ErrCorruptChunk, c.Seq)
+	}
+	a.chunks = append(a.chunks, c.Data)
+	a.done = c.Last
+	return a.done, nil
+}
+
+// Done reports whether the last chunk was added.
+func (a *ChunkAssembler) Done() bool {
+	return a.done
+}
+
+// Reader returns a reader of the data of the chunks added so far.
+func (a *ChunkAssembler) Reader() io.Reader {
+	readers := make([]io.Reader, len(a.chunks))
+	for i, data := range a.chunks {
+		readers[i] = bytes.NewReader(data)
+	}
+	return io.MultiReader(readers...)
+}
+
+// Reset empties the assembler for the next value.
+func (a *ChunkAssembler) Reset() {
+	*a = ChunkAssembler{}
+}
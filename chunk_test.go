@@ -0,0 +1,105 @@
+package datapool
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkWriter(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		data   string
+		chunks []string
+	}{
+		{"empty", "", []string{""}},
+		{"one", "abc", []string{"abc"}},
+		{"boundary", "abcdef", []string{"abc", "def"}},
+		{"partial", "abcdefg", []string{"abc", "def", "g"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var chunks []Chunk
+			w := NewChunkWriter(3, func(c Chunk) error {
+				chunks = append(chunks, c)
+				return nil
+			})
+			// Write byte by byte so chunks are filled across writes.
+			for i := range len(tc.data) {
+				_, err := w.Write([]byte{tc.data[i]})
+				require.NoError(t, err)
+			}
+			require.NoError(t, w.Close())
+
+			require.Len(t, chunks, len(tc.chunks))
+			var a ChunkAssembler
+			for i, c := range chunks {
+				assert.Equal(t, i, c.Seq)
+				assert.Equal(t, tc.chunks[i], string(c.Data))
+				assert.Equal(t, i == len(chunks)-1, c.Last)
+				assert.True(t, c.Verify())
+
+				done, err := a.Add(c)
+				require.NoError(t, err)
+				assert.Equal(t, c.Last, done)
+			}
+			assert.True(t, a.Done())
+			data, err := io.ReadAll(a.Reader())
+			require.NoError(t, err)
+			assert.Equal(t, tc.data, string(data))
+		})
+	}
+}
+
+func TestChunkWriterError(t *testing.T) {
+	errFull := errors.New("full")
+	w := NewChunkWriter(2, func(Chunk) error { return errFull })
+	n, err := w.Write([]byte("abcde"))
+	assert.ErrorIs(t, err, errFull)
+	assert.Equal(t, 2, n, "Only the bytes of the chunk emit failed on are written")
+	_, err = w.Write([]byte("f"))
+	assert.ErrorIs(t, err, errFull)
+	assert.ErrorIs(t, w.Close(), errFull)
+}
+
+func TestChunkAssembler(t *testing.T) {
+	var chunks []Chunk
+	w := NewChunkWriter(0, func(c Chunk) error {
+		chunks = append(chunks, c)
+		return nil
+	})
+	large := bytes.Repeat([]byte("x"), DefaultChunkSize+1)
+	_, err := w.Write(large)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Len(t, chunks, 2)
+
+	var a ChunkAssembler
+	_, err = a.Add(chunks[1])
+	assert.ErrorIs(t, err, ErrCorruptChunk)
+	assert.EqualError(t, err, "datapool: corrupt chunk: got chunk 1, want 0")
+
+	corrupt := chunks[0]
+	corrupt.Data = append([]byte("y"), corrupt.Data[1:]...)
+	_, err = a.Add(corrupt)
+	assert.EqualError(t, err, "datapool: corrupt chunk: chunk 0 fails its checksum")
+
+	for _, c := range chunks {
+		_, err = a.Add(c)
+		require.NoError(t, err)
+	}
+	_, err = a.Add(Chunk{Seq: 2})
+	assert.ErrorIs(t, err, ErrCorruptChunk, "Nothing follows the last chunk")
+
+	data, err := io.ReadAll(a.Reader())
+	require.NoError(t, err)
+	assert.Equal(t, large, data)
+
+	a.Reset()
+	assert.False(t, a.Done())
+	_, err = a.Add(chunks[0])
+	assert.NoError(t, err)
+}
@@ -0,0 +1,110 @@
+package datapool
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownKey is returned when data was sealed with a key a Cipher does not
+// have.
+var ErrUnknownKey = errors.New("datapool: unknown encryption key")
+
+// Cipher encrypts persisted values, such as those of a write-ahead log (see
+// WithWALCipher), so that buckets holding credentials or tokens are not
+// stored in the clear.
+type Cipher interface {
+	// Seal returns plaintext encrypted with the current key, authenticating
+	// aad along with it without encrypting it. The write-ahead log passes
+	// the header of the record holding the value, its timestamp and bucket
+	// name, as aad.
+	Seal(plaintext, aad []byte) ([]byte, error)
+
+	// Open decrypts ciphertext returned by Seal, with the current key or an
+	// earlier one, and reports whether it was the current key, so data
+	// sealed with an earlier key can be sealed again. It fails if ciphertext
+	// was altered or aad differs from the one it was sealed with.
+	Open(ciphertext, aad []byte) (plaintext []byte, current bool, err error)
+}
+
+// aesGCMKeyID is the length of the key identifier that starts the data
+// sealed by an AES-GCM Cipher.
+const aesGCMKeyID = 4
+
+type aesGCMCipher struct {
+	current []byte
+	keys    map[string]cipher.AEAD
+}
+
+// NewAESGCMCipher returns a Cipher sealing data with AES-GCM under key, which
+// must be 16, 24 or 32 bytes long, and opening data sealed under key or any
+// of the previous keys, for key rotation. Sealed data starts with an
+// identifier of its key, derived from the key with SHA-256, followed by a
+// random nonce; the identifier and aad are authenticated as GCM's additional
+// data.
+func NewAESGCMCipher(key []byte, previous ...[]byte) (Cipher, error) {
+	c := &aesGCMCipher{keys: make(map[string]cipher.AEAD)}
+	for i, k := range append([][]byte{key}, previous...) {
+		block, err := aes.NewCipher(k)
+		if err != nil {
+			return nil, fmt.Errorf("datapool: aes-gcm key %d: %w", i, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("datapool: aes-gcm key %d: %w", i, err)
+		}
+		id := aesGCMID(k)
+		if i == 0 {
+			c.current = id
+		}
+		if _, ok := c.keys[string(id)]; !ok {
+			c.keys[string(id)] = aead
+		}
+	}
+	return c, nil
+}
+
+func aesGCMID(key []byte) []byte {
+	sum := sha256.Sum256(append([]byte("datapool key id\x00"), key...))
+	return sum[:aesGCMKeyID]
+}
+
+// aesGCMData returns the additional data authenticated with a value sealed
+// under the key identified by id.
+func aesGCMData(id, aad []byte) []byte {
+	return append(append(make([]byte, 0, len(id)+len(aad)), id...), aad...)
+}
+
+func (c *aesGCMCipher) Seal(plaintext, aad []byte) ([]byte, error) {
+	aead := c.keys[string(c.current)]
+	out := make([]byte, aesGCMKeyID+aead.NonceSize(), aesGCMKeyID+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	copy(out, c.current)
+	nonce := out[aesGCMKeyID:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("datapool: aes-gcm nonce: %w", err)
+	}
+	return aead.Seal(out, nonce, plaintext, aesGCMData(c.current, aad)), nil
+}
+
+func (c *aesGCMCipher) Open(ciphertext, aad []byte) ([]byte, bool, error) {
+	if len(ciphertext) < aesGCMKeyID {
+		return nil, false, errors.New("datapool: aes-gcm: data too short")
+	}
+	id := ciphertext[:aesGCMKeyID]
+	aead, ok := c.keys[string(id)]
+	if !ok {
+		return nil, false, fmt.Errorf("%w: id %x", ErrUnknownKey, id)
+	}
+	rest := ciphertext[aesGCMKeyID:]
+	if len(rest) < aead.NonceSize() {
+		return nil, false, errors.New("datapool: aes-gcm: data too short")
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], aesGCMData(id, aad))
+	if err != nil {
+		return nil, false, fmt.Errorf("datapool: aes-gcm: %w", err)
+	}
+	return plaintext, string(id) == string(c.current), nil
+}
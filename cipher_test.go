@@ -0,0 +1,78 @@
+package datapool
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAESGCMCipher(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 16)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	old, err := NewAESGCMCipher(oldKey)
+	require.NoError(t, err)
+	sealed, err := old.Seal([]byte("token"), []byte("header"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "token")
+
+	again, err := old.Seal([]byte("token"), []byte("header"))
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "Every seal uses a new nonce")
+
+	plaintext, current, err := old.Open(sealed, []byte("header"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("token"), plaintext)
+	assert.True(t, current)
+
+	rotated, err := NewAESGCMCipher(newKey, oldKey)
+	require.NoError(t, err)
+	plaintext, current, err = rotated.Open(sealed, []byte("header"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("token"), plaintext)
+	assert.False(t, current, "Data sealed with a previous key is not current")
+
+	fresh, err := NewAESGCMCipher(newKey)
+	require.NoError(t, err)
+	_, _, err = fresh.Open(sealed, []byte("header"))
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	_, _, err = old.Open(tampered, []byte("header"))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUnknownKey)
+
+	_, _, err = old.Open(sealed, []byte("other header"))
+	assert.Error(t, err, "The additional data is authenticated")
+	assert.NotErrorIs(t, err, ErrUnknownKey)
+
+	_, err = NewAESGCMCipher([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestWALCipherAuthenticatesHeader(t *testing.T) {
+	c, err := NewAESGCMCipher(bytes.Repeat([]byte{1}, 16))
+	require.NoError(t, err)
+	w := openWAL(t, filepath.Join(t.TempDir(), "pool.wal"), WithWALCipher(c))
+	defer w.Close()
+
+	rec := walRecord{op: walPut, name: "secrets/a", timestamp: 1, value: "token"}
+	rec.kind, rec.data, err = w.encodeValue(rec)
+	require.NoError(t, err)
+	value, err := w.decodeValue(rec)
+	require.NoError(t, err)
+	assert.Equal(t, "token", value)
+
+	moved := rec
+	moved.name = "secrets/b"
+	_, err = w.decodeValue(moved)
+	assert.Error(t, err, "Sealed values cannot move to another bucket")
+	replayed := rec
+	replayed.timestamp = 2
+	_, err = w.decodeValue(replayed)
+	assert.Error(t, err, "Sealed values cannot move to another time")
+}
@@ -0,0 +1,172 @@
+package datapool
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ErrClaimed is wrapped by the errors of writes to a bucket claimed by
+// another writer (see Bucket.Claim), and of claims of such buckets. It is an
+// ErrSealed.
+var ErrClaimed = newKindError(ErrSealed, "datapool: bucket claimed")
+
+// ErrClaimLost is wrapped by the errors of writes and renewals through a
+// WriteToken whose claim expired, was released or was superseded. It is an
+// ErrStale.
+var ErrClaimLost = newKindError(ErrStale, "datapool: claim lost")
+
+// DefaultClaimTTL is how long a claim lasts without being renewed, unless
+// set with WithClaimTTL.
+const DefaultClaimTTL = 30 * time.Second
+
+// WithClaimTTL sets how long claims made with Bucket.Claim last without being
+// renewed. The default is DefaultClaimTTL.
+func WithClaimTTL(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.claimTTL = d
+		}
+	}
+}
+
+// claim is the hold of a writer on a bucket.
+type claim struct {
+	owner     string
+	id        uint64
+	expiresAt int64
+}
+
+// WriteToken is the right to write a claimed bucket, returned by
+// Bucket.Claim. The zero WriteToken holds no claim.
+type WriteToken struct {
+	bucket Bucket
+	owner  string
+	id     uint64
+}
+
+// Claim claims the bucket for writing by owner, such as the name of a
+// refresher job, so that two jobs refreshing the same bucket cannot overwrite
+// each other's values. While the claim lasts only the returned token's Put
+// stores values; every other write, from Put to PutMany and transactions,
+// fails with an error wrapping ErrClaimed, which Put passes to the error
+// handler. A claim expires after the claim TTL (see WithClaimTTL) unless
+// renewed, so an owner that crashed does not hold the bucket forever.
+//
+// Claiming a bucket another owner holds fails with ErrClaimed. Claiming it
+// again as the same owner, as a restarted job does, supersedes the previous
+// token. Claims are local to the pool: values received from a backend are
+// applied regardless.
+func (b *Bucket) Claim(owner string) (WriteToken, error) {
+	bk, err := b.lookup("claim")
+	if err != nil {
+		return WriteToken{}, err
+	}
+	if bk.system {
+		return WriteToken{}, fmt.Errorf("%w: %q", ErrSystemBucket, bk.name())
+	}
+	now := b.pool.now()
+
+	bk.guard.Lock()
+	defer bk.guard.Unlock()
+
+	if c := bk.claim; c != nil && now < c.expiresAt && c.owner != owner {
+		return WriteToken{}, fmt.Errorf("%w: %q by %q", ErrClaimed, bk.name(), c.owner)
+	}
+	bk.claims++
+	bk.claim = &claim{owner: owner, id: bk.claims, expiresAt: now + int64(b.pool.opts.claimTTL)}
+	return WriteToken{bucket: Bucket{pool: b.pool, b: bk}, owner: owner, id: bk.claims}, nil
+}
+
+// Owner returns the owner holding the bucket's claim, and false if it is not
+// claimed.
+func (b *Bucket) Owner() (string, bool) {
+	bk := b.resolve("owner")
+	if bk == nil {
+		return "", false
+	}
+	now := b.pool.now()
+
+	bk.guard.RLock()
+	defer bk.guard.RUnlock()
+
+	if c := bk.claim; c != nil && now < c.expiresAt {
+		return c.owner, true
+	}
+	return "", false
+}
+
+// Owner returns the owner the token was claimed for.
+func (t WriteToken) Owner() string {
+	return t.owner
+}
+
+// Put stores value in the claimed bucket like PutE, and returns the new
+// timestamp. It fails with an error wrapping ErrClaimLost if the claim
+// expired, was released or was superseded.
+func (t WriteToken) Put(value any) (int64, error) {
+	bk, err := t.bucket.lookup("put claimed")
+	if err != nil {
+		return 0, err
+	}
+	u, err := t.bucket.pool.writeAt(context.Background(), bk, Update{Value: value, claim: t.id}, 0, ConsistencyDefault)
+	if err == nil && u.Timestamp == 0 {
+		err = bk.checkRemoved()
+	}
+	return u.Timestamp, err
+}
+
+// Renew extends the claim by the claim TTL from now. It fails with an error
+// wrapping ErrClaimLost if the claim was released or superseded; a claim
+// that expired is renewed if no one claimed the bucket since.
+func (t WriteToken) Renew() error {
+	bk, err := t.bucket.lookup("renew claim")
+	if err != nil {
+		return err
+	}
+	now := t.bucket.pool.now()
+
+	bk.guard.Lock()
+	defer bk.guard.Unlock()
+
+	c := bk.claim
+	if c == nil || c.id != t.id {
+		return fmt.Errorf("%w: %q by %q: released or superseded", ErrClaimLost, bk.name(), t.owner)
+	}
+	c.expiresAt = now + int64(t.bucket.pool.opts.claimTTL)
+	return nil
+}
+
+// Release gives up the claim, so anyone may write the bucket again. It does
+// nothing if the claim was lost already.
+func (t WriteToken) Release() {
+	bk := t.bucket.resolve("release claim")
+	if bk == nil {
+		return
+	}
+
+	bk.guard.Lock()
+	defer bk.guard.Unlock()
+
+	if c := bk.claim; c != nil && c.id == t.id {
+		bk.claim = nil
+	}
+}
+
+// checkClaim returns the error of a write to b at now through the claim with
+// id, zero for writes without a token. It must be called with b.guard held.
+func (b *bucket) checkClaim(now int64, id uint64) error {
+	c := b.claim
+	live := c != nil && now < c.expiresAt
+	switch {
+	case id == 0 && live:
+		return fmt.Errorf("%w: %q by %q", ErrClaimed, b.name(), c.owner)
+	case id == 0:
+		return nil
+	case c == nil || c.id != id:
+		return fmt.Errorf("%w: %q: released or superseded", ErrClaimLost, b.name())
+	case !live:
+		return fmt.Errorf("%w: %q by %q: expired", ErrClaimLost, b.name(), c.owner)
+	}
+	return nil
+}
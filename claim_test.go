@@ -0,0 +1,97 @@
+package datapool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaim(t *testing.T) {
+	var reported []error
+	pool := NewDataPool(WithErrorHandler(func(_ string, err error) {
+		reported = append(reported, err)
+	}))
+	rates := pool.Bucket("rates")
+
+	token, err := rates.Claim("refresher-a")
+	require.NoError(t, err)
+	assert.Equal(t, "refresher-a", token.Owner())
+	owner, ok := rates.Owner()
+	assert.True(t, ok)
+	assert.Equal(t, "refresher-a", owner)
+
+	ts, err := token.Put("from a")
+	require.NoError(t, err)
+	assert.NotZero(t, ts)
+
+	_, err = rates.Claim("refresher-b")
+	assert.ErrorIs(t, err, ErrClaimed)
+	assert.ErrorIs(t, err, ErrSealed)
+
+	assert.Zero(t, rates.Put("from b"))
+	require.Len(t, reported, 1)
+	assert.ErrorIs(t, reported[0], ErrClaimed)
+	_, err = rates.PutE("from b")
+	assert.ErrorIs(t, err, ErrClaimed)
+	assert.Zero(t, pool.PutMany(map[string]any{"rates": "from b"})["rates"])
+	err = pool.Update(func(tx *Tx) error {
+		tx.Put("rates", "from b")
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrClaimed)
+	value, _, _ := rates.Get(0)
+	assert.Equal(t, "from a", value, "Only the holder writes a claimed bucket")
+
+	again, err := rates.Claim("refresher-a")
+	require.NoError(t, err, "The same owner may claim again")
+	_, err = token.Put("stale")
+	assert.ErrorIs(t, err, ErrClaimLost, "Claiming again supersedes the previous token")
+	assert.ErrorIs(t, token.Renew(), ErrClaimLost)
+
+	again.Release()
+	_, ok = rates.Owner()
+	assert.False(t, ok)
+	_, err = again.Put("released")
+	assert.ErrorIs(t, err, ErrClaimLost)
+	assert.NotZero(t, rates.Put("from b"), "Released buckets can be written by anyone")
+
+	_, err = WriteToken{}.Put("v")
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+	system := pool.Bucket(SystemStatsBucket)
+	_, err = system.Claim("x")
+	assert.ErrorIs(t, err, ErrSystemBucket)
+}
+
+func TestClaimExpires(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock), WithClaimTTL(time.Minute))
+	rates := pool.Bucket("rates")
+
+	crashed, err := rates.Claim("refresher-a")
+	require.NoError(t, err)
+	clock.Advance(time.Minute)
+
+	_, ok := rates.Owner()
+	assert.False(t, ok, "Claims expire after the claim TTL")
+	_, err = crashed.Put("late")
+	assert.ErrorIs(t, err, ErrClaimLost)
+	require.NoError(t, crashed.Renew(), "Expired claims no one took over can be renewed")
+	_, err = crashed.Put("renewed")
+	require.NoError(t, err)
+
+	clock.Advance(time.Minute)
+	token, err := rates.Claim("refresher-b")
+	require.NoError(t, err, "Expired claims can be taken over")
+	_, err = token.Put("from b")
+	require.NoError(t, err)
+	assert.ErrorIs(t, crashed.Renew(), ErrClaimLost)
+
+	clock.Advance(time.Minute / 2)
+	require.NoError(t, token.Renew())
+	clock.Advance(time.Minute / 2)
+	owner, ok := rates.Owner()
+	assert.True(t, ok, "Renewing extends the claim")
+	assert.Equal(t, "refresher-b", owner)
+}
@@ -0,0 +1,92 @@
+package datapool
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock supplies the wall time a pool derives its timestamps and ages from.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock backed by time.Now. It is the default.
+type SystemClock struct{}
+
+// Now returns the current time.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ManualClock is a Clock that only moves when told to, for deterministic tests.
+// The zero value starts at the Unix epoch. It is safe for concurrent use.
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewManualClock returns a ManualClock set to t.
+func NewManualClock(t time.Time) *ManualClock {
+	return &ManualClock{now: t}
+}
+
+// Now returns the clock's current time.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.now.IsZero() {
+		return time.Unix(0, 0)
+	}
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.now.IsZero() {
+		c.now = time.Unix(0, 0)
+	}
+	c.now = c.now.Add(d)
+}
+
+// Set moves the clock to t, which may be in the past.
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = t
+}
+
+// stamp returns a timestamp for a new write. Timestamps follow the pool's
+// clock but are strictly increasing across the whole pool, even when the clock
+// stands still or is set back, so freshness comparisons are always ordered.
+func (p *DataPool) stamp() int64 {
+	p.lazyInit()
+	now := p.opts.clock.Now().UnixNano()
+	for {
+		last := p.lastStamp.Load()
+		ts := max(now, last+1)
+		if p.lastStamp.CompareAndSwap(last, ts) {
+			return ts
+		}
+	}
+}
+
+// observe makes later stamps exceed ts, a timestamp assigned elsewhere.
+func (p *DataPool) observe(ts int64) {
+	for {
+		last := p.lastStamp.Load()
+		if last >= ts || p.lastStamp.CompareAndSwap(last, ts) {
+			return
+		}
+	}
+}
+
+// now returns the current time of the pool's clock in nanoseconds.
+func (p *DataPool) now() int64 {
+	p.lazyInit()
+	return p.opts.clock.Now().UnixNano()
+}
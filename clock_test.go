@@ -0,0 +1,92 @@
+package datapool
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManualClock(t *testing.T) {
+	var zero ManualClock
+	assert.Equal(t, time.Unix(0, 0), zero.Now())
+	zero.Advance(time.Second)
+	assert.Equal(t, time.Unix(1, 0), zero.Now())
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	clock.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), clock.Now())
+	clock.Set(start)
+	assert.Equal(t, start, clock.Now())
+}
+
+func TestTimestampsFollowClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewManualClock(start)
+	pool := NewDataPool(WithClock(clock))
+	bucket := pool.Bucket("test")
+
+	assert.Equal(t, start.UnixNano(), bucket.Put(1))
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second).UnixNano(), bucket.Put(2))
+}
+
+func TestTimestampsStrictlyIncreasing(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	a := pool.Bucket("a")
+	b := pool.Bucket("b")
+
+	// The clock stands still, yet every write is ordered after the previous one.
+	ts1 := a.Put(1)
+	ts2 := b.Put(2)
+	ts3 := a.Put(3)
+	assert.Equal(t, ts1+1, ts2)
+	assert.Equal(t, ts2+1, ts3)
+
+	_, _, fresh := a.Get(ts2)
+	assert.True(t, fresh, "Write after ts2 is fresh relative to ts2")
+
+	// Setting the clock back does not make timestamps go backwards.
+	clock.Set(time.Unix(10, 0))
+	assert.Greater(t, b.Put(4), ts3)
+}
+
+func TestConcurrentTimestampsUnique(t *testing.T) {
+	pool := NewDataPool(WithClock(NewManualClock(time.Unix(1000, 0))))
+	bucket := pool.Bucket("test")
+
+	const numGoroutines = 50
+	var mu sync.Mutex
+	seen := make(map[int64]bool)
+	var wg sync.WaitGroup
+	wg.Add(numGoroutines)
+	for i := 0; i < numGoroutines; i++ {
+		go func(val int) {
+			defer wg.Done()
+			ts := bucket.Put(val)
+			mu.Lock()
+			seen[ts] = true
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Len(t, seen, numGoroutines, "No two writes share a timestamp")
+}
+
+func TestAgesUseClock(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	bucket := pool.Bucket("config")
+	bucket.Put("v1")
+	clock.Advance(90 * time.Second)
+
+	var buf bytes.Buffer
+	require.NoError(t, pool.WriteOpenMetrics(&buf, 0))
+	assert.Equal(t, 90.0, parseSamples(t, buf.String())[`datapool_bucket_age_seconds{bucket="config"}`])
+}
@@ -0,0 +1,138 @@
+package datapool
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// CloneOption configures DataPool.Clone.
+type CloneOption func(*cloneConfig)
+
+type cloneConfig struct {
+	shallow bool
+}
+
+// WithShallowClone makes Clone share values with the pool instead of copying
+// them, which is much faster, and safe when values are never mutated in
+// place: writes to either pool still only replace values in that pool.
+func WithShallowClone() CloneOption {
+	return func(c *cloneConfig) {
+		c.shallow = true
+	}
+}
+
+// Clone returns an independent copy of the pool, for tests and what-if
+// simulations that must not affect it. Values are deep-copied by a round trip
+// through the pool's codec (see WithCodec), unless WithShallowClone is given,
+// and keep their timestamps, versions, expiration times, provenance and
+// schema versions. Buckets keep their settings: TTLs, priorities, pins,
+// loaders, validators and transformers.
+//
+// The copy has the pool's configuration except for what reaches outside of
+// it, so that it never writes back to the systems the pool writes to: it has
+// no backend, write-ahead log, writers, metrics recorder, logger, callbacks,
+// error handler or memory pressure monitor. Watchers, throttles and derived buckets are not copied either;
+// derived buckets keep their current value. Each bucket is copied under its
+// own lock, so the copy is not atomic across buckets.
+//
+// Clone fails if a value cannot be copied with the codec, naming the bucket.
+func (p *DataPool) Clone(opts ...CloneOption) (*DataPool, error) {
+	var cfg cloneConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	o := p.opts
+	o.backend = nil
+	o.wal = nil
+	o.writer = nil
+	o.metrics = nil
+	o.onPut = nil
+	o.onEvict = nil
+	o.onError = nil
+	o.pressure = nil
+	o.hotGets = nil
+	o.logger = nil
+	o.driftThreshold = 0
+	clone := NewDataPool(func(dst *options) { *dst = o })
+	clone.observe(p.lastStamp.Load())
+
+	for _, b := range p.all() {
+		if b.system {
+			continue
+		}
+		if err := clone.cloneBucket(b, cfg); err != nil {
+			return nil, err
+		}
+	}
+	return clone, nil
+}
+
+// cloneBucket stores a copy of b in p.
+func (p *DataPool) cloneBucket(src *bucket, cfg cloneConfig) error {
+	dst, err := p.bucket(src.name())
+	if err != nil {
+		return err
+	}
+
+	src.guard.RLock()
+	defer src.guard.RUnlock()
+
+	if src.removed {
+		return nil
+	}
+	value := src.current()
+	if !cfg.shallow && value != nil {
+		if value, err = p.copyWithCodec(value); err != nil {
+			return fmt.Errorf("datapool: clone %q: %w", src.name(), err)
+		}
+	}
+
+	dst.guard.Lock()
+	defer dst.guard.Unlock()
+
+	dst.ttl, dst.softTTL, dst.hardTTL = src.ttl, src.softTTL, src.hardTTL
+	dst.priority = src.priority
+	dst.loader = src.loader
+	dst.validate, dst.transform = src.validate, src.transform
+	dst.expected, dst.expectedSince = src.expected, src.expectedSince
+	dst.copyValues.Store(src.copyValues.Load())
+	if src.pinned.Load() && dst.pinned.CompareAndSwap(false, true) {
+		p.pinned.Add(1)
+	}
+	if src.timestamp != 0 {
+		dst.store(value, src.timestamp)
+		dst.cachedErr = src.cachedErr
+		dst.expiresAt = src.expiresAt
+	}
+	dst.version = src.version
+	dst.fence = src.fence
+	dst.provenance = src.provenance
+	dst.schema = src.schema
+	dst.reschedule()
+	return nil
+}
+
+// copyWithCodec returns a deep copy of value, encoded and decoded with the
+// pool's codec into a value of the same type. Encoded values are copied as
+// they are.
+func (p *DataPool) copyWithCodec(value any) (any, error) {
+	switch v := value.(type) {
+	case Encoded:
+		return Encoded{Codec: v.Codec, Data: bytes.Clone(v.Data)}, nil
+	case []byte:
+		return bytes.Clone(v), nil
+	}
+
+	codec := p.opts.codec
+	data, err := codec.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("encode %T with %s: %w", value, codec.Name(), err)
+	}
+	copied := reflect.New(reflect.TypeOf(value))
+	if err := codec.Unmarshal(data, copied.Interface()); err != nil {
+		return nil, fmt.Errorf("decode %T with %s: %w", value, codec.Name(), err)
+	}
+	return copied.Elem().Interface(), nil
+}
@@ -0,0 +1,133 @@
+package datapool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type cloneConfigValue struct {
+	Name  string
+	Hosts []string
+}
+
+func TestClone(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	config := pool.Bucket("config")
+	ts := config.Put(cloneConfigValue{Name: "a", Hosts: []string{"h1", "h2"}})
+	limits := pool.Bucket("limits")
+	limits.PutSchema(map[string]int{"rps": 10}, 2)
+
+	clone, err := pool.Clone()
+	require.NoError(t, err)
+
+	cc := clone.Bucket("config")
+	value, got, _ := cc.Get(0)
+	assert.Equal(t, cloneConfigValue{Name: "a", Hosts: []string{"h1", "h2"}}, value)
+	assert.Equal(t, ts, got, "Timestamps are kept")
+	assert.Equal(t, config.Version(), cc.Version())
+
+	value.(cloneConfigValue).Hosts[0] = "changed"
+	value, _, _ = config.Get(0)
+	assert.Equal(t, "h1", value.(cloneConfigValue).Hosts[0], "Values are deep-copied")
+
+	cl := clone.Bucket("limits")
+	value, _, _ = cl.Get(0)
+	value.(map[string]int)["rps"] = 99
+	value, _, _ = limits.Get(0)
+	assert.Equal(t, 10, value.(map[string]int)["rps"])
+	assert.Equal(t, 2, cl.Schema())
+
+	cc.Put(cloneConfigValue{Name: "b"})
+	value, _, _ = config.Get(0)
+	assert.Equal(t, "a", value.(cloneConfigValue).Name, "Writes to the clone stay in it")
+	config.Put(cloneConfigValue{Name: "c"})
+	value, _, _ = cc.Get(0)
+	assert.Equal(t, "b", value.(cloneConfigValue).Name, "Writes to the pool stay in it")
+
+	assert.Greater(t, clone.Handle("other").Put(1), ts, "The clone's clock is past the pool's timestamps")
+}
+
+func TestCloneShallow(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("limits")
+	b.Put(map[string]int{"rps": 10})
+
+	clone, err := pool.Clone(WithShallowClone())
+	require.NoError(t, err)
+
+	cb := clone.Bucket("limits")
+	value, _, _ := cb.Get(0)
+	value.(map[string]int)["rps"] = 99
+	value, _, _ = b.Get(0)
+	assert.Equal(t, 99, value.(map[string]int)["rps"], "Shallow clones share values")
+
+	cb.Put(map[string]int{"rps": 1})
+	value, _, _ = b.Get(0)
+	assert.Equal(t, 99, value.(map[string]int)["rps"], "Writes still replace values in one pool only")
+}
+
+func TestCloneKeepsSettings(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	session := pool.Bucket("session")
+	session.SetTTL(time.Minute)
+	session.Put("token")
+	keys := pool.Bucket("auth/keys")
+	keys.SetTTL(time.Second)
+	keys.Put("key")
+	keys.Pin()
+
+	clone, err := pool.Clone()
+	require.NoError(t, err)
+	assert.Equal(t, 1, clone.PinnedBuckets())
+	assert.True(t, clone.Handle("auth/keys").(*Bucket).Pinned())
+
+	name, at, ok := clone.NextExpiry()
+	require.True(t, ok)
+	assert.Equal(t, "session", name)
+	assert.Equal(t, time.Unix(1060, 0), at)
+
+	clock.Advance(2 * time.Minute)
+	assert.Equal(t, 1, clone.Expire(), "Only the unpinned value expires")
+	value, _, _ := session.Get(0)
+	assert.Nil(t, value)
+	value, _, _ = clone.Handle("auth/keys").Get(0)
+	assert.Equal(t, "key", value)
+}
+
+func TestCloneDetachesBackend(t *testing.T) {
+	backend := &MemoryBackend{}
+	var puts int
+	pool := NewDataPool(WithBackend(backend), WithPutCallback(func(string, any, int64) { puts++ }))
+	pool.Handle("config").Put("v1")
+	require.Equal(t, 1, puts)
+
+	clone, err := pool.Clone()
+	require.NoError(t, err)
+	clone.Handle("config").Put("v2")
+	clone.Handle("other").Put("v3")
+	assert.Equal(t, 1, puts, "Callbacks are not copied")
+
+	u, err := backend.Get(context.Background(), "config")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", u.Value, "The clone does not write to the backend")
+	value, _, _ := clone.Handle("missing").Get(0)
+	assert.Nil(t, value)
+}
+
+func TestCloneEncodeError(t *testing.T) {
+	pool := NewDataPool()
+	pool.Handle("events").Put(make(chan int))
+
+	_, err := pool.Clone()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"events"`)
+
+	_, err = pool.Clone(WithShallowClone())
+	assert.NoError(t, err, "Shallow clones do not encode values")
+}
@@ -0,0 +1,136 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrPoolClosed is wrapped by the errors of operations on a closed pool (see
+// DataPool.Close). It is an ErrClosed, and matches os.ErrClosed.
+var ErrPoolClosed = withKind(newKindError(ErrClosed, "datapool: pool closed"), os.ErrClosed)
+
+// closedError returns the error of op on a closed pool.
+func closedError(op string) error {
+	return fmt.Errorf("datapool: %s: %w", op, ErrPoolClosed)
+}
+
+// Close shuts the pool down. From the call on, operations on the pool and
+// its buckets fail with errors wrapping ErrPoolClosed: those returning
+// errors return them, the others pass them to the error handler and do
+// nothing, as with removed buckets. Operations already running complete.
+// Then Close
+//
+//   - stores the values held back by throttles (see SetThrottle);
+//   - waits for the pool's background work: refreshes, including those of
+//     Refreshers, recomputations of derived buckets, stale-while-revalidate
+//     reloads, background writes to the backend and write-ahead log
+//     compactions; queued refreshes fail with ErrPoolClosed without running
+//     their loader, and derived buckets waiting for their batch window are
+//     not recomputed;
+//   - replays the writes queued while the backend was unreachable (see
+//     WithOfflineQueue);
+//   - closes the channels of every watcher, including those of WatchPrefix
+//     and WatchMatch;
+//   - and syncs and closes the write-ahead log, and the snapshot file of
+//     WithSnapshotFallback.
+//
+// If ctx is done before the background work completes or the writes are
+// replayed, Close skips to closing the watchers and the log, and returns
+// ctx.Err() along with any other error. Run of a Refresher returns. Calling
+// Close again returns nil.
+func (p *DataPool) Close(ctx context.Context) error {
+	p.lazyInit()
+	p.closeMu.Lock()
+	if p.closed.Load() {
+		p.closeMu.Unlock()
+		return nil
+	}
+	p.closed.Store(true)
+	close(p.done)
+	p.closeMu.Unlock()
+
+	for _, b := range p.all() {
+		if t := b.throttle.Load(); t != nil {
+			p.flushThrottle(b, t, 0)
+		}
+	}
+
+	var errs []error
+	if err := p.waitBackground(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("datapool: close: background work: %w", err))
+	} else if p.offlineMode() {
+		if err := p.replay(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("datapool: close: offline queue: %w", err))
+		}
+	}
+
+	for _, b := range p.all() {
+		b.guard.Lock()
+		watchers := b.watchers
+		b.watchers = nil
+		b.guard.Unlock()
+		closeWatchers(watchers)
+	}
+	for _, h := range p.nameWatchers.snapshot() {
+		h.fn.close()
+	}
+
+	if p.wal != nil {
+		if err := p.wal.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("datapool: close: %w", err))
+		}
+	}
+	if s := p.opts.snapshot; s != nil {
+		s.mu.Lock()
+		s.close()
+		s.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// Done returns a channel closed when Close is called, for goroutines serving
+// the pool, such as a refresher.Scheduler, to stop.
+func (p *DataPool) Done() <-chan struct{} {
+	p.lazyInit()
+	return p.done
+}
+
+// isClosed reports whether Close was called.
+func (p *DataPool) isClosed() bool {
+	return p.closed.Load()
+}
+
+// goBackground runs fn in a goroutine Close waits for, and reports whether it
+// does: once the pool is closed, fn is not run.
+func (p *DataPool) goBackground(fn func()) bool {
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+
+	if p.closed.Load() {
+		return false
+	}
+	p.background.Add(1)
+	go func() {
+		defer p.background.Done()
+		fn()
+	}()
+	return true
+}
+
+// waitBackground waits for the goroutines started by goBackground, or for ctx
+// to be done.
+func (p *DataPool) waitBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,144 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClose(t *testing.T) {
+	var mu sync.Mutex
+	var reported []error
+	pool := NewDataPool(WithErrorHandler(func(_ string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, err)
+	}))
+	b := pool.Bucket("config")
+	b.Put("value")
+	updates := b.Watch(context.Background())
+	prefixed := pool.WatchPrefix(context.Background(), "con")
+
+	require.NoError(t, pool.Close(context.Background()))
+	assertClosed(t, updates)
+	assertClosed(t, prefixed)
+	select {
+	case <-pool.Done():
+	default:
+		assert.Fail(t, "Done is closed")
+	}
+
+	_, err := b.PutE("other")
+	assert.ErrorIs(t, err, ErrPoolClosed)
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.ErrorIs(t, err, os.ErrClosed)
+	_, _, _, err = b.GetE(0)
+	assert.ErrorIs(t, err, ErrPoolClosed)
+	assert.Zero(t, b.Put("other"))
+	value, _, _ := b.Get(0)
+	assert.Nil(t, value)
+	_, err = pool.Lookup("config")
+	assert.ErrorIs(t, err, ErrPoolClosed)
+	assert.ErrorIs(t, <-pool.ScheduleRefresh("config", func() (any, error) { return 1, nil }), ErrPoolClosed)
+	assertClosed(t, b.Watch(context.Background()))
+	assertClosed(t, pool.WatchPrefix(context.Background(), ""))
+
+	mu.Lock()
+	require.NotEmpty(t, reported)
+	for _, err := range reported {
+		assert.ErrorIs(t, err, ErrPoolClosed)
+	}
+	mu.Unlock()
+	assert.NoError(t, pool.Close(context.Background()), "Closing again does nothing")
+}
+
+func TestCloseFlushes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+	pool := NewDataPool(WithWAL(openWAL(t, path)))
+	b := pool.Bucket("sensor")
+	b.SetThrottle(WithMinPutInterval(time.Hour))
+	b.Put("first")
+	b.Put("last")
+	require.NoError(t, pool.Close(context.Background()))
+
+	restored := NewDataPool(WithWAL(openWAL(t, path)))
+	value, _, _ := restored.Handle("sensor").Get(0)
+	assert.Equal(t, "last", value, "Throttled values are stored and logged on close")
+}
+
+func TestCloseWaits(t *testing.T) {
+	pool := NewDataPool(WithRefreshWorkers(1))
+	release := make(chan struct{})
+	running := pool.ScheduleRefresh("slow", func() (any, error) {
+		<-release
+		return "slow", nil
+	})
+	ran := false
+	queued := pool.ScheduleRefresh("queued", func() (any, error) {
+		ran = true
+		return "queued", nil
+	})
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, pool.Close(ctx), context.DeadlineExceeded, "Close gives up when ctx is done")
+	close(release)
+	assert.NoError(t, <-running)
+	assert.ErrorIs(t, <-queued, ErrPoolClosed)
+	assert.False(t, ran, "Queued refreshes do not run once the pool is closed")
+}
+
+func TestCloseStopsRefresher(t *testing.T) {
+	pool := NewDataPool()
+	r := pool.NewRefresher("config", func() (any, error) { return 1, nil }, time.Hour)
+	done := make(chan error, 1)
+	go func() { done <- r.Run(context.Background(), nil) }()
+
+	require.NoError(t, pool.Close(context.Background()))
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrPoolClosed)
+	case <-time.After(time.Second):
+		assert.Fail(t, "Run returns once the pool is closed")
+	}
+}
+
+func TestCloseDerive(t *testing.T) {
+	pool := NewDataPool()
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	_, err := pool.Derive("total", []string{"items"}, func(values []any) (any, error) {
+		once.Do(func() { close(started) })
+		<-release
+		return values[0], nil
+	}, WithFreeze())
+	require.NoError(t, err)
+	<-started
+
+	closed := make(chan error, 1)
+	go func() { closed <- pool.Close(context.Background()) }()
+	select {
+	case <-closed:
+		assert.Fail(t, "Close waits for running recomputations")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	assert.NoError(t, <-closed)
+
+	batched := NewDataPool()
+	_, err = batched.Derive("total", []string{"items"}, func([]any) (any, error) {
+		return nil, errors.New("not run")
+	}, WithBatchWindow(time.Hour))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, batched.Close(ctx), "Close cuts batch windows short")
+}
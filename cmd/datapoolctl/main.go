@@ -0,0 +1,374 @@
+// Command datapoolctl inspects and changes the pool of a running service,
+// over the HTTP API of datapoolhttp or the gRPC API of datapoolgrpc.
+//
+// Usage:
+//
+//	datapoolctl [flags] list
+//	datapoolctl [flags] get NAME
+//	datapoolctl [flags] put NAME JSON|-
+//	datapoolctl [flags] watch [-n COUNT] NAME
+//	datapoolctl [flags] snapshot
+//
+// Values are JSON: get prints the bucket's value, put reads it from its
+// argument, or from standard input if that is "-", and watch prints one line
+// per update, with its timestamp and bucket name. snapshot has the service
+// compact its write-ahead log. The gRPC API has no listing nor snapshots, so
+// list and snapshot need the HTTP API.
+//
+// The flags are:
+//
+//	-addr ADDR
+//		The service's HTTP address, such as http://cache:8080, or its gRPC
+//		address, such as cache:9090, with -grpc. Defaults to $DATAPOOL_ADDR,
+//		or localhost:8080.
+//	-grpc
+//		Use the gRPC API.
+//	-timeout DURATION
+//		The timeout of each request other than watch. Defaults to 10s.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/radamsa/datapool"
+	"github.com/radamsa/datapool/datapoolclient"
+	"github.com/radamsa/datapool/datapoolgrpc"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// errUsage fails a command run with the wrong arguments.
+var errUsage = errors.New("usage")
+
+const usage = `usage: datapoolctl [-addr ADDR] [-grpc] [-timeout DURATION] COMMAND
+
+commands:
+  list                  list the buckets and their timestamps
+  get NAME              print the bucket's value as JSON
+  put NAME JSON|-       store a JSON value, read from stdin for "-"
+  watch [-n COUNT] NAME print updates as they happen
+  snapshot              compact the service's write-ahead log
+`
+
+// run runs the command line args and returns the exit status.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("datapoolctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fmt.Fprintln(stderr, "\nflags:")
+		flags.PrintDefaults()
+	}
+	addr := flags.String("addr", defaultAddr(), "service address")
+	useGRPC := flags.Bool("grpc", false, "use the gRPC API")
+	timeout := flags.Duration("timeout", 10*time.Second, "request timeout")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	r, err := dial(*addr, *useGRPC, *timeout)
+	if err != nil {
+		fmt.Fprintf(stderr, "datapoolctl: %v\n", err)
+		return 1
+	}
+	defer r.Close()
+
+	cmd := &command{remote: r, timeout: *timeout, stdin: stdin, stdout: stdout}
+	err = cmd.run(ctx, flags.Arg(0), flags.Args()[1:])
+	switch {
+	case errors.Is(err, errUsage):
+		fmt.Fprint(stderr, usage)
+		return 2
+	case err != nil:
+		fmt.Fprintf(stderr, "datapoolctl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func defaultAddr() string {
+	if addr := os.Getenv("DATAPOOL_ADDR"); addr != "" {
+		return addr
+	}
+	return "localhost:8080"
+}
+
+// remote is a pool of a running service.
+type remote interface {
+	// Buckets returns the names and timestamps of the buckets.
+	Buckets(ctx context.Context) ([]bucketInfo, error)
+	Get(ctx context.Context, name string) (any, int64, error)
+	Put(ctx context.Context, name string, value any) (int64, error)
+	// Watch returns the updates of the named bucket, and the error that
+	// ended them, received once the channel is closed.
+	Watch(ctx context.Context, name string) (<-chan datapool.Update, <-chan error)
+	Snapshot(ctx context.Context) (int, error)
+	Close() error
+}
+
+type bucketInfo struct {
+	name      string
+	timestamp int64
+}
+
+// errNoGRPC fails the commands the gRPC API does not serve.
+var errNoGRPC = errors.New("not supported by the gRPC API; use the HTTP API")
+
+// dial returns the remote pool at addr.
+func dial(addr string, useGRPC bool, timeout time.Duration) (remote, error) {
+	errs := make(chan error, 1)
+	onError := func(_ string, err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	if !useGRPC {
+		c := datapoolclient.New(addr, datapoolclient.WithTimeout(timeout), datapoolclient.WithErrorHandler(onError))
+		return &httpRemote{client: c, errs: errs}, nil
+	}
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	c := datapoolgrpc.New(conn, datapoolgrpc.WithTimeout(timeout), datapoolgrpc.WithErrorHandler(onError))
+	return &grpcRemote{conn: conn, client: c, errs: errs}, nil
+}
+
+type httpRemote struct {
+	client *datapoolclient.Client
+	errs   chan error
+}
+
+func (r *httpRemote) Buckets(ctx context.Context) ([]bucketInfo, error) {
+	list, err := r.client.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]bucketInfo, len(list))
+	for i, b := range list {
+		infos[i] = bucketInfo{name: b.Name, timestamp: b.Timestamp}
+	}
+	return infos, nil
+}
+
+func (r *httpRemote) Get(ctx context.Context, name string) (any, int64, error) {
+	value, ts, _, err := r.client.Bucket(name).GetContext(ctx, 0)
+	return value, ts, err
+}
+
+func (r *httpRemote) Put(ctx context.Context, name string, value any) (int64, error) {
+	return r.client.Bucket(name).PutContext(ctx, value)
+}
+
+func (r *httpRemote) Watch(ctx context.Context, name string) (<-chan datapool.Update, <-chan error) {
+	return r.client.Bucket(name).Watch(ctx), r.errs
+}
+
+func (r *httpRemote) Snapshot(ctx context.Context) (int, error) {
+	return r.client.Snapshot(ctx)
+}
+
+func (r *httpRemote) Close() error {
+	return nil
+}
+
+type grpcRemote struct {
+	conn   *grpc.ClientConn
+	client *datapoolgrpc.Client
+	errs   chan error
+}
+
+func (r *grpcRemote) Buckets(context.Context) ([]bucketInfo, error) {
+	return nil, fmt.Errorf("list: %w", errNoGRPC)
+}
+
+func (r *grpcRemote) Get(ctx context.Context, name string) (any, int64, error) {
+	value, ts, _, err := r.client.Bucket(name).GetContext(ctx, 0)
+	return value, ts, err
+}
+
+func (r *grpcRemote) Put(ctx context.Context, name string, value any) (int64, error) {
+	return r.client.Bucket(name).PutContext(ctx, value)
+}
+
+func (r *grpcRemote) Watch(ctx context.Context, name string) (<-chan datapool.Update, <-chan error) {
+	return r.client.Bucket(name).Watch(ctx), r.errs
+}
+
+func (r *grpcRemote) Snapshot(context.Context) (int, error) {
+	return 0, fmt.Errorf("snapshot: %w", errNoGRPC)
+}
+
+func (r *grpcRemote) Close() error {
+	return r.conn.Close()
+}
+
+// command runs a command against a remote pool.
+type command struct {
+	remote  remote
+	timeout time.Duration
+	stdin   io.Reader
+	stdout  io.Writer
+}
+
+func (c *command) run(ctx context.Context, name string, args []string) error {
+	switch name {
+	case "list":
+		return c.list(ctx, args)
+	case "get":
+		return c.get(ctx, args)
+	case "put":
+		return c.put(ctx, args)
+	case "watch":
+		return c.watch(ctx, args)
+	case "snapshot":
+		return c.snapshot(ctx, args)
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, name)
+	}
+}
+
+// callContext returns the context of a request other than watch.
+func (c *command) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+func (c *command) list(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	buckets, err := c.remote.Buckets(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "NAME\tUPDATED\tTIMESTAMP\n")
+	for _, b := range buckets {
+		updated := "never"
+		if b.timestamp != 0 {
+			updated = time.Unix(0, b.timestamp).UTC().Format(time.RFC3339Nano)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", b.name, updated, b.timestamp)
+	}
+	return tw.Flush()
+}
+
+func (c *command) get(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	value, _, err := c.remote.Get(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.printJSON(value)
+}
+
+func (c *command) put(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	data := []byte(args[1])
+	if args[1] == "-" {
+		var err error
+		if data, err = io.ReadAll(c.stdin); err != nil {
+			return fmt.Errorf("read value: %w", err)
+		}
+	}
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("value is not JSON: %w", err)
+	}
+
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	ts, err := c.remote.Put(ctx, args[0], value)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, ts)
+	return nil
+}
+
+func (c *command) watch(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	count := flags.Int("n", 0, "exit after COUNT updates")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	updates, errs := c.remote.Watch(ctx, flags.Arg(0))
+	seen := 0
+	for u := range updates {
+		value, err := json.Marshal(u.Value)
+		if err != nil {
+			return fmt.Errorf("encode update: %w", err)
+		}
+		fmt.Fprintf(c.stdout, "%d %s %s\n", u.Timestamp, strconv.Quote(u.Bucket), value)
+		if seen++; *count > 0 && seen == *count {
+			return nil
+		}
+	}
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (c *command) snapshot(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	n, err := c.remote.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "snapshot taken: %d buckets\n", n)
+	return nil
+}
+
+func (c *command) printJSON(value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode value: %w", err)
+	}
+	_, err = fmt.Fprintf(c.stdout, "%s\n", data)
+	return err
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/radamsa/datapool"
+	"github.com/radamsa/datapool/datapoolgrpc"
+	"github.com/radamsa/datapool/datapoolhttp"
+)
+
+// ctl runs datapoolctl with args and returns its exit status and output.
+func ctl(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func serveHTTP(t *testing.T, pool *datapool.DataPool) string {
+	srv := httptest.NewServer(datapoolhttp.NewHandler(pool))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func serveGRPC(t *testing.T, pool *datapool.DataPool) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer()
+	datapoolgrpc.RegisterDataPoolServer(s, datapoolgrpc.NewServer(pool))
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+func TestGetPut(t *testing.T) {
+	pool := datapool.NewDataPool()
+	for _, api := range []struct {
+		name string
+		args []string
+	}{
+		{"http", []string{"-addr", serveHTTP(t, pool)}},
+		{"grpc", []string{"-grpc", "-addr", serveGRPC(t, pool)}},
+	} {
+		t.Run(api.name, func(t *testing.T) {
+			code, out, errOut := ctl(t, "", append(api.args, "put", "config/"+api.name, `{"retries":3}`)...)
+			require.Equal(t, 0, code, errOut)
+			assert.NotEqual(t, "0\n", out, "put prints the timestamp")
+
+			b := pool.Bucket("config/" + api.name)
+			value, _, _ := b.Get(0)
+			assert.Equal(t, map[string]any{"retries": 3.0}, value)
+
+			code, out, errOut = ctl(t, "", append(api.args, "get", "config/"+api.name)...)
+			require.Equal(t, 0, code, errOut)
+			assert.JSONEq(t, `{"retries":3}`, out)
+
+			code, _, errOut = ctl(t, `[1, 2]`, append(api.args, "put", "list/"+api.name, "-")...)
+			require.Equal(t, 0, code, errOut)
+			b = pool.Bucket("list/" + api.name)
+			value, _, _ = b.Get(0)
+			assert.Equal(t, []any{1.0, 2.0}, value, "Values can come from stdin")
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	pool := datapool.NewDataPool()
+	pool.Handle("config").Put(1)
+	pool.Bucket("empty")
+
+	code, out, errOut := ctl(t, "", "-addr", serveHTTP(t, pool), "list")
+	require.Equal(t, 0, code, errOut)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "NAME"))
+	assert.True(t, strings.HasPrefix(lines[1], "config "))
+	assert.Contains(t, lines[2], "never")
+
+	code, _, errOut = ctl(t, "", "-grpc", "-addr", serveGRPC(t, pool), "list")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "not supported by the gRPC API")
+}
+
+func TestWatch(t *testing.T) {
+	pool := datapool.NewDataPool()
+	addr := serveHTTP(t, pool)
+
+	done := make(chan string)
+	go func() {
+		_, out, _ := ctl(t, "", "-addr", addr, "watch", "-n", "1", "prices")
+		done <- out
+	}()
+	// Put until the watch, registered asynchronously, sees a value.
+	prices := pool.Bucket("prices")
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		prices.Put(1.5)
+		select {
+		case out := <-done:
+			fields := strings.Fields(out)
+			require.Len(t, fields, 3)
+			ts, err := strconv.ParseInt(fields[0], 10, 64)
+			require.NoError(t, err)
+			assert.NotZero(t, ts)
+			assert.Equal(t, `"prices"`, fields[1])
+			assert.Equal(t, "1.5", fields[2])
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	wal, err := datapool.OpenWAL(filepath.Join(t.TempDir(), "pool.wal"))
+	require.NoError(t, err)
+	defer wal.Close()
+	pool := datapool.NewDataPool(datapool.WithWAL(wal))
+	pool.Handle("config").Put(1)
+
+	code, out, errOut := ctl(t, "", "-addr", serveHTTP(t, pool), "snapshot")
+	require.Equal(t, 0, code, errOut)
+	assert.Equal(t, "snapshot taken: 1 buckets\n", out)
+}
+
+func TestUsage(t *testing.T) {
+	code, _, errOut := ctl(t, "")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, errOut, "usage: datapoolctl")
+
+	code, _, errOut = ctl(t, "", "-addr", "localhost:1", "frobnicate")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, errOut, "commands:")
+
+	code, _, _ = ctl(t, "", "-addr", "localhost:1", "get")
+	assert.Equal(t, 2, code, "get needs a name")
+
+	code, _, errOut = ctl(t, "", "-addr", "localhost:1", "put", "a", "{not json")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "value is not JSON")
+}
@@ -0,0 +1,122 @@
+package datapool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Codec encodes values stored in encoded form with PutEncoded. The
+// datapoolmsgpack package provides a MessagePack codec.
+type Codec interface {
+	// Name identifies the codec, for example in encoded values written out
+	// as JSON.
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	// JSONCodec encodes values with encoding/json. It is the default codec.
+	JSONCodec Codec = jsonCodec{}
+	// GobCodec encodes values with encoding/gob.
+	GobCodec Codec = gobCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                       { return "json" }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// Encoded is a value stored in encoded form. Get returns it as is; it is only
+// decoded when asked to, by Decode or Bucket.GetDecoded, and every decode
+// yields a fresh copy, so the pool keeps no pointers into the caller's data.
+// Data must not be modified.
+type Encoded struct {
+	Codec Codec
+	Data  []byte
+}
+
+// Decode decodes the value into target, which must be a non-nil pointer.
+func (e Encoded) Decode(target any) error {
+	if err := e.Codec.Unmarshal(e.Data, target); err != nil {
+		return fmt.Errorf("datapool: decode %s value: %w", e.Codec.Name(), err)
+	}
+	return nil
+}
+
+// MarshalJSON writes JSON-encoded values as they are, and other values as an
+// object holding the codec name and the encoded bytes.
+func (e Encoded) MarshalJSON() ([]byte, error) {
+	if e.Codec.Name() == JSONCodec.Name() {
+		return e.Data, nil
+	}
+	return json.Marshal(struct {
+		Codec string `json:"codec"`
+		Data  []byte `json:"data"`
+	}{e.Codec.Name(), e.Data})
+}
+
+// PutEncoded encodes value with the pool's codec (see WithCodec) and stores
+// the result, an Encoded, returning its timestamp. Nothing is stored if value
+// cannot be encoded, or if the handle does not refer to a bucket, which
+// returns an error wrapping ErrBucketNotFound.
+func (b *Bucket) PutEncoded(value any) (int64, error) {
+	bk, err := b.lookup("put encoded")
+	if err != nil {
+		return 0, err
+	}
+
+	codec := b.pool.opts.codec
+	data, err := codec.Marshal(value)
+	if err != nil {
+		return 0, fmt.Errorf("datapool: encode %s value of %q: %w", codec.Name(), bk.name(), err)
+	}
+	return b.pool.put(bk, Encoded{Codec: codec, Data: data}), nil
+}
+
+// GetDecoded decodes the bucket's value into target, which must be a non-nil
+// pointer, and returns its timestamp. An empty bucket leaves target alone and
+// returns a zero timestamp. Values not stored in encoded form are assigned to
+// target if their type allows it, and return an error wrapping
+// ErrTypeMismatch otherwise. Like GetE, it returns an error wrapping
+// ErrBucketNotFound if the handle does not refer to a bucket.
+func (b *Bucket) GetDecoded(target any) (int64, error) {
+	value, ts, _, err := b.GetE(0)
+	if err != nil || ts == 0 || value == nil {
+		return ts, err
+	}
+	if e, ok := value.(Encoded); ok {
+		return ts, e.Decode(target)
+	}
+
+	dst := reflect.ValueOf(target)
+	if dst.Kind() != reflect.Pointer || dst.IsNil() {
+		return ts, fmt.Errorf("datapool: decode into non-pointer %T", target)
+	}
+	src := reflect.ValueOf(value)
+	if !src.Type().AssignableTo(dst.Elem().Type()) {
+		return ts, fmt.Errorf("%w: cannot decode %T into %T", ErrTypeMismatch, value, target)
+	}
+	dst.Elem().Set(src)
+	return ts, nil
+}
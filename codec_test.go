@@ -0,0 +1,94 @@
+package datapool
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type codecUser struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+func TestPutEncoded(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec, GobCodec} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			pool := NewDataPool(WithCodec(codec))
+			b := pool.Bucket("users/42")
+
+			in := &codecUser{Name: "Alice", Roles: []string{"admin"}}
+			ts, err := b.PutEncoded(in)
+			require.NoError(t, err)
+			in.Roles[0] = "changed"
+
+			val, _, _ := b.Get(0)
+			require.IsType(t, Encoded{}, val, "Get returns the encoded form")
+			assert.Equal(t, codec, val.(Encoded).Codec)
+
+			var out codecUser
+			got, err := b.GetDecoded(&out)
+			require.NoError(t, err)
+			assert.Equal(t, ts, got)
+			assert.Equal(t, codecUser{Name: "Alice", Roles: []string{"admin"}}, out,
+				"Encoded values do not share the caller's data")
+
+			// Every decode is a fresh copy.
+			out.Roles[0] = "mutated"
+			var again codecUser
+			_, err = b.GetDecoded(&again)
+			require.NoError(t, err)
+			assert.Equal(t, "admin", again.Roles[0])
+		})
+	}
+}
+
+func TestPutEncodedError(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("bad")
+
+	_, err := b.PutEncoded(func() {})
+	assert.ErrorContains(t, err, `datapool: encode json value of "bad"`)
+	_, ts, _ := b.Get(0)
+	assert.Zero(t, ts, "Nothing is stored")
+}
+
+func TestGetDecoded(t *testing.T) {
+	pool := NewDataPool()
+
+	empty := pool.Bucket("empty")
+	out := "untouched"
+	ts, err := empty.GetDecoded(&out)
+	require.NoError(t, err)
+	assert.Zero(t, ts)
+	assert.Equal(t, "untouched", out)
+
+	plain := pool.Bucket("plain")
+	plain.Put("hello")
+	_, err = plain.GetDecoded(&out)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", out, "Plain values are assigned")
+
+	var n int
+	_, err = plain.GetDecoded(&n)
+	assert.ErrorContains(t, err, "cannot decode string into *int")
+	_, err = plain.GetDecoded(n)
+	assert.ErrorContains(t, err, "non-pointer")
+
+	broken := pool.Bucket("broken")
+	broken.Put(Encoded{Codec: JSONCodec, Data: []byte("{")})
+	_, err = broken.GetDecoded(&out)
+	assert.ErrorContains(t, err, "datapool: decode json value")
+}
+
+func TestEncodedJSON(t *testing.T) {
+	data, err := json.Marshal(Encoded{Codec: JSONCodec, Data: []byte(`{"name":"Alice"}`)})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"Alice"}`, string(data), "JSON values are written as is")
+
+	data, err = json.Marshal(Encoded{Codec: GobCodec, Data: []byte{1, 2, 3}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"codec":"gob","data":"AQID"}`, string(data))
+}
@@ -0,0 +1,157 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Consistency chooses, for a single read or write of a pool with a backend,
+// between the latency of the local copy and the freshness of the backend.
+// Pools without a backend are their own source of truth, so all levels behave
+// the same there.
+type Consistency int
+
+const (
+	// ConsistencyDefault is the consistency of Get and Put: reads are served
+	// locally and read through to the backend on a miss, and writes are
+	// written through to the backend before Put returns, with errors passed
+	// to the error handler.
+	ConsistencyDefault Consistency = iota
+	// ConsistencyLocal serves reads from the local copy only, even on a
+	// miss, and writes through to the backend in the background.
+	ConsistencyLocal
+	// ConsistencyLeader reads the backend before answering, and waits for
+	// writes to be stored in the backend, returning its errors.
+	ConsistencyLeader
+	// ConsistencyQuorum is ConsistencyLeader where a majority of the
+	// backend's replicas must answer, for backends implementing
+	// ConsistentBackend such as QuorumBackend. Other backends treat it as
+	// ConsistencyLeader.
+	ConsistencyQuorum
+)
+
+var consistencyNames = [...]string{
+	ConsistencyDefault: "default",
+	ConsistencyLocal:   "local",
+	ConsistencyLeader:  "leader",
+	ConsistencyQuorum:  "quorum",
+}
+
+func (c Consistency) String() string {
+	if c < 0 || int(c) >= len(consistencyNames) {
+		return fmt.Sprintf("Consistency(%d)", int(c))
+	}
+	return consistencyNames[c]
+}
+
+// ConsistentBackend is implemented by backends whose reads and writes can be
+// made at ConsistencyLeader or ConsistencyQuorum. The pool calls Get and Put
+// of other backends at every level.
+type ConsistentBackend interface {
+	Backend
+
+	// GetConsistent is Get answered at level, ConsistencyLeader or
+	// ConsistencyQuorum.
+	GetConsistent(ctx context.Context, name string, level Consistency) (Update, error)
+
+	// PutConsistent is Put acknowledged at level, ConsistencyLeader or
+	// ConsistencyQuorum.
+	PutConsistent(ctx context.Context, u Update, level Consistency) error
+}
+
+// backendGet reads name from the pool's backend at level.
+func (p *DataPool) backendGet(ctx context.Context, name string, level Consistency) (u Update, err error) {
+	ctx, s := p.startSpan(ctx, SpanBackendGet, name)
+	s.consistency(level)
+	defer func() {
+		s.hit(u.Timestamp != 0, u.Value)
+		s.end(err)
+	}()
+	if cb, ok := p.opts.backend.(ConsistentBackend); ok && (level == ConsistencyLeader || level == ConsistencyQuorum) {
+		return cb.GetConsistent(ctx, name, level)
+	}
+	return p.opts.backend.Get(ctx, name)
+}
+
+// backendPut stores u in the pool's backend at level.
+func (p *DataPool) backendPut(ctx context.Context, u Update, level Consistency) (err error) {
+	ctx, s := p.startSpan(ctx, SpanBackendPut, u.Bucket)
+	s.consistency(level)
+	s.size(u.Value)
+	defer func() { s.end(err) }()
+	if cb, ok := p.opts.backend.(ConsistentBackend); ok && (level == ConsistencyLeader || level == ConsistencyQuorum) {
+		return cb.PutConsistent(ctx, u, level)
+	}
+	return p.opts.backend.Put(ctx, u)
+}
+
+// GetConsistent is Get at level. At ConsistencyLeader and ConsistencyQuorum
+// the backend is read first and a newer value it holds is stored locally; if
+// that fails, the local value is returned along with the error, which is
+// ErrOffline while an offline-mode backend is down.
+func (b *Bucket) GetConsistent(ctx context.Context, timestamp int64, level Consistency) (any, int64, bool, error) {
+	bk := b.resolve("get consistent")
+	if bk == nil {
+		return nil, timestamp, false, nil
+	}
+	if err := checkConsistency(level); err != nil {
+		return nil, timestamp, false, err
+	}
+
+	var err error
+	if level == ConsistencyLeader || level == ConsistencyQuorum {
+		err = b.pool.readLeader(ctx, bk, level)
+		level = ConsistencyLocal
+	}
+	value, ts, fresh := b.pool.get(bk, timestamp, level)
+	return value, ts, fresh, err
+}
+
+// PutConsistent is Put at level, returning the value's timestamp. At
+// ConsistencyLeader and ConsistencyQuorum it returns once the backend has
+// stored the value, or with the backend's error; the value is stored locally
+// either way, and in offline mode it is queued like other writes. Values
+// rejected by the bucket's validator are not stored, and their error is
+// returned at every level.
+func (b *Bucket) PutConsistent(ctx context.Context, value any, level Consistency) (int64, error) {
+	bk := b.resolve("put consistent")
+	if bk == nil {
+		return 0, nil
+	}
+	if err := checkConsistency(level); err != nil {
+		return 0, err
+	}
+	u, err := b.pool.writeAt(ctx, bk, Update{Value: value}, 0, level)
+	if err != nil && !errors.Is(err, ErrInvalidValue) {
+		err = fmt.Errorf("datapool: put %q at %v consistency: %w", bk.name(), level, err)
+	}
+	return u.Timestamp, err
+}
+
+func checkConsistency(level Consistency) error {
+	if level < ConsistencyDefault || level > ConsistencyQuorum {
+		return fmt.Errorf("datapool: unknown consistency %v", level)
+	}
+	return nil
+}
+
+// readLeader stores the backend's value of the bucket if it is newer than the
+// local one.
+func (p *DataPool) readLeader(ctx context.Context, b *bucket, level Consistency) error {
+	if p.opts.backend == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.opts.backendTimeout)
+	defer cancel()
+
+	u, err := p.fetch(ctx, b.name(), level)
+	if err != nil {
+		return fmt.Errorf("datapool: get %q at %v consistency: %w", b.name(), level, err)
+	}
+	if u.Timestamp != 0 {
+		p.apply(b, u)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetConsistent(t *testing.T) {
+	ctx := context.Background()
+	backend := &MemoryBackend{}
+	a := NewDataPool(WithBackend(backend))
+	b := NewDataPool(WithBackend(backend))
+
+	local := a.Bucket("config")
+	local.Put("v1")
+	remote := b.Bucket("config")
+	ts := remote.Put("v2")
+
+	val, _, _ := local.Get(0)
+	assert.Equal(t, "v1", val, "Default reads are served locally")
+
+	val, _, _, err := local.GetConsistent(ctx, 0, ConsistencyLocal)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", val)
+
+	val, got, fresh, err := local.GetConsistent(ctx, 0, ConsistencyLeader)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", val, "Leader reads see the backend's newer value")
+	assert.Equal(t, ts, got)
+	assert.True(t, fresh)
+
+	val, _, _ = local.Get(0)
+	assert.Equal(t, "v2", val, "The backend's value is stored locally")
+}
+
+func TestGetConsistentLocalMiss(t *testing.T) {
+	ctx := context.Background()
+	backend := &MemoryBackend{}
+	require.NoError(t, backend.Put(ctx, Update{Bucket: "config", Value: "v", Timestamp: 1}))
+	pool := NewDataPool(WithBackend(backend))
+	b := pool.Bucket("config")
+
+	val, ts, _, err := b.GetConsistent(ctx, 0, ConsistencyLocal)
+	require.NoError(t, err)
+	assert.Nil(t, val, "Local reads do not read through")
+	assert.Zero(t, ts)
+
+	val, _, _, err = b.GetConsistent(ctx, 0, ConsistencyDefault)
+	require.NoError(t, err)
+	assert.Equal(t, "v", val)
+}
+
+func TestGetConsistentBackendError(t *testing.T) {
+	backend := &flakyBackend{}
+	var reported int
+	pool := NewDataPool(WithBackend(backend), WithErrorHandler(func(string, error) { reported++ }))
+	b := pool.Bucket("config")
+	b.Put("v")
+
+	backend.down.Store(true)
+	val, _, _, err := b.GetConsistent(context.Background(), 0, ConsistencyQuorum)
+	assert.ErrorIs(t, err, errUnreachable)
+	assert.EqualError(t, err, `datapool: get "config" at quorum consistency: backend unreachable`)
+	assert.Equal(t, "v", val, "The local value is returned on errors")
+	assert.Zero(t, reported, "Returned errors are not reported")
+}
+
+func TestPutConsistent(t *testing.T) {
+	ctx := context.Background()
+	backend := &flakyBackend{}
+	var reported int
+	pool := NewDataPool(WithBackend(backend), WithErrorHandler(func(string, error) { reported++ }))
+	b := pool.Bucket("config")
+
+	ts, err := b.PutConsistent(ctx, "v1", ConsistencyLeader)
+	require.NoError(t, err)
+	u, _ := backend.MemoryBackend.Get(ctx, "config")
+	assert.Equal(t, ts, u.Timestamp, "Leader writes are stored before returning")
+
+	backend.down.Store(true)
+	ts, err = b.PutConsistent(ctx, "v2", ConsistencyLeader)
+	assert.ErrorIs(t, err, errUnreachable)
+	assert.EqualError(t, err, `datapool: put "config" at leader consistency: backend unreachable`)
+	assert.NotZero(t, ts)
+	val, _, _ := b.Get(0)
+	assert.Equal(t, "v2", val, "The value is stored locally despite the error")
+	assert.Zero(t, reported)
+
+	b.Put("v3")
+	assert.Equal(t, 1, reported, "Default writes still report errors")
+
+	backend.down.Store(false)
+	ts, err = b.PutConsistent(ctx, "v4", ConsistencyLocal)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		u, _ := backend.MemoryBackend.Get(ctx, "config")
+		return u.Timestamp == ts
+	}, time.Second, time.Millisecond, "Local writes reach the backend in the background")
+}
+
+func TestConsistencyOffline(t *testing.T) {
+	ctx := context.Background()
+	clock := NewManualClock(time.Unix(1000, 0))
+	backend := &flakyBackend{}
+	backend.down.Store(true)
+	pool := NewDataPool(WithClock(clock), WithBackend(backend), WithOfflineQueue(10))
+	b := pool.Bucket("config")
+
+	_, err := b.PutConsistent(ctx, "v1", ConsistencyLeader)
+	assert.ErrorIs(t, err, errUnreachable)
+	_, err = b.PutConsistent(ctx, "v2", ConsistencyLeader)
+	assert.ErrorIs(t, err, ErrOffline, "Writes within the retry interval fail at once")
+	_, _, _, err = b.GetConsistent(ctx, 0, ConsistencyLeader)
+	assert.ErrorIs(t, err, ErrOffline)
+	assert.Equal(t, 1, pool.BackendStatus().Queued, "Failed writes are queued")
+
+	backend.down.Store(false)
+	clock.Advance(time.Second)
+	val, _, fresh, err := b.GetConsistent(ctx, 0, ConsistencyLeader)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", val)
+	assert.True(t, fresh)
+	assert.Equal(t, BackendStatus{Replayed: 1}, pool.BackendStatus())
+}
+
+func TestConsistencyWithoutBackend(t *testing.T) {
+	ctx := context.Background()
+	pool := NewDataPool()
+	b := pool.Bucket("config")
+
+	for _, level := range []Consistency{ConsistencyDefault, ConsistencyLocal, ConsistencyLeader, ConsistencyQuorum} {
+		ts, err := b.PutConsistent(ctx, level.String(), level)
+		require.NoError(t, err, level)
+		val, got, _, err := b.GetConsistent(ctx, 0, level)
+		require.NoError(t, err, level)
+		assert.Equal(t, level.String(), val)
+		assert.Equal(t, ts, got)
+	}
+}
+
+func TestUnknownConsistency(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("config")
+
+	_, err := b.PutConsistent(context.Background(), 1, Consistency(7))
+	assert.EqualError(t, err, "datapool: unknown consistency Consistency(7)")
+	_, _, _, err = b.GetConsistent(context.Background(), 0, -1)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrOffline))
+	assert.Equal(t, "leader", ConsistencyLeader.String())
+}
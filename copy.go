@@ -0,0 +1,135 @@
+package datapool
+
+import "reflect"
+
+// Cloner is implemented by values that copy themselves, which buckets that
+// copy values (see SetCopyValues) use instead of copying by reflection.
+type Cloner interface {
+	Clone() any
+}
+
+// SetCopyValues makes the bucket copy values: Put stores a deep copy of the
+// value, and every read, by Get, GetMany, groups, transactions, replica views,
+// watchers, Inspect, or the fn of Update and the value Swap returns,
+// receives a deep copy of the stored one, so callers mutating
+// maps, slices or structs they stored or read cannot affect other readers.
+// Values implementing Cloner are copied with Clone; others
+// are copied by reflection, which shares unexported fields, channels and
+// functions. Copying is off by default, and only applies to values stored
+// after it is turned on.
+func (b *Bucket) SetCopyValues(on bool) {
+	bk := b.resolve("set copy values")
+	if bk == nil {
+		return
+	}
+	bk.copyValues.Store(on)
+}
+
+// CopyValues reports whether the bucket copies values (see SetCopyValues).
+func (b *Bucket) CopyValues() bool {
+	bk := b.resolve("copy values")
+	if bk == nil {
+		return false
+	}
+	return bk.copyValues.Load()
+}
+
+var clonerType = reflect.TypeFor[Cloner]()
+
+// copyValue returns a deep copy of v.
+func copyValue(v any) any {
+	if v == nil {
+		return nil
+	}
+	if c, ok := v.(Cloner); ok {
+		return c.Clone()
+	}
+	c := copier{seen: make(map[copyKey]reflect.Value)}
+	return c.copy(reflect.ValueOf(v)).Interface()
+}
+
+// copier deep-copies values by reflection. Pointers and maps reached more
+// than once are copied once, which preserves aliasing and ends cycles.
+type copier struct {
+	seen map[copyKey]reflect.Value
+}
+
+type copyKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+func (c *copier) copy(v reflect.Value) reflect.Value {
+	t := v.Type()
+	if t.Kind() != reflect.Interface && t.Implements(clonerType) && !(t.Kind() == reflect.Pointer && v.IsNil()) {
+		if r := reflect.ValueOf(v.Interface().(Cloner).Clone()); r.IsValid() && r.Type().AssignableTo(t) {
+			return r
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		key := copyKey{v.Pointer(), t}
+		if d, ok := c.seen[key]; ok {
+			return d
+		}
+		d := reflect.New(t.Elem())
+		c.seen[key] = d
+		d.Elem().Set(c.copy(v.Elem()))
+		return d
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		key := copyKey{v.Pointer(), t}
+		if d, ok := c.seen[key]; ok {
+			return d
+		}
+		d := reflect.MakeMapWithSize(t, v.Len())
+		c.seen[key] = d
+		for it := v.MapRange(); it.Next(); {
+			d.SetMapIndex(c.copy(it.Key()), c.copy(it.Value()))
+		}
+		return d
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		d := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			d.Index(i).Set(c.copy(v.Index(i)))
+		}
+		return d
+
+	case reflect.Array:
+		d := reflect.New(t).Elem()
+		for i := 0; i < v.Len(); i++ {
+			d.Index(i).Set(c.copy(v.Index(i)))
+		}
+		return d
+
+	case reflect.Struct:
+		d := reflect.New(t).Elem()
+		d.Set(v)
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				d.Field(i).Set(c.copy(v.Field(i)))
+			}
+		}
+		return d
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		d := reflect.New(t).Elem()
+		d.Set(c.copy(v.Elem()))
+		return d
+	}
+	return v
+}
@@ -0,0 +1,108 @@
+package datapool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type copyNode struct {
+	Name     string
+	Tags     []string
+	Attrs    map[string]any
+	Next     *copyNode
+	internal *int
+}
+
+func TestCopyValues(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("config")
+	assert.False(t, b.CopyValues())
+	b.SetCopyValues(true)
+	assert.True(t, b.CopyValues())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := b.Watch(ctx)
+
+	stored := map[string][]int{"a": {1, 2}}
+	b.Put(stored)
+	stored["a"][0] = 99
+	stored["b"] = nil
+
+	val, _, _ := b.Get(0)
+	assert.Equal(t, map[string][]int{"a": {1, 2}}, val, "Put stores a copy")
+
+	val.(map[string][]int)["a"][1] = 99
+	again, _, _ := b.Get(0)
+	assert.Equal(t, map[string][]int{"a": {1, 2}}, again, "Get returns a copy")
+
+	u := receive(t, updates)
+	u.Value.(map[string][]int)["a"][1] = 99
+	again, _, _ = b.Get(0)
+	assert.Equal(t, map[string][]int{"a": {1, 2}}, again, "Watchers receive a copy")
+}
+
+func TestCopyValuesReadPaths(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("config")
+	b.SetCopyValues(true)
+	b.Put(map[string][]int{"a": {1, 2}})
+	want := map[string][]int{"a": {1, 2}}
+	mutate := func(v any) { v.(map[string][]int)["a"][0] = 99 }
+
+	mutate(pool.GetMany([]string{"config"}, 0)["config"].Value)
+	value, _, _ := b.Get(0)
+	assert.Equal(t, want, value, "GetMany returns a copy")
+
+	mutate(pool.Group("config").Get(0)["config"].Value)
+	value, _, _ = b.Get(0)
+	assert.Equal(t, want, value, "Groups return a copy")
+
+	require.NoError(t, pool.Update(func(tx *Tx) error {
+		v, _ := tx.Get("config")
+		mutate(v)
+		return nil
+	}))
+	value, _, _ = b.Get(0)
+	assert.Equal(t, want, value, "Transactions read a copy")
+
+	view := pool.ReplicaView(time.Minute)
+	view.Refresh()
+	v, _, _ := view.Get("config", 0)
+	mutate(v)
+	value, _, _ = b.Get(0)
+	assert.Equal(t, want, value, "Replica views return a copy")
+	v, _, _ = view.Get("config", 0)
+	assert.Equal(t, want, v)
+
+	for _, info := range pool.Inspect() {
+		mutate(info.Value)
+	}
+	value, _, _ = b.Get(0)
+	assert.Equal(t, want, value, "Inspect returns a copy")
+}
+
+func TestCopyValuesModify(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("config")
+	b.SetCopyValues(true)
+	b.SetHistory(4)
+	first := b.Put(map[string][]int{"a": {1, 2}})
+	want := map[string][]int{"a": {1, 2}}
+
+	second := b.Update(func(old any) any {
+		old.(map[string][]int)["a"][0] = 99
+		return map[string][]int{"b": {3}}
+	})
+	value, _, _ := b.GetAt(first)
+	assert.Equal(t, want, value, "Mutations inside fn do not change the stored value")
+
+	old, _ := b.Swap(map[string][]int{"c": {5}})
+	old.(map[string][]int)["b"][0] = 42
+	value, _, _ = b.GetAt(second)
+	assert.Equal(t, map[string][]int{"b": {3}}, value, "Swap returns a copy")
+}
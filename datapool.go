@@ -4,102 +4,653 @@
 package datapool
 
 import (
-	"fmt"
+	"context"
+	"math"
+	"slices"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // DataPool is a concurrent-safe key-value store with timestamp tracking
 // that allows checking for data freshness based on timestamps.
 type DataPool struct {
-	buckets []*bucket
+	shards      []*shard
+	shardMask   uint64
+	nextID      atomic.Int64
+	id          string
+	opts        options
+	corruptions atomic.Uint64
+	refresh     *refreshQueue
+	loadLimits  map[string]*loadLimit
+	lastStamp   atomic.Int64
+	count       atomic.Int64
+	trackAccess bool
+
+	watchOverflows   atomic.Uint64
+	schemaMismatches atomic.Uint64
+
+	pressureChecked atomic.Int64
+	pinned          atomic.Int64
+
+	// commits counts the writes of several buckets at once, PutMany and
+	// transactions, started and done, for readers of many buckets that want
+	// to see none or all of each (see beginCommit).
+	commitsStarted atomic.Uint64
+	commitsDone    atomic.Uint64
+
+	deriveMu sync.Mutex
+	derived  atomic.Pointer[derivedGraph]
+
+	loads     loads
+	freeBytes sync.Pool
+	mem       *memoryUsage
+	wal       *WAL
+	offline   offlineState
+	expiries  expiryQueue
+	hooks     hooks
+	hotGets   *hotGetDetector
+	drift     *driftDetector
+
+	nameWatchers hookList[*nameWatcher]
+
+	typesMu sync.Mutex
+	types   atomic.Pointer[typeRegistry]
+
+	// refs holds the buckets whose references were taken, by id (see
+	// Bucket.Ref).
+	refs sync.Map
+
+	tenantsMu sync.Mutex
+	tenants   map[string]*tenant
+
+	// closeMu orders Close after the goroutines started by goBackground
+	// are added to background.
+	closeMu    sync.RWMutex
+	closed     atomic.Bool
+	done       chan struct{}
+	background sync.WaitGroup
+
+	// renameMu serializes renames, aliases and removals, and guards the
+	// aliases of buckets.
+	renameMu sync.Mutex
+
+	initOnce sync.Once
+	ready    atomic.Bool
+}
+
+// shard indexes a subset of the buckets by name. Bucket handles point at their
+// bucket directly, so shard locks are only taken to resolve or enumerate names.
+type shard struct {
+	mu      sync.RWMutex
+	buckets map[string]*bucket
 }
 
 // Bucket represents a named entry in the DataPool with methods to get and update values.
+// A handle refers to one bucket for as long as the bucket exists, whatever
+// it is renamed to; once the bucket is removed, the handle is invalid (see
+// Valid) and reads through it return nothing.
 type Bucket struct {
 	pool *DataPool
-	id   int
+	b    *bucket
 }
 
 type bucket struct {
-	name      string
-	value     any
-	timestamp int64
-	guard     sync.RWMutex
+	id         int
+	label      atomic.Pointer[string]
+	value      any
+	shared     *sharedBytes
+	size       int64
+	reserved   int64
+	mem        *memoryUsage
+	expiries   *expiryQueue
+	queued     *expiryItem
+	timestamp  int64
+	cachedErr  error
+	tenant     *tenant
+	version    uint64
+	fence      uint64
+	claim      *claim
+	claims     uint64
+	expiresAt  int64
+	ttl        time.Duration
+	softTTL    time.Duration
+	hardTTL    time.Duration
+	removed    bool
+	system     bool
+	priority   Priority
+	lastAccess atomic.Int64
+	hits       atomic.Uint64
+	copyValues atomic.Bool
+	pinned     atomic.Bool
+	stats      bucketStats
+	watchers   []*watcher
+	provenance []Source
+	schema     int
+	aliases    []string
+	history    []historyEntry
+	historyLen int
+	frozen     bool
+	loader     Loader
+	writer     Writer
+	validate   Validator
+	transform  Transformer
+	types      *atomic.Pointer[typeRegistry]
+
+	expected      time.Duration
+	expectedSince int64
+
+	revalidating atomic.Bool
+	throttle     atomic.Pointer[throttle]
+	slo          atomic.Pointer[sloTracker]
+	typeBinding  atomic.Pointer[typeBinding]
+	latest       atomic.Pointer[entry]
+	decoded      atomic.Pointer[decodedValue]
+
+	guard sync.RWMutex
 }
 
-// NewDataPool creates a new empty DataPool instance.
-func NewDataPool() *DataPool {
-	return &DataPool{
-		buckets: make([]*bucket, 0),
+// name returns the bucket's name, which Rename may change.
+func (b *bucket) name() string {
+	return *b.label.Load()
+}
+
+// NewDataPool creates a new empty DataPool instance configured by opts.
+func NewDataPool(opts ...Option) *DataPool {
+	p := &DataPool{}
+	p.Init(opts...)
+	return p
+}
+
+// Init configures a DataPool declared as a value, such as a field of a
+// larger struct, with opts, as NewDataPool does. Only the first call has an
+// effect, so every code path that may use the pool first can call it; pools
+// made by NewDataPool are initialized already. A pool used without calling
+// Init is initialized with the default options on first use.
+func (p *DataPool) Init(opts ...Option) {
+	p.initOnce.Do(func() {
+		o := defaultOptions()
+		for _, opt := range opts {
+			opt(&o)
+		}
+		p.setup(o)
+	})
+}
+
+// lazyInit initializes a pool that was not, with the default options.
+func (p *DataPool) lazyInit() {
+	if !p.ready.Load() {
+		p.Init()
 	}
 }
 
-func (p *DataPool) dump() {
-	fmt.Println("Dump of DataPool:")
-	for i, b := range p.buckets {
-		fmt.Printf("[%d] (%d) %v\n", i, b.timestamp, b.value)
+// setup initializes the pool with o.
+func (p *DataPool) setup(o options) {
+	// Round the shard count up to a power of two so a mask selects the shard.
+	n := 1
+	for n < o.shards {
+		n <<= 1
+	}
+
+	p.shards = make([]*shard, n)
+	p.shardMask = uint64(n - 1)
+	p.opts = o
+	p.id = newPoolID(o)
+	p.done = make(chan struct{})
+	p.trackAccess = o.pressure != nil || o.maxBuckets > 0
+	for i := range p.shards {
+		p.shards[i] = &shard{buckets: make(map[string]*bucket)}
+	}
+	if o.memoryBudget > 0 || o.sizer != nil {
+		p.mem = &memoryUsage{size: o.sizer}
+		if p.mem.size == nil {
+			p.mem.size = sizeOf
+		}
+	}
+	p.refresh = newRefreshQueue(p, o.refreshWorkers, o.namespaceWeights)
+	p.loadLimits = newLoadLimits(p, o.loaderLimits)
+	if o.hotGets != nil {
+		p.hotGets = newHotGetDetector(*o.hotGets)
+	}
+	if o.driftThreshold > 0 {
+		p.drift = newDriftDetector(o.driftThreshold)
+	}
+	// Replaying the WAL uses the pool, so it must not initialize it again.
+	p.ready.Store(true)
+	if o.wal != nil {
+		p.attachWAL(o.wal)
 	}
-	fmt.Println("--- end ---")
 }
 
-func (p *DataPool) get(id int, timestamp int64) (any, int64, bool) {
-	if id < 0 || id >= len(p.buckets) {
-		return nil, timestamp, false
+// shardFor returns the shard responsible for name, using FNV-1a.
+func (p *DataPool) shardFor(name string) *shard {
+	p.lazyInit()
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(name); i++ {
+		h ^= uint64(name[i])
+		h *= 1099511628211
 	}
+	return p.shards[h&p.shardMask]
+}
 
-	p.buckets[id].guard.RLock()
-	defer p.buckets[id].guard.RUnlock()
+// all returns every bucket in the pool ordered by creation.
+func (p *DataPool) all() []*bucket {
+	p.lazyInit()
+	var buckets []*bucket
+	for _, sh := range p.shards {
+		sh.mu.RLock()
+		for name, b := range sh.buckets {
+			// Aliases list their bucket under another name.
+			if name == b.name() {
+				buckets = append(buckets, b)
+			}
+		}
+		sh.mu.RUnlock()
+	}
+
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].id < buckets[j].id
+	})
+	// A bucket renamed meanwhile may be listed under both names.
+	return slices.Compact(buckets)
+}
+
+// Len returns the number of buckets in the pool.
+func (p *DataPool) Len() int {
+	return int(p.count.Load())
+}
+
+// get reads the bucket at level, which is ConsistencyDefault or
+// ConsistencyLocal; reads at other levels first read the backend with
+// readLeader, then read locally.
+func (p *DataPool) get(b *bucket, timestamp int64, level Consistency) (any, int64, bool) {
+	if b.system {
+		p.refreshSystem(b)
+	}
+	if p.hotGets != nil {
+		p.hotGets.observe(p, b.name())
+	}
+	if p.trackAccess {
+		b.lastAccess.Store(p.now())
+		b.hits.Add(1)
+	}
+
+	var value any
+	var ts int64
+	var fresh, removed bool
+	var load Loader
+	var soft time.Duration
+	if e := p.loadEntry(b); e != nil {
+		value, ts, fresh = e.value, e.timestamp, e.timestamp > timestamp && !e.frozen
+		if e.removed {
+			value, ts, fresh = nil, timestamp, false
+		}
+		removed, load, soft = e.removed, e.loader, e.softTTL
+	} else {
+		b.guard.RLock()
+		value, ts, fresh = b.read(p, timestamp)
+		removed = b.removed
+		load = b.loader
+		soft = b.softTTL
+		b.guard.RUnlock()
+	}
+
+	if ts == 0 && !removed && p.opts.snapshot != nil && !b.system {
+		value, ts = p.restoreSnapshot(b)
+		fresh = ts > timestamp
+	}
+	if ts == 0 && !removed && p.opts.backend != nil && level == ConsistencyDefault {
+		value, ts = p.readThrough(b)
+		fresh = ts > timestamp
+	}
+	if fresh && p.isOffline() {
+		fresh = false
+	}
+	if load == nil {
+		load = p.opts.loader
+	}
+	if ts == 0 && !removed && load != nil {
+		value, ts = p.loadThrough(b, load)
+		fresh = ts > timestamp
+	} else if soft > 0 && load != nil && ts != 0 && !removed && p.age(ts) >= soft {
+		p.revalidate(b, load)
+	}
+
+	if !removed {
+		b.stats.recordRead(ts, fresh)
+		p.recordSLO(b, ts)
+	}
+	if m := p.opts.metrics; m != nil {
+		m.RecordGet(b.name(), ts != 0, fresh)
+	}
+	if b.copyValues.Load() {
+		value = copyValue(value)
+	}
+	return value, ts, fresh
+}
 
-	return p.buckets[id].value, p.buckets[id].timestamp, p.buckets[id].timestamp > timestamp
+// read returns the bucket's value as seen by Get. It must be called with
+// b.guard held.
+func (b *bucket) read(p *DataPool, timestamp int64) (any, int64, bool) {
+	if b.removed {
+		return nil, timestamp, false
+	}
+	if b.expiredAt(p) {
+		return nil, 0, false
+	}
+	return b.current(), b.timestamp, b.timestamp > timestamp && !b.frozen
 }
 
-func (p *DataPool) put(id int, value any) int64 {
-	if id < 0 || id >= len(p.buckets) {
+// readTimestamp returns the timestamp read would, without the value. It must
+// be called with b.guard held.
+func (b *bucket) readTimestamp(p *DataPool) int64 {
+	if b.removed || b.expiredAt(p) {
 		return 0
 	}
+	return b.timestamp
+}
+
+func (p *DataPool) put(b *bucket, value any) int64 {
+	return p.write(b, value, 0, nil)
+}
+
+// write is put with an explicit expiration time in pool-clock nanoseconds,
+// where zero applies the bucket's TTL, and the value's provenance, where nil
+// stands for a plain Put.
+func (p *DataPool) write(b *bucket, value any, expiresAt int64, chain []Source) int64 {
+	u, err := p.writeAt(context.Background(), b, Update{Value: value, Provenance: chain}, expiresAt, ConsistencyDefault)
+	if err != nil {
+		p.reportError(b.name(), err)
+	}
+	return u.Timestamp
+}
 
-	p.buckets[id].guard.Lock()
-	defer p.buckets[id].guard.Unlock()
+// writeAt is write of u's value, provenance, schema version and fencing
+// token at a consistency level, through the claim u.claim if not zero. It
+// returns u with its bucket, stored value, timestamp and version filled in,
+// or a zero Update if nothing was stored, along with the error of a value
+// rejected by the bucket's validator, of a stale fencing token, of a claimed
+// bucket or of a removed one. Only writes at ConsistencyLeader and ConsistencyQuorum
+// return backend errors; the value is stored locally either way.
+func (p *DataPool) writeAt(ctx context.Context, b *bucket, u Update, expiresAt int64, level Consistency) (Update, error) {
+	if p.rejectSystem(b) {
+		return Update{}, nil
+	}
+
+	b.guard.Lock()
+	if b.removed {
+		b.guard.Unlock()
+		return Update{}, b.removedError()
+	}
+	if err := b.checkFence(u.Fence); err != nil {
+		b.guard.Unlock()
+		return Update{}, err
+	}
+	if err := b.checkClaim(p.now(), u.claim); err != nil {
+		b.guard.Unlock()
+		return Update{}, err
+	}
+	u.claim = 0
+	value, err := b.prepare(u.Value)
+	if err != nil {
+		b.guard.Unlock()
+		return Update{}, err
+	}
+	b.fence = max(b.fence, u.Fence)
+	u.Value = value
+	u.Bucket = b.name()
+	u.Timestamp = p.stamp()
+	u.Version = b.store(u.Value, u.Timestamp)
+	if expiresAt != 0 {
+		b.expiresAt = expiresAt
+		b.reschedule()
+	}
+	b.provenance = u.Provenance
+	b.schema = u.Schema
+	b.publish(p)
+	watchers := b.watchers
+	b.guard.Unlock()
 
-	p.buckets[id].value = value
-	p.buckets[id].timestamp = time.Now().UnixNano()
+	err = p.notifyPut(ctx, b, watchers, u, level)
+	p.checkMemoryPressure(u.Timestamp)
 
-	return p.buckets[id].timestamp
+	return u, err
+}
+
+// store sets the bucket's value and timestamp, recording it as a plain Put,
+// and returns the value's version. It must be called with b.guard held for
+// writing.
+func (b *bucket) store(value any, ts int64) uint64 {
+	if b.copyValues.Load() {
+		value = copyValue(value)
+	}
+	b.record()
+	b.releaseShared()
+	b.account(value)
+	b.forgetRead()
+	b.value = value
+	b.cachedErr = nil
+	b.timestamp = ts
+	b.provenance = nil
+	b.schema = 0
+	b.expiresAt = 0
+	if b.ttl > 0 {
+		b.expiresAt = ts + int64(b.ttl)
+	}
+	if hard := ts + int64(b.hardTTL); b.hardTTL > 0 && (b.expiresAt == 0 || hard < b.expiresAt) {
+		b.expiresAt = hard
+	}
+	b.reschedule()
+	b.lastAccess.Store(ts)
+	b.hits.Add(1)
+	b.stats.writes.Add(1)
+	b.version++
+	return b.version
+}
+
+// notifyPut reports a completed Put to the write-ahead log, watchers,
+// metrics, callbacks, the backend, the writer and derived buckets. It must be
+// called without holding any pool lock. It returns the backend's error for
+// writes at ConsistencyLeader and ConsistencyQuorum.
+func (p *DataPool) notifyPut(ctx context.Context, b *bucket, watchers []*watcher, u Update, level Consistency) error {
+	if p.wal != nil {
+		p.logPut(b, u)
+	}
+	p.deliverUpdate(b, watchers, u)
+	if m := p.opts.metrics; m != nil {
+		m.RecordPut(b.name())
+	}
+	p.firePut(b.name(), u.Value, u.Timestamp)
+	p.fireAudit(b, u)
+	p.firePublish(b, u)
+	p.logStored(b, u)
+	var err error
+	if p.opts.backend != nil {
+		err = p.writeThrough(ctx, u, level)
+	}
+	p.writeSource(b, u.Value, u.Provenance)
+	p.triggerDerived(b.name())
+	return err
+}
+
+// reportError passes an error that cannot be returned to the error handler
+// set with WithErrorHandler.
+func (p *DataPool) reportError(name string, err error) {
+	if fn := p.opts.onError; fn != nil {
+		fn(name, err)
+	}
+}
+
+// find returns the bucket named name without creating it, or nil.
+func (p *DataPool) find(name string) *bucket {
+	sh := p.shardFor(name)
+
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	return sh.buckets[name]
 }
 
 // Bucket gets a bucket by name or creates a new one if it doesn't exist.
-// It returns a Bucket reference that can be used for future operations.
+// It returns a Bucket reference that can be used for future operations. If
+// the pool's NameRules reject name, it returns the zero Bucket and passes the
+// error to the error handler (see WithNameRules).
 func (p *DataPool) Bucket(name string) Bucket {
-	for i, b := range p.buckets {
-		if b.name == name {
-			return Bucket{
-				pool: p,
-				id:   i,
-			}
-		}
+	b, err := p.bucket(name)
+	if err != nil {
+		p.reportError(name, err)
+		return Bucket{}
+	}
+	return Bucket{pool: p, b: b}
+}
+
+// bucket returns the bucket named name, creating it if the pool's NameRules
+// allow.
+func (p *DataPool) bucket(name string) (*bucket, error) {
+	sh := p.shardFor(name)
+	if p.isClosed() {
+		return nil, ErrPoolClosed
 	}
 
-	b := &bucket{
-		name:      name,
+	sh.mu.RLock()
+	b, ok := sh.buckets[name]
+	sh.mu.RUnlock()
+	if ok {
+		return b, nil
+	}
+	if err := p.ValidateName(name); err != nil {
+		return nil, err
+	}
+	if err := checkReserved(name); err != nil {
+		return nil, err
+	}
+	var t *tenant
+	if id, ok := tenantID(name); ok {
+		t = p.tenant(id)
+	}
+
+	sh.mu.Lock()
+	if b, ok := sh.buckets[name]; ok {
+		sh.mu.Unlock()
+		return b, nil
+	}
+
+	b = &bucket{
+		id:        int(p.nextID.Add(1) - 1),
 		timestamp: 0,
+		system:    isSystem(name),
+	}
+	b.label.Store(&name)
+	if !b.system {
+		b.ttl = p.opts.defaultTTL
+		b.softTTL = p.opts.softTTL
+		b.hardTTL = p.opts.hardTTL
+		b.historyLen = p.opts.history
+		b.mem = p.mem
+		b.expiries = &p.expiries
+		b.types = &p.types
+	}
+	if t != nil {
+		if err := t.admit(b); err != nil {
+			sh.mu.Unlock()
+			return nil, err
+		}
+		b.tenant = t
+	}
+	b.lastAccess.Store(p.now())
+	sh.buckets[name] = b
+	if b.system {
+		sh.mu.Unlock()
+		return b, nil
 	}
-	p.buckets = append(p.buckets, b)
+	count := p.count.Add(1)
+	sh.mu.Unlock()
+	p.logEvent(LogCreate, name)
 
-	return Bucket{
-		pool: p,
-		id:   len(p.buckets) - 1,
+	if limit := p.opts.maxBuckets; limit > 0 && count > int64(limit) {
+		p.evictOverflow(b)
 	}
+
+	return b, nil
+}
+
+// resolve returns the bucket behind the handle, or nil if the handle does not
+// refer to a bucket or its pool is closed, which it reports. The zero Bucket
+// is not attached to any pool and resolves to nil silently.
+func (b *Bucket) resolve(op string) *bucket {
+	if b.pool == nil {
+		return nil
+	}
+	if b.pool.isClosed() {
+		b.pool.reportError(b.Name(), closedError(op))
+		return nil
+	}
+	if b.b == nil {
+		b.pool.violation("%s: bucket handle has no bucket", op)
+	}
+	return b.b
 }
 
 // Get returns the value of the bucket, its timestamp, and whether the value is fresher
 // than the provided comparison timestamp. The boolean return value will be true if
 // the bucket's timestamp is newer than the provided timestamp.
 func (b *Bucket) Get(timestamp int64) (any, int64, bool) {
-	return b.pool.get(b.id, timestamp)
+	bk := b.resolve("get")
+	if bk == nil {
+		return nil, timestamp, false
+	}
+	return b.pool.get(bk, timestamp, ConsistencyDefault)
 }
 
 // Put updates the value of the bucket and returns the new timestamp.
 func (b *Bucket) Put(value any) int64 {
-	return b.pool.put(b.id, value)
+	bk := b.resolve("put")
+	if bk == nil {
+		return 0
+	}
+	if t := bk.throttle.Load(); t != nil {
+		return b.pool.throttledPut(bk, t, value)
+	}
+	return b.pool.put(bk, value)
+}
+
+// GetFresh returns the value of the bucket if it was stored at most maxAge
+// ago. It returns nil and false for older values and empty buckets.
+func (b *Bucket) GetFresh(maxAge time.Duration) (any, bool) {
+	bk := b.resolve("get fresh")
+	if bk == nil {
+		return nil, false
+	}
+
+	value, ts, _ := b.pool.get(bk, 0, ConsistencyDefault)
+	if ts == 0 || b.pool.age(ts) > maxAge {
+		return nil, false
+	}
+	return value, true
+}
+
+// Age returns how long ago the bucket's value was stored. Empty buckets are
+// infinitely old: their age is the largest Duration, so comparisons against
+// any bound treat them as stale.
+func (b *Bucket) Age() time.Duration {
+	bk := b.resolve("age")
+	if bk == nil {
+		return math.MaxInt64
+	}
+
+	bk.guard.RLock()
+	ts := bk.readTimestamp(b.pool)
+	bk.guard.RUnlock()
+
+	if ts == 0 {
+		return math.MaxInt64
+	}
+	return b.pool.age(ts)
+}
+
+// age returns the time elapsed since timestamp ts. Timestamps may run ahead of
+// the clock by a few nanoseconds to stay strictly increasing, so the result is
+// never negative.
+func (p *DataPool) age(ts int64) time.Duration {
+	return time.Duration(max(p.now()-ts, 0))
 }
@@ -2,7 +2,10 @@ package datapool
 
 import (
 	"fmt"
+	"math"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -14,7 +17,30 @@ func TestNewDataPool(t *testing.T) {
 	pool := NewDataPool()
 
 	assert.NotNil(t, pool)
-	assert.Empty(t, pool.buckets, "New pool should have no buckets")
+	assert.Equal(t, 0, pool.Len(), "New pool should have no buckets")
+	assert.Len(t, pool.shards, defaultShards)
+}
+
+func TestInit(t *testing.T) {
+	type app struct {
+		cache DataPool
+	}
+	var a app
+	config := a.cache.Bucket("config")
+	config.Put("v1")
+	value, _, _ := config.Get(0)
+	assert.Equal(t, "v1", value, "A zero pool is initialized on first use")
+	assert.Len(t, a.cache.shards, defaultShards)
+
+	a.cache.Init(WithShards(4))
+	assert.Len(t, a.cache.shards, defaultShards, "Only the first Init has an effect")
+	assert.Equal(t, 1, a.cache.Len())
+
+	var b app
+	b.cache.Init(WithShards(4), WithDefaultTTL(time.Minute))
+	b.cache.Init()
+	assert.Len(t, b.cache.shards, 4)
+	assert.Equal(t, time.Minute, b.cache.opts.defaultTTL)
 }
 
 func TestBucket(t *testing.T) {
@@ -22,14 +48,15 @@ func TestBucket(t *testing.T) {
 	bucket := pool.Bucket("test")
 	bucket2 := pool.Bucket("test")
 
-	assert.Equal(t, bucket.id, bucket2.id, "Same name should return same bucket")
+	assert.Same(t, bucket.b, bucket2.b, "Same name should return same bucket")
 
 	// Different bucket names should create different buckets
 	bucket3 := pool.Bucket("different")
-	assert.NotEqual(t, bucket.id, bucket3.id, "Different name should return different bucket")
+	assert.NotSame(t, bucket.b, bucket3.b, "Different name should return different bucket")
+	assert.NotEqual(t, bucket.b.id, bucket3.b.id, "Different buckets should have different ids")
 
 	// Test pool has correct number of buckets
-	assert.Equal(t, 2, len(pool.buckets), "Pool should have two buckets")
+	assert.Equal(t, 2, pool.Len(), "Pool should have two buckets")
 }
 
 func TestUnfreshValue(t *testing.T) {
@@ -79,24 +106,65 @@ func TestNilValue(t *testing.T) {
 	assert.True(t, ok, "Value should be fresh")
 }
 
-func TestInvalidBucketID(t *testing.T) {
+func TestInvalidBucketHandle(t *testing.T) {
 	pool := NewDataPool()
 
-	// Test with negative ID
-	value, ts, ok := pool.get(-1, 0)
+	// Test with a handle that has no bucket
+	invalid := Bucket{pool: pool}
+	value, ts, ok := invalid.Get(0)
 	assert.Nil(t, value)
 	assert.Equal(t, int64(0), ts)
 	assert.False(t, ok)
 
-	// Test with out-of-bounds ID
-	value, ts, ok = pool.get(999, 0)
+	// Test with the zero handle
+	var zero Bucket
+	value, ts, ok = zero.Get(0)
 	assert.Nil(t, value)
 	assert.Equal(t, int64(0), ts)
 	assert.False(t, ok)
 
-	// Test put with invalid ID
-	timestamp := pool.put(-1, "test")
-	assert.Equal(t, int64(0), timestamp)
+	// Test put with invalid handles
+	assert.Equal(t, int64(0), invalid.Put("test"))
+	assert.Equal(t, int64(0), zero.Put("test"))
+}
+
+func TestGetFresh(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	bucket := pool.Bucket("test")
+
+	value, ok := bucket.GetFresh(time.Hour)
+	assert.Nil(t, value)
+	assert.False(t, ok, "Empty bucket should not be fresh")
+	assert.Equal(t, time.Duration(math.MaxInt64), bucket.Age())
+
+	bucket.Put(10)
+	clock.Advance(5 * time.Second)
+	assert.Equal(t, 5*time.Second, bucket.Age())
+
+	value, ok = bucket.GetFresh(5 * time.Second)
+	assert.Equal(t, 10, value)
+	assert.True(t, ok)
+
+	value, ok = bucket.GetFresh(4 * time.Second)
+	assert.Nil(t, value)
+	assert.False(t, ok, "Value older than maxAge should not be returned")
+}
+
+func TestAgeNeverNegative(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	bucket := pool.Bucket("test")
+
+	// With the clock standing still, timestamps step ahead of it.
+	bucket.Put(1)
+	bucket.Put(2)
+	assert.Zero(t, bucket.Age())
+
+	var zero Bucket
+	assert.Equal(t, time.Duration(math.MaxInt64), zero.Age())
+	_, ok := zero.GetFresh(time.Hour)
+	assert.False(t, ok)
 }
 
 func TestSequentialUpdates(t *testing.T) {
@@ -109,9 +177,6 @@ func TestSequentialUpdates(t *testing.T) {
 	assert.Equal(t, "first", value1)
 	assert.Equal(t, timestamp1, ts1)
 
-	// Ensure timestamps are different
-	time.Sleep(time.Millisecond)
-
 	// Second update
 	timestamp2 := bucket.Put("second")
 	value2, ts2, _ := bucket.Get(0)
@@ -169,13 +234,12 @@ func TestConcurrentBucketCreation(t *testing.T) {
 	// Wait for all goroutines to finish
 	wg.Wait()
 
-	// Check that buckets were created
-	assert.GreaterOrEqual(t, len(pool.buckets), 1)
-	assert.LessOrEqual(t, len(pool.buckets), numGoroutines)
+	// Check that all buckets were created
+	assert.Equal(t, numGoroutines, pool.Len())
 
 	// Check that we can retrieve buckets
-	for i := 0; i < len(pool.buckets); i++ {
-		assert.NotEmpty(t, pool.buckets[i].name)
+	for _, b := range pool.all() {
+		assert.NotEmpty(t, b.name())
 	}
 }
 
@@ -202,7 +266,7 @@ func TestConcurrentSameBucketName(t *testing.T) {
 	wg.Wait()
 
 	// We should have exactly one bucket
-	assert.Equal(t, 1, len(pool.buckets), "Should have only one bucket despite concurrent access")
+	assert.Equal(t, 1, pool.Len(), "Should have only one bucket despite concurrent access")
 
 	// The bucket should be accessible and have a value
 	b := pool.Bucket("same-name")
@@ -344,7 +408,7 @@ func TestManyBuckets(t *testing.T) {
 	}
 
 	// Check all buckets were created
-	assert.Equal(t, numBuckets, len(pool.buckets))
+	assert.Equal(t, numBuckets, pool.Len())
 
 	// Verify values in each bucket
 	for i := 0; i < numBuckets; i++ {
@@ -388,3 +452,38 @@ func BenchmarkConcurrentAccess(b *testing.B) {
 		}
 	})
 }
+
+// highConcurrency returns the RunParallel parallelism needed for at least 64 goroutines.
+func highConcurrency() int {
+	procs := runtime.GOMAXPROCS(0)
+	return (64 + procs - 1) / procs
+}
+
+func BenchmarkHighConcurrencyManyBuckets(b *testing.B) {
+	names := make([]string, 1024)
+	for i := range names {
+		names[i] = fmt.Sprintf("bucket-%d", i)
+	}
+
+	for _, shards := range []int{1, 32, 256} {
+		b.Run(fmt.Sprintf("shards-%d", shards), func(b *testing.B) {
+			pool := NewDataPool(WithShards(shards))
+			for _, name := range names {
+				pool.Bucket(name)
+			}
+
+			var next atomic.Int64
+			b.SetParallelism(highConcurrency())
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(next.Add(1)) * 7919
+				for pb.Next() {
+					bucket := pool.Bucket(names[i%len(names)])
+					bucket.Put(i)
+					bucket.Get(0)
+					i++
+				}
+			})
+		})
+	}
+}
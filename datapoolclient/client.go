@@ -0,0 +1,374 @@
+// Package datapoolclient accesses a datapool.DataPool served by datapoolhttp
+// in another process. Its Bucket has the same Get, Put and Watch methods as
+// datapool.Bucket, so code written against those methods works with either.
+//
+// Values travel as JSON, so they come back as the types encoding/json decodes
+// into: float64, string, bool, []any, map[string]any and nil.
+package datapoolclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/radamsa/datapool"
+	"github.com/radamsa/datapool/datapoolhttp"
+)
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests. The default is a
+// client without timeout; per-call timeouts come from WithTimeout.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// WithTimeout bounds every Get and Put that is not given a context. The
+// default is 10 seconds. Watch streams are not affected.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithErrorHandler sets a function receiving the errors that Get, Put and
+// Watch cannot return because their signatures match datapool.Bucket.
+func WithErrorHandler(fn func(bucket string, err error)) Option {
+	return func(c *Client) {
+		c.onError = fn
+	}
+}
+
+// Client talks to a datapoolhttp server.
+type Client struct {
+	base    string
+	http    *http.Client
+	timeout time.Duration
+	onError func(bucket string, err error)
+}
+
+// New returns a client for the server at addr, either a URL such as
+// "http://cache:8080" or a bare "host:port".
+func New(addr string, opts ...Option) *Client {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	c := &Client{
+		base:    strings.TrimRight(addr, "/"),
+		http:    &http.Client{},
+		timeout: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Bucket returns a handle to the named remote bucket. Like
+// datapool.DataPool.Bucket, the bucket is created on first use.
+func (c *Client) Bucket(name string) *Bucket {
+	return &Bucket{client: c, name: name}
+}
+
+// Handle returns Bucket(name), letting a Client serve as a datapool.Pool.
+func (c *Client) Handle(name string) datapool.Handle {
+	return c.Bucket(name)
+}
+
+// Buckets lists the names of the remote pool's buckets.
+func (c *Client) Buckets(ctx context.Context) ([]string, error) {
+	buckets, err := c.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(buckets))
+	for _, b := range buckets {
+		names = append(names, b.Name)
+	}
+	return names, nil
+}
+
+// List lists the remote pool's buckets with the timestamps of their values,
+// zero for empty buckets.
+func (c *Client) List(ctx context.Context) ([]datapoolhttp.BucketInfo, error) {
+	var resp datapoolhttp.ListResponse
+	if err := c.do(ctx, http.MethodGet, "/v1/buckets", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Buckets, nil
+}
+
+// Snapshot has the remote pool write a snapshot of its values to its
+// write-ahead log (see datapool.DataPool.CompactWAL), or to the snapshot file
+// of datapoolhttp.WithSnapshotFile, and returns the number of buckets it held.
+// It fails for a pool with neither.
+func (c *Client) Snapshot(ctx context.Context) (int, error) {
+	var resp datapoolhttp.SnapshotResponse
+	if err := c.do(ctx, http.MethodPost, "/v1/snapshot", nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Buckets, nil
+}
+
+// Bucket is a handle to a bucket of a remote pool.
+type Bucket struct {
+	client *Client
+	name   string
+}
+
+// Name returns the bucket's name.
+func (b *Bucket) Name() string {
+	return b.name
+}
+
+// Get behaves like datapool.Bucket.Get: a bucket the remote pool does not
+// have reads as empty. If the request fails it reports the error to the
+// client's error handler and returns nil, 0, false.
+func (b *Bucket) Get(timestamp int64) (any, int64, bool) {
+	ctx, cancel := b.client.callContext()
+	defer cancel()
+
+	value, ts, fresh, err := b.GetContext(ctx, timestamp)
+	if errors.Is(err, datapool.ErrNotFound) {
+		return nil, 0, false
+	}
+	if err != nil {
+		b.client.reportError(b.name, err)
+		return nil, 0, false
+	}
+	return value, ts, fresh
+}
+
+// GetContext is Get with a context and an error result, which wraps
+// datapool.ErrNotFound if the remote pool has no such bucket.
+func (b *Bucket) GetContext(ctx context.Context, timestamp int64) (any, int64, bool, error) {
+	var resp datapoolhttp.GetResponse
+	path := "/v1/buckets/" + escapeName(b.name) + "?since=" + strconv.FormatInt(timestamp, 10)
+	if err := b.client.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, 0, false, err
+	}
+
+	var value any
+	if err := json.Unmarshal(resp.Value, &value); err != nil {
+		return nil, 0, false, fmt.Errorf("datapoolclient: decode value of %q: %w", b.name, err)
+	}
+	return value, resp.Timestamp, resp.Fresh, nil
+}
+
+// Put behaves like datapool.Bucket.Put. If the request fails it reports the
+// error to the client's error handler and returns 0.
+func (b *Bucket) Put(value any) int64 {
+	ctx, cancel := b.client.callContext()
+	defer cancel()
+
+	ts, err := b.PutContext(ctx, value)
+	if err != nil {
+		b.client.reportError(b.name, err)
+		return 0
+	}
+	return ts
+}
+
+// PutContext is Put with a context and an error result.
+func (b *Bucket) PutContext(ctx context.Context, value any) (int64, error) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return 0, fmt.Errorf("datapoolclient: encode value of %q: %w", b.name, err)
+	}
+
+	var resp datapoolhttp.PutResponse
+	if err := b.client.do(ctx, http.MethodPut, "/v1/buckets/"+escapeName(b.name), body, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Timestamp, nil
+}
+
+// Watch behaves like datapool.Bucket.Watch. It returns once the server has
+// registered the watch, so no later Put is missed. The bucket must exist:
+// watching one the remote pool does not have reports an error wrapping
+// datapool.ErrNotFound and returns a closed channel. The channel is closed
+// when ctx is done or the stream ends; a broken stream is reported to the
+// client's error handler.
+func (b *Bucket) Watch(ctx context.Context) <-chan datapool.Update {
+	ch := make(chan datapool.Update, 16)
+
+	resp, err := b.client.open(ctx, "/v1/watch/"+escapeName(b.name))
+	if err != nil {
+		b.client.reportError(b.name, err)
+		close(ch)
+		return ch
+	}
+
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		err := readEvents(resp.Body, func(ev datapoolhttp.Event) error {
+			var value any
+			if err := json.Unmarshal(ev.Value, &value); err != nil {
+				return fmt.Errorf("datapoolclient: decode update of %q: %w", b.name, err)
+			}
+			select {
+			case ch <- datapool.Update{Bucket: ev.Bucket, Value: value, Timestamp: ev.Timestamp}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			b.client.reportError(b.name, err)
+		}
+	}()
+	return ch
+}
+
+// readEvents calls fn with every server-sent event read from r until r ends.
+func readEvents(r io.Reader, fn func(datapoolhttp.Event) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), datapoolhttp.MaxValueSize)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var ev datapoolhttp.Event
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return fmt.Errorf("datapoolclient: decode event: %w", err)
+		}
+		if err := fn(ev); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (c *Client) callContext() (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), c.timeout)
+}
+
+func (c *Client) reportError(bucket string, err error) {
+	if c.onError != nil {
+		c.onError(bucket, err)
+	}
+}
+
+// do sends a request with an optional JSON body and decodes the JSON response into out.
+func (c *Client) do(ctx context.Context, method, path string, body []byte, out any) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, reader)
+	if err != nil {
+		return fmt.Errorf("datapoolclient: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("datapoolclient: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("datapoolclient: decode response: %w", err)
+	}
+	return nil
+}
+
+// open starts a streaming GET request.
+func (c *Client) open(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("datapoolclient: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("datapoolclient: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// StatusError is returned when the server answers with an error status. It
+// matches with errors.Is the datapool error the status stands for, if any:
+// datapool.ErrNotFound for 404, datapool.ErrSealed for 403, and
+// datapool.ErrSystemBucket for writes to system buckets, datapool.ErrTooLarge
+// for 413, datapool.ErrStale for 409, datapool.ErrBackpressure for 429, and
+// datapool.ErrInvalidValue or datapool.ErrInvalidName for the 400s of values
+// and names the server rejects.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("datapoolclient: server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Unwrap returns the datapool error the status stands for, or nil.
+func (e *StatusError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return datapool.ErrNotFound
+	case http.StatusForbidden:
+		if strings.Contains(e.Message, datapool.ErrSystemBucket.Error()) {
+			return datapool.ErrSystemBucket
+		}
+		return datapool.ErrSealed
+	case http.StatusRequestEntityTooLarge:
+		return datapool.ErrTooLarge
+	case http.StatusConflict:
+		return datapool.ErrStale
+	case http.StatusTooManyRequests:
+		return datapool.ErrBackpressure
+	case http.StatusBadRequest:
+		switch {
+		case strings.Contains(e.Message, datapool.ErrInvalidValue.Error()):
+			return datapool.ErrInvalidValue
+		case strings.Contains(e.Message, datapool.ErrInvalidName.Error()):
+			return datapool.ErrInvalidName
+		}
+	}
+	return nil
+}
+
+func responseError(resp *http.Response) error {
+	var body datapoolhttp.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Error == "" {
+		body.Error = http.StatusText(resp.StatusCode)
+	}
+	return &StatusError{StatusCode: resp.StatusCode, Message: body.Error}
+}
+
+// escapeName escapes a bucket name, slashes included, for use as a single URL
+// path segment.
+func escapeName(name string) string {
+	return url.PathEscape(name)
+}
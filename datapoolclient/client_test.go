@@ -0,0 +1,190 @@
+package datapoolclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radamsa/datapool"
+	"github.com/radamsa/datapool/datapoolhttp"
+)
+
+var (
+	_ datapool.Pool   = (*Client)(nil)
+	_ datapool.Handle = (*Bucket)(nil)
+)
+
+func newTestServer(t *testing.T) (*datapool.DataPool, *Client) {
+	pool := datapool.NewDataPool()
+	srv := httptest.NewServer(datapoolhttp.NewHandler(pool))
+	t.Cleanup(srv.Close)
+
+	var errs []error
+	t.Cleanup(func() {
+		assert.Empty(t, errs, "Unexpected client errors")
+	})
+	return pool, New(srv.URL, WithErrorHandler(func(_ string, err error) {
+		errs = append(errs, err)
+	}))
+}
+
+func TestGetPut(t *testing.T) {
+	pool, client := newTestServer(t)
+
+	remote := client.Bucket("config")
+	ts := remote.Put(map[string]any{"theme": "dark"})
+	assert.NotZero(t, ts)
+
+	local := pool.Bucket("config")
+	val, localTS, _ := local.Get(0)
+	assert.Equal(t, map[string]any{"theme": "dark"}, val)
+	assert.Equal(t, ts, localTS, "Timestamps come from the remote pool")
+
+	val, ts2, fresh := remote.Get(ts - 1)
+	assert.Equal(t, map[string]any{"theme": "dark"}, val)
+	assert.Equal(t, ts, ts2)
+	assert.True(t, fresh)
+
+	_, _, fresh = remote.Get(ts)
+	assert.False(t, fresh)
+}
+
+func TestValuesAreJSON(t *testing.T) {
+	_, client := newTestServer(t)
+
+	b := client.Bucket("counter")
+	b.Put(42)
+	val, _, _ := b.Get(0)
+	assert.Equal(t, 42.0, val, "Numbers come back as float64")
+
+	empty := client.Bucket("empty")
+	val, ts, fresh := empty.Get(0)
+	assert.Nil(t, val)
+	assert.Zero(t, ts)
+	assert.False(t, fresh)
+}
+
+func TestNamesWithSlashes(t *testing.T) {
+	pool, client := newTestServer(t)
+
+	for _, name := range []string{"users/42", "a b/c?d", "/leading", "trailing/", "double//slash"} {
+		remote := client.Bucket(name)
+		ts := remote.Put(name)
+		require.NotZero(t, ts, name)
+
+		local := pool.Bucket(name)
+		val, _, _ := local.Get(0)
+		assert.Equal(t, name, val, name)
+	}
+
+	names, err := client.Buckets(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"users/42", "a b/c?d", "/leading", "trailing/", "double//slash"}, names)
+}
+
+func TestWatch(t *testing.T) {
+	pool, client := newTestServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	local := pool.Bucket("feed")
+	updates := client.Bucket("feed").Watch(ctx)
+
+	ts := local.Put("hello")
+
+	select {
+	case u := <-updates:
+		assert.Equal(t, datapool.Update{Bucket: "feed", Value: "hello", Timestamp: ts}, u)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for update")
+	}
+
+	cancel()
+	for range updates {
+	}
+}
+
+func TestErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":"maintenance"}`))
+	}))
+	defer srv.Close()
+
+	var reported []error
+	client := New(srv.URL, WithErrorHandler(func(bucket string, err error) {
+		assert.Equal(t, "config", bucket)
+		reported = append(reported, err)
+	}))
+	b := client.Bucket("config")
+
+	_, _, _, err := b.GetContext(context.Background(), 0)
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+	assert.Equal(t, "maintenance", statusErr.Message)
+
+	assert.Zero(t, b.Put("x"))
+	val, _, _ := b.Get(0)
+	assert.Nil(t, val)
+	_, ok := <-b.Watch(context.Background())
+	assert.False(t, ok, "Failed watch returns a closed channel")
+	assert.Len(t, reported, 3)
+}
+
+func TestErrorSentinels(t *testing.T) {
+	pool, client := newTestServer(t)
+	config := pool.Bucket("config")
+	config.SetValidator(func(value any) error {
+		return errors.New("config must be an object")
+	})
+	ctx := context.Background()
+
+	_, err := client.Bucket("config").PutContext(ctx, "v")
+	assert.ErrorIs(t, err, datapool.ErrInvalidValue)
+	_, err = client.Bucket(datapool.SystemStatsBucket).PutContext(ctx, "forged")
+	assert.ErrorIs(t, err, datapool.ErrSystemBucket)
+	assert.ErrorIs(t, err, datapool.ErrSealed)
+
+	for code, want := range map[int]error{
+		http.StatusNotFound:              datapool.ErrNotFound,
+		http.StatusForbidden:             datapool.ErrSealed,
+		http.StatusRequestEntityTooLarge: datapool.ErrTooLarge,
+		http.StatusConflict:              datapool.ErrStale,
+		http.StatusTooManyRequests:       datapool.ErrBackpressure,
+	} {
+		err := &StatusError{StatusCode: code}
+		assert.ErrorIs(t, err, want, code)
+	}
+	assert.ErrorIs(t, &StatusError{StatusCode: http.StatusBadRequest, Message: "datapool: invalid bucket name: too long"}, datapool.ErrInvalidName)
+	assert.Nil(t, (&StatusError{StatusCode: http.StatusServiceUnavailable}).Unwrap())
+}
+
+func TestNewAddress(t *testing.T) {
+	assert.Equal(t, "http://cache:8080", New("cache:8080").base)
+	assert.Equal(t, "https://cache", New("https://cache/").base)
+}
+
+func TestSnapshot(t *testing.T) {
+	pool := datapool.NewDataPool()
+	path := filepath.Join(t.TempDir(), "pool.snapshot")
+	srv := httptest.NewServer(datapoolhttp.NewHandler(pool, datapoolhttp.WithSnapshotFile(path)))
+	t.Cleanup(srv.Close)
+	client := New(srv.URL)
+	pool.Handle("config").Put(1)
+
+	n, err := client.Snapshot(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, client = newTestServer(t)
+	_, err = client.Snapshot(context.Background())
+	assert.Error(t, err, "Pools without a log nor snapshot file cannot take snapshots")
+}
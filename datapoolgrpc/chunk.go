@@ -0,0 +1,74 @@
+package datapoolgrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/radamsa/datapool"
+)
+
+// sendChunks sends raw with send, in one message without a Chunk if it fits
+// in size bytes, and in chunks of size bytes otherwise.
+func sendChunks(raw []byte, size int, send func(data []byte, c *Chunk) error) error {
+	if size <= 0 {
+		size = datapool.DefaultChunkSize
+	}
+	if len(raw) <= size {
+		return send(raw, nil)
+	}
+
+	w := datapool.NewChunkWriter(size, func(c datapool.Chunk) error {
+		return send(c.Data, &Chunk{Seq: uint32(c.Seq), Checksum: c.Checksum, More: !c.Last})
+	})
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// chunked is a message carrying a whole value or a chunk of one.
+type chunked interface {
+	GetValue() []byte
+	GetChunk() *Chunk
+}
+
+// reassembler collects the chunks of the values of a stream.
+type reassembler struct {
+	chunks  datapool.ChunkAssembler
+	started bool
+}
+
+var errInterleaved = errors.New("whole value sent within a chunked one")
+
+// add adds msg and returns the decoded value once it is complete.
+func (r *reassembler) add(msg chunked) (value any, done bool, err error) {
+	c := msg.GetChunk()
+	if c == nil {
+		if r.started {
+			return nil, false, errInterleaved
+		}
+		value, err := decode(bytes.NewReader(msg.GetValue()))
+		return value, true, err
+	}
+
+	r.started = true
+	done, err = r.chunks.Add(datapool.Chunk{Seq: int(c.GetSeq()), Data: msg.GetValue(), Checksum: c.GetChecksum(), Last: !c.GetMore()})
+	if err != nil || !done {
+		return nil, false, err
+	}
+	value, err = decode(r.chunks.Reader())
+	r.chunks.Reset()
+	r.started = false
+	return value, true, err
+}
+
+// decode decodes a JSON value without reading it into one buffer first.
+func decode(r io.Reader) (any, error) {
+	var value any
+	if err := json.NewDecoder(r).Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,122 @@
+package datapoolgrpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/radamsa/datapool"
+)
+
+func TestLargeValues(t *testing.T) {
+	pool := datapool.NewDataPool()
+	conn := dial(t, NewServer(pool, WithServerChunkSize(64)))
+	var errs []error
+	client := New(conn, WithChunkSize(64), WithErrorHandler(func(_ string, err error) {
+		errs = append(errs, err)
+	}))
+	large := strings.Repeat("0123456789", 100)
+
+	remote := client.Bucket("blob")
+	local := pool.Bucket("blob")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := remote.Watch(ctx)
+
+	ts := remote.Put(large)
+	require.NotZero(t, ts)
+	val, _, _ := local.Get(0)
+	assert.Equal(t, large, val, "Put sends large values in chunks")
+
+	val, got, fresh := remote.Get(0)
+	assert.Equal(t, large, val, "Get receives large values in chunks")
+	assert.Equal(t, ts, got)
+	assert.True(t, fresh)
+
+	u := <-updates
+	assert.Equal(t, datapool.Update{Bucket: "blob", Value: large, Timestamp: ts}, u, "Watch streams large values in chunks")
+
+	// Small values still travel whole.
+	remote.Put("small")
+	assert.Equal(t, "small", (<-updates).Value)
+	assert.Empty(t, errs)
+}
+
+func TestPutChunksLimit(t *testing.T) {
+	pool := datapool.NewDataPool()
+	conn := dial(t, NewServer(pool, WithMaxValueSize(256)))
+	client := New(conn, WithChunkSize(64))
+
+	_, err := client.Bucket("blob").PutContext(context.Background(), strings.Repeat("x", 1000))
+	assert.ErrorIs(t, err, datapool.ErrTooLarge, "Reassembled values are bounded")
+	assert.Zero(t, pool.Len())
+}
+
+func TestReassemblerRejectsCorruptChunks(t *testing.T) {
+	var chunks []*Update
+	err := sendChunks([]byte(`"abcdefghij"`), 4, func(data []byte, c *Chunk) error {
+		chunks = append(chunks, &Update{Value: data, Chunk: c})
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, chunks, 3)
+
+	var r reassembler
+	for i, c := range chunks {
+		value, done, err := r.add(c)
+		require.NoError(t, err)
+		assert.Equal(t, i == len(chunks)-1, done)
+		if done {
+			assert.Equal(t, "abcdefghij", value)
+		}
+	}
+
+	_, _, err = r.add(chunks[1])
+	assert.ErrorIs(t, err, datapool.ErrCorruptChunk, "Chunks must arrive in order")
+
+	r = reassembler{}
+	_, _, err = r.add(chunks[0])
+	require.NoError(t, err)
+	_, _, err = r.add(&Update{Value: []byte(`"whole"`)})
+	assert.ErrorIs(t, err, errInterleaved)
+
+	r = reassembler{}
+	flipped := &Update{Value: []byte("xyz!"), Chunk: chunks[0].Chunk}
+	_, _, err = r.add(flipped)
+	assert.ErrorIs(t, err, datapool.ErrCorruptChunk, "Checksums are verified")
+}
+
+// wholeOnly is a server predating GetChunks and PutChunks.
+type wholeOnly struct {
+	UnimplementedDataPoolServer
+	srv DataPoolServer
+}
+
+func (w wholeOnly) Get(ctx context.Context, req *GetRequest) (*GetResponse, error) {
+	return w.srv.Get(ctx, req)
+}
+
+func (w wholeOnly) Put(ctx context.Context, req *PutRequest) (*PutResponse, error) {
+	return w.srv.Put(ctx, req)
+}
+
+func (w wholeOnly) Watch(req *WatchRequest, stream grpc.ServerStreamingServer[Update]) error {
+	return w.srv.Watch(req, stream)
+}
+
+func TestGetFromServerWithoutChunks(t *testing.T) {
+	pool := datapool.NewDataPool()
+	conn := dial(t, wholeOnly{srv: NewServer(pool)})
+	client := New(conn)
+
+	b := pool.Bucket("config")
+	ts := b.Put("v")
+	val, got, _, err := client.Bucket("config").GetContext(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "v", val)
+	assert.Equal(t, ts, got)
+}
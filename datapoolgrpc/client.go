@@ -0,0 +1,315 @@
+package datapoolgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/radamsa/datapool"
+)
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithTimeout bounds every Get and Put that is not given a context. The
+// default is 10 seconds. Watch streams are not affected.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithChunkSize sets the size above which Put sends values in chunks. The
+// default is datapool.DefaultChunkSize.
+func WithChunkSize(n int) Option {
+	return func(c *Client) {
+		c.chunkSize = n
+	}
+}
+
+// WithErrorHandler sets a function receiving the errors that Get, Put and
+// Watch cannot return because their signatures match datapool.Bucket.
+func WithErrorHandler(fn func(bucket string, err error)) Option {
+	return func(c *Client) {
+		c.onError = fn
+	}
+}
+
+// Client talks to a DataPool gRPC server.
+type Client struct {
+	rpc       DataPoolClient
+	timeout   time.Duration
+	chunkSize int
+	onError   func(bucket string, err error)
+}
+
+// New returns a client using conn, which stays owned by the caller.
+func New(conn grpc.ClientConnInterface, opts ...Option) *Client {
+	c := &Client{
+		rpc:       NewDataPoolClient(conn),
+		timeout:   10 * time.Second,
+		chunkSize: datapool.DefaultChunkSize,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Bucket returns a handle to the named remote bucket. Like
+// datapool.DataPool.Bucket, the bucket is created on first use.
+func (c *Client) Bucket(name string) *Bucket {
+	return &Bucket{client: c, name: name}
+}
+
+// Handle returns Bucket(name), letting a Client serve as a datapool.Pool.
+func (c *Client) Handle(name string) datapool.Handle {
+	return c.Bucket(name)
+}
+
+// Bucket is a handle to a bucket of a remote pool.
+type Bucket struct {
+	client *Client
+	name   string
+}
+
+// Name returns the bucket's name.
+func (b *Bucket) Name() string {
+	return b.name
+}
+
+// Get behaves like datapool.Bucket.Get: a bucket the remote pool does not
+// have reads as empty. If the call fails it reports the error to the
+// client's error handler and returns nil, 0, false.
+func (b *Bucket) Get(timestamp int64) (any, int64, bool) {
+	ctx, cancel := b.client.callContext()
+	defer cancel()
+
+	value, ts, fresh, err := b.GetContext(ctx, timestamp)
+	if errors.Is(err, datapool.ErrNotFound) {
+		return nil, 0, false
+	}
+	if err != nil {
+		b.client.reportError(b.name, err)
+		return nil, 0, false
+	}
+	return value, ts, fresh
+}
+
+// GetContext is Get with a context and an error result, which wraps
+// datapool.ErrNotFound if the remote pool has no such bucket. Values are
+// streamed in chunks, except from servers predating chunking.
+func (b *Bucket) GetContext(ctx context.Context, timestamp int64) (any, int64, bool, error) {
+	stream, err := b.client.rpc.GetChunks(ctx, &GetRequest{Bucket: b.name, Since: timestamp})
+	var chunks reassembler
+	for err == nil {
+		var resp *GetResponse
+		if resp, err = stream.Recv(); err != nil {
+			break
+		}
+		value, done, err := chunks.add(resp)
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("datapoolgrpc: decode value of %q: %w", b.name, err)
+		}
+		if done {
+			return value, resp.GetTimestamp(), resp.GetFresh(), nil
+		}
+	}
+	if status.Code(err) == codes.Unimplemented {
+		return b.getWhole(ctx, timestamp)
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return nil, 0, false, fmt.Errorf("datapoolgrpc: get %q: %w", b.name, remoteError(err))
+}
+
+// getWhole is GetContext for servers without GetChunks.
+func (b *Bucket) getWhole(ctx context.Context, timestamp int64) (any, int64, bool, error) {
+	resp, err := b.client.rpc.Get(ctx, &GetRequest{Bucket: b.name, Since: timestamp})
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("datapoolgrpc: get %q: %w", b.name, remoteError(err))
+	}
+
+	var value any
+	if err := json.Unmarshal(resp.GetValue(), &value); err != nil {
+		return nil, 0, false, fmt.Errorf("datapoolgrpc: decode value of %q: %w", b.name, err)
+	}
+	return value, resp.GetTimestamp(), resp.GetFresh(), nil
+}
+
+// Put behaves like datapool.Bucket.Put. If the call fails it reports the
+// error to the client's error handler and returns 0.
+func (b *Bucket) Put(value any) int64 {
+	ctx, cancel := b.client.callContext()
+	defer cancel()
+
+	ts, err := b.PutContext(ctx, value)
+	if err != nil {
+		b.client.reportError(b.name, err)
+		return 0
+	}
+	return ts
+}
+
+// PutContext is Put with a context and an error result.
+func (b *Bucket) PutContext(ctx context.Context, value any) (int64, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return 0, fmt.Errorf("datapoolgrpc: encode value of %q: %w", b.name, err)
+	}
+
+	if size := b.client.chunkSize; size <= 0 || len(raw) <= size {
+		resp, err := b.client.rpc.Put(ctx, &PutRequest{Bucket: b.name, Value: raw})
+		if err != nil {
+			return 0, fmt.Errorf("datapoolgrpc: put %q: %w", b.name, remoteError(err))
+		}
+		return resp.GetTimestamp(), nil
+	}
+
+	stream, err := b.client.rpc.PutChunks(ctx)
+	if err == nil {
+		err = sendChunks(raw, b.client.chunkSize, func(data []byte, c *Chunk) error {
+			req := &PutRequest{Value: data, Chunk: c}
+			if c.GetSeq() == 0 {
+				req.Bucket = b.name
+			}
+			return stream.Send(req)
+		})
+	}
+	var resp *PutResponse
+	if err == nil || err == io.EOF {
+		// A failed Send returns io.EOF; the status comes with the response.
+		resp, err = stream.CloseAndRecv()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("datapoolgrpc: put %q: %w", b.name, remoteError(err))
+	}
+	return resp.GetTimestamp(), nil
+}
+
+// Watch behaves like datapool.Bucket.Watch. It returns once the server has
+// registered the watch, so no later Put is missed. The bucket must exist:
+// watching one the remote pool does not have reports an error wrapping
+// datapool.ErrNotFound and returns a closed channel. The channel is closed
+// when ctx is done or the stream ends; a broken stream is reported to the
+// client's error handler.
+func (b *Bucket) Watch(ctx context.Context) <-chan datapool.Update {
+	ch := make(chan datapool.Update, 16)
+
+	stream, err := b.client.rpc.Watch(ctx, &WatchRequest{Bucket: b.name})
+	if err == nil {
+		// The server sends its headers once the watch is registered.
+		_, err = stream.Header()
+	}
+	if err != nil {
+		b.client.reportError(b.name, fmt.Errorf("datapoolgrpc: watch %q: %w", b.name, remoteError(err)))
+		close(ch)
+		return ch
+	}
+
+	go func() {
+		defer close(ch)
+
+		err := b.receive(ctx, stream, ch)
+		if err != nil && ctx.Err() == nil {
+			b.client.reportError(b.name, err)
+		}
+	}()
+	return ch
+}
+
+// receive sends the updates read from stream to ch until the stream ends.
+func (b *Bucket) receive(ctx context.Context, stream grpc.ServerStreamingClient[Update], ch chan<- datapool.Update) error {
+	var chunks reassembler
+	for {
+		u, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("datapoolgrpc: watch %q: %w", b.name, remoteError(err))
+		}
+
+		value, done, err := chunks.add(u)
+		if err != nil {
+			return fmt.Errorf("datapoolgrpc: decode update of %q: %w", b.name, err)
+		}
+		if !done {
+			continue
+		}
+		select {
+		case ch <- datapool.Update{Bucket: u.GetBucket(), Value: value, Timestamp: u.GetTimestamp()}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *Client) callContext() (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), c.timeout)
+}
+
+func (c *Client) reportError(bucket string, err error) {
+	if c.onError != nil {
+		c.onError(bucket, err)
+	}
+}
+
+// remoteError returns err, the error of a call, matching with errors.Is the
+// datapool error its gRPC code stands for, if any: datapool.ErrNotFound for
+// NotFound, datapool.ErrSealed for PermissionDenied, and
+// datapool.ErrSystemBucket for writes to system buckets,
+// datapool.ErrTooLarge for ResourceExhausted, and datapool.ErrInvalidValue or
+// datapool.ErrInvalidName for the InvalidArguments of values and names the
+// server rejects.
+func remoteError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	var kind error
+	switch st.Code() {
+	case codes.NotFound:
+		kind = datapool.ErrNotFound
+	case codes.PermissionDenied:
+		kind = datapool.ErrSealed
+		if strings.Contains(st.Message(), datapool.ErrSystemBucket.Error()) {
+			kind = datapool.ErrSystemBucket
+		}
+	case codes.ResourceExhausted:
+		kind = datapool.ErrTooLarge
+	case codes.InvalidArgument:
+		switch {
+		case strings.Contains(st.Message(), datapool.ErrInvalidValue.Error()):
+			kind = datapool.ErrInvalidValue
+		case strings.Contains(st.Message(), datapool.ErrInvalidName.Error()):
+			kind = datapool.ErrInvalidName
+		}
+	}
+	if kind == nil {
+		return err
+	}
+	return &statusError{err: err, kind: kind}
+}
+
+// statusError is the error of a call that also matches the datapool error
+// its code stands for.
+type statusError struct {
+	err  error
+	kind error
+}
+
+func (e *statusError) Error() string   { return e.err.Error() }
+func (e *statusError) Unwrap() []error { return []error{e.err, e.kind} }
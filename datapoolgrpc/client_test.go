@@ -0,0 +1,183 @@
+package datapoolgrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/radamsa/datapool"
+)
+
+var (
+	_ datapool.Pool   = (*Client)(nil)
+	_ datapool.Handle = (*Bucket)(nil)
+)
+
+// dial serves srv in memory and returns a connection to it.
+func dial(t *testing.T, srv DataPoolServer) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	RegisterDataPoolServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func newTestServer(t *testing.T) (*datapool.DataPool, *Client) {
+	pool := datapool.NewDataPool()
+	conn := dial(t, NewServer(pool))
+
+	var errs []error
+	t.Cleanup(func() {
+		assert.Empty(t, errs, "Unexpected client errors")
+	})
+	return pool, New(conn, WithErrorHandler(func(_ string, err error) {
+		errs = append(errs, err)
+	}))
+}
+
+func TestGetPut(t *testing.T) {
+	pool, client := newTestServer(t)
+
+	remote := client.Bucket("config")
+	ts := remote.Put(map[string]any{"theme": "dark"})
+	assert.NotZero(t, ts)
+
+	local := pool.Bucket("config")
+	val, localTS, _ := local.Get(0)
+	assert.Equal(t, map[string]any{"theme": "dark"}, val)
+	assert.Equal(t, ts, localTS, "Timestamps come from the remote pool")
+
+	val, ts2, fresh := remote.Get(ts - 1)
+	assert.Equal(t, map[string]any{"theme": "dark"}, val)
+	assert.Equal(t, ts, ts2)
+	assert.True(t, fresh)
+
+	_, _, fresh = remote.Get(ts)
+	assert.False(t, fresh)
+}
+
+func TestValuesAreJSON(t *testing.T) {
+	_, client := newTestServer(t)
+
+	b := client.Bucket("counter")
+	b.Put(42)
+	val, _, _ := b.Get(0)
+	assert.Equal(t, 42.0, val, "Numbers come back as float64")
+
+	empty := client.Bucket("empty")
+	val, ts, fresh := empty.Get(0)
+	assert.Nil(t, val)
+	assert.Zero(t, ts)
+	assert.False(t, fresh)
+}
+
+func TestWatch(t *testing.T) {
+	pool, client := newTestServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	local := pool.Bucket("feed")
+	updates := client.Bucket("feed").Watch(ctx)
+
+	ts := local.Put("hello")
+
+	select {
+	case u := <-updates:
+		assert.Equal(t, datapool.Update{Bucket: "feed", Value: "hello", Timestamp: ts}, u)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for update")
+	}
+
+	cancel()
+	for range updates {
+	}
+}
+
+// unavailable fails every call.
+type unavailable struct {
+	UnimplementedDataPoolServer
+}
+
+func (unavailable) Get(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Error(codes.Unavailable, "maintenance")
+}
+
+func (unavailable) Put(context.Context, *PutRequest) (*PutResponse, error) {
+	return nil, status.Error(codes.Unavailable, "maintenance")
+}
+
+func (unavailable) Watch(*WatchRequest, grpc.ServerStreamingServer[Update]) error {
+	return status.Error(codes.Unavailable, "maintenance")
+}
+
+func TestErrors(t *testing.T) {
+	conn := dial(t, unavailable{})
+
+	var reported []error
+	client := New(conn, WithErrorHandler(func(bucket string, err error) {
+		assert.Equal(t, "config", bucket)
+		reported = append(reported, err)
+	}))
+	b := client.Bucket("config")
+
+	_, _, _, err := b.GetContext(context.Background(), 0)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.ErrorContains(t, err, `datapoolgrpc: get "config"`)
+
+	assert.Zero(t, b.Put("x"))
+	val, _, _ := b.Get(0)
+	assert.Nil(t, val)
+	_, ok := <-b.Watch(context.Background())
+	assert.False(t, ok, "Failed watch returns a closed channel")
+	assert.Len(t, reported, 3)
+}
+
+func TestErrorSentinels(t *testing.T) {
+	pool := datapool.NewDataPool(datapool.WithNameRules(datapool.NameRules{MaxLength: 8}))
+	config := pool.Bucket("config")
+	config.SetValidator(func(value any) error {
+		if _, ok := value.(map[string]any); !ok {
+			return errors.New("config must be an object")
+		}
+		return nil
+	})
+	client := New(dial(t, NewServer(pool)))
+	ctx := context.Background()
+
+	_, err := client.Bucket("config").PutContext(ctx, "v")
+	assert.ErrorIs(t, err, datapool.ErrInvalidValue)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.ErrorContains(t, err, "config must be an object")
+
+	_, err = client.Bucket(datapool.SystemStatsBucket).PutContext(ctx, "forged")
+	assert.ErrorIs(t, err, datapool.ErrSystemBucket)
+	assert.ErrorIs(t, err, datapool.ErrSealed)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.Bucket("much-too-long").PutContext(ctx, "v")
+	assert.ErrorIs(t, err, datapool.ErrInvalidName)
+
+	_, _, _, err = New(dial(t, unavailable{})).Bucket("config").GetContext(ctx, 0)
+	assert.NotErrorIs(t, err, datapool.ErrNotFound)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
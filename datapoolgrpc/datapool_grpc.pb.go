@@ -0,0 +1,294 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: datapool.proto
+
+// The DataPool service exposes the buckets of one pool to remote processes.
+// Values travel as JSON, like with datapoolhttp. Values larger than a chunk
+// travel as several consecutive messages, each carrying a Chunk describing
+// its part of the value; messages without a Chunk hold a whole value.
+
+package datapoolgrpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	DataPool_Get_FullMethodName       = "/datapool.v1.DataPool/Get"
+	DataPool_Put_FullMethodName       = "/datapool.v1.DataPool/Put"
+	DataPool_Watch_FullMethodName     = "/datapool.v1.DataPool/Watch"
+	DataPool_GetChunks_FullMethodName = "/datapool.v1.DataPool/GetChunks"
+	DataPool_PutChunks_FullMethodName = "/datapool.v1.DataPool/PutChunks"
+)
+
+// DataPoolClient is the client API for DataPool service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DataPoolClient interface {
+	// Get reads a bucket, reporting whether its value is newer than since.
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	// Put writes a bucket and returns the value's timestamp.
+	Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*PutResponse, error)
+	// Watch streams every value stored in a bucket after the call. The server
+	// sends the response headers once the watch is registered.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Update], error)
+	// GetChunks is Get for values of any size: the response is streamed in
+	// chunks, one message for values that fit in one.
+	GetChunks(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GetResponse], error)
+	// PutChunks is Put for values of any size, sent in chunks. Only the first
+	// request needs to name the bucket.
+	PutChunks(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[PutRequest, PutResponse], error)
+}
+
+type dataPoolClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDataPoolClient(cc grpc.ClientConnInterface) DataPoolClient {
+	return &dataPoolClient{cc}
+}
+
+func (c *dataPoolClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, DataPool_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dataPoolClient) Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*PutResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PutResponse)
+	err := c.cc.Invoke(ctx, DataPool_Put_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dataPoolClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Update], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DataPool_ServiceDesc.Streams[0], DataPool_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequest, Update]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DataPool_WatchClient = grpc.ServerStreamingClient[Update]
+
+func (c *dataPoolClient) GetChunks(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GetResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DataPool_ServiceDesc.Streams[1], DataPool_GetChunks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetRequest, GetResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DataPool_GetChunksClient = grpc.ServerStreamingClient[GetResponse]
+
+func (c *dataPoolClient) PutChunks(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[PutRequest, PutResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DataPool_ServiceDesc.Streams[2], DataPool_PutChunks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PutRequest, PutResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DataPool_PutChunksClient = grpc.ClientStreamingClient[PutRequest, PutResponse]
+
+// DataPoolServer is the server API for DataPool service.
+// All implementations must embed UnimplementedDataPoolServer
+// for forward compatibility.
+type DataPoolServer interface {
+	// Get reads a bucket, reporting whether its value is newer than since.
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	// Put writes a bucket and returns the value's timestamp.
+	Put(context.Context, *PutRequest) (*PutResponse, error)
+	// Watch streams every value stored in a bucket after the call. The server
+	// sends the response headers once the watch is registered.
+	Watch(*WatchRequest, grpc.ServerStreamingServer[Update]) error
+	// GetChunks is Get for values of any size: the response is streamed in
+	// chunks, one message for values that fit in one.
+	GetChunks(*GetRequest, grpc.ServerStreamingServer[GetResponse]) error
+	// PutChunks is Put for values of any size, sent in chunks. Only the first
+	// request needs to name the bucket.
+	PutChunks(grpc.ClientStreamingServer[PutRequest, PutResponse]) error
+	mustEmbedUnimplementedDataPoolServer()
+}
+
+// UnimplementedDataPoolServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDataPoolServer struct{}
+
+func (UnimplementedDataPoolServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedDataPoolServer) Put(context.Context, *PutRequest) (*PutResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Put not implemented")
+}
+func (UnimplementedDataPoolServer) Watch(*WatchRequest, grpc.ServerStreamingServer[Update]) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedDataPoolServer) GetChunks(*GetRequest, grpc.ServerStreamingServer[GetResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method GetChunks not implemented")
+}
+func (UnimplementedDataPoolServer) PutChunks(grpc.ClientStreamingServer[PutRequest, PutResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method PutChunks not implemented")
+}
+func (UnimplementedDataPoolServer) mustEmbedUnimplementedDataPoolServer() {}
+func (UnimplementedDataPoolServer) testEmbeddedByValue()                  {}
+
+// UnsafeDataPoolServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DataPoolServer will
+// result in compilation errors.
+type UnsafeDataPoolServer interface {
+	mustEmbedUnimplementedDataPoolServer()
+}
+
+func RegisterDataPoolServer(s grpc.ServiceRegistrar, srv DataPoolServer) {
+	// If the following call pancis, it indicates UnimplementedDataPoolServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&DataPool_ServiceDesc, srv)
+}
+
+func _DataPool_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DataPoolServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DataPool_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DataPoolServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DataPool_Put_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DataPoolServer).Put(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DataPool_Put_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DataPoolServer).Put(ctx, req.(*PutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DataPool_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DataPoolServer).Watch(m, &grpc.GenericServerStream[WatchRequest, Update]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DataPool_WatchServer = grpc.ServerStreamingServer[Update]
+
+func _DataPool_GetChunks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DataPoolServer).GetChunks(m, &grpc.GenericServerStream[GetRequest, GetResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DataPool_GetChunksServer = grpc.ServerStreamingServer[GetResponse]
+
+func _DataPool_PutChunks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(DataPoolServer).PutChunks(&grpc.GenericServerStream[PutRequest, PutResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DataPool_PutChunksServer = grpc.ClientStreamingServer[PutRequest, PutResponse]
+
+// DataPool_ServiceDesc is the grpc.ServiceDesc for DataPool service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DataPool_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "datapool.v1.DataPool",
+	HandlerType: (*DataPoolServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _DataPool_Get_Handler,
+		},
+		{
+			MethodName: "Put",
+			Handler:    _DataPool_Put_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _DataPool_Watch_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetChunks",
+			Handler:       _DataPool_GetChunks_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PutChunks",
+			Handler:       _DataPool_PutChunks_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "datapool.proto",
+}
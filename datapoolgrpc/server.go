@@ -0,0 +1,242 @@
+// Package datapoolgrpc serves a datapool.DataPool over gRPC and accesses it
+// from other processes, so edge workers can read, write and watch the buckets
+// of one authoritative pool:
+//
+//	srv := grpc.NewServer()
+//	datapoolgrpc.RegisterDataPoolServer(srv, datapoolgrpc.NewServer(pool))
+//	go srv.Serve(lis)
+//
+//	conn, err := grpc.NewClient("cache:9090", grpc.WithTransportCredentials(insecure.NewCredentials()))
+//	client := datapoolgrpc.New(conn)
+//	rates := client.Bucket("rates/EURUSD")
+//	value, ts, fresh := rates.Get(0)
+//
+// The service is defined in datapool.proto; datapool.pb.go and
+// datapool_grpc.pb.go are generated from it with protoc-gen-go and
+// protoc-gen-go-grpc. Values travel as JSON, so they come back as the types
+// encoding/json decodes into: float64, string, bool, []any, map[string]any
+// and nil. Values larger than a chunk (see datapool.DefaultChunkSize) are
+// streamed in chunks with checksums, so they are not bound by gRPC's message
+// size limit, up to the server's limit (see WithMaxValueSize). Get and Watch
+// of a bucket that does not exist fail with NotFound, without creating it;
+// buckets are only created by a Put of a valid JSON value.
+package datapoolgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/radamsa/datapool"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative datapool.proto
+
+// ServerOption configures a server returned by NewServer.
+type ServerOption func(*server)
+
+// WithServerChunkSize sets the size of the chunks the server splits large
+// values into on GetChunks and Watch streams. The default is
+// datapool.DefaultChunkSize.
+func WithServerChunkSize(n int) ServerOption {
+	return func(s *server) {
+		s.chunkSize = n
+	}
+}
+
+// DefaultMaxValueSize is the largest value the server reassembles from the
+// chunks of PutChunks unless WithMaxValueSize says otherwise.
+const DefaultMaxValueSize = 32 << 20
+
+// WithMaxValueSize sets the largest value, in bytes of JSON, the server
+// reassembles from the chunks of PutChunks; larger ones fail with
+// ResourceExhausted. Values sent whole are bound by gRPC's message size
+// limit instead. The default is DefaultMaxValueSize.
+func WithMaxValueSize(n int) ServerOption {
+	return func(s *server) {
+		s.maxValueSize = n
+	}
+}
+
+// NewServer returns a DataPoolServer serving pool. Register it on a
+// grpc.Server with RegisterDataPoolServer.
+func NewServer(pool *datapool.DataPool, opts ...ServerOption) DataPoolServer {
+	s := &server{pool: pool, chunkSize: datapool.DefaultChunkSize, maxValueSize: DefaultMaxValueSize}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+type server struct {
+	UnimplementedDataPoolServer
+	pool         *datapool.DataPool
+	chunkSize    int
+	maxValueSize int
+}
+
+// bucket returns the named bucket, creating it if needed, or an
+// InvalidArgument error if the pool rejects the name.
+func (s *server) bucket(name string) (datapool.Bucket, error) {
+	if err := s.pool.ValidateName(name); err != nil {
+		return datapool.Bucket{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return s.pool.Bucket(name), nil
+}
+
+// lookup returns the existing named bucket, or an InvalidArgument error if
+// the pool rejects the name and a NotFound error if there is no such bucket.
+func (s *server) lookup(name string) (datapool.Bucket, error) {
+	bucket, err := s.pool.Lookup(name)
+	if err != nil {
+		return datapool.Bucket{}, status.Error(putCode(err), err.Error())
+	}
+	return bucket, nil
+}
+
+func (s *server) Get(_ context.Context, req *GetRequest) (*GetResponse, error) {
+	bucket, err := s.lookup(req.GetBucket())
+	if err != nil {
+		return nil, err
+	}
+	value, ts, fresh := bucket.Get(req.GetSince())
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "encode value: %v", err)
+	}
+	return &GetResponse{Value: raw, Timestamp: ts, Fresh: fresh}, nil
+}
+
+func (s *server) Put(_ context.Context, req *PutRequest) (*PutResponse, error) {
+	var value any
+	if err := json.Unmarshal(req.GetValue(), &value); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "decode value: %v", err)
+	}
+
+	ts, err := s.put(req.GetBucket(), value)
+	if err != nil {
+		return nil, err
+	}
+	return &PutResponse{Timestamp: ts}, nil
+}
+
+// put writes value to the named bucket. Writes the pool rejects fail with
+// InvalidArgument if the bucket's validator rejects the value,
+// PermissionDenied if the bucket cannot be written, such as a system bucket,
+// and ResourceExhausted if the value is too large.
+func (s *server) put(name string, value any) (int64, error) {
+	if strings.HasPrefix(name, datapool.SystemNamespace) {
+		return 0, status.Error(codes.PermissionDenied, fmt.Errorf("%w: %q", datapool.ErrSystemBucket, name).Error())
+	}
+	bucket, err := s.bucket(name)
+	if err != nil {
+		return 0, err
+	}
+	ts, err := bucket.PutE(value)
+	if err != nil {
+		return 0, status.Error(putCode(err), err.Error())
+	}
+	return ts, nil
+}
+
+// putCode returns the code of a write, or a lookup, failing with err.
+func putCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, datapool.ErrInvalidValue), errors.Is(err, datapool.ErrInvalidName):
+		return codes.InvalidArgument
+	case errors.Is(err, datapool.ErrSealed):
+		return codes.PermissionDenied
+	case errors.Is(err, datapool.ErrTooLarge):
+		return codes.ResourceExhausted
+	case errors.Is(err, datapool.ErrNotFound):
+		return codes.NotFound
+	default:
+		return codes.Internal
+	}
+}
+
+func (s *server) GetChunks(req *GetRequest, stream grpc.ServerStreamingServer[GetResponse]) error {
+	bucket, err := s.lookup(req.GetBucket())
+	if err != nil {
+		return err
+	}
+	value, ts, fresh := bucket.Get(req.GetSince())
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return status.Errorf(codes.Internal, "encode value: %v", err)
+	}
+	return sendChunks(raw, s.chunkSize, func(data []byte, c *Chunk) error {
+		return stream.Send(&GetResponse{Value: data, Timestamp: ts, Fresh: fresh, Chunk: c})
+	})
+}
+
+func (s *server) PutChunks(stream grpc.ClientStreamingServer[PutRequest, PutResponse]) error {
+	var name string
+	var chunks reassembler
+	size := 0
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return status.Error(codes.InvalidArgument, "incomplete value")
+		}
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			name = req.GetBucket()
+		}
+		if size += len(req.GetValue()); size > s.maxValueSize {
+			return status.Errorf(codes.ResourceExhausted, "value of %q larger than %d bytes", name, s.maxValueSize)
+		}
+
+		value, done, err := chunks.add(req)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "decode value: %v", err)
+		}
+		if done {
+			ts, err := s.put(name, value)
+			if err != nil {
+				return err
+			}
+			return stream.SendAndClose(&PutResponse{Timestamp: ts})
+		}
+	}
+}
+
+func (s *server) Watch(req *WatchRequest, stream grpc.ServerStreamingServer[Update]) error {
+	bucket, err := s.lookup(req.GetBucket())
+	if err != nil {
+		return err
+	}
+	updates := bucket.Watch(stream.Context())
+
+	// The watch is registered before the headers are sent, so a client that
+	// has received them will not miss any later update.
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for u := range updates {
+		raw, err := json.Marshal(u.Value)
+		if err != nil {
+			raw, _ = json.Marshal(fmt.Sprintf("%v", u.Value))
+		}
+		err = sendChunks(raw, s.chunkSize, func(data []byte, c *Chunk) error {
+			return stream.Send(&Update{Bucket: u.Bucket, Value: data, Timestamp: u.Timestamp, Chunk: c})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return stream.Context().Err()
+}
@@ -0,0 +1,109 @@
+package datapoolgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/radamsa/datapool"
+)
+
+func TestServerGetAndPut(t *testing.T) {
+	pool := datapool.NewDataPool()
+	srv := NewServer(pool)
+	ctx := context.Background()
+
+	put, err := srv.Put(ctx, &PutRequest{Bucket: "users/42", Value: []byte(`{"name":"Alice"}`)})
+	require.NoError(t, err)
+
+	get, err := srv.Get(ctx, &GetRequest{Bucket: "users/42"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"Alice"}`, string(get.Value))
+	assert.Equal(t, put.Timestamp, get.Timestamp)
+	assert.True(t, get.Fresh)
+
+	get, err = srv.Get(ctx, &GetRequest{Bucket: "users/42", Since: put.Timestamp})
+	require.NoError(t, err)
+	assert.False(t, get.Fresh)
+
+	bucket := pool.Bucket("users/42")
+	val, _, _ := bucket.Get(0)
+	assert.Equal(t, map[string]any{"name": "Alice"}, val)
+
+	pool.Bucket("empty")
+	get, err = srv.Get(ctx, &GetRequest{Bucket: "empty"})
+	require.NoError(t, err)
+	assert.Equal(t, "null", string(get.Value))
+	assert.Zero(t, get.Timestamp)
+
+	_, err = srv.Get(ctx, &GetRequest{Bucket: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, 2, pool.Len(), "Gets create no buckets")
+}
+
+func TestServerBadRequests(t *testing.T) {
+	pool := datapool.NewDataPool()
+	srv := NewServer(pool)
+
+	_, err := srv.Put(context.Background(), &PutRequest{Bucket: "config", Value: []byte("{")})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	config := pool.Bucket("config")
+	config.Put(func() {})
+	_, err = srv.Get(context.Background(), &GetRequest{Bucket: "config"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestServerInvalidNames(t *testing.T) {
+	pool := datapool.NewDataPool(datapool.WithNameRules(datapool.NameRules{MaxLength: 8}))
+	srv := NewServer(pool)
+	name := "much-too-long"
+
+	_, err := srv.Get(context.Background(), &GetRequest{Bucket: name})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = srv.Put(context.Background(), &PutRequest{Bucket: name, Value: []byte(`"v"`)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	client := New(dial(t, srv))
+	_, _, _, err = client.Bucket(name).GetContext(context.Background(), 0)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "GetChunks rejects the name")
+	assert.Zero(t, pool.Len())
+}
+
+func TestServerRejectedPuts(t *testing.T) {
+	pool := datapool.NewDataPool()
+	srv := NewServer(pool)
+	ctx := context.Background()
+	config := pool.Bucket("config")
+	config.SetValidator(func(value any) error {
+		if _, ok := value.(map[string]any); !ok {
+			return errors.New("config must be an object")
+		}
+		return nil
+	})
+
+	_, err := srv.Put(ctx, &PutRequest{Bucket: "config", Value: []byte(`"v"`)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "config must be an object")
+	val, ts, _ := config.Get(0)
+	assert.Nil(t, val)
+	assert.Zero(t, ts)
+
+	for _, name := range []string{datapool.SystemStatsBucket, datapool.SystemNamespace + "other"} {
+		_, err = srv.Put(ctx, &PutRequest{Bucket: name, Value: []byte(`"forged"`)})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err), name)
+	}
+
+	client := New(dial(t, srv), WithChunkSize(1))
+	_, err = client.Bucket("config").PutContext(ctx, "v")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "PutChunks rejects the value")
+
+	assert.Equal(t, codes.ResourceExhausted, putCode(fmt.Errorf("name: %w", datapool.ErrTooLarge)))
+	assert.Equal(t, codes.NotFound, putCode(datapool.ErrBucketNotFound))
+}
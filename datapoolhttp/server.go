@@ -0,0 +1,280 @@
+// Package datapoolhttp serves a datapool.DataPool over HTTP so other processes
+// can read, write and watch its buckets, typically through datapoolclient.
+//
+// Values travel as JSON. The API is:
+//
+//	GET  /v1/buckets                 list buckets
+//	GET  /v1/buckets/{name}?since=ts read a bucket
+//	PUT  /v1/buckets/{name}          write a bucket, the body is the JSON value
+//	GET  /v1/watch/{name}            stream updates as server-sent events
+//	POST /v1/snapshot                compact the pool's write-ahead log
+//
+// POST /v1/snapshot writes the snapshot file set with WithSnapshotFile for
+// pools without a write-ahead log, and fails with 501 if there is neither.
+//
+// A GET or watch of a bucket that does not exist fails with 404, without
+// creating it; buckets are only created by a PUT of a valid JSON value. A PUT
+// the pool rejects fails with 400 if the bucket's validator rejects the value,
+// 403 if the bucket cannot be written, such as a system bucket, and 413 if the
+// value is too large.
+//
+// Bucket names are a single path segment; clients escape slashes in names as
+// %2F, although unescaped slashes are accepted too.
+package datapoolhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/radamsa/datapool"
+)
+
+// MaxValueSize is the largest request body accepted by PUT.
+const MaxValueSize = 32 << 20
+
+// BucketInfo is an entry of the bucket listing.
+type BucketInfo struct {
+	Name      string `json:"name"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// ListResponse is the body of GET /v1/buckets.
+type ListResponse struct {
+	Buckets []BucketInfo `json:"buckets"`
+}
+
+// GetResponse is the body of GET /v1/buckets/{name}.
+type GetResponse struct {
+	Value     json.RawMessage `json:"value"`
+	Timestamp int64           `json:"timestamp"`
+	Fresh     bool            `json:"fresh"`
+}
+
+// PutResponse is the body of PUT /v1/buckets/{name}.
+type PutResponse struct {
+	Timestamp int64 `json:"timestamp"`
+}
+
+// SnapshotResponse is the body of POST /v1/snapshot.
+type SnapshotResponse struct {
+	// Buckets is the number of buckets in the pool when the snapshot was
+	// taken.
+	Buckets int `json:"buckets"`
+}
+
+// Event is the data of each server-sent event of GET /v1/watch/{name}.
+type Event struct {
+	Bucket    string          `json:"bucket"`
+	Value     json.RawMessage `json:"value"`
+	Timestamp int64           `json:"timestamp"`
+}
+
+// ErrorResponse is the body of every failed request.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Option configures the handler returned by NewHandler.
+type Option func(*server)
+
+// WithSnapshotFile makes POST /v1/snapshot write the pool's values to a
+// snapshot file at path, encoded with opts (see
+// datapool.DataPool.WriteSnapshotFile), when the pool has no write-ahead log,
+// for a later pool to restore them from with datapool.WithSnapshotFallback.
+// The file is rewritten from the values the pool holds, so it should not be
+// the one the pool itself falls back to: values the pool has not restored yet
+// would be left out.
+func WithSnapshotFile(path string, opts ...datapool.WALOption) Option {
+	return func(s *server) {
+		s.snapshotPath = path
+		s.snapshotOpts = opts
+	}
+}
+
+// NewHandler returns an http.Handler serving pool.
+func NewHandler(pool *datapool.DataPool, opts ...Option) http.Handler {
+	s := &server{pool: pool}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/buckets", s.list)
+	mux.HandleFunc("GET /v1/buckets/{name...}", s.get)
+	mux.HandleFunc("PUT /v1/buckets/{name...}", s.put)
+	mux.HandleFunc("GET /v1/watch/{name...}", s.watch)
+	mux.HandleFunc("POST /v1/snapshot", s.snapshot)
+	return mux
+}
+
+type server struct {
+	pool         *datapool.DataPool
+	snapshotPath string
+	snapshotOpts []datapool.WALOption
+}
+
+// lookup returns the existing bucket named by the request path, or writes a
+// 400 response if the pool rejects the name and a 404 if there is no such
+// bucket.
+func (s *server) lookup(w http.ResponseWriter, r *http.Request) (datapool.Bucket, bool) {
+	bucket, err := s.pool.Lookup(r.PathValue("name"))
+	if err != nil {
+		writeError(w, putStatus(err), err)
+		return datapool.Bucket{}, false
+	}
+	return bucket, true
+}
+
+func (s *server) list(w http.ResponseWriter, r *http.Request) {
+	infos := s.pool.Inspect()
+	resp := ListResponse{Buckets: make([]BucketInfo, 0, len(infos))}
+	for _, info := range infos {
+		resp.Buckets = append(resp.Buckets, BucketInfo{Name: info.Name, Timestamp: info.Timestamp})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *server) get(w http.ResponseWriter, r *http.Request) {
+	var since int64
+	if v := r.URL.Query().Get("since"); v != "" {
+		var err error
+		if since, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid since: %w", err))
+			return
+		}
+	}
+
+	bucket, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	value, ts, fresh := bucket.Get(since)
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("encode value: %w", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, GetResponse{Value: raw, Timestamp: ts, Fresh: fresh})
+}
+
+func (s *server) put(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := s.pool.ValidateName(name); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxValueSize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decode value: %w", err))
+		return
+	}
+
+	if strings.HasPrefix(name, datapool.SystemNamespace) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("%w: %q", datapool.ErrSystemBucket, name))
+		return
+	}
+	bucket := s.pool.Bucket(name)
+	ts, err := bucket.PutE(value)
+	if err != nil {
+		writeError(w, putStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, PutResponse{Timestamp: ts})
+}
+
+// putStatus returns the status of a PUT failing with err: 400 for values the
+// bucket's validator rejects, 403 for buckets that cannot be written, such as
+// system buckets, and 413 for values beyond a size limit. Lookups failing
+// with err get the same status, 404 for buckets that do not exist.
+func putStatus(err error) int {
+	switch {
+	case errors.Is(err, datapool.ErrInvalidValue), errors.Is(err, datapool.ErrInvalidName):
+		return http.StatusBadRequest
+	case errors.Is(err, datapool.ErrSealed):
+		return http.StatusForbidden
+	case errors.Is(err, datapool.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, datapool.ErrNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (s *server) watch(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	bucket, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	updates := bucket.Watch(r.Context())
+
+	// The watch is registered before the headers are sent, so a client that
+	// has received them will not miss any later update.
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for u := range updates {
+		raw, err := json.Marshal(u.Value)
+		if err != nil {
+			raw, _ = json.Marshal(fmt.Sprintf("%v", u.Value))
+		}
+		data, _ := json.Marshal(Event{Bucket: u.Bucket, Value: raw, Timestamp: u.Timestamp})
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// snapshot rewrites the pool's write-ahead log with its current values (see
+// datapool.DataPool.CompactWAL), or writes them to the snapshot file of
+// WithSnapshotFile for a pool without a log.
+func (s *server) snapshot(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch {
+	case s.pool.HasWAL():
+		err = s.pool.CompactWAL()
+	case s.snapshotPath != "":
+		err = s.pool.WriteSnapshotFile(s.snapshotPath, s.snapshotOpts...)
+	default:
+		writeError(w, http.StatusNotImplemented, errors.New("the pool has no write-ahead log nor snapshot file"))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, SnapshotResponse{Buckets: s.pool.Len()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
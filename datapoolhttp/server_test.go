@@ -0,0 +1,178 @@
+package datapoolhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radamsa/datapool"
+)
+
+func serve(t *testing.T, h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestList(t *testing.T) {
+	pool := datapool.NewDataPool()
+	config := pool.Bucket("config")
+	ts := config.Put("v")
+	pool.Bucket("users/42")
+
+	rec := serve(t, NewHandler(pool), http.MethodGet, "/v1/buckets", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var resp ListResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, []BucketInfo{{Name: "config", Timestamp: ts}, {Name: "users/42"}}, resp.Buckets)
+}
+
+func TestGetAndPut(t *testing.T) {
+	pool := datapool.NewDataPool()
+	h := NewHandler(pool)
+
+	rec := serve(t, h, http.MethodPut, "/v1/buckets/users%2F42", `{"name":"Alice"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var put PutResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &put))
+
+	rec = serve(t, h, http.MethodGet, "/v1/buckets/users%2F42?since=0", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var get GetResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &get))
+	assert.JSONEq(t, `{"name":"Alice"}`, string(get.Value))
+	assert.Equal(t, put.Timestamp, get.Timestamp)
+	assert.True(t, get.Fresh)
+
+	bucket := pool.Bucket("users/42")
+	val, _, _ := bucket.Get(0)
+	assert.Equal(t, map[string]any{"name": "Alice"}, val)
+}
+
+func TestBadRequests(t *testing.T) {
+	pool := datapool.NewDataPool()
+	h := NewHandler(pool)
+
+	rec := serve(t, h, http.MethodGet, "/v1/buckets/config?since=yesterday", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "invalid since")
+
+	rec = serve(t, h, http.MethodPut, "/v1/buckets/config", `{not json`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "decode value")
+
+	fn := pool.Bucket("func")
+	fn.Put(func() {})
+	rec = serve(t, h, http.MethodGet, "/v1/buckets/func", "")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	rec = serve(t, h, http.MethodDelete, "/v1/buckets/config", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestMissingBuckets(t *testing.T) {
+	pool := datapool.NewDataPool()
+	h := NewHandler(pool)
+
+	rec := serve(t, h, http.MethodGet, "/v1/buckets/missing", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = serve(t, h, http.MethodGet, "/v1/watch/missing", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = serve(t, h, http.MethodPut, "/v1/buckets/missing", `{not json`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Zero(t, pool.Len(), "Reads and rejected PUTs create no buckets")
+}
+
+func TestInvalidNames(t *testing.T) {
+	pool := datapool.NewDataPool(datapool.WithNameRules(datapool.NameRules{ReservedPrefixes: []string{"internal/"}}))
+	h := NewHandler(pool)
+
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		rec := serve(t, h, method, "/v1/buckets/internal/secret", `"v"`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, method)
+		assert.Contains(t, rec.Body.String(), "reserved", method)
+	}
+	rec := serve(t, h, http.MethodGet, "/v1/watch/internal/secret", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Zero(t, pool.Len())
+}
+
+func TestRejectedPuts(t *testing.T) {
+	pool := datapool.NewDataPool()
+	h := NewHandler(pool)
+	config := pool.Bucket("config")
+	config.SetValidator(func(value any) error {
+		if _, ok := value.(map[string]any); !ok {
+			return errors.New("config must be an object")
+		}
+		return nil
+	})
+
+	rec := serve(t, h, http.MethodPut, "/v1/buckets/config", `"v"`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "config must be an object")
+	val, ts, _ := config.Get(0)
+	assert.Nil(t, val)
+	assert.Zero(t, ts)
+
+	for _, name := range []string{datapool.SystemStatsBucket, datapool.SystemNamespace + "other"} {
+		rec = serve(t, h, http.MethodPut, "/v1/buckets/"+url.PathEscape(name), `"forged"`)
+		assert.Equal(t, http.StatusForbidden, rec.Code, name)
+		assert.Contains(t, rec.Body.String(), "system buckets are read-only", name)
+	}
+
+	assert.Equal(t, http.StatusForbidden, putStatus(datapool.ErrSealed))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, putStatus(fmt.Errorf("name: %w", datapool.ErrTooLarge)))
+	assert.Equal(t, http.StatusNotFound, putStatus(datapool.ErrBucketNotFound))
+}
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+	wal, err := datapool.OpenWAL(path)
+	require.NoError(t, err)
+	pool := datapool.NewDataPool(datapool.WithWAL(wal))
+	config := pool.Bucket("config")
+	for i := range 3 {
+		config.Put(i)
+	}
+
+	rec := serve(t, NewHandler(pool), http.MethodPost, "/v1/snapshot", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp SnapshotResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.Buckets)
+	require.NoError(t, wal.Close())
+
+	wal, err = datapool.OpenWAL(path)
+	require.NoError(t, err)
+	defer wal.Close()
+	assert.Equal(t, 1, wal.Report().Records, "The log holds the current values only")
+
+	rec = serve(t, NewHandler(datapool.NewDataPool()), http.MethodPost, "/v1/snapshot", "")
+	assert.Equal(t, http.StatusNotImplemented, rec.Code, "Pools without a log nor snapshot file cannot take snapshots")
+}
+
+func TestSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.snapshot")
+	pool := datapool.NewDataPool()
+	pool.Handle("config").Put("value")
+
+	rec := serve(t, NewHandler(pool, WithSnapshotFile(path)), http.MethodPost, "/v1/snapshot", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	restored := datapool.NewDataPool(datapool.WithSnapshotFallback(path))
+	value, _, _ := restored.Handle("config").Get(0)
+	assert.Equal(t, "value", value)
+}
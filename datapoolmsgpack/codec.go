@@ -0,0 +1,22 @@
+// Package datapoolmsgpack provides a MessagePack datapool.Codec, for storing
+// values in encoded form more compactly than JSON:
+//
+//	pool := datapool.NewDataPool(datapool.WithCodec(datapoolmsgpack.Codec))
+//	bucket := pool.Bucket("users/42")
+//	_, err := bucket.PutEncoded(user)
+package datapoolmsgpack
+
+import (
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/radamsa/datapool"
+)
+
+// Codec encodes values with MessagePack.
+var Codec datapool.Codec = codec{}
+
+type codec struct{}
+
+func (codec) Name() string                       { return "msgpack" }
+func (codec) Marshal(v any) ([]byte, error)      { return msgpack.Marshal(v) }
+func (codec) Unmarshal(data []byte, v any) error { return msgpack.Unmarshal(data, v) }
@@ -0,0 +1,34 @@
+package datapoolmsgpack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radamsa/datapool"
+)
+
+type user struct {
+	Name  string
+	Roles []string
+}
+
+func TestCodec(t *testing.T) {
+	pool := datapool.NewDataPool(datapool.WithCodec(Codec))
+	b := pool.Bucket("users/42")
+
+	in := user{Name: "Alice", Roles: []string{"admin"}}
+	ts, err := b.PutEncoded(in)
+	require.NoError(t, err)
+
+	val, _, _ := b.Get(0)
+	require.IsType(t, datapool.Encoded{}, val)
+	assert.Equal(t, "msgpack", val.(datapool.Encoded).Codec.Name())
+
+	var out user
+	got, err := b.GetDecoded(&out)
+	require.NoError(t, err)
+	assert.Equal(t, ts, got)
+	assert.Equal(t, in, out)
+}
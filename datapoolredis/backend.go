@@ -0,0 +1,193 @@
+// Package datapoolredis implements a datapool.Backend on Redis, so pools in
+// several processes share the same buckets:
+//
+//	rdb := redis.NewClient(&redis.Options{Addr: "redis:6379"})
+//	pool := datapool.NewDataPool(datapool.WithBackend(datapoolredis.New(rdb)))
+//	go pool.SyncBackend(ctx)
+//
+// Each bucket is stored as a hash holding its JSON-encoded value, its
+// timestamp, its JSON-encoded provenance, its schema version and its version,
+// and every stored value is published to a channel that SyncBackend
+// subscribes to.
+package datapoolredis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/radamsa/datapool"
+)
+
+// DefaultPrefix is the prefix of the keys and the channel used by default.
+const DefaultPrefix = "datapool:"
+
+// putScript stores a value unless the key holds a newer or equal timestamp,
+// and publishes it. Timestamps are compared as decimal strings, since Lua
+// numbers cannot represent every int64.
+var putScript = redis.NewScript(`
+local cur = redis.call('HGET', KEYS[1], 'ts')
+if cur and (#cur > #ARGV[2] or (#cur == #ARGV[2] and cur >= ARGV[2])) then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'v', ARGV[1], 'ts', ARGV[2], 'p', ARGV[3], 's', ARGV[6], 'n', ARGV[7], 'f', ARGV[8])
+redis.call('PUBLISH', ARGV[4], ARGV[5])
+return 1
+`)
+
+// Backend is a datapool.Backend storing buckets in Redis. Values are encoded
+// as JSON, so they read back as the types encoding/json decodes into an any
+// (for example, numbers become float64 and structs become maps).
+type Backend struct {
+	client  redis.UniversalClient
+	prefix  string
+	channel string
+}
+
+var _ datapool.Backend = (*Backend)(nil)
+
+// Option configures a Backend.
+type Option func(*Backend)
+
+// WithPrefix sets the prefix of the bucket keys and of the update channel,
+// letting several pools share one Redis database. The default is
+// DefaultPrefix.
+func WithPrefix(prefix string) Option {
+	return func(b *Backend) {
+		b.prefix = prefix
+	}
+}
+
+// New returns a backend using client. The client stays owned by the caller.
+func New(client redis.UniversalClient, opts ...Option) *Backend {
+	b := &Backend{client: client, prefix: DefaultPrefix}
+	for _, opt := range opts {
+		opt(b)
+	}
+	b.channel = b.prefix + "updates"
+	return b
+}
+
+// message is published for every stored value.
+type message struct {
+	Bucket     string            `json:"bucket"`
+	Value      json.RawMessage   `json:"value"`
+	Timestamp  int64             `json:"timestamp"`
+	Provenance []datapool.Source `json:"provenance,omitempty"`
+	Schema     int               `json:"schema,omitempty"`
+	Version    uint64            `json:"version,omitempty"`
+	Fence      uint64            `json:"fence,omitempty"`
+}
+
+// Get implements datapool.Backend.
+func (b *Backend) Get(ctx context.Context, name string) (datapool.Update, error) {
+	fields, err := b.client.HMGet(ctx, b.prefix+name, "v", "ts", "p", "s", "n", "f").Result()
+	if err != nil {
+		return datapool.Update{}, fmt.Errorf("datapoolredis: get %q: %w", name, err)
+	}
+
+	raw, _ := fields[0].(string)
+	tsField, _ := fields[1].(string)
+	chain, _ := fields[2].(string)
+	schema, _ := fields[3].(string)
+	version, _ := fields[4].(string)
+	fence, _ := fields[5].(string)
+	if tsField == "" {
+		return datapool.Update{}, nil
+	}
+	u := datapool.Update{Bucket: name}
+	if u.Timestamp, err = strconv.ParseInt(tsField, 10, 64); err != nil {
+		return datapool.Update{}, fmt.Errorf("datapoolredis: get %q: bad timestamp %q", name, tsField)
+	}
+	if err := json.Unmarshal([]byte(raw), &u.Value); err != nil {
+		return datapool.Update{}, fmt.Errorf("datapoolredis: get %q: decode value: %w", name, err)
+	}
+	// Hashes written before provenance was stored have none.
+	if chain != "" {
+		if err := json.Unmarshal([]byte(chain), &u.Provenance); err != nil {
+			return datapool.Update{}, fmt.Errorf("datapoolredis: get %q: decode provenance: %w", name, err)
+		}
+	}
+	// So do hashes written before schema versions were stored.
+	if schema != "" {
+		if u.Schema, err = strconv.Atoi(schema); err != nil {
+			return datapool.Update{}, fmt.Errorf("datapoolredis: get %q: bad schema version %q", name, schema)
+		}
+	}
+	// And before versions were.
+	if version != "" {
+		if u.Version, err = strconv.ParseUint(version, 10, 64); err != nil {
+			return datapool.Update{}, fmt.Errorf("datapoolredis: get %q: bad version %q", name, version)
+		}
+	}
+	// And before fencing tokens were.
+	if fence != "" {
+		if u.Fence, err = strconv.ParseUint(fence, 10, 64); err != nil {
+			return datapool.Update{}, fmt.Errorf("datapoolredis: get %q: bad fencing token %q", name, fence)
+		}
+	}
+	return u, nil
+}
+
+// Put implements datapool.Backend.
+func (b *Backend) Put(ctx context.Context, u datapool.Update) error {
+	raw, err := json.Marshal(u.Value)
+	if err != nil {
+		return fmt.Errorf("datapoolredis: put %q: encode value: %w", u.Bucket, err)
+	}
+	chain, err := json.Marshal(u.Provenance)
+	if err != nil {
+		return fmt.Errorf("datapoolredis: put %q: encode provenance: %w", u.Bucket, err)
+	}
+	msg, err := json.Marshal(message{Bucket: u.Bucket, Value: raw, Timestamp: u.Timestamp, Provenance: u.Provenance, Schema: u.Schema, Version: u.Version, Fence: u.Fence})
+	if err != nil {
+		return fmt.Errorf("datapoolredis: put %q: %w", u.Bucket, err)
+	}
+
+	keys := []string{b.prefix + u.Bucket}
+	args := []any{raw, strconv.FormatInt(u.Timestamp, 10), chain, b.channel, msg, strconv.Itoa(u.Schema), strconv.FormatUint(u.Version, 10), strconv.FormatUint(u.Fence, 10)}
+	if err := putScript.Run(ctx, b.client, keys, args...).Err(); err != nil {
+		return fmt.Errorf("datapoolredis: put %q: %w", u.Bucket, err)
+	}
+	return nil
+}
+
+// Watch implements datapool.Backend. It subscribes before returning any
+// update, so values stored once the subscription is confirmed are not missed.
+func (b *Backend) Watch(ctx context.Context, fn func(datapool.Update)) error {
+	sub := b.client.Subscribe(ctx, b.channel)
+	defer sub.Close()
+	// Receiving does not watch ctx; closing the subscription interrupts it.
+	stop := context.AfterFunc(ctx, func() { sub.Close() })
+	defer stop()
+
+	if _, err := sub.Receive(ctx); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("datapoolredis: subscribe: %w", err)
+	}
+
+	for {
+		received, err := sub.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("datapoolredis: watch: %w", err)
+		}
+
+		var msg message
+		if err := json.Unmarshal([]byte(received.Payload), &msg); err != nil {
+			return fmt.Errorf("datapoolredis: watch: bad message: %w", err)
+		}
+		var value any
+		if err := json.Unmarshal(msg.Value, &value); err != nil {
+			return fmt.Errorf("datapoolredis: watch: %q: decode value: %w", msg.Bucket, err)
+		}
+		fn(datapool.Update{Bucket: msg.Bucket, Value: value, Timestamp: msg.Timestamp, Provenance: msg.Provenance, Schema: msg.Schema, Version: msg.Version, Fence: msg.Fence})
+	}
+}
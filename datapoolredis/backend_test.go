@@ -0,0 +1,137 @@
+package datapoolredis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radamsa/datapool"
+)
+
+func newTestBackend(t *testing.T, opts ...Option) (*miniredis.Miniredis, *Backend) {
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return srv, New(client, opts...)
+}
+
+func TestGetPut(t *testing.T) {
+	srv, b := newTestBackend(t)
+	ctx := context.Background()
+
+	u, err := b.Get(ctx, "config")
+	require.NoError(t, err)
+	assert.Zero(t, u)
+
+	chain := []datapool.Source{
+		{Kind: datapool.SourceLoader, Name: "settings", At: time.Unix(1700000000, 0).UTC()},
+		{Kind: datapool.SourceReplica, Name: "host-a", At: time.Unix(1700000001, 0).UTC()},
+	}
+	stored := datapool.Update{
+		Bucket:     "config",
+		Value:      map[string]any{"theme": "dark"},
+		Timestamp:  1700000000000000000,
+		Provenance: chain,
+		Schema:     3,
+		Version:    7,
+		Fence:      2,
+	}
+	require.NoError(t, b.Put(ctx, stored))
+	u, err = b.Get(ctx, "config")
+	require.NoError(t, err)
+	assert.Equal(t, stored, u)
+
+	assert.Equal(t, `{"theme":"dark"}`, srv.HGet("datapool:config", "v"))
+}
+
+func TestGetWithoutProvenance(t *testing.T) {
+	srv, b := newTestBackend(t)
+	srv.HSet("datapool:config", "v", `"dark"`, "ts", "5")
+
+	u, err := b.Get(context.Background(), "config")
+	require.NoError(t, err)
+	assert.Equal(t, datapool.Update{Bucket: "config", Value: "dark", Timestamp: 5}, u)
+}
+
+func TestPutKeepsNewest(t *testing.T) {
+	_, b := newTestBackend(t)
+	ctx := context.Background()
+
+	put := func(value string, ts int64) {
+		require.NoError(t, b.Put(ctx, datapool.Update{Bucket: "config", Value: value, Timestamp: ts}))
+	}
+	put("new", 1700000000000000002)
+	put("old", 1700000000000000001)
+	put("same", 1700000000000000002)
+	// Shorter decimal strings are smaller numbers.
+	put("short", 999)
+
+	u, err := b.Get(ctx, "config")
+	require.NoError(t, err)
+	assert.Equal(t, "new", u.Value)
+	assert.Equal(t, int64(1700000000000000002), u.Timestamp)
+}
+
+func TestWatch(t *testing.T) {
+	srv, b := newTestBackend(t, WithPrefix("app:"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := make(chan datapool.Update, 4)
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Watch(ctx, func(u datapool.Update) { updates <- u })
+	}()
+	require.Eventually(t, func() bool {
+		return srv.PubSubNumSub("app:updates")["app:updates"] == 1
+	}, time.Second, time.Millisecond)
+
+	chain := []datapool.Source{{Kind: datapool.SourceReplica, Name: "host-a", At: time.Unix(1, 0).UTC()}}
+	require.NoError(t, b.Put(context.Background(), datapool.Update{Bucket: "config", Value: "v1", Timestamp: 2, Provenance: chain, Schema: 2, Version: 4, Fence: 1}))
+	select {
+	case u := <-updates:
+		assert.Equal(t, datapool.Update{Bucket: "config", Value: "v1", Timestamp: 2, Provenance: chain, Schema: 2, Version: 4, Fence: 1}, u)
+	case <-time.After(time.Second):
+		require.Fail(t, "No update received")
+	}
+
+	// Puts that lose to a newer value are not published.
+	require.NoError(t, b.Put(context.Background(), datapool.Update{Bucket: "config", Value: "old", Timestamp: 1}))
+	select {
+	case u := <-updates:
+		assert.Fail(t, "Unexpected update", "%v", u)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestPoolsShareBuckets(t *testing.T) {
+	_, backend := newTestBackend(t)
+	a := datapool.NewDataPool(datapool.WithBackend(backend))
+	b := datapool.NewDataPool(datapool.WithBackend(backend))
+
+	ba := a.Bucket("rates/EURUSD")
+	ts := ba.Put(1.08)
+
+	bb := b.Bucket("rates/EURUSD")
+	val, got, fresh := bb.Get(0)
+	assert.Equal(t, 1.08, val)
+	assert.Equal(t, ts, got)
+	assert.True(t, fresh)
+}
+
+func TestUnreachable(t *testing.T) {
+	srv, b := newTestBackend(t)
+	srv.Close()
+
+	_, err := b.Get(context.Background(), "config")
+	assert.ErrorContains(t, err, `datapoolredis: get "config"`)
+	assert.Error(t, b.Put(context.Background(), datapool.Update{Bucket: "config", Value: 1, Timestamp: 1}))
+	assert.Error(t, b.Watch(context.Background(), func(datapool.Update) {}))
+}
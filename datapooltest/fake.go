@@ -0,0 +1,255 @@
+// Package datapooltest provides a programmable fake datapool.Pool for testing
+// code that depends on a pool, without the timing of a real one.
+package datapooltest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/radamsa/datapool"
+)
+
+// Op identifies a method called on a fake bucket.
+type Op string
+
+// Operations recorded in Call.Op.
+const (
+	OpGet   Op = "get"
+	OpPut   Op = "put"
+	OpWatch Op = "watch"
+)
+
+// Call records one method call on a fake bucket.
+type Call struct {
+	Op     Op
+	Bucket string
+	// Timestamp is the argument of a Get, or the timestamp returned by a Put.
+	Timestamp int64
+	// Value is the argument of a Put.
+	Value any
+}
+
+// Freshness controls how a fake bucket reports freshness from Get.
+type Freshness int
+
+const (
+	// ByTimestamp reports values newer than the comparison timestamp as
+	// fresh, like a real pool.
+	ByTimestamp Freshness = iota
+	// AlwaysFresh reports every non-empty value as fresh.
+	AlwaysFresh
+	// NeverFresh reports every value as stale.
+	NeverFresh
+)
+
+// watchBuffer is the capacity of Watch channels. Like a real pool, the fake
+// drops the oldest pending update when a watcher falls further behind.
+const watchBuffer = 16
+
+// Pool is a fake datapool.Pool. Its buckets store values like a real pool
+// but use a simple counter for timestamps, can be scripted and record every
+// call. The zero Pool is not usable; create one with NewPool.
+type Pool struct {
+	mu      sync.Mutex
+	buckets map[string]*Bucket
+	calls   []Call
+	lastTS  int64
+}
+
+var _ datapool.Pool = (*Pool)(nil)
+
+// NewPool returns an empty fake pool.
+func NewPool() *Pool {
+	return &Pool{buckets: make(map[string]*Bucket)}
+}
+
+// Handle returns Bucket(name).
+func (p *Pool) Handle(name string) datapool.Handle {
+	return p.Bucket(name)
+}
+
+// Bucket returns the named fake bucket, creating it if needed.
+func (p *Pool) Bucket(name string) *Bucket {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b, ok := p.buckets[name]
+	if !ok {
+		b = &Bucket{pool: p, name: name}
+		p.buckets[name] = b
+	}
+	return b
+}
+
+// Calls returns all calls made on the pool's buckets, in order.
+func (p *Pool) Calls() []Call {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Call(nil), p.calls...)
+}
+
+// Reset forgets all recorded calls.
+func (p *Pool) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = nil
+}
+
+// Bucket is a fake datapool.Handle. Its methods are safe for concurrent use.
+type Bucket struct {
+	pool *Pool
+	name string
+
+	// Guarded by pool.mu.
+	value     any
+	timestamp int64
+	script    []datapool.Result
+	freshness Freshness
+	rejectPut bool
+	watchers  []chan datapool.Update
+}
+
+var _ datapool.Handle = (*Bucket)(nil)
+
+// Name returns the bucket's name.
+func (b *Bucket) Name() string {
+	return b.name
+}
+
+// Get returns the next scripted result if there is one, and otherwise the
+// stored value with freshness as configured by SetFreshness.
+func (b *Bucket) Get(timestamp int64) (any, int64, bool) {
+	p := b.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls = append(p.calls, Call{Op: OpGet, Bucket: b.name, Timestamp: timestamp})
+
+	if len(b.script) > 0 {
+		r := b.script[0]
+		b.script = b.script[1:]
+		return r.Value, r.Timestamp, r.Fresh
+	}
+
+	if b.timestamp == 0 {
+		return nil, 0, false
+	}
+	var fresh bool
+	switch b.freshness {
+	case ByTimestamp:
+		fresh = b.timestamp > timestamp
+	case AlwaysFresh:
+		fresh = true
+	}
+	return b.value, b.timestamp, fresh
+}
+
+// Put stores value under the next timestamp of the pool and delivers it to
+// watchers. After RejectPuts(true) it stores nothing and returns 0 instead.
+func (b *Bucket) Put(value any) int64 {
+	p := b.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var ts int64
+	if !b.rejectPut {
+		p.lastTS++
+		ts = p.lastTS
+		b.store(value, ts)
+	}
+	p.calls = append(p.calls, Call{Op: OpPut, Bucket: b.name, Timestamp: ts, Value: value})
+	return ts
+}
+
+// Watch returns a channel receiving an Update for every subsequent Put and
+// Set, closed when ctx is done.
+func (b *Bucket) Watch(ctx context.Context) <-chan datapool.Update {
+	p := b.pool
+	ch := make(chan datapool.Update, watchBuffer)
+
+	p.mu.Lock()
+	p.calls = append(p.calls, Call{Op: OpWatch, Bucket: b.name})
+	b.watchers = append(b.watchers, ch)
+	p.mu.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for i, w := range b.watchers {
+			if w == ch {
+				b.watchers = append(b.watchers[:i], b.watchers[i+1:]...)
+				close(ch)
+				return
+			}
+		}
+	})
+	return ch
+}
+
+// Set stores value with the given timestamp without recording a call, as if
+// another writer had put it. Watchers receive the update. Timestamps later
+// assigned by Put continue after the largest one set.
+func (b *Bucket) Set(value any, timestamp int64) {
+	p := b.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lastTS = max(p.lastTS, timestamp)
+	b.store(value, timestamp)
+}
+
+// Script queues results returned by the following calls to Get, one per
+// call, before Get falls back to the stored value.
+func (b *Bucket) Script(results ...datapool.Result) {
+	b.pool.mu.Lock()
+	defer b.pool.mu.Unlock()
+	b.script = append(b.script, results...)
+}
+
+// SetFreshness sets how Get reports the freshness of the stored value.
+func (b *Bucket) SetFreshness(f Freshness) {
+	b.pool.mu.Lock()
+	defer b.pool.mu.Unlock()
+	b.freshness = f
+}
+
+// RejectPuts makes subsequent Puts fail as on an evicted bucket, storing
+// nothing and returning 0, until it is called with false.
+func (b *Bucket) RejectPuts(reject bool) {
+	b.pool.mu.Lock()
+	defer b.pool.mu.Unlock()
+	b.rejectPut = reject
+}
+
+// Calls returns the calls made on this bucket, in order.
+func (b *Bucket) Calls() []Call {
+	var calls []Call
+	for _, c := range b.pool.Calls() {
+		if c.Bucket == b.name {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// store sets the bucket's value and notifies watchers. It must be called with
+// pool.mu held.
+func (b *Bucket) store(value any, ts int64) {
+	b.value = value
+	b.timestamp = ts
+
+	u := datapool.Update{Bucket: b.name, Value: value, Timestamp: ts}
+	for _, ch := range b.watchers {
+		select {
+		case ch <- u:
+			continue
+		default:
+		}
+		// Senders hold pool.mu, so after taking one update out there is room.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- u
+	}
+}
@@ -0,0 +1,153 @@
+package datapooltest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radamsa/datapool"
+)
+
+// latestConfig is the kind of code the fake is meant to test.
+func latestConfig(pool datapool.Pool, since int64) (any, bool) {
+	value, _, fresh := pool.Handle("config").Get(since)
+	return value, fresh
+}
+
+func TestPutGet(t *testing.T) {
+	pool := NewPool()
+	b := pool.Handle("config")
+	assert.Equal(t, "config", b.Name())
+
+	val, ts, fresh := b.Get(0)
+	assert.Nil(t, val)
+	assert.Zero(t, ts)
+	assert.False(t, fresh)
+
+	ts1 := b.Put("v1")
+	ts2 := b.Put("v2")
+	assert.Equal(t, int64(1), ts1)
+	assert.Equal(t, int64(2), ts2)
+
+	val, ts, fresh = b.Get(ts1)
+	assert.Equal(t, "v2", val)
+	assert.Equal(t, ts2, ts)
+	assert.True(t, fresh)
+
+	_, _, fresh = b.Get(ts2)
+	assert.False(t, fresh)
+}
+
+func TestScript(t *testing.T) {
+	pool := NewPool()
+	b := pool.Bucket("config")
+	b.Set("stored", 100)
+	b.Script(
+		datapool.Result{},
+		datapool.Result{Value: "scripted", Timestamp: 7, Fresh: true},
+	)
+
+	val, ok := latestConfig(pool, 0)
+	assert.Nil(t, val)
+	assert.False(t, ok)
+
+	val, ok = latestConfig(pool, 0)
+	assert.Equal(t, "scripted", val)
+	assert.True(t, ok)
+
+	val, ts, _ := b.Get(0)
+	assert.Equal(t, "stored", val, "Get falls back to the stored value once the script is used up")
+	assert.Equal(t, int64(100), ts)
+
+	assert.Equal(t, int64(101), b.Put("next"), "Put continues after Set timestamps")
+}
+
+func TestSetFreshness(t *testing.T) {
+	pool := NewPool()
+	b := pool.Bucket("config")
+	ts := b.Put(1)
+
+	b.SetFreshness(AlwaysFresh)
+	_, _, fresh := b.Get(ts + 10)
+	assert.True(t, fresh)
+
+	b.SetFreshness(NeverFresh)
+	_, _, fresh = b.Get(0)
+	assert.False(t, fresh)
+
+	b.SetFreshness(ByTimestamp)
+	_, _, fresh = b.Get(ts - 1)
+	assert.True(t, fresh)
+}
+
+func TestRejectPuts(t *testing.T) {
+	pool := NewPool()
+	b := pool.Bucket("config")
+
+	b.RejectPuts(true)
+	assert.Zero(t, b.Put(1))
+	val, _, _ := b.Get(0)
+	assert.Nil(t, val)
+
+	b.RejectPuts(false)
+	assert.NotZero(t, b.Put(1))
+}
+
+func TestCalls(t *testing.T) {
+	pool := NewPool()
+	config := pool.Bucket("config")
+	other := pool.Bucket("other")
+
+	ts := config.Put("v1")
+	config.Get(5)
+	other.Put("x")
+	config.Set("ignored", 50)
+
+	assert.Equal(t, []Call{
+		{Op: OpPut, Bucket: "config", Timestamp: ts, Value: "v1"},
+		{Op: OpGet, Bucket: "config", Timestamp: 5},
+	}, config.Calls())
+	assert.Len(t, pool.Calls(), 3)
+
+	pool.Reset()
+	assert.Empty(t, pool.Calls())
+}
+
+func TestWatch(t *testing.T) {
+	pool := NewPool()
+	b := pool.Bucket("config")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := b.Watch(ctx)
+
+	ts := b.Put("v1")
+	b.Set("v2", 42)
+
+	assert.Equal(t, datapool.Update{Bucket: "config", Value: "v1", Timestamp: ts}, <-updates)
+	assert.Equal(t, datapool.Update{Bucket: "config", Value: "v2", Timestamp: 42}, <-updates)
+
+	cancel()
+	select {
+	case _, ok := <-updates:
+		assert.False(t, ok, "Channel should be closed")
+	case <-time.After(time.Second):
+		require.Fail(t, "Watch channel not closed after cancel")
+	}
+	assert.Equal(t, OpWatch, b.Calls()[0].Op)
+}
+
+func TestWatchSlowReceiver(t *testing.T) {
+	pool := NewPool()
+	b := pool.Bucket("config")
+	updates := b.Watch(context.Background())
+
+	for i := 0; i < watchBuffer+5; i++ {
+		b.Put(i)
+	}
+
+	first := <-updates
+	assert.Equal(t, 5, first.Value, "Oldest updates are dropped")
+}
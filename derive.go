@@ -0,0 +1,361 @@
+package datapool
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultDeriveTimeout bounds derive functions not given a timeout with
+// WithDeriveTimeout.
+const defaultDeriveTimeout = 30 * time.Second
+
+// ErrDeriveTimeout is reported when a derive function runs longer than its
+// timeout (see WithDeriveTimeout).
+var ErrDeriveTimeout = errors.New("datapool: derive timed out")
+
+// CycleError is returned by Derive when a derivation would make a bucket
+// depend, directly or not, on itself.
+type CycleError struct {
+	// Cycle lists the buckets of the cycle in the order updates flow, each
+	// derived from the one before it; the first and last are the same.
+	Cycle []string
+}
+
+func (e *CycleError) Error() string {
+	return "datapool: derived bucket cycle: " + strings.Join(e.Cycle, " -> ")
+}
+
+// DeriveFunc computes the value of a derived bucket from the current values of
+// its dependencies, given in the order they were passed to Derive. Empty
+// dependencies are passed as nil.
+type DeriveFunc func(values []any) (any, error)
+
+// DeriveOption configures a derived bucket.
+type DeriveOption func(*derivation)
+
+// WithFreeze freezes reads of the derived bucket while it is recomputed: Get
+// keeps returning the previous value but reports it as stale, so readers do
+// not take a value that lags behind its dependencies for a fresh one.
+// Without it, reads during a recomputation see the previous value with its
+// usual freshness.
+func WithFreeze() DeriveOption {
+	return func(d *derivation) {
+		d.freeze = true
+	}
+}
+
+// WithBatchWindow delays recomputations by d after a dependency update, so
+// that a burst of updates arriving within the window is recomputed once. The
+// window is measured in real time, not on the pool's clock.
+func WithBatchWindow(d time.Duration) DeriveOption {
+	return func(dv *derivation) {
+		dv.window = max(d, 0)
+	}
+}
+
+// WithDeriveTimeout bounds each run of the derive function. A run that takes
+// longer fails with ErrDeriveTimeout and its result, should it arrive later,
+// is dropped. The default is 30s; zero or less disables the timeout.
+func WithDeriveTimeout(d time.Duration) DeriveOption {
+	return func(dv *derivation) {
+		dv.timeout = d
+	}
+}
+
+// derivation keeps a derived bucket up to date with its dependencies.
+// Recomputations run on their own goroutine, one at a time per derived
+// bucket; dependency updates arriving during a run or its batch window
+// schedule one more.
+type derivation struct {
+	pool    *DataPool
+	name    string
+	deps    []string
+	fn      DeriveFunc
+	freeze  bool
+	window  time.Duration
+	timeout time.Duration
+
+	mu      sync.Mutex
+	running bool
+	pending bool
+	err     error
+}
+
+// derivedGraph indexes the derived buckets of a pool. It is copied on write,
+// so Put can look up dependents without locking.
+type derivedGraph struct {
+	byName map[string]*derivation
+	byDep  map[string][]*derivation
+}
+
+// Derive makes the bucket named name a derived bucket: whenever one of the
+// buckets named in deps is written, fn recomputes its value from theirs,
+// which is stored with a SourceTransform provenance step. It is first
+// computed right away, or after the batch window (see WithBatchWindow).
+//
+// When fn fails, times out or panics, the error is passed to the pool's error
+// handler and returned by DeriveError, and the previous value stays in place
+// but reads report it as stale until a recomputation succeeds.
+//
+// Derive fails if the bucket is already derived or in the SystemNamespace, if
+// the pool's NameRules reject its name or one of deps, and with a *CycleError if it would depend
+// on itself through its dependencies.
+func (p *DataPool) Derive(name string, deps []string, fn DeriveFunc, opts ...DeriveOption) (Bucket, error) {
+	if len(deps) == 0 {
+		return Bucket{}, fmt.Errorf("datapool: derived bucket %q has no dependencies", name)
+	}
+	if err := p.checkWritable(name); err != nil {
+		return Bucket{}, err
+	}
+	for _, dep := range deps {
+		if err := p.ValidateName(dep); err != nil {
+			return Bucket{}, err
+		}
+	}
+	d := &derivation{pool: p, name: name, deps: slices.Clone(deps), fn: fn, timeout: defaultDeriveTimeout}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	p.deriveMu.Lock()
+	old := p.derived.Load()
+	if old == nil {
+		old = &derivedGraph{}
+	}
+	if _, ok := old.byName[name]; ok {
+		p.deriveMu.Unlock()
+		return Bucket{}, fmt.Errorf("datapool: bucket %q is already derived", name)
+	}
+	if cycle := old.cycle(name, d.deps); cycle != nil {
+		p.deriveMu.Unlock()
+		return Bucket{}, &CycleError{Cycle: cycle}
+	}
+	graph := &derivedGraph{
+		byName: make(map[string]*derivation, len(old.byName)+1),
+		byDep:  make(map[string][]*derivation, len(old.byDep)+len(d.deps)),
+	}
+	for n, other := range old.byName {
+		graph.byName[n] = other
+	}
+	for dep, dependents := range old.byDep {
+		graph.byDep[dep] = dependents
+	}
+	graph.byName[name] = d
+	for i, dep := range d.deps {
+		if slices.Index(d.deps, dep) == i {
+			graph.byDep[dep] = append(slices.Clip(graph.byDep[dep]), d)
+		}
+	}
+	p.derived.Store(graph)
+	p.deriveMu.Unlock()
+
+	b := p.Bucket(name)
+	d.trigger()
+	return b, nil
+}
+
+// cycle returns the cycle that deriving name from deps would close, or nil.
+// Updates flow from a bucket to the buckets derived from it, so there is a
+// cycle if updates of name already reach one of deps.
+func (g *derivedGraph) cycle(name string, deps []string) []string {
+	if slices.Contains(deps, name) {
+		return []string{name, name}
+	}
+
+	visited := map[string]bool{name: true}
+	path := []string{name}
+	var walk func(from string) bool
+	walk = func(from string) bool {
+		for _, d := range g.byDep[from] {
+			if visited[d.name] {
+				continue
+			}
+			visited[d.name] = true
+			path = append(path, d.name)
+			if slices.Contains(deps, d.name) || walk(d.name) {
+				return true
+			}
+			path = path[:len(path)-1]
+		}
+		return false
+	}
+	if walk(name) {
+		return append(path, name)
+	}
+	return nil
+}
+
+// triggerDerived schedules the recomputation of the buckets derived from the
+// bucket named name.
+func (p *DataPool) triggerDerived(name string) {
+	graph := p.derived.Load()
+	if graph == nil {
+		return
+	}
+	for _, d := range graph.byDep[name] {
+		d.trigger()
+	}
+}
+
+// trigger schedules a recomputation, starting a run unless one is going.
+func (d *derivation) trigger() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pending = true
+	if d.running {
+		return
+	}
+	d.running = true
+	d.updateFrozen()
+	d.start()
+}
+
+// start runs the next recomputation once the batch window has passed, in the
+// background so that Close waits for it; Close also cuts the window short. It
+// must be called with d.mu held.
+func (d *derivation) start() {
+	p := d.pool
+	started := p.goBackground(func() {
+		if d.window > 0 {
+			timer := time.NewTimer(d.window)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-p.done:
+			}
+		}
+		d.run()
+	})
+	if !started {
+		d.running = false
+	}
+}
+
+// run recomputes the derived bucket, then starts again if dependencies were
+// updated in the meantime. Updates arriving before it clears pending are
+// covered by this recomputation.
+func (d *derivation) run() {
+	d.mu.Lock()
+	d.pending = false
+	d.mu.Unlock()
+
+	err := d.recompute()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.err = err
+	if d.pending {
+		d.start()
+		return
+	}
+	d.running = false
+	d.updateFrozen()
+}
+
+// recompute stores the value of fn for the current values of the
+// dependencies, or reports and returns its error.
+func (d *derivation) recompute() error {
+	p := d.pool
+	if p.isClosed() {
+		return nil
+	}
+	values := make([]any, len(d.deps))
+	for i, dep := range d.deps {
+		b := p.Bucket(dep)
+		values[i], _, _ = b.Get(0)
+	}
+
+	value, err := d.call(values)
+	if err != nil {
+		if errors.Is(err, ErrDeriveTimeout) {
+			err = fmt.Errorf("%w: %q after %v", ErrDeriveTimeout, d.name, d.timeout)
+		} else {
+			err = fmt.Errorf("datapool: derive %q: %w", d.name, err)
+		}
+		p.reportError(d.name, err)
+		return err
+	}
+	if p.isClosed() {
+		return nil
+	}
+	b := p.Bucket(d.name)
+	b.PutFrom(value, Source{Kind: SourceTransform, Name: d.name, At: p.opts.clock.Now()})
+	return nil
+}
+
+// call runs fn within the derivation's timeout, returning panics as errors.
+func (d *derivation) call(values []any) (any, error) {
+	if d.timeout <= 0 {
+		return d.callSafely(values)
+	}
+
+	type result struct {
+		value any
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := d.callSafely(values)
+		done <- result{value, err}
+	}()
+
+	timer := time.NewTimer(d.timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-timer.C:
+		return nil, ErrDeriveTimeout
+	}
+}
+
+func (d *derivation) callSafely(values []any) (value any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return d.fn(values)
+}
+
+// updateFrozen makes reads of the derived bucket report it as stale while the
+// last recomputation failed, or under WithFreeze while one is going, and
+// thaws it otherwise. It must be called with d.mu held.
+func (d *derivation) updateFrozen() {
+	frozen := d.err != nil || (d.freeze && d.running)
+	b, err := d.pool.bucket(d.name)
+	if err != nil {
+		// The pool is closed, or the bucket's tenant refuses it.
+		return
+	}
+	b.guard.Lock()
+	b.frozen = frozen
+	b.forgetRead()
+	b.guard.Unlock()
+}
+
+// DeriveError returns the error of the last recomputation of a derived
+// bucket, or nil if it succeeded or the bucket is not derived.
+func (b *Bucket) DeriveError() error {
+	bk := b.resolve("derive error")
+	if bk == nil {
+		return nil
+	}
+	graph := b.pool.derived.Load()
+	if graph == nil {
+		return nil
+	}
+	d, ok := graph.byName[bk.name()]
+	if !ok {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
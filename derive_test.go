@@ -0,0 +1,301 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDerive(t *testing.T) {
+	pool := NewDataPool()
+	price := pool.Bucket("price")
+	qty := pool.Bucket("qty")
+	price.Put(2.0)
+
+	total, err := pool.Derive("total", []string{"price", "qty"}, func(values []any) (any, error) {
+		p, _ := values[0].(float64)
+		q, _ := values[1].(float64)
+		return p * q, nil
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := total.Watch(ctx)
+
+	require.Eventually(t, func() bool {
+		val, _, _ := total.Get(0)
+		return val == 0.0
+	}, time.Second, time.Millisecond, "Derived buckets are computed at once")
+
+	qty.Put(3.0)
+	for u := range updates {
+		if u.Value == 6.0 {
+			break
+		}
+	}
+
+	chain := total.Provenance()
+	require.Len(t, chain, 1)
+	assert.Equal(t, SourceTransform, chain[0].Kind)
+	assert.Equal(t, "total", chain[0].Name)
+}
+
+func TestDeriveChained(t *testing.T) {
+	pool := NewDataPool()
+	double := func(values []any) (any, error) {
+		n, _ := values[0].(int)
+		return 2 * n, nil
+	}
+	_, err := pool.Derive("b", []string{"a"}, double)
+	require.NoError(t, err)
+	c, err := pool.Derive("c", []string{"b"}, double)
+	require.NoError(t, err)
+
+	a := pool.Bucket("a")
+	a.Put(1)
+	require.Eventually(t, func() bool {
+		val, _, _ := c.Get(0)
+		return val == 4
+	}, time.Second, time.Millisecond)
+}
+
+func TestDeriveErrors(t *testing.T) {
+	pool := NewDataPool()
+	identity := func(values []any) (any, error) { return values[0], nil }
+
+	_, err := pool.Derive("a", nil, identity)
+	assert.ErrorContains(t, err, "no dependencies")
+	_, err = pool.Derive("a", []string{"b", "a"}, identity)
+	var cycle *CycleError
+	require.ErrorAs(t, err, &cycle)
+	assert.Equal(t, []string{"a", "a"}, cycle.Cycle)
+
+	_, err = pool.Derive("a", []string{"b"}, identity)
+	require.NoError(t, err)
+	_, err = pool.Derive("a", []string{"c"}, identity)
+	assert.ErrorContains(t, err, "already derived")
+}
+
+func TestDeriveFuncError(t *testing.T) {
+	errBad := errors.New("bad input")
+	var mu sync.Mutex
+	var reported []error
+	pool := NewDataPool(WithErrorHandler(func(bucket string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "out", bucket)
+		reported = append(reported, err)
+	}))
+
+	in := pool.Bucket("in")
+	in.Put(1)
+	out, err := pool.Derive("out", []string{"in"}, func(values []any) (any, error) {
+		if values[0] == 2 {
+			return nil, errBad
+		}
+		return values[0], nil
+	})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		val, _, _ := out.Get(0)
+		return val == 1
+	}, time.Second, time.Millisecond)
+
+	in.Put(2)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reported) == 1
+	}, time.Second, time.Millisecond)
+	assert.ErrorIs(t, reported[0], errBad)
+	val, _, _ := out.Get(0)
+	assert.Equal(t, 1, val, "Failed recomputations keep the previous value")
+}
+
+func TestDeriveFreeze(t *testing.T) {
+	for _, freeze := range []bool{false, true} {
+		pool := NewDataPool()
+		in := pool.Bucket("in")
+		in.Put(1)
+
+		release := make(chan struct{})
+		var opts []DeriveOption
+		if freeze {
+			opts = append(opts, WithFreeze())
+		}
+		out, err := pool.Derive("out", []string{"in"}, func(values []any) (any, error) {
+			if values[0] == 2 {
+				<-release
+			}
+			return values[0], nil
+		}, opts...)
+		require.NoError(t, err)
+
+		var ts int64
+		require.Eventually(t, func() bool {
+			var val any
+			val, ts, _ = out.Get(0)
+			return val == 1
+		}, time.Second, time.Millisecond)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		updates := out.Watch(ctx)
+
+		in.Put(2)
+		val, got, fresh := out.Get(ts - 1)
+		assert.Equal(t, 1, val, "Reads during a recomputation see the previous value")
+		assert.Equal(t, ts, got)
+		assert.Equal(t, !freeze, fresh, "Frozen reads are flagged stale")
+
+		close(release)
+		assert.Equal(t, 2, receive(t, updates).Value)
+		require.Eventually(t, func() bool {
+			_, _, fresh := out.Get(ts)
+			return fresh
+		}, time.Second, time.Millisecond, "The bucket thaws once recomputed")
+		cancel()
+	}
+}
+
+func TestDeriveCycle(t *testing.T) {
+	pool := NewDataPool()
+	identity := func(values []any) (any, error) { return values[0], nil }
+
+	_, err := pool.Derive("b", []string{"a"}, identity)
+	require.NoError(t, err)
+	_, err = pool.Derive("c", []string{"b", "x"}, identity)
+	require.NoError(t, err)
+	_, err = pool.Derive("d", []string{"x"}, identity)
+	require.NoError(t, err)
+
+	_, err = pool.Derive("a", []string{"d", "c"}, identity)
+	var cycle *CycleError
+	require.ErrorAs(t, err, &cycle)
+	assert.Equal(t, []string{"a", "b", "c", "a"}, cycle.Cycle)
+	assert.EqualError(t, err, "datapool: derived bucket cycle: a -> b -> c -> a")
+
+	// The failed registration leaves the graph unchanged.
+	_, err = pool.Derive("a", []string{"d"}, identity)
+	assert.NoError(t, err)
+}
+
+func TestDeriveBatchWindow(t *testing.T) {
+	pool := NewDataPool()
+	in := pool.Bucket("in")
+	var calls atomic.Int32
+	out, err := pool.Derive("out", []string{"in"}, func(values []any) (any, error) {
+		calls.Add(1)
+		return values[0], nil
+	}, WithBatchWindow(50*time.Millisecond))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	for i := 1; i <= 5; i++ {
+		in.Put(i)
+	}
+	require.Eventually(t, func() bool {
+		val, _, _ := out.Get(0)
+		return val == 5
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int32(2), calls.Load(), "A burst of updates is recomputed once")
+}
+
+func TestDeriveFailureFlagsStale(t *testing.T) {
+	pool := NewDataPool()
+	in := pool.Bucket("in")
+	in.Put(1)
+	out, err := pool.Derive("out", []string{"in"}, func(values []any) (any, error) {
+		switch values[0] {
+		case 2:
+			return nil, errors.New("bad input")
+		case 3:
+			panic("boom")
+		}
+		return values[0], nil
+	})
+	require.NoError(t, err)
+	var ts int64
+	require.Eventually(t, func() bool {
+		var val any
+		val, ts, _ = out.Get(0)
+		return val == 1
+	}, time.Second, time.Millisecond)
+	assert.NoError(t, out.DeriveError())
+
+	in.Put(2)
+	require.Eventually(t, func() bool { return out.DeriveError() != nil }, time.Second, time.Millisecond)
+	assert.ErrorContains(t, out.DeriveError(), `datapool: derive "out": bad input`)
+	val, got, fresh := out.Get(ts - 1)
+	assert.Equal(t, 1, val, "The previous value is kept")
+	assert.Equal(t, ts, got)
+	assert.False(t, fresh, "It is flagged stale")
+
+	in.Put(3)
+	require.Eventually(t, func() bool {
+		err := out.DeriveError()
+		return err != nil && err.Error() == `datapool: derive "out": panic: boom`
+	}, time.Second, time.Millisecond, "Panics are reported as errors")
+
+	in.Put(4)
+	require.Eventually(t, func() bool {
+		_, _, fresh := out.Get(ts)
+		return fresh
+	}, time.Second, time.Millisecond, "A successful recomputation clears the flag")
+	assert.NoError(t, out.DeriveError())
+
+	plain := pool.Bucket("plain")
+	assert.NoError(t, plain.DeriveError())
+}
+
+func TestDeriveTimeout(t *testing.T) {
+	var mu sync.Mutex
+	var reported []error
+	pool := NewDataPool(WithErrorHandler(func(bucket string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, err)
+	}))
+
+	in := pool.Bucket("in")
+	in.Put(1)
+	hang := make(chan struct{})
+	defer close(hang)
+	out, err := pool.Derive("out", []string{"in"}, func(values []any) (any, error) {
+		if values[0] == 2 {
+			<-hang
+		}
+		return values[0], nil
+	}, WithFreeze(), WithDeriveTimeout(20*time.Millisecond))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		val, _, _ := out.Get(0)
+		return val == 1
+	}, time.Second, time.Millisecond)
+
+	in.Put(2)
+	require.Eventually(t, func() bool { return out.DeriveError() != nil }, time.Second, time.Millisecond)
+	assert.ErrorIs(t, out.DeriveError(), ErrDeriveTimeout)
+	assert.EqualError(t, out.DeriveError(), `datapool: derive timed out: "out" after 20ms`)
+
+	mu.Lock()
+	require.Len(t, reported, 1)
+	assert.ErrorIs(t, reported[0], ErrDeriveTimeout)
+	mu.Unlock()
+
+	val, _, fresh := out.Get(0)
+	assert.Equal(t, 1, val)
+	assert.False(t, fresh)
+
+	in.Put(5)
+	require.Eventually(t, func() bool {
+		val, _, fresh := out.Get(0)
+		return val == 5 && fresh
+	}, time.Second, time.Millisecond, "A hung run does not block later ones")
+}
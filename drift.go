@@ -0,0 +1,121 @@
+package datapool
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrClockDrift is wrapped by the errors reported when a peer's clock drifts
+// from the pool's by more than the threshold set with WithDriftDetection.
+var ErrClockDrift = errors.New("datapool: clock drift")
+
+// DriftRecorder is implemented by MetricsRecorders that track the clock
+// drift of peers. RecordClockDrift is called, outside of pool locks, with a
+// peer's estimated drift (see DataPool.PeerDrift) for every value received
+// from it.
+type DriftRecorder interface {
+	RecordClockDrift(peer string, drift time.Duration)
+}
+
+// driftSamples is the number of recent offsets a peer's drift is estimated
+// from.
+const driftSamples = 32
+
+// driftDetector estimates the clock drift of the peers a pool receives
+// values from.
+type driftDetector struct {
+	threshold time.Duration
+
+	mu    sync.Mutex
+	peers map[string]*peerDrift
+}
+
+// peerDrift holds the recent offsets between a peer's send times and the
+// local receive times of its values.
+type peerDrift struct {
+	offsets [driftSamples]int64
+	n       int
+	next    int
+	flagged bool
+}
+
+// estimate returns the largest recent offset. Every offset is the peer's
+// drift minus the value's transit time, which is never negative, so the
+// largest one is the closest to the drift.
+func (d *peerDrift) estimate() time.Duration {
+	return time.Duration(slices.Max(d.offsets[:d.n]))
+}
+
+func newDriftDetector(threshold time.Duration) *driftDetector {
+	return &driftDetector{threshold: threshold, peers: make(map[string]*peerDrift)}
+}
+
+// observeDrift records the offset between the time u was sent to the
+// backend by its peer, its last SourceReplica step, and now, reporting an
+// error wrapping ErrClockDrift when the peer's estimated drift crosses the
+// threshold: at once for peers ahead, and only once driftSamples offsets
+// agree for peers behind. Values the pool wrote itself are ignored.
+func (p *DataPool) observeDrift(u Update) {
+	d := p.drift
+	if d == nil || len(u.Provenance) == 0 {
+		return
+	}
+	src := u.Provenance[len(u.Provenance)-1]
+	if src.Kind != SourceReplica || src.Name == p.opts.peerName {
+		return
+	}
+	offset := src.At.UnixNano() - p.now()
+
+	d.mu.Lock()
+	peer := d.peers[src.Name]
+	if peer == nil {
+		peer = &peerDrift{}
+		d.peers[src.Name] = peer
+	}
+	peer.offsets[peer.next] = offset
+	peer.next = (peer.next + 1) % driftSamples
+	peer.n = min(peer.n+1, driftSamples)
+	drift := peer.estimate()
+	// A single late value can make a peer seem behind, but not ahead.
+	over := drift > d.threshold || drift < -d.threshold && peer.n == driftSamples
+	report := over && !peer.flagged
+	peer.flagged = over
+	d.mu.Unlock()
+
+	if report {
+		p.reportError(u.Bucket, fmt.Errorf("%w: peer %q is %v %s", ErrClockDrift, src.Name, drift.Abs(), driftDirection(drift)))
+	}
+	if r, ok := p.opts.metrics.(DriftRecorder); ok {
+		r.RecordClockDrift(src.Name, drift)
+	}
+}
+
+func driftDirection(drift time.Duration) string {
+	if drift > 0 {
+		return "ahead"
+	}
+	return "behind"
+}
+
+// PeerDrift returns the estimated clock drift of every peer the pool has
+// received values from through SyncBackend, by peer name (see WithPeerName),
+// or nil without drift detection. A positive drift is a peer whose clock is
+// ahead of the pool's.
+func (p *DataPool) PeerDrift() map[string]time.Duration {
+	d := p.drift
+	if d == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	drifts := make(map[string]time.Duration, len(d.peers))
+	for name, peer := range d.peers {
+		drifts[name] = peer.estimate()
+	}
+	return drifts
+}
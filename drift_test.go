@@ -0,0 +1,109 @@
+package datapool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type driftRecorder struct {
+	*recordingMetrics
+	mu     sync.Mutex
+	drifts map[string]time.Duration
+}
+
+func (r *driftRecorder) RecordClockDrift(peer string, drift time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.drifts[peer] = drift
+}
+
+func TestDriftDetection(t *testing.T) {
+	backend := &MemoryBackend{}
+	start := time.Unix(1000, 0)
+	ahead := NewManualClock(start.Add(10 * time.Second))
+	local := NewManualClock(start)
+
+	var mu sync.Mutex
+	var reported []error
+	recorder := &driftRecorder{recordingMetrics: newRecordingMetrics(), drifts: map[string]time.Duration{}}
+	a := NewDataPool(WithBackend(backend), WithClock(ahead), WithPeerName("a"))
+	b := NewDataPool(WithBackend(backend), WithClock(local), WithPeerName("b"),
+		WithDriftDetection(time.Second), WithMetrics(recorder),
+		WithErrorHandler(func(_ string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported = append(reported, err)
+		}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.SyncBackend(ctx)
+	require.Eventually(t, func() bool {
+		backend.mu.Lock()
+		defer backend.mu.Unlock()
+		return len(backend.watchers) == 1
+	}, time.Second, time.Millisecond)
+
+	ba := a.Bucket("config")
+	ba.Put("v1")
+	ba.Put("v2")
+
+	assert.Equal(t, map[string]time.Duration{"a": 10 * time.Second}, b.PeerDrift())
+	mu.Lock()
+	require.Len(t, reported, 1, "Drift is reported once while it lasts")
+	assert.ErrorIs(t, reported[0], ErrClockDrift)
+	assert.EqualError(t, reported[0], `datapool: clock drift: peer "a" is 10s ahead`)
+	mu.Unlock()
+	recorder.mu.Lock()
+	assert.Equal(t, 10*time.Second, recorder.drifts["a"])
+	recorder.mu.Unlock()
+
+	bb := b.Bucket("config")
+	bb.Put("local")
+	assert.NotContains(t, b.PeerDrift(), "b", "Values the pool wrote itself are ignored")
+}
+
+func TestDriftEstimate(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	var reported []error
+	pool := NewDataPool(WithClock(clock), WithPeerName("local"), WithDriftDetection(time.Second),
+		WithErrorHandler(func(_ string, err error) { reported = append(reported, err) }))
+	replica := func(at time.Time) Update {
+		return Update{Bucket: "x", Provenance: []Source{{Kind: SourcePut}, {Kind: SourceReplica, Name: "peer", At: at}}}
+	}
+
+	now := clock.Now()
+	pool.observeDrift(replica(now.Add(-3 * time.Second)))
+	pool.observeDrift(replica(now.Add(-100 * time.Millisecond)))
+	pool.observeDrift(replica(now.Add(-2 * time.Second)))
+	assert.Equal(t, -100*time.Millisecond, pool.PeerDrift()["peer"], "The fastest delivery estimates the drift")
+	assert.Empty(t, reported, "A few late values are not drift")
+
+	for range driftSamples {
+		pool.observeDrift(replica(now.Add(-5 * time.Second)))
+	}
+	assert.Equal(t, -5*time.Second, pool.PeerDrift()["peer"])
+	require.Len(t, reported, 1)
+	assert.ErrorIs(t, reported[0], ErrClockDrift)
+	assert.Contains(t, reported[0].Error(), `peer "peer" is`)
+	assert.Contains(t, reported[0].Error(), "behind")
+
+	pool.observeDrift(replica(now))
+	pool.observeDrift(replica(now.Add(-5 * time.Second)))
+	assert.Zero(t, pool.PeerDrift()["peer"])
+	for range driftSamples {
+		pool.observeDrift(replica(now.Add(-5 * time.Second)))
+	}
+	assert.Len(t, reported, 2, "Drift is reported again after it recovered")
+
+	pool.observeDrift(Update{Bucket: "x"})
+	pool.observeDrift(Update{Bucket: "x", Provenance: []Source{{Kind: SourcePut, At: now.Add(time.Hour)}}})
+	assert.Len(t, pool.PeerDrift(), 1, "Values without a replica step are ignored")
+
+	assert.Nil(t, NewDataPool().PeerDrift())
+}
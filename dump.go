@@ -0,0 +1,135 @@
+package datapool
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// Format selects the output format of DumpTo.
+type Format int
+
+const (
+	// FormatText renders one aligned, human-readable line per bucket.
+	FormatText Format = iota
+	// FormatJSON renders a single JSON document, suitable for debug endpoints.
+	FormatJSON
+)
+
+// String returns the name of the format.
+func (f Format) String() string {
+	switch f {
+	case FormatText:
+		return "text"
+	case FormatJSON:
+		return "json"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// BucketInfo describes the state of a single bucket at the time it was inspected.
+type BucketInfo struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Timestamp int64     `json:"timestamp"`
+	Updated   time.Time `json:"updated"`
+	Type      string    `json:"type"`
+	Value     any       `json:"-"`
+}
+
+// Inspect returns a description of every bucket in the pool, in creation order.
+// Each bucket is read under its own lock, so the result is consistent per bucket
+// but not across buckets.
+func (p *DataPool) Inspect() []BucketInfo {
+	buckets := p.all()
+
+	infos := make([]BucketInfo, 0, len(buckets))
+	for _, b := range buckets {
+		if b.system {
+			p.refreshSystem(b)
+		}
+		b.guard.RLock()
+		info := BucketInfo{
+			ID:   b.id,
+			Name: b.name(),
+		}
+		if !b.expiredAt(p) {
+			info.Timestamp = b.timestamp
+			info.Value = b.current()
+		}
+		b.guard.RUnlock()
+		if b.copyValues.Load() {
+			info.Value = copyValue(info.Value)
+		}
+
+		info.Type = fmt.Sprintf("%T", info.Value)
+
+		if info.Timestamp != 0 {
+			info.Updated = time.Unix(0, info.Timestamp).UTC()
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// DumpTo writes the state of every bucket to w in the given format.
+func (p *DataPool) DumpTo(w io.Writer, format Format) error {
+	infos := p.Inspect()
+
+	switch format {
+	case FormatText:
+		return dumpText(w, infos)
+	case FormatJSON:
+		return dumpJSON(w, infos)
+	default:
+		return fmt.Errorf("datapool: unsupported dump format %v", format)
+	}
+}
+
+func dumpText(w io.Writer, infos []BucketInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID\tNAME\tUPDATED\tTYPE\tVALUE\n")
+	for _, info := range infos {
+		updated := "never"
+		if info.Timestamp != 0 {
+			updated = info.Updated.Format(time.RFC3339Nano)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%v\n", info.ID, info.Name, updated, info.Type, info.Value)
+	}
+	return tw.Flush()
+}
+
+type jsonBucket struct {
+	BucketInfo
+	Updated *time.Time      `json:"updated,omitempty"`
+	Value   json.RawMessage `json:"value"`
+}
+
+func dumpJSON(w io.Writer, infos []BucketInfo) error {
+	out := struct {
+		Buckets []jsonBucket `json:"buckets"`
+	}{
+		Buckets: make([]jsonBucket, 0, len(infos)),
+	}
+
+	for _, info := range infos {
+		value, err := json.Marshal(info.Value)
+		if err != nil {
+			// Not every value is representable in JSON (channels, funcs, cyclic
+			// structures); fall back to its printed form.
+			value, _ = json.Marshal(fmt.Sprintf("%v", info.Value))
+		}
+		jb := jsonBucket{BucketInfo: info, Value: value}
+		if info.Timestamp != 0 {
+			jb.Updated = &info.Updated
+		}
+		out.Buckets = append(out.Buckets, jb)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,86 @@
+package datapool
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	pool := NewDataPool()
+	users := pool.Bucket("users")
+	pool.Bucket("empty")
+	ts := users.Put("user data")
+
+	infos := pool.Inspect()
+	require.Len(t, infos, 2)
+
+	assert.Equal(t, 0, infos[0].ID)
+	assert.Equal(t, "users", infos[0].Name)
+	assert.Equal(t, ts, infos[0].Timestamp)
+	assert.Equal(t, time.Unix(0, ts).UTC(), infos[0].Updated)
+	assert.Equal(t, "string", infos[0].Type)
+	assert.Equal(t, "user data", infos[0].Value)
+
+	assert.Equal(t, "empty", infos[1].Name)
+	assert.True(t, infos[1].Updated.IsZero(), "Empty bucket has no update time")
+	assert.Equal(t, "<nil>", infos[1].Type)
+}
+
+func TestDumpText(t *testing.T) {
+	pool := NewDataPool()
+	counter := pool.Bucket("counter")
+	pool.Bucket("empty")
+	ts := counter.Put(42)
+
+	var buf bytes.Buffer
+	require.NoError(t, pool.DumpTo(&buf, FormatText))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"ID", "NAME", "UPDATED", "TYPE", "VALUE"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"0", "counter", time.Unix(0, ts).UTC().Format(time.RFC3339Nano), "int", "42"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"1", "empty", "never", "<nil>", "<nil>"}, strings.Fields(lines[2]))
+}
+
+func TestDumpJSON(t *testing.T) {
+	pool := NewDataPool()
+	config := pool.Bucket("config")
+	pool.Bucket("empty")
+	fn := pool.Bucket("func")
+	fn.Put(func() {})
+	ts := config.Put(map[string]string{"theme": "dark"})
+
+	var buf bytes.Buffer
+	require.NoError(t, pool.DumpTo(&buf, FormatJSON))
+
+	var out struct {
+		Buckets []map[string]any `json:"buckets"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	require.Len(t, out.Buckets, 3)
+
+	assert.Equal(t, "config", out.Buckets[0]["name"])
+	assert.Equal(t, float64(ts), out.Buckets[0]["timestamp"])
+	assert.Equal(t, time.Unix(0, ts).UTC().Format(time.RFC3339Nano), out.Buckets[0]["updated"])
+	assert.Equal(t, "map[string]string", out.Buckets[0]["type"])
+	assert.Equal(t, map[string]any{"theme": "dark"}, out.Buckets[0]["value"])
+
+	assert.NotContains(t, out.Buckets[1], "updated", "Empty bucket has no update time")
+	assert.Nil(t, out.Buckets[1]["value"])
+
+	assert.Equal(t, "func()", out.Buckets[2]["type"])
+	assert.IsType(t, "", out.Buckets[2]["value"], "Unmarshalable values fall back to their printed form")
+}
+
+func TestDumpUnknownFormat(t *testing.T) {
+	pool := NewDataPool()
+
+	err := pool.DumpTo(&bytes.Buffer{}, Format(42))
+	assert.EqualError(t, err, "datapool: unsupported dump format Format(42)")
+}
@@ -0,0 +1,65 @@
+package datapool
+
+import "time"
+
+// entry is a bucket's state as read by Get, published in bucket.latest so
+// that Gets read it with a single atomic load instead of locking the bucket.
+// Entries are immutable; every change to the bucket replaces or drops its
+// entry.
+type entry struct {
+	value     any
+	timestamp int64
+	expiresAt int64
+	removed   bool
+	frozen    bool
+	loader    Loader
+	softTTL   time.Duration
+}
+
+// loadEntry returns the entry of b, reading the bucket under its read lock if
+// it changed since the entry was last published, or nil if Gets must read the
+// bucket itself. Values held in buffers from AllocBytes never get an entry,
+// since every Get returns a copy of them.
+func (p *DataPool) loadEntry(b *bucket) *entry {
+	if e := b.latest.Load(); e != nil && (e.expiresAt == 0 || p.now() < e.expiresAt) {
+		return e
+	}
+
+	b.guard.RLock()
+	defer b.guard.RUnlock()
+
+	return b.publish(p)
+}
+
+// publish stores and returns the entry of the bucket's current state, or nil
+// if it is held in a buffer from AllocBytes. It must be called with b.guard
+// held; the entry is published with the lock held, so it cannot overwrite the
+// forgetRead of a change made after it.
+func (b *bucket) publish(p *DataPool) *entry {
+	if b.shared != nil {
+		b.latest.Store(nil)
+		return nil
+	}
+	e := &entry{
+		value:     b.value,
+		timestamp: b.timestamp,
+		expiresAt: b.expiry(),
+		removed:   b.removed,
+		frozen:    b.frozen,
+		loader:    b.loader,
+		softTTL:   b.softTTL,
+	}
+	if b.expiredAt(p) {
+		e.value, e.timestamp, e.expiresAt = nil, 0, 0
+	}
+	b.latest.Store(e)
+	return e
+}
+
+// forgetRead drops the bucket's entry, so the next Get reads the bucket
+// again. It must be called with b.guard held for writing whenever state read
+// by Get changes.
+func (b *bucket) forgetRead() {
+	b.latest.Store(nil)
+	b.decoded.Store(nil)
+}
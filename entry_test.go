@@ -0,0 +1,175 @@
+package datapool
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetReadsEntry(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	b := pool.Bucket("hot")
+
+	ts := b.Put("a")
+	require.NotNil(t, b.b.latest.Load(), "Puts publish the entry")
+	value, got, fresh := b.Get(0)
+	assert.Equal(t, "a", value)
+	assert.Equal(t, ts, got)
+	assert.True(t, fresh)
+
+	_, _, fresh = b.Get(ts)
+	assert.False(t, fresh, "Freshness is checked against each Get's timestamp")
+
+	ts = b.Put("b")
+	value, got, _ = b.Get(0)
+	assert.Equal(t, "b", value, "Writes replace the entry")
+	assert.Equal(t, ts, got)
+
+	shared := b.b.latest.Load()
+	clock.Advance(time.Hour)
+	b.Get(0)
+	assert.Same(t, shared, b.b.latest.Load(), "Entries are reused until the bucket changes")
+}
+
+func TestGetEntryExpiry(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	b := pool.Bucket("session")
+	b.SetTTL(time.Minute)
+	b.Put("token")
+
+	value, _, _ := b.Get(0)
+	assert.Equal(t, "token", value)
+	clock.Advance(2 * time.Minute)
+	value, ts, _ := b.Get(0)
+	assert.Nil(t, value, "Entries expire with the value")
+	assert.Zero(t, ts)
+}
+
+func TestGetEntryChanges(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("a")
+	b.Put(1)
+	b.Get(0)
+
+	b.SetLoader(func(string) (any, error) { return "loaded", nil })
+	require.Equal(t, 1, pool.Clear())
+	value, ts, fresh := b.Get(0)
+	assert.Nil(t, value, "Removed buckets read as empty")
+	assert.Zero(t, ts)
+	assert.False(t, fresh)
+
+	c := pool.Bucket("c")
+	c.Get(0)
+	c.SetLoader(func(string) (any, error) { return "loaded", nil })
+	value, _, _ = c.Get(0)
+	assert.Equal(t, "loaded", value, "Loaders set after the entry was published are used")
+}
+
+func TestGetEntryBytes(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("blob")
+	buf := pool.AllocBytes(3)
+	copy(buf, "abc")
+	b.PutBytes(buf)
+
+	first, _, _ := b.Get(0)
+	first.([]byte)[0] = 'x'
+	second, _, _ := b.Get(0)
+	assert.Equal(t, []byte("abc"), second, "Every Get of pooled bytes returns its own copy")
+	assert.Nil(t, b.b.latest.Load())
+}
+
+func TestGetEntryConcurrent(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("hot")
+	b.Put(0)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := int64(0)
+			for i := 0; i < 1000; i++ {
+				_, ts, _ := b.Get(0)
+				assert.GreaterOrEqual(t, ts, last, "Reads never go back in time")
+				last = ts
+			}
+		}()
+	}
+	for i := 1; i <= 1000; i++ {
+		b.Put(i)
+	}
+	wg.Wait()
+
+	putTs := b.Put("last")
+	value, ts, _ := b.Get(0)
+	assert.Equal(t, "last", value)
+	assert.Equal(t, putTs, ts)
+}
+
+// BenchmarkGetParallel reads a hot bucket from many goroutines, through its
+// entry or under its read lock as Get used to, and with Bucket.Get end to end,
+// counters and freshness SLO included, while it is written once every 2, 100
+// or 10000 operations.
+func BenchmarkGetParallel(b *testing.B) {
+	reads := map[string]func(p *DataPool, bk *bucket){
+		"get": func(p *DataPool, bk *bucket) {
+			h := Bucket{pool: p, b: bk}
+			h.Get(0)
+		},
+		"locked": func(p *DataPool, bk *bucket) {
+			bk.guard.RLock()
+			bk.read(p, 0)
+			bk.guard.RUnlock()
+		},
+		"atomic": func(p *DataPool, bk *bucket) {
+			p.loadEntry(bk)
+		},
+	}
+	for _, path := range []string{"locked", "atomic", "get"} {
+		read := reads[path]
+		for _, writeEvery := range []int{2, 100, 10000} {
+			b.Run(fmt.Sprintf("%s/write-every-%d", path, writeEvery), func(b *testing.B) {
+				pool := NewDataPool()
+				bucket := pool.Bucket("hot")
+				bucket.Put("value")
+				bucket.SetFreshnessSLO(FreshnessSLO{MaxAge: time.Minute, Target: 0.99})
+
+				b.SetParallelism(highConcurrency())
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for i := 1; pb.Next(); i++ {
+						if i%writeEvery == 0 {
+							bucket.Put("value")
+						} else {
+							read(pool, bucket.b)
+						}
+					}
+				})
+			})
+		}
+	}
+}
+
+// BenchmarkGetHot is Get of a bucket that is never written, from many
+// goroutines.
+func BenchmarkGetHot(b *testing.B) {
+	pool := NewDataPool()
+	bucket := pool.Bucket("hot")
+	bucket.Put("value")
+
+	b.SetParallelism(highConcurrency())
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			bucket.Get(0)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package datapool
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The general failure modes of the package. Every error the package returns
+// or reports for one of them matches it with errors.Is, whichever subsystem
+// it comes from, as do the more specific errors defined for that subsystem:
+// ErrStaleFence is an ErrStale, for instance, so callers can branch on either.
+var (
+	// ErrNotFound is a bucket or value that does not exist, such as
+	// ErrBucketNotFound and ErrRemoved.
+	ErrNotFound = errors.New("datapool: not found")
+
+	// ErrStale is a write or commit based on outdated state, such as
+	// ErrStaleFence, ErrClaimLost and ErrConflict.
+	ErrStale = errors.New("datapool: stale")
+
+	// ErrSealed is a write to a bucket that cannot be written, such as
+	// ErrSystemBucket and ErrClaimed.
+	ErrSealed = errors.New("datapool: sealed")
+
+	// ErrTooLarge is an input beyond a size limit, such as a bucket name
+	// longer than NameRules allow or an oversized string in an RDB file.
+	ErrTooLarge = errors.New("datapool: too large")
+
+	// ErrClosed is an operation on a closed resource, such as a closed WAL.
+	// Its errors also match os.ErrClosed.
+	ErrClosed = errors.New("datapool: closed")
+
+	// ErrBackpressure is work dropped because a bounded queue was full, such
+	// as writes dropped from the offline queue (see WithOfflineQueue).
+	ErrBackpressure = errors.New("datapool: backpressure")
+)
+
+// ErrBucketNotFound is returned by the error-returning variants of bucket
+// operations (GetE, GetAs, PutE and the like) for handles that do not refer
+// to a bucket of a pool, such as the zero Bucket returned for an invalid
+// name, and for buckets that were removed or evicted. The errors of removed
+// buckets also wrap ErrRemoved. It is an ErrNotFound.
+var ErrBucketNotFound = newKindError(ErrNotFound, "datapool: bucket not found")
+
+// ErrTypeMismatch is returned by GetAs and GetDecoded when the bucket's value
+// is not of the requested type.
+var ErrTypeMismatch = errors.New("datapool: type mismatch")
+
+// kindError is a sentinel error of one of the general failure modes, which
+// it matches with errors.Is while keeping its own message.
+type kindError struct {
+	msg  string
+	kind error
+}
+
+func newKindError(kind error, msg string) error {
+	return &kindError{msg: msg, kind: kind}
+}
+
+func (e *kindError) Error() string { return e.msg }
+func (e *kindError) Unwrap() error { return e.kind }
+
+// withKind returns err, unchanged but for also matching kind with errors.Is.
+func withKind(err error, kind error) error {
+	return &kindedError{err: err, kind: kind}
+}
+
+type kindedError struct {
+	err  error
+	kind error
+}
+
+func (e *kindedError) Error() string   { return e.err.Error() }
+func (e *kindedError) Unwrap() []error { return []error{e.err, e.kind} }
+
+// Lookup returns the named bucket without creating it. It returns the name's
+// error, wrapping ErrInvalidName, if the pool's NameRules reject it,
+// ErrBucketNotFound if there is no such bucket, and ErrPoolClosed once the
+// pool is closed.
+func (p *DataPool) Lookup(name string) (Bucket, error) {
+	if p.isClosed() {
+		return Bucket{}, fmt.Errorf("datapool: lookup %q: %w", name, ErrPoolClosed)
+	}
+	if err := p.ValidateName(name); err != nil {
+		return Bucket{}, err
+	}
+	b := p.find(name)
+	if b == nil {
+		return Bucket{}, fmt.Errorf("%w: %q", ErrBucketNotFound, name)
+	}
+	return Bucket{pool: p, b: b}, nil
+}
+
+// GetE is Get returning an error, wrapping ErrBucketNotFound, if the handle
+// does not refer to a bucket or the bucket was removed, so that such buckets
+// are told apart from empty ones: an empty bucket returns a nil value, a zero
+// timestamp and no error. A bucket holding an error stored with PutError
+// returns a nil value, the error's timestamp and an error wrapping
+// ErrCachedError.
+func (b *Bucket) GetE(timestamp int64) (any, int64, bool, error) {
+	bk, err := b.lookup("get")
+	if err != nil {
+		return nil, timestamp, false, err
+	}
+	value, ts, fresh := b.pool.get(bk, timestamp, ConsistencyDefault)
+	if ts == 0 {
+		if err := bk.checkRemoved(); err != nil {
+			return nil, timestamp, false, err
+		}
+	} else if value == nil {
+		if err := bk.cachedError(ts); err != nil {
+			return nil, ts, fresh, err
+		}
+	}
+	return value, ts, fresh, nil
+}
+
+// GetAs is GetE returning the bucket's value as a T. Values stored in encoded
+// form (see PutEncoded) are decoded into a T, returning the codec's error if
+// they cannot be; other values that are not a T return an error wrapping
+// ErrTypeMismatch. An empty bucket returns the zero T and no error.
+//
+// The decoded value is kept until the bucket changes, so that repeated calls
+// for the same value and type skip decoding. Like values stored with Put, it
+// is shared between callers, unless the bucket copies values (see
+// SetCopyValues).
+func GetAs[T any](b *Bucket, timestamp int64) (T, int64, bool, error) {
+	var zero T
+	value, ts, fresh, err := b.GetE(timestamp)
+	if err != nil || ts == 0 || value == nil {
+		return zero, ts, fresh, err
+	}
+	switch v := value.(type) {
+	case T:
+		return v, ts, fresh, nil
+	case Encoded:
+		out, err := decodeAs[T](b.b, v, ts)
+		if err != nil {
+			return zero, ts, fresh, fmt.Errorf("datapool: decode %s value of %q: %w", v.Codec.Name(), b.b.name(), err)
+		}
+		return out, ts, fresh, nil
+	}
+	return zero, ts, fresh, fmt.Errorf("%w: bucket %q holds %T, not %T", ErrTypeMismatch, b.b.name(), value, zero)
+}
+
+// lookup is resolve returning an error wrapping ErrBucketNotFound for handles
+// that do not refer to a bucket, zero Buckets included, and one wrapping
+// ErrPoolClosed once the pool is closed.
+func (b *Bucket) lookup(op string) (*bucket, error) {
+	if b.pool != nil && b.pool.isClosed() {
+		return nil, closedError(op)
+	}
+	bk := b.resolve(op)
+	if bk == nil {
+		return nil, fmt.Errorf("datapool: %s: %w", op, ErrBucketNotFound)
+	}
+	return bk, nil
+}
+
+// checkRemoved returns an error wrapping ErrBucketNotFound and ErrRemoved if
+// the bucket was removed.
+func (b *bucket) checkRemoved() error {
+	b.guard.RLock()
+	removed := b.removed
+	b.guard.RUnlock()
+	if removed {
+		return b.removedError()
+	}
+	return nil
+}
+
+// removedError returns the error of an operation on the bucket once removed.
+func (b *bucket) removedError() error {
+	return fmt.Errorf("%w: %q: %w", ErrBucketNotFound, b.name(), ErrRemoved)
+}
@@ -0,0 +1,160 @@
+package datapool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	pool := NewDataPool(WithNameRules(testRules()))
+
+	_, err := pool.Lookup("config")
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+	assert.Zero(t, pool.Len(), "Lookup creates no bucket")
+
+	_, err = pool.Lookup("sys/config")
+	assert.ErrorIs(t, err, ErrInvalidName)
+
+	pool.Handle("config").Put("v")
+	b, err := pool.Lookup("config")
+	require.NoError(t, err)
+	val, _, _ := b.Get(0)
+	assert.Equal(t, "v", val)
+}
+
+func TestGetE(t *testing.T) {
+	pool := NewDataPool(WithMaxBuckets(1))
+
+	var invalid Bucket
+	_, ts, _, err := invalid.GetE(7)
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+	assert.Equal(t, int64(7), ts)
+
+	first := pool.Bucket("first")
+	val, ts, fresh, err := first.GetE(0)
+	require.NoError(t, err, "An empty bucket is not an error")
+	assert.Nil(t, val)
+	assert.Zero(t, ts)
+	assert.False(t, fresh)
+
+	stored := first.Put("v")
+	val, ts, fresh, err = first.GetE(0)
+	require.NoError(t, err)
+	assert.Equal(t, "v", val)
+	assert.Equal(t, stored, ts)
+	assert.True(t, fresh)
+
+	pool.Bucket("second")
+	_, _, _, err = first.GetE(0)
+	assert.ErrorIs(t, err, ErrBucketNotFound, "Evicted buckets are not found")
+	assert.ErrorIs(t, err, ErrRemoved)
+}
+
+func TestPutEErrors(t *testing.T) {
+	pool := NewDataPool(WithMaxBuckets(1))
+
+	var invalid Bucket
+	_, err := invalid.PutE("v")
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+
+	stats := pool.Bucket(SystemStatsBucket)
+	_, err = stats.PutE("v")
+	assert.ErrorIs(t, err, ErrSystemBucket)
+
+	first := pool.Bucket("first")
+	pool.Bucket("second")
+	ts, err := first.PutE("v")
+	assert.ErrorIs(t, err, ErrRemoved)
+	assert.Zero(t, ts)
+}
+
+func TestGetAs(t *testing.T) {
+	pool := NewDataPool()
+	counts := pool.Bucket("counts")
+
+	n, ts, _, err := GetAs[int](&counts, 0)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Zero(t, ts)
+
+	counts.Put(42)
+	n, _, _, err = GetAs[int](&counts, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 42, n)
+
+	_, _, _, err = GetAs[string](&counts, 0)
+	assert.ErrorIs(t, err, ErrTypeMismatch)
+	assert.EqualError(t, err, `datapool: type mismatch: bucket "counts" holds int, not string`)
+
+	type point struct{ X, Y int }
+	points := pool.Bucket("points")
+	_, err = points.PutEncoded(point{1, 2})
+	require.NoError(t, err)
+	p, _, _, err := GetAs[point](&points, 0)
+	require.NoError(t, err)
+	assert.Equal(t, point{1, 2}, p)
+
+	_, _, _, err = GetAs[[]string](&points, 0)
+	assert.Error(t, err, "Codec failures are returned")
+
+	var invalid Bucket
+	_, _, _, err = GetAs[int](&invalid, 0)
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+}
+
+func TestGetDecodedErrors(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("b")
+	b.Put(42)
+
+	var s string
+	_, err := b.GetDecoded(&s)
+	assert.ErrorIs(t, err, ErrTypeMismatch)
+
+	var invalid Bucket
+	_, err = invalid.GetDecoded(&s)
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+	_, err = invalid.PutEncoded("v")
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+}
+
+func TestErrorKinds(t *testing.T) {
+	for _, tc := range []struct {
+		err, kind error
+	}{
+		{ErrBucketNotFound, ErrNotFound},
+		{ErrRemoved, ErrNotFound},
+		{ErrStaleFence, ErrStale},
+		{ErrConflict, ErrStale},
+		{ErrSystemBucket, ErrSealed},
+	} {
+		assert.ErrorIs(t, tc.err, tc.kind, tc.err.Error())
+		assert.NotErrorIs(t, tc.kind, tc.err)
+	}
+	assert.Equal(t, "datapool: stale fencing token", ErrStaleFence.Error(), "Specific errors keep their messages")
+}
+
+func TestErrorKindsAcrossSubsystems(t *testing.T) {
+	pool := NewDataPool(WithNameRules(NameRules{MaxLength: 8}))
+
+	err := pool.ValidateName("much-too-long")
+	assert.ErrorIs(t, err, ErrInvalidName)
+	assert.ErrorIs(t, err, ErrTooLarge)
+	assert.EqualError(t, err, `datapool: invalid bucket name "much-too-long": longer than 8 bytes`)
+	assert.NotErrorIs(t, pool.ValidateName("a\xff"), ErrTooLarge)
+
+	b := pool.Bucket("b")
+	token := b.AcquireFence()
+	b.AcquireFence()
+	_, err = b.PutFenced("v", token)
+	assert.ErrorIs(t, err, ErrStale)
+
+	stats := NewDataPool().Bucket(SystemStatsBucket)
+	_, err = stats.PutE("v")
+	assert.ErrorIs(t, err, ErrSealed)
+
+	_, err = pool.Lookup("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,140 @@
+package datapool
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+// EvictionPolicy chooses which bucket to evict when the pool exceeds the cap
+// set with WithMaxBuckets.
+type EvictionPolicy int
+
+const (
+	// LRU evicts the least recently read or written bucket.
+	LRU EvictionPolicy = iota
+	// LFU evicts the least frequently read or written bucket.
+	LFU
+)
+
+// String returns the name of the policy.
+func (e EvictionPolicy) String() string {
+	switch e {
+	case LRU:
+		return "lru"
+	case LFU:
+		return "lfu"
+	default:
+		return fmt.Sprintf("EvictionPolicy(%d)", int(e))
+	}
+}
+
+// evictionSamples is how many buckets are compared to pick a victim. Like
+// Redis, eviction approximates the policy by sampling instead of keeping a
+// global ordering that every Get would have to update under a lock. Pools with
+// at most this many buckets are evicted exactly.
+const evictionSamples = 16
+
+// evictOverflow evicts buckets until the pool is back within its cap, never
+// choosing keep (the bucket whose creation caused the overflow).
+func (p *DataPool) evictOverflow(keep *bucket) {
+	for p.count.Load() > int64(p.opts.maxBuckets) {
+		victim := p.pickVictim(keep)
+		if victim == nil {
+			return
+		}
+		value, ok := p.remove(victim)
+		if !ok {
+			continue
+		}
+		if m := p.opts.metrics; m != nil {
+			m.RecordEviction(victim.name())
+		}
+		p.fireEvict(victim.name(), value, true)
+	}
+}
+
+// pickVictim samples buckets starting at a random shard and returns the one
+// ranked lowest by the eviction policy.
+func (p *DataPool) pickVictim(keep *bucket) *bucket {
+	var victim *bucket
+	var victimRank uint64
+
+	sampled := 0
+	start := rand.IntN(len(p.shards))
+	for i := 0; i < len(p.shards) && sampled < evictionSamples; i++ {
+		sh := p.shards[(start+i)%len(p.shards)]
+		sh.mu.RLock()
+		for _, b := range sh.buckets {
+			if b == keep || b.system || b.pinned.Load() {
+				continue
+			}
+			if rank := p.evictionRank(b); victim == nil || rank < victimRank {
+				victim, victimRank = b, rank
+			}
+			if sampled++; sampled >= evictionSamples {
+				break
+			}
+		}
+		sh.mu.RUnlock()
+	}
+	return victim
+}
+
+// evictionRank orders buckets for eviction; the lowest rank goes first.
+func (p *DataPool) evictionRank(b *bucket) uint64 {
+	switch p.opts.eviction {
+	case LFU:
+		return b.hits.Load()
+	case LRU:
+		return uint64(b.lastAccess.Load())
+	default:
+		p.violation("evict: unknown eviction policy %v", p.opts.eviction)
+		return uint64(b.lastAccess.Load())
+	}
+}
+
+// remove deletes b from the pool and invalidates every handle to it. It
+// returns the bucket's last value, and false if b had already been removed.
+func (p *DataPool) remove(b *bucket) (any, bool) {
+	p.renameMu.Lock()
+	name := b.name()
+	sh := p.shardFor(name)
+	sh.mu.Lock()
+	if sh.buckets[name] != b {
+		sh.mu.Unlock()
+		p.renameMu.Unlock()
+		return nil, false
+	}
+	delete(sh.buckets, name)
+	if !b.system {
+		p.count.Add(-1)
+	}
+	if b.tenant != nil {
+		b.tenant.release(b)
+	}
+	sh.mu.Unlock()
+	p.dropAliases(b)
+	p.renameMu.Unlock()
+
+	b.guard.Lock()
+	b.removed = true
+	if b.unpin() {
+		p.pinned.Add(-1)
+	}
+	b.reschedule()
+	value := b.current()
+	b.value = nil
+	b.cachedErr = nil
+	b.releaseShared()
+	b.account(nil)
+	b.forgetRead()
+	b.provenance = nil
+	b.schema = 0
+	watchers := b.watchers
+	b.watchers = nil
+	b.guard.Unlock()
+	p.refs.Delete(b.id)
+
+	closeWatchers(watchers)
+	return value, true
+}
@@ -0,0 +1,119 @@
+package datapool
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvictLRU(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	var evicted []string
+	pool := NewDataPool(WithClock(clock), WithMaxBuckets(3), WithEvictionCallback(func(name string, value any) {
+		evicted = append(evicted, fmt.Sprintf("%s=%v", name, value))
+	}))
+
+	a := pool.Bucket("a")
+	a.Put(1)
+	clock.Advance(time.Second)
+	b := pool.Bucket("b")
+	b.Put(2)
+	clock.Advance(time.Second)
+	c := pool.Bucket("c")
+	c.Put(3)
+	clock.Advance(time.Second)
+
+	// Reading "a" makes "b" the least recently used bucket.
+	a.Get(0)
+	clock.Advance(time.Second)
+
+	pool.Bucket("d")
+	assert.Equal(t, 3, pool.Len())
+	assert.Equal(t, []string{"b=2"}, evicted)
+
+	names := []string{}
+	for _, info := range pool.Inspect() {
+		names = append(names, info.Name)
+	}
+	assert.Equal(t, []string{"a", "c", "d"}, names)
+}
+
+func TestEvictLFU(t *testing.T) {
+	var evicted []string
+	pool := NewDataPool(WithMaxBuckets(2), WithEviction(LFU), WithEvictionCallback(func(name string, _ any) {
+		evicted = append(evicted, name)
+	}))
+
+	hot := pool.Bucket("hot")
+	cold := pool.Bucket("cold")
+	hot.Put("h")
+	cold.Put("c")
+	for i := 0; i < 5; i++ {
+		hot.Get(0)
+	}
+	// Make "cold" the most recently used, so LRU would have picked "hot".
+	cold.Get(0)
+
+	pool.Bucket("new")
+	assert.Equal(t, []string{"cold"}, evicted)
+}
+
+func TestEvictedHandleIsInvalidated(t *testing.T) {
+	pool := NewDataPool(WithMaxBuckets(1))
+	old := pool.Bucket("old")
+	old.Put("value")
+
+	pool.Bucket("new")
+
+	val, _, fresh := old.Get(0)
+	assert.Nil(t, val, "Evicted handle reads nothing")
+	assert.False(t, fresh)
+	assert.Equal(t, int64(0), old.Put("again"), "Put through an evicted handle is dropped")
+	assert.Equal(t, 1, pool.Len(), "Put through an evicted handle does not resurrect the bucket")
+
+	// The name can be reused; it creates a new, empty bucket.
+	reborn := pool.Bucket("old")
+	val, _, _ = reborn.Get(0)
+	assert.Nil(t, val)
+	assert.NotSame(t, old.b, reborn.b)
+	assert.Equal(t, uint64(0), pool.Corruptions())
+}
+
+func TestEvictionConcurrentCreation(t *testing.T) {
+	const limit = 10
+	var mu sync.Mutex
+	evicted := 0
+	pool := NewDataPool(WithMaxBuckets(limit), WithEvictionCallback(func(string, any) {
+		mu.Lock()
+		evicted++
+		mu.Unlock()
+	}))
+
+	const numGoroutines = 20
+	var wg sync.WaitGroup
+	wg.Add(numGoroutines)
+	for i := 0; i < numGoroutines; i++ {
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				b := pool.Bucket(fmt.Sprintf("bucket-%d-%d", id, j))
+				b.Put(j)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, limit, pool.Len())
+	require.Len(t, pool.Inspect(), limit)
+	assert.Equal(t, numGoroutines*10-limit, evicted)
+}
+
+func TestEvictionPolicyString(t *testing.T) {
+	assert.Equal(t, "lru", LRU.String())
+	assert.Equal(t, "lfu", LFU.String())
+	assert.Equal(t, "EvictionPolicy(7)", EvictionPolicy(7).String())
+}
@@ -0,0 +1,124 @@
+package datapool
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+)
+
+// expiryQueue is a min-heap of the expiration times of the pool's values, so
+// that the next one to expire is found without visiting every bucket.
+type expiryQueue struct {
+	mu    sync.Mutex
+	items expiryHeap
+}
+
+// expiryItem is a bucket's place in the expiryQueue. Its fields are guarded
+// by the queue's lock.
+type expiryItem struct {
+	b     *bucket
+	at    int64
+	index int
+}
+
+type expiryHeap []*expiryItem
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].at < h[j].at }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x any) {
+	item := x.(*expiryItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
+
+// reschedule updates the bucket's place in the expiry queue after a change to
+// its value, expiration time or pin. It must be called with b.guard held for
+// writing.
+func (b *bucket) reschedule() {
+	q := b.expiries
+	if q == nil {
+		return
+	}
+	var at int64
+	if !b.removed && b.timestamp != 0 {
+		at = b.expiry()
+	}
+	if b.queued == nil && at == 0 {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	switch {
+	case at == 0:
+		heap.Remove(&q.items, b.queued.index)
+		b.queued = nil
+	case b.queued == nil:
+		b.queued = &expiryItem{b: b, at: at}
+		heap.Push(&q.items, b.queued)
+	case b.queued.at != at:
+		b.queued.at = at
+		heap.Fix(&q.items, b.queued.index)
+	}
+}
+
+// due returns the buckets whose values expire at or before now, soonest
+// first.
+func (q *expiryQueue) due(now int64) []*bucket {
+	q.mu.Lock()
+	var items []*expiryItem
+	// Children expire no sooner than their parent, so only the subtrees of
+	// due items need visiting.
+	stack := []int{0}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if i >= len(q.items) || q.items[i].at > now {
+			continue
+		}
+		items = append(items, q.items[i])
+		stack = append(stack, 2*i+1, 2*i+2)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].at < items[j].at })
+	buckets := make([]*bucket, len(items))
+	for i, item := range items {
+		buckets[i] = item.b
+	}
+	q.mu.Unlock()
+	return buckets
+}
+
+// NextExpiry returns the bucket whose value expires first and when, on the
+// pool's clock, or false if no value is set to expire. Schedulers can sleep
+// until then and call Expire instead of polling: the time is in the past for
+// values that expired but were not dropped by Expire yet. A value written
+// later may expire sooner, so schedulers should not sleep longer than the
+// shortest TTL in use. Pinned buckets are left out, as they do not expire.
+func (p *DataPool) NextExpiry() (name string, at time.Time, ok bool) {
+	q := &p.expiries
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
+		return "", time.Time{}, false
+	}
+	next := q.items[0]
+	return next.b.name(), time.Unix(0, next.at), true
+}
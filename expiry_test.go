@@ -0,0 +1,120 @@
+package datapool
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextExpiry(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+
+	_, _, ok := pool.NextExpiry()
+	assert.False(t, ok, "Nothing expires in an empty pool")
+
+	session := pool.Bucket("session")
+	session.SetTTL(time.Minute)
+	token := pool.Bucket("token")
+	token.SetTTL(time.Hour)
+	plain := pool.Bucket("plain")
+	plain.Put("forever")
+
+	token.Put("t")
+	ts := session.Put("s")
+	name, at, ok := pool.NextExpiry()
+	require.True(t, ok)
+	assert.Equal(t, "session", name)
+	assert.Equal(t, time.Unix(0, ts).Add(time.Minute), at)
+
+	clock.Advance(30 * time.Second)
+	session.Put("s2")
+	name, _, _ = pool.NextExpiry()
+	assert.Equal(t, "session", name, "Rewrites move the expiration")
+
+	session.Pin()
+	name, _, _ = pool.NextExpiry()
+	assert.Equal(t, "token", name, "Pinned buckets do not expire")
+	session.Unpin()
+	name, _, _ = pool.NextExpiry()
+	assert.Equal(t, "session", name)
+
+	clock.Advance(2 * time.Minute)
+	name, at, _ = pool.NextExpiry()
+	assert.Equal(t, "session", name, "Expired values stay due until Expire drops them")
+	assert.True(t, at.Before(clock.Now()))
+	assert.Equal(t, 1, pool.Expire())
+	name, _, _ = pool.NextExpiry()
+	assert.Equal(t, "token", name)
+
+	assert.Equal(t, 3, pool.Clear())
+	_, _, ok = pool.NextExpiry()
+	assert.False(t, ok, "Removed buckets leave the queue")
+}
+
+func TestExpireVisitsDueBucketsInOrder(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	var expired []string
+	pool.OnExpire(func(name string, _ any) { expired = append(expired, name) })
+
+	for i, ttl := range []time.Duration{3, 1, 5, 2, 4} {
+		b := pool.Bucket(fmt.Sprint("b", i))
+		b.SetTTL(ttl * time.Minute)
+		b.Put(i)
+	}
+	clock.Advance(3 * time.Minute)
+	assert.Equal(t, 3, pool.Expire())
+	assert.Equal(t, []string{"b1", "b3", "b0"}, expired)
+	assert.Zero(t, pool.Expire())
+}
+
+// TestExpiryQueueMatchesBuckets checks the queue against the buckets after
+// random writes, pins, expirations and evictions.
+func TestExpiryQueueMatchesBuckets(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock), WithMaxBuckets(20))
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 2000; i++ {
+		b := pool.Bucket(fmt.Sprint("b", rng.Intn(30)))
+		switch rng.Intn(6) {
+		case 0:
+			b.SetTTL(time.Duration(rng.Intn(10)) * time.Second)
+		case 1:
+			b.Pin()
+		case 2:
+			b.Unpin()
+		case 3:
+			pool.Expire()
+		default:
+			b.Put(i)
+		}
+		clock.Advance(time.Duration(rng.Intn(500)) * time.Millisecond)
+	}
+
+	want := map[*bucket]int64{}
+	for _, b := range pool.all() {
+		b.guard.RLock()
+		if at := b.expiry(); at != 0 && b.timestamp != 0 && !b.removed {
+			want[b] = at
+		}
+		b.guard.RUnlock()
+	}
+	got := map[*bucket]int64{}
+	q := &pool.expiries
+	q.mu.Lock()
+	for i, item := range q.items {
+		assert.Equal(t, i, item.index)
+		if i > 0 {
+			assert.LessOrEqual(t, q.items[(i-1)/2].at, item.at, "The queue is a heap")
+		}
+		got[item.b] = item.at
+	}
+	q.mu.Unlock()
+	assert.Equal(t, want, got)
+}
@@ -0,0 +1,155 @@
+package datapool
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+	"unsafe"
+)
+
+// debugState is the pool's state as published by Expvar and ExpvarHandler.
+type debugState struct {
+	Len     int           `json:"len"`
+	Buckets []debugBucket `json:"buckets"`
+}
+
+type debugBucket struct {
+	Name      string     `json:"name"`
+	Timestamp int64      `json:"timestamp"`
+	Updated   *time.Time `json:"updated,omitempty"`
+	// AgeSeconds is omitted for empty buckets, which are infinitely old.
+	AgeSeconds *float64 `json:"age_seconds,omitempty"`
+	Type       string   `json:"type"`
+	Size       int      `json:"size"`
+}
+
+// Expvar returns an expvar.Var publishing the name, timestamp, age, type and
+// approximate size in bytes of every bucket as JSON, evaluated whenever the
+// variable is read, so operators can check at run time that refreshers keep
+// values fresh:
+//
+//	expvar.Publish("datapool", pool.Expvar())
+//
+// The result implements expvar.Var without this package importing expvar,
+// which registers /debug/vars on http.DefaultServeMux.
+func (p *DataPool) Expvar() fmt.Stringer {
+	return debugVar{p}
+}
+
+type debugVar struct {
+	pool *DataPool
+}
+
+func (v debugVar) String() string {
+	data, _ := json.Marshal(v.pool.debugState())
+	return string(data)
+}
+
+// ExpvarHandler returns an http.Handler serving what Expvar publishes, for a
+// debug endpoint of its own.
+func (p *DataPool) ExpvarHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(p.debugState())
+	})
+}
+
+func (p *DataPool) debugState() debugState {
+	infos := p.Inspect()
+	state := debugState{Len: len(infos), Buckets: make([]debugBucket, 0, len(infos))}
+	for _, info := range infos {
+		db := debugBucket{Name: info.Name, Timestamp: info.Timestamp, Type: info.Type, Size: sizeOf(info.Value)}
+		if info.Timestamp != 0 {
+			age := p.age(info.Timestamp).Seconds()
+			db.Updated = &info.Updated
+			db.AgeSeconds = &age
+		}
+		state.Buckets = append(state.Buckets, db)
+	}
+	return state
+}
+
+// sizeOf approximates the memory held by v in bytes: the size of its value
+// plus that of the strings, slices, maps and pointers it reaches, each
+// counted once.
+func sizeOf(v any) int {
+	if v == nil {
+		return 0
+	}
+	s := sizer{seen: make(map[uintptr]bool)}
+	rv := reflect.ValueOf(v)
+	return int(rv.Type().Size()) + s.indirect(rv)
+}
+
+type sizer struct {
+	seen map[uintptr]bool
+}
+
+// indirect returns the size of the memory v refers to, beyond v itself.
+func (s *sizer) indirect(v reflect.Value) int {
+	switch v.Kind() {
+	case reflect.String:
+		if v.Len() == 0 || s.visit(uintptr(unsafe.Pointer(unsafe.StringData(v.String())))) {
+			return 0
+		}
+		return v.Len()
+	case reflect.Slice:
+		if v.Cap() == 0 || s.visit(v.Pointer()) {
+			return 0
+		}
+		n := v.Cap() * int(v.Type().Elem().Size())
+		for i := range v.Len() {
+			n += s.indirect(v.Index(i))
+		}
+		return n
+	case reflect.Array:
+		n := 0
+		for i := range v.Len() {
+			n += s.indirect(v.Index(i))
+		}
+		return n
+	case reflect.Map:
+		if v.IsNil() || s.visit(v.Pointer()) {
+			return 0
+		}
+		n := 0
+		it := v.MapRange()
+		for it.Next() {
+			k, e := it.Key(), it.Value()
+			n += int(k.Type().Size()+e.Type().Size()) + s.indirect(k) + s.indirect(e)
+		}
+		return n
+	case reflect.Pointer:
+		if v.IsNil() || s.visit(v.Pointer()) {
+			return 0
+		}
+		return int(v.Type().Elem().Size()) + s.indirect(v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		e := v.Elem()
+		return int(e.Type().Size()) + s.indirect(e)
+	case reflect.Struct:
+		n := 0
+		for i := range v.NumField() {
+			n += s.indirect(v.Field(i))
+		}
+		return n
+	default:
+		return 0
+	}
+}
+
+// visit reports whether the memory at ptr was already counted, and marks it.
+func (s *sizer) visit(ptr uintptr) bool {
+	if s.seen[ptr] {
+		return true
+	}
+	s.seen[ptr] = true
+	return false
+}
@@ -0,0 +1,84 @@
+package datapool
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpvar(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0).UTC())
+	pool := NewDataPool(WithClock(clock))
+	rates := pool.Bucket("rates")
+	rates.Put("1.0842")
+	pool.Bucket("empty")
+	clock.Advance(90 * time.Second)
+
+	var v expvar.Var = pool.Expvar()
+	var state map[string]any
+	require.NoError(t, json.Unmarshal([]byte(v.String()), &state))
+	assert.Equal(t, map[string]any{
+		"len": float64(2),
+		"buckets": []any{
+			map[string]any{
+				"name":        "rates",
+				"timestamp":   float64(time.Unix(1000, 0).UnixNano()),
+				"updated":     "1970-01-01T00:16:40Z",
+				"age_seconds": float64(90),
+				"type":        "string",
+				"size":        float64(22),
+			},
+			map[string]any{
+				"name":      "empty",
+				"timestamp": float64(0),
+				"type":      "<nil>",
+				"size":      float64(0),
+			},
+		},
+	}, state)
+}
+
+func TestExpvarHandler(t *testing.T) {
+	pool := NewDataPool(WithClock(NewManualClock(time.Unix(1000, 0))))
+	config := pool.Bucket("config")
+	config.Put(map[string]any{"theme": "dark"})
+
+	rec := httptest.NewRecorder()
+	pool.ExpvarHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/datapool", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, pool.Expvar().String(), rec.Body.String())
+}
+
+func TestSizeOf(t *testing.T) {
+	type node struct {
+		Name string
+		Next *node
+	}
+	cyclic := &node{Name: "a"}
+	cyclic.Next = cyclic
+	shared := "shared string"
+
+	for _, tc := range []struct {
+		name  string
+		value any
+		size  int
+	}{
+		{"nil", nil, 0},
+		{"int", 42, 8},
+		{"string", "abcd", 16 + 4},
+		{"bytes", make([]byte, 3, 10), 24 + 10},
+		{"slice of strings", []string{"ab", "cd"}, 24 + 2*16 + 4},
+		{"shared strings", []string{shared, shared}, 24 + 2*16 + len(shared)},
+		{"map", map[string]int{"ab": 1}, 8 + 16 + 8 + 2},
+		{"cycle", cyclic, 8 + 24 + 1},
+	} {
+		assert.Equal(t, tc.size, sizeOf(tc.value), tc.name)
+	}
+}
@@ -0,0 +1,73 @@
+package datapool
+
+import (
+	"context"
+	"fmt"
+)
+
+// ErrStaleFence is wrapped by the errors of PutFenced calls whose fencing
+// token was superseded by a newer one. It is an ErrStale.
+var ErrStaleFence = newKindError(ErrStale, "datapool: stale fencing token")
+
+// AcquireFence returns a new fencing token for the bucket, higher than every
+// token it has seen, for a writer taking over the bucket, such as a newly
+// elected leader. From then on, PutFenced calls with older tokens fail, so a
+// writer that lost its leadership without noticing cannot overwrite its
+// successor's values. Tokens from an external coordinator, such as the
+// revision of a lock, can be used with PutFenced directly instead.
+func (b *Bucket) AcquireFence() uint64 {
+	bk := b.resolve("acquire fence")
+	if bk == nil {
+		return 0
+	}
+
+	bk.guard.Lock()
+	defer bk.guard.Unlock()
+
+	bk.fence++
+	return bk.fence
+}
+
+// Fence returns the highest fencing token the bucket has seen, zero if none.
+// Like versions, fencing tokens outlive values and start over when a removed
+// bucket is created again.
+func (b *Bucket) Fence() uint64 {
+	bk := b.resolve("fence")
+	if bk == nil {
+		return 0
+	}
+
+	bk.guard.RLock()
+	defer bk.guard.RUnlock()
+
+	return bk.fence
+}
+
+// PutFenced is Put by a writer holding a fencing token, which must be
+// positive. The value is stored only if token is at least the highest token
+// the bucket has seen, which it becomes; otherwise nothing is stored and the
+// error wraps ErrStaleFence. The token travels with the value as its
+// Update's Fence, through watchers, callbacks and backends, so external side
+// effects driven by updates can ignore those of a superseded writer by
+// keeping the highest Fence they acted on. Values received from a backend
+// raise the bucket's token to theirs.
+func (b *Bucket) PutFenced(value any, token uint64) (int64, error) {
+	bk := b.resolve("put fenced")
+	if bk == nil {
+		return 0, nil
+	}
+	if token == 0 {
+		return 0, fmt.Errorf("datapool: put fenced %q: zero fencing token", bk.name())
+	}
+	u, err := b.pool.writeAt(context.Background(), bk, Update{Value: value, Fence: token}, 0, ConsistencyDefault)
+	return u.Timestamp, err
+}
+
+// checkFence returns the error of a write fenced with token, zero for none,
+// to b. It must be called with b.guard held.
+func (b *bucket) checkFence(token uint64) error {
+	if token != 0 && token < b.fence {
+		return fmt.Errorf("%w: bucket %q: token %d, current %d", ErrStaleFence, b.name(), token, b.fence)
+	}
+	return nil
+}
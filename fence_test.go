@@ -0,0 +1,72 @@
+package datapool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutFenced(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("leader/state")
+	assert.Zero(t, b.Fence())
+
+	old := b.AcquireFence()
+	ts, err := b.PutFenced("from old", old)
+	require.NoError(t, err)
+	assert.NotZero(t, ts)
+
+	current := b.AcquireFence()
+	assert.Greater(t, current, old)
+	ts, err = b.PutFenced("late write from old", old)
+	assert.ErrorIs(t, err, ErrStaleFence)
+	assert.Zero(t, ts)
+	value, _, _ := b.Get(0)
+	assert.Equal(t, "from old", value, "Writes with superseded tokens are not stored")
+
+	_, err = b.PutFenced("from current", current)
+	require.NoError(t, err)
+	_, err = b.PutFenced("again", current)
+	require.NoError(t, err, "A token stays valid until superseded")
+
+	_, err = b.PutFenced("external", 100)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), b.Fence(), "Tokens from elsewhere raise the bucket's")
+	assert.Equal(t, uint64(101), b.AcquireFence())
+
+	_, err = b.PutFenced("unfenced", 0)
+	assert.Error(t, err)
+}
+
+func TestFenceInUpdates(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("leader/state")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := b.Watch(ctx)
+
+	token := b.AcquireFence()
+	b.PutFenced("fenced", token)
+	b.Put("plain")
+	assert.Equal(t, token, (<-updates).Fence)
+	assert.Zero(t, (<-updates).Fence, "Plain writes are not fenced")
+}
+
+func TestFenceBackend(t *testing.T) {
+	backend := &MemoryBackend{}
+	a := NewDataPool(WithBackend(backend), WithPeerName("a"))
+	b := NewDataPool(WithBackend(backend), WithPeerName("b"))
+
+	ab := a.Bucket("leader/state")
+	_, err := ab.PutFenced("from a", 5)
+	require.NoError(t, err)
+
+	bb := b.Bucket("leader/state")
+	value, _, _ := bb.Get(0)
+	require.Equal(t, "from a", value)
+	assert.Equal(t, uint64(5), bb.Fence(), "Values read from the backend carry their token")
+	_, err = bb.PutFenced("from b", 4)
+	assert.ErrorIs(t, err, ErrStaleFence)
+}
@@ -0,0 +1,395 @@
+package datapool
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportGoFixture writes a Go source file of package pkg defining
+//
+//	func NewFixture(opts ...datapool.Option) *datapool.DataPool
+//
+// which returns a new pool holding the pool's current values, for turning
+// captured state into a reproducible test fixture. The values are stored with
+// a single PutMany, in name order, so their timestamps are not preserved;
+// empty buckets are created empty, and system buckets are left out.
+//
+// Values may be booleans, numbers, strings, slices, arrays, maps, structs with
+// only exported fields, pointers to structs, time.Time values, Encoded values
+// of JSONCodec, GobCodec or the datapoolmsgpack codec, and any value nested of
+// these, as long as their named types are exported; for anything else, such as
+// channels, functions, cyclic structures or unexported types, ExportGoFixture
+// fails without writing.
+func (p *DataPool) ExportGoFixture(w io.Writer, pkg string) error {
+	g := fixtureWriter{imports: map[string]bool{datapoolPath: true}}
+
+	var body bytes.Buffer
+	var empty []string
+	body.WriteString("\tpool := datapool.NewDataPool(opts...)\n")
+	body.WriteString("\tpool.PutMany(map[string]any{\n")
+	infos := p.Inspect()
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	for _, info := range infos {
+		if isSystem(info.Name) {
+			continue
+		}
+		if info.Timestamp == 0 {
+			empty = append(empty, info.Name)
+			continue
+		}
+		lit, err := g.literal(reflect.ValueOf(info.Value), false)
+		if err != nil {
+			return fmt.Errorf("datapool: export go fixture: bucket %q: %w", info.Name, err)
+		}
+		fmt.Fprintf(&body, "\t\t%s: %s,\n", strconv.Quote(info.Name), lit)
+	}
+	body.WriteString("\t})\n")
+	for _, name := range empty {
+		fmt.Fprintf(&body, "\tpool.Bucket(%s)\n", strconv.Quote(name))
+	}
+	body.WriteString("\treturn pool\n")
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by datapool.ExportGoFixture; DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(&src, "\t%s\n", strconv.Quote(path))
+	}
+	src.WriteString(")\n\n")
+	src.WriteString("// NewFixture returns a pool configured by opts holding the exported values.\n")
+	src.WriteString("func NewFixture(opts ...datapool.Option) *datapool.DataPool {\n")
+	src.Write(body.Bytes())
+	src.WriteString("}\n")
+
+	out, err := format.Source(src.Bytes())
+	if err != nil {
+		return fmt.Errorf("datapool: export go fixture: %w", err)
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+var (
+	datapoolPath = reflect.TypeFor[DataPool]().PkgPath()
+	timeType     = reflect.TypeFor[time.Time]()
+	encodedType  = reflect.TypeFor[Encoded]()
+)
+
+// fixtureWriter renders values as Go expressions, recording the packages
+// they refer to.
+type fixtureWriter struct {
+	imports map[string]bool
+	// visiting holds the pointers being rendered, to detect cycles.
+	visiting map[uintptr]bool
+}
+
+// literal renders v. untyped reports whether v sits where its type is
+// implied, as do the elements of a composite literal; otherwise the
+// expression must have v's own type even where only any is expected.
+func (g *fixtureWriter) literal(v reflect.Value, untyped bool) (string, error) {
+	if !v.IsValid() {
+		return "nil", nil
+	}
+	t := v.Type()
+	if t == timeType {
+		g.imports["time"] = true
+		ts := v.Interface().(time.Time)
+		return fmt.Sprintf("time.Unix(%d, %d).UTC()", ts.Unix(), ts.Nanosecond()), nil
+	}
+	if t == encodedType {
+		return g.encoded(v.Interface().(Encoded))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return g.typed(t, strconv.FormatBool(v.Bool()), reflect.Bool, untyped)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return g.typed(t, strconv.FormatInt(v.Int(), 10), reflect.Int, untyped)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return g.typed(t, strconv.FormatUint(v.Uint(), 10), reflect.Invalid, untyped)
+	case reflect.Float32, reflect.Float64:
+		return g.float(t, v.Float(), untyped)
+	case reflect.String:
+		return g.typed(t, strconv.Quote(v.String()), reflect.String, untyped)
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return "nil", nil
+		}
+		// The dynamic type is not implied by the interface's.
+		return g.literal(v.Elem(), false)
+
+	case reflect.Pointer:
+		if v.IsNil() {
+			return g.conversion(t, "nil")
+		}
+		if t.Elem().Kind() != reflect.Struct || t.Elem() == timeType {
+			return "", fmt.Errorf("cannot represent %s", t)
+		}
+		if err := g.enter(v.Pointer()); err != nil {
+			return "", err
+		}
+		defer delete(g.visiting, v.Pointer())
+		lit, err := g.literal(v.Elem(), false)
+		if err != nil {
+			return "", err
+		}
+		return "&" + lit, nil
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return g.conversion(t, "nil")
+		}
+		if t.Elem().Kind() == reflect.Uint8 && t.Elem().PkgPath() == "" {
+			name, err := g.typeName(t)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%s(%s)", name, strconv.Quote(string(v.Bytes()))), nil
+		}
+		if err := g.enter(v.Pointer()); err != nil {
+			return "", err
+		}
+		defer delete(g.visiting, v.Pointer())
+		return g.elements(v)
+	case reflect.Array:
+		return g.elements(v)
+
+	case reflect.Map:
+		if v.IsNil() {
+			return g.conversion(t, "nil")
+		}
+		if err := g.enter(v.Pointer()); err != nil {
+			return "", err
+		}
+		defer delete(g.visiting, v.Pointer())
+		return g.entries(v)
+
+	case reflect.Struct:
+		return g.fields(v)
+
+	default:
+		return "", fmt.Errorf("cannot represent %s", t)
+	}
+}
+
+// encoded renders e, whose codec is spelled as the exported variable holding
+// it.
+func (g *fixtureWriter) encoded(e Encoded) (string, error) {
+	var codec string
+	switch {
+	case e.Codec == nil:
+		codec = "nil"
+	case e.Codec == JSONCodec:
+		codec = "datapool.JSONCodec"
+	case e.Codec == GobCodec:
+		codec = "datapool.GobCodec"
+	case reflect.TypeOf(e.Codec).PkgPath() == datapoolPath+"/datapoolmsgpack":
+		g.imports[datapoolPath+"/datapoolmsgpack"] = true
+		codec = "datapoolmsgpack.Codec"
+	default:
+		return "", fmt.Errorf("cannot represent Encoded value of codec %s", e.Codec.Name())
+	}
+	data := "nil"
+	if e.Data != nil {
+		data = fmt.Sprintf("[]byte(%s)", strconv.Quote(string(e.Data)))
+	}
+	return fmt.Sprintf("datapool.Encoded{Codec: %s, Data: %s}", codec, data), nil
+}
+
+// typed renders the constant lit of type t. Constants of the kind the
+// untyped constant defaults to need no conversion where the type is implied
+// or is the default type itself.
+func (g *fixtureWriter) typed(t reflect.Type, lit string, def reflect.Kind, untyped bool) (string, error) {
+	if untyped || (t.Kind() == def && t.PkgPath() == "") {
+		return lit, nil
+	}
+	return g.conversion(t, lit)
+}
+
+// float renders f, which defaults to float64 when written with a decimal
+// point.
+func (g *fixtureWriter) float(t reflect.Type, f float64, untyped bool) (string, error) {
+	var lit string
+	switch {
+	case math.IsInf(f, 0) || math.IsNaN(f):
+		g.imports["math"] = true
+		switch {
+		case math.IsNaN(f):
+			lit = "math.NaN()"
+		case f > 0:
+			lit = "math.Inf(1)"
+		default:
+			lit = "math.Inf(-1)"
+		}
+		if t.Kind() == reflect.Float64 && t.PkgPath() == "" {
+			return lit, nil
+		}
+		return g.conversion(t, lit)
+	case t.Kind() == reflect.Float32:
+		lit = strconv.FormatFloat(f, 'g', -1, 32)
+	default:
+		lit = strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	if !strings.ContainsAny(lit, ".e") {
+		lit += ".0"
+	}
+	return g.typed(t, lit, reflect.Float64, untyped)
+}
+
+// conversion renders lit converted to t.
+func (g *fixtureWriter) conversion(t reflect.Type, lit string) (string, error) {
+	name, err := g.typeName(t)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(name, "*") || strings.HasPrefix(name, "func") {
+		name = "(" + name + ")"
+	}
+	return name + "(" + lit + ")", nil
+}
+
+func (g *fixtureWriter) elements(v reflect.Value) (string, error) {
+	name, err := g.typeName(v.Type())
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString(name + "{")
+	for i := range v.Len() {
+		lit, err := g.literal(v.Index(i), true)
+		if err != nil {
+			return "", err
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(lit)
+	}
+	b.WriteString("}")
+	return b.String(), nil
+}
+
+func (g *fixtureWriter) entries(v reflect.Value) (string, error) {
+	name, err := g.typeName(v.Type())
+	if err != nil {
+		return "", err
+	}
+	lines := make([]string, 0, v.Len())
+	it := v.MapRange()
+	for it.Next() {
+		k, err := g.literal(it.Key(), true)
+		if err != nil {
+			return "", err
+		}
+		e, err := g.literal(it.Value(), true)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, k+": "+e+",\n")
+	}
+	// Map iteration is random; sorting keeps the output reproducible.
+	sort.Strings(lines)
+	return name + "{\n" + strings.Join(lines, "") + "}", nil
+}
+
+func (g *fixtureWriter) fields(v reflect.Value) (string, error) {
+	t := v.Type()
+	name, err := g.typeName(t)
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for i := range t.NumField() {
+		f, fv := t.Field(i), v.Field(i)
+		if fv.IsZero() {
+			continue
+		}
+		if !f.IsExported() {
+			return "", fmt.Errorf("cannot represent %s: unexported field %s", t, f.Name)
+		}
+		lit, err := g.literal(fv, true)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, f.Name+": "+lit+",\n")
+	}
+	if len(lines) == 0 {
+		return name + "{}", nil
+	}
+	return name + "{\n" + strings.Join(lines, "") + "}", nil
+}
+
+// typeName returns how the generated file spells t, importing the packages
+// of the named types it refers to.
+func (g *fixtureWriter) typeName(t reflect.Type) (string, error) {
+	if t.Name() != "" {
+		if t.PkgPath() == "" {
+			return t.Name(), nil
+		}
+		if t.PkgPath() == "main" || strings.Contains(t.Name(), "[") {
+			return "", fmt.Errorf("cannot represent %s", t)
+		}
+		if !token.IsExported(t.Name()) {
+			return "", fmt.Errorf("cannot represent %s: unexported type", t)
+		}
+		g.imports[t.PkgPath()] = true
+		return t.String(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem, err := g.typeName(t.Elem())
+		return "*" + elem, err
+	case reflect.Slice:
+		if t.Elem() == reflect.TypeFor[byte]() {
+			return "[]byte", nil
+		}
+		elem, err := g.typeName(t.Elem())
+		return "[]" + elem, err
+	case reflect.Array:
+		elem, err := g.typeName(t.Elem())
+		return fmt.Sprintf("[%d]%s", t.Len(), elem), err
+	case reflect.Map:
+		key, err := g.typeName(t.Key())
+		if err != nil {
+			return "", err
+		}
+		elem, err := g.typeName(t.Elem())
+		return fmt.Sprintf("map[%s]%s", key, elem), err
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "any", nil
+		}
+	case reflect.Struct:
+		if t.NumField() == 0 {
+			return "struct{}", nil
+		}
+	}
+	return "", fmt.Errorf("cannot represent %s", t)
+}
+
+// enter marks the pointer ptr as being rendered, failing if it already is.
+func (g *fixtureWriter) enter(ptr uintptr) error {
+	if g.visiting == nil {
+		g.visiting = make(map[uintptr]bool)
+	}
+	if g.visiting[ptr] {
+		return fmt.Errorf("cannot represent cyclic values")
+	}
+	g.visiting[ptr] = true
+	return nil
+}
@@ -0,0 +1,163 @@
+package datapool
+
+import (
+	"archive/tar"
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fixtureConfig struct {
+	Name    string
+	Retries int8
+	Tags    []string
+	Limits  map[string]float64
+	Next    *fixtureConfig
+	Updated time.Time
+}
+
+func TestExportGoFixture(t *testing.T) {
+	pool := NewDataPool()
+	pool.PutMany(map[string]any{
+		"count":  42,
+		"ratio":  1.0,
+		"small":  uint8(7),
+		"name":   "café \"quoted\"",
+		"blob":   []byte{0, 1, 'a'},
+		"list":   []any{1, "two", 3.5, nil, true},
+		"nested": map[string]any{"b": []int{1, 2}, "a": map[string]any{}},
+		"inf":    math.Inf(-1),
+	})
+	pool.Bucket("empty")
+	stats := pool.Bucket(SystemStatsBucket)
+	stats.Get(0)
+
+	var buf bytes.Buffer
+	require.NoError(t, pool.ExportGoFixture(&buf, "fixtures"))
+	src := buf.String()
+
+	assert.Contains(t, src, "// Code generated by datapool.ExportGoFixture; DO NOT EDIT.\n\npackage fixtures\n")
+	for _, entry := range []string{
+		`"count": 42,`,
+		`"ratio": 1.0,`,
+		`"small": uint8(7),`,
+		`"name": "café \"quoted\"",`,
+		`"blob": []byte("\x00\x01a"),`,
+		`"list": []any{1, "two", 3.5, nil, true},`,
+		`"inf": math.Inf(-1),`,
+	} {
+		// gofmt aligns the values of consecutive entries.
+		pattern := strings.Replace(regexp.QuoteMeta(entry), ": ", `:\s+`, 1)
+		assert.Regexp(t, pattern, src)
+	}
+	assert.Contains(t, src, `pool.Bucket("empty")`)
+	assert.Contains(t, src, "\"nested\": map[string]any{\n\t\t\t\"a\": map[string]any{},\n\t\t\t\"b\": []int{1, 2},\n\t\t},")
+	assert.NotContains(t, src, SystemNamespace)
+	typeCheck(t, src)
+
+	var again bytes.Buffer
+	require.NoError(t, pool.ExportGoFixture(&again, "fixtures"))
+	assert.Equal(t, src, again.String(), "The output is reproducible")
+}
+
+func TestExportGoFixtureStructs(t *testing.T) {
+	pool := NewDataPool()
+	headers := pool.Bucket("headers")
+	headers.Put(map[string]*tar.Header{
+		"config": {
+			Name:       "config.json",
+			Mode:       0o644,
+			ModTime:    time.Unix(1700000000, 5),
+			Format:     tar.FormatPAX,
+			PAXRecords: map[string]string{"comment": "primary"},
+		},
+	})
+	link := pool.Bucket("link")
+	link.Put(url.URL{Scheme: "https", Host: "example.com"})
+
+	var buf bytes.Buffer
+	require.NoError(t, pool.ExportGoFixture(&buf, "fixtures"))
+	src := buf.String()
+	assert.Contains(t, src, `"archive/tar"`)
+	assert.Contains(t, src, `"github.com/radamsa/datapool"`)
+	assert.Contains(t, src, `"net/url"`)
+	assert.Contains(t, src, `"time"`)
+	assert.Contains(t, src, "\"config\": &tar.Header{\n")
+	assert.Contains(t, src, "ModTime: time.Unix(1700000000, 5).UTC(),")
+	assert.Contains(t, src, "Format: 4,")
+	assert.Contains(t, src, `"comment": "primary",`)
+	assert.Contains(t, src, "\"link\": url.URL{\n")
+	typeCheck(t, src)
+}
+
+func TestExportGoFixtureEncoded(t *testing.T) {
+	pool := NewDataPool(WithCodec(GobCodec))
+	config := pool.Bucket("config")
+	_, err := config.PutEncoded(cloneConfigValue{Name: "a"})
+	require.NoError(t, err)
+	raw := pool.Bucket("raw")
+	raw.Put(Encoded{Codec: JSONCodec, Data: []byte(`{"a":1}`)})
+
+	var buf bytes.Buffer
+	require.NoError(t, pool.ExportGoFixture(&buf, "fixtures"))
+	src := buf.String()
+	assert.Contains(t, src, `datapool.Encoded{Codec: datapool.JSONCodec, Data: []byte("{\"a\":1}")}`)
+	assert.Contains(t, src, "datapool.Encoded{Codec: datapool.GobCodec, Data: []byte(")
+	assert.NotContains(t, src, "jsonCodec")
+	typeCheck(t, src)
+
+	custom := pool.Bucket("custom")
+	custom.Put(Encoded{Codec: &countingCodec{}, Data: []byte("1")})
+	assert.EqualError(t, pool.ExportGoFixture(&buf, "fixtures"), `datapool: export go fixture: bucket "custom": cannot represent Encoded value of codec json`)
+}
+
+func TestExportGoFixtureUnsupported(t *testing.T) {
+	cyclic := map[string]any{}
+	cyclic["self"] = cyclic
+	loop := []any{nil}
+	loop[0] = loop
+
+	for _, tc := range []struct {
+		value any
+		err   string
+	}{
+		{make(chan int), `datapool: export go fixture: bucket "v": cannot represent chan int`},
+		{func() {}, `datapool: export go fixture: bucket "v": cannot represent func()`},
+		{cyclic, `datapool: export go fixture: bucket "v": cannot represent cyclic values`},
+		{loop, `datapool: export go fixture: bucket "v": cannot represent cyclic values`},
+		{new(int), `datapool: export go fixture: bucket "v": cannot represent *int`},
+		{struct{ n int }{1}, `datapool: export go fixture: bucket "v": cannot represent struct { n int }`},
+		{&fixtureConfig{Name: "a"}, `datapool: export go fixture: bucket "v": cannot represent datapool.fixtureConfig: unexported type`},
+		{jsonCodec{}, `datapool: export go fixture: bucket "v": cannot represent datapool.jsonCodec: unexported type`},
+	} {
+		pool := NewDataPool()
+		b := pool.Bucket("v")
+		b.Put(tc.value)
+		var buf bytes.Buffer
+		assert.EqualError(t, pool.ExportGoFixture(&buf, "fixtures"), tc.err)
+		assert.Zero(t, buf.Len(), "Nothing is written on failure")
+	}
+}
+
+// typeCheck fails the test unless src is a valid Go file.
+func typeCheck(t *testing.T, src string) {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "fixture.go", src, 0)
+	require.NoError(t, err)
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	_, err = conf.Check("fixtures", fset, []*ast.File{f}, nil)
+	require.NoError(t, err, src)
+}
@@ -0,0 +1,42 @@
+package datapool
+
+import "slices"
+
+// Group is a fixed set of buckets read together, such as the pieces of a
+// configuration that must all be up to date. It is created by DataPool.Group.
+type Group struct {
+	pool  *DataPool
+	names []string
+}
+
+// Group returns a handle on the named buckets. Like GetMany, reading the
+// group does not create buckets that do not exist, which read as empty.
+func (p *DataPool) Group(names ...string) *Group {
+	return &Group{pool: p, names: slices.Clone(names)}
+}
+
+// Names returns the names of the group's buckets.
+func (g *Group) Names() []string {
+	return slices.Clone(g.names)
+}
+
+// Get reads every bucket of the group at a single consistent point (see
+// GetMany), reporting for each whether its value is newer than since.
+func (g *Group) Get(since int64) map[string]Result {
+	return g.pool.GetMany(g.names, since)
+}
+
+// Fresh reports whether every bucket of the group holds a value newer than
+// since, as of a single consistent point. An empty group is never fresh.
+func (g *Group) Fresh(since int64) bool {
+	results := g.Get(since)
+	if len(results) == 0 {
+		return false
+	}
+	for _, r := range results {
+		if !r.Fresh {
+			return false
+		}
+	}
+	return true
+}
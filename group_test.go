@@ -0,0 +1,36 @@
+package datapool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup(t *testing.T) {
+	pool := NewDataPool()
+	names := []string{"config/a", "config/b", "config/c"}
+	group := pool.Group(names...)
+	names[0] = "changed"
+	assert.Equal(t, []string{"config/a", "config/b", "config/c"}, group.Names())
+
+	assert.False(t, group.Fresh(0), "Missing buckets are not fresh")
+	assert.Zero(t, pool.Len(), "Reading a group creates no bucket")
+
+	since := pool.PutMany(map[string]any{"config/a": 1, "config/b": 2})["config/a"]
+	assert.False(t, group.Fresh(0))
+	c := pool.Bucket("config/c")
+	ts := c.Put(3)
+	assert.True(t, group.Fresh(0))
+	assert.False(t, group.Fresh(since), "A and B are not newer than since")
+
+	results := group.Get(since)
+	assert.Equal(t, map[string]Result{
+		"config/a": {Value: 1, Timestamp: since},
+		"config/b": {Value: 2, Timestamp: since},
+		"config/c": {Value: 3, Timestamp: ts, Fresh: true},
+	}, results)
+
+	pool.PutMany(map[string]any{"config/a": 10, "config/b": 20})
+	assert.True(t, group.Fresh(since))
+	assert.False(t, pool.Group().Fresh(0), "An empty group is never fresh")
+}
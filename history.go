@@ -0,0 +1,116 @@
+package datapool
+
+import "sort"
+
+// WithHistory makes every new bucket keep its n previous values, as if
+// SetHistory had been called on it at creation, for GetAt. Zero, the
+// default, keeps none.
+func WithHistory(n int) Option {
+	return func(o *options) {
+		o.history = max(n, 0)
+	}
+}
+
+// historyEntry is a value a bucket held before its current one.
+type historyEntry struct {
+	value     any
+	timestamp int64
+	expiresAt int64
+}
+
+// SetHistory makes the bucket keep its n previous values, replaced or
+// dropped by Expire, so GetAt can answer for times before its current value.
+// Zero keeps none, dropping the values kept so far. Kept values are not
+// counted against the memory budget (see WithMemoryBudget).
+func (b *Bucket) SetHistory(n int) {
+	bk := b.resolve("set history")
+	if bk == nil {
+		return
+	}
+
+	bk.guard.Lock()
+	defer bk.guard.Unlock()
+
+	bk.historyLen = max(n, 0)
+	bk.trimHistory()
+}
+
+// GetAt returns the value the bucket held at ts, a pool timestamp such as
+// one returned by Put, along with the timestamp it was stored with, and
+// false if it held none or the value is older than the history the bucket
+// keeps (see SetHistory). Values that had expired by ts are not returned.
+// Values dropped by eviction or memory pressure are not kept, so GetAt
+// returns the value last stored before ts for times they were dropped at.
+func (b *Bucket) GetAt(ts int64) (any, int64, bool) {
+	bk := b.resolve("get at")
+	if bk == nil {
+		return nil, 0, false
+	}
+
+	bk.guard.RLock()
+	defer bk.guard.RUnlock()
+
+	if bk.removed {
+		return nil, 0, false
+	}
+	var e historyEntry
+	ok := bk.timestamp != 0 && bk.timestamp <= ts
+	if ok {
+		e = historyEntry{value: bk.current(), timestamp: bk.timestamp, expiresAt: bk.expiresAt}
+	}
+	for i := len(bk.history) - 1; !ok && i >= 0; i-- {
+		e = bk.history[i]
+		ok = e.timestamp <= ts
+	}
+	if !ok || e.expiresAt != 0 && ts >= e.expiresAt {
+		return nil, 0, false
+	}
+	if bk.copyValues.Load() {
+		e.value = copyValue(e.value)
+	}
+	return e.value, e.timestamp, true
+}
+
+// ChangedSince returns the names, in sorted order, of the buckets outside the
+// SystemNamespace holding a value stored after ts, a pool timestamp such as
+// one returned by Put, for consumers that sync periodically and only want
+// what changed since their last poll. Buckets emptied since ts, by Clear or
+// expiry, are not listed.
+func (p *DataPool) ChangedSince(ts int64) []string {
+	var names []string
+	for _, b := range p.all() {
+		if b.system {
+			continue
+		}
+		b.guard.RLock()
+		changed := b.readTimestamp(p) > ts
+		b.guard.RUnlock()
+		if changed {
+			names = append(names, b.name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// record adds the bucket's current value, about to be replaced or dropped,
+// to its history. It must be called with b.guard held for writing.
+func (b *bucket) record() {
+	if b.historyLen == 0 || b.timestamp == 0 {
+		return
+	}
+	b.history = append(b.history, historyEntry{value: b.current(), timestamp: b.timestamp, expiresAt: b.expiresAt})
+	b.trimHistory()
+}
+
+// trimHistory drops the oldest values beyond the bucket's history length. It
+// must be called with b.guard held for writing.
+func (b *bucket) trimHistory() {
+	if extra := len(b.history) - b.historyLen; extra > 0 {
+		clear(b.history[:extra])
+		b.history = append(b.history[:0], b.history[extra:]...)
+	}
+	if len(b.history) == 0 {
+		b.history = nil
+	}
+}
@@ -0,0 +1,83 @@
+package datapool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAt(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock), WithHistory(2))
+	b := pool.Bucket("config")
+
+	_, _, ok := b.GetAt(clock.Now().UnixNano())
+	assert.False(t, ok, "Empty buckets hold nothing at any time")
+
+	ts1 := b.Put("v1")
+	clock.Advance(time.Second)
+	ts2 := b.Put("v2")
+	clock.Advance(time.Second)
+	ts3 := b.Put("v3")
+
+	value, ts, ok := b.GetAt(ts3 + 1)
+	assert.True(t, ok)
+	assert.Equal(t, "v3", value)
+	assert.Equal(t, ts3, ts)
+	value, ts, ok = b.GetAt(ts3 - 1)
+	assert.True(t, ok)
+	assert.Equal(t, "v2", value, "Values are answered until replaced")
+	assert.Equal(t, ts2, ts)
+	value, _, ok = b.GetAt(ts1)
+	assert.True(t, ok)
+	assert.Equal(t, "v1", value)
+	_, _, ok = b.GetAt(ts1 - 1)
+	assert.False(t, ok)
+
+	clock.Advance(time.Second)
+	b.Put("v4")
+	_, _, ok = b.GetAt(ts1)
+	assert.False(t, ok, "Values beyond the history length are forgotten")
+	value, _, _ = b.GetAt(ts2)
+	assert.Equal(t, "v2", value)
+
+	b.SetHistory(0)
+	_, _, ok = b.GetAt(ts3)
+	assert.False(t, ok)
+}
+
+func TestGetAtExpiry(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	b := pool.Bucket("session")
+	b.SetHistory(4)
+	b.SetTTL(time.Minute)
+
+	ts := b.Put("v1")
+	clock.Advance(2 * time.Minute)
+	_, _, ok := b.GetAt(ts + int64(time.Minute))
+	assert.False(t, ok, "Expired values are not returned")
+	value, _, _ := b.GetAt(ts + int64(30*time.Second))
+	assert.Equal(t, "v1", value)
+
+	assert.Equal(t, 1, pool.Expire())
+	value, _, _ = b.GetAt(ts + int64(30*time.Second))
+	assert.Equal(t, "v1", value, "Values dropped by Expire are kept")
+}
+
+func TestChangedSince(t *testing.T) {
+	pool := NewDataPool()
+	a := pool.Bucket("a")
+	b := pool.Bucket("b")
+	c := pool.Bucket("c")
+	pool.Bucket("empty")
+	a.Put(1)
+	poll := b.Put(1)
+	c.Put(1)
+	a.Put(2)
+
+	assert.Equal(t, []string{"a", "c"}, pool.ChangedSince(poll))
+	assert.Equal(t, []string{"a", "b", "c"}, pool.ChangedSince(0))
+	assert.Empty(t, pool.ChangedSince(pool.lastStamp.Load()))
+}
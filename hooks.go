@@ -0,0 +1,124 @@
+package datapool
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// hookList holds callbacks, or other listeners such as name watchers,
+// registered at run time. The slice is replaced on every change, so they are
+// called from a snapshot without holding mu.
+type hookList[F any] struct {
+	mu     sync.RWMutex
+	nextID int
+	hooks  []hook[F]
+}
+
+type hook[F any] struct {
+	id int
+	fn F
+}
+
+// add registers fn and returns a function removing it.
+func (l *hookList[F]) add(fn F) func() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.nextID++
+	id := l.nextID
+	l.hooks = append(slices.Clip(l.hooks), hook[F]{id, fn})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.hooks = slices.DeleteFunc(slices.Clone(l.hooks), func(h hook[F]) bool { return h.id == id })
+		})
+	}
+}
+
+// snapshot returns the registered callbacks, which must not be modified.
+func (l *hookList[F]) snapshot() []hook[F] {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.hooks
+}
+
+// hooks are the event callbacks registered with OnPut, OnExpire and OnEvict.
+type hooks struct {
+	put    hookList[func(name string, value any, ts int64)]
+	expire hookList[func(name string, value any)]
+	evict  hookList[func(name string, value any)]
+	audit  hookList[func(b *bucket, u Update)]
+	// publish is only called for values written locally, not for values
+	// applied from a backend or a bridge.
+	publish hookList[func(b *bucket, u Update)]
+}
+
+// OnPut registers fn to be called after every value stored in the pool, by
+// Put or any other write including values applied from a backend, with the
+// bucket name, the value and its timestamp. It runs outside of all pool
+// locks, after the callback set with WithPutCallback, on the goroutine that
+// stored the value. The returned function unregisters fn.
+func (p *DataPool) OnPut(fn func(name string, value any, ts int64)) (remove func()) {
+	return p.hooks.put.add(fn)
+}
+
+// OnExpire registers fn to be called with the name and value of every expired
+// value dropped by Expire. Expired values are not dropped, and fn not called,
+// until Expire runs. It runs outside of all pool locks, on the goroutine
+// calling Expire. The returned function unregisters fn.
+func (p *DataPool) OnExpire(fn func(name string, value any)) (remove func()) {
+	return p.hooks.expire.add(fn)
+}
+
+// OnEvict registers fn to be called with the name and last value of every
+// bucket evicted to respect WithMaxBuckets, and of every value dropped under
+// memory pressure (see WithMemoryPressure). It runs outside of all pool
+// locks, after the callback set with WithEvictionCallback, on the goroutine
+// that caused the eviction. The returned function unregisters fn.
+func (p *DataPool) OnEvict(fn func(name string, value any)) (remove func()) {
+	return p.hooks.evict.add(fn)
+}
+
+// firePut calls the put callbacks. It must be called without holding any
+// pool lock.
+func (p *DataPool) firePut(name string, value any, ts int64) {
+	if fn := p.opts.onPut; fn != nil {
+		fn(name, value, ts)
+	}
+	for _, h := range p.hooks.put.snapshot() {
+		h.fn(name, value, ts)
+	}
+}
+
+// fireExpire calls the expiry callbacks. It must be called without holding
+// any pool lock.
+func (p *DataPool) fireExpire(name string, value any) {
+	p.logEvent(LogExpire, name)
+	for _, h := range p.hooks.expire.snapshot() {
+		h.fn(name, value)
+	}
+}
+
+// fireEvict calls the eviction callbacks; maxBuckets reports whether the
+// bucket itself was evicted to respect WithMaxBuckets, which the callback set
+// with WithEvictionCallback is limited to. It must be called without holding
+// any pool lock.
+func (p *DataPool) fireEvict(name string, value any, maxBuckets bool) {
+	if p.opts.logger != nil {
+		reason := "memory"
+		if maxBuckets {
+			reason = "max-buckets"
+		}
+		p.logEvent(LogEvict, name, slog.String("reason", reason))
+	}
+	if fn := p.opts.onEvict; fn != nil && maxBuckets {
+		fn(name, value)
+	}
+	for _, h := range p.hooks.evict.snapshot() {
+		h.fn(name, value)
+	}
+}
@@ -0,0 +1,104 @@
+package datapool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnPut(t *testing.T) {
+	var fromOption, first, second []string
+	pool := NewDataPool(WithPutCallback(func(name string, value any, ts int64) {
+		fromOption = append(fromOption, name)
+	}))
+	remove := pool.OnPut(func(name string, value any, ts int64) {
+		first = append(first, name)
+		// Callbacks run outside of bucket locks, so they can use the bucket.
+		b := pool.Bucket(name)
+		got, gotTS, _ := b.Get(0)
+		assert.Equal(t, value, got)
+		assert.Equal(t, ts, gotTS)
+	})
+	pool.OnPut(func(name string, value any, ts int64) {
+		second = append(second, name)
+	})
+
+	a := pool.Bucket("a")
+	a.Put(1)
+	remove()
+	remove()
+	pool.PutMany(map[string]any{"b": 2})
+
+	assert.Equal(t, []string{"a", "b"}, fromOption)
+	assert.Equal(t, []string{"a"}, first, "Removed callbacks are not called")
+	assert.Equal(t, []string{"a", "b"}, second)
+}
+
+func TestOnPutFromBackend(t *testing.T) {
+	ctx := context.Background()
+	backend := &MemoryBackend{}
+	require.NoError(t, backend.Put(ctx, Update{Bucket: "remote", Value: "v", Timestamp: 1}))
+	pool := NewDataPool(WithBackend(backend))
+	var puts []any
+	pool.OnPut(func(name string, value any, ts int64) {
+		puts = append(puts, value)
+	})
+
+	remote := pool.Bucket("remote")
+	remote.Get(0)
+	assert.Equal(t, []any{"v"}, puts, "Values read through from the backend are reported")
+}
+
+func TestOnExpire(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock), WithDefaultTTL(time.Minute))
+	expired := map[string]any{}
+	pool.OnExpire(func(name string, value any) {
+		expired[name] = value
+		// Callbacks run outside of bucket locks.
+		b := pool.Bucket(name)
+		b.Put("renewed")
+	})
+
+	session := pool.Bucket("session")
+	session.Put("token")
+	assert.Equal(t, 0, pool.Expire())
+	assert.Empty(t, expired)
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, 1, pool.Expire())
+	assert.Equal(t, map[string]any{"session": "token"}, expired)
+	val, _, _ := session.Get(0)
+	assert.Equal(t, "renewed", val)
+}
+
+func TestOnEvict(t *testing.T) {
+	var fromOption []string
+	evicted := map[string]any{}
+	pool := NewDataPool(
+		WithMaxBuckets(1),
+		WithMemoryPressure(PressureConfig{
+			Pressure: func() float64 { return 0 },
+			Fraction: 1,
+		}),
+		WithEvictionCallback(func(name string, value any) {
+			fromOption = append(fromOption, name)
+		}),
+	)
+	pool.OnEvict(func(name string, value any) {
+		evicted[name] = value
+	})
+
+	old := pool.Bucket("old")
+	old.Put(1)
+	current := pool.Bucket("new")
+	current.Put(2)
+	assert.Equal(t, map[string]any{"old": 1}, evicted)
+
+	assert.Equal(t, 1, pool.RelieveMemoryPressure())
+	assert.Equal(t, map[string]any{"old": 1, "new": 2}, evicted, "Values dropped under memory pressure are evicted too")
+	assert.Equal(t, []string{"old"}, fromOption, "WithEvictionCallback only sees buckets evicted by WithMaxBuckets")
+}
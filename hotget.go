@@ -0,0 +1,153 @@
+package datapool
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HotGetConfig configures the detection of callers reading a bucket at
+// pathological rates (see WithHotGetDetection), typically polling loops that
+// should Watch the bucket instead.
+type HotGetConfig struct {
+	// Threshold is the number of Gets of a single bucket from a single caller
+	// within Window above which the caller is flagged. Defaults to 1000.
+	Threshold int
+
+	// Window is the period Gets are counted over. Defaults to one second.
+	Window time.Duration
+
+	// SampleRate is how many Gets are counted for each one attributed to its
+	// caller, since finding the caller walks the stack: Gets are attributed
+	// at random, one in SampleRate on average. Defaults to 64; 1 attributes
+	// every Get.
+	SampleRate int
+
+	// Logger receives a warning for every flagged caller and bucket, at most
+	// once per window. slog.Default() is used when nil.
+	Logger *slog.Logger
+}
+
+// HotGetRecorder is implemented by MetricsRecorders that count hot Get
+// callers. RecordHotGet is called, outside of pool locks, whenever a caller
+// is flagged, with the caller's label (see WithHotGetDetection) and its
+// estimated Gets per second.
+type HotGetRecorder interface {
+	RecordHotGet(bucket, caller string, rate float64)
+}
+
+// hotGetDetector counts sampled Gets by bucket and caller.
+type hotGetDetector struct {
+	cfg HotGetConfig
+
+	mu     sync.Mutex
+	counts map[hotGetKey]*hotGetCount
+	labels map[uintptr]string
+}
+
+// hotGetKey identifies a caller of a bucket by its label rather than its
+// program counter, since a call inlined in several places has several.
+type hotGetKey struct {
+	bucket string
+	caller string
+}
+
+type hotGetCount struct {
+	since   int64
+	gets    int
+	flagged bool
+}
+
+// maxHotGetCounts bounds the counts kept before those of past windows are
+// dropped.
+const maxHotGetCounts = 1024
+
+func newHotGetDetector(cfg HotGetConfig) *hotGetDetector {
+	return &hotGetDetector{
+		cfg:    cfg,
+		counts: make(map[hotGetKey]*hotGetCount),
+		labels: make(map[uintptr]string),
+	}
+}
+
+// observe counts a Get of the named bucket, attributing one in SampleRate to
+// its caller, and flags the caller if it crossed the threshold.
+func (d *hotGetDetector) observe(p *DataPool, name string) {
+	// Sampling at random keeps concurrent Gets from contending on a counter.
+	if d.cfg.SampleRate > 1 && rand.IntN(d.cfg.SampleRate) != 0 {
+		return
+	}
+	pc := getCaller()
+	now := p.now()
+	window := int64(d.cfg.Window)
+
+	d.mu.Lock()
+	key := hotGetKey{bucket: name, caller: d.label(pc)}
+	c := d.counts[key]
+	if c == nil {
+		if len(d.counts) >= maxHotGetCounts {
+			for k, old := range d.counts {
+				if now-old.since >= window {
+					delete(d.counts, k)
+				}
+			}
+		}
+		c = &hotGetCount{since: now}
+		d.counts[key] = c
+	}
+	if now-c.since >= window {
+		c.since, c.gets, c.flagged = now, 0, false
+	}
+	c.gets += d.cfg.SampleRate
+	flag := c.gets > d.cfg.Threshold && !c.flagged
+	if flag {
+		c.flagged = true
+	}
+	rate := float64(c.gets) / d.cfg.Window.Seconds()
+	d.mu.Unlock()
+
+	if !flag {
+		return
+	}
+	d.cfg.Logger.Warn("datapool: hot Get loop, consider Watch", "bucket", name, "caller", key.caller, "rate", rate)
+	if r, ok := p.opts.metrics.(HotGetRecorder); ok {
+		r.RecordHotGet(name, key.caller, rate)
+	}
+}
+
+// label returns the label of the caller at pc, as the function name and the
+// file and line of the call. It must be called with d.mu held.
+func (d *hotGetDetector) label(pc uintptr) string {
+	if label, ok := d.labels[pc]; ok {
+		return label
+	}
+	label := "unknown"
+	if pc != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		label = fmt.Sprintf("%s (%s:%d)", frame.Function, filepath.Base(frame.File), frame.Line)
+	}
+	d.labels[pc] = label
+	return label
+}
+
+// getCaller returns the program counter of the first caller outside this
+// package, its tests aside, zero if there is none.
+func getCaller() uintptr {
+	var pcs [16]uintptr
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, datapoolPath+".") || strings.HasSuffix(frame.File, "_test.go") {
+			return frame.PC
+		}
+		if !more {
+			return 0
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package datapool
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type hotGetRecorder struct {
+	*recordingMetrics
+	callers []string
+}
+
+func (r *hotGetRecorder) RecordHotGet(bucket, caller string, rate float64) {
+	r.callers = append(r.callers, bucket+" "+caller)
+}
+
+func pollRates(b *Bucket, n int) {
+	for range n {
+		b.Get(0)
+	}
+}
+
+func readRatesOnce(b *Bucket) {
+	b.Get(0)
+}
+
+func TestHotGetDetection(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	recorder := &hotGetRecorder{recordingMetrics: newRecordingMetrics()}
+	var logs bytes.Buffer
+	pool := NewDataPool(
+		WithClock(clock),
+		WithMetrics(recorder),
+		WithHotGetDetection(HotGetConfig{
+			Threshold:  10,
+			Window:     time.Second,
+			SampleRate: 1,
+			Logger:     slog.New(slog.NewTextHandler(&logs, nil)),
+		}),
+	)
+	rates := pool.Bucket("rates")
+	rates.Put(1.1)
+
+	pollRates(&rates, 50)
+	for range 5 {
+		readRatesOnce(&rates)
+	}
+	require.Len(t, recorder.callers, 1, "A hot caller is flagged once per window")
+	assert.Contains(t, recorder.callers[0], "rates ")
+	assert.Contains(t, recorder.callers[0], "pollRates")
+	assert.Contains(t, recorder.callers[0], "hotget_test.go:")
+	assert.Contains(t, logs.String(), "hot Get loop")
+	assert.Contains(t, logs.String(), "pollRates")
+	assert.NotContains(t, logs.String(), "readRatesOnce")
+
+	pollRates(&rates, 5)
+	clock.Advance(time.Second)
+	pollRates(&rates, 50)
+	assert.Len(t, recorder.callers, 2, "The caller is flagged again in a new window")
+}
+
+func TestHotGetDetectionSampling(t *testing.T) {
+	recorder := &hotGetRecorder{recordingMetrics: newRecordingMetrics()}
+	pool := NewDataPool(
+		WithClock(NewManualClock(time.Unix(1000, 0))),
+		WithMetrics(recorder),
+		WithHotGetDetection(HotGetConfig{
+			Threshold:  1000,
+			SampleRate: 8,
+			Logger:     slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)),
+		}),
+	)
+	rates := pool.Bucket("rates")
+
+	pollRates(&rates, 400)
+	assert.Empty(t, recorder.callers)
+	pollRates(&rates, 2000)
+	assert.Len(t, recorder.callers, 1, "Sampled Gets count for the whole sample")
+}
+
+func TestHotGetDetectionOff(t *testing.T) {
+	pool := NewDataPool()
+	assert.Nil(t, pool.hotGets)
+}
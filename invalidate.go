@@ -0,0 +1,303 @@
+package datapool
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InvalidationTarget is an external cache holding data derived from buckets,
+// such as CDN paths or Redis keys, that an Invalidator purges when the
+// buckets change.
+type InvalidationTarget interface {
+	// Name identifies the target in errors and metrics.
+	Name() string
+
+	// Invalidate purges keys from the cache. It may be called again with the
+	// same keys after failing, so it should be idempotent.
+	Invalidate(ctx context.Context, keys []string) error
+}
+
+// InvalidateFunc returns an InvalidationTarget named name calling fn.
+func InvalidateFunc(name string, fn func(ctx context.Context, keys []string) error) InvalidationTarget {
+	return &funcTarget{name: name, fn: fn}
+}
+
+type funcTarget struct {
+	name string
+	fn   func(ctx context.Context, keys []string) error
+}
+
+func (t *funcTarget) Name() string { return t.name }
+
+func (t *funcTarget) Invalidate(ctx context.Context, keys []string) error {
+	return t.fn(ctx, keys)
+}
+
+// InvalidationRecorder is implemented by metrics recorders that also track
+// invalidations (see Invalidator). RecordInvalidation is called once per call
+// to a target that succeeded or ran out of attempts, with the number of keys,
+// the number of attempts made and the last error, nil on success.
+type InvalidationRecorder interface {
+	RecordInvalidation(target string, keys, attempts int, err error)
+}
+
+// InvalidationStats counts the keys an Invalidator purged.
+type InvalidationStats struct {
+	// Invalidated is the number of keys purged.
+	Invalidated uint64
+	// Failed is the number of keys given up on after the last attempt.
+	Failed uint64
+	// Retries is the number of attempts made after a failed one.
+	Retries uint64
+}
+
+// InvalidationOption configures an Invalidator.
+type InvalidationOption func(*Invalidator)
+
+// WithInvalidationAttempts sets how many times a target is called for a
+// batch of keys before giving up. The default is 3.
+func WithInvalidationAttempts(n int) InvalidationOption {
+	return func(inv *Invalidator) {
+		if n > 0 {
+			inv.attempts = n
+		}
+	}
+}
+
+// WithInvalidationBackoff sets the wait after the first failed attempt, which
+// doubles after each further one. The default is 100ms.
+func WithInvalidationBackoff(d time.Duration) InvalidationOption {
+	return func(inv *Invalidator) {
+		if d > 0 {
+			inv.backoff = d
+		}
+	}
+}
+
+// WithInvalidationTimeout bounds every call to a target. The default is 5s.
+func WithInvalidationTimeout(d time.Duration) InvalidationOption {
+	return func(inv *Invalidator) {
+		if d > 0 {
+			inv.timeout = d
+		}
+	}
+}
+
+// Invalidator purges the entries of external caches that depend on buckets
+// whenever the buckets change, as mapped with Map.
+type Invalidator struct {
+	pool     *DataPool
+	attempts int
+	backoff  time.Duration
+	timeout  time.Duration
+
+	mu       sync.Mutex
+	mappings []invalidation
+	targets  []InvalidationTarget
+	// pending holds the keys to purge by index in targets, so that changes
+	// made while a target is purged are purged together once it is done.
+	pending map[int]map[string]struct{}
+	stats   InvalidationStats
+	wake    chan struct{}
+}
+
+// invalidation maps the buckets matching pattern to keys of the target at
+// index target.
+type invalidation struct {
+	pattern string
+	target  int
+	keys    []string
+}
+
+// NewInvalidator returns an Invalidator for the pool's buckets, which purges
+// caches once mappings are added and it runs.
+func (p *DataPool) NewInvalidator(opts ...InvalidationOption) *Invalidator {
+	inv := &Invalidator{
+		pool:     p,
+		attempts: 3,
+		backoff:  100 * time.Millisecond,
+		timeout:  5 * time.Second,
+		pending:  make(map[int]map[string]struct{}),
+		wake:     make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(inv)
+	}
+	return inv
+}
+
+// Map makes a change to a bucket whose name matches pattern, in the syntax of
+// path.Match as for WatchMatch, purge keys from target. Keys may contain
+// "{bucket}", which is replaced with the bucket's name; without keys, the
+// bucket's name is purged. It fails if pattern is malformed.
+func (inv *Invalidator) Map(pattern string, target InvalidationTarget, keys ...string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("datapool: invalidate %q: %w", pattern, err)
+	}
+	if len(keys) == 0 {
+		keys = []string{"{bucket}"}
+	}
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.mappings = append(inv.mappings, invalidation{pattern: pattern, target: inv.targetIndex(target), keys: slices.Clone(keys)})
+	return nil
+}
+
+// targetIndex returns the index of target in inv.targets, adding it if it is
+// new. Targets of types that cannot be compared are new every time. It must
+// be called with inv.mu held.
+func (inv *Invalidator) targetIndex(target InvalidationTarget) int {
+	if reflect.TypeOf(target).Comparable() {
+		for i, t := range inv.targets {
+			if reflect.TypeOf(t) == reflect.TypeOf(target) && t == target {
+				return i
+			}
+		}
+	}
+	inv.targets = append(inv.targets, target)
+	return len(inv.targets) - 1
+}
+
+// Run purges the mapped keys of every bucket that changes, by a value stored
+// in it, including values applied from a backend, or by an expired value
+// dropped by Expire, until ctx is done. It then purges what is pending once,
+// within the invalidation timeout, and returns ctx.Err(). Keys of several
+// changes are purged together while a target is busy. Calls that fail are
+// retried with backoff; errors are passed to onError, if it is not nil, once
+// a target runs out of attempts.
+func (inv *Invalidator) Run(ctx context.Context, onError func(error)) error {
+	p := inv.pool
+	removePut := p.hooks.put.add(func(name string, _ any, _ int64) { inv.changed(name) })
+	defer removePut()
+	removeExpire := p.hooks.expire.add(func(name string, _ any) { inv.changed(name) })
+	defer removeExpire()
+
+	for {
+		select {
+		case <-ctx.Done():
+			removePut()
+			removeExpire()
+			final, cancel := context.WithTimeout(context.WithoutCancel(ctx), inv.timeout)
+			inv.flush(final, 1, onError)
+			cancel()
+			return ctx.Err()
+		case <-inv.wake:
+			inv.flush(ctx, inv.attempts, onError)
+		}
+	}
+}
+
+// Stats returns how many keys were purged so far.
+func (inv *Invalidator) Stats() InvalidationStats {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	return inv.stats
+}
+
+// changed queues the keys mapped to the named bucket.
+func (inv *Invalidator) changed(name string) {
+	if isSystem(name) {
+		return
+	}
+	if inv.queue(name) {
+		select {
+		case inv.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// queue adds the keys mapped to the named bucket to inv.pending, reporting
+// whether there were any.
+func (inv *Invalidator) queue(name string) bool {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	queued := false
+	for _, m := range inv.mappings {
+		if ok, _ := path.Match(m.pattern, name); !ok {
+			continue
+		}
+		keys := inv.pending[m.target]
+		if keys == nil {
+			keys = make(map[string]struct{})
+			inv.pending[m.target] = keys
+		}
+		for _, key := range m.keys {
+			keys[strings.ReplaceAll(key, "{bucket}", name)] = struct{}{}
+		}
+		queued = true
+	}
+	return queued
+}
+
+// flush purges the pending keys, target by target, making up to attempts
+// calls to each.
+func (inv *Invalidator) flush(ctx context.Context, attempts int, onError func(error)) {
+	inv.mu.Lock()
+	pending := inv.pending
+	inv.pending = make(map[int]map[string]struct{})
+	targets := inv.targets
+	inv.mu.Unlock()
+
+	for i, set := range pending {
+		keys := make([]string, 0, len(set))
+		for key := range set {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		if err := inv.purge(ctx, targets[i], keys, attempts); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// purge calls target with keys until it succeeds, attempts calls were made or
+// ctx is done.
+func (inv *Invalidator) purge(ctx context.Context, target InvalidationTarget, keys []string, attempts int) error {
+	var err error
+	made := 0
+	wait := inv.backoff
+	for made < attempts {
+		if made > 0 {
+			inv.mu.Lock()
+			inv.stats.Retries++
+			inv.mu.Unlock()
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			wait *= 2
+		}
+		made++
+		call, cancel := context.WithTimeout(ctx, inv.timeout)
+		err = target.Invalidate(call, keys)
+		cancel()
+		if err == nil {
+			break
+		}
+	}
+
+	inv.mu.Lock()
+	if err == nil {
+		inv.stats.Invalidated += uint64(len(keys))
+	} else {
+		inv.stats.Failed += uint64(len(keys))
+	}
+	inv.mu.Unlock()
+	if r, ok := inv.pool.opts.metrics.(InvalidationRecorder); ok {
+		r.RecordInvalidation(target.Name(), len(keys), made, err)
+	}
+	if err != nil {
+		return fmt.Errorf("datapool: invalidate %d keys of %s after %d attempts: %w", len(keys), target.Name(), made, err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTarget records the keys it is asked to purge, failing the first
+// fails calls.
+type recordingTarget struct {
+	name  string
+	mu    sync.Mutex
+	fails int
+	calls [][]string
+}
+
+func (r *recordingTarget) Name() string { return r.name }
+
+func (r *recordingTarget) Invalidate(_ context.Context, keys []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, keys)
+	if r.fails > 0 {
+		r.fails--
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func (r *recordingTarget) called() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]string(nil), r.calls...)
+}
+
+// invalidationMetrics records the invalidations of a pool.
+type invalidationMetrics struct {
+	*recordingMetrics
+	mu    sync.Mutex
+	calls []string
+}
+
+func (m *invalidationMetrics) RecordInvalidation(target string, keys, attempts int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, fmt.Sprintf("%s keys=%d attempts=%d err=%v", target, keys, attempts, err))
+}
+
+// runInvalidator runs inv until the test ends, returning once it records
+// changes.
+func runInvalidator(t *testing.T, inv *Invalidator, onError func(error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	hooks := len(inv.pool.hooks.expire.snapshot())
+	go func() {
+		defer close(done)
+		inv.Run(ctx, onError)
+	}()
+	require.Eventually(t, func() bool {
+		return len(inv.pool.hooks.expire.snapshot()) > hooks
+	}, time.Second, time.Millisecond)
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestInvalidator(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	cdn := &recordingTarget{name: "cdn"}
+	redis := &recordingTarget{name: "redis"}
+	inv := pool.NewInvalidator()
+	require.NoError(t, inv.Map("pricing", cdn, "/api/pricing", "/pricing.html"))
+	require.NoError(t, inv.Map("user/*", redis, "profile:{bucket}"))
+	require.NoError(t, inv.Map("user/*", cdn))
+	runInvalidator(t, inv, nil)
+
+	pricing := pool.Bucket("pricing")
+	pricing.Put(1)
+	require.Eventually(t, func() bool { return len(cdn.called()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"/api/pricing", "/pricing.html"}, cdn.called()[0])
+
+	user := pool.Bucket("user/42")
+	user.SetTTL(time.Minute)
+	user.Put("alice")
+	require.Eventually(t, func() bool { return len(redis.called()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"profile:user/42"}, redis.called()[0])
+	require.Eventually(t, func() bool { return len(cdn.called()) == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"user/42"}, cdn.called()[1], "Keys default to the bucket name")
+
+	clock.Advance(2 * time.Minute)
+	pool.Expire()
+	require.Eventually(t, func() bool { return len(redis.called()) == 2 && len(cdn.called()) == 3 }, time.Second, time.Millisecond, "Expired values invalidate")
+
+	other := pool.Bucket("other")
+	other.Put(1)
+	system := pool.Bucket(SystemStatsBucket)
+	system.Get(0)
+	assert.Equal(t, InvalidationStats{Invalidated: 6}, inv.Stats())
+
+	assert.ErrorContains(t, inv.Map("[", cdn), `datapool: invalidate "["`)
+}
+
+func TestInvalidatorRetries(t *testing.T) {
+	metrics := &invalidationMetrics{recordingMetrics: newRecordingMetrics()}
+	pool := NewDataPool(WithMetrics(metrics))
+	flaky := &recordingTarget{name: "cdn", fails: 1}
+	down := &recordingTarget{name: "redis", fails: 10}
+	inv := pool.NewInvalidator(WithInvalidationAttempts(2), WithInvalidationBackoff(time.Millisecond))
+	require.NoError(t, inv.Map("a", flaky))
+	require.NoError(t, inv.Map("b", down))
+	errs := make(chan error, 10)
+	runInvalidator(t, inv, func(err error) { errs <- err })
+
+	a := pool.Bucket("a")
+	a.Put(1)
+	require.Eventually(t, func() bool { return len(flaky.called()) == 2 }, time.Second, time.Millisecond)
+	b := pool.Bucket("b")
+	b.Put(1)
+	err := <-errs
+	assert.ErrorContains(t, err, "datapool: invalidate 1 keys of redis after 2 attempts: unavailable")
+	assert.Len(t, down.called(), 2)
+	assert.Equal(t, InvalidationStats{Invalidated: 1, Failed: 1, Retries: 2}, inv.Stats())
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	assert.Equal(t, []string{
+		"cdn keys=1 attempts=2 err=<nil>",
+		"redis keys=1 attempts=2 err=unavailable",
+	}, metrics.calls)
+}
+
+func TestInvalidatorCoalesces(t *testing.T) {
+	pool := NewDataPool()
+	block := make(chan struct{})
+	var mu sync.Mutex
+	var calls [][]string
+	target := InvalidateFunc("cdn", func(_ context.Context, keys []string) error {
+		mu.Lock()
+		calls = append(calls, keys)
+		first := len(calls) == 1
+		mu.Unlock()
+		if first {
+			<-block
+		}
+		return nil
+	})
+	inv := pool.NewInvalidator()
+	require.NoError(t, inv.Map("*", target))
+	runInvalidator(t, inv, nil)
+
+	a := pool.Bucket("a")
+	a.Put(1)
+	require.Eventually(t, func() bool { mu.Lock(); defer mu.Unlock(); return len(calls) == 1 }, time.Second, time.Millisecond)
+	b := pool.Bucket("b")
+	for i := range 3 {
+		a.Put(i)
+		b.Put(i)
+	}
+	close(block)
+	require.Eventually(t, func() bool { mu.Lock(); defer mu.Unlock(); return len(calls) == 2 }, time.Second, time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"a", "b"}, calls[1], "Changes made while the target is busy are purged together")
+}
@@ -0,0 +1,59 @@
+package datapool
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvariant is wrapped by every error reported for an internal invariant
+// violation, such as a Bucket handle that points outside of its pool.
+var ErrInvariant = errors.New("datapool: invariant violation")
+
+// PanicPolicy controls what the pool does when it detects that one of its
+// internal invariants does not hold.
+type PanicPolicy int
+
+const (
+	// ReportViolations makes the failing operation return its zero result (or an
+	// error wrapping ErrInvariant where the API allows it) and increments the
+	// corruption counter reported by Corruptions. This is the default and is
+	// meant for production.
+	ReportViolations PanicPolicy = iota
+
+	// PanicOnViolation panics as soon as a violation is detected, so bugs
+	// surface at their origin during development and tests.
+	PanicOnViolation
+)
+
+// String returns the name of the policy.
+func (p PanicPolicy) String() string {
+	switch p {
+	case ReportViolations:
+		return "report"
+	case PanicOnViolation:
+		return "panic"
+	default:
+		return fmt.Sprintf("PanicPolicy(%d)", int(p))
+	}
+}
+
+// Corruptions returns how many invariant violations the pool has detected
+// since it was created.
+func (p *DataPool) Corruptions() uint64 {
+	return p.corruptions.Load()
+}
+
+// violation handles a broken invariant according to the pool's panic policy.
+// Under ReportViolations it counts the violation and returns an error wrapping
+// ErrInvariant for the caller to propagate.
+func (p *DataPool) violation(format string, args ...any) error {
+	err := fmt.Errorf("%w: %s", ErrInvariant, fmt.Sprintf(format, args...))
+	if p.opts.panicPolicy == PanicOnViolation {
+		panic(err)
+	}
+	p.corruptions.Add(1)
+	if m := p.opts.metrics; m != nil {
+		m.RecordCorruption()
+	}
+	return err
+}
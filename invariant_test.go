@@ -0,0 +1,53 @@
+package datapool
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultPanicPolicyReports(t *testing.T) {
+	pool := NewDataPool()
+	assert.Equal(t, ReportViolations, pool.opts.panicPolicy)
+
+	invalid := Bucket{pool: pool}
+	assert.NotPanics(t, func() {
+		invalid.Get(0)
+		invalid.Put("x")
+	})
+	assert.Equal(t, uint64(2), pool.Corruptions())
+}
+
+func TestPanicOnViolation(t *testing.T) {
+	pool := NewDataPool(WithPanicPolicy(PanicOnViolation))
+
+	invalid := Bucket{pool: pool}
+	assert.PanicsWithError(t, "datapool: invariant violation: get: bucket handle has no bucket", func() {
+		invalid.Get(0)
+	})
+	assert.Equal(t, uint64(0), pool.Corruptions(), "Panicking violations are not counted")
+}
+
+func TestViolationWrapsErrInvariant(t *testing.T) {
+	pool := NewDataPool()
+
+	err := pool.violation("bucket %q is broken", "x")
+	assert.True(t, errors.Is(err, ErrInvariant))
+	assert.Equal(t, `datapool: invariant violation: bucket "x" is broken`, err.Error())
+}
+
+func TestValidBucketsDoNotCountCorruptions(t *testing.T) {
+	pool := NewDataPool(WithPanicPolicy(PanicOnViolation))
+	bucket := pool.Bucket("test")
+
+	bucket.Put(1)
+	bucket.Get(0)
+	assert.Equal(t, uint64(0), pool.Corruptions())
+}
+
+func TestPanicPolicyString(t *testing.T) {
+	assert.Equal(t, "report", ReportViolations.String())
+	assert.Equal(t, "panic", PanicOnViolation.String())
+	assert.Equal(t, "PanicPolicy(9)", PanicPolicy(9).String())
+}
@@ -0,0 +1,149 @@
+package datapool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Loader fetches the value of the named bucket from a source of truth, such
+// as a database, when the pool holds none. A nil value with a nil error means
+// the source has no value either, and nothing is stored unless the pool
+// caches load failures (see WithNegativeTTL).
+type Loader func(name string) (any, error)
+
+// Writer propagates a value Put to the named bucket to a source of truth.
+type Writer func(name string, value any) error
+
+// SetLoader sets the loader called when Get finds the bucket empty, which
+// overrides the pool's loader (see WithLoader). A nil loader restores the
+// pool's.
+func (b *Bucket) SetLoader(load Loader) {
+	bk := b.resolve("set loader")
+	if bk == nil {
+		return
+	}
+
+	bk.guard.Lock()
+	defer bk.guard.Unlock()
+
+	bk.loader = load
+	bk.forgetRead()
+}
+
+// SetWriter sets the writer called with every value Put to the bucket, which
+// overrides the pool's writer (see WithWriter). A nil writer restores the
+// pool's.
+func (b *Bucket) SetWriter(write Writer) {
+	bk := b.resolve("set writer")
+	if bk == nil {
+		return
+	}
+
+	bk.guard.Lock()
+	defer bk.guard.Unlock()
+
+	bk.writer = write
+}
+
+// loadCall is a load in progress, shared by concurrent misses of one bucket.
+type loadCall struct {
+	done  chan struct{}
+	value any
+	ts    int64
+}
+
+// loads tracks the loads in progress of a pool.
+type loads struct {
+	mu    sync.Mutex
+	calls map[*bucket]*loadCall
+}
+
+// loadThrough fills an empty bucket from load and returns the stored value,
+// or a zero timestamp if there is none. Concurrent misses of the same bucket
+// share one call to load.
+func (p *DataPool) loadThrough(b *bucket, load Loader) (any, int64) {
+	p.loads.mu.Lock()
+	if c, ok := p.loads.calls[b]; ok {
+		p.loads.mu.Unlock()
+		<-c.done
+		return c.value, c.ts
+	}
+	if p.loads.calls == nil {
+		p.loads.calls = make(map[*bucket]*loadCall)
+	}
+	c := &loadCall{done: make(chan struct{})}
+	p.loads.calls[b] = c
+	p.loads.mu.Unlock()
+
+	defer func() {
+		p.loads.mu.Lock()
+		delete(p.loads.calls, b)
+		p.loads.mu.Unlock()
+		close(c.done)
+	}()
+
+	value, err := p.runLoader(b.name(), load)
+	if err != nil {
+		p.reportError(b.name(), fmt.Errorf("datapool: load %q: %w", b.name(), err))
+	} else if value == nil {
+		err = ErrNoValue
+	}
+	if err != nil {
+		if p.opts.negativeTTL > 0 {
+			// Cache the failure, so Gets do not call the loader again until
+			// it expires.
+			c.ts, _ = p.storeError(b, err, p.opts.negativeTTL)
+		}
+		return nil, c.ts
+	}
+	u, err := p.writeAt(context.Background(), b, Update{Value: value, Provenance: []Source{{Kind: SourceLoader, At: p.opts.clock.Now()}}}, 0, ConsistencyDefault)
+	if err != nil {
+		p.reportError(b.name(), err)
+	}
+	if u.Timestamp != 0 {
+		c.value, c.ts = u.Value, u.Timestamp
+	}
+	return c.value, c.ts
+}
+
+// runLoader calls load for the named bucket within the loader concurrency
+// limit of its namespace, if any (see WithLoaderConcurrency).
+func (p *DataPool) runLoader(name string, load Loader) (value any, err error) {
+	if limit := p.loadLimits[Namespace(name)]; limit != nil {
+		limit.acquire()
+		defer limit.release()
+	}
+	_, s := p.startSpan(context.Background(), SpanLoad, name)
+	defer func() {
+		s.hit(err == nil && value != nil, value)
+		s.end(err)
+	}()
+	return load(name)
+}
+
+// writeSource passes a value Put to the bucket to its writer, if it has one.
+// Values that came from a loader are not written back.
+func (p *DataPool) writeSource(b *bucket, value any, chain []Source) {
+	if n := len(chain); n > 0 && chain[n-1].Kind == SourceLoader {
+		return
+	}
+
+	b.guard.RLock()
+	write := b.writer
+	b.guard.RUnlock()
+	if write == nil {
+		write = p.opts.writer
+	}
+	if write == nil {
+		return
+	}
+
+	_, s := p.startSpan(context.Background(), SpanWrite, b.name())
+	s.size(value)
+	err := write(b.name(), value)
+	s.end(err)
+	if err != nil {
+		p.reportError(b.name(), fmt.Errorf("datapool: write %q: %w", b.name(), err))
+	}
+}
@@ -0,0 +1,149 @@
+package datapool
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoader(t *testing.T) {
+	db := map[string]any{"users/1": "Alice"}
+	var calls atomic.Int32
+	pool := NewDataPool(WithLoader(func(name string) (any, error) {
+		calls.Add(1)
+		return db[name], nil
+	}))
+
+	b := pool.Bucket("users/1")
+	val, ts, fresh := b.Get(0)
+	assert.Equal(t, "Alice", val, "Misses load from the loader")
+	assert.NotZero(t, ts)
+	assert.True(t, fresh)
+	assert.Equal(t, SourceLoader, b.Provenance()[0].Kind)
+
+	val, _, _ = b.Get(0)
+	assert.Equal(t, "Alice", val)
+	assert.Equal(t, int32(1), calls.Load(), "Loaded values are served locally")
+
+	missing := pool.Bucket("users/2")
+	val, ts, _ = missing.Get(0)
+	assert.Nil(t, val)
+	assert.Zero(t, ts, "Nil values are not stored")
+}
+
+func TestLoaderSharesConcurrentMisses(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	pool := NewDataPool(WithLoader(func(name string) (any, error) {
+		calls.Add(1)
+		<-release
+		return "v", nil
+	}))
+	b := pool.Bucket("config")
+
+	var wg sync.WaitGroup
+	results := make([]any, 8)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _, _ = b.Get(0)
+		}()
+	}
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for _, r := range results {
+		assert.Equal(t, "v", r)
+	}
+}
+
+func TestBucketLoaderOverridesPool(t *testing.T) {
+	pool := NewDataPool(WithLoader(func(string) (any, error) { return "pool", nil }))
+	b := pool.Bucket("config")
+	b.SetLoader(func(string) (any, error) { return "bucket", nil })
+
+	val, _, _ := b.Get(0)
+	assert.Equal(t, "bucket", val)
+
+	other := pool.Bucket("other")
+	val, _, _ = other.Get(0)
+	assert.Equal(t, "pool", val)
+}
+
+func TestLoaderError(t *testing.T) {
+	errDown := errors.New("db down")
+	var reported []error
+	pool := NewDataPool(
+		WithLoader(func(string) (any, error) { return nil, errDown }),
+		WithErrorHandler(func(bucket string, err error) {
+			reported = append(reported, err)
+		}),
+	)
+
+	b := pool.Bucket("config")
+	val, ts, _ := b.Get(0)
+	assert.Nil(t, val)
+	assert.Zero(t, ts)
+	require.Len(t, reported, 1)
+	assert.ErrorIs(t, reported[0], errDown)
+	assert.ErrorContains(t, reported[0], `datapool: load "config"`)
+}
+
+func TestWriter(t *testing.T) {
+	var mu sync.Mutex
+	written := map[string]any{}
+	pool := NewDataPool(
+		WithWriter(func(name string, value any) error {
+			mu.Lock()
+			defer mu.Unlock()
+			written[name] = value
+			return nil
+		}),
+		WithLoader(func(string) (any, error) { return "loaded", nil }),
+	)
+
+	b := pool.Bucket("config")
+	b.Put("v1")
+	pool.PutMany(map[string]any{"a": 1})
+	assert.Equal(t, map[string]any{"config": "v1", "a": 1}, written)
+
+	loaded := pool.Bucket("loaded")
+	val, _, _ := loaded.Get(0)
+	assert.Equal(t, "loaded", val)
+	assert.NotContains(t, written, "loaded", "Loaded values are not written back")
+}
+
+func TestBucketWriter(t *testing.T) {
+	errDown := errors.New("db down")
+	var poolWrites []string
+	var reported []error
+	pool := NewDataPool(
+		WithWriter(func(name string, value any) error {
+			poolWrites = append(poolWrites, name)
+			return nil
+		}),
+		WithErrorHandler(func(bucket string, err error) {
+			reported = append(reported, err)
+		}),
+	)
+
+	b := pool.Bucket("config")
+	b.SetWriter(func(string, any) error { return errDown })
+	ts := b.Put("v1")
+	assert.NotZero(t, ts, "Writer errors do not undo the Put")
+	require.Len(t, reported, 1)
+	assert.ErrorIs(t, reported[0], errDown)
+	assert.Empty(t, poolWrites)
+
+	b.SetWriter(nil)
+	b.Put("v2")
+	assert.Equal(t, []string{"config"}, poolWrites)
+}
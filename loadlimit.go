@@ -0,0 +1,121 @@
+package datapool
+
+import "sync"
+
+// WithLoaderConcurrency limits how many loads of the buckets in namespace
+// (see Namespace) run at once to n, for sources that cannot take many
+// requests, such as a fragile upstream API. The limit covers loaders run for
+// Get misses (see WithLoader) and refreshes scheduled with ScheduleRefresh,
+// including those of a Refresher. Loads beyond it wait for a running one to
+// finish, without holding a refresh worker; refreshes of a bucket scheduled
+// while one of it is waiting collapse into it, so the latest load runs once
+// and every caller receives its result. Namespaces without a limit are not
+// limited.
+func WithLoaderConcurrency(namespace string, n int) Option {
+	return func(o *options) {
+		if n <= 0 {
+			delete(o.loaderLimits, namespace)
+			return
+		}
+		o.loaderLimits[namespace] = n
+	}
+}
+
+// loadLimit bounds the loads running at once in a namespace.
+type loadLimit struct {
+	pool      *DataPool
+	namespace string
+	limit     int
+
+	mu      sync.Mutex
+	running int
+	queue   []*pendingLoad
+}
+
+// pendingLoad is a load waiting for a slot: a refresh of a bucket, or the
+// loader run by a Get miss, which waits on ready.
+type pendingLoad struct {
+	b     Bucket
+	load  LoadFunc
+	dones []chan error
+	ready chan struct{}
+}
+
+func newLoadLimits(p *DataPool, limits map[string]int) map[string]*loadLimit {
+	if len(limits) == 0 {
+		return nil
+	}
+	m := make(map[string]*loadLimit, len(limits))
+	for namespace, n := range limits {
+		m[namespace] = &loadLimit{pool: p, namespace: namespace, limit: n}
+	}
+	return m
+}
+
+// acquire waits until fewer than l.limit loads run, and takes a slot.
+func (l *loadLimit) acquire() {
+	l.mu.Lock()
+	if l.running < l.limit {
+		l.running++
+		l.mu.Unlock()
+		return
+	}
+	pl := &pendingLoad{ready: make(chan struct{})}
+	l.queue = append(l.queue, pl)
+	l.mu.Unlock()
+	<-pl.ready
+}
+
+// refresh runs load for b with a slot, or queues it, joining a queued
+// refresh of the same bucket, if all are taken. done receives the error of
+// the load that ran.
+func (l *loadLimit) refresh(b Bucket, load LoadFunc, done chan error) {
+	l.mu.Lock()
+	for _, pl := range l.queue {
+		if pl.ready == nil && pl.b.b == b.b {
+			pl.load = load
+			pl.dones = append(pl.dones, done)
+			l.mu.Unlock()
+			return
+		}
+	}
+	pl := &pendingLoad{b: b, load: load, dones: []chan error{done}}
+	if l.running >= l.limit {
+		l.queue = append(l.queue, pl)
+		l.mu.Unlock()
+		return
+	}
+	l.running++
+	l.mu.Unlock()
+	l.run(pl)
+}
+
+// run runs the refresh pl with the slot it was given, then releases it.
+func (l *loadLimit) run(pl *pendingLoad) {
+	err := l.pool.storeRefresh(pl.b, pl.load)
+	for _, done := range pl.dones {
+		done <- err
+	}
+	l.release()
+}
+
+// release hands the caller's slot to the next queued load, or frees it.
+// Queued refreshes go back to the refresh workers.
+func (l *loadLimit) release() {
+	l.mu.Lock()
+	if len(l.queue) == 0 {
+		l.running--
+		l.mu.Unlock()
+		return
+	}
+	next := l.queue[0]
+	l.queue[0] = nil
+	l.queue = l.queue[1:]
+	l.mu.Unlock()
+
+	if next.ready != nil {
+		close(next.ready)
+		return
+	}
+	l.pool.refresh.enqueue(l.namespace, func() { l.run(next) })
+}
@@ -0,0 +1,100 @@
+package datapool
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoaderConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	pool := NewDataPool(
+		WithLoaderConcurrency("api", 2),
+		WithLoader(func(name string) (any, error) {
+			if Namespace(name) != "api" {
+				return "loaded " + name, nil
+			}
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			<-release
+			return "loaded " + name, nil
+		}),
+	)
+
+	var wg sync.WaitGroup
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b := pool.Bucket(fmt.Sprintf("api/%d", i))
+			value, _, _ := b.Get(0)
+			assert.Equal(t, fmt.Sprintf("loaded api/%d", i), value)
+		}()
+	}
+	limit := pool.loadLimits["api"]
+	assert.Eventually(t, func() bool {
+		limit.mu.Lock()
+		defer limit.mu.Unlock()
+		return limit.running == 2 && len(limit.queue) == 3
+	}, time.Second, time.Millisecond)
+
+	other := pool.Bucket("db/users")
+	value, _, _ := other.Get(0)
+	assert.Equal(t, "loaded db/users", value, "Other namespaces are not limited")
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(2), peak.Load(), "At most two loads ran at once")
+	assert.Zero(t, limit.running)
+}
+
+func TestLoaderConcurrencyCollapsesRefreshes(t *testing.T) {
+	// One worker runs the first refresh, the other queues the rest in order.
+	pool := NewDataPool(WithLoaderConcurrency("prices", 1), WithRefreshWorkers(2))
+	limit := pool.loadLimits["prices"]
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	first := pool.ScheduleRefresh("prices/EURUSD", func() (any, error) {
+		close(started)
+		<-release
+		return 1.0, nil
+	})
+	<-started
+
+	var loads atomic.Int32
+	var dones []<-chan error
+	for i := range 3 {
+		dones = append(dones, pool.ScheduleRefresh("prices/EURUSD", func() (any, error) {
+			loads.Add(1)
+			return 2.0 + float64(i), nil
+		}))
+	}
+	require.Eventually(t, func() bool {
+		limit.mu.Lock()
+		defer limit.mu.Unlock()
+		return len(limit.queue) == 1 && len(limit.queue[0].dones) == 3
+	}, time.Second, time.Millisecond, "Queued refreshes of one bucket collapse")
+
+	close(release)
+	require.NoError(t, <-first)
+	for _, done := range dones {
+		require.NoError(t, <-done)
+	}
+	assert.Equal(t, int32(1), loads.Load(), "The collapsed refreshes load once")
+	b := pool.Bucket("prices/EURUSD")
+	value, _, _ := b.Get(0)
+	assert.Equal(t, 4.0, value, "The latest refresh is the one that runs")
+}
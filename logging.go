@@ -0,0 +1,107 @@
+package datapool
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// LogEvent is a kind of event logged by the pool (see WithLogger).
+type LogEvent int
+
+const (
+	// LogCreate is a bucket created.
+	LogCreate LogEvent = iota
+	// LogPut is a value stored, by Put or any other write including values
+	// applied from a backend or a bridge.
+	LogPut
+	// LogRemove is a bucket removed by Clear.
+	LogRemove
+	// LogExpire is an expired value dropped by Expire, or a time bucket
+	// partition removed by TimeBucket.Expire.
+	LogExpire
+	// LogEvict is a bucket evicted to respect WithMaxBuckets, or a value
+	// dropped to respect a memory budget or under memory pressure.
+	LogEvict
+	// LogWatchOverflow is an update dropped by a watcher that fell behind.
+	LogWatchOverflow
+
+	numLogEvents
+)
+
+var logEventNames = [...]string{
+	LogCreate:        "create",
+	LogPut:           "put",
+	LogRemove:        "remove",
+	LogExpire:        "expire",
+	LogEvict:         "evict",
+	LogWatchOverflow: "watch-overflow",
+}
+
+var logMessages = [...]string{
+	LogCreate:        "datapool: bucket created",
+	LogPut:           "datapool: value stored",
+	LogRemove:        "datapool: bucket removed",
+	LogExpire:        "datapool: value expired",
+	LogEvict:         "datapool: value evicted",
+	LogWatchOverflow: "datapool: watch overflow",
+}
+
+var defaultLogLevels = [numLogEvents]slog.Level{
+	LogCreate:        slog.LevelDebug,
+	LogPut:           slog.LevelDebug,
+	LogRemove:        slog.LevelInfo,
+	LogExpire:        slog.LevelDebug,
+	LogEvict:         slog.LevelInfo,
+	LogWatchOverflow: slog.LevelWarn,
+}
+
+func (e LogEvent) String() string {
+	if e < 0 || e >= numLogEvents {
+		return fmt.Sprintf("LogEvent(%d)", int(e))
+	}
+	return logEventNames[e]
+}
+
+// logEvent logs event for the named bucket with attrs, if the pool has a
+// logger enabled at the event's level. Callers building attributes should
+// check p.opts.logger first, so pools without a logger pay nothing.
+func (p *DataPool) logEvent(event LogEvent, bucket string, attrs ...slog.Attr) {
+	logger := p.opts.logger
+	if logger == nil || isSystem(bucket) {
+		return
+	}
+	level := p.opts.logLevels[event]
+	ctx := context.Background()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	logger.LogAttrs(ctx, level, logMessages[event], append([]slog.Attr{
+		slog.String("event", event.String()),
+		slog.String("bucket", bucket),
+	}, attrs...)...)
+}
+
+// logStored logs the value u stored in b.
+func (p *DataPool) logStored(b *bucket, u Update) {
+	if p.opts.logger == nil {
+		return
+	}
+	source := SourcePut
+	if len(u.Provenance) > 0 {
+		source = u.Provenance[len(u.Provenance)-1].Kind
+	}
+	p.logEvent(LogPut, b.name(),
+		slog.Time("timestamp", time.Unix(0, u.Timestamp)),
+		slog.Uint64("version", u.Version),
+		slog.String("source", source.String()))
+}
+
+// watchOverflow counts an update dropped by a watcher of b.
+func (p *DataPool) watchOverflow(b *bucket) {
+	n := p.watchOverflows.Add(1)
+	if p.opts.logger != nil {
+		p.logEvent(LogWatchOverflow, b.name(), slog.Uint64("overflows", n))
+	}
+}
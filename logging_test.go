@@ -0,0 +1,130 @@
+package datapool
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHandler keeps the records logged at or above its level.
+type recordingHandler struct {
+	level slog.Level
+	mu    sync.Mutex
+	logs  []slog.Record
+}
+
+func (h *recordingHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.logs = append(h.logs, r)
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *recordingHandler) WithGroup(string) slog.Handler      { return h }
+
+// events returns the logged events as "event bucket" strings, with their
+// levels.
+func (h *recordingHandler) events() map[string]slog.Level {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	events := make(map[string]slog.Level)
+	for _, r := range h.logs {
+		attrs := make(map[string]string)
+		r.Attrs(func(a slog.Attr) bool {
+			attrs[a.Key] = a.Value.String()
+			return true
+		})
+		events[attrs["event"]+" "+attrs["bucket"]] = r.Level
+	}
+	return events
+}
+
+func (h *recordingHandler) attrs(message string) map[string]slog.Value {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, r := range h.logs {
+		if r.Message != message {
+			continue
+		}
+		attrs := make(map[string]slog.Value)
+		r.Attrs(func(a slog.Attr) bool {
+			attrs[a.Key] = a.Value
+			return true
+		})
+		return attrs
+	}
+	return nil
+}
+
+func TestLogger(t *testing.T) {
+	h := &recordingHandler{level: slog.LevelDebug}
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithLogger(slog.New(h)), WithClock(clock), WithMaxBuckets(2), WithWatchBuffer(1))
+
+	session := pool.Bucket("session")
+	session.SetTTL(time.Minute)
+	session.Put("token")
+	ch := session.Watch(context.Background())
+	session.Put("token2")
+	session.Put("token3") // Overflows the unread watch buffer.
+	clock.Advance(2 * time.Minute)
+	pool.Expire()
+
+	pool.Handle("a").Put(1)
+	pool.Handle("b").Put(2) // Evicts session, the least recently used.
+	pool.Clear()
+	_, ok := <-ch
+	require.True(t, ok)
+
+	assert.Equal(t, map[string]slog.Level{
+		"create session":         slog.LevelDebug,
+		"put session":            slog.LevelDebug,
+		"watch-overflow session": slog.LevelWarn,
+		"expire session":         slog.LevelDebug,
+		"create a":               slog.LevelDebug,
+		"put a":                  slog.LevelDebug,
+		"create b":               slog.LevelDebug,
+		"put b":                  slog.LevelDebug,
+		"evict session":          slog.LevelInfo,
+		"remove a":               slog.LevelInfo,
+		"remove b":               slog.LevelInfo,
+	}, h.events())
+
+	put := h.attrs("datapool: value stored")
+	require.NotNil(t, put)
+	assert.Equal(t, "session", put["bucket"].String())
+	assert.Equal(t, time.Unix(1000, 0), put["timestamp"].Time())
+	assert.Equal(t, uint64(1), put["version"].Uint64())
+	assert.Equal(t, "put", put["source"].String())
+	assert.Equal(t, "max-buckets", h.attrs("datapool: value evicted")["reason"].String())
+	assert.Equal(t, uint64(1), h.attrs("datapool: watch overflow")["overflows"].Uint64())
+
+	system := pool.Bucket(SystemStatsBucket)
+	system.Get(0)
+	assert.NotContains(t, h.events(), "create "+SystemStatsBucket, "System buckets are not logged")
+}
+
+func TestLogLevel(t *testing.T) {
+	h := &recordingHandler{level: slog.LevelInfo}
+	pool := NewDataPool(
+		WithLogger(slog.New(h)),
+		WithLogLevel(LogPut, slog.LevelInfo),
+		WithLogLevel(LogRemove, slog.LevelDebug),
+	)
+	pool.Handle("a").Put(1)
+	pool.Clear()
+	assert.Equal(t, map[string]slog.Level{"put a": slog.LevelInfo}, h.events())
+
+	assert.Equal(t, "watch-overflow", LogWatchOverflow.String())
+	assert.Equal(t, "LogEvent(9)", LogEvent(9).String())
+}
@@ -0,0 +1,34 @@
+package datapool
+
+import "reflect"
+
+// decodedValue is an Encoded value decoded by GetAs, kept in its bucket until
+// the bucket changes.
+type decodedValue struct {
+	timestamp int64
+	typ       reflect.Type
+	value     any
+}
+
+// decodeAs decodes e, the value of bk stored at ts, into a T, reusing the
+// result of an earlier call for the same value and type. A decode racing with
+// a write may keep the old value, which the timestamp tells apart.
+func decodeAs[T any](bk *bucket, e Encoded, ts int64) (T, error) {
+	typ := reflect.TypeFor[T]()
+	d := bk.decoded.Load()
+	if d == nil || d.timestamp != ts || d.typ != typ {
+		var out T
+		if err := e.Codec.Unmarshal(e.Data, &out); err != nil {
+			return out, err
+		}
+		d = &decodedValue{timestamp: ts, typ: typ, value: out}
+		bk.decoded.Store(d)
+	}
+
+	value := d.value
+	if bk.copyValues.Load() {
+		value = copyValue(value)
+	}
+	out, _ := value.(T)
+	return out, nil
+}
@@ -0,0 +1,83 @@
+package datapool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingCodec counts the values JSONCodec decodes for it.
+type countingCodec struct {
+	decodes int
+}
+
+func (c *countingCodec) Name() string                  { return "json" }
+func (c *countingCodec) Marshal(v any) ([]byte, error) { return JSONCodec.Marshal(v) }
+
+func (c *countingCodec) Unmarshal(data []byte, v any) error {
+	c.decodes++
+	return JSONCodec.Unmarshal(data, v)
+}
+
+func TestGetAsMemoizes(t *testing.T) {
+	codec := &countingCodec{}
+	pool := NewDataPool(WithCodec(codec))
+	b := pool.Bucket("config")
+	ts, err := b.PutEncoded(cloneConfigValue{Name: "a", Hosts: []string{"h1"}})
+	require.NoError(t, err)
+
+	for range 3 {
+		got, gotTS, _, err := GetAs[cloneConfigValue](&b, 0)
+		require.NoError(t, err)
+		assert.Equal(t, cloneConfigValue{Name: "a", Hosts: []string{"h1"}}, got)
+		assert.Equal(t, ts, gotTS)
+	}
+	assert.Equal(t, 1, codec.decodes, "Unchanged values are decoded once")
+
+	m, _, _, err := GetAs[map[string]any](&b, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "a", m["Name"])
+	assert.Equal(t, 2, codec.decodes, "Other types are decoded again")
+
+	_, err = b.PutEncoded(cloneConfigValue{Name: "b"})
+	require.NoError(t, err)
+	got, _, _, err := GetAs[cloneConfigValue](&b, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "b", got.Name, "Writes drop the decoded value")
+	assert.Equal(t, 3, codec.decodes)
+
+	b.SetCopyValues(true)
+	first, _, _, err := GetAs[map[string]any](&b, 0)
+	require.NoError(t, err)
+	first["Name"] = "changed"
+	second, _, _, err := GetAs[map[string]any](&b, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "b", second["Name"], "Buckets copying values copy decoded values")
+}
+
+func BenchmarkGetAs(b *testing.B) {
+	value := cloneConfigValue{Name: "service", Hosts: []string{"h1", "h2", "h3", "h4"}}
+	for _, bc := range []struct {
+		name    string
+		changed bool
+	}{{"unchanged", false}, {"changed", true}} {
+		b.Run(bc.name, func(b *testing.B) {
+			pool := NewDataPool()
+			bucket := pool.Bucket("config")
+			bucket.PutEncoded(value)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				if bc.changed {
+					// Stands in for a write between reads, which drops the
+					// decoded value.
+					bucket.b.decoded.Store(nil)
+				}
+				if _, _, _, err := GetAs[cloneConfigValue](&bucket, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package datapool
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// MergeStrategy decides, for DataPool.Merge, which value a bucket holding a
+// value in both pools keeps.
+type MergeStrategy int
+
+const (
+	// MergeKeepNewer keeps whichever value has the later timestamp.
+	MergeKeepNewer MergeStrategy = iota
+	// MergeKeepLocal keeps the local value, only filling buckets that are
+	// empty locally.
+	MergeKeepLocal
+	// MergeOverwrite replaces local values with the other pool's.
+	MergeOverwrite
+)
+
+var mergeStrategyNames = [...]string{
+	MergeKeepNewer: "keep-newer",
+	MergeKeepLocal: "keep-local",
+	MergeOverwrite: "overwrite",
+}
+
+func (s MergeStrategy) String() string {
+	if s < 0 || int(s) >= len(mergeStrategyNames) {
+		return fmt.Sprintf("MergeStrategy(%d)", int(s))
+	}
+	return mergeStrategyNames[s]
+}
+
+// mergeEntry is a bucket's value as read by snapshot.
+type mergeEntry struct {
+	value      any
+	timestamp  int64
+	expiresAt  int64
+	schema     int
+	provenance []Source
+}
+
+// snapshot returns the values of the pool's non-empty user buckets by name.
+// Each bucket is read under its own lock, as by Inspect.
+func (p *DataPool) snapshot() map[string]mergeEntry {
+	entries := make(map[string]mergeEntry)
+	for _, b := range p.all() {
+		if b.system {
+			continue
+		}
+		b.guard.RLock()
+		value, ts := b.readValue(p)
+		if ts != 0 {
+			entries[b.name()] = mergeEntry{
+				value:      value,
+				timestamp:  ts,
+				expiresAt:  b.expiresAt,
+				schema:     b.schema,
+				provenance: b.provenance,
+			}
+		}
+		b.guard.RUnlock()
+	}
+	return entries
+}
+
+// Merge stores the values of other's buckets in the pool, creating buckets as
+// needed, and returns how many it stored. Buckets holding a value in both
+// pools are settled by strategy; empty buckets of other are ignored, and so
+// are its system buckets. Each bucket is read and written under its own lock,
+// so concurrent writes to either pool are not lost, but the merge is not
+// atomic across buckets.
+//
+// Merged values keep their expiration time, schema version and provenance,
+// and take a new local timestamp, so they reach watchers, callbacks, the
+// write-ahead log and the backend like other writes. Values are shared
+// between the pools, not copied, unless the pool copies values on Put.
+func (p *DataPool) Merge(other *DataPool, strategy MergeStrategy) (int, error) {
+	if strategy < MergeKeepNewer || strategy > MergeOverwrite {
+		return 0, fmt.Errorf("datapool: unknown merge strategy %v", strategy)
+	}
+
+	entries := other.snapshot()
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	merged := 0
+	for _, name := range names {
+		b, err := p.bucket(name)
+		if err != nil {
+			p.reportError(name, err)
+			continue
+		}
+		if p.mergeInto(b, entries[name], strategy) {
+			merged++
+		}
+	}
+	return merged, nil
+}
+
+// mergeInto stores e in b unless strategy keeps b's value, and reports
+// whether it did.
+func (p *DataPool) mergeInto(b *bucket, e mergeEntry, strategy MergeStrategy) bool {
+	if p.rejectSystem(b) {
+		return false
+	}
+
+	b.guard.Lock()
+	if b.removed {
+		b.guard.Unlock()
+		return false
+	}
+	if _, ts := b.readValue(p); ts != 0 {
+		if strategy == MergeKeepLocal || strategy == MergeKeepNewer && e.timestamp <= ts {
+			b.guard.Unlock()
+			return false
+		}
+	}
+	if err := b.checkClaim(p.now(), 0); err != nil {
+		b.guard.Unlock()
+		p.reportError(b.name(), err)
+		return false
+	}
+	value, err := b.prepare(e.value)
+	if err != nil {
+		b.guard.Unlock()
+		p.reportError(b.name(), err)
+		return false
+	}
+	u := Update{
+		Bucket:     b.name(),
+		Value:      value,
+		Timestamp:  p.stamp(),
+		Provenance: e.provenance,
+		Schema:     e.schema,
+	}
+	u.Version = b.store(u.Value, u.Timestamp)
+	if e.expiresAt != 0 {
+		b.expiresAt = e.expiresAt
+		b.reschedule()
+	}
+	b.provenance = u.Provenance
+	b.schema = u.Schema
+	watchers := b.watchers
+	b.guard.Unlock()
+
+	p.notifyPut(context.Background(), b, watchers, u, ConsistencyDefault)
+	p.checkMemoryPressure(u.Timestamp)
+	return true
+}
+
+// ChangeKind is the kind of a Change between two pools.
+type ChangeKind int
+
+const (
+	// ChangeAdded is a bucket holding a value in the other pool only.
+	ChangeAdded ChangeKind = iota
+	// ChangeRemoved is a bucket holding a value in the local pool only.
+	ChangeRemoved
+	// ChangeModified is a bucket holding different values in both pools.
+	ChangeModified
+)
+
+var changeKindNames = [...]string{
+	ChangeAdded:    "added",
+	ChangeRemoved:  "removed",
+	ChangeModified: "modified",
+}
+
+func (k ChangeKind) String() string {
+	if k < 0 || int(k) >= len(changeKindNames) {
+		return fmt.Sprintf("ChangeKind(%d)", int(k))
+	}
+	return changeKindNames[k]
+}
+
+// Change describes a bucket whose value differs between two pools, as
+// returned by DataPool.Diff. The local side of a ChangeAdded and the other
+// side of a ChangeRemoved are nil, with a zero timestamp.
+type Change struct {
+	Bucket string
+	Kind   ChangeKind
+
+	Local          any
+	LocalTimestamp int64
+	Other          any
+	OtherTimestamp int64
+}
+
+// Diff returns the changes that would turn the pool's values into other's, in
+// bucket name order. Values are compared with reflect.DeepEqual; buckets
+// holding equal values are not changes, whatever their timestamps, and empty
+// buckets count as missing. System buckets are left out.
+func (p *DataPool) Diff(other *DataPool) []Change {
+	local, remote := p.snapshot(), other.snapshot()
+
+	var changes []Change
+	for name, l := range local {
+		r, ok := remote[name]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Bucket: name, Kind: ChangeRemoved, Local: l.value, LocalTimestamp: l.timestamp})
+		case !reflect.DeepEqual(l.value, r.value):
+			changes = append(changes, Change{
+				Bucket:         name,
+				Kind:           ChangeModified,
+				Local:          l.value,
+				LocalTimestamp: l.timestamp,
+				Other:          r.value,
+				OtherTimestamp: r.timestamp,
+			})
+		}
+	}
+	for name, r := range remote {
+		if _, ok := local[name]; !ok {
+			changes = append(changes, Change{Bucket: name, Kind: ChangeAdded, Other: r.value, OtherTimestamp: r.timestamp})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Bucket < changes[j].Bucket })
+	return changes
+}
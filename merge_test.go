@@ -0,0 +1,117 @@
+package datapool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mergePools returns a local pool and another one written after it, holding
+// "shared" with different values and one bucket of their own each.
+func mergePools(t *testing.T) (local, other *DataPool) {
+	t.Helper()
+	local = NewDataPool(WithClock(NewManualClock(time.Unix(1000, 0))))
+	local.PutMany(map[string]any{"shared": "local", "local-only": 1})
+	local.Bucket("empty")
+	other = NewDataPool(WithClock(NewManualClock(time.Unix(2000, 0))))
+	other.PutMany(map[string]any{"shared": "other", "other-only": 2})
+	other.Bucket("other-empty")
+	return local, other
+}
+
+func TestMerge(t *testing.T) {
+	for _, tc := range []struct {
+		strategy MergeStrategy
+		merged   int
+		shared   any
+	}{
+		{MergeKeepNewer, 2, "other"},
+		{MergeKeepLocal, 1, "local"},
+		{MergeOverwrite, 2, "other"},
+	} {
+		t.Run(tc.strategy.String(), func(t *testing.T) {
+			local, other := mergePools(t)
+			merged, err := local.Merge(other, tc.strategy)
+			require.NoError(t, err)
+			assert.Equal(t, tc.merged, merged)
+
+			values := local.GetMany([]string{"shared", "local-only", "other-only"}, 0)
+			assert.Equal(t, tc.shared, values["shared"].Value)
+			assert.Equal(t, 1, values["local-only"].Value, "Merging does not remove buckets")
+			assert.Equal(t, 2, values["other-only"].Value)
+			assert.Nil(t, local.find("other-empty"), "Empty buckets are not merged")
+		})
+	}
+}
+
+func TestMergeKeepNewerKeepsLocal(t *testing.T) {
+	local, other := mergePools(t)
+	local.opts.clock.(*ManualClock).Set(time.Unix(3000, 0))
+	local.Handle("shared").Put("newer")
+
+	merged, err := local.Merge(other, MergeKeepNewer)
+	require.NoError(t, err)
+	assert.Equal(t, 1, merged)
+	value, _, _ := local.Handle("shared").Get(0)
+	assert.Equal(t, "newer", value)
+
+	merged, err = local.Merge(other, MergeOverwrite)
+	require.NoError(t, err)
+	assert.Equal(t, 2, merged)
+	value, _, _ = local.Handle("shared").Get(0)
+	assert.Equal(t, "other", value)
+}
+
+func TestMergeNotifies(t *testing.T) {
+	local, other := mergePools(t)
+	var puts []string
+	local.OnPut(func(name string, _ any, _ int64) { puts = append(puts, name) })
+	ob := other.Bucket("other-only")
+	ob.SetTTL(time.Hour)
+	ts := ob.Put("expiring")
+
+	_, err := local.Merge(other, MergeOverwrite)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"shared", "other-only"}, puts)
+	assert.Equal(t, ts+int64(time.Hour), local.find("other-only").expiresAt, "Expiration times are kept")
+}
+
+func TestMergeUnknownStrategy(t *testing.T) {
+	local, other := mergePools(t)
+	_, err := local.Merge(other, MergeStrategy(7))
+	assert.ErrorContains(t, err, "MergeStrategy(7)")
+}
+
+func TestDiff(t *testing.T) {
+	local, other := mergePools(t)
+	changes := local.Diff(other)
+	require.Len(t, changes, 3)
+
+	assert.Equal(t, "local-only", changes[0].Bucket)
+	assert.Equal(t, ChangeRemoved, changes[0].Kind)
+	assert.Equal(t, 1, changes[0].Local)
+	assert.Nil(t, changes[0].Other)
+
+	assert.Equal(t, "other-only", changes[1].Bucket)
+	assert.Equal(t, ChangeAdded, changes[1].Kind)
+	assert.Equal(t, 2, changes[1].Other)
+	assert.Zero(t, changes[1].LocalTimestamp)
+
+	assert.Equal(t, Change{
+		Bucket:         "shared",
+		Kind:           ChangeModified,
+		Local:          "local",
+		LocalTimestamp: changes[2].LocalTimestamp,
+		Other:          "other",
+		OtherTimestamp: changes[2].OtherTimestamp,
+	}, changes[2])
+	assert.Less(t, changes[2].LocalTimestamp, changes[2].OtherTimestamp)
+
+	_, err := local.Merge(other, MergeOverwrite)
+	require.NoError(t, err)
+	changes = local.Diff(other)
+	require.Len(t, changes, 1, "Equal values are not changes, whatever their timestamps")
+	assert.Equal(t, ChangeRemoved, changes[0].Kind)
+}
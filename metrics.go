@@ -0,0 +1,23 @@
+package datapool
+
+// MetricsRecorder receives pool activity for export to a metrics system such
+// as Prometheus or StatsD. Methods are called synchronously on the goroutine
+// performing the operation and must be safe for concurrent use; keep them
+// cheap.
+type MetricsRecorder interface {
+	// RecordGet is called for every Get, outside of pool locks. hit reports whether the bucket held a
+	// value, fresh whether it was newer than the caller's timestamp.
+	RecordGet(bucket string, hit, fresh bool)
+
+	// RecordPut is called for every successful Put, outside of pool locks.
+	RecordPut(bucket string)
+
+	// RecordEviction is called for every bucket evicted to respect
+	// WithMaxBuckets, outside of pool locks.
+	RecordEviction(bucket string)
+
+	// RecordCorruption is called for every invariant violation handled under
+	// ReportViolations. Unlike the other methods it may run while pool locks
+	// are held, so it must not call back into the pool.
+	RecordCorruption()
+}
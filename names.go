@@ -0,0 +1,84 @@
+package datapool
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidName is wrapped by the errors reported for bucket names rejected
+// by the pool's NameRules.
+var ErrInvalidName = errors.New("datapool: invalid bucket name")
+
+// maxQuotedName bounds how much of a rejected name error messages quote.
+const maxQuotedName = 64
+
+// NameRules restricts the names of the buckets a pool creates (see
+// WithNameRules), so names derived from user input cannot grow unbounded or
+// collide with names the application reserves. The zero NameRules allows any
+// name.
+type NameRules struct {
+	// MaxLength is the longest name allowed, in bytes. Zero or less means no
+	// limit.
+	MaxLength int
+
+	// Allow reports whether a character may appear in names. Nil allows any;
+	// otherwise names must also be valid UTF-8.
+	Allow func(r rune) bool
+
+	// ReservedPrefixes lists prefixes names must not start with.
+	ReservedPrefixes []string
+}
+
+// check returns an error wrapping ErrInvalidName if name breaks the rules.
+func (r *NameRules) check(name string) error {
+	if r.MaxLength > 0 && len(name) > r.MaxLength {
+		return withKind(fmt.Errorf("%w %s: longer than %d bytes", ErrInvalidName, quoteName(name), r.MaxLength), ErrTooLarge)
+	}
+	if r.Allow != nil {
+		for i, c := range name {
+			if c == utf8.RuneError && !strings.HasPrefix(name[i:], string(utf8.RuneError)) {
+				return fmt.Errorf("%w %s: not valid UTF-8", ErrInvalidName, quoteName(name))
+			}
+			if !r.Allow(c) {
+				return fmt.Errorf("%w %s: character %q not allowed", ErrInvalidName, quoteName(name), c)
+			}
+		}
+	}
+	for _, prefix := range r.ReservedPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return fmt.Errorf("%w %s: prefix %q is reserved", ErrInvalidName, quoteName(name), prefix)
+		}
+	}
+	return nil
+}
+
+// quoteName quotes name for an error message, truncating long names.
+func quoteName(name string) string {
+	if len(name) > maxQuotedName {
+		return fmt.Sprintf("%q...", name[:maxQuotedName])
+	}
+	return fmt.Sprintf("%q", name)
+}
+
+// WithNameRules makes the pool validate the names of the buckets it creates.
+// Bucket returns the zero Bucket for a rejected name, which reads as empty
+// and drops writes, and passes the error to the error handler; PutMany and
+// transactions skip or fail on such names. Use ValidateName to reject names
+// up front.
+func WithNameRules(rules NameRules) Option {
+	return func(o *options) {
+		rules.ReservedPrefixes = append([]string(nil), rules.ReservedPrefixes...)
+		o.nameRules = &rules
+	}
+}
+
+// ValidateName returns an error wrapping ErrInvalidName if the pool's
+// NameRules (see WithNameRules) reject name, and nil otherwise.
+func (p *DataPool) ValidateName(name string) error {
+	if rules := p.opts.nameRules; rules != nil {
+		return rules.check(name)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package datapool
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRules() NameRules {
+	return NameRules{
+		MaxLength: 16,
+		Allow: func(r rune) bool {
+			return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("/_-", r))
+		},
+		ReservedPrefixes: []string{"sys/"},
+	}
+}
+
+func TestNameRules(t *testing.T) {
+	pool := NewDataPool(WithNameRules(testRules()))
+
+	for _, tc := range []struct {
+		name, err string
+	}{
+		{"users/42", ""},
+		{strings.Repeat("x", 16), ""},
+		{strings.Repeat("x", 17), `datapool: invalid bucket name "xxxxxxxxxxxxxxxxx": longer than 16 bytes`},
+		{"users 42", `datapool: invalid bucket name "users 42": character ' ' not allowed`},
+		{"café", `datapool: invalid bucket name "café": character 'é' not allowed`},
+		{"a\xffb", `datapool: invalid bucket name "a\xffb": not valid UTF-8`},
+		{"sys/config", `datapool: invalid bucket name "sys/config": prefix "sys/" is reserved`},
+	} {
+		err := pool.ValidateName(tc.name)
+		if tc.err == "" {
+			assert.NoError(t, err, tc.name)
+			continue
+		}
+		assert.ErrorIs(t, err, ErrInvalidName, tc.name)
+		assert.EqualError(t, err, tc.err)
+	}
+
+	assert.NoError(t, NewDataPool().ValidateName(strings.Repeat("\xff", 1000)), "Pools without rules accept any name")
+}
+
+func TestNameRulesQuoteLongNames(t *testing.T) {
+	pool := NewDataPool(WithNameRules(NameRules{MaxLength: 100}))
+	err := pool.ValidateName(strings.Repeat("x", 1<<20))
+	require.Error(t, err)
+	assert.Less(t, len(err.Error()), 200, "Error messages do not repeat hostile names in full")
+}
+
+func TestBucketRejectsInvalidNames(t *testing.T) {
+	var errs []error
+	pool := NewDataPool(WithNameRules(testRules()), WithErrorHandler(func(_ string, err error) {
+		errs = append(errs, err)
+	}))
+
+	b := pool.Bucket("sys/config")
+	assert.Equal(t, Bucket{}, b)
+	assert.Zero(t, b.Put("v"), "Writes to a rejected bucket are dropped")
+	val, ts, _ := b.Get(0)
+	assert.Nil(t, val)
+	assert.Zero(t, ts)
+	assert.Zero(t, pool.Len(), "Rejected names create no bucket")
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrInvalidName)
+	assert.Zero(t, pool.Corruptions())
+
+	users := pool.Bucket("users/42")
+	users.Put("Alice")
+	assert.Equal(t, 1, pool.Len())
+}
+
+func TestNameRulesElsewhere(t *testing.T) {
+	var errs []error
+	pool := NewDataPool(WithNameRules(testRules()), WithErrorHandler(func(_ string, err error) {
+		errs = append(errs, err)
+	}))
+
+	timestamps := pool.PutMany(map[string]any{"ok": 1, "sys/x": 2})
+	assert.NotZero(t, timestamps["ok"])
+	assert.Zero(t, timestamps["sys/x"], "PutMany skips rejected names")
+	assert.Len(t, errs, 1)
+
+	err := pool.Update(func(tx *Tx) error {
+		tx.Put("ok", 3)
+		tx.Put("sys/x", 4)
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrInvalidName, "Transactions fail on rejected names")
+	ok := pool.Bucket("ok")
+	val, _, _ := ok.Get(0)
+	assert.Equal(t, 1, val, "A failed transaction writes nothing")
+
+	_, err = pool.Derive("sum", []string{"ok", "sys/x"}, func(values []any) (any, error) { return nil, nil })
+	assert.ErrorIs(t, err, ErrInvalidName)
+
+	assert.ErrorIs(t, <-pool.ScheduleRefresh("sys/x", func() (any, error) { return 1, nil }), ErrInvalidName)
+	assert.Equal(t, 1, pool.Len())
+}
@@ -0,0 +1,117 @@
+package datapool
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrCachedError is returned by GetE for a bucket holding an error stored
+// with PutError, which the returned error also wraps. GetE thus tells the
+// three states of a bucket apart: a cached value returns it and no error, a
+// cached error returns an error matching ErrCachedError, and an empty bucket
+// returns a zero timestamp and no error.
+var ErrCachedError = errors.New("datapool: cached error")
+
+// ErrNoValue is the error cached for a bucket whose loader returned no value
+// when the pool caches load failures (see WithNegativeTTL). It is an
+// ErrNotFound.
+var ErrNoValue = newKindError(ErrNotFound, "datapool: no value at source")
+
+// WithNegativeTTL makes the pool cache the failures of its loaders for ttl:
+// when a loader returns an error, or no value, the bucket stores the error,
+// or ErrNoValue, as with PutError, so that Gets return an empty value
+// without calling the loader again until it expires. Load errors are still
+// passed to the error handler when they happen. A ttl of zero or less, the
+// default, caches nothing.
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.negativeTTL = max(ttl, 0)
+	}
+}
+
+// PutError stores err as the bucket's result in place of a value, so that
+// repeated lookups of a missing or failing key do not reach its source: Get
+// returns a nil value with the error's timestamp, so neither the backend nor
+// the loader are consulted, and GetE returns an error wrapping
+// ErrCachedError and err. The error expires after ttl, typically shorter
+// than the bucket's TTL; a ttl of zero or less applies the bucket's TTL.
+//
+// A cached error is local to the pool: it is not passed to watchers, the
+// write-ahead log, the backend or the writer, and the bucket reads as empty
+// to snapshots and compactions of the log, snapshot files and views,
+// PublishSwap, Merge and Diff, ExportRDB, replicas and transactions. Clone
+// copies it, and GetMany reports it in Result.Err. The next Put replaces it.
+// It returns the timestamp of the error, or zero if it was not stored.
+func (b *Bucket) PutError(err error, ttl time.Duration) int64 {
+	bk := b.resolve("put error")
+	if bk == nil {
+		return 0
+	}
+	if err == nil {
+		b.pool.reportError(bk.name(), fmt.Errorf("datapool: put error %q: nil error", bk.name()))
+		return 0
+	}
+
+	ts, err := b.pool.storeError(bk, err, ttl)
+	if err != nil {
+		b.pool.reportError(bk.name(), err)
+	}
+	return ts
+}
+
+// storeError stores err as the result of b for ttl and returns its
+// timestamp, or the error of a claimed or removed bucket.
+func (p *DataPool) storeError(b *bucket, err error, ttl time.Duration) (int64, error) {
+	if p.rejectSystem(b) {
+		return 0, nil
+	}
+
+	b.guard.Lock()
+	defer b.guard.Unlock()
+
+	if b.removed {
+		return 0, b.removedError()
+	}
+	if err := b.checkClaim(p.now(), 0); err != nil {
+		return 0, err
+	}
+	ts := p.stamp()
+	b.store(nil, ts)
+	b.cachedErr = err
+	if ttl > 0 {
+		b.expiresAt = ts + int64(ttl)
+		b.reschedule()
+	}
+	b.publish(p)
+	return ts, nil
+}
+
+// readValue is read for the paths copying values out of the pool, which
+// leave cached errors behind: a bucket holding one reads as empty. It must be
+// called with b.guard held.
+func (b *bucket) readValue(p *DataPool) (any, int64) {
+	if b.cachedErr != nil {
+		return nil, 0
+	}
+	value, ts, _ := b.read(p, 0)
+	return value, ts
+}
+
+// cachedError returns the error cached in b at ts, or nil if a value was
+// stored at ts.
+func (b *bucket) cachedError(ts int64) error {
+	b.guard.RLock()
+	defer b.guard.RUnlock()
+
+	if b.cachedErr == nil || b.timestamp != ts {
+		return nil
+	}
+	return b.wrapCachedError()
+}
+
+// wrapCachedError returns the error cached in b as GetE returns it. It must be
+// called with b.guard held.
+func (b *bucket) wrapCachedError() error {
+	return fmt.Errorf("%w: bucket %q: %w", ErrCachedError, b.name(), b.cachedErr)
+}
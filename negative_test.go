@@ -0,0 +1,138 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutError(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	b := pool.Bucket("user/42")
+	b.Put("value")
+
+	failed := errors.New("lookup failed")
+	ts := b.PutError(failed, time.Second)
+	require.NotZero(t, ts)
+	value, got, _ := b.Get(0)
+	assert.Nil(t, value)
+	assert.Equal(t, ts, got, "Get sees the cached error as a result")
+
+	_, got, _, err := b.GetE(0)
+	assert.Equal(t, ts, got)
+	assert.ErrorIs(t, err, ErrCachedError)
+	assert.ErrorIs(t, err, failed)
+
+	clock.Advance(2 * time.Second)
+	value, got, _, err = b.GetE(0)
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+	assert.Zero(t, got, "The error expires after its TTL")
+
+	b.PutError(failed, 0)
+	b.Put("fresh")
+	value, _, _, err = b.GetE(0)
+	assert.NoError(t, err, "A Put replaces the error")
+	assert.Equal(t, "fresh", value)
+}
+
+func TestPutErrorRejected(t *testing.T) {
+	var reported []error
+	pool := NewDataPool(WithErrorHandler(func(_ string, err error) { reported = append(reported, err) }))
+	b := pool.Bucket("a")
+	assert.Zero(t, b.PutError(nil, time.Second))
+	pool.Clear()
+	assert.Zero(t, b.PutError(errors.New("failed"), time.Second))
+	require.Len(t, reported, 2)
+	assert.ErrorIs(t, reported[1], ErrRemoved)
+}
+
+func TestNegativeTTL(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	failed := errors.New("source down")
+	var calls atomic.Int64
+	pool := NewDataPool(WithClock(clock), WithNegativeTTL(time.Minute), WithLoader(func(name string) (any, error) {
+		calls.Add(1)
+		if name == "broken" {
+			return nil, failed
+		}
+		return nil, nil
+	}))
+
+	for i := 0; i < 3; i++ {
+		pool.Handle("broken").Get(0)
+		pool.Handle("missing").Get(0)
+	}
+	assert.Equal(t, int64(2), calls.Load(), "Failures are cached")
+	broken, missing := pool.Bucket("broken"), pool.Bucket("missing")
+	_, _, _, err := broken.GetE(0)
+	assert.ErrorIs(t, err, failed)
+	_, _, _, err = missing.GetE(0)
+	assert.ErrorIs(t, err, ErrNoValue)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	clock.Advance(2 * time.Minute)
+	pool.Handle("broken").Get(0)
+	assert.Equal(t, int64(3), calls.Load(), "The loader runs again once the error expires")
+}
+
+func TestPutErrorStaysLocal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+	pool := NewDataPool(WithWAL(openWAL(t, path)))
+	b := pool.Bucket("alice")
+	b.Put("value")
+	failed := errors.New("lookup failed")
+	b.PutError(failed, 0)
+
+	results := pool.GetMany([]string{"alice"}, 0)
+	assert.Nil(t, results["alice"].Value)
+	assert.ErrorIs(t, results["alice"].Err, ErrCachedError)
+	assert.ErrorIs(t, results["alice"].Err, failed)
+	require.NoError(t, pool.Update(func(tx *Tx) error {
+		value, ts := tx.Get("alice")
+		assert.Nil(t, value)
+		assert.Zero(t, ts, "Transactions read cached errors as empty")
+		return nil
+	}))
+	_, ts, _ := pool.SnapshotView().Get("alice", 0)
+	assert.Zero(t, ts)
+
+	clone, err := pool.Clone()
+	require.NoError(t, err)
+	c := clone.Bucket("alice")
+	_, _, _, err = c.GetE(0)
+	assert.ErrorIs(t, err, failed, "Clones keep cached errors")
+
+	require.NoError(t, pool.CompactWAL())
+	require.NoError(t, pool.Close(context.Background()))
+	restored := NewDataPool(WithWAL(openWAL(t, path)))
+	r := restored.Bucket("alice")
+	value, ts, _, err := r.GetE(0)
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+	assert.Zero(t, ts, "Compactions leave cached errors out")
+}
+
+func TestPutErrorPublishSwap(t *testing.T) {
+	live, staging := NewDataPool(), NewDataPool()
+	live.Handle("config").Put("old")
+	s := staging.Bucket("config")
+	s.PutError(errors.New("failed"), 0)
+	var stored []any
+	live.OnPut(func(_ string, value any, _ int64) { stored = append(stored, value) })
+
+	require.NoError(t, live.PublishSwap(staging))
+	value, ts, _ := live.Handle("config").Get(0)
+	assert.Nil(t, value)
+	assert.Zero(t, ts, "Cached errors are not published")
+	assert.Empty(t, stored)
+	value, _, _ = staging.Handle("config").Get(0)
+	assert.Equal(t, "old", value)
+}
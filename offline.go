@@ -0,0 +1,176 @@
+package datapool
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultOfflineRetry is how often an offline pool retries its backend when
+// WithOfflineRetry is not given.
+const defaultOfflineRetry = time.Second
+
+// ErrOffline is returned by reads and writes at ConsistencyLeader or
+// ConsistencyQuorum while the backend is unreachable in offline mode (see
+// WithOfflineQueue).
+var ErrOffline = errors.New("datapool: backend offline")
+
+// BackendStatus describes a pool's connection to its backend in offline mode
+// (see WithOfflineQueue).
+type BackendStatus struct {
+	// Offline reports whether the last backend call failed.
+	Offline bool
+	// Queued is the number of writes waiting to be replayed.
+	Queued int
+	// Dropped counts queued writes dropped to respect the queue limit.
+	Dropped uint64
+	// Replayed counts queued writes stored in the backend after it came back.
+	Replayed uint64
+}
+
+// offlineState tracks an unreachable backend and the writes queued for it.
+// Writes are queued at most once per bucket: a newer write replaces the
+// queued one, which the backend would ignore anyway.
+type offlineState struct {
+	offline atomic.Bool
+
+	mu       sync.Mutex
+	retryAt  int64
+	queue    *list.List // of Update, oldest first
+	queued   map[string]*list.Element
+	dropped  uint64
+	replayed uint64
+}
+
+// BackendStatus returns the state of the pool's connection to its backend.
+// It is the zero BackendStatus unless offline mode is on.
+func (p *DataPool) BackendStatus() BackendStatus {
+	o := &p.offline
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	st := BackendStatus{Offline: o.offline.Load(), Dropped: o.dropped, Replayed: o.replayed}
+	if o.queue != nil {
+		st.Queued = o.queue.Len()
+	}
+	return st
+}
+
+// offlineMode reports whether the pool queues writes for an unreachable
+// backend.
+func (p *DataPool) offlineMode() bool {
+	return p.opts.backend != nil && p.opts.offlineQueue > 0
+}
+
+// isOffline reports whether the pool is in offline mode and its backend is
+// unreachable.
+func (p *DataPool) isOffline() bool {
+	return p.offline.offline.Load()
+}
+
+// backendAvailable reports whether a backend call should be made: always
+// when online, and once per retry interval while offline.
+func (p *DataPool) backendAvailable() bool {
+	o := &p.offline
+	if !o.offline.Load() {
+		return true
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := p.now()
+	if now < o.retryAt {
+		return false
+	}
+	o.retryAt = now + int64(p.opts.offlineRetry)
+	return true
+}
+
+// backendFailed takes the pool offline after a failed backend call. Only the
+// error that takes it offline is reported.
+func (p *DataPool) backendFailed(name string, err error) {
+	o := &p.offline
+	o.mu.Lock()
+	wasOffline := o.offline.Swap(true)
+	if !wasOffline {
+		o.retryAt = p.now() + int64(p.opts.offlineRetry)
+	}
+	o.mu.Unlock()
+
+	if !wasOffline {
+		p.reportError(name, err)
+	}
+}
+
+// enqueue queues u for replay, replacing an older queued write to the same
+// bucket and dropping the oldest write if the queue is full, which it reports
+// as an error wrapping ErrBackpressure.
+func (p *DataPool) enqueue(u Update) {
+	if dropped, ok := p.queue(u); ok {
+		p.reportError(dropped, fmt.Errorf("%w: offline queue full, dropped write of %q", ErrBackpressure, dropped))
+	}
+}
+
+// queue is enqueue without the report, returning the bucket of the write it
+// dropped, if any.
+func (p *DataPool) queue(u Update) (dropped string, ok bool) {
+	o := &p.offline
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.queue == nil {
+		o.queue = list.New()
+		o.queued = make(map[string]*list.Element)
+	}
+	if e, ok := o.queued[u.Bucket]; ok {
+		if e.Value.(Update).Timestamp < u.Timestamp {
+			e.Value = u
+			o.queue.MoveToBack(e)
+		}
+		return "", false
+	}
+	if o.queue.Len() >= p.opts.offlineQueue {
+		oldest := o.queue.Front()
+		o.queue.Remove(oldest)
+		dropped, ok = oldest.Value.(Update).Bucket, true
+		delete(o.queued, dropped)
+		o.dropped++
+	}
+	o.queued[u.Bucket] = o.queue.PushBack(u)
+	return dropped, ok
+}
+
+// replay stores the queued writes in the backend, oldest first, and brings
+// the pool back online once the queue is empty. It stops at the first error.
+func (p *DataPool) replay(ctx context.Context) error {
+	o := &p.offline
+	for {
+		o.mu.Lock()
+		if o.queue == nil || o.queue.Len() == 0 {
+			o.offline.Store(false)
+			o.mu.Unlock()
+			return nil
+		}
+		e := o.queue.Front()
+		u := e.Value.(Update)
+		o.mu.Unlock()
+
+		if err := p.backendPut(ctx, u, ConsistencyDefault); err != nil {
+			return err
+		}
+
+		o.mu.Lock()
+		// A newer write may have replaced the entry while it was replayed.
+		if e.Value.(Update).Timestamp == u.Timestamp && o.queued[u.Bucket] == e {
+			o.queue.Remove(e)
+			delete(o.queued, u.Bucket)
+		}
+		o.replayed++
+		o.mu.Unlock()
+	}
+}
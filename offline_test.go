@@ -0,0 +1,164 @@
+package datapool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errUnreachable = errors.New("backend unreachable")
+
+// flakyBackend is a MemoryBackend that fails every call while down.
+type flakyBackend struct {
+	MemoryBackend
+	down  atomic.Bool
+	calls atomic.Int32
+}
+
+func (f *flakyBackend) Get(ctx context.Context, name string) (Update, error) {
+	f.calls.Add(1)
+	if f.down.Load() {
+		return Update{}, errUnreachable
+	}
+	return f.MemoryBackend.Get(ctx, name)
+}
+
+func (f *flakyBackend) Put(ctx context.Context, u Update) error {
+	f.calls.Add(1)
+	if f.down.Load() {
+		return errUnreachable
+	}
+	return f.MemoryBackend.Put(ctx, u)
+}
+
+func TestOfflineMode(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	backend := &flakyBackend{}
+	var reported []error
+	pool := NewDataPool(
+		WithClock(clock),
+		WithBackend(backend),
+		WithOfflineQueue(10),
+		WithErrorHandler(func(bucket string, err error) {
+			reported = append(reported, err)
+		}),
+	)
+
+	config := pool.Bucket("config")
+	ts := config.Put("v1")
+	_, _, fresh := config.Get(ts - 1)
+	assert.True(t, fresh)
+
+	backend.down.Store(true)
+	config.Put("v2")
+	assert.Equal(t, BackendStatus{Offline: true, Queued: 1}, pool.BackendStatus())
+	assert.Equal(t, []error{errUnreachable}, reported)
+
+	val, got, fresh := config.Get(0)
+	assert.Equal(t, "v2", val, "Local values are served while offline")
+	assert.NotZero(t, got)
+	assert.False(t, fresh, "They are flagged stale")
+
+	// Within the retry interval the backend is not called at all.
+	calls := backend.calls.Load()
+	other := pool.Bucket("other")
+	other.Put(1)
+	missing := pool.Bucket("missing")
+	missing.Get(0)
+	assert.Equal(t, calls, backend.calls.Load())
+	assert.Equal(t, 2, pool.BackendStatus().Queued)
+	assert.Len(t, reported, 1, "Only the failure taking the pool offline is reported")
+
+	backend.down.Store(false)
+	clock.Advance(time.Second)
+	last := other.Put(2)
+
+	assert.Equal(t, BackendStatus{Replayed: 2}, pool.BackendStatus())
+	u, err := backend.MemoryBackend.Get(context.Background(), "config")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", u.Value, "Queued writes are replayed")
+	u, _ = backend.MemoryBackend.Get(context.Background(), "other")
+	assert.Equal(t, last, u.Timestamp)
+
+	_, _, fresh = config.Get(0)
+	assert.True(t, fresh, "Values are fresh again once online")
+}
+
+func TestOfflineQueueLimit(t *testing.T) {
+	backend := &flakyBackend{}
+	backend.down.Store(true)
+	var reported []error
+	pool := NewDataPool(WithBackend(backend), WithOfflineQueue(2), WithErrorHandler(func(_ string, err error) {
+		reported = append(reported, err)
+	}))
+
+	for _, name := range []string{"a", "b", "a", "c"} {
+		b := pool.Bucket(name)
+		b.Put(name)
+	}
+	// "a" was queued once and replaced, moving it behind "b", which was
+	// then dropped as the oldest.
+	assert.Equal(t, BackendStatus{Offline: true, Queued: 2, Dropped: 1}, pool.BackendStatus())
+	require.NotEmpty(t, reported)
+	assert.ErrorIs(t, reported[len(reported)-1], ErrBackpressure)
+	assert.EqualError(t, reported[len(reported)-1], `datapool: backpressure: offline queue full, dropped write of "b"`)
+}
+
+func TestOfflineReadReconnects(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	backend := &flakyBackend{}
+	pool := NewDataPool(WithClock(clock), WithBackend(backend), WithOfflineQueue(10))
+
+	backend.down.Store(true)
+	queued := pool.Bucket("queued")
+	queued.Put("v")
+	require.True(t, pool.BackendStatus().Offline)
+
+	backend.down.Store(false)
+	require.NoError(t, backend.MemoryBackend.Put(context.Background(), Update{Bucket: "remote", Value: "r", Timestamp: 1}))
+	clock.Advance(time.Second)
+
+	remote := pool.Bucket("remote")
+	val, _, _ := remote.Get(0)
+	assert.Equal(t, "r", val)
+	assert.Equal(t, BackendStatus{Replayed: 1}, pool.BackendStatus(), "A successful read replays the queue")
+}
+
+func TestOfflineModeOff(t *testing.T) {
+	backend := &flakyBackend{}
+	backend.down.Store(true)
+	var reported int
+	pool := NewDataPool(WithBackend(backend), WithErrorHandler(func(string, error) { reported++ }))
+
+	b := pool.Bucket("config")
+	ts := b.Put(1)
+	b.Put(2)
+	_, _, fresh := b.Get(ts)
+	assert.True(t, fresh, "Without offline mode values are not flagged")
+	assert.Equal(t, 2, reported, "Every failure is reported")
+	assert.Equal(t, BackendStatus{}, pool.BackendStatus())
+}
+
+func TestOfflineOpenMetrics(t *testing.T) {
+	backend := &flakyBackend{}
+	backend.down.Store(true)
+	pool := NewDataPool(WithBackend(backend), WithOfflineQueue(1))
+	a := pool.Bucket("a")
+	a.Put(1)
+	b := pool.Bucket("b")
+	b.Put(2)
+
+	var buf bytes.Buffer
+	require.NoError(t, pool.WriteOpenMetrics(&buf, 0))
+	samples := parseSamples(t, buf.String())
+	assert.Equal(t, 1.0, samples["datapool_backend_offline"])
+	assert.Equal(t, 1.0, samples["datapool_backend_queued_writes"])
+	assert.Equal(t, 1.0, samples["datapool_backend_dropped_writes_total"])
+	assert.Equal(t, 0.0, samples["datapool_backend_replayed_writes_total"])
+}
@@ -0,0 +1,244 @@
+package datapool
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxAgeSeries is the cardinality limit used by WriteOpenMetrics and
+// OpenMetricsHandler when none is given.
+const DefaultMaxAgeSeries = 1000
+
+// OverflowBucketLabel is the bucket label value of the series that aggregates
+// the buckets beyond the cardinality limit.
+const OverflowBucketLabel = "__overflow__"
+
+// OpenMetricsContentType is the content type of the OpenMetrics text format.
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// WriteOpenMetrics writes a snapshot of the pool in the OpenMetrics text
+// format. Every updated bucket's age is exported as a datapool_bucket_age_seconds
+// gauge labeled with the bucket name. Buckets are labeled in creation order up
+// to maxSeries (DefaultMaxAgeSeries if maxSeries <= 0); the remaining buckets
+// are aggregated into a single series labeled OverflowBucketLabel reporting
+// their maximum age, and counted by datapool_bucket_age_overflow_buckets. In
+// offline mode (see WithOfflineQueue) the backend status is exported too.
+// Buckets with a freshness SLO (see Bucket.SetFreshnessSLO) also export its
+// attainment, target and reads in its window.
+//
+// Everything exported is gathered in a single pass before anything is
+// written, holding each bucket's read lock only to read its timestamp, so
+// scrapes do not stall writers however large the values. Ages are measured
+// from the start of the pass; buckets updated during it have age zero.
+func (p *DataPool) WriteOpenMetrics(w io.Writer, maxSeries int) error {
+	if maxSeries <= 0 {
+		maxSeries = DefaultMaxAgeSeries
+	}
+
+	snap := p.metricsSnapshot()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# TYPE datapool_bucket_age_seconds gauge")
+	fmt.Fprintln(bw, "# UNIT datapool_bucket_age_seconds seconds")
+	fmt.Fprintln(bw, "# HELP datapool_bucket_age_seconds Time since the bucket was last updated.")
+
+	series, overflow, empty := 0, 0, 0
+	var overflowAge float64
+	for _, b := range snap.buckets {
+		if b.timestamp == 0 {
+			empty++
+			continue
+		}
+
+		age := float64(max(snap.at-b.timestamp, 0)) / float64(time.Second)
+		if series < maxSeries {
+			fmt.Fprintf(bw, "datapool_bucket_age_seconds{bucket=\"%s\"} %s\n", escapeLabel(b.name), formatFloat(age))
+			series++
+			continue
+		}
+		overflow++
+		overflowAge = max(overflowAge, age)
+	}
+	if overflow > 0 {
+		fmt.Fprintf(bw, "datapool_bucket_age_seconds{bucket=\"%s\"} %s\n", OverflowBucketLabel, formatFloat(overflowAge))
+	}
+
+	writeSLOMetrics(bw, snap)
+
+	fmt.Fprintln(bw, "# TYPE datapool_bucket_age_overflow_buckets gauge")
+	fmt.Fprintln(bw, "# HELP datapool_bucket_age_overflow_buckets Buckets aggregated into the overflow age series.")
+	fmt.Fprintf(bw, "datapool_bucket_age_overflow_buckets %d\n", overflow)
+
+	fmt.Fprintln(bw, "# TYPE datapool_buckets gauge")
+	fmt.Fprintln(bw, "# HELP datapool_buckets Buckets in the pool.")
+	fmt.Fprintf(bw, "datapool_buckets{state=\"updated\"} %d\n", len(snap.buckets)-empty)
+	fmt.Fprintf(bw, "datapool_buckets{state=\"empty\"} %d\n", empty)
+
+	fmt.Fprintln(bw, "# TYPE datapool_corruptions counter")
+	fmt.Fprintln(bw, "# HELP datapool_corruptions Internal invariant violations detected.")
+	fmt.Fprintf(bw, "datapool_corruptions_total %d\n", snap.corruptions)
+
+	fmt.Fprintln(bw, "# TYPE datapool_schema_mismatches counter")
+	fmt.Fprintln(bw, "# HELP datapool_schema_mismatches Reads rejected for the schema version of the value.")
+	fmt.Fprintf(bw, "datapool_schema_mismatches_total %d\n", snap.schemaMismatches)
+
+	if snap.offlineMode {
+		st := snap.backend
+		offline := 0
+		if st.Offline {
+			offline = 1
+		}
+		fmt.Fprintln(bw, "# TYPE datapool_backend_offline gauge")
+		fmt.Fprintln(bw, "# HELP datapool_backend_offline Whether the backend is unreachable.")
+		fmt.Fprintf(bw, "datapool_backend_offline %d\n", offline)
+		fmt.Fprintln(bw, "# TYPE datapool_backend_queued_writes gauge")
+		fmt.Fprintln(bw, "# HELP datapool_backend_queued_writes Writes waiting for the backend.")
+		fmt.Fprintf(bw, "datapool_backend_queued_writes %d\n", st.Queued)
+		fmt.Fprintln(bw, "# TYPE datapool_backend_dropped_writes counter")
+		fmt.Fprintln(bw, "# HELP datapool_backend_dropped_writes Queued writes dropped at the queue limit.")
+		fmt.Fprintf(bw, "datapool_backend_dropped_writes_total %d\n", st.Dropped)
+		fmt.Fprintln(bw, "# TYPE datapool_backend_replayed_writes counter")
+		fmt.Fprintln(bw, "# HELP datapool_backend_replayed_writes Queued writes replayed to the backend.")
+		fmt.Fprintf(bw, "datapool_backend_replayed_writes_total %d\n", st.Replayed)
+	}
+
+	fmt.Fprintln(bw, "# EOF")
+	return bw.Flush()
+}
+
+// writeSLOMetrics writes the attainment and target of the buckets with a
+// freshness SLO, if any.
+func writeSLOMetrics(bw *bufio.Writer, snap metricsSnapshot) {
+	var statuses []SLOStatus
+	var names []string
+	for _, b := range snap.buckets {
+		if b.slo != nil {
+			statuses = append(statuses, b.slo.status(snap.at))
+			names = append(names, b.name)
+		}
+	}
+	if len(statuses) == 0 {
+		return
+	}
+
+	fmt.Fprintln(bw, "# TYPE datapool_bucket_freshness_slo_attainment gauge")
+	fmt.Fprintln(bw, "# HELP datapool_bucket_freshness_slo_attainment Share of reads in the SLO window that returned a fresh value.")
+	for i, st := range statuses {
+		fmt.Fprintf(bw, "datapool_bucket_freshness_slo_attainment{bucket=\"%s\"} %s\n", escapeLabel(names[i]), formatFloat(st.Attainment()))
+	}
+	fmt.Fprintln(bw, "# TYPE datapool_bucket_freshness_slo_target gauge")
+	fmt.Fprintln(bw, "# HELP datapool_bucket_freshness_slo_target Share of reads the freshness SLO requires to be fresh.")
+	for i, st := range statuses {
+		fmt.Fprintf(bw, "datapool_bucket_freshness_slo_target{bucket=\"%s\"} %s\n", escapeLabel(names[i]), formatFloat(st.SLO.Target))
+	}
+	fmt.Fprintln(bw, "# TYPE datapool_bucket_freshness_slo_reads gauge")
+	fmt.Fprintln(bw, "# HELP datapool_bucket_freshness_slo_reads Reads in the SLO window, fresh or not.")
+	for i, st := range statuses {
+		name := escapeLabel(names[i])
+		fmt.Fprintf(bw, "datapool_bucket_freshness_slo_reads{bucket=\"%s\",fresh=\"true\"} %d\n", name, st.FreshReads)
+		fmt.Fprintf(bw, "datapool_bucket_freshness_slo_reads{bucket=\"%s\",fresh=\"false\"} %d\n", name, st.Reads-st.FreshReads)
+	}
+}
+
+// metricsSnapshot is the state exported by WriteOpenMetrics.
+type metricsSnapshot struct {
+	// at is the pool-clock time the snapshot was started at.
+	at      int64
+	buckets []metricsBucket
+
+	corruptions      uint64
+	schemaMismatches uint64
+	offlineMode      bool
+	backend          BackendStatus
+}
+
+type metricsBucket struct {
+	name      string
+	timestamp int64
+	slo       *sloTracker
+}
+
+// metricsRetries is how many times metricsSnapshot walks the buckets before
+// locking them all instead.
+const metricsRetries = 4
+
+// metricsSnapshot gathers the state exported by WriteOpenMetrics, buckets in
+// creation order. Only timestamps are read, under each bucket's read lock in
+// turn, so writers wait for one bucket at most; values are neither read nor
+// copied. A walk that a write of several buckets at once, by PutMany or a
+// transaction, ran into is retried, so the snapshot holds none or all of
+// every such write. If they keep coming, the buckets are read-locked together
+// instead, as by GetMany.
+func (p *DataPool) metricsSnapshot() metricsSnapshot {
+	snap := metricsSnapshot{at: p.now()}
+	buckets := p.all()
+	for _, b := range buckets {
+		if b.system {
+			p.refreshSystem(b)
+		}
+	}
+	snap.buckets = make([]metricsBucket, len(buckets))
+
+	consistent := false
+	for range metricsRetries {
+		gen, idle := p.commitGeneration()
+		if !idle {
+			runtime.Gosched()
+			continue
+		}
+		for i, b := range buckets {
+			b.guard.RLock()
+			snap.buckets[i] = metricsBucket{name: b.name(), timestamp: b.readTimestamp(p), slo: b.slo.Load()}
+			b.guard.RUnlock()
+		}
+		if again, _ := p.commitGeneration(); again == gen {
+			consistent = true
+			break
+		}
+	}
+	if !consistent {
+		locked := lockOrder(slices.Clone(buckets))
+		for _, b := range locked {
+			b.guard.RLock()
+		}
+		for i, b := range buckets {
+			snap.buckets[i] = metricsBucket{name: b.name(), timestamp: b.readTimestamp(p), slo: b.slo.Load()}
+		}
+		for _, b := range locked {
+			b.guard.RUnlock()
+		}
+	}
+
+	snap.corruptions = p.Corruptions()
+	snap.schemaMismatches = p.SchemaMismatches()
+	if snap.offlineMode = p.offlineMode(); snap.offlineMode {
+		snap.backend = p.BackendStatus()
+	}
+	return snap
+}
+
+// OpenMetricsHandler returns an http.Handler serving WriteOpenMetrics with the
+// given cardinality limit, for scraping by Prometheus-compatible collectors.
+func (p *DataPool) OpenMetricsHandler(maxSeries int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", OpenMetricsContentType)
+		p.WriteOpenMetrics(w, maxSeries)
+	})
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
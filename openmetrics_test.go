@@ -0,0 +1,179 @@
+package datapool
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseSamples returns the samples of an OpenMetrics exposition keyed by
+// metric name and labels.
+func parseSamples(t *testing.T, text string) map[string]float64 {
+	samples := make(map[string]float64)
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	require.Equal(t, "# EOF", lines[len(lines)-1], "Exposition must end with # EOF")
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		require.NoError(t, err, line)
+		samples[line[:i]] = value
+	}
+	return samples
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	pool := NewDataPool()
+	users := pool.Bucket("users")
+	users.Put("data")
+	odd := pool.Bucket("quote\"back\\slash")
+	odd.Put(1)
+	pool.Bucket("empty")
+
+	var buf bytes.Buffer
+	require.NoError(t, pool.WriteOpenMetrics(&buf, 0))
+	samples := parseSamples(t, buf.String())
+
+	assert.Contains(t, samples, `datapool_bucket_age_seconds{bucket="users"}`)
+	assert.GreaterOrEqual(t, samples[`datapool_bucket_age_seconds{bucket="users"}`], 0.0)
+	assert.Less(t, samples[`datapool_bucket_age_seconds{bucket="users"}`], 10.0)
+	assert.Contains(t, samples, `datapool_bucket_age_seconds{bucket="quote\"back\\slash"}`)
+	assert.NotContains(t, samples, `datapool_bucket_age_seconds{bucket="empty"}`, "Empty buckets have no age")
+
+	assert.Equal(t, 0.0, samples["datapool_bucket_age_overflow_buckets"])
+	assert.Equal(t, 2.0, samples[`datapool_buckets{state="updated"}`])
+	assert.Equal(t, 1.0, samples[`datapool_buckets{state="empty"}`])
+	assert.Equal(t, 0.0, samples["datapool_corruptions_total"])
+}
+
+func TestWriteOpenMetricsCardinalityLimit(t *testing.T) {
+	pool := NewDataPool()
+	for i := 0; i < 10; i++ {
+		b := pool.Bucket(fmt.Sprintf("bucket-%d", i))
+		b.Put(i)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, pool.WriteOpenMetrics(&buf, 4))
+	samples := parseSamples(t, buf.String())
+
+	series := 0
+	for key := range samples {
+		if strings.HasPrefix(key, "datapool_bucket_age_seconds{") {
+			series++
+		}
+	}
+	assert.Equal(t, 5, series, "Four labeled series plus the overflow series")
+	assert.Contains(t, samples, `datapool_bucket_age_seconds{bucket="bucket-3"}`, "Buckets are labeled in creation order")
+	assert.NotContains(t, samples, `datapool_bucket_age_seconds{bucket="bucket-4"}`)
+	assert.GreaterOrEqual(t, samples[`datapool_bucket_age_seconds{bucket="__overflow__"}`], 0.0)
+	assert.Equal(t, 6.0, samples["datapool_bucket_age_overflow_buckets"])
+}
+
+func TestOpenMetricsHandler(t *testing.T) {
+	pool := NewDataPool()
+	bucket := pool.Bucket("users")
+	bucket.Put("data")
+
+	rec := httptest.NewRecorder()
+	pool.OpenMetricsHandler(0).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, OpenMetricsContentType, rec.Header().Get("Content-Type"))
+	assert.Contains(t, parseSamples(t, rec.Body.String()), `datapool_bucket_age_seconds{bucket="users"}`)
+}
+
+func TestWriteOpenMetricsSnapshot(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	// With the clock standing still, the second timestamp is ahead of it.
+	pool.Handle("a").Put(1)
+	pool.Handle("b").Put(2)
+
+	var buf bytes.Buffer
+	require.NoError(t, pool.WriteOpenMetrics(&buf, 0))
+	samples := parseSamples(t, buf.String())
+	assert.Equal(t, 0.0, samples[`datapool_bucket_age_seconds{bucket="a"}`])
+	assert.Equal(t, 0.0, samples[`datapool_bucket_age_seconds{bucket="b"}`], "Ages are never negative")
+}
+
+func TestWriteOpenMetricsDuringChurn(t *testing.T) {
+	pool := NewDataPool()
+	for i := 0; i < 20; i++ {
+		pool.Bucket(fmt.Sprintf("b%d", i))
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			pool.Handle(fmt.Sprintf("b%d", i%40)).Put(i)
+		}
+	}()
+
+	for range 20 {
+		var buf bytes.Buffer
+		require.NoError(t, pool.WriteOpenMetrics(&buf, 0))
+		samples := parseSamples(t, buf.String())
+		buckets := 0.0
+		for key, value := range samples {
+			if strings.HasPrefix(key, "datapool_bucket_age_seconds{") {
+				assert.GreaterOrEqual(t, value, 0.0, key)
+				buckets++
+			}
+		}
+		assert.Equal(t, buckets, samples[`datapool_buckets{state="updated"}`], "Series and counts come from the same snapshot")
+	}
+}
+
+func TestMetricsSnapshotSeesWholeBatches(t *testing.T) {
+	pool := NewDataPool()
+	pool.PutMany(map[string]any{"a": 0, "b": 0, "c": 0})
+	buckets := pool.all()
+	first, second, last := buckets[0], buckets[1], buckets[2]
+
+	// Hold the walk up between the first two buckets while a batch writes
+	// all three, as PutMany does.
+	second.guard.Lock()
+	snaps := make(chan metricsSnapshot)
+	go func() { snaps <- pool.metricsSnapshot() }()
+	time.Sleep(20 * time.Millisecond)
+	end := pool.beginCommit()
+	first.guard.Lock()
+	last.guard.Lock()
+	ts := pool.stamp()
+	for _, b := range buckets {
+		b.store(1, ts)
+	}
+	for _, b := range buckets {
+		b.guard.Unlock()
+	}
+	end()
+
+	snap := <-snaps
+	require.Len(t, snap.buckets, 3)
+	for _, b := range snap.buckets {
+		assert.Equal(t, ts, b.timestamp, "A batch is seen whole")
+	}
+
+	// Batches that never let a walk finish cleanly fall back to locking.
+	end = pool.beginCommit()
+	snap = pool.metricsSnapshot()
+	end()
+	assert.Len(t, snap.buckets, 3)
+}
@@ -0,0 +1,145 @@
+package datapool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenSearchAuditSink is an AuditSink indexing records in OpenSearch or
+// Elasticsearch with the bulk API (POST /_bulk). Every record is created as a
+// document with an ID derived from its bucket and timestamp, so records
+// retried after a partial failure are not stored twice.
+type OpenSearchAuditSink struct {
+	// URL is the cluster's base URL, e.g. https://opensearch:9200.
+	URL string
+
+	// Index is the index or data stream records are created in. Defaults to
+	// "datapool-audit".
+	Index string
+
+	// Client sends the requests. http.DefaultClient is used when nil.
+	Client *http.Client
+
+	// Header is added to every request, e.g. for an Authorization header.
+	Header http.Header
+}
+
+type openSearchAction struct {
+	Create struct {
+		Index string `json:"_index"`
+		ID    string `json:"_id"`
+	} `json:"create"`
+}
+
+type openSearchDocument struct {
+	Timestamp  time.Time `json:"@timestamp"`
+	Bucket     string    `json:"bucket"`
+	Source     string    `json:"source"`
+	SourceName string    `json:"source_name,omitempty"`
+	SourceAt   time.Time `json:"source_at"`
+	Version    uint64    `json:"version"`
+	Size       int       `json:"size"`
+	Peer       string    `json:"peer,omitempty"`
+}
+
+type openSearchResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// WriteAudit indexes records with a single bulk request. Records that already
+// exist count as stored.
+func (s *OpenSearchAuditSink) WriteAudit(ctx context.Context, records []AuditRecord) error {
+	index := s.Index
+	if index == "" {
+		index = "datapool-audit"
+	}
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, r := range records {
+		var action openSearchAction
+		action.Create.Index = index
+		action.Create.ID = r.Bucket + "@" + strconv.FormatInt(r.Timestamp.UnixNano(), 10)
+		doc := openSearchDocument{
+			Timestamp:  r.Timestamp.UTC(),
+			Bucket:     r.Bucket,
+			Source:     r.Source.Kind.String(),
+			SourceName: r.Source.Name,
+			SourceAt:   r.Source.At.UTC(),
+			Version:    r.Version,
+			Size:       r.Size,
+			Peer:       r.Peer,
+		}
+		if err := enc.Encode(action); err != nil {
+			return fmt.Errorf("datapool: encode audit records: %w", err)
+		}
+		if err := enc.Encode(doc); err != nil {
+			return fmt.Errorf("datapool: encode audit records: %w", err)
+		}
+	}
+
+	url := strings.TrimSuffix(s.URL, "/") + "/_bulk"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return fmt.Errorf("datapool: opensearch request: %w", err)
+	}
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("datapool: post audit records: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("datapool: post audit records: unexpected status %s", resp.Status)
+	}
+	var result openSearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("datapool: decode bulk response: %w", err)
+	}
+	if !result.Errors {
+		return nil
+	}
+
+	failed := 0
+	var first string
+	for _, item := range result.Items {
+		for _, status := range item {
+			// Conflicts are records stored by an earlier attempt.
+			if status.Error == nil || status.Status == http.StatusConflict {
+				continue
+			}
+			if failed == 0 {
+				first = status.Error.Type + ": " + status.Error.Reason
+			}
+			failed++
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("datapool: post audit records: %d of %d failed, first: %s", failed, len(records), first)
+}
@@ -0,0 +1,92 @@
+package datapool
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenSearchAuditSink(t *testing.T) {
+	var lines []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/_bulk", r.URL.Path)
+		assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Basic secret", r.Header.Get("Authorization"))
+
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var line map[string]any
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+			lines = append(lines, line)
+		}
+		fmt.Fprint(w, `{"errors":false,"items":[{"create":{"status":201}}]}`)
+	}))
+	defer srv.Close()
+
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	sink := &OpenSearchAuditSink{URL: srv.URL + "/", Header: http.Header{"Authorization": {"Basic secret"}}}
+	err := sink.WriteAudit(context.Background(), []AuditRecord{{
+		Bucket:    "config",
+		Source:    Source{Kind: SourceReplica, Name: "api-2", At: at},
+		Timestamp: at,
+		Version:   7,
+		Size:      42,
+		Peer:      "api-1",
+	}})
+	require.NoError(t, err)
+
+	require.Len(t, lines, 2)
+	assert.Equal(t, map[string]any{"create": map[string]any{
+		"_index": "datapool-audit",
+		"_id":    fmt.Sprintf("config@%d", at.UnixNano()),
+	}}, lines[0])
+	assert.Equal(t, map[string]any{
+		"@timestamp":  "2024-01-01T12:00:00Z",
+		"bucket":      "config",
+		"source":      "replica",
+		"source_name": "api-2",
+		"source_at":   "2024-01-01T12:00:00Z",
+		"version":     float64(7),
+		"size":        float64(42),
+		"peer":        "api-1",
+	}, lines[1])
+}
+
+func TestOpenSearchAuditSinkErrors(t *testing.T) {
+	response := ""
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, response)
+	}))
+	defer srv.Close()
+
+	sink := &OpenSearchAuditSink{URL: srv.URL, Index: "audit"}
+	records := []AuditRecord{{Bucket: "a"}, {Bucket: "b"}, {Bucket: "c"}}
+
+	response = `{"errors":true,"items":[
+		{"create":{"status":201}},
+		{"create":{"status":409,"error":{"type":"version_conflict_engine_exception","reason":"exists"}}},
+		{"create":{"status":201}}]}`
+	assert.NoError(t, sink.WriteAudit(context.Background(), records), "Records stored by an earlier attempt are not errors")
+
+	response = `{"errors":true,"items":[
+		{"create":{"status":201}},
+		{"create":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"bad size"}}},
+		{"create":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"busy"}}}]}`
+	assert.EqualError(t, sink.WriteAudit(context.Background(), records),
+		"datapool: post audit records: 2 of 3 failed, first: mapper_parsing_exception: bad size")
+
+	status = http.StatusUnauthorized
+	assert.EqualError(t, sink.WriteAudit(context.Background(), records),
+		"datapool: post audit records: unexpected status 401 Unauthorized")
+}
@@ -0,0 +1,501 @@
+package datapool
+
+import (
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultShards         = 32
+	defaultRefreshWorkers = 4
+	defaultWatchBuffer    = 16
+)
+
+// Option configures a DataPool at construction time. Options are applied in
+// order, so a later option overrides an earlier one of the same kind. New pool
+// features are configured by adding options, leaving NewDataPool unchanged.
+type Option func(*options)
+
+type options struct {
+	panicPolicy PanicPolicy
+	clock       Clock
+	pressure    *PressureConfig
+	shards      int
+	defaultTTL  time.Duration
+	metrics     MetricsRecorder
+	tracer      Tracer
+	watchBuffer int
+
+	maxBuckets int
+	eviction   EvictionPolicy
+	onEvict    func(name string, value any)
+	onPut      func(name string, value any, ts int64)
+
+	refreshWorkers   int
+	namespaceWeights map[string]int
+	loaderLimits     map[string]int
+
+	backend        Backend
+	backendTimeout time.Duration
+	onError        func(name string, err error)
+	peerName       string
+	driftThreshold time.Duration
+	offlineQueue   int
+	offlineRetry   time.Duration
+
+	loader      Loader
+	writer      Writer
+	negativeTTL time.Duration
+	softTTL     time.Duration
+	hardTTL     time.Duration
+
+	codec     Codec
+	nameRules *NameRules
+
+	memoryBudget int64
+	sizer        Sizer
+	tenantQuota  TenantQuota
+
+	hotGets *HotGetConfig
+
+	logger    *slog.Logger
+	logLevels [numLogEvents]slog.Level
+
+	wal      *WAL
+	snapshot *snapshotFile
+
+	claimTTL    time.Duration
+	idGenerator func() string
+	history     int
+}
+
+func defaultOptions() options {
+	return options{
+		panicPolicy: ReportViolations,
+		clock:       SystemClock{},
+		shards:      defaultShards,
+		eviction:    LRU,
+		watchBuffer: defaultWatchBuffer,
+
+		refreshWorkers:   defaultRefreshWorkers,
+		namespaceWeights: make(map[string]int),
+		loaderLimits:     make(map[string]int),
+
+		backendTimeout: defaultBackendTimeout,
+		peerName:       defaultPeerName(),
+		offlineRetry:   defaultOfflineRetry,
+
+		codec: JSONCodec,
+
+		logLevels: defaultLogLevels,
+
+		claimTTL: DefaultClaimTTL,
+	}
+}
+
+// WithShards sets how many shards the bucket name index is split into. More
+// shards reduce lock contention when many goroutines create or resolve buckets
+// concurrently. The count is rounded up to a power of two; the default is 32.
+func WithShards(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.shards = n
+		}
+	}
+}
+
+// WithRefreshWorkers sets how many refreshes scheduled with ScheduleRefresh may
+// run concurrently. The default is 4.
+func WithRefreshWorkers(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.refreshWorkers = n
+		}
+	}
+}
+
+// WithNamespaceWeight gives a namespace a share of the refresh workers
+// proportional to weight. Namespaces without an explicit weight have weight 1.
+func WithNamespaceWeight(namespace string, weight int) Option {
+	return func(o *options) {
+		o.namespaceWeights[namespace] = weight
+	}
+}
+
+// WithGetCoalescing made the Gets of a bucket arriving between two changes to
+// it share one read of the bucket.
+//
+// Deprecated: Gets no longer lock the bucket they read, which is faster still,
+// so WithGetCoalescing has no effect.
+func WithGetCoalescing() Option {
+	return func(*options) {}
+}
+
+// WithHotGetDetection flags callers issuing pathological Get rates on a
+// single bucket, such as polling loops that should Watch it instead. Callers
+// are told apart by call site, labeled with the calling function and the file
+// and line of the call; a caller reading a bucket more than cfg.Threshold
+// times within cfg.Window is logged to cfg.Logger and reported to a metrics
+// recorder implementing HotGetRecorder. Only sampled Gets walk the stack to
+// find their caller, but every Get counts towards the sample, so detection
+// costs a little on every Get. It is off by default.
+func WithHotGetDetection(cfg HotGetConfig) Option {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = 1000
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Second
+	}
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = 64
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	return func(o *options) {
+		o.hotGets = &cfg
+	}
+}
+
+// WithLogger makes the pool log an event for every bucket created, value
+// stored, bucket removed by Clear, value expired, value evicted and update
+// dropped by a watcher that fell behind, at the levels set with WithLogLevel.
+// Events carry the bucket name and details such as timestamps and versions,
+// never values. Nothing is logged by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithLogLevel sets the level event is logged at by the logger set with
+// WithLogger. A level the logger does not enable silences the event. By
+// default, creations, stored values and expirations are logged at
+// slog.LevelDebug, removals and evictions at slog.LevelInfo, and watch
+// overflows at slog.LevelWarn.
+func WithLogLevel(event LogEvent, level slog.Level) Option {
+	return func(o *options) {
+		if event >= 0 && event < numLogEvents {
+			o.logLevels[event] = level
+		}
+	}
+}
+
+// WithClock sets the clock the pool takes timestamps and ages from. The
+// default is SystemClock; tests can use a ManualClock instead of sleeping.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		if clock != nil {
+			o.clock = clock
+		}
+	}
+}
+
+// WithDefaultTTL sets the TTL of every new bucket, as if SetTTL had been
+// called on it at creation. Zero, the default, means values do not expire.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.defaultTTL = max(ttl, 0)
+	}
+}
+
+// WithMaxBuckets caps the number of buckets in the pool. Creating a bucket
+// beyond the cap evicts an existing one chosen by the eviction policy (LRU by
+// default, see WithEviction). Zero or less means no cap.
+func WithMaxBuckets(n int) Option {
+	return func(o *options) {
+		o.maxBuckets = n
+	}
+}
+
+// WithEviction sets the policy choosing which bucket to evict when the pool
+// exceeds WithMaxBuckets.
+func WithEviction(policy EvictionPolicy) Option {
+	return func(o *options) {
+		o.eviction = policy
+	}
+}
+
+// WithEvictionCallback registers fn to be called with the name and last value
+// of every bucket evicted to respect WithMaxBuckets. It runs outside of all
+// pool locks, on the goroutine whose Bucket call caused the eviction.
+func WithEvictionCallback(fn func(name string, value any)) Option {
+	return func(o *options) {
+		o.onEvict = fn
+	}
+}
+
+// WithPutCallback registers fn to be called after every successful Put with
+// the bucket name, the stored value and its timestamp. It runs outside of all
+// pool locks, on the goroutine calling Put.
+func WithPutCallback(fn func(name string, value any, ts int64)) Option {
+	return func(o *options) {
+		o.onPut = fn
+	}
+}
+
+// WithWatchBuffer sets how many updates each Watch channel buffers before the
+// oldest pending update is dropped. The default is 16.
+func WithWatchBuffer(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.watchBuffer = n
+		}
+	}
+}
+
+// WithBackend makes the pool a local cache of shared storage: every Put is
+// also written to backend, and Get reads through to it for buckets that hold
+// no value. Run SyncBackend to also receive values written by other
+// processes. Without a backend (the default) the pool is purely in-memory.
+func WithBackend(backend Backend) Option {
+	return func(o *options) {
+		o.backend = backend
+	}
+}
+
+// WithBackendTimeout bounds each backend call made by Get and Put. The default
+// is 5s.
+func WithBackendTimeout(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.backendTimeout = d
+		}
+	}
+}
+
+// WithLoader sets the loader called when Get finds a bucket empty, turning
+// the pool into a read-through cache: the loaded value is stored with a
+// SourceLoader provenance step and returned. Concurrent misses of one bucket
+// share a call. Load errors are passed to the error handler and Get returns
+// an empty value; WithNegativeTTL caches them. Buckets can override it with
+// SetLoader.
+func WithLoader(load Loader) Option {
+	return func(o *options) {
+		o.loader = load
+	}
+}
+
+// WithStaleWhileRevalidate makes every new bucket serve stale values while
+// they are refreshed, as if SetStaleWhileRevalidate had been called on it at
+// creation.
+func WithStaleWhileRevalidate(soft, hard time.Duration) Option {
+	return func(o *options) {
+		o.softTTL = max(soft, 0)
+		o.hardTTL = max(hard, 0)
+	}
+}
+
+// WithWriter sets the writer called with every value Put, turning the pool
+// into a write-through cache. It runs before Put returns; its errors are
+// passed to the error handler and do not undo the Put. Values from a loader
+// are not written back. Buckets can override it with SetWriter.
+func WithWriter(write Writer) Option {
+	return func(o *options) {
+		o.writer = write
+	}
+}
+
+// WithCodec sets the codec PutEncoded stores values with. The default is
+// JSONCodec.
+func WithCodec(codec Codec) Option {
+	return func(o *options) {
+		if codec != nil {
+			o.codec = codec
+		}
+	}
+}
+
+// WithOfflineQueue turns on offline mode for the pool's backend. When a
+// backend call fails, the pool goes offline: Get keeps serving local values
+// but reports them as stale, misses are not read through, and writes are
+// queued, up to limit buckets, dropping the oldest writes beyond it. Only the
+// failure taking the pool offline is passed to the error handler. Later
+// writes and misses retry the backend at most once per retry interval (see
+// WithOfflineRetry), and the queue is replayed once it answers again.
+// BackendStatus reports the state.
+func WithOfflineQueue(limit int) Option {
+	return func(o *options) {
+		o.offlineQueue = max(limit, 0)
+	}
+}
+
+// WithOfflineRetry sets how often an offline pool retries its backend, on
+// the pool's clock. The default is 1s.
+func WithOfflineRetry(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.offlineRetry = d
+		}
+	}
+}
+
+// WithPeerName sets the name recorded in the SourceReplica provenance step of
+// values this pool writes to its backend. The default is the host name.
+func WithPeerName(name string) Option {
+	return func(o *options) {
+		if name != "" {
+			o.peerName = name
+		}
+	}
+}
+
+// WithDriftDetection estimates the clock drift of the peers whose values
+// SyncBackend receives, from the send times recorded in their provenance and
+// the local receive times, and reports an error wrapping ErrClockDrift to the
+// error handler whenever a peer's drift grows beyond threshold, as the pools
+// then settle concurrent writes by timestamp wrongly. Transit times make
+// peers seem behind, so a drift behind the pool may also be values delivered
+// late; peers detect a pool that is behind them as ahead. See PeerDrift.
+func WithDriftDetection(threshold time.Duration) Option {
+	return func(o *options) {
+		o.driftThreshold = max(threshold, 0)
+	}
+}
+
+// WithErrorHandler registers fn to be called with the bucket name and error
+// of operations that fail without a way to return the error, such as backend
+// calls made by Get and Put, loaders, writers and recomputations of derived
+// buckets. Errors are dropped by default.
+func WithErrorHandler(fn func(bucket string, err error)) Option {
+	return func(o *options) {
+		o.onError = fn
+	}
+}
+
+// WithMetrics reports pool activity to recorder. See MetricsRecorder.
+func WithMetrics(recorder MetricsRecorder) Option {
+	return func(o *options) {
+		o.metrics = recorder
+	}
+}
+
+// WithPanicPolicy sets how the pool reacts to internal invariant violations.
+// See PanicPolicy for the available policies.
+func WithPanicPolicy(policy PanicPolicy) Option {
+	return func(o *options) {
+		o.panicPolicy = policy
+	}
+}
+
+// WithMemoryBudget caps the approximate number of bytes held by the values of
+// the pool's buckets, as measured by the Sizer (see WithSizer). A write
+// taking the pool over budget drops the values of the least recently updated
+// buckets, lower priorities first (see SetPriority), until it is back within
+// budget; like under memory pressure, their buckets keep their name but read
+// as empty until they are Put again, and callbacks registered with OnEvict
+// are called. Victims are sampled, as with WithMaxBuckets, so the order is
+// approximate. Values just written are never dropped, even if they exceed
+// the budget on their own. Zero or less means no budget.
+func WithMemoryBudget(bytes int64) Option {
+	return func(o *options) {
+		o.memoryBudget = max(bytes, 0)
+	}
+}
+
+// WithSizer sets how the memory budget (see WithMemoryBudget) and
+// MemoryUsage measure values. The default estimates sizes by reflection,
+// counting strings, slices, maps and pointers reached from a value once each.
+// Setting a Sizer without a budget tracks MemoryUsage only.
+func WithSizer(size Sizer) Option {
+	return func(o *options) {
+		o.sizer = size
+	}
+}
+
+// WithMemoryPressure enables eviction of low-priority and cold bucket values
+// when the process nears its memory limit. Evicted buckets keep their name and
+// priority but read as empty until they are Put again.
+func WithMemoryPressure(cfg PressureConfig) Option {
+	if cfg.Pressure == nil {
+		cfg.Pressure = RuntimeMemoryPressure()
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = 0.9
+	}
+	if cfg.Fraction <= 0 || cfg.Fraction > 1 {
+		cfg.Fraction = 0.1
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+
+	return func(o *options) {
+		o.pressure = &cfg
+	}
+}
+
+// Options configures a DataPool from a struct, for applications that load
+// their configuration into structs, such as from a file, rather than build
+// lists of options. Each field has the effect of the option of the same name;
+// zero fields keep the defaults. Pass it with WithOptions.
+type Options struct {
+	Shards         int
+	RefreshWorkers int
+	WatchBuffer    int
+	DefaultTTL     time.Duration
+	MaxBuckets     int
+	Eviction       EvictionPolicy
+	MemoryBudget   int64
+	BackendTimeout time.Duration
+	OfflineQueue   int
+	OfflineRetry   time.Duration
+	PeerName       string
+	DriftDetection time.Duration
+	ClaimTTL       time.Duration
+	History        int
+	NameRules      *NameRules
+
+	Clock        Clock
+	Logger       *slog.Logger
+	Metrics      MetricsRecorder
+	ErrorHandler func(bucket string, err error)
+	Backend      Backend
+	Loader       Loader
+	Writer       Writer
+	Codec        Codec
+	Sizer        Sizer
+}
+
+// WithOptions applies the set fields of opts, as the corresponding options
+// do. Options after it override its fields.
+func WithOptions(opts Options) Option {
+	var set []Option
+	add := func(isSet bool, opt Option) {
+		if isSet {
+			set = append(set, opt)
+		}
+	}
+	add(opts.Shards != 0, WithShards(opts.Shards))
+	add(opts.RefreshWorkers != 0, WithRefreshWorkers(opts.RefreshWorkers))
+	add(opts.WatchBuffer != 0, WithWatchBuffer(opts.WatchBuffer))
+	add(opts.DefaultTTL != 0, WithDefaultTTL(opts.DefaultTTL))
+	add(opts.MaxBuckets != 0, WithMaxBuckets(opts.MaxBuckets))
+	add(opts.Eviction != LRU, WithEviction(opts.Eviction))
+	add(opts.MemoryBudget != 0, WithMemoryBudget(opts.MemoryBudget))
+	add(opts.BackendTimeout != 0, WithBackendTimeout(opts.BackendTimeout))
+	add(opts.OfflineQueue != 0, WithOfflineQueue(opts.OfflineQueue))
+	add(opts.OfflineRetry != 0, WithOfflineRetry(opts.OfflineRetry))
+	add(opts.PeerName != "", WithPeerName(opts.PeerName))
+	add(opts.DriftDetection != 0, WithDriftDetection(opts.DriftDetection))
+	add(opts.ClaimTTL != 0, WithClaimTTL(opts.ClaimTTL))
+	add(opts.History != 0, WithHistory(opts.History))
+	add(opts.Clock != nil, WithClock(opts.Clock))
+	add(opts.Logger != nil, WithLogger(opts.Logger))
+	add(opts.Metrics != nil, WithMetrics(opts.Metrics))
+	add(opts.ErrorHandler != nil, WithErrorHandler(opts.ErrorHandler))
+	add(opts.Backend != nil, WithBackend(opts.Backend))
+	add(opts.Loader != nil, WithLoader(opts.Loader))
+	add(opts.Writer != nil, WithWriter(opts.Writer))
+	add(opts.Codec != nil, WithCodec(opts.Codec))
+	add(opts.Sizer != nil, WithSizer(opts.Sizer))
+	if opts.NameRules != nil {
+		set = append(set, WithNameRules(*opts.NameRules))
+	}
+
+	return func(o *options) {
+		for _, opt := range set {
+			opt(o)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package datapool
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingMetrics struct {
+	mu          sync.Mutex
+	gets        map[string]int
+	hits        int
+	fresh       int
+	puts        map[string]int
+	evictions   []string
+	corruptions int
+}
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{gets: map[string]int{}, puts: map[string]int{}}
+}
+
+func (m *recordingMetrics) RecordGet(bucket string, hit, fresh bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gets[bucket]++
+	if hit {
+		m.hits++
+	}
+	if fresh {
+		m.fresh++
+	}
+}
+
+func (m *recordingMetrics) RecordPut(bucket string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.puts[bucket]++
+}
+
+func (m *recordingMetrics) RecordEviction(bucket string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.evictions = append(m.evictions, bucket)
+}
+
+func (m *recordingMetrics) RecordCorruption() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.corruptions++
+}
+
+func TestDefaultOptions(t *testing.T) {
+	pool := NewDataPool()
+
+	assert.Equal(t, ReportViolations, pool.opts.panicPolicy)
+	assert.Equal(t, SystemClock{}, pool.opts.clock)
+	assert.Equal(t, time.Duration(0), pool.opts.defaultTTL)
+	assert.Equal(t, 0, pool.opts.maxBuckets)
+	assert.Nil(t, pool.opts.metrics)
+	assert.False(t, pool.trackAccess, "Access tracking is off unless a feature needs it")
+}
+
+func TestLaterOptionsOverride(t *testing.T) {
+	pool := NewDataPool(WithMaxBuckets(5), WithShards(4), WithMaxBuckets(10), WithClock(nil))
+
+	assert.Equal(t, 10, pool.opts.maxBuckets)
+	assert.Len(t, pool.shards, 4)
+	assert.Equal(t, SystemClock{}, pool.opts.clock, "A nil clock keeps the default")
+}
+
+func TestWithOptions(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithOptions(Options{
+		Shards:     4,
+		DefaultTTL: time.Minute,
+		MaxBuckets: 5,
+		Eviction:   LFU,
+		Clock:      clock,
+		NameRules:  &NameRules{MaxLength: 8},
+	}), WithMaxBuckets(10))
+
+	assert.Len(t, pool.shards, 4)
+	assert.Equal(t, time.Minute, pool.opts.defaultTTL)
+	assert.Equal(t, 10, pool.opts.maxBuckets, "Later options override the struct's fields")
+	assert.Equal(t, LFU, pool.opts.eviction)
+	assert.Equal(t, clock, pool.opts.clock)
+	assert.Error(t, pool.ValidateName("much-too-long"))
+
+	defaults := NewDataPool(WithOptions(Options{}))
+	assert.Equal(t, NewDataPool().opts.shards, defaults.opts.shards, "Zero fields keep the defaults")
+	assert.Equal(t, DefaultClaimTTL, defaults.opts.claimTTL)
+	assert.Nil(t, defaults.opts.nameRules)
+}
+
+func TestWithDefaultTTL(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock), WithDefaultTTL(time.Minute))
+
+	bucket := pool.Bucket("cache")
+	assert.Equal(t, time.Minute, bucket.TTL())
+	bucket.Put("v")
+
+	custom := pool.Bucket("custom")
+	custom.SetTTL(time.Hour)
+	custom.Put("v")
+
+	clock.Advance(2 * time.Minute)
+	val, _, _ := bucket.Get(0)
+	assert.Nil(t, val, "Default TTL applies to new buckets")
+	val, _, _ = custom.Get(0)
+	assert.Equal(t, "v", val, "SetTTL overrides the default")
+}
+
+func TestWithPutCallback(t *testing.T) {
+	type put struct {
+		name  string
+		value any
+		ts    int64
+	}
+	var puts []put
+	pool := NewDataPool(WithPutCallback(func(name string, value any, ts int64) {
+		puts = append(puts, put{name, value, ts})
+	}))
+
+	bucket := pool.Bucket("config")
+	ts := bucket.Put("v1")
+	invalid := Bucket{}
+	invalid.Put("ignored")
+
+	require.Len(t, puts, 1)
+	assert.Equal(t, put{"config", "v1", ts}, puts[0])
+}
+
+func TestWithMetrics(t *testing.T) {
+	metrics := newRecordingMetrics()
+	pool := NewDataPool(WithMetrics(metrics), WithMaxBuckets(1))
+
+	bucket := pool.Bucket("a")
+	bucket.Get(0)
+	ts := bucket.Put(1)
+	bucket.Get(0)
+	bucket.Get(ts)
+	pool.Bucket("b")
+	broken := Bucket{pool: pool}
+	broken.Get(0)
+
+	assert.Equal(t, 3, metrics.gets["a"])
+	assert.Equal(t, 2, metrics.hits)
+	assert.Equal(t, 1, metrics.fresh)
+	assert.Equal(t, 1, metrics.puts["a"])
+	assert.Equal(t, []string{"a"}, metrics.evictions)
+	assert.Equal(t, 1, metrics.corruptions)
+}
@@ -0,0 +1,65 @@
+package datapool
+
+// Pin exempts the bucket from cleanup, for values the pool must not lose
+// such as authentication keys or core configuration: it is never evicted,
+// whether to respect WithMaxBuckets, the memory budget or memory pressure,
+// and its values do not expire, so they are still read after their TTL and
+// left alone by Expire. A pool may exceed its limits when only pinned buckets
+// are left to evict. Removing the bucket, as Clear does, still works, and
+// system buckets cannot be pinned.
+func (b *Bucket) Pin() {
+	bk := b.resolve("pin")
+	if bk == nil {
+		return
+	}
+
+	bk.guard.Lock()
+	defer bk.guard.Unlock()
+
+	if !bk.removed && !bk.system && bk.pinned.CompareAndSwap(false, true) {
+		b.pool.pinned.Add(1)
+		bk.forgetRead()
+		bk.reschedule()
+	}
+}
+
+// Unpin undoes Pin. A value whose expiration time passed while the bucket was
+// pinned expires at once.
+func (b *Bucket) Unpin() {
+	bk := b.resolve("unpin")
+	if bk == nil {
+		return
+	}
+
+	bk.guard.Lock()
+	defer bk.guard.Unlock()
+
+	if bk.unpin() {
+		b.pool.pinned.Add(-1)
+	}
+}
+
+// Pinned reports whether the bucket is pinned (see Pin).
+func (b *Bucket) Pinned() bool {
+	bk := b.resolve("pinned")
+	if bk == nil {
+		return false
+	}
+	return bk.pinned.Load()
+}
+
+// unpin clears the bucket's pin and reports whether it was pinned. It must be
+// called with b.guard held for writing.
+func (b *bucket) unpin() bool {
+	if !b.pinned.CompareAndSwap(true, false) {
+		return false
+	}
+	b.forgetRead()
+	b.reschedule()
+	return true
+}
+
+// PinnedBuckets returns how many of the pool's buckets are pinned.
+func (p *DataPool) PinnedBuckets() int {
+	return int(p.pinned.Load())
+}
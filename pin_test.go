@@ -0,0 +1,110 @@
+package datapool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPin(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("auth/keys")
+	assert.False(t, b.Pinned())
+
+	b.Pin()
+	b.Pin()
+	assert.True(t, b.Pinned())
+	assert.True(t, b.Stats().Pinned)
+	assert.Equal(t, 1, pool.PinnedBuckets(), "Pinning twice counts once")
+
+	value, _, _ := pool.Handle(SystemStatsBucket).Get(0)
+	assert.Equal(t, 1, value.(PoolStats).PinnedBuckets)
+
+	b.Unpin()
+	b.Unpin()
+	assert.False(t, b.Pinned())
+	assert.Zero(t, pool.PinnedBuckets())
+
+	b.Pin()
+	pool.Clear()
+	assert.Zero(t, pool.PinnedBuckets(), "Removed buckets are no longer pinned")
+	b.Pin()
+	assert.Zero(t, pool.PinnedBuckets())
+
+	system := pool.Bucket(SystemStatsBucket)
+	system.Pin()
+	assert.False(t, system.Pinned())
+}
+
+func TestPinExemptsFromExpiration(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	var expired []string
+	pool := NewDataPool(WithClock(clock))
+	pool.OnExpire(func(name string, _ any) { expired = append(expired, name) })
+	b := pool.Bucket("config")
+	b.SetTTL(time.Minute)
+	b.Put("v")
+	b.Pin()
+
+	clock.Advance(time.Hour)
+	value, _, _ := b.Get(0)
+	assert.Equal(t, "v", value, "Pinned values do not expire")
+	assert.Zero(t, pool.Expire())
+	assert.Empty(t, expired)
+
+	b.Unpin()
+	value, _, _ = b.Get(0)
+	assert.Nil(t, value, "Unpinned values expire as due")
+	assert.Equal(t, 1, pool.Expire())
+	assert.Equal(t, []string{"config"}, expired)
+}
+
+func TestPinExemptsFromEviction(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock), WithMaxBuckets(2))
+	a := pool.Bucket("a")
+	a.Put(1)
+	a.Pin()
+	clock.Advance(time.Second)
+	pool.Handle("b").Put(2)
+	clock.Advance(time.Second)
+
+	pool.Bucket("c")
+	assert.NotNil(t, pool.find("a"), "The least recently used bucket is pinned")
+	assert.Nil(t, pool.find("b"))
+
+	c := pool.Bucket("c")
+	c.Pin()
+	pool.Bucket("d")
+	assert.Equal(t, 3, pool.Len(), "Only pinned buckets were left to evict")
+}
+
+func TestPinExemptsFromMemoryLimits(t *testing.T) {
+	pool := NewDataPool(WithMemoryBudget(8), WithSizer(lenSizer))
+	keys := pool.Bucket("keys")
+	keys.SetPriority(PriorityLow)
+	keys.Put("1234")
+	keys.Pin()
+	cheap := pool.Bucket("cheap")
+	cheap.Put("1234")
+
+	pool.Handle("new").Put("1234")
+	value, _, _ := keys.Get(0)
+	assert.Equal(t, "1234", value)
+	value, _, _ = cheap.Get(0)
+	assert.Nil(t, value)
+
+	pressured := NewDataPool(WithMemoryPressure(PressureConfig{
+		Pressure: func() float64 { return 0 },
+		Fraction: 1,
+	}))
+	pinned := pressured.Bucket("pinned")
+	pinned.Put("p")
+	pinned.Pin()
+	pressured.Handle("other").Put("o")
+	require.Equal(t, 1, pressured.RelieveMemoryPressure())
+	value, _, _ = pinned.Get(0)
+	assert.Equal(t, "p", value)
+}
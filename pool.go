@@ -0,0 +1,102 @@
+package datapool
+
+import "context"
+
+// Pool is implemented by everything that serves buckets by name: the
+// in-process DataPool, views of it such as ReplicaView, and remote pools
+// (see the datapoolclient package). Code written against Pool and Handle
+// works with any of them.
+type Pool interface {
+	// Handle returns a handle to the named bucket.
+	Handle(name string) Handle
+}
+
+// Handle is the method set of a bucket shared by all Pool implementations.
+type Handle interface {
+	// Name returns the bucket's name.
+	Name() string
+
+	// Get returns the value of the bucket, its timestamp, and whether it is
+	// newer than timestamp.
+	Get(timestamp int64) (any, int64, bool)
+
+	// Put stores value in the bucket and returns its timestamp, or 0 if the
+	// value was not stored.
+	Put(value any) int64
+
+	// Watch returns a channel receiving an Update for every subsequent Put,
+	// closed when ctx is done.
+	Watch(ctx context.Context) <-chan Update
+}
+
+var (
+	_ Pool   = (*DataPool)(nil)
+	_ Pool   = (*ReplicaView)(nil)
+	_ Handle = (*Bucket)(nil)
+)
+
+// Handle returns a handle to the named bucket, creating it if needed, like
+// Bucket.
+func (p *DataPool) Handle(name string) Handle {
+	b := p.Bucket(name)
+	return &b
+}
+
+// Name returns the bucket's name, which is its new name after a Rename.
+func (b *Bucket) Name() string {
+	if b.b == nil {
+		return ""
+	}
+	return b.b.name()
+}
+
+// Valid reports whether the handle refers to a bucket of a pool that was not
+// removed since, by Clear, eviction or TimeBucket.Expire; values expiring
+// leave their bucket in place. A handle stays valid across renames. Once invalid, a handle stays so: creating a bucket
+// under the same name makes a new bucket, which the handle does not refer
+// to, so writes through it are never stored, and Put and the other writes
+// report an error wrapping ErrRemoved to the pool's error handler; call
+// Bucket again for a handle to the new bucket.
+func (b *Bucket) Valid() bool {
+	return b.Err() == nil
+}
+
+// Err returns nil if the handle is valid (see Valid), and otherwise the error
+// its operations fail with: one wrapping ErrBucketNotFound, and ErrRemoved if
+// the bucket was removed.
+func (b *Bucket) Err() error {
+	bk, err := b.lookup("bucket")
+	if err != nil {
+		return err
+	}
+	return bk.checkRemoved()
+}
+
+// Handle returns a handle whose Get reads through the view. Put and Watch go
+// to the underlying pool.
+func (v *ReplicaView) Handle(name string) Handle {
+	return &replicaHandle{view: v, name: name}
+}
+
+type replicaHandle struct {
+	view *ReplicaView
+	name string
+}
+
+func (h *replicaHandle) Name() string {
+	return h.name
+}
+
+func (h *replicaHandle) Get(timestamp int64) (any, int64, bool) {
+	return h.view.Get(h.name, timestamp)
+}
+
+func (h *replicaHandle) Put(value any) int64 {
+	b := h.view.pool.Bucket(h.name)
+	return b.Put(value)
+}
+
+func (h *replicaHandle) Watch(ctx context.Context) <-chan Update {
+	b := h.view.pool.Bucket(h.name)
+	return b.Watch(ctx)
+}
@@ -0,0 +1,107 @@
+package datapool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exercisePool runs the same checks against any Pool implementation.
+func exercisePool(t *testing.T, pool Pool) {
+	t.Helper()
+
+	h := pool.Handle("config")
+	assert.Equal(t, "config", h.Name())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := h.Watch(ctx)
+
+	ts := h.Put("v1")
+	require.NotZero(t, ts)
+
+	u := receive(t, updates)
+	assert.Equal(t, Update{Bucket: "config", Value: "v1", Timestamp: ts, Version: 1}, u)
+
+	val, got, fresh := pool.Handle("config").Get(ts - 1)
+	assert.Equal(t, "v1", val)
+	assert.Equal(t, ts, got)
+	assert.True(t, fresh)
+}
+
+func TestDataPoolAsPool(t *testing.T) {
+	exercisePool(t, NewDataPool())
+}
+
+func TestReplicaViewAsPool(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	view := pool.ReplicaView(time.Second)
+
+	// Without a copy the view reads through to the pool.
+	exercisePool(t, view)
+
+	view.Refresh()
+	h := view.Handle("config")
+	h.Put("v2")
+	val, _, _ := h.Get(0)
+	assert.Equal(t, "v1", val, "Reads are served by the copy while it is current")
+}
+
+func TestBucketName(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("a/b")
+	assert.Equal(t, "a/b", b.Name())
+
+	var zero Bucket
+	assert.Empty(t, zero.Name())
+}
+
+func TestBucketValid(t *testing.T) {
+	var reported []error
+	pool := NewDataPool(WithErrorHandler(func(_ string, err error) { reported = append(reported, err) }))
+	config := pool.Bucket("config")
+	config.Put(1)
+	assert.True(t, config.Valid())
+	assert.NoError(t, config.Err())
+
+	require.NoError(t, pool.Rename("config", "settings"))
+	assert.True(t, config.Valid(), "Handles stay valid across renames")
+
+	pool.Clear()
+	assert.False(t, config.Valid())
+	assert.ErrorIs(t, config.Err(), ErrRemoved)
+
+	recreated := pool.Bucket("settings")
+	recreated.Put(2)
+	assert.Zero(t, config.Put(3), "Writes through an invalid handle are not stored")
+	require.Len(t, reported, 1)
+	assert.ErrorIs(t, reported[0], ErrRemoved)
+	assert.ErrorIs(t, reported[0], ErrBucketNotFound)
+	config.PutBytes([]byte("x"))
+	config.Update(func(any) any { return 4 })
+	assert.Len(t, reported, 3, "Every write reports the error")
+
+	value, _, _ := recreated.Get(0)
+	assert.Equal(t, 2, value, "The bucket recreated under the name is left alone")
+	value, _, _ = config.Get(0)
+	assert.Nil(t, value)
+
+	var zero Bucket
+	assert.False(t, zero.Valid())
+	assert.ErrorIs(t, zero.Err(), ErrBucketNotFound)
+	assert.NotErrorIs(t, zero.Err(), ErrRemoved)
+}
+
+func TestBucketValidEviction(t *testing.T) {
+	pool := NewDataPool(WithMaxBuckets(1))
+	a := pool.Bucket("a")
+	a.Put(1)
+	pool.Bucket("b")
+	assert.False(t, a.Valid(), "Evicted buckets invalidate their handles")
+	_, err := a.PutE(2)
+	assert.ErrorIs(t, err, ErrRemoved)
+}
@@ -0,0 +1,121 @@
+package datapool
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// WatchPrefix returns a channel receiving an Update for every subsequent Put
+// to a bucket whose name starts with prefix, including buckets created after
+// the call. Updates of each bucket arrive in timestamp order. The channel is
+// closed when ctx is done. A receiver that falls behind by more than the
+// watch buffer (see WithWatchBuffer) loses the oldest pending updates,
+// whichever bucket they belong to.
+func (p *DataPool) WatchPrefix(ctx context.Context, prefix string) <-chan Update {
+	return p.watchNames(ctx, func(name string) bool {
+		return strings.HasPrefix(name, prefix)
+	})
+}
+
+// WatchMatch is WatchPrefix for the buckets whose name matches pattern, in
+// the syntax of path.Match: "sensors/*/temp" matches "sensors/a/temp" but not
+// "sensors/a/b/temp". It fails if pattern is malformed.
+func (p *DataPool) WatchMatch(ctx context.Context, pattern string) (<-chan Update, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("datapool: watch %q: %w", pattern, err)
+	}
+	return p.watchNames(ctx, func(name string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}), nil
+}
+
+func (p *DataPool) watchNames(ctx context.Context, match func(name string) bool) <-chan Update {
+	w := &nameWatcher{
+		match: match,
+		ch:    make(chan Update, p.opts.watchBuffer),
+		last:  make(map[string]int64),
+	}
+	p.closeMu.RLock()
+	if p.isClosed() {
+		p.closeMu.RUnlock()
+		w.close()
+		return w.ch
+	}
+	remove := p.nameWatchers.add(w)
+	p.closeMu.RUnlock()
+	context.AfterFunc(ctx, func() {
+		remove()
+		w.close()
+	})
+	return w.ch
+}
+
+// nameWatcher is a watcher of the buckets whose names match. Unlike a
+// watcher, it orders updates per bucket.
+type nameWatcher struct {
+	match func(name string) bool
+
+	mu     sync.Mutex
+	ch     chan Update
+	last   map[string]int64
+	closed bool
+}
+
+// deliver queues u unless the watcher is closed or already delivered a newer
+// update of the same bucket. It reports whether an older pending update was
+// dropped for room.
+func (w *nameWatcher) deliver(u Update) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed || u.Timestamp <= w.last[u.Bucket] {
+		return false
+	}
+	w.last[u.Bucket] = u.Timestamp
+
+	select {
+	case w.ch <- u:
+		return false
+	default:
+	}
+
+	// As for watchers, only deliverers send, under w.mu.
+	select {
+	case <-w.ch:
+	default:
+	}
+	w.ch <- u
+	return true
+}
+
+func (w *nameWatcher) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.closed {
+		w.closed = true
+		close(w.ch)
+	}
+}
+
+// deliverMatching sends an update of b to the name watchers matching it.
+func (p *DataPool) deliverMatching(b *bucket, u Update) {
+	copies := b.copyValues.Load()
+	value := u.Value
+	for _, h := range p.nameWatchers.snapshot() {
+		w := h.fn
+		if !w.match(b.name()) {
+			continue
+		}
+		if copies {
+			u.Value = copyValue(value)
+		}
+		if w.deliver(u) {
+			p.watchOverflow(b)
+		}
+	}
+}
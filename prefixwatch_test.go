@@ -0,0 +1,114 @@
+package datapool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchPrefix(t *testing.T) {
+	pool := NewDataPool()
+	existing := pool.Bucket("sensors/a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := pool.WatchPrefix(ctx, "sensors/")
+
+	ts := existing.Put(1)
+	other := pool.Bucket("config")
+	other.Put("x")
+	created := pool.Bucket("sensors/b")
+	created.Put(2)
+	pool.PutMany(map[string]any{"sensors/c": 3})
+
+	u := receive(t, updates)
+	assert.Equal(t, Update{Bucket: "sensors/a", Value: 1, Timestamp: ts, Version: 1}, u)
+	assert.Equal(t, "sensors/b", receive(t, updates).Bucket, "Buckets created after the watch are included")
+	assert.Equal(t, "sensors/c", receive(t, updates).Bucket)
+
+	cancel()
+	assertClosed(t, updates)
+	existing.Put(4) // must not send on the closed channel
+}
+
+func TestWatchMatch(t *testing.T) {
+	pool := NewDataPool()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates, err := pool.WatchMatch(ctx, "sensors/*/temp")
+	require.NoError(t, err)
+
+	deep := pool.Bucket("sensors/a/b/temp")
+	deep.Put(0)
+	humidity := pool.Bucket("sensors/a/humidity")
+	humidity.Put(0)
+	temp := pool.Bucket("sensors/a/temp")
+	temp.Put(21.5)
+
+	u := receive(t, updates)
+	assert.Equal(t, "sensors/a/temp", u.Bucket)
+	assert.Equal(t, 21.5, u.Value)
+
+	_, err = pool.WatchMatch(ctx, "sensors/[")
+	assert.ErrorContains(t, err, `datapool: watch "sensors/[": syntax error in pattern`)
+}
+
+func TestWatchPrefixOrdersPerBucket(t *testing.T) {
+	pool := NewDataPool(WithWatchBuffer(4))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := pool.WatchPrefix(ctx, "")
+
+	a := pool.Bucket("a")
+	b := pool.Bucket("b")
+	newer := a.Put(2)
+	// An older update of another bucket still arrives; one of the same
+	// bucket does not.
+	b.b.guard.Lock()
+	watchers := b.b.watchers
+	b.b.guard.Unlock()
+	pool.deliverUpdate(b.b, watchers, Update{Bucket: "b", Value: 1, Timestamp: newer - 1})
+	pool.deliverUpdate(a.b, nil, Update{Bucket: "a", Value: 1, Timestamp: newer - 1})
+
+	assert.Equal(t, Update{Bucket: "a", Value: 2, Timestamp: newer, Version: 1}, receive(t, updates))
+	assert.Equal(t, "b", receive(t, updates).Bucket)
+	select {
+	case u := <-updates:
+		assert.Fail(t, "Unexpected update", "%v", u)
+	default:
+	}
+}
+
+func TestWatchPrefixOverflow(t *testing.T) {
+	pool := NewDataPool(WithWatchBuffer(2))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := pool.WatchPrefix(ctx, "")
+
+	for _, name := range []string{"a", "b", "c"} {
+		b := pool.Bucket(name)
+		b.Put(name)
+	}
+	assert.Equal(t, "b", receive(t, updates).Bucket, "The oldest pending update is dropped")
+	assert.Equal(t, "c", receive(t, updates).Bucket)
+	assert.Equal(t, uint64(1), pool.WatchOverflows())
+}
+
+func TestWatchPrefixCopiesValues(t *testing.T) {
+	pool := NewDataPool()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := pool.WatchPrefix(ctx, "m")
+
+	m := pool.Bucket("m")
+	m.SetCopyValues(true)
+	stored := map[string]int{"n": 1}
+	m.Put(stored)
+
+	u := receive(t, updates)
+	u.Value.(map[string]int)["n"] = 2
+	val, _, _ := m.Get(0)
+	assert.Equal(t, map[string]int{"n": 1}, val)
+}
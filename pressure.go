@@ -0,0 +1,177 @@
+package datapool
+
+import (
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"sort"
+	"time"
+)
+
+// Priority ranks buckets for eviction under memory pressure. Buckets with a
+// lower priority are evicted first; within the same priority the least
+// recently accessed bucket goes first.
+type Priority int
+
+const (
+	// PriorityLow marks values that are cheap to reload.
+	PriorityLow Priority = -1
+	// PriorityNormal is the priority of every new bucket.
+	PriorityNormal Priority = 0
+	// PriorityHigh marks values that should be kept as long as possible.
+	PriorityHigh Priority = 1
+)
+
+// PressureFunc reports the current memory pressure as the fraction of the
+// available memory that is in use; 1 means the limit has been reached.
+type PressureFunc func() float64
+
+// PressureConfig configures proactive eviction under memory pressure.
+type PressureConfig struct {
+	// Pressure reports the current memory pressure. RuntimeMemoryPressure is
+	// used when nil.
+	Pressure PressureFunc
+
+	// Threshold is the pressure above which buckets are evicted. Defaults to 0.9.
+	Threshold float64
+
+	// Fraction is the share of the non-empty buckets evicted each time the
+	// threshold is exceeded. Defaults to 0.1; at least one bucket is evicted.
+	Fraction float64
+
+	// Interval is the minimum time between two pressure checks. Checks happen
+	// lazily on Put, so no background goroutine is involved. Defaults to one second.
+	Interval time.Duration
+}
+
+// RuntimeMemoryPressure returns a PressureFunc that compares the memory
+// mapped by the Go runtime against the soft limit set with
+// debug.SetMemoryLimit (or GOMEMLIMIT). Without a limit it reports no pressure.
+func RuntimeMemoryPressure() PressureFunc {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+
+	return func() float64 {
+		limit := debug.SetMemoryLimit(-1)
+		if limit <= 0 || limit == math.MaxInt64 {
+			return 0
+		}
+
+		metrics.Read(samples)
+		used := samples[0].Value.Uint64() - samples[1].Value.Uint64()
+		return float64(used) / float64(limit)
+	}
+}
+
+// SetPriority sets the eviction priority of the bucket.
+func (b *Bucket) SetPriority(priority Priority) {
+	if bk := b.resolve("set priority"); bk != nil {
+		bk.guard.Lock()
+		bk.priority = priority
+		bk.guard.Unlock()
+	}
+}
+
+// Priority returns the eviction priority of the bucket.
+func (b *Bucket) Priority() Priority {
+	bk := b.resolve("priority")
+	if bk == nil {
+		return PriorityNormal
+	}
+
+	bk.guard.RLock()
+	defer bk.guard.RUnlock()
+	return bk.priority
+}
+
+// RelieveMemoryPressure evicts the configured fraction of the non-empty
+// buckets, lowest priority and least recently accessed first, regardless of
+// the current pressure. It returns the number of evicted buckets. It is a no-op
+// returning 0 when the pool was created without WithMemoryPressure.
+func (p *DataPool) RelieveMemoryPressure() int {
+	cfg := p.opts.pressure
+	if cfg == nil {
+		return 0
+	}
+
+	type candidate struct {
+		b          *bucket
+		priority   Priority
+		lastAccess int64
+	}
+
+	buckets := p.all()
+	candidates := make([]candidate, 0, len(buckets))
+	for _, b := range buckets {
+		b.guard.RLock()
+		if b.timestamp != 0 && !b.system && !b.pinned.Load() {
+			candidates = append(candidates, candidate{b: b, priority: b.priority, lastAccess: b.lastAccess.Load()})
+		}
+		b.guard.RUnlock()
+	}
+
+	if len(candidates) == 0 {
+		return 0
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].priority != candidates[j].priority {
+			return candidates[i].priority < candidates[j].priority
+		}
+		return candidates[i].lastAccess < candidates[j].lastAccess
+	})
+
+	n := int(math.Ceil(cfg.Fraction * float64(len(candidates))))
+	evicted := 0
+	for _, c := range candidates[:n] {
+		c.b.guard.Lock()
+		// Skip buckets that were written, read or pinned since they were
+		// ranked.
+		dropped := c.b.timestamp != 0 && c.b.lastAccess.Load() == c.lastAccess && !c.b.pinned.Load()
+		var value any
+		if dropped {
+			value = c.b.current()
+			c.b.value = nil
+			c.b.cachedErr = nil
+			c.b.releaseShared()
+			c.b.account(nil)
+			c.b.forgetRead()
+			c.b.timestamp = 0
+			c.b.reschedule()
+			c.b.provenance = nil
+			c.b.schema = 0
+			evicted++
+		}
+		c.b.guard.Unlock()
+
+		if dropped {
+			p.fireEvict(c.b.name(), value, false)
+		}
+	}
+	return evicted
+}
+
+// checkMemoryPressure evicts bucket values if the pressure threshold is
+// exceeded, at most once per configured interval, or if the pool exceeds its
+// memory budget. now is the timestamp of the write that triggered the check.
+func (p *DataPool) checkMemoryPressure(now int64) {
+	if p.mem != nil {
+		p.enforceBudget(now)
+	}
+
+	cfg := p.opts.pressure
+	if cfg == nil {
+		return
+	}
+
+	last := p.pressureChecked.Load()
+	if now-last < int64(cfg.Interval) || !p.pressureChecked.CompareAndSwap(last, now) {
+		return
+	}
+
+	if cfg.Pressure() > cfg.Threshold {
+		p.RelieveMemoryPressure()
+	}
+}
@@ -0,0 +1,103 @@
+package datapool
+
+import (
+	"fmt"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelieveMemoryPressureOrder(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock), WithMemoryPressure(PressureConfig{
+		Pressure: func() float64 { return 0 },
+		Fraction: 0.5,
+	}))
+
+	high := pool.Bucket("high")
+	high.SetPriority(PriorityHigh)
+	high.Put("h")
+	cold := pool.Bucket("cold")
+	cold.Put("c")
+	warm := pool.Bucket("warm")
+	warm.Put("w")
+	low := pool.Bucket("low")
+	low.SetPriority(PriorityLow)
+	low.Put("l")
+	pool.Bucket("empty")
+
+	// Touch cold and warm in order so "cold" is the least recently accessed
+	clock.Advance(time.Second)
+	cold.Get(0)
+	clock.Advance(time.Second)
+	warm.Get(0)
+
+	assert.Equal(t, 2, pool.RelieveMemoryPressure(), "Half of the four non-empty buckets")
+
+	val, ts, _ := low.Get(0)
+	assert.Nil(t, val, "Low priority bucket is evicted first")
+	assert.Equal(t, int64(0), ts)
+	val, _, _ = cold.Get(0)
+	assert.Nil(t, val, "Least recently accessed bucket is evicted next")
+	val, _, _ = warm.Get(0)
+	assert.Equal(t, "w", val)
+	val, _, _ = high.Get(0)
+	assert.Equal(t, "h", val)
+
+	assert.Equal(t, PriorityLow, low.Priority(), "Eviction keeps the bucket's priority")
+}
+
+func TestMemoryPressureCheckedOnPut(t *testing.T) {
+	var pressure float64
+	calls := 0
+	pool := NewDataPool(WithMemoryPressure(PressureConfig{
+		Pressure: func() float64 {
+			calls++
+			return pressure
+		},
+		Threshold: 0.8,
+		Interval:  time.Hour,
+	}))
+
+	// Fill the pool while there is no pressure; only the first Put checks.
+	for i := 0; i < 10; i++ {
+		b := pool.Bucket(fmt.Sprintf("bucket-%d", i))
+		b.Put(i)
+	}
+	assert.Equal(t, 1, calls, "Pressure is checked at most once per interval")
+
+	pressure = 0.95
+	pool.pressureChecked.Store(0)
+	trigger := pool.Bucket("bucket-9")
+	trigger.Put(9)
+	assert.Equal(t, 2, calls)
+
+	empty := 0
+	for _, info := range pool.Inspect() {
+		if info.Timestamp == 0 {
+			empty++
+		}
+	}
+	assert.Equal(t, 1, empty, "Default fraction evicts 10% (at least one) of the buckets")
+}
+
+func TestRelieveMemoryPressureDisabled(t *testing.T) {
+	pool := NewDataPool()
+	bucket := pool.Bucket("test")
+	bucket.Put(1)
+
+	assert.Equal(t, 0, pool.RelieveMemoryPressure())
+	val, _, _ := bucket.Get(0)
+	assert.Equal(t, 1, val)
+}
+
+func TestRuntimeMemoryPressure(t *testing.T) {
+	pressure := RuntimeMemoryPressure()
+	assert.GreaterOrEqual(t, pressure(), 0.0)
+
+	old := debug.SetMemoryLimit(1 << 62)
+	defer debug.SetMemoryLimit(old)
+	assert.Less(t, pressure(), 0.01, "A huge limit leaves plenty of headroom")
+}
@@ -0,0 +1,132 @@
+package datapool
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// maxProvenance bounds the provenance chain kept per value; older steps are
+// dropped first.
+const maxProvenance = 8
+
+// SourceKind is the kind of a step in a value's provenance.
+type SourceKind int
+
+const (
+	// SourcePut is a value stored directly with Put.
+	SourcePut SourceKind = iota
+	// SourceLoader is a value produced by a loader, such as a refresh
+	// scheduled with ScheduleRefresh.
+	SourceLoader
+	// SourceTransform is a value derived from another one.
+	SourceTransform
+	// SourceReplica is a value replicated from another pool through a
+	// backend; the source's Name is that pool's peer name.
+	SourceReplica
+)
+
+var sourceKindNames = [...]string{
+	SourcePut:       "put",
+	SourceLoader:    "loader",
+	SourceTransform: "transform",
+	SourceReplica:   "replica",
+}
+
+// String returns the name of the kind.
+func (k SourceKind) String() string {
+	if k >= 0 && int(k) < len(sourceKindNames) {
+		return sourceKindNames[k]
+	}
+	return fmt.Sprintf("SourceKind(%d)", int(k))
+}
+
+// MarshalText encodes the kind as its name.
+func (k SourceKind) MarshalText() ([]byte, error) {
+	if k < 0 || int(k) >= len(sourceKindNames) {
+		return nil, fmt.Errorf("datapool: unknown source kind %d", int(k))
+	}
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText decodes a kind from its name.
+func (k *SourceKind) UnmarshalText(text []byte) error {
+	for i, name := range sourceKindNames {
+		if name == string(text) {
+			*k = SourceKind(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("datapool: unknown source kind %q", text)
+}
+
+// Source is one step in the provenance of a value: where it came from or
+// what was done to it on the way into the bucket.
+type Source struct {
+	Kind SourceKind `json:"kind"`
+	// Name identifies the loader, transformer or peer pool of the step.
+	Name string    `json:"name,omitempty"`
+	At   time.Time `json:"at"`
+}
+
+// Provenance returns the chain of steps that produced the bucket's current
+// value, oldest first, for example a loader, then a transform, then
+// replication from a peer. A value stored with Put has a single SourcePut
+// step; an empty bucket has none.
+func (b *Bucket) Provenance() []Source {
+	bk := b.resolve("provenance")
+	if bk == nil {
+		return nil
+	}
+
+	bk.guard.RLock()
+	defer bk.guard.RUnlock()
+
+	ts := bk.readTimestamp(b.pool)
+	if ts == 0 {
+		return nil
+	}
+	return append([]Source(nil), bk.chain()...)
+}
+
+// PutFrom stores value like Put, recording chain as its provenance. Loaders
+// and transformers use it to say where a value came from; a transformer
+// typically passes the provenance of its input followed by its own
+// SourceTransform step. Only the last steps of long chains are kept.
+func (b *Bucket) PutFrom(value any, chain ...Source) int64 {
+	bk := b.resolve("put")
+	if bk == nil {
+		return 0
+	}
+	return b.pool.write(bk, value, 0, capProvenance(chain))
+}
+
+// chain returns the provenance of the bucket's value, which must not be
+// empty. A nil chain stands for a plain Put, so Put does not allocate one. It
+// must be called with b.guard held.
+func (b *bucket) chain() []Source {
+	if b.provenance == nil {
+		return []Source{{Kind: SourcePut, At: time.Unix(0, b.timestamp)}}
+	}
+	return b.provenance
+}
+
+// capProvenance returns a copy of the last maxProvenance steps of chain, or
+// nil for an empty chain.
+func capProvenance(chain []Source) []Source {
+	if len(chain) == 0 {
+		return nil
+	}
+	if len(chain) > maxProvenance {
+		chain = chain[len(chain)-maxProvenance:]
+	}
+	return append([]Source(nil), chain...)
+}
+
+// defaultPeerName names pools that were not given one with WithPeerName.
+func defaultPeerName() string {
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "datapool"
+}
@@ -0,0 +1,108 @@
+package datapool
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvenance(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	b := pool.Bucket("rates")
+	assert.Nil(t, b.Provenance(), "Empty buckets have no provenance")
+
+	ts := b.Put(1.0)
+	assert.Equal(t, []Source{{Kind: SourcePut, At: time.Unix(0, ts)}}, b.Provenance())
+
+	chain := []Source{
+		{Kind: SourceLoader, Name: "rates-api", At: time.Unix(998, 0)},
+		{Kind: SourceTransform, Name: "round", At: time.Unix(999, 0)},
+	}
+	b.PutFrom(1.1, chain...)
+	got := b.Provenance()
+	assert.Equal(t, chain, got)
+
+	// Callers get a copy.
+	got[0].Name = "changed"
+	assert.Equal(t, chain, b.Provenance())
+
+	// A plain Put replaces the chain.
+	b.Put(1.2)
+	assert.Len(t, b.Provenance(), 1)
+	assert.Equal(t, SourcePut, b.Provenance()[0].Kind)
+
+	var zero Bucket
+	assert.Nil(t, zero.Provenance())
+}
+
+func TestProvenanceKeepsNewest(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("derived")
+
+	var chain []Source
+	for i := 0; i < maxProvenance+3; i++ {
+		chain = append(chain, Source{Kind: SourceTransform, At: time.Unix(int64(i), 0)})
+	}
+	b.PutFrom("v", chain...)
+	assert.Equal(t, chain[3:], b.Provenance())
+}
+
+func TestProvenanceCleared(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	b := pool.Bucket("session")
+	b.SetTTL(time.Minute)
+	b.PutFrom("v", Source{Kind: SourceLoader, At: clock.Now()})
+
+	clock.Advance(2 * time.Minute)
+	assert.Equal(t, 1, pool.Expire())
+	assert.Nil(t, b.Provenance())
+}
+
+func TestScheduleRefreshProvenance(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+
+	err := <-pool.ScheduleRefresh("rates", func() (any, error) { return 1.08, nil })
+	require.NoError(t, err)
+
+	b := pool.Bucket("rates")
+	assert.Equal(t, []Source{{Kind: SourceLoader, At: time.Unix(1000, 0)}}, b.Provenance())
+}
+
+func TestWatchProvenance(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("rates")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := b.Watch(ctx)
+
+	b.Put(1)
+	assert.Nil(t, receive(t, updates).Provenance)
+
+	src := Source{Kind: SourceLoader, Name: "rates-api", At: time.Unix(1, 0)}
+	b.PutFrom(2, src)
+	assert.Equal(t, []Source{src}, receive(t, updates).Provenance)
+}
+
+func TestSourceJSON(t *testing.T) {
+	src := Source{Kind: SourceReplica, Name: "host-a", At: time.Unix(1700000000, 0).UTC()}
+	data, err := json.Marshal(src)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"kind":"replica","name":"host-a","at":"2023-11-14T22:13:20Z"}`, string(data))
+
+	var got Source
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, src, got)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"kind":"magic"}`), &got))
+	_, err = json.Marshal(Source{Kind: SourceKind(42)})
+	assert.Error(t, err)
+	assert.Equal(t, "SourceKind(42)", SourceKind(42).String())
+}
@@ -0,0 +1,177 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// publishMu serializes PublishSwap calls, which lock the buckets of two
+// pools, so that swaps of two pools in opposite directions cannot deadlock.
+var publishMu sync.Mutex
+
+// PublishSwap atomically exchanges the values of the pool with those of
+// staging, for blue/green refreshes: a dataset prepared in a staging pool is
+// published at once, so readers never see it partially refreshed, and the
+// previous dataset is left in staging, to roll back with another PublishSwap
+// or to refill. Every bucket outside the SystemNamespace of either pool ends
+// up with the value the other pool's bucket of that name had, stored under
+// one new timestamp per pool as with PutMany, or empty if that bucket had
+// none. Only values move: buckets keep their handles and their settings, such
+// as TTLs and transformers, which apply to the values they receive. Watchers,
+// callbacks and backends of each pool see the values it receives as Puts.
+//
+// Nothing is swapped if a name is rejected by the other pool's NameRules, a
+// bucket is claimed (see Bucket.Claim) or a bucket's validator rejects the
+// value it would receive; the error says which.
+func (p *DataPool) PublishSwap(staging *DataPool) error {
+	if staging == p {
+		return errors.New("datapool: publish swap: staging is the pool itself")
+	}
+
+	publishMu.Lock()
+	defer publishMu.Unlock()
+
+	for {
+		live, stage, err := pairBuckets(p, staging)
+		if err != nil {
+			return err
+		}
+		if done, err := p.swapBuckets(staging, live, stage); done || err != nil {
+			return err
+		}
+		// A bucket was removed while pairing; pair them again.
+	}
+}
+
+// pairBuckets returns the buckets of a and b, outside the SystemNamespace,
+// paired by name: a's i-th bucket has the name of b's, creating the buckets
+// missing from either. It fails if a name of one pool is an alias (see Alias)
+// of a bucket paired under another name in the other.
+func pairBuckets(a, b *DataPool) ([]*bucket, []*bucket, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, pool := range []*DataPool{a, b} {
+		for _, bk := range pool.all() {
+			if !bk.system && !seen[bk.name()] {
+				seen[bk.name()] = true
+				names = append(names, bk.name())
+			}
+		}
+	}
+
+	left := make([]*bucket, len(names))
+	right := make([]*bucket, len(names))
+	paired := make(map[*bucket]bool, 2*len(names))
+	for i, name := range names {
+		var err error
+		if left[i], err = a.bucket(name); err != nil {
+			return nil, nil, fmt.Errorf("datapool: publish swap: %w", err)
+		}
+		if right[i], err = b.bucket(name); err != nil {
+			return nil, nil, fmt.Errorf("datapool: publish swap: %w", err)
+		}
+		if paired[left[i]] || paired[right[i]] {
+			return nil, nil, fmt.Errorf("datapool: publish swap: %q is an alias of another bucket", name)
+		}
+		paired[left[i]], paired[right[i]] = true, true
+	}
+	return left, right, nil
+}
+
+// swapBuckets exchanges the values of the paired buckets live, of p, and
+// stage, of staging. It reports false, swapping nothing, if one of them was
+// removed.
+func (p *DataPool) swapBuckets(staging *DataPool, live, stage []*bucket) (bool, error) {
+	locked := append(lockOrder(slices.Clone(live)), lockOrder(slices.Clone(stage))...)
+	endLive, endStage := p.beginCommit(), staging.beginCommit()
+	for _, b := range locked {
+		b.guard.Lock()
+	}
+	unlock := func() {
+		for _, b := range locked {
+			b.unreserve()
+			b.guard.Unlock()
+		}
+		endLive()
+		endStage()
+	}
+
+	toLive := make([]any, len(live))
+	toStage := make([]any, len(stage))
+	hasLive := make([]bool, len(live))
+	hasStage := make([]bool, len(stage))
+	liveNow, stageNow := p.now(), staging.now()
+	for i := range live {
+		l, s := live[i], stage[i]
+		if l.removed || s.removed {
+			unlock()
+			return false, nil
+		}
+		if err := l.checkClaim(liveNow, 0); err != nil {
+			unlock()
+			return false, fmt.Errorf("datapool: publish swap: %w", err)
+		}
+		if err := s.checkClaim(stageNow, 0); err != nil {
+			unlock()
+			return false, fmt.Errorf("datapool: publish swap: staging: %w", err)
+		}
+		lv, lts := l.readValue(p)
+		sv, sts := s.readValue(staging)
+		var err error
+		if hasLive[i] = sts != 0; hasLive[i] {
+			toLive[i], err = l.prepare(sv)
+		}
+		if hasStage[i] = lts != 0; hasStage[i] && err == nil {
+			toStage[i], err = s.prepare(lv)
+		}
+		if err != nil {
+			unlock()
+			return false, fmt.Errorf("datapool: publish swap: %w", err)
+		}
+	}
+
+	liveTs, stageTs := p.stamp(), staging.stamp()
+	liveUpdates := swapInto(live, toLive, hasLive, liveTs)
+	stageUpdates := swapInto(stage, toStage, hasStage, stageTs)
+	unlock()
+
+	for _, u := range liveUpdates {
+		p.notifyPut(context.Background(), u.b, u.watchers, u.Update, ConsistencyDefault)
+	}
+	for _, u := range stageUpdates {
+		staging.notifyPut(context.Background(), u.b, u.watchers, u.Update, ConsistencyDefault)
+	}
+	p.checkMemoryPressure(liveTs)
+	staging.checkMemoryPressure(stageTs)
+	return true, nil
+}
+
+// swappedUpdate is a value stored by PublishSwap, to notify once the buckets
+// are unlocked.
+type swappedUpdate struct {
+	Update
+	b        *bucket
+	watchers []*watcher
+}
+
+// swapInto stores values[i] in buckets[i] at ts where has[i] is set, and
+// empties the other buckets. It must be called with the buckets' guards held
+// for writing.
+func swapInto(buckets []*bucket, values []any, has []bool, ts int64) []swappedUpdate {
+	var updates []swappedUpdate
+	for i, b := range buckets {
+		if !has[i] {
+			if b.timestamp != 0 {
+				b.drop()
+			}
+			continue
+		}
+		u := Update{Bucket: b.name(), Value: values[i], Timestamp: ts}
+		u.Version = b.store(u.Value, ts)
+		updates = append(updates, swappedUpdate{Update: u, b: b, watchers: b.watchers})
+	}
+	return updates
+}
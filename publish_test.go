@@ -0,0 +1,77 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishSwap(t *testing.T) {
+	live := NewDataPool()
+	prices := live.Bucket("prices")
+	live.PutMany(map[string]any{"prices": "old", "retired": "old"})
+	updates := prices.Watch(context.Background())
+
+	staging := NewDataPool()
+	staging.PutMany(map[string]any{"prices": "new", "added": "new"})
+
+	before := live.lastStamp.Load()
+	require.NoError(t, live.PublishSwap(staging))
+
+	results := live.GetMany([]string{"prices", "retired", "added"}, before)
+	assert.Equal(t, "new", results["prices"].Value)
+	assert.True(t, results["prices"].Fresh, "Swapped values get new timestamps")
+	assert.Equal(t, "new", results["added"].Value)
+	assert.Equal(t, results["prices"].Timestamp, results["added"].Timestamp, "Swapped values share a timestamp")
+	assert.Nil(t, results["retired"].Value, "Buckets missing from staging are emptied")
+	assert.Zero(t, results["retired"].Timestamp)
+	value, _, _ := prices.Get(0)
+	assert.Equal(t, "new", value, "Handles stay valid")
+	u := <-updates
+	assert.Equal(t, "new", u.Value)
+
+	results = staging.GetMany([]string{"prices", "retired", "added"}, 0)
+	assert.Equal(t, "old", results["prices"].Value, "The previous dataset is left in staging")
+	assert.Equal(t, "old", results["retired"].Value)
+	assert.Nil(t, results["added"].Value)
+
+	require.NoError(t, live.PublishSwap(staging), "Swapping again rolls back")
+	value, _, _ = prices.Get(0)
+	assert.Equal(t, "old", value)
+
+	assert.Error(t, live.PublishSwap(live))
+}
+
+func TestPublishSwapRejected(t *testing.T) {
+	live := NewDataPool()
+	config := live.Bucket("config")
+	config.Put("v1")
+	config.SetValidator(func(value any) error {
+		if _, ok := value.(string); !ok {
+			return errors.New("config must be a string")
+		}
+		return nil
+	})
+	other := live.Bucket("other")
+	other.Put("v1")
+
+	staging := NewDataPool()
+	staging.PutMany(map[string]any{"config": 2, "other": "v2"})
+
+	err := live.PublishSwap(staging)
+	assert.ErrorIs(t, err, ErrInvalidValue)
+	value, _, _ := other.Get(0)
+	assert.Equal(t, "v1", value, "Nothing is swapped when a value is rejected")
+	value, _, _ = staging.Handle("other").Get(0)
+	assert.Equal(t, "v2", value)
+
+	staging.PutMany(map[string]any{"config": "v2"})
+	_, err = other.Claim("job")
+	require.NoError(t, err)
+	assert.ErrorIs(t, live.PublishSwap(staging), ErrClaimed)
+	value, _, _ = config.Get(0)
+	assert.Equal(t, "v1", value)
+}
@@ -0,0 +1,124 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// QuorumBackend replicates a pool's buckets across several backends. The
+// first replica is the leader: default reads and reads at ConsistencyLeader
+// are answered by it, and Watch follows it. Writes go to every replica. At
+// ConsistencyQuorum, reads and writes must be answered by a majority of the
+// replicas, and reads return the newest value among them.
+type QuorumBackend struct {
+	replicas []Backend
+}
+
+var _ ConsistentBackend = (*QuorumBackend)(nil)
+
+// NewQuorumBackend returns a backend replicating to leader and followers.
+func NewQuorumBackend(leader Backend, followers ...Backend) *QuorumBackend {
+	return &QuorumBackend{replicas: append([]Backend{leader}, followers...)}
+}
+
+// Get implements Backend. It reads the leader.
+func (q *QuorumBackend) Get(ctx context.Context, name string) (Update, error) {
+	return q.replicas[0].Get(ctx, name)
+}
+
+// Put implements Backend. It writes every replica and returns the leader's
+// error.
+func (q *QuorumBackend) Put(ctx context.Context, u Update) error {
+	return q.PutConsistent(ctx, u, ConsistencyLeader)
+}
+
+// Watch implements Backend. It watches the leader.
+func (q *QuorumBackend) Watch(ctx context.Context, fn func(Update)) error {
+	return q.replicas[0].Watch(ctx, fn)
+}
+
+// GetConsistent implements ConsistentBackend.
+func (q *QuorumBackend) GetConsistent(ctx context.Context, name string, level Consistency) (Update, error) {
+	if level != ConsistencyQuorum {
+		return q.Get(ctx, name)
+	}
+
+	type result struct {
+		u   Update
+		err error
+	}
+	results := make(chan result, len(q.replicas))
+	for _, r := range q.replicas {
+		go func() {
+			u, err := r.Get(ctx, name)
+			results <- result{u, err}
+		}()
+	}
+
+	var newest Update
+	var errs []error
+	quorum := q.quorum()
+	for answered := 0; answered < quorum; {
+		res := <-results
+		if res.err != nil {
+			errs = append(errs, res.err)
+			if len(errs) > len(q.replicas)-quorum {
+				return Update{}, q.noQuorum(errs)
+			}
+			continue
+		}
+		answered++
+		if res.u.Timestamp > newest.Timestamp {
+			newest = res.u
+		}
+	}
+	return newest, nil
+}
+
+// PutConsistent implements ConsistentBackend. Writes to every replica are
+// started; at ConsistencyLeader it waits for all of them and returns the
+// leader's error, at ConsistencyQuorum it returns once a majority stored the
+// value, leaving the others to finish until ctx is done.
+func (q *QuorumBackend) PutConsistent(ctx context.Context, u Update, level Consistency) error {
+	results := make(chan error, len(q.replicas))
+	for _, r := range q.replicas[1:] {
+		go func() {
+			results <- r.Put(ctx, u)
+		}()
+	}
+
+	if level != ConsistencyQuorum {
+		err := q.replicas[0].Put(ctx, u)
+		for range q.replicas[1:] {
+			<-results
+		}
+		return err
+	}
+
+	go func() {
+		results <- q.replicas[0].Put(ctx, u)
+	}()
+	var errs []error
+	quorum := q.quorum()
+	for stored := 0; stored < quorum; {
+		if err := <-results; err != nil {
+			errs = append(errs, err)
+			if len(errs) > len(q.replicas)-quorum {
+				return q.noQuorum(errs)
+			}
+			continue
+		}
+		stored++
+	}
+	return nil
+}
+
+// quorum returns the number of replicas making a majority.
+func (q *QuorumBackend) quorum() int {
+	return len(q.replicas)/2 + 1
+}
+
+func (q *QuorumBackend) noQuorum(errs []error) error {
+	return fmt.Errorf("datapool: no quorum of %d out of %d replicas: %w", q.quorum(), len(q.replicas), errors.Join(errs...))
+}
@@ -0,0 +1,84 @@
+package datapool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func quorumOf(n int) (*QuorumBackend, []*flakyBackend) {
+	replicas := make([]*flakyBackend, n)
+	for i := range replicas {
+		replicas[i] = &flakyBackend{}
+	}
+	followers := make([]Backend, n-1)
+	for i, r := range replicas[1:] {
+		followers[i] = r
+	}
+	return NewQuorumBackend(replicas[0], followers...), replicas
+}
+
+func TestQuorumBackendPut(t *testing.T) {
+	ctx := context.Background()
+	q, replicas := quorumOf(3)
+
+	replicas[2].down.Store(true)
+	require.NoError(t, q.PutConsistent(ctx, Update{Bucket: "a", Value: 1, Timestamp: 1}, ConsistencyQuorum))
+
+	replicas[1].down.Store(true)
+	err := q.PutConsistent(ctx, Update{Bucket: "a", Value: 2, Timestamp: 2}, ConsistencyQuorum)
+	assert.ErrorIs(t, err, errUnreachable)
+	assert.ErrorContains(t, err, "datapool: no quorum of 2 out of 3 replicas")
+
+	require.NoError(t, q.Put(ctx, Update{Bucket: "b", Value: 1, Timestamp: 3}), "Default writes only need the leader")
+	u, _ := replicas[0].MemoryBackend.Get(ctx, "b")
+	assert.Equal(t, int64(3), u.Timestamp)
+
+	replicas[0].down.Store(true)
+	replicas[1].down.Store(false)
+	assert.ErrorIs(t, q.Put(ctx, Update{Bucket: "b", Value: 2, Timestamp: 4}), errUnreachable)
+	u, _ = replicas[1].MemoryBackend.Get(ctx, "b")
+	assert.Equal(t, int64(4), u.Timestamp, "Followers are written even when the leader fails")
+}
+
+func TestQuorumBackendGet(t *testing.T) {
+	ctx := context.Background()
+	q, replicas := quorumOf(3)
+	require.NoError(t, replicas[0].MemoryBackend.Put(ctx, Update{Bucket: "a", Value: "old", Timestamp: 1}))
+	require.NoError(t, replicas[1].MemoryBackend.Put(ctx, Update{Bucket: "a", Value: "new", Timestamp: 2}))
+
+	u, err := q.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "old", u.Value, "Default reads are answered by the leader")
+
+	replicas[2].down.Store(true)
+	u, err = q.GetConsistent(ctx, "a", ConsistencyQuorum)
+	require.NoError(t, err)
+	assert.Equal(t, "new", u.Value, "Quorum reads return the newest value of a majority")
+
+	replicas[1].down.Store(true)
+	_, err = q.GetConsistent(ctx, "a", ConsistencyQuorum)
+	assert.ErrorIs(t, err, errUnreachable)
+}
+
+func TestQuorumBackendPool(t *testing.T) {
+	ctx := context.Background()
+	q, replicas := quorumOf(3)
+	pool := NewDataPool(WithBackend(q))
+	b := pool.Bucket("config")
+
+	replicas[0].down.Store(true)
+	ts, err := b.PutConsistent(ctx, "v", ConsistencyQuorum)
+	require.NoError(t, err, "A majority is enough without the leader")
+	_, err = b.PutConsistent(ctx, "w", ConsistencyLeader)
+	assert.ErrorIs(t, err, errUnreachable)
+
+	other := NewDataPool(WithBackend(q))
+	ob := other.Bucket("config")
+	val, got, _, err := ob.GetConsistent(ctx, 0, ConsistencyQuorum)
+	require.NoError(t, err)
+	assert.Equal(t, "w", val)
+	assert.Greater(t, got, ts)
+}
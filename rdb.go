@@ -0,0 +1,485 @@
+package datapool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"math"
+)
+
+// rdbVersion is the RDB version written by ExportRDB: that of Redis 5.0, which
+// every later release can load.
+const rdbVersion = 9
+
+// rdbMaxVersion is the newest RDB version ImportRDB reads.
+const rdbMaxVersion = 12
+
+// RDB opcodes, as defined by Redis' rdb.h.
+const (
+	rdbOpSlotInfo  = 0xF4
+	rdbOpModuleAux = 0xF7
+	rdbOpIdle      = 0xF8
+	rdbOpFreq      = 0xF9
+	rdbOpAux       = 0xFA
+	rdbOpResizeDB  = 0xFB
+	rdbOpExpireMs  = 0xFC
+	rdbOpExpireSec = 0xFD
+	rdbOpSelectDB  = 0xFE
+	rdbOpEOF       = 0xFF
+)
+
+// rdbTypeString is the only value type ImportRDB reads.
+const rdbTypeString = 0
+
+// Length encodings, told apart by the two high bits of the first byte.
+const (
+	rdbLen6Bit    = 0
+	rdbLen14Bit   = 1
+	rdbLenEncoded = 3
+	rdbLen32Bit   = 0x80
+	rdbLen64Bit   = 0x81
+)
+
+// Special string encodings, following an rdbLenEncoded byte.
+const (
+	rdbEncInt8  = 0
+	rdbEncInt16 = 1
+	rdbEncInt32 = 2
+	rdbEncLZF   = 3
+)
+
+// rdbCRC is the CRC-64/Jones table used by Redis, in reflected form.
+var rdbCRC = crc64.MakeTable(0x95AC9329AC4BC9B5)
+
+// rdbChecksum updates a Redis RDB checksum. Unlike hash/crc64, Redis does not
+// invert the register before and after.
+func rdbChecksum(crc uint64, p []byte) uint64 {
+	for _, b := range p {
+		crc = rdbCRC[byte(crc)^b] ^ (crc >> 8)
+	}
+	return crc
+}
+
+// ExportRDB writes the named buckets to w as a Redis RDB file of string keys,
+// which redis-server loads at startup when it is its dump file. With no
+// names, every bucket is exported; empty buckets are skipped. Strings and
+// []byte values are written as they are and other values as JSON, which keeps
+// numbers usable with INCR and friends. Buckets whose values expire keep their
+// expiration time.
+func (p *DataPool) ExportRDB(w io.Writer, names ...string) error {
+	var buckets []*bucket
+	if len(names) == 0 {
+		buckets = p.all()
+	} else {
+		for _, name := range names {
+			if b := p.find(name); b != nil {
+				buckets = append(buckets, b)
+			}
+		}
+	}
+
+	rw := &rdbWriter{w: bufio.NewWriter(w)}
+	rw.write([]byte(fmt.Sprintf("REDIS%04d", rdbVersion)))
+	rw.write([]byte{rdbOpSelectDB})
+	rw.writeLen(0)
+
+	for _, b := range buckets {
+		b.guard.RLock()
+		value, ts := b.readValue(p)
+		expiresAt := b.expiresAt
+		b.guard.RUnlock()
+		if ts == 0 {
+			continue
+		}
+
+		data, err := encodeRDBValue(value)
+		if err != nil {
+			return fmt.Errorf("datapool: export rdb: bucket %q: %w", b.name(), err)
+		}
+		if expiresAt != 0 {
+			var ms [8]byte
+			binary.LittleEndian.PutUint64(ms[:], uint64(expiresAt/1e6))
+			rw.write([]byte{rdbOpExpireMs})
+			rw.write(ms[:])
+		}
+		rw.write([]byte{rdbTypeString})
+		rw.writeString([]byte(b.name()))
+		rw.writeString(data)
+	}
+
+	rw.write([]byte{rdbOpEOF})
+	var sum [8]byte
+	binary.LittleEndian.PutUint64(sum[:], rw.crc)
+	rw.write(sum[:])
+
+	if rw.err == nil {
+		rw.err = rw.w.Flush()
+	}
+	if rw.err != nil {
+		return fmt.Errorf("datapool: export rdb: %w", rw.err)
+	}
+	return nil
+}
+
+func encodeRDBValue(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	default:
+		return json.Marshal(v)
+	}
+}
+
+type rdbWriter struct {
+	w   *bufio.Writer
+	crc uint64
+	err error
+}
+
+func (rw *rdbWriter) write(p []byte) {
+	if rw.err != nil {
+		return
+	}
+	rw.crc = rdbChecksum(rw.crc, p)
+	_, rw.err = rw.w.Write(p)
+}
+
+func (rw *rdbWriter) writeLen(n uint64) {
+	switch {
+	case n < 1<<6:
+		rw.write([]byte{byte(n)})
+	case n < 1<<14:
+		rw.write([]byte{byte(n>>8) | rdbLen14Bit<<6, byte(n)})
+	case n <= math.MaxUint32:
+		var buf [5]byte
+		buf[0] = rdbLen32Bit
+		binary.BigEndian.PutUint32(buf[1:], uint32(n))
+		rw.write(buf[:])
+	default:
+		var buf [9]byte
+		buf[0] = rdbLen64Bit
+		binary.BigEndian.PutUint64(buf[1:], n)
+		rw.write(buf[:])
+	}
+}
+
+func (rw *rdbWriter) writeString(s []byte) {
+	rw.writeLen(uint64(len(s)))
+	rw.write(s)
+}
+
+// ErrRDBFormat is returned by ImportRDB for input that is not a valid RDB
+// file, or uses features it does not support.
+var ErrRDBFormat = errors.New("datapool: invalid rdb")
+
+// ImportRDB loads the string keys of a Redis RDB file (as written by SAVE or
+// ExportRDB) into buckets of the same name, and returns how many were loaded.
+// Keys of all databases are loaded; keys that have already expired are
+// skipped, and the others keep their expiration time. decode converts each raw
+// value into the value to store; when nil, values are stored as strings.
+//
+// Only string keys are supported: a file holding lists, hashes or other types
+// fails with ErrRDBFormat after loading the keys before it.
+func (p *DataPool) ImportRDB(r io.Reader, decode func(name string, value []byte) (any, error)) (int, error) {
+	rr := &rdbReader{r: bufio.NewReader(r)}
+	n, err := p.importRDB(rr, decode)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = fmt.Errorf("%w: unexpected end of file", ErrRDBFormat)
+	}
+	return n, err
+}
+
+func (p *DataPool) importRDB(rr *rdbReader, decode func(string, []byte) (any, error)) (int, error) {
+	header := make([]byte, 9)
+	if err := rr.read(header); err != nil {
+		return 0, err
+	}
+	if !bytes.HasPrefix(header, []byte("REDIS")) {
+		return 0, fmt.Errorf("%w: bad header", ErrRDBFormat)
+	}
+	var version int
+	if _, err := fmt.Sscanf(string(header[5:]), "%04d", &version); err != nil || version < 1 || version > rdbMaxVersion {
+		return 0, fmt.Errorf("%w: unsupported version %q", ErrRDBFormat, header[5:])
+	}
+
+	loaded := 0
+	var expiresAt int64
+	for {
+		op, err := rr.readByte()
+		if err != nil {
+			return loaded, err
+		}
+
+		switch op {
+		case rdbOpEOF:
+			if version < 5 {
+				return loaded, nil
+			}
+			want := rr.crc
+			sum := make([]byte, 8)
+			if err := rr.read(sum); err != nil {
+				return loaded, err
+			}
+			// Redis writes a zero checksum when rdbchecksum is disabled.
+			if got := binary.LittleEndian.Uint64(sum); got != 0 && got != want {
+				return loaded, fmt.Errorf("%w: checksum mismatch", ErrRDBFormat)
+			}
+			return loaded, nil
+
+		case rdbOpSelectDB:
+			if _, err := rr.readLen(); err != nil {
+				return loaded, err
+			}
+
+		case rdbOpResizeDB:
+			if _, err := rr.readLen(); err != nil {
+				return loaded, err
+			}
+			if _, err := rr.readLen(); err != nil {
+				return loaded, err
+			}
+
+		case rdbOpSlotInfo:
+			for i := 0; i < 3; i++ {
+				if _, err := rr.readLen(); err != nil {
+					return loaded, err
+				}
+			}
+
+		case rdbOpAux:
+			if _, err := rr.readString(); err != nil {
+				return loaded, err
+			}
+			if _, err := rr.readString(); err != nil {
+				return loaded, err
+			}
+
+		case rdbOpIdle:
+			if _, err := rr.readLen(); err != nil {
+				return loaded, err
+			}
+
+		case rdbOpFreq:
+			if _, err := rr.readByte(); err != nil {
+				return loaded, err
+			}
+
+		case rdbOpExpireSec:
+			buf := make([]byte, 4)
+			if err := rr.read(buf); err != nil {
+				return loaded, err
+			}
+			expiresAt = int64(binary.LittleEndian.Uint32(buf)) * 1e9
+
+		case rdbOpExpireMs:
+			buf := make([]byte, 8)
+			if err := rr.read(buf); err != nil {
+				return loaded, err
+			}
+			expiresAt = int64(binary.LittleEndian.Uint64(buf)) * 1e6
+
+		case rdbTypeString:
+			name, err := rr.readString()
+			if err != nil {
+				return loaded, err
+			}
+			raw, err := rr.readString()
+			if err != nil {
+				return loaded, err
+			}
+
+			exp := expiresAt
+			expiresAt = 0
+			if exp != 0 && exp <= p.now() {
+				continue
+			}
+
+			var value any = string(raw)
+			if decode != nil {
+				if value, err = decode(string(name), raw); err != nil {
+					return loaded, fmt.Errorf("datapool: import rdb: key %q: %w", name, err)
+				}
+			}
+			b, err := p.bucket(string(name))
+			if err != nil {
+				return loaded, fmt.Errorf("datapool: import rdb: %w", err)
+			}
+			if p.write(b, value, exp, nil) != 0 {
+				loaded++
+			}
+
+		case rdbOpModuleAux:
+			return loaded, fmt.Errorf("%w: module data is not supported", ErrRDBFormat)
+
+		default:
+			return loaded, fmt.Errorf("%w: unsupported value type %d", ErrRDBFormat, op)
+		}
+	}
+}
+
+type rdbReader struct {
+	r   *bufio.Reader
+	crc uint64
+}
+
+func (rr *rdbReader) read(p []byte) error {
+	if _, err := io.ReadFull(rr.r, p); err != nil {
+		return err
+	}
+	rr.crc = rdbChecksum(rr.crc, p)
+	return nil
+}
+
+func (rr *rdbReader) readByte() (byte, error) {
+	var b [1]byte
+	err := rr.read(b[:])
+	return b[0], err
+}
+
+// readLenEnc reads a length, or the special encoding of a string if encoded.
+func (rr *rdbReader) readLenEnc() (n uint64, encoded bool, err error) {
+	first, err := rr.readByte()
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch first >> 6 {
+	case rdbLen6Bit:
+		return uint64(first & 0x3F), false, nil
+	case rdbLen14Bit:
+		next, err := rr.readByte()
+		return uint64(first&0x3F)<<8 | uint64(next), false, err
+	case rdbLenEncoded:
+		return uint64(first & 0x3F), true, nil
+	}
+
+	switch first {
+	case rdbLen32Bit:
+		buf := make([]byte, 4)
+		err := rr.read(buf)
+		return uint64(binary.BigEndian.Uint32(buf)), false, err
+	case rdbLen64Bit:
+		buf := make([]byte, 8)
+		err := rr.read(buf)
+		return binary.BigEndian.Uint64(buf), false, err
+	}
+	return 0, false, fmt.Errorf("%w: bad length encoding %#x", ErrRDBFormat, first)
+}
+
+func (rr *rdbReader) readLen() (uint64, error) {
+	n, encoded, err := rr.readLenEnc()
+	if err == nil && encoded {
+		err = fmt.Errorf("%w: unexpected encoded length", ErrRDBFormat)
+	}
+	return n, err
+}
+
+// maxRDBString bounds the strings ImportRDB allocates for, so a corrupt length
+// cannot exhaust memory.
+const maxRDBString = 512 << 20 // Redis' proto-max-bulk-len
+
+func (rr *rdbReader) readString() ([]byte, error) {
+	n, encoded, err := rr.readLenEnc()
+	if err != nil {
+		return nil, err
+	}
+	if !encoded {
+		return rr.readBytes(n)
+	}
+
+	switch n {
+	case rdbEncInt8:
+		b, err := rr.readByte()
+		return []byte(fmt.Sprint(int8(b))), err
+	case rdbEncInt16:
+		buf := make([]byte, 2)
+		err := rr.read(buf)
+		return []byte(fmt.Sprint(int16(binary.LittleEndian.Uint16(buf)))), err
+	case rdbEncInt32:
+		buf := make([]byte, 4)
+		err := rr.read(buf)
+		return []byte(fmt.Sprint(int32(binary.LittleEndian.Uint32(buf)))), err
+	case rdbEncLZF:
+		clen, err := rr.readLen()
+		if err != nil {
+			return nil, err
+		}
+		ulen, err := rr.readLen()
+		if err != nil {
+			return nil, err
+		}
+		if ulen > maxRDBString {
+			return nil, withKind(fmt.Errorf("%w: string of %d bytes is too long", ErrRDBFormat, ulen), ErrTooLarge)
+		}
+		compressed, err := rr.readBytes(clen)
+		if err != nil {
+			return nil, err
+		}
+		return lzfDecompress(compressed, int(ulen))
+	}
+	return nil, fmt.Errorf("%w: unknown string encoding %d", ErrRDBFormat, n)
+}
+
+func (rr *rdbReader) readBytes(n uint64) ([]byte, error) {
+	if n > maxRDBString {
+		return nil, withKind(fmt.Errorf("%w: string of %d bytes is too long", ErrRDBFormat, n), ErrTooLarge)
+	}
+	buf := make([]byte, n)
+	return buf, rr.read(buf)
+}
+
+// lzfDecompress expands LZF data, the compression Redis applies to long
+// strings, into exactly size bytes.
+func lzfDecompress(in []byte, size int) ([]byte, error) {
+	out := make([]byte, 0, size)
+	for i := 0; i < len(in); {
+		ctrl := int(in[i])
+		i++
+
+		if ctrl < 1<<5 {
+			// Literal run of ctrl+1 bytes.
+			n := ctrl + 1
+			if i+n > len(in) || len(out)+n > size {
+				return nil, fmt.Errorf("%w: corrupt lzf data", ErrRDBFormat)
+			}
+			out = append(out, in[i:i+n]...)
+			i += n
+			continue
+		}
+
+		// Back reference.
+		n := ctrl >> 5
+		if n == 7 {
+			if i >= len(in) {
+				return nil, fmt.Errorf("%w: corrupt lzf data", ErrRDBFormat)
+			}
+			n += int(in[i])
+			i++
+		}
+		n += 2
+		if i >= len(in) {
+			return nil, fmt.Errorf("%w: corrupt lzf data", ErrRDBFormat)
+		}
+		ref := len(out) - (ctrl&0x1F)<<8 - int(in[i]) - 1
+		i++
+		if ref < 0 || len(out)+n > size {
+			return nil, fmt.Errorf("%w: corrupt lzf data", ErrRDBFormat)
+		}
+		// The reference may overlap the bytes being written.
+		for j := 0; j < n; j++ {
+			out = append(out, out[ref+j])
+		}
+	}
+
+	if len(out) != size {
+		return nil, fmt.Errorf("%w: corrupt lzf data", ErrRDBFormat)
+	}
+	return out, nil
+}
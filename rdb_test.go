@@ -0,0 +1,185 @@
+package datapool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRDBChecksum(t *testing.T) {
+	// The check value from Redis' crc64.c.
+	assert.Equal(t, uint64(0xe9c6d914c4b8d9ca), rdbChecksum(0, []byte("123456789")))
+}
+
+func TestRDBRoundTrip(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+
+	str := pool.Bucket("greeting")
+	str.Put("hello")
+	raw := pool.Bucket("raw")
+	raw.Put([]byte{0, 1, 2})
+	num := pool.Bucket("counter")
+	num.Put(42)
+	obj := pool.Bucket("user")
+	obj.Put(map[string]any{"name": "Alice"})
+	session := pool.Bucket("session")
+	session.SetTTL(time.Minute)
+	session.Put("token")
+	long := pool.Bucket("long")
+	long.Put(string(bytes.Repeat([]byte("x"), 20000)))
+	pool.Bucket("empty")
+
+	var buf bytes.Buffer
+	require.NoError(t, pool.ExportRDB(&buf))
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("REDIS0009")))
+
+	restored := NewDataPool(WithClock(clock))
+	n, err := restored.ImportRDB(bytes.NewReader(buf.Bytes()), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 6, n)
+
+	get := func(name string) any {
+		b := restored.Bucket(name)
+		v, _, _ := b.Get(0)
+		return v
+	}
+	assert.Equal(t, "hello", get("greeting"))
+	assert.Equal(t, "\x00\x01\x02", get("raw"))
+	assert.Equal(t, "42", get("counter"), "Non-string values are exported as JSON")
+	assert.Equal(t, `{"name":"Alice"}`, get("user"))
+	assert.Len(t, get("long"), 20000)
+	assert.Equal(t, "token", get("session"))
+
+	clock.Advance(time.Minute)
+	assert.Nil(t, get("session"), "Expiration time survives the round trip")
+	assert.NotNil(t, get("greeting"))
+}
+
+func TestExportRDBSelected(t *testing.T) {
+	pool := NewDataPool()
+	pool.PutMany(map[string]any{"a": "1", "b": "2", "c": "3"})
+
+	var buf bytes.Buffer
+	require.NoError(t, pool.ExportRDB(&buf, "a", "c", "missing"))
+
+	restored := NewDataPool()
+	n, err := restored.ImportRDB(&buf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Nil(t, restored.find("b"))
+}
+
+func TestExportRDBUnencodable(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("fn")
+	b.Put(func() {})
+
+	err := pool.ExportRDB(&bytes.Buffer{})
+	assert.ErrorContains(t, err, `bucket "fn"`)
+}
+
+func TestImportRDBDecode(t *testing.T) {
+	pool := NewDataPool()
+	pool.PutMany(map[string]any{"n": 42, "s": "text"})
+
+	var buf bytes.Buffer
+	require.NoError(t, pool.ExportRDB(&buf))
+
+	restored := NewDataPool()
+	_, err := restored.ImportRDB(&buf, func(name string, value []byte) (any, error) {
+		if name == "s" {
+			return string(value), nil
+		}
+		var v any
+		err := json.Unmarshal(value, &v)
+		return v, err
+	})
+	require.NoError(t, err)
+
+	b := restored.Bucket("n")
+	v, _, _ := b.Get(0)
+	assert.Equal(t, float64(42), v)
+}
+
+// rdbFile builds an RDB file from raw body bytes, with checksum disabled.
+func rdbFile(version string, body ...byte) []byte {
+	file := append([]byte("REDIS"+version), body...)
+	return append(file, rdbOpEOF, 0, 0, 0, 0, 0, 0, 0, 0)
+}
+
+func TestImportRDBEncodings(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+
+	expired := make([]byte, 4)
+	binary.LittleEndian.PutUint32(expired, 999)
+
+	file := rdbFile("0011",
+		rdbOpAux, 9, 'r', 'e', 'd', 'i', 's', '-', 'v', 'e', 'r', 5, '7', '.', '2', '.', '0',
+		rdbOpSelectDB, 0,
+		rdbOpResizeDB, 4, 0,
+		// Integer encodings.
+		rdbTypeString, 2, 'i', '8', 0xC0, 0xFB,
+		rdbTypeString, 3, 'i', '1', '6', 0xC1, 0x39, 0x30,
+		rdbTypeString, 3, 'i', '3', '2', 0xC2, 0x00, 0xCA, 0x9A, 0x3B,
+		// "abc" followed by a back reference repeating it twice.
+		rdbTypeString, 3, 'l', 'z', 'f', 0xC3, 6, 9, 2, 'a', 'b', 'c', 4<<5, 2,
+		// Idle time and frequency hints are skipped.
+		rdbOpIdle, 10, rdbOpFreq, 3,
+		rdbTypeString, 1, 'h', 1, 'x',
+		// Already expired.
+		rdbOpExpireSec, expired[0], expired[1], expired[2], expired[3],
+		rdbTypeString, 3, 'o', 'l', 'd', 1, 'x',
+	)
+
+	n, err := pool.ImportRDB(bytes.NewReader(file), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+
+	for name, want := range map[string]any{
+		"i8":  "-5",
+		"i16": "12345",
+		"i32": "1000000000",
+		"lzf": "abcabcabc",
+		"h":   "x",
+	} {
+		b := pool.Bucket(name)
+		v, _, _ := b.Get(0)
+		assert.Equal(t, want, v, name)
+	}
+	assert.Nil(t, pool.find("old"), "Expired keys are not imported")
+}
+
+func TestImportRDBErrors(t *testing.T) {
+	valid := func() []byte {
+		pool := NewDataPool()
+		b := pool.Bucket("a")
+		b.Put("1")
+		var buf bytes.Buffer
+		require.NoError(t, pool.ExportRDB(&buf))
+		return buf.Bytes()
+	}
+
+	corrupt := valid()
+	corrupt[len(corrupt)-1] ^= 0xFF
+
+	for name, file := range map[string][]byte{
+		"bad header":       []byte("NOTREDIS0009"),
+		"future version":   rdbFile("0099"),
+		"truncated":        valid()[:12],
+		"checksum":         corrupt,
+		"unsupported type": rdbFile("0009", 1, 1, 'l', 0),
+		"corrupt lzf":      rdbFile("0009", rdbTypeString, 1, 'k', 0xC3, 2, 9, 4<<5, 9),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewDataPool().ImportRDB(bytes.NewReader(file), nil)
+			assert.ErrorIs(t, err, ErrRDBFormat)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package datapool
+
+import "context"
+
+// ReadOnlyPool is a view of a pool that can read and watch buckets but not
+// write them, for handing a pool to code that must not change it, such as
+// plugins. Its buckets have no write methods, and the view cannot be turned
+// back into the pool it reads.
+//
+// Values are returned as they are stored, so a reader could still mutate a
+// map or slice it reads in place; buckets that copy values (see
+// SetCopyValues) return copies instead.
+type ReadOnlyPool struct {
+	pool *DataPool
+}
+
+// ReadOnlyBucket is a bucket of a ReadOnlyPool.
+type ReadOnlyBucket struct {
+	pool *DataPool
+	name string
+}
+
+// ReadOnly returns a read-only view of the pool.
+func (p *DataPool) ReadOnly() ReadOnlyPool {
+	return ReadOnlyPool{pool: p}
+}
+
+// Bucket returns the named bucket. Unlike DataPool.Bucket, it does not create
+// the bucket: a bucket that does not exist reads as empty until it is
+// created, and can be watched before it is first written.
+func (v ReadOnlyPool) Bucket(name string) ReadOnlyBucket {
+	return ReadOnlyBucket{pool: v.pool, name: name}
+}
+
+// Name returns the bucket's name.
+func (b ReadOnlyBucket) Name() string {
+	return b.name
+}
+
+// Get is Bucket.Get. A bucket that does not exist returns no value and a zero
+// timestamp.
+func (b ReadOnlyBucket) Get(timestamp int64) (any, int64, bool) {
+	if b.pool == nil {
+		return nil, 0, false
+	}
+	bk := b.pool.find(b.name)
+	if bk == nil {
+		return nil, 0, false
+	}
+	h := Bucket{pool: b.pool, b: bk}
+	return h.Get(timestamp)
+}
+
+// Watch is Bucket.Watch, except that the channel receives the updates of the
+// bucket created again after it is evicted or cleared, and is only closed
+// when ctx is done.
+func (b ReadOnlyBucket) Watch(ctx context.Context) <-chan Update {
+	if b.pool == nil {
+		ch := make(chan Update)
+		close(ch)
+		return ch
+	}
+	return b.pool.watchNames(ctx, func(name string) bool {
+		return name == b.name
+	})
+}
@@ -0,0 +1,65 @@
+package datapool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOnly(t *testing.T) {
+	pool := NewDataPool()
+	config := pool.Bucket("config")
+	ts := config.Put("v1")
+
+	view := pool.ReadOnly()
+	b := view.Bucket("config")
+	assert.Equal(t, "config", b.Name())
+	value, got, fresh := b.Get(0)
+	assert.Equal(t, "v1", value)
+	assert.Equal(t, ts, got)
+	assert.True(t, fresh)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := view.Bucket("later").Watch(ctx)
+	pool.Handle("later").Put("v2")
+	assert.Equal(t, "v2", (<-updates).Value)
+
+	value, _, _ = ReadOnlyPool{}.Bucket("config").Get(0)
+	assert.Nil(t, value, "The zero view reads nothing")
+}
+
+func TestReadOnlyDoesNotCreate(t *testing.T) {
+	pool := NewDataPool(WithMaxBuckets(2))
+	pool.Handle("a").Put(1)
+	pool.Handle("b").Put(2)
+
+	view := pool.ReadOnly()
+	value, ts, fresh := view.Bucket("missing").Get(0)
+	assert.Nil(t, value)
+	assert.Zero(t, ts)
+	assert.False(t, fresh)
+	view.Bucket("other").Watch(context.Background())
+
+	assert.Equal(t, 2, pool.Len())
+	assert.NotNil(t, pool.find("a"), "Reading unknown buckets evicts nothing")
+	assert.Nil(t, pool.find("missing"))
+}
+
+func TestReadOnlyMethodSets(t *testing.T) {
+	methods := func(v any) []string {
+		typ := reflect.TypeOf(v)
+		names := make([]string, typ.NumMethod())
+		for i := range names {
+			names[i] = typ.Method(i).Name
+		}
+		return names
+	}
+	assert.Equal(t, []string{"Bucket"}, methods(ReadOnlyPool{}))
+	assert.Equal(t, []string{"Get", "Name", "Watch"}, methods(ReadOnlyBucket{}), "Read-only buckets cannot be written")
+
+	_, ok := any(ReadOnlyBucket{}).(Handle)
+	assert.False(t, ok)
+}
@@ -0,0 +1,119 @@
+package datapool
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Ref is a reference to a bucket, returned by Bucket.Ref, that can be passed
+// to other processes, stored and resolved back to the bucket with
+// DataPool.Resolve. It is an opaque string: it identifies the pool the bucket
+// belongs to, by the pool's ID, and the bucket's generation within the pool,
+// so a reference never resolves to a bucket created after the one it was
+// taken from, under the same name or another, nor to a bucket of another pool
+// or of the same pool's next process.
+type Ref string
+
+// ErrInvalidRef is returned by Resolve for a string that is not a Ref.
+var ErrInvalidRef = errors.New("datapool: invalid bucket reference")
+
+// ErrForeignRef is returned by Resolve for a Ref to a bucket of another pool,
+// or of a pool created earlier with the same IDs. It is an ErrNotFound.
+var ErrForeignRef = newKindError(ErrNotFound, "datapool: reference to another pool")
+
+// WithIDGenerator sets the function generating the pool's ID (see ID), called
+// once as the pool is created. The ID tells the pool's references apart from
+// those of other pools, so it must be unique across the pools, and restarts,
+// whose references meet, and must not be empty: a generator returning "" is
+// ignored. The default generates a random UUID.
+func WithIDGenerator(gen func() string) Option {
+	return func(o *options) {
+		o.idGenerator = gen
+	}
+}
+
+// ID returns the pool's ID, which the references of its buckets carry (see
+// Bucket.Ref). It is generated as the pool is created, by the function set
+// with WithIDGenerator, or as a random UUID.
+func (p *DataPool) ID() string {
+	p.lazyInit()
+	return p.id
+}
+
+// newPoolID returns the ID of a pool configured with o.
+func newPoolID(o options) string {
+	if o.idGenerator != nil {
+		if id := o.idGenerator(); id != "" {
+			return id
+		}
+	}
+	return newUUID()
+}
+
+// newUUID returns a random, version 4 UUID.
+func newUUID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// Ref returns a reference to the bucket, that Resolve turns back into a
+// handle to it for as long as it exists, including after a Rename, in this
+// process or in another one given the string.
+func (b *Bucket) Ref() Ref {
+	bk := b.resolve("ref")
+	if bk == nil {
+		return ""
+	}
+	return b.pool.ref(bk)
+}
+
+// ref returns the reference to b, registering b so Resolve finds it.
+func (p *DataPool) ref(b *bucket) Ref {
+	p.refs.Store(b.id, b)
+	// A bucket removed meanwhile stays unregistered: remove drops it after
+	// marking it removed.
+	b.guard.RLock()
+	removed := b.removed
+	b.guard.RUnlock()
+	if removed {
+		p.refs.Delete(b.id)
+	}
+	return Ref(p.ID() + ":" + strconv.FormatInt(int64(b.id), 36))
+}
+
+// Resolve returns a handle to the bucket ref refers to. It fails with
+// ErrInvalidRef if ref is not a reference, ErrForeignRef if it refers to a
+// bucket of another pool, and ErrBucketNotFound, wrapping ErrRemoved, if the
+// bucket was removed or evicted since.
+func (p *DataPool) Resolve(ref Ref) (Bucket, error) {
+	i := strings.LastIndexByte(string(ref), ':')
+	if i <= 0 {
+		return Bucket{}, fmt.Errorf("%w: %q", ErrInvalidRef, ref)
+	}
+	gen, err := strconv.ParseInt(string(ref[i+1:]), 36, 64)
+	if err != nil || gen < 0 {
+		return Bucket{}, fmt.Errorf("%w: %q", ErrInvalidRef, ref)
+	}
+	if string(ref[:i]) != p.ID() {
+		return Bucket{}, fmt.Errorf("%w: %q", ErrForeignRef, ref)
+	}
+
+	v, ok := p.refs.Load(int(gen))
+	if !ok {
+		if gen < p.nextID.Load() {
+			return Bucket{}, fmt.Errorf("%w: %q: %w", ErrBucketNotFound, ref, ErrRemoved)
+		}
+		return Bucket{}, fmt.Errorf("%w: %q", ErrForeignRef, ref)
+	}
+	b := v.(*bucket)
+	if err := b.checkRemoved(); err != nil {
+		return Bucket{}, err
+	}
+	return Bucket{pool: p, b: b}, nil
+}
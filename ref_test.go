@@ -0,0 +1,79 @@
+package datapool
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRef(t *testing.T) {
+	pool := NewDataPool()
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), pool.ID())
+	assert.NotEqual(t, pool.ID(), NewDataPool().ID())
+
+	config := pool.Bucket("config")
+	config.Put(1)
+	ref := config.Ref()
+	assert.True(t, strings.HasPrefix(string(ref), pool.ID()+":"))
+
+	b, err := pool.Resolve(ref)
+	require.NoError(t, err)
+	value, _, _ := b.Get(0)
+	assert.Equal(t, 1, value)
+
+	require.NoError(t, pool.Rename("config", "settings"))
+	b, err = pool.Resolve(ref)
+	require.NoError(t, err, "References survive renames")
+	assert.Equal(t, "settings", b.Name())
+
+	pool.Clear()
+	_, err = pool.Resolve(ref)
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+	assert.ErrorIs(t, err, ErrRemoved)
+
+	recreated := pool.Bucket("settings")
+	assert.NotEqual(t, ref, recreated.Ref(), "A new bucket under the same name has another reference")
+	_, err = pool.Resolve(ref)
+	assert.ErrorIs(t, err, ErrRemoved)
+
+	var zero Bucket
+	assert.Equal(t, Ref(""), zero.Ref())
+}
+
+func TestResolveErrors(t *testing.T) {
+	pool := NewDataPool()
+	other := NewDataPool()
+	a := other.Bucket("a")
+	ref := a.Ref()
+
+	_, err := pool.Resolve(ref)
+	assert.ErrorIs(t, err, ErrForeignRef)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	for _, bad := range []Ref{"", "config", ":1", Ref(pool.ID() + ":"), Ref(pool.ID() + ":-1"), Ref(pool.ID() + ":!")} {
+		_, err := pool.Resolve(bad)
+		assert.True(t, errors.Is(err, ErrInvalidRef), "%q: %v", bad, err)
+	}
+}
+
+func TestIDGenerator(t *testing.T) {
+	pool := NewDataPool(WithIDGenerator(func() string { return "cache-1:boot-7" }))
+	assert.Equal(t, "cache-1:boot-7", pool.ID())
+	b := pool.Bucket("a")
+	resolved, err := pool.Resolve(b.Ref())
+	require.NoError(t, err, "IDs may hold the separator")
+	assert.Equal(t, "a", resolved.Name())
+
+	// A reference of a pool's previous process, which had more buckets, is
+	// not one of the new process's.
+	restarted := NewDataPool(WithIDGenerator(func() string { return "cache-1:boot-7" }))
+	_, err = restarted.Resolve(Ref("cache-1:boot-7:zz"))
+	assert.ErrorIs(t, err, ErrForeignRef)
+
+	empty := NewDataPool(WithIDGenerator(func() string { return "" }))
+	assert.NotEmpty(t, empty.ID(), "Empty IDs are replaced with a random one")
+}
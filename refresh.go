@@ -0,0 +1,177 @@
+package datapool
+
+import (
+	"strings"
+	"sync"
+)
+
+// LoadFunc produces a fresh value for a bucket.
+type LoadFunc func() (any, error)
+
+// Namespace returns the namespace of a bucket name: the part before the first
+// '/', or the empty string when the name has no '/'.
+func Namespace(name string) string {
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// ScheduleRefresh queues load to run on the pool's shared refresh workers and
+// stores its result in the bucket named name. If load fails the bucket keeps
+// its previous value. The returned channel receives the error returned by load
+// (nil on success) once it has run; callers may ignore it. Loaded values are
+// recorded with a SourceLoader step in their provenance. If the pool's
+// NameRules reject name, it is in the SystemNamespace, or the bucket cannot
+// be created, as beyond its tenant's quota, load does not run and the channel
+// receives the error.
+//
+// Workers are shared between namespaces by weighted fair queuing, so a
+// namespace with a large backlog cannot starve another's refreshes.
+func (p *DataPool) ScheduleRefresh(name string, load LoadFunc) <-chan error {
+	done := make(chan error, 1)
+	if err := p.checkWritable(name); err != nil {
+		done <- err
+		return done
+	}
+	bk, err := p.bucket(name)
+	if err != nil {
+		done <- err
+		return done
+	}
+	b := Bucket{pool: p, b: bk}
+
+	namespace := Namespace(name)
+	limit := p.loadLimits[namespace]
+	p.refresh.enqueue(namespace, func() {
+		if limit != nil {
+			limit.refresh(b, load, done)
+			return
+		}
+		done <- p.storeRefresh(b, load)
+	})
+	return done
+}
+
+// storeRefresh runs load and stores its value in b, returning load's error,
+// or ErrPoolClosed without running load once the pool is closed.
+func (p *DataPool) storeRefresh(b Bucket, load LoadFunc) error {
+	if p.isClosed() {
+		return ErrPoolClosed
+	}
+	value, err := load()
+	if err == nil {
+		b.PutFrom(value, Source{Kind: SourceLoader, At: p.opts.clock.Now()})
+	}
+	return err
+}
+
+// refreshQueue is a self-clocked weighted fair queue feeding a bounded number
+// of workers. Every job gets a virtual finish tag of
+// max(virtual time, previous tag of its namespace) + 1/weight, and workers
+// always run the queued job with the smallest tag. Workers are started on
+// demand and exit when the queue drains.
+type refreshQueue struct {
+	pool    *DataPool
+	mu      sync.Mutex
+	workers int
+	running int
+	weights map[string]int
+	queues  map[string]*namespaceQueue
+	vtime   float64
+}
+
+type namespaceQueue struct {
+	jobs   []refreshJob
+	finish float64
+}
+
+type refreshJob struct {
+	run func()
+	tag float64
+}
+
+func newRefreshQueue(p *DataPool, workers int, weights map[string]int) *refreshQueue {
+	return &refreshQueue{
+		pool:    p,
+		workers: workers,
+		weights: weights,
+		queues:  make(map[string]*namespaceQueue),
+	}
+}
+
+func (q *refreshQueue) weight(namespace string) float64 {
+	if w, ok := q.weights[namespace]; ok && w > 0 {
+		return float64(w)
+	}
+	return 1
+}
+
+func (q *refreshQueue) enqueue(namespace string, run func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	nq, ok := q.queues[namespace]
+	if !ok {
+		nq = &namespaceQueue{}
+		q.queues[namespace] = nq
+	}
+
+	start := max(q.vtime, nq.finish)
+	nq.finish = start + 1/q.weight(namespace)
+	nq.jobs = append(nq.jobs, refreshJob{run: run, tag: nq.finish})
+
+	if q.running < q.workers {
+		q.running++
+		// Jobs queued once the pool is closed fail quickly, so their workers
+		// need not be waited for.
+		if !q.pool.goBackground(q.work) {
+			go q.work()
+		}
+	}
+}
+
+// next pops the job with the smallest finish tag. It must be called with q.mu held.
+func (q *refreshQueue) next() (refreshJob, bool) {
+	var best *namespaceQueue
+	var bestName string
+	for name, nq := range q.queues {
+		if len(nq.jobs) == 0 {
+			q.pool.violation("refresh: namespace %q has an empty queue", name)
+			delete(q.queues, name)
+			continue
+		}
+		// Ties are broken by name so scheduling is deterministic.
+		if best == nil || nq.jobs[0].tag < best.jobs[0].tag ||
+			(nq.jobs[0].tag == best.jobs[0].tag && name < bestName) {
+			best, bestName = nq, name
+		}
+	}
+	if best == nil {
+		return refreshJob{}, false
+	}
+
+	job := best.jobs[0]
+	best.jobs[0] = refreshJob{}
+	best.jobs = best.jobs[1:]
+	if len(best.jobs) == 0 {
+		delete(q.queues, bestName)
+	}
+	q.vtime = job.tag
+	return job, true
+}
+
+func (q *refreshQueue) work() {
+	for {
+		q.mu.Lock()
+		job, ok := q.next()
+		if !ok {
+			q.running--
+			q.mu.Unlock()
+			return
+		}
+		q.mu.Unlock()
+
+		job.run()
+	}
+}
@@ -0,0 +1,126 @@
+package datapool
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespace(t *testing.T) {
+	assert.Equal(t, "users", Namespace("users/42"))
+	assert.Equal(t, "a", Namespace("a/b/c"))
+	assert.Equal(t, "", Namespace("plain"))
+	assert.Equal(t, "", Namespace("/leading"))
+}
+
+func TestScheduleRefresh(t *testing.T) {
+	pool := NewDataPool()
+	bucket := pool.Bucket("config")
+	bucket.Put("old")
+
+	err := <-pool.ScheduleRefresh("config", func() (any, error) {
+		return "new", nil
+	})
+	require.NoError(t, err)
+	val, _, _ := bucket.Get(0)
+	assert.Equal(t, "new", val)
+
+	failure := errors.New("source unavailable")
+	err = <-pool.ScheduleRefresh("config", func() (any, error) {
+		return nil, failure
+	})
+	assert.Equal(t, failure, err)
+	val, _, _ = bucket.Get(0)
+	assert.Equal(t, "new", val, "Failed refresh keeps the previous value")
+}
+
+// scheduleOrdered blocks the pool's only refresh worker, queues count refreshes
+// for each namespace and returns the order in which they ran.
+func scheduleOrdered(t *testing.T, pool *DataPool, counts map[string]int) []string {
+	gate := make(chan struct{})
+	gateDone := pool.ScheduleRefresh("gate", func() (any, error) {
+		<-gate
+		return nil, nil
+	})
+
+	var mu sync.Mutex
+	var order []string
+	var done []<-chan error
+	for _, ns := range []string{"big", "small"} {
+		for i := 0; i < counts[ns]; i++ {
+			ns := ns
+			done = append(done, pool.ScheduleRefresh(fmt.Sprintf("%s/%d", ns, i), func() (any, error) {
+				mu.Lock()
+				order = append(order, ns)
+				mu.Unlock()
+				return nil, nil
+			}))
+		}
+	}
+
+	close(gate)
+	require.NoError(t, <-gateDone)
+	for _, ch := range done {
+		require.NoError(t, <-ch)
+	}
+	return order
+}
+
+func TestRefreshFairSharing(t *testing.T) {
+	pool := NewDataPool(WithRefreshWorkers(1))
+
+	order := scheduleOrdered(t, pool, map[string]int{"big": 100, "small": 3})
+	require.Len(t, order, 103)
+	assert.Equal(t, []string{"big", "small", "big", "small", "big", "small"}, order[:6],
+		"Small namespace is interleaved instead of waiting behind the big backlog")
+}
+
+func TestRefreshWeightedSharing(t *testing.T) {
+	pool := NewDataPool(WithRefreshWorkers(1), WithNamespaceWeight("big", 3))
+
+	order := scheduleOrdered(t, pool, map[string]int{"big": 30, "small": 30})
+	counts := map[string]int{}
+	for _, ns := range order[:20] {
+		counts[ns]++
+	}
+	assert.Equal(t, 15, counts["big"], "Weight 3 gets three quarters of the workers")
+	assert.Equal(t, 5, counts["small"])
+}
+
+func TestRefreshWorkerLimit(t *testing.T) {
+	pool := NewDataPool(WithRefreshWorkers(2))
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	release := make(chan struct{})
+	var done []<-chan error
+	for i := 0; i < 6; i++ {
+		done = append(done, pool.ScheduleRefresh(fmt.Sprintf("job-%d", i), func() (any, error) {
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			mu.Unlock()
+			<-release
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil, nil
+		}))
+	}
+	close(release)
+	for _, ch := range done {
+		<-ch
+	}
+
+	assert.LessOrEqual(t, peak, 2)
+	assert.Eventually(t, func() bool {
+		pool.refresh.mu.Lock()
+		defer pool.refresh.mu.Unlock()
+		return pool.refresh.running == 0
+	}, time.Second, time.Millisecond, "Workers exit once the queue drains")
+}
@@ -0,0 +1,145 @@
+package datapool
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Refresher refreshes a bucket periodically on the pool's refresh workers
+// (see ScheduleRefresh). It is created by DataPool.NewRefresher.
+//
+// In adaptive mode (see WithAdaptiveInterval), the interval doubles every time
+// the loader returns the value the bucket already holds, and halves every
+// time it returns a different one, so buckets that rarely change are loaded
+// rarely and those that change often are followed closely.
+type Refresher struct {
+	pool *DataPool
+	name string
+	load LoadFunc
+
+	adaptive bool
+	min, max time.Duration
+
+	mu       sync.Mutex
+	interval time.Duration
+}
+
+// RefreshOption configures a Refresher created by NewRefresher.
+type RefreshOption func(*Refresher)
+
+// WithAdaptiveInterval adapts the refresh interval to how often the loaded
+// value changes, keeping it between min and max, which must be positive.
+// Values are compared with reflect.DeepEqual.
+func WithAdaptiveInterval(min, max time.Duration) RefreshOption {
+	return func(r *Refresher) {
+		r.adaptive = true
+		r.min = min
+		r.max = max
+	}
+}
+
+// NewRefresher returns a Refresher storing the values returned by load in the
+// bucket named name every interval, which must be positive, once Run is
+// called. In adaptive mode, interval is the starting interval, moved within
+// the bounds if needed.
+func (p *DataPool) NewRefresher(name string, load LoadFunc, interval time.Duration, opts ...RefreshOption) *Refresher {
+	r := &Refresher{pool: p, name: name, load: load, interval: interval}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.adaptive {
+		r.max = max(r.max, r.min)
+		r.interval = min(max(r.interval, r.min), r.max)
+	}
+	return r
+}
+
+// Interval returns the time Run waits between two refreshes.
+func (r *Refresher) Interval() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.interval
+}
+
+// Refresh runs the loader once on the pool's refresh workers and stores its
+// value, like ScheduleRefresh, and adapts the interval in adaptive mode. It
+// returns the loader's error, leaving the bucket and the interval unchanged,
+// or ctx.Err() if ctx is done before the loader has run.
+func (r *Refresher) Refresh(ctx context.Context) error {
+	p := r.pool
+	if err := p.checkWritable(r.name); err != nil {
+		return err
+	}
+	bk, err := p.bucket(r.name)
+	if err != nil {
+		return err
+	}
+	b := Bucket{pool: p, b: bk}
+
+	done := make(chan error, 1)
+	var changed bool
+	p.refresh.enqueue(Namespace(r.name), func() {
+		if p.isClosed() {
+			done <- ErrPoolClosed
+			return
+		}
+		b.b.guard.RLock()
+		old, _, _ := b.b.read(p, 0)
+		b.b.guard.RUnlock()
+
+		value, err := r.load()
+		if err == nil {
+			changed = !reflect.DeepEqual(old, value)
+			b.PutFrom(value, Source{Kind: SourceLoader, At: p.opts.clock.Now()})
+		}
+		done <- err
+	})
+
+	select {
+	case err := <-done:
+		if err == nil && r.adaptive {
+			r.adapt(changed)
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// adapt halves the interval after a change and doubles it otherwise, within
+// the bounds.
+func (r *Refresher) adapt(changed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if changed {
+		r.interval = max(r.interval/2, r.min)
+	} else {
+		r.interval = min(r.interval*2, r.max)
+	}
+}
+
+// Run calls Refresh every interval until ctx is done, then returns ctx.Err(),
+// or until the pool is closed, then returns ErrPoolClosed. In adaptive mode,
+// each wait uses the interval as adapted by the previous refresh. Loader
+// errors are passed to onError if it is not nil.
+func (r *Refresher) Run(ctx context.Context, onError func(error)) error {
+	timer := time.NewTimer(r.Interval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.pool.Done():
+			return ErrPoolClosed
+		case <-timer.C:
+			if err := r.Refresh(ctx); err != nil && ctx.Err() == nil && onError != nil {
+				onError(err)
+			}
+			timer.Reset(r.Interval())
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package refresher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCron(t *testing.T) {
+	// A Wednesday.
+	at := time.Date(2024, 5, 15, 10, 7, 30, 0, time.UTC)
+	for _, tt := range []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2024, 5, 15, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 5, 15, 10, 15, 0, 0, time.UTC)},
+		{"0 9-17 * * 1-5", time.Date(2024, 5, 15, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2024, 5, 16, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted.
+		{"0 0 20 * 5", time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC)},
+		{"5,10 10 * * *", time.Date(2024, 5, 15, 10, 10, 0, 0, time.UTC)},
+	} {
+		s, err := Cron(tt.spec)
+		require.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.next, s.Next(at), tt.spec)
+	}
+
+	never, err := Cron("0 0 30 2 *")
+	require.NoError(t, err)
+	assert.True(t, never.Next(at).IsZero(), "Specs that never match have no next time")
+}
+
+func TestCronErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		_, err := Cron(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestEvery(t *testing.T) {
+	at := time.Unix(1000, 0)
+	assert.Equal(t, at.Add(time.Minute), Every(time.Minute).Next(at))
+	assert.True(t, Every(0).Next(at).IsZero())
+}
@@ -0,0 +1,412 @@
+// Package refresher keeps buckets of a datapool.DataPool fresh: buckets are
+// registered with a function producing their value and a Schedule, an
+// interval or a cron spec, and a scheduler goroutine refreshes them when due,
+// with jitter, backoff on errors and a limit on concurrent refreshes, which
+// go to the buckets of highest priority first.
+//
+//	s := refresher.New(pool, refresher.WithConcurrency(8))
+//	s.Register("prices/EURUSD", fetchEURUSD, refresher.Every(time.Minute))
+//	go s.Run(ctx)
+package refresher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/radamsa/datapool"
+)
+
+// Defaults of the options of New.
+const (
+	DefaultConcurrency = 4
+	DefaultJitter      = 0.1
+	DefaultMinBackoff  = time.Second
+	DefaultMaxBackoff  = 5 * time.Minute
+)
+
+// Func produces the value of a bucket. ctx is done when the scheduler stops
+// or the refresh times out (see WithTimeout).
+type Func func(ctx context.Context) (any, error)
+
+// ErrRunning is returned by Run when the scheduler is already running.
+var ErrRunning = errors.New("refresher: already running")
+
+// ErrNotRegistered is returned by Trigger for a bucket that is not
+// registered.
+var ErrNotRegistered = errors.New("refresher: bucket not registered")
+
+// Option configures a Scheduler.
+type Option func(*Scheduler)
+
+// WithConcurrency sets how many refreshes may run at once. The default is
+// DefaultConcurrency.
+func WithConcurrency(n int) Option {
+	return func(s *Scheduler) {
+		if n > 0 {
+			s.concurrency = n
+		}
+	}
+}
+
+// WithJitter delays every scheduled refresh by a random share, up to
+// fraction, of the time until it is due, so buckets registered together do
+// not hit their sources together. The default is DefaultJitter; zero
+// disables jitter.
+func WithJitter(fraction float64) Option {
+	return func(s *Scheduler) {
+		s.jitter = min(max(fraction, 0), 1)
+	}
+}
+
+// WithBackoff sets how a failed refresh is retried: after minDelay, doubling
+// with every failure in a row, up to maxDelay, instead of on the bucket's schedule,
+// which resumes once a refresh succeeds. The defaults are DefaultMinBackoff
+// and DefaultMaxBackoff.
+func WithBackoff(minDelay, maxDelay time.Duration) Option {
+	return func(s *Scheduler) {
+		if minDelay > 0 {
+			s.minBackoff = minDelay
+			s.maxBackoff = max(maxDelay, minDelay)
+		}
+	}
+}
+
+// WithErrorHandler sets a function receiving the error of every failed
+// refresh. Errors are dropped by default; the last one of each bucket is in
+// its Status.
+func WithErrorHandler(fn func(bucket string, err error)) Option {
+	return func(s *Scheduler) {
+		s.onError = fn
+	}
+}
+
+// RegisterOption configures a bucket registered with Register.
+type RegisterOption func(*job)
+
+// WithPriority sets the priority of the bucket's refreshes. When more
+// refreshes are due than may run at once, those of higher priority run
+// first. The default is zero.
+func WithPriority(priority int) RegisterOption {
+	return func(j *job) {
+		j.priority = priority
+	}
+}
+
+// WithTimeout bounds each refresh of the bucket: the context passed to its
+// Func is done after d. Refreshes have no timeout by default.
+func WithTimeout(d time.Duration) RegisterOption {
+	return func(j *job) {
+		j.timeout = d
+	}
+}
+
+// Scheduler refreshes the buckets registered with it while Run runs. It is
+// created by New.
+type Scheduler struct {
+	pool        *datapool.DataPool
+	concurrency int
+	jitter      float64
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	onError     func(bucket string, err error)
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	running bool
+	// wake is signaled when a job is registered, triggered or done, for Run
+	// to look at the jobs again.
+	wake chan struct{}
+}
+
+// job is a registered bucket.
+type job struct {
+	name     string
+	fn       Func
+	schedule Schedule
+	priority int
+	timeout  time.Duration
+
+	next   time.Time
+	active bool
+	// triggered is set by a Trigger while the job runs.
+	triggered bool
+	failures  int
+	lastRun   time.Time
+	lastErr   error
+}
+
+// Status is the state of a registered bucket's refreshes.
+type Status struct {
+	// Next is when the bucket is refreshed next, once the running refresh
+	// is done if Running.
+	Next    time.Time
+	Running bool
+	// LastRun is when the last refresh ended, and LastError its error.
+	LastRun   time.Time
+	LastError error
+	// Failures counts the refreshes that failed in a row.
+	Failures int
+}
+
+// New returns a Scheduler refreshing buckets of pool.
+func New(pool *datapool.DataPool, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		pool:        pool,
+		concurrency: DefaultConcurrency,
+		jitter:      DefaultJitter,
+		minBackoff:  DefaultMinBackoff,
+		maxBackoff:  DefaultMaxBackoff,
+		jobs:        make(map[string]*job),
+		wake:        make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Register makes the scheduler refresh the named bucket with the value fn
+// returns, on schedule, replacing any earlier registration of the bucket; a
+// refresh of it already running completes. The first refresh is due at once,
+// so the bucket fills as soon as Run runs.
+//
+// Values are stored with Bucket.PutFrom, recording a SourceLoader step in
+// their provenance; the pool reports the errors of values it rejects to its
+// error handler. A failed refresh leaves the bucket's value as it was.
+// Register fails if the pool's NameRules reject name, if it is in the
+// SystemNamespace, or if schedule gives no time after now.
+func (s *Scheduler) Register(name string, fn Func, schedule Schedule, opts ...RegisterOption) error {
+	if err := s.pool.ValidateName(name); err != nil {
+		return fmt.Errorf("refresher: register %q: %w", name, err)
+	}
+	if strings.HasPrefix(name, datapool.SystemNamespace) {
+		return fmt.Errorf("refresher: register %q: %w", name, datapool.ErrSystemBucket)
+	}
+	if fn == nil || schedule == nil {
+		return fmt.Errorf("refresher: register %q: no func or schedule", name)
+	}
+	now := time.Now()
+	if next := schedule.Next(now); !next.After(now) {
+		return fmt.Errorf("refresher: register %q: schedule has no time after now", name)
+	}
+
+	j := &job{name: name, fn: fn, schedule: schedule, next: now}
+	for _, opt := range opts {
+		opt(j)
+	}
+	s.mu.Lock()
+	if old := s.jobs[name]; old != nil {
+		j.active = old.active
+	}
+	s.jobs[name] = j
+	s.mu.Unlock()
+	s.signal()
+	return nil
+}
+
+// Unregister stops refreshing the named bucket. A refresh of it already
+// running completes.
+func (s *Scheduler) Unregister(name string) {
+	s.mu.Lock()
+	delete(s.jobs, name)
+	s.mu.Unlock()
+}
+
+// Trigger makes the named bucket's next refresh due at once, or right after
+// the one running.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	j := s.jobs[name]
+	switch {
+	case j == nil:
+	case j.active:
+		j.triggered = true
+	default:
+		j.next = time.Now()
+	}
+	s.mu.Unlock()
+	if j == nil {
+		return fmt.Errorf("%w: %q", ErrNotRegistered, name)
+	}
+	s.signal()
+	return nil
+}
+
+// Status returns the state of the named bucket's refreshes, and whether it
+// is registered.
+func (s *Scheduler) Status(name string) (Status, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := s.jobs[name]
+	if j == nil {
+		return Status{}, false
+	}
+	return Status{Next: j.next, Running: j.active, LastRun: j.lastRun, LastError: j.lastErr, Failures: j.failures}, true
+}
+
+func (s *Scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run refreshes the registered buckets as they are due until ctx is done or
+// the pool is closed (see datapool.DataPool.Close), then waits for the
+// refreshes running, whose context is done too, and returns ctx.Err(), or an
+// error wrapping datapool.ErrPoolClosed. It fails with ErrRunning if the
+// scheduler is already running.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return ErrRunning
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go func() {
+		select {
+		case <-s.pool.Done():
+			cancel(fmt.Errorf("refresher: %w", datapool.ErrPoolClosed))
+		case <-ctx.Done():
+		}
+	}()
+
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	active := 0
+	done := make(chan *job)
+	for {
+		due, wait := s.due(s.concurrency - active)
+		for _, j := range due {
+			active++
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.refresh(ctx, j)
+				select {
+				case done <- j:
+				case <-ctx.Done():
+				}
+			}()
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-done:
+			active--
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// due marks active and returns the jobs that are due, up to free, highest
+// priority first, along with how long to wait before the next one is.
+func (s *Scheduler) due(free int) ([]*job, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var due []*job
+	wait := time.Hour
+	for _, j := range s.jobs {
+		if j.active {
+			continue
+		}
+		if d := j.next.Sub(now); d > 0 {
+			wait = min(wait, d)
+			continue
+		}
+		due = append(due, j)
+	}
+	slices.SortFunc(due, func(a, b *job) int {
+		if a.priority != b.priority {
+			return b.priority - a.priority
+		}
+		return a.next.Compare(b.next)
+	})
+	if len(due) > max(free, 0) {
+		due = due[:max(free, 0)]
+	}
+	for _, j := range due {
+		j.active, j.triggered = true, false
+	}
+	return due, wait
+}
+
+// refresh runs j's Func, stores its value and schedules j's next refresh.
+func (s *Scheduler) refresh(ctx context.Context, j *job) {
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+		defer cancel()
+	}
+	value, err := j.fn(ctx)
+	if err == nil {
+		b := s.pool.Bucket(j.name)
+		b.PutFrom(value, datapool.Source{Kind: datapool.SourceLoader, At: time.Now()})
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	j.active = false
+	j.lastRun, j.lastErr = now, err
+	j.next = s.nextRun(j, now, err)
+	if j.triggered {
+		j.next, j.triggered = now, false
+	}
+	if current := s.jobs[j.name]; current != nil && current != j && current.active {
+		// j was replaced while it ran; the new registration may run now.
+		current.active = false
+	}
+	s.mu.Unlock()
+
+	if err != nil && s.onError != nil && ctx.Err() == nil {
+		s.onError(j.name, err)
+	}
+}
+
+// nextRun returns when j runs next after a refresh ending at now with err.
+// It must be called with s.mu held.
+func (s *Scheduler) nextRun(j *job, now time.Time, err error) time.Time {
+	if err != nil {
+		j.failures++
+		backoff := s.minBackoff << min(j.failures-1, 30)
+		if backoff <= 0 || backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+		return now.Add(backoff)
+	}
+	j.failures = 0
+	next := j.schedule.Next(now)
+	if s.jitter > 0 {
+		next = next.Add(time.Duration(rand.Float64() * s.jitter * float64(next.Sub(now))))
+	}
+	return next
+}
@@ -0,0 +1,187 @@
+package refresher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radamsa/datapool"
+)
+
+// start runs s until the test ends.
+func start(t *testing.T, s *Scheduler) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.ErrorIs(t, <-done, context.Canceled)
+	})
+}
+
+func TestRefresh(t *testing.T) {
+	pool := datapool.NewDataPool()
+	s := New(pool, WithJitter(0))
+	var calls atomic.Int64
+	require.NoError(t, s.Register("config", func(context.Context) (any, error) {
+		return calls.Add(1), nil
+	}, Every(10*time.Millisecond)))
+	start(t, s)
+
+	b := pool.Bucket("config")
+	require.Eventually(t, func() bool {
+		value, _, _ := b.Get(0)
+		n, _ := value.(int64)
+		return n >= 3
+	}, time.Second, time.Millisecond, "The bucket is refreshed on schedule")
+	assert.Equal(t, datapool.SourceLoader, b.Provenance()[0].Kind)
+
+	st, ok := s.Status("config")
+	require.True(t, ok)
+	assert.NoError(t, st.LastError)
+	assert.False(t, st.LastRun.IsZero())
+
+	s.Unregister("config")
+	_, ok = s.Status("config")
+	assert.False(t, ok)
+}
+
+func TestBackoff(t *testing.T) {
+	pool := datapool.NewDataPool()
+	pool.Handle("rates").Put("previous")
+	failed := errors.New("upstream down")
+	var reported atomic.Int64
+	s := New(pool, WithBackoff(time.Hour, 4*time.Hour), WithErrorHandler(func(bucket string, err error) {
+		assert.Equal(t, "rates", bucket)
+		assert.ErrorIs(t, err, failed)
+		reported.Add(1)
+	}))
+	require.NoError(t, s.Register("rates", func(context.Context) (any, error) {
+		return nil, failed
+	}, Every(time.Millisecond)))
+	start(t, s)
+
+	require.Eventually(t, func() bool { return reported.Load() == 1 }, time.Second, time.Millisecond)
+	st, _ := s.Status("rates")
+	assert.Equal(t, 1, st.Failures)
+	assert.ErrorIs(t, st.LastError, failed)
+	assert.WithinDuration(t, st.LastRun.Add(time.Hour), st.Next, time.Millisecond, "Failures are retried after the backoff")
+	value, _, _ := pool.Handle("rates").Get(0)
+	assert.Equal(t, "previous", value, "Failed refreshes keep the value")
+
+	require.NoError(t, s.Trigger("rates"))
+	require.Eventually(t, func() bool { return reported.Load() == 2 }, time.Second, time.Millisecond)
+	st, _ = s.Status("rates")
+	assert.WithinDuration(t, st.LastRun.Add(2*time.Hour), st.Next, time.Millisecond, "The backoff doubles")
+}
+
+func TestPriority(t *testing.T) {
+	pool := datapool.NewDataPool()
+	s := New(pool, WithConcurrency(1))
+	var mu sync.Mutex
+	var order []string
+	for i, name := range []string{"low", "high", "mid"} {
+		require.NoError(t, s.Register(name, func(context.Context) (any, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			return name, nil
+		}, Every(time.Hour), WithPriority([]int{0, 10, 5}[i])))
+	}
+	start(t, s)
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(order) == 3
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"high", "mid", "low"}, order)
+}
+
+func TestConcurrency(t *testing.T) {
+	pool := datapool.NewDataPool()
+	s := New(pool, WithConcurrency(2))
+	var running, peak atomic.Int64
+	release := make(chan struct{})
+	for _, name := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, s.Register(name, func(ctx context.Context) (any, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				if p := peak.Load(); n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+			return 1, nil
+		}, Every(time.Hour), WithTimeout(time.Second)))
+	}
+	start(t, s)
+
+	require.Eventually(t, func() bool { return running.Load() == 2 }, time.Second, time.Millisecond)
+	close(release)
+	require.Eventually(t, func() bool {
+		for _, name := range []string{"a", "b", "c", "d"} {
+			if _, ts, _ := pool.Handle(name).Get(0); ts == 0 {
+				return false
+			}
+		}
+		return true
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int64(2), peak.Load())
+}
+
+func TestRegisterErrors(t *testing.T) {
+	pool := datapool.NewDataPool()
+	s := New(pool)
+	fn := func(context.Context) (any, error) { return 1, nil }
+	assert.ErrorIs(t, s.Register(datapool.SystemNamespace+"stats", fn, Every(time.Second)), datapool.ErrSystemBucket)
+	strict := New(datapool.NewDataPool(datapool.WithNameRules(datapool.NameRules{MaxLength: 3})))
+	assert.ErrorIs(t, strict.Register("toolong", fn, Every(time.Second)), datapool.ErrInvalidName)
+	assert.Error(t, s.Register("a", nil, Every(time.Second)))
+	assert.Error(t, s.Register("a", fn, Every(0)))
+	assert.ErrorIs(t, s.Trigger("missing"), ErrNotRegistered)
+
+	start(t, s)
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.running
+	}, time.Second, time.Millisecond)
+	assert.ErrorIs(t, s.Run(context.Background()), ErrRunning)
+}
+
+func TestRunStopsOnClose(t *testing.T) {
+	pool := datapool.NewDataPool()
+	s := New(pool)
+	stopped := make(chan struct{})
+	require.NoError(t, s.Register("config", func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		close(stopped)
+		return nil, ctx.Err()
+	}, Every(time.Hour)))
+	done := make(chan error, 1)
+	go func() { done <- s.Run(context.Background()) }()
+	require.Eventually(t, func() bool {
+		st, _ := s.Status("config")
+		return st.Running
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, pool.Close(context.Background()))
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, datapool.ErrPoolClosed)
+	case <-time.After(time.Second):
+		require.FailNow(t, "Run returns once the pool is closed")
+	}
+	<-stopped
+}
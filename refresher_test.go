@@ -0,0 +1,99 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefresher(t *testing.T) {
+	pool := NewDataPool()
+	n := 0
+	r := pool.NewRefresher("counter", func() (any, error) {
+		n++
+		return n, nil
+	}, time.Minute)
+
+	require.NoError(t, r.Refresh(context.Background()))
+	require.NoError(t, r.Refresh(context.Background()))
+	b := pool.Bucket("counter")
+	value, _, _ := b.Get(0)
+	assert.Equal(t, 2, value)
+	assert.Equal(t, SourceLoader, b.Provenance()[0].Kind)
+	assert.Equal(t, time.Minute, r.Interval(), "Fixed intervals do not adapt")
+}
+
+func TestRefresherAdaptive(t *testing.T) {
+	pool := NewDataPool()
+	value := "a"
+	r := pool.NewRefresher("config", func() (any, error) {
+		return value, nil
+	}, 10*time.Second, WithAdaptiveInterval(time.Second, time.Minute))
+	ctx := context.Background()
+
+	require.NoError(t, r.Refresh(ctx))
+	assert.Equal(t, 5*time.Second, r.Interval(), "A first value is a change")
+
+	for _, want := range []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute} {
+		require.NoError(t, r.Refresh(ctx))
+		assert.Equal(t, want, r.Interval(), "Unchanged values lengthen the interval up to the maximum")
+	}
+
+	for _, want := range []time.Duration{30 * time.Second, 15 * time.Second, 7500 * time.Millisecond} {
+		value += "a"
+		require.NoError(t, r.Refresh(ctx))
+		assert.Equal(t, want, r.Interval(), "Changes shorten the interval")
+	}
+	for range 5 {
+		value += "a"
+		require.NoError(t, r.Refresh(ctx))
+	}
+	assert.Equal(t, time.Second, r.Interval(), "Down to the minimum")
+}
+
+func TestRefresherBounds(t *testing.T) {
+	pool := NewDataPool()
+	load := func() (any, error) { return 1, nil }
+	assert.Equal(t, time.Second, pool.NewRefresher("a", load, time.Millisecond, WithAdaptiveInterval(time.Second, time.Minute)).Interval())
+	assert.Equal(t, time.Minute, pool.NewRefresher("b", load, time.Hour, WithAdaptiveInterval(time.Second, time.Minute)).Interval())
+}
+
+func TestRefresherError(t *testing.T) {
+	pool := NewDataPool()
+	failure := errors.New("unavailable")
+	r := pool.NewRefresher("config", func() (any, error) {
+		return nil, failure
+	}, 10*time.Second, WithAdaptiveInterval(time.Second, time.Minute))
+
+	assert.ErrorIs(t, r.Refresh(context.Background()), failure)
+	assert.Equal(t, 10*time.Second, r.Interval(), "Failures leave the interval alone")
+
+	invalid := pool.NewRefresher(SystemStatsBucket, func() (any, error) { return 1, nil }, time.Second)
+	assert.ErrorIs(t, invalid.Refresh(context.Background()), ErrSystemBucket)
+}
+
+func TestRefresherRun(t *testing.T) {
+	pool := NewDataPool()
+	loaded := make(chan struct{}, 1)
+	r := pool.NewRefresher("config", func() (any, error) {
+		select {
+		case loaded <- struct{}{}:
+		default:
+		}
+		return "v", nil
+	}, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx, nil) }()
+
+	<-loaded
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	value, _, _ := pool.Handle("config").Get(0)
+	assert.Equal(t, "v", value)
+}
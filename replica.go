@@ -0,0 +1,128 @@
+package datapool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaView serves reads from a local copy of a pool that is refreshed by
+// Refresh or Run. Reads are answered from the copy only while it is at most
+// maxStaleness old; otherwise they fall back to the pool itself, so a result is
+// never older than the bound. This keeps read traffic off pools whose reads
+// are expensive, such as remote-backed ones.
+type ReplicaView struct {
+	pool         *DataPool
+	maxStaleness time.Duration
+
+	mu       sync.RWMutex
+	copy     map[string]replicaEntry
+	copiedAt int64
+
+	fallbacks atomic.Uint64
+}
+
+type replicaEntry struct {
+	value     any
+	timestamp int64
+	// copies is set for buckets that copy values (see SetCopyValues).
+	copies bool
+}
+
+// ReplicaView returns a view serving reads at most maxStaleness old. The view
+// starts without a copy, so reads fall back to the pool until the first
+// Refresh.
+func (p *DataPool) ReplicaView(maxStaleness time.Duration) *ReplicaView {
+	return &ReplicaView{
+		pool:         p,
+		maxStaleness: maxStaleness,
+	}
+}
+
+// Refresh replaces the local copy with the current contents of the pool.
+func (v *ReplicaView) Refresh() {
+	// The copy is as old as the moment copying started.
+	copiedAt := v.pool.now()
+
+	entries := make(map[string]replicaEntry)
+	for _, b := range v.pool.all() {
+		b.guard.RLock()
+		value, ts := b.readValue(v.pool)
+		b.guard.RUnlock()
+		entries[b.name()] = replicaEntry{value: value, timestamp: ts, copies: b.copyValues.Load()}
+	}
+
+	v.mu.Lock()
+	v.copy = entries
+	v.copiedAt = copiedAt
+	v.mu.Unlock()
+}
+
+// Run refreshes the copy every interval until ctx is done, then returns
+// ctx.Err(). The interval should be shorter than maxStaleness, or reads will
+// regularly fall back to the pool.
+func (v *ReplicaView) Run(ctx context.Context, interval time.Duration) error {
+	v.Refresh()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			v.Refresh()
+		}
+	}
+}
+
+// Age returns the age of the local copy, or false if there is none yet.
+func (v *ReplicaView) Age() (time.Duration, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.copy == nil {
+		return 0, false
+	}
+	return time.Duration(v.pool.now() - v.copiedAt), true
+}
+
+// Get returns the value of the named bucket like Bucket.Get. Buckets missing
+// or empty in a fresh enough copy are read from the pool, and so from its
+// backend or loader, if the pool has the bucket or would fill it; otherwise
+// they read as empty without being created.
+func (v *ReplicaView) Get(name string, timestamp int64) (any, int64, bool) {
+	v.mu.RLock()
+	current := v.copy != nil && time.Duration(v.pool.now()-v.copiedAt) <= v.maxStaleness
+	entry, ok := v.copy[name]
+	v.mu.RUnlock()
+
+	if !current || ((!ok || entry.timestamp == 0) && v.readsThrough(name)) {
+		v.fallbacks.Add(1)
+		b := v.pool.Bucket(name)
+		return b.Get(timestamp)
+	}
+	if !ok || entry.timestamp == 0 {
+		return nil, 0, false
+	}
+	value := entry.value
+	if entry.copies {
+		value = copyValue(value)
+	}
+	return value, entry.timestamp, entry.timestamp > timestamp
+}
+
+// readsThrough reports whether the pool may have a value for the named bucket
+// that the copy lacks: the bucket was created since the copy was made, or the
+// pool reads through to a backend or loader.
+func (v *ReplicaView) readsThrough(name string) bool {
+	return v.pool.find(name) != nil || v.pool.opts.backend != nil || v.pool.opts.loader != nil
+}
+
+// Fallbacks returns how many reads were served by the pool because the local
+// copy was missing or too old.
+func (v *ReplicaView) Fallbacks() uint64 {
+	return v.fallbacks.Load()
+}
@@ -0,0 +1,110 @@
+package datapool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicaViewServesCopy(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	config := pool.Bucket("config")
+	ts := config.Put("v1")
+
+	view := pool.ReplicaView(time.Minute)
+	view.Refresh()
+	config.Put("v2")
+
+	val, ts2, fresh := view.Get("config", 0)
+	assert.Equal(t, "v1", val, "Reads come from the copy while it is fresh enough")
+	assert.Equal(t, ts, ts2)
+	assert.True(t, fresh)
+	assert.Equal(t, uint64(0), view.Fallbacks())
+
+	val, _, _ = view.Get("missing", 0)
+	assert.Nil(t, val)
+	assert.Equal(t, 1, pool.Len(), "Reading a missing bucket does not create it")
+
+	age, ok := view.Age()
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), age)
+}
+
+func TestReplicaViewFallsBackWhenStale(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	config := pool.Bucket("config")
+	config.Put("v1")
+
+	view := pool.ReplicaView(time.Minute)
+	val, _, _ := view.Get("config", 0)
+	assert.Equal(t, "v1", val, "Without a copy reads fall back to the pool")
+	_, ok := view.Age()
+	assert.False(t, ok)
+
+	view.Refresh()
+	config.Put("v2")
+	clock.Advance(time.Minute + time.Nanosecond)
+
+	val, _, _ = view.Get("config", 0)
+	assert.Equal(t, "v2", val, "A copy older than the bound is not used")
+	assert.Equal(t, uint64(2), view.Fallbacks())
+
+	view.Refresh()
+	config.Put("v3")
+	val, _, _ = view.Get("config", 0)
+	assert.Equal(t, "v2", val)
+}
+
+func TestReplicaViewFallsBackForMissing(t *testing.T) {
+	backend := &MemoryBackend{}
+	writer := NewDataPool(WithBackend(backend))
+	pool := NewDataPool(WithBackend(backend))
+	view := pool.ReplicaView(time.Minute)
+	view.Refresh()
+
+	config := writer.Bucket("config")
+	ts := config.Put("v1")
+	val, got, fresh := view.Get("config", 0)
+	assert.Equal(t, "v1", val, "Buckets missing from the copy read through to the backend")
+	assert.Equal(t, ts, got)
+	assert.True(t, fresh)
+	assert.Equal(t, uint64(1), view.Fallbacks())
+
+	local := NewDataPool()
+	view = local.ReplicaView(time.Minute)
+	view.Refresh()
+	flags := local.Bucket("flags")
+	flags.Put("on")
+	val, _, _ = view.Get("flags", 0)
+	assert.Equal(t, "on", val, "Buckets created since the copy are read from the pool")
+
+	loaded := NewDataPool(WithLoader(func(name string) (any, error) { return "loaded " + name, nil }))
+	view = loaded.ReplicaView(time.Minute)
+	view.Refresh()
+	val, _, _ = view.Get("rates", 0)
+	assert.Equal(t, "loaded rates", val, "Buckets missing from the copy are loaded")
+}
+
+func TestReplicaViewRun(t *testing.T) {
+	pool := NewDataPool()
+	config := pool.Bucket("config")
+	config.Put("v1")
+
+	view := pool.ReplicaView(time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- view.Run(ctx, time.Millisecond) }()
+
+	config.Put("v2")
+	assert.Eventually(t, func() bool {
+		val, _, _ := view.Get("config", 0)
+		return val == "v2"
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,111 @@
+package datapool
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// SchemaError is returned by GetSchema when the bucket holds a value of a
+// schema version the reader does not accept.
+type SchemaError struct {
+	Bucket string
+	// Version is the schema version of the value, zero if it has none.
+	Version int
+	// Accepted lists the versions the reader accepts.
+	Accepted []int
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("datapool: bucket %q holds schema version %d, reader accepts %v", e.Bucket, e.Version, e.Accepted)
+}
+
+// SchemaMismatchRecorder is implemented by MetricsRecorders that count schema
+// mismatches. RecordSchemaMismatch is called, outside of pool locks, for every
+// GetSchema failing with a *SchemaError.
+type SchemaMismatchRecorder interface {
+	RecordSchemaMismatch(bucket string, version int)
+}
+
+// PutSchema stores value tagged with a schema version, which readers check
+// with GetSchema, and returns its timestamp. The tag travels with the value
+// through backends; values stored otherwise have version zero.
+func (b *Bucket) PutSchema(value any, version int) int64 {
+	bk := b.resolve("put schema")
+	if bk == nil {
+		return 0
+	}
+	u, err := b.pool.writeAt(context.Background(), bk, Update{Value: value, Schema: version}, 0, ConsistencyDefault)
+	if err != nil {
+		b.pool.reportError(bk.name(), err)
+	}
+	return u.Timestamp
+}
+
+// Schema returns the schema version of the bucket's value, zero if it has
+// none or the bucket is empty.
+func (b *Bucket) Schema() int {
+	bk := b.resolve("schema")
+	if bk == nil {
+		return 0
+	}
+
+	bk.guard.RLock()
+	defer bk.guard.RUnlock()
+
+	if bk.readTimestamp(b.pool) == 0 {
+		return 0
+	}
+	return bk.schema
+}
+
+// GetSchema is Get for readers that only understand some schema versions.
+// If the bucket holds a value whose version is not in accept, it returns a
+// nil value with the value's timestamp and a *SchemaError, and counts the
+// mismatch (see SchemaMismatches). Untagged values have version zero, so
+// readers accepting them must list it. An empty bucket is not a mismatch.
+func (b *Bucket) GetSchema(timestamp int64, accept ...int) (any, int64, bool, error) {
+	bk := b.resolve("get schema")
+	if bk == nil {
+		return nil, timestamp, false, nil
+	}
+
+	var value any
+	var ts int64
+	var fresh bool
+	var version int
+	for {
+		value, ts, fresh = b.pool.get(bk, timestamp, ConsistencyDefault)
+		if ts == 0 {
+			return value, ts, fresh, nil
+		}
+
+		// The version is read apart from the value, so read again if the
+		// value changed in between.
+		bk.guard.RLock()
+		current, removed := bk.timestamp == ts, bk.removed
+		version = bk.schema
+		bk.guard.RUnlock()
+		if removed {
+			return nil, timestamp, false, nil
+		}
+		if current {
+			break
+		}
+	}
+
+	if slices.Contains(accept, version) {
+		return value, ts, fresh, nil
+	}
+	b.pool.schemaMismatches.Add(1)
+	if r, ok := b.pool.opts.metrics.(SchemaMismatchRecorder); ok {
+		r.RecordSchemaMismatch(bk.name(), version)
+	}
+	return nil, ts, false, &SchemaError{Bucket: bk.name(), Version: version, Accepted: slices.Clone(accept)}
+}
+
+// SchemaMismatches returns how many reads GetSchema rejected because of the
+// value's schema version.
+func (p *DataPool) SchemaMismatches() uint64 {
+	return p.schemaMismatches.Load()
+}
@@ -0,0 +1,92 @@
+package datapool
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type schemaRecorder struct {
+	*recordingMetrics
+	mismatches []int
+}
+
+func (r *schemaRecorder) RecordSchemaMismatch(bucket string, version int) {
+	r.mismatches = append(r.mismatches, version)
+}
+
+func TestGetSchema(t *testing.T) {
+	recorder := &schemaRecorder{recordingMetrics: newRecordingMetrics()}
+	pool := NewDataPool(WithMetrics(recorder))
+	users := pool.Bucket("users")
+
+	val, ts, _, err := users.GetSchema(0, 2)
+	require.NoError(t, err, "An empty bucket is not a mismatch")
+	assert.Nil(t, val)
+	assert.Zero(t, ts)
+
+	stored := users.PutSchema("v2", 2)
+	assert.Equal(t, 2, users.Schema())
+	val, ts, fresh, err := users.GetSchema(0, 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", val)
+	assert.Equal(t, stored, ts)
+	assert.True(t, fresh)
+
+	users.PutSchema("v3", 3)
+	val, ts, fresh, err = users.GetSchema(0, 1, 2)
+	var schemaErr *SchemaError
+	require.ErrorAs(t, err, &schemaErr)
+	assert.Equal(t, &SchemaError{Bucket: "users", Version: 3, Accepted: []int{1, 2}}, schemaErr)
+	assert.EqualError(t, err, `datapool: bucket "users" holds schema version 3, reader accepts [1 2]`)
+	assert.Nil(t, val, "Mismatched values are not returned")
+	assert.NotZero(t, ts)
+	assert.False(t, fresh)
+
+	users.Put("plain")
+	assert.Zero(t, users.Schema(), "Values stored otherwise are untagged")
+	_, _, _, err = users.GetSchema(0, 2)
+	assert.ErrorAs(t, err, &schemaErr)
+	val, _, _, err = users.GetSchema(0, 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "plain", val)
+
+	assert.Equal(t, uint64(2), pool.SchemaMismatches())
+	assert.Equal(t, []int{3, 0}, recorder.mismatches)
+
+	var buf bytes.Buffer
+	require.NoError(t, pool.WriteOpenMetrics(&buf, 0))
+	assert.Equal(t, 2.0, parseSamples(t, buf.String())["datapool_schema_mismatches_total"])
+}
+
+func TestSchemaThroughBackend(t *testing.T) {
+	backend := &MemoryBackend{}
+	producer := NewDataPool(WithBackend(backend))
+	consumer := NewDataPool(WithBackend(backend))
+
+	p := producer.Bucket("events")
+	p.PutSchema("e", 4)
+	u, err := backend.Get(context.Background(), "events")
+	require.NoError(t, err)
+	assert.Equal(t, 4, u.Schema)
+
+	c := consumer.Bucket("events")
+	_, _, _, err = c.GetSchema(0, 3)
+	var schemaErr *SchemaError
+	require.ErrorAs(t, err, &schemaErr)
+	assert.Equal(t, 4, schemaErr.Version, "Versions travel through the backend")
+}
+
+func TestSchemaWatch(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("events")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := b.Watch(ctx)
+
+	b.PutSchema("e", 7)
+	assert.Equal(t, 7, (<-updates).Schema)
+}
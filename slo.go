@@ -0,0 +1,159 @@
+package datapool
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// DefaultSLOWindow is the window over which a FreshnessSLO without one is
+// measured.
+const DefaultSLOWindow = time.Hour
+
+// sloSlots is the number of slots the window of a FreshnessSLO is divided
+// into: reads leave the window one slot at a time.
+const sloSlots = 60
+
+// FreshnessSLO is an objective for the freshness of the values a bucket's
+// reads return, such as 99% of reads returning a value updated within the
+// last 60 seconds, declared with Bucket.SetFreshnessSLO.
+type FreshnessSLO struct {
+	// MaxAge is the age, on the pool's clock, up to which a value counts as
+	// fresh.
+	MaxAge time.Duration
+	// Target is the share of reads, from 0 to 1, that should return a fresh
+	// value.
+	Target float64
+	// Window is how far back reads are counted, DefaultSLOWindow if zero.
+	Window time.Duration
+}
+
+// SLOStatus is how a bucket meets its FreshnessSLO, as reported by
+// Bucket.Stats, over the reads of the SLO's window.
+type SLOStatus struct {
+	SLO FreshnessSLO
+	// Reads counts the reads in the window, and FreshReads those that
+	// returned a value no older than SLO.MaxAge. Reads of an empty bucket
+	// count against the SLO.
+	Reads      uint64
+	FreshReads uint64
+}
+
+// Attainment returns the share of reads in the window that returned a fresh
+// value, or 1 if there were none.
+func (s SLOStatus) Attainment() float64 {
+	if s.Reads == 0 {
+		return 1
+	}
+	return float64(s.FreshReads) / float64(s.Reads)
+}
+
+// Met reports whether the attainment reaches the SLO's target.
+func (s SLOStatus) Met() bool {
+	return s.Attainment() >= s.SLO.Target
+}
+
+// SetFreshnessSLO declares a freshness objective for the bucket and starts
+// tracking how its reads meet it, from Get, GetMany and the Get variants,
+// replacing any earlier SLO and its counts. Bucket.Stats reports the
+// attainment and WriteOpenMetrics exports it, so teams can alert on the
+// quality of what the cache serves rather than on the raw age of its values.
+// A MaxAge of zero or less clears the SLO; a Target outside 0 to 1 is
+// clamped.
+func (b *Bucket) SetFreshnessSLO(slo FreshnessSLO) {
+	bk := b.resolve("set freshness slo")
+	if bk == nil {
+		return
+	}
+	if slo.MaxAge <= 0 {
+		bk.slo.Store(nil)
+		return
+	}
+	slo.Target = min(max(slo.Target, 0), 1)
+	if slo.Window <= 0 {
+		slo.Window = DefaultSLOWindow
+	}
+	bk.slo.Store(&sloTracker{slo: slo, slot: max(int64(slo.Window)/sloSlots, 1)})
+}
+
+// FreshnessSLO returns the bucket's freshness objective, and whether it has
+// one.
+func (b *Bucket) FreshnessSLO() (FreshnessSLO, bool) {
+	bk := b.resolve("freshness slo")
+	if bk == nil {
+		return FreshnessSLO{}, false
+	}
+	if t := bk.slo.Load(); t != nil {
+		return t.slo, true
+	}
+	return FreshnessSLO{}, false
+}
+
+// sloTracker counts the reads of a bucket with a FreshnessSLO in rings of
+// slots covering its window, one per stripe so that concurrent Gets rarely
+// take the same lock.
+type sloTracker struct {
+	slo FreshnessSLO
+	// slot is the length of a slot in nanoseconds.
+	slot int64
+
+	stripes [readStripes]sloStripe
+}
+
+type sloStripe struct {
+	mu    sync.Mutex
+	slots [sloSlots]sloSlot
+}
+
+type sloSlot struct {
+	// epoch numbers the slot's interval since the Unix epoch.
+	epoch int64
+	reads uint64
+	fresh uint64
+}
+
+// recordSLO counts a read of b that returned timestamp ts against the
+// bucket's SLO, if it has one.
+func (p *DataPool) recordSLO(b *bucket, ts int64) {
+	t := b.slo.Load()
+	if t == nil {
+		return
+	}
+	now := p.now()
+	t.record(now, ts != 0 && now-ts <= int64(t.slo.MaxAge))
+}
+
+func (t *sloTracker) record(now int64, fresh bool) {
+	epoch := now / t.slot
+	st := &t.stripes[rand.Uint32()%readStripes]
+	s := &st.slots[epoch%sloSlots]
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if s.epoch != epoch {
+		*s = sloSlot{epoch: epoch}
+	}
+	s.reads++
+	if fresh {
+		s.fresh++
+	}
+}
+
+// status sums the slots within the window ending at now.
+func (t *sloTracker) status(now int64) SLOStatus {
+	st := SLOStatus{SLO: t.slo}
+	epoch := now / t.slot
+
+	for i := range t.stripes {
+		stripe := &t.stripes[i]
+		stripe.mu.Lock()
+		for _, s := range stripe.slots {
+			if s.epoch > epoch-sloSlots && s.epoch <= epoch {
+				st.Reads += s.reads
+				st.FreshReads += s.fresh
+			}
+		}
+		stripe.mu.Unlock()
+	}
+	return st
+}
@@ -0,0 +1,76 @@
+package datapool
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreshnessSLO(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	b := pool.Bucket("prices")
+	b.SetFreshnessSLO(FreshnessSLO{MaxAge: 10 * time.Second, Target: 0.9, Window: time.Minute})
+	slo, ok := b.FreshnessSLO()
+	require.True(t, ok)
+	assert.Equal(t, 0.9, slo.Target)
+
+	b.Get(0)
+	b.Put(1.0)
+	for range 3 {
+		b.Get(0)
+	}
+	clock.Advance(20 * time.Second)
+	b.Get(0)
+	pool.GetMany([]string{"prices"}, 0)
+
+	st := b.Stats().FreshnessSLO
+	assert.Equal(t, uint64(6), st.Reads)
+	assert.Equal(t, uint64(3), st.FreshReads, "Empty reads and values older than MaxAge are not fresh")
+	assert.Equal(t, 0.5, st.Attainment())
+	assert.False(t, st.Met())
+
+	clock.Advance(time.Minute)
+	st = b.Stats().FreshnessSLO
+	assert.Zero(t, st.Reads, "Reads leave the window")
+	assert.Equal(t, 1.0, st.Attainment())
+	assert.True(t, st.Met())
+
+	b.SetFreshnessSLO(FreshnessSLO{})
+	_, ok = b.FreshnessSLO()
+	assert.False(t, ok)
+	assert.Zero(t, b.Stats().FreshnessSLO)
+}
+
+func TestFreshnessSLODefaults(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("a")
+	b.SetFreshnessSLO(FreshnessSLO{MaxAge: time.Second, Target: 2})
+	slo, _ := b.FreshnessSLO()
+	assert.Equal(t, 1.0, slo.Target, "Targets are clamped")
+	assert.Equal(t, DefaultSLOWindow, slo.Window)
+}
+
+func TestFreshnessSLOMetrics(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	b := pool.Bucket("prices")
+	b.SetFreshnessSLO(FreshnessSLO{MaxAge: 10 * time.Second, Target: 0.99})
+	b.Put(1.0)
+	b.Get(0)
+	clock.Advance(time.Minute)
+	b.Get(0)
+	pool.Handle("other").Put(1)
+
+	var buf bytes.Buffer
+	require.NoError(t, pool.WriteOpenMetrics(&buf, 0))
+	samples := parseSamples(t, buf.String())
+	assert.Equal(t, 0.5, samples[`datapool_bucket_freshness_slo_attainment{bucket="prices"}`])
+	assert.Equal(t, 0.99, samples[`datapool_bucket_freshness_slo_target{bucket="prices"}`])
+	assert.Equal(t, 1.0, samples[`datapool_bucket_freshness_slo_reads{bucket="prices",fresh="true"}`])
+	assert.Equal(t, 1.0, samples[`datapool_bucket_freshness_slo_reads{bucket="prices",fresh="false"}`])
+	assert.NotContains(t, samples, `datapool_bucket_freshness_slo_target{bucket="other"}`)
+}
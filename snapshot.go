@@ -0,0 +1,101 @@
+package datapool
+
+import (
+	"slices"
+	"sort"
+)
+
+// View is a read-only copy of the values of a pool's buckets at one point in
+// time, taken with SnapshotView: of any two values in it, neither was
+// replaced before the other was stored. The zero View is empty.
+type View struct {
+	stamp   int64
+	entries map[string]viewEntry
+	// aliases maps the aliases of buckets in the view to their names.
+	aliases map[string]string
+}
+
+type viewEntry struct {
+	value     any
+	timestamp int64
+	// copies is set for buckets that copy values (see SetCopyValues).
+	copies bool
+}
+
+// SnapshotView returns a View of the values of all buckets, outside the
+// SystemNamespace, at one point in time, so that reports derived from
+// several buckets never mix values from before and after a write, whether
+// the write was a PutMany, a transaction or Puts made one after the other.
+// Writes to the pool wait while the values are copied, which takes one map
+// insertion per bucket; buckets created meanwhile are left out.
+func (p *DataPool) SnapshotView() View {
+	buckets := slices.DeleteFunc(p.all(), func(b *bucket) bool { return b.system })
+
+	var aliases map[string]string
+	p.renameMu.Lock()
+	for _, b := range buckets {
+		for _, alias := range b.aliases {
+			if aliases == nil {
+				aliases = make(map[string]string)
+			}
+			aliases[alias] = b.name()
+		}
+	}
+	p.renameMu.Unlock()
+
+	// p.all lists buckets in lock order.
+	for _, b := range buckets {
+		b.guard.RLock()
+	}
+	v := View{stamp: p.lastStamp.Load(), entries: make(map[string]viewEntry, len(buckets)), aliases: aliases}
+	for _, b := range buckets {
+		if value, ts := b.readValue(p); ts != 0 {
+			v.entries[b.name()] = viewEntry{value: value, timestamp: ts, copies: b.copyValues.Load()}
+		}
+	}
+	for _, b := range buckets {
+		b.guard.RUnlock()
+	}
+	return v
+}
+
+// Get returns the value the named bucket had in the view, its timestamp, and
+// whether it is newer than timestamp, like Bucket.Get, resolving the aliases
+// buckets had (see Alias). Buckets that were empty, held an error stored
+// with PutError or did not exist read as empty.
+func (v View) Get(name string, timestamp int64) (any, int64, bool) {
+	if target, ok := v.aliases[name]; ok {
+		name = target
+	}
+	e, ok := v.entries[name]
+	if !ok {
+		return nil, 0, false
+	}
+	value := e.value
+	if e.copies {
+		value = copyValue(value)
+	}
+	return value, e.timestamp, e.timestamp > timestamp
+}
+
+// Names returns the names of the buckets holding a value in the view, in
+// sorted order.
+func (v View) Names() []string {
+	names := make([]string, 0, len(v.entries))
+	for name := range v.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Len returns the number of buckets holding a value in the view.
+func (v View) Len() int {
+	return len(v.entries)
+}
+
+// Timestamp returns the pool's latest timestamp when the view was taken; no
+// value in the view is newer.
+func (v View) Timestamp() int64 {
+	return v.stamp
+}
@@ -0,0 +1,77 @@
+package datapool
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotView(t *testing.T) {
+	pool := NewDataPool()
+	a := pool.Bucket("a")
+	b := pool.Bucket("b")
+	pool.Bucket("empty")
+	tsA := a.Put(map[string]any{"n": 1.0})
+	b.SetCopyValues(true)
+	b.Put(map[string]any{"n": 2.0})
+
+	view := pool.SnapshotView()
+	a.Put("replaced")
+	created := pool.Bucket("created")
+	created.Put("later")
+
+	assert.Equal(t, []string{"a", "b"}, view.Names(), "Empty buckets and system buckets are left out")
+	assert.Equal(t, 2, view.Len())
+	val, ts, fresh := view.Get("a", 0)
+	assert.Equal(t, map[string]any{"n": 1.0}, val, "The view keeps the values it was taken with")
+	assert.Equal(t, tsA, ts)
+	assert.True(t, fresh)
+	_, _, fresh = view.Get("a", tsA)
+	assert.False(t, fresh)
+	val, ts, _ = view.Get("created", 0)
+	assert.Nil(t, val)
+	assert.Zero(t, ts)
+	assert.GreaterOrEqual(t, view.Timestamp(), tsA)
+
+	val, _, _ = view.Get("b", 0)
+	val.(map[string]any)["n"] = 3.0
+	val, _, _ = view.Get("b", 0)
+	assert.Equal(t, map[string]any{"n": 2.0}, val, "Values of copying buckets are copied")
+
+	assert.Zero(t, View{}.Len())
+}
+
+func TestSnapshotViewIsConsistent(t *testing.T) {
+	pool := NewDataPool()
+	first := pool.Bucket("first")
+	second := pool.Bucket("second")
+	first.Put(0)
+	second.Put(0)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			first.Put(i)
+			second.Put(i)
+		}
+	}()
+
+	for range 1000 {
+		view := pool.SnapshotView()
+		f, _, _ := view.Get("first", 0)
+		s, _, _ := view.Get("second", 0)
+		require.Contains(t, []int{s.(int), s.(int) + 1}, f.(int), "second is never written ahead of first")
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,319 @@
+package datapool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// WriteSnapshotFile writes the current values of the pool's buckets, outside
+// the SystemNamespace, to a file at path, in the format of a compacted
+// write-ahead log, for a pool to restore them from on demand after a restart
+// (see WithSnapshotFallback). The values are encoded with the codec, cipher
+// and chunk size set by the WALOptions given; other WALOptions are ignored.
+// The file is written next to path and renamed over it once synced, so
+// readers of path never see a partial snapshot. Each bucket is read under its
+// own lock, so the snapshot is consistent per bucket but not across buckets.
+func (p *DataPool) WriteSnapshotFile(path string, opts ...WALOption) error {
+	w := newSnapshotWAL(path, opts)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("datapool: write snapshot: %w", err)
+	}
+	if _, _, err = w.writeSnapshot(f, p); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err == nil {
+		err = syncDir(path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("datapool: write snapshot: %w", err)
+	}
+	return nil
+}
+
+// WithSnapshotFallback makes the pool restore buckets from the snapshot file
+// at path, written by WriteSnapshotFile, or from a write-ahead log, on demand:
+// a Get of a bucket that never held a value since the pool was created reads
+// the bucket's value from the file, with its timestamp and expiration time,
+// before trying the backend or the loader. Unlike a log replayed with
+// WithWAL, or values imported at startup, nothing is read when the pool is
+// created, so huge pools start at once and only decode the values they are
+// asked for. The file is indexed, by bucket name, on the first miss; values
+// that have expired since, and buckets read or written before, are not
+// restored, nor are any after Clear. GetMany, which does not create
+// buckets, does not restore them either. Restored values are not reported to
+// watchers, callbacks, the backend or the write-ahead log, as for a replay.
+//
+// opts give the codec and cipher the file was written with. A file that is
+// missing, damaged or written with another codec disables the fallback,
+// reporting the error to the error handler (see WithErrorHandler); a value
+// that fails to decode is reported and left out.
+func WithSnapshotFallback(path string, opts ...WALOption) Option {
+	return func(o *options) {
+		o.snapshot = &snapshotFile{w: newSnapshotWAL(path, opts)}
+	}
+}
+
+// newSnapshotWAL returns a WAL configured by opts, to encode and decode the
+// records of a snapshot file at path without opening it.
+func newSnapshotWAL(path string, opts []WALOption) *WAL {
+	w := &WAL{path: path, codec: JSONCodec, chunkSize: DefaultChunkSize}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// snapshotFile restores buckets from the records of a snapshot file.
+type snapshotFile struct {
+	w *WAL
+
+	once sync.Once
+	// done is set once no record is left to restore.
+	done atomic.Bool
+	mu   sync.Mutex
+	// f is the open file, closed once every record was restored or the file
+	// failed.
+	f *os.File
+	// index holds the offset in f of the latest record of every bucket not
+	// restored yet.
+	index map[string]snapshotRecord
+}
+
+type snapshotRecord struct {
+	op        byte
+	off, n    int64
+	timestamp int64
+	expiresAt int64
+}
+
+// restoreSnapshot restores b from the pool's snapshot file if it never held a
+// value, and returns the value and timestamp Get should return. The file is
+// read with b.guard held, so concurrent Gets of b all see the value restored.
+func (p *DataPool) restoreSnapshot(b *bucket) (any, int64) {
+	if p.opts.snapshot.done.Load() {
+		return nil, 0
+	}
+	now := p.now()
+	var err error
+	b.guard.Lock()
+	if b.version == 0 && b.timestamp == 0 && !b.removed {
+		var rec walRecord
+		var ok bool
+		if rec, ok, err = p.opts.snapshot.lookup(b.name(), now); ok {
+			p.observe(rec.timestamp)
+			b.store(rec.value, rec.timestamp)
+			if rec.expiresAt != 0 {
+				b.expiresAt = rec.expiresAt
+				b.reschedule()
+			}
+			b.version = max(b.version, rec.version)
+		}
+	}
+	value, ts, _ := b.read(p, 0)
+	b.guard.Unlock()
+	if err != nil {
+		p.reportError(b.name(), err)
+	}
+	if ts != 0 {
+		p.checkMemoryPressure(now)
+	}
+	return value, ts
+}
+
+// lookup takes the named bucket's record out of the index, building the
+// index first if needed, and returns it with its value decoded, unless it
+// expired by now.
+func (s *snapshotFile) lookup(name string, now int64) (walRecord, bool, error) {
+	var err error
+	s.once.Do(func() { err = s.open() })
+	if err != nil {
+		return walRecord{}, false, fmt.Errorf("datapool: snapshot fallback %s: %w", s.w.path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.index[name]
+	if !ok {
+		return walRecord{}, false, nil
+	}
+	delete(s.index, name)
+	if len(s.index) == 0 {
+		defer s.close()
+	}
+	if entry.expiresAt != 0 && entry.expiresAt <= now {
+		return walRecord{}, false, nil
+	}
+
+	buf := make([]byte, entry.n)
+	if _, err := s.f.ReadAt(buf, entry.off); err != nil {
+		return walRecord{}, false, fmt.Errorf("datapool: snapshot fallback %s: bucket %q: %w", s.w.path, name, err)
+	}
+	rec, _, err := parseWALRecord(buf)
+	if err == nil {
+		rec.value, err = s.w.decodeValue(rec)
+	}
+	if err != nil {
+		return walRecord{}, false, fmt.Errorf("datapool: snapshot fallback %s: bucket %q: %w", s.w.path, name, err)
+	}
+	return rec, true, nil
+}
+
+// open opens the file and indexes its records. It must be called once.
+func (s *snapshotFile) open() error {
+	f, err := os.Open(s.w.path)
+	if err != nil {
+		s.done.Store(true)
+		return err
+	}
+	index, err := s.scan(f)
+	if err != nil {
+		f.Close()
+		s.done.Store(true)
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.f, s.index = f, index
+	if len(index) == 0 {
+		s.close()
+	}
+	return nil
+}
+
+// discard drops the records not restored yet of the buckets whose names
+// start with prefix, building the index first if needed so none is restored
+// afterwards.
+func (s *snapshotFile) discard(prefix string) {
+	s.once.Do(func() { s.open() })
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name := range s.index {
+		if strings.HasPrefix(name, prefix) {
+			delete(s.index, name)
+		}
+	}
+	if len(s.index) == 0 {
+		s.close()
+	}
+}
+
+// close closes the file. It must be called with s.mu held.
+func (s *snapshotFile) close() {
+	if s.f != nil {
+		s.f.Close()
+		s.f = nil
+	}
+	s.done.Store(true)
+}
+
+// scan reads the records of f, one at a time, and returns the latest record
+// of every bucket holding a value. A torn last record is ignored, as when a
+// log is opened.
+func (s *snapshotFile) scan(f *os.File) (map[string]snapshotRecord, error) {
+	r := bufio.NewReader(f)
+	header := make([]byte, len(s.w.header()))
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("%w: bad header", ErrCorruptWAL)
+	}
+	if err := s.w.checkHeader(header); err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]snapshotRecord)
+	off := int64(len(header))
+	for {
+		rec, n, err := readSnapshotRecord(r)
+		if errors.Is(err, io.EOF) || errors.Is(err, errTornRecord) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w at offset %d", err, off)
+		}
+		if prev, ok := latest[rec.name]; !ok || rec.timestamp > prev.timestamp {
+			latest[rec.name] = snapshotRecord{op: rec.op, off: off, n: n, timestamp: rec.timestamp, expiresAt: rec.expiresAt}
+		}
+		off += n
+	}
+	for name, rec := range latest {
+		if rec.op == walDelete {
+			delete(latest, name)
+		}
+	}
+	return latest, nil
+}
+
+// readSnapshotRecord reads the next record from r, with the chunks of its
+// value if it is chunked, and returns it, without its value, along with its
+// length in the file. It returns io.EOF at the end of r, and errTornRecord
+// for a record cut short.
+func readSnapshotRecord(r *bufio.Reader) (walRecord, int64, error) {
+	body, n, err := readSnapshotFrame(r)
+	if err != nil {
+		return walRecord{}, 0, err
+	}
+	rec, err := decodeWALRecord(body)
+	if err != nil {
+		return walRecord{}, 0, err
+	}
+	rec.data = nil
+	if rec.kind&walChunked == 0 {
+		return rec, n, nil
+	}
+	for {
+		body, m, err := readSnapshotFrame(r)
+		if errors.Is(err, io.EOF) {
+			err = errTornRecord
+		}
+		if err != nil {
+			return walRecord{}, 0, err
+		}
+		if len(body) < 2 || body[0] != walChunk {
+			return walRecord{}, 0, fmt.Errorf("%w: missing chunk of %q", ErrCorruptWAL, rec.name)
+		}
+		n += m
+		if body[1] != 0 {
+			return rec, n, nil
+		}
+	}
+}
+
+// readSnapshotFrame reads the next frame from r and returns its checked body
+// and its length in the file.
+func readSnapshotFrame(r *bufio.Reader) ([]byte, int64, error) {
+	var head [8]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, 0, errTornRecord
+		}
+		return nil, 0, err
+	}
+	body := make([]byte, binary.LittleEndian.Uint32(head[:4]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, 0, errTornRecord
+	}
+	if crc32.Checksum(body, castagnoli) != binary.LittleEndian.Uint32(head[4:8]) {
+		if _, err := r.Peek(1); errors.Is(err, io.EOF) {
+			return nil, 0, errTornRecord
+		}
+		return nil, 0, fmt.Errorf("%w: record fails its checksum", ErrCorruptWAL)
+	}
+	return body, int64(8 + len(body)), nil
+}
@@ -0,0 +1,103 @@
+package datapool
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotFallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.snap")
+	clock := NewManualClock(time.Unix(1000, 0))
+
+	pool := NewDataPool(WithClock(clock))
+	config := pool.Bucket("config")
+	ts := config.Put(map[string]any{"retries": 3})
+	blob := pool.Bucket("blob")
+	blob.PutBytes([]byte{0, 1, 2})
+	short := pool.Bucket("short")
+	short.SetTTL(time.Minute)
+	short.Put("a")
+	require.NoError(t, pool.WriteSnapshotFile(path))
+	_, err := os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err), "The temporary file is renamed")
+
+	clock.Advance(10 * time.Minute)
+	restored := NewDataPool(WithClock(clock), WithSnapshotFallback(path))
+	assert.Equal(t, 0, restored.Len(), "Nothing is restored up front")
+
+	value, got, _ := restored.Handle("config").Get(0)
+	assert.Equal(t, ts, got, "Values keep their timestamps")
+	assert.Equal(t, map[string]any{"retries": 3.0}, value)
+	value, _, _ = restored.Handle("blob").Get(0)
+	assert.Equal(t, []byte{0, 1, 2}, value)
+	value, _, _ = restored.Handle("short").Get(0)
+	assert.Nil(t, value, "Expired values are not restored")
+	value, _, _ = restored.Handle("missing").Get(0)
+	assert.Nil(t, value)
+
+	assert.Greater(t, restored.Handle("other").Put(1), ts, "Later writes are stamped after restored ones")
+}
+
+func TestSnapshotFallbackWrittenFirst(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.snap")
+	pool := NewDataPool()
+	pool.Handle("config").Put("old")
+	pool.Handle("gone").Put("old")
+	require.NoError(t, pool.WriteSnapshotFile(path))
+
+	restored := NewDataPool(WithSnapshotFallback(path))
+	restored.Handle("config").Put("new")
+	value, _, _ := restored.Handle("config").Get(0)
+	assert.Equal(t, "new", value, "Buckets written since the restart are not restored")
+
+	restored.Clear()
+	value, _, _ = restored.Handle("gone").Get(0)
+	assert.Nil(t, value, "Nothing is restored after Clear")
+}
+
+func TestSnapshotFallbackFromWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+	w := openWAL(t, path, WithWALChunkSize(4))
+	pool := NewDataPool(WithWAL(w))
+	pool.Handle("gone").Put(1)
+	pool.Clear()
+	pool.Handle("config").Put("first")
+	ts := pool.Handle("config").Put("a value larger than a chunk")
+	require.NoError(t, w.Close())
+
+	restored := NewDataPool(WithSnapshotFallback(path))
+	value, got, _ := restored.Handle("config").Get(0)
+	assert.Equal(t, ts, got, "The latest record of a bucket is restored")
+	assert.Equal(t, "a value larger than a chunk", value)
+	value, _, _ = restored.Handle("gone").Get(0)
+	assert.Nil(t, value, "Removed buckets are not restored")
+}
+
+func TestSnapshotFallbackErrors(t *testing.T) {
+	dir := t.TempDir()
+	var reported []error
+	onError := WithErrorHandler(func(_ string, err error) { reported = append(reported, err) })
+
+	pool := NewDataPool(onError, WithSnapshotFallback(filepath.Join(dir, "missing.snap")))
+	pool.Handle("a").Get(0)
+	pool.Handle("b").Get(0)
+	require.Len(t, reported, 1, "A missing file disables the fallback")
+	assert.Contains(t, reported[0].Error(), "datapool: snapshot fallback")
+
+	path := filepath.Join(dir, "pool.snap")
+	written := NewDataPool()
+	written.Handle("a").Put(1)
+	require.NoError(t, written.WriteSnapshotFile(path, WithWALCodec(GobCodec)))
+	reported = nil
+	pool = NewDataPool(onError, WithSnapshotFallback(path))
+	value, _, _ := pool.Handle("a").Get(0)
+	assert.Nil(t, value)
+	require.Len(t, reported, 1)
+	assert.True(t, strings.Contains(reported[0].Error(), "codec"), "The codec must match")
+}
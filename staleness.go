@@ -0,0 +1,177 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ExpectUpdates declares that the bucket should be updated at least once per
+// interval. A bucket that goes longer without a Put is reported as stale by
+// StaleBuckets and by Watchdog. An interval of zero or less clears the
+// expectation.
+func (b *Bucket) ExpectUpdates(interval time.Duration) {
+	bk := b.resolve("expect updates")
+	if bk == nil {
+		return
+	}
+
+	bk.guard.Lock()
+	defer bk.guard.Unlock()
+
+	if interval <= 0 {
+		bk.expected, bk.expectedSince = 0, 0
+		return
+	}
+	bk.expected = interval
+	bk.expectedSince = b.pool.now()
+}
+
+// StalenessAlert describes a bucket that has gone longer than its expected
+// update interval without a Put.
+type StalenessAlert struct {
+	Bucket   string
+	Expected time.Duration
+	// Updated is the time of the bucket's last update, zero if it never had one.
+	Updated time.Time
+	// StartsAt is when the bucket became stale.
+	StartsAt time.Time
+	// EndsAt is zero while the bucket is stale and is set once it is fresh again.
+	EndsAt time.Time
+}
+
+// Resolved reports whether the bucket has become fresh again.
+func (a StalenessAlert) Resolved() bool {
+	return !a.EndsAt.IsZero()
+}
+
+// StaleBuckets returns an alert for every bucket that currently exceeds its
+// expected update interval, in bucket creation order.
+func (p *DataPool) StaleBuckets() []StalenessAlert {
+	now := p.now()
+
+	var alerts []StalenessAlert
+	for _, b := range p.all() {
+		b.guard.RLock()
+		expected, since, ts := b.expected, b.expectedSince, b.timestamp
+		b.guard.RUnlock()
+
+		if expected <= 0 {
+			continue
+		}
+		// A bucket is only expected to be updated from the moment the
+		// expectation was declared.
+		startsAt := max(ts, since) + int64(expected)
+		if now <= startsAt {
+			continue
+		}
+
+		alert := StalenessAlert{
+			Bucket:   b.name(),
+			Expected: expected,
+			StartsAt: time.Unix(0, startsAt),
+		}
+		if ts != 0 {
+			alert.Updated = time.Unix(0, ts)
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+// StalenessNotifier receives the alerts produced by each Watchdog check: one
+// for every bucket that is currently stale, and a resolved alert for every
+// bucket that recovered since the previous check. Firing alerts are repeated at
+// every check, which is what Alertmanager-style receivers expect.
+type StalenessNotifier interface {
+	Notify(ctx context.Context, alerts []StalenessAlert) error
+}
+
+// NotifierFunc adapts a function to the StalenessNotifier interface.
+type NotifierFunc func(ctx context.Context, alerts []StalenessAlert) error
+
+// Notify calls f(ctx, alerts).
+func (f NotifierFunc) Notify(ctx context.Context, alerts []StalenessAlert) error {
+	return f(ctx, alerts)
+}
+
+// Watchdog periodically checks a pool for stale buckets and reports them to
+// its notifiers.
+type Watchdog struct {
+	pool      *DataPool
+	notifiers []StalenessNotifier
+
+	mu     sync.Mutex
+	firing map[string]StalenessAlert
+}
+
+// NewWatchdog returns a Watchdog reporting the pool's stale buckets to notifiers.
+func (p *DataPool) NewWatchdog(notifiers ...StalenessNotifier) *Watchdog {
+	return &Watchdog{
+		pool:      p,
+		notifiers: notifiers,
+		firing:    make(map[string]StalenessAlert),
+	}
+}
+
+// Check runs a single staleness check and notifies every notifier, even if
+// some of them fail. It returns the errors of the failed notifiers joined
+// together. Nothing is sent when no bucket is stale or recovered.
+func (w *Watchdog) Check(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stale := w.pool.StaleBuckets()
+	now := time.Unix(0, w.pool.now())
+
+	alerts := make([]StalenessAlert, 0, len(stale))
+	current := make(map[string]StalenessAlert, len(stale))
+	for _, alert := range stale {
+		current[alert.Bucket] = alert
+		alerts = append(alerts, alert)
+	}
+	var resolved []StalenessAlert
+	for name, alert := range w.firing {
+		if _, ok := current[name]; !ok {
+			alert.EndsAt = now
+			resolved = append(resolved, alert)
+		}
+	}
+	sort.Slice(resolved, func(i, j int) bool {
+		return resolved[i].Bucket < resolved[j].Bucket
+	})
+	alerts = append(alerts, resolved...)
+	w.firing = current
+
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, n := range w.notifiers {
+		if err := n.Notify(ctx, alerts); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run calls Check every interval until ctx is done, then returns ctx.Err().
+// Notifier errors are passed to onError if it is not nil.
+func (w *Watchdog) Run(ctx context.Context, interval time.Duration, onError func(error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := w.Check(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaleBuckets(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+
+	config := pool.Bucket("config")
+	config.ExpectUpdates(time.Minute)
+	ts := config.Put("v1")
+	never := pool.Bucket("never")
+	never.ExpectUpdates(2 * time.Minute)
+	untracked := pool.Bucket("untracked")
+	untracked.Put("x")
+
+	assert.Empty(t, pool.StaleBuckets())
+
+	clock.Advance(90 * time.Second)
+	stale := pool.StaleBuckets()
+	require.Len(t, stale, 1)
+	assert.Equal(t, "config", stale[0].Bucket)
+	assert.Equal(t, time.Minute, stale[0].Expected)
+	assert.Equal(t, time.Unix(0, ts), stale[0].Updated)
+	assert.Equal(t, time.Unix(0, ts).Add(time.Minute), stale[0].StartsAt)
+	assert.False(t, stale[0].Resolved())
+
+	clock.Advance(time.Minute)
+	stale = pool.StaleBuckets()
+	require.Len(t, stale, 2)
+	assert.Equal(t, "never", stale[1].Bucket)
+	assert.True(t, stale[1].Updated.IsZero(), "Never updated bucket has no update time")
+	assert.Equal(t, time.Unix(1000, 0).Add(2*time.Minute), stale[1].StartsAt,
+		"Expectation counts from when it was declared")
+
+	config.Put("v2")
+	never.ExpectUpdates(0)
+	assert.Empty(t, pool.StaleBuckets())
+}
+
+func TestWatchdogTransitions(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	config := pool.Bucket("config")
+	config.ExpectUpdates(time.Minute)
+	config.Put("v1")
+
+	var batches [][]StalenessAlert
+	wd := pool.NewWatchdog(NotifierFunc(func(ctx context.Context, alerts []StalenessAlert) error {
+		batches = append(batches, alerts)
+		return nil
+	}))
+
+	require.NoError(t, wd.Check(context.Background()))
+	assert.Empty(t, batches, "Nothing is sent while everything is fresh")
+
+	clock.Advance(2 * time.Minute)
+	require.NoError(t, wd.Check(context.Background()))
+	require.NoError(t, wd.Check(context.Background()))
+	require.Len(t, batches, 2, "Firing alerts are repeated at every check")
+	assert.False(t, batches[1][0].Resolved())
+
+	config.Put("v2")
+	require.NoError(t, wd.Check(context.Background()))
+	require.Len(t, batches, 3)
+	require.Len(t, batches[2], 1)
+	assert.Equal(t, "config", batches[2][0].Bucket)
+	assert.True(t, batches[2][0].Resolved())
+	assert.Equal(t, clock.Now(), batches[2][0].EndsAt)
+
+	require.NoError(t, wd.Check(context.Background()))
+	assert.Len(t, batches, 3, "Resolved alerts are sent once")
+}
+
+func TestWatchdogNotifierErrors(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	bucket := pool.Bucket("config")
+	bucket.ExpectUpdates(time.Second)
+	clock.Advance(time.Minute)
+
+	failure := errors.New("receiver down")
+	delivered := 0
+	wd := pool.NewWatchdog(
+		NotifierFunc(func(context.Context, []StalenessAlert) error { return failure }),
+		NotifierFunc(func(context.Context, []StalenessAlert) error { delivered++; return nil }),
+	)
+
+	err := wd.Check(context.Background())
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, 1, delivered, "A failing notifier does not block the others")
+}
+
+func TestWatchdogRun(t *testing.T) {
+	pool := NewDataPool()
+	bucket := pool.Bucket("config")
+	bucket.ExpectUpdates(time.Nanosecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	notified := make(chan struct{}, 1)
+	wd := pool.NewWatchdog(NotifierFunc(func(context.Context, []StalenessAlert) error {
+		select {
+		case notified <- struct{}{}:
+		default:
+		}
+		return nil
+	}))
+
+	done := make(chan error)
+	go func() { done <- wd.Run(ctx, time.Millisecond, nil) }()
+
+	<-notified
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
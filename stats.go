@@ -0,0 +1,125 @@
+package datapool
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// BucketStats describes how a bucket is read and written, as returned by
+// Bucket.Stats. Counts start when the bucket is created.
+type BucketStats struct {
+	// Reads counts Get calls, including those of GetMany, GetDecoded and
+	// GetWait.
+	Reads uint64
+	// Writes counts values stored in the bucket, whether Put locally, loaded,
+	// derived or replicated from a backend.
+	Writes uint64
+	// FreshHits counts reads that returned a value newer than the caller's
+	// timestamp, and StaleHits reads that returned an older or frozen one.
+	// The remaining reads found the bucket empty.
+	FreshHits uint64
+	StaleHits uint64
+	// Updated is the time of the current value, and SinceUpdate how long ago
+	// that was on the pool's clock. Both are zero for an empty bucket.
+	Updated     time.Time
+	SinceUpdate time.Duration
+	// LastWriter is the last provenance step of the current value (see
+	// Provenance), zero for an empty bucket.
+	LastWriter Source
+	// Pinned reports whether the bucket is pinned (see Pin).
+	Pinned bool
+	// CoalescedPuts counts values Put under a throttle (see SetThrottle)
+	// that were never stored, superseded by a later value first.
+	CoalescedPuts uint64
+	// FreshnessSLO is how the bucket's reads meet its freshness objective
+	// (see SetFreshnessSLO), zero if it has none.
+	FreshnessSLO SLOStatus
+}
+
+// HitRate returns the share of reads that found a value, fresh or stale, or
+// zero if there were none.
+func (s BucketStats) HitRate() float64 {
+	if s.Reads == 0 {
+		return 0
+	}
+	return float64(s.FreshHits+s.StaleHits) / float64(s.Reads)
+}
+
+// bucketStats counts the reads and writes of a bucket. The counters are
+// updated without holding the bucket's guard.
+type bucketStats struct {
+	reads  [readStripes]readStripe
+	writes atomic.Uint64
+
+	coalescedPuts atomic.Uint64
+}
+
+// readStripes is the number of stripes a bucket's read counters are spread
+// over, so that concurrent Gets of a hot bucket rarely update the same ones.
+const readStripes = 8
+
+// readStripe counts a share of a bucket's reads, padded to a cache line of
+// its own.
+type readStripe struct {
+	reads atomic.Uint64
+	fresh atomic.Uint64
+	stale atomic.Uint64
+	_     [40]byte
+}
+
+// recordRead counts a read that returned timestamp ts.
+func (s *bucketStats) recordRead(ts int64, fresh bool) {
+	r := &s.reads[rand.Uint32()%readStripes]
+	r.reads.Add(1)
+	switch {
+	case fresh:
+		r.fresh.Add(1)
+	case ts != 0:
+		r.stale.Add(1)
+	}
+}
+
+// readCounts sums the read counters over the stripes.
+func (s *bucketStats) readCounts() (reads, fresh, stale uint64) {
+	for i := range s.reads {
+		r := &s.reads[i]
+		reads += r.reads.Load()
+		fresh += r.fresh.Load()
+		stale += r.stale.Load()
+	}
+	return reads, fresh, stale
+}
+
+// Stats returns the bucket's read and write statistics.
+func (b *Bucket) Stats() BucketStats {
+	bk := b.resolve("stats")
+	if bk == nil {
+		return BucketStats{}
+	}
+
+	reads, fresh, stale := bk.stats.readCounts()
+	st := BucketStats{
+		Reads:     reads,
+		Writes:    bk.stats.writes.Load(),
+		FreshHits: fresh,
+		StaleHits: stale,
+		Pinned:    bk.pinned.Load(),
+
+		CoalescedPuts: bk.stats.coalescedPuts.Load(),
+	}
+	if t := bk.slo.Load(); t != nil {
+		st.FreshnessSLO = t.status(b.pool.now())
+	}
+
+	bk.guard.RLock()
+	defer bk.guard.RUnlock()
+
+	if ts := bk.readTimestamp(b.pool); ts != 0 {
+		chain := bk.chain()
+		st.Updated = time.Unix(0, ts)
+		st.SinceUpdate = b.pool.age(ts)
+		st.LastWriter = chain[len(chain)-1]
+	}
+	return st
+}
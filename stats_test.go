@@ -0,0 +1,53 @@
+package datapool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketStats(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	users := pool.Bucket("users")
+
+	assert.Equal(t, BucketStats{}, users.Stats())
+
+	users.Get(0) // miss
+	ts := users.Put("v1")
+	users.Get(0)  // fresh
+	users.Get(ts) // stale
+	pool.GetMany([]string{"users"}, 0)
+	clock.Advance(3 * time.Second)
+
+	st := users.Stats()
+	assert.Equal(t, uint64(4), st.Reads)
+	assert.Equal(t, uint64(1), st.Writes)
+	assert.Equal(t, uint64(2), st.FreshHits)
+	assert.Equal(t, uint64(1), st.StaleHits)
+	assert.Equal(t, 0.75, st.HitRate())
+	assert.Equal(t, time.Unix(0, ts), st.Updated)
+	assert.Equal(t, 3*time.Second, st.SinceUpdate)
+	assert.Equal(t, Source{Kind: SourcePut, At: time.Unix(0, ts)}, st.LastWriter)
+
+	loaded := Source{Kind: SourceLoader, Name: "db", At: clock.Now()}
+	users.PutFrom("v2", loaded)
+	st = users.Stats()
+	assert.Equal(t, uint64(2), st.Writes)
+	assert.Equal(t, time.Duration(0), st.SinceUpdate)
+	assert.Equal(t, loaded, st.LastWriter)
+}
+
+func TestBucketStatsEvicted(t *testing.T) {
+	pool := NewDataPool(WithMaxBuckets(1))
+	b := pool.Bucket("gone")
+	b.Put(1)
+	b.Get(0)
+	pool.Bucket("other")
+	b.Get(1)
+
+	assert.Equal(t, BucketStats{Reads: 1, Writes: 1, FreshHits: 1}, b.Stats(),
+		"Evicted buckets keep their counts but hold no value, and reads through their handles are not counted")
+	assert.Zero(t, BucketStats{}.HitRate())
+}
@@ -0,0 +1,81 @@
+package datapool
+
+import "context"
+
+// Swap stores value and returns the value and timestamp it replaced, as Get
+// would have returned them: nil and zero for an empty or expired bucket. No
+// other write can come in between, so exactly one of several concurrent
+// Swaps sees any given value.
+func (b *Bucket) Swap(value any) (old any, oldTs int64) {
+	bk := b.resolve("swap")
+	if bk == nil {
+		return nil, 0
+	}
+	old, oldTs, _ = b.pool.modify(bk, func(any) any { return value })
+	return old, oldTs
+}
+
+// Update stores the value fn returns for the bucket's current value, nil for
+// an empty or expired bucket, and returns its timestamp. fn runs under the
+// bucket's write lock, so no other write can come in between and concurrent
+// Updates, say incrementing a counter, never lose each other's changes:
+//
+//	hits.Update(func(old any) any {
+//		n, _ := old.(int)
+//		return n + 1
+//	})
+//
+// fn must be quick and must not use the bucket itself. It sees the value held
+// in the pool; it does not consult the backend or the loader.
+func (b *Bucket) Update(fn func(old any) any) int64 {
+	bk := b.resolve("update")
+	if bk == nil {
+		return 0
+	}
+	_, _, ts := b.pool.modify(bk, fn)
+	return ts
+}
+
+// modify is write of the value fn derives from the bucket's current one,
+// read and replaced under a single hold of b.guard. It returns the replaced
+// value and timestamp along with the new timestamp, all zero if b has been
+// removed or the bucket's validator rejected the new value.
+func (p *DataPool) modify(b *bucket, fn func(old any) any) (old any, oldTs, ts int64) {
+	if p.rejectSystem(b) {
+		return nil, 0, 0
+	}
+	u, watchers, err := func() (Update, []*watcher, error) {
+		b.guard.Lock()
+		defer b.guard.Unlock()
+
+		if b.removed {
+			return Update{}, nil, b.removedError()
+		}
+		if err := b.checkClaim(p.now(), 0); err != nil {
+			return Update{}, nil, err
+		}
+		old, oldTs, _ = b.read(p, 0)
+		arg := old
+		if b.copyValues.Load() {
+			// fn and the caller each get their own copy of the stored value.
+			arg, old = copyValue(old), copyValue(old)
+		}
+		value, err := b.prepare(fn(arg))
+		if err != nil {
+			return Update{}, nil, err
+		}
+		u := Update{Bucket: b.name(), Value: value, Timestamp: p.stamp()}
+		u.Version = b.store(u.Value, u.Timestamp)
+		return u, b.watchers, nil
+	}()
+	if err != nil {
+		p.reportError(b.name(), err)
+	}
+	if u.Timestamp == 0 {
+		return nil, 0, 0
+	}
+
+	p.notifyPut(context.Background(), b, watchers, u, ConsistencyDefault)
+	p.checkMemoryPressure(u.Timestamp)
+	return old, oldTs, u.Timestamp
+}
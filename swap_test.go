@@ -0,0 +1,135 @@
+package datapool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwap(t *testing.T) {
+	pool := NewDataPool()
+	bucket := pool.Bucket("leader")
+
+	old, oldTs := bucket.Swap("a")
+	assert.Nil(t, old, "Swapping into an empty bucket returns nothing")
+	assert.Zero(t, oldTs)
+	_, ts, _ := bucket.Get(0)
+
+	old, oldTs = bucket.Swap("b")
+	assert.Equal(t, "a", old)
+	assert.Equal(t, ts, oldTs)
+
+	val, newTs, _ := bucket.Get(0)
+	assert.Equal(t, "b", val)
+	assert.Greater(t, newTs, ts)
+}
+
+func TestSwapConcurrent(t *testing.T) {
+	pool := NewDataPool()
+	bucket := pool.Bucket("token")
+	bucket.Put(0)
+
+	// Every value is swapped out by exactly one goroutine.
+	const n = 50
+	seen := make(chan any, n)
+	var wg sync.WaitGroup
+	for i := 1; i <= n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			old, _ := bucket.Swap(i)
+			seen <- old
+		}()
+	}
+	wg.Wait()
+	close(seen)
+
+	last, _, _ := bucket.Get(0)
+	values := map[any]bool{last: true}
+	for old := range seen {
+		assert.False(t, values[old], "%v swapped out twice", old)
+		values[old] = true
+	}
+	assert.Len(t, values, n+1)
+}
+
+func TestUpdateCounter(t *testing.T) {
+	pool := NewDataPool()
+	hits := pool.Bucket("hits")
+
+	const goroutines, increments = 8, 100
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range increments {
+				hits.Update(func(old any) any {
+					n, _ := old.(int)
+					return n + 1
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	val, _, _ := hits.Get(0)
+	assert.Equal(t, goroutines*increments, val)
+}
+
+func TestUpdateNotifies(t *testing.T) {
+	var puts []any
+	pool := NewDataPool(WithPutCallback(func(_ string, value any, _ int64) {
+		puts = append(puts, value)
+	}))
+	bucket := pool.Bucket("list")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := bucket.Watch(ctx)
+
+	ts := bucket.Update(func(old any) any {
+		assert.Nil(t, old)
+		return []string{"a"}
+	})
+	require.NotZero(t, ts)
+
+	u := receive(t, updates)
+	assert.Equal(t, Update{Bucket: "list", Value: []string{"a"}, Timestamp: ts, Version: 1}, u)
+	assert.Equal(t, []any{[]string{"a"}}, puts)
+}
+
+func TestUpdateExpiredValue(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	bucket := pool.Bucket("session")
+	bucket.SetTTL(time.Minute)
+	bucket.Put("token")
+
+	clock.Advance(time.Minute)
+	bucket.Update(func(old any) any {
+		assert.Nil(t, old, "Expired values read as an empty bucket")
+		return "token2"
+	})
+	old, _ := bucket.Swap("token3")
+	assert.Equal(t, "token2", old)
+}
+
+func TestSwapEvictedHandle(t *testing.T) {
+	pool := NewDataPool(WithMaxBuckets(1))
+	old := pool.Bucket("old")
+	old.Put("value")
+	pool.Bucket("new")
+
+	val, ts := old.Swap("again")
+	assert.Nil(t, val)
+	assert.Zero(t, ts)
+	assert.Zero(t, old.Update(func(any) any {
+		t.Error("Update ran on an evicted bucket")
+		return nil
+	}))
+	assert.Equal(t, 1, pool.Len())
+}
@@ -0,0 +1,55 @@
+package datapool
+
+import "time"
+
+// SetStaleWhileRevalidate makes the bucket serve stale values while they are
+// refreshed. Once its value is soft old, Get still returns it right away but
+// also starts reloading it in the background with the bucket's loader (see
+// SetLoader and WithLoader); one reload runs at a time, and a failed one is
+// retried by the next Get. Once the value is hard old it expires as with
+// SetTTL, so Get blocks on the loader and returns an empty value if that
+// fails. A soft of zero or less disables revalidation, and a hard of zero or
+// less serves stale values until a reload replaces them. Like SetTTL, the
+// hard limit applies to subsequent Puts.
+func (b *Bucket) SetStaleWhileRevalidate(soft, hard time.Duration) {
+	bk := b.resolve("set stale while revalidate")
+	if bk == nil {
+		return
+	}
+
+	bk.guard.Lock()
+	defer bk.guard.Unlock()
+
+	bk.softTTL = max(soft, 0)
+	bk.hardTTL = max(hard, 0)
+	bk.forgetRead()
+}
+
+// StaleWhileRevalidate returns the bucket's soft and hard limits, zero when
+// not set.
+func (b *Bucket) StaleWhileRevalidate() (soft, hard time.Duration) {
+	bk := b.resolve("stale while revalidate")
+	if bk == nil {
+		return 0, 0
+	}
+
+	bk.guard.RLock()
+	defer bk.guard.RUnlock()
+
+	return bk.softTTL, bk.hardTTL
+}
+
+// revalidate reloads a stale value from load in the background, unless a
+// reload of the bucket is already running.
+func (p *DataPool) revalidate(b *bucket, load Loader) {
+	if !b.revalidating.CompareAndSwap(false, true) {
+		return
+	}
+	started := p.goBackground(func() {
+		defer b.revalidating.Store(false)
+		p.loadThrough(b, load)
+	})
+	if !started {
+		b.revalidating.Store(false)
+	}
+}
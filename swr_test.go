@@ -0,0 +1,119 @@
+package datapool
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	release := make(chan struct{})
+	var calls atomic.Int32
+	pool := NewDataPool(WithClock(clock), WithStaleWhileRevalidate(time.Minute, time.Hour))
+	b := pool.Bucket("rates")
+	soft, hard := b.StaleWhileRevalidate()
+	assert.Equal(t, time.Minute, soft)
+	assert.Equal(t, time.Hour, hard)
+	b.SetLoader(func(string) (any, error) {
+		calls.Add(1)
+		<-release
+		return "new", nil
+	})
+	ts := b.Put("old")
+
+	clock.Advance(59 * time.Second)
+	val, _, _ := b.Get(0)
+	assert.Equal(t, "old", val)
+	assert.Zero(t, calls.Load(), "Values younger than the soft limit are not reloaded")
+
+	clock.Advance(time.Second)
+	for range 5 {
+		val, got, fresh := b.Get(0)
+		assert.Equal(t, "old", val, "Stale values are served while reloading")
+		assert.Equal(t, ts, got)
+		assert.True(t, fresh)
+	}
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	close(release)
+	require.Eventually(t, func() bool {
+		val, _, _ := b.Get(0)
+		return val == "new"
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int32(1), calls.Load(), "One reload runs at a time")
+	assert.Equal(t, SourceLoader, b.Provenance()[0].Kind)
+}
+
+func TestStaleWhileRevalidateHardLimit(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	var fail atomic.Bool
+	var errs []error
+	pool := NewDataPool(WithClock(clock), WithErrorHandler(func(_ string, err error) {
+		errs = append(errs, err)
+	}), WithLoader(func(string) (any, error) {
+		if fail.Load() {
+			return nil, errUnreachable
+		}
+		return "loaded", nil
+	}))
+	b := pool.Bucket("rates")
+	b.SetStaleWhileRevalidate(time.Minute, time.Hour)
+	b.Put("old")
+
+	clock.Advance(time.Hour)
+	val, ts, _ := b.Get(0)
+	assert.Equal(t, "loaded", val, "Values past the hard limit are loaded before Get returns")
+	assert.NotZero(t, ts)
+
+	fail.Store(true)
+	clock.Advance(time.Hour)
+	val, ts, _ = b.Get(0)
+	assert.Nil(t, val, "Get fails past the hard limit if the loader does")
+	assert.Zero(t, ts)
+	require.Len(t, errs, 1)
+	assert.True(t, errors.Is(errs[0], errUnreachable))
+}
+
+func TestStaleWhileRevalidateWithoutHardLimit(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	var calls atomic.Int32
+	pool := NewDataPool(WithClock(clock), WithLoader(func(string) (any, error) {
+		calls.Add(1)
+		return nil, errUnreachable
+	}))
+	b := pool.Bucket("rates")
+	b.SetStaleWhileRevalidate(time.Minute, 0)
+	b.Put("old")
+
+	clock.Advance(24 * time.Hour)
+	val, _, _ := b.Get(0)
+	assert.Equal(t, "old", val, "Without a hard limit stale values are served indefinitely")
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	// A failed reload is retried by the next Get.
+	require.Eventually(t, func() bool {
+		b.Get(0)
+		return calls.Load() >= 2
+	}, time.Second, time.Millisecond)
+	val, _, _ = b.Get(0)
+	assert.Equal(t, "old", val)
+}
+
+func TestStaleWhileRevalidateKeepsShorterTTL(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	b := pool.Bucket("session")
+	b.SetTTL(time.Minute)
+	b.SetStaleWhileRevalidate(time.Second, time.Hour)
+	b.Put("token")
+
+	clock.Advance(time.Minute)
+	val, _, _ := b.Get(0)
+	assert.Nil(t, val, "The shorter of the TTL and the hard limit applies")
+	assert.Equal(t, 1, pool.Expire())
+}
@@ -0,0 +1,159 @@
+package datapool
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SystemNamespace prefixes the names of the buckets in which the pool
+// publishes its own state. They are read like any other bucket, so existing
+// tooling such as Inspect, the HTTP and gRPC servers and watchers can show
+// them, but only the pool writes them: user writes are rejected with
+// ErrSystemBucket, and Clear, eviction and memory pressure leave them alone.
+// System buckets are created on first use and are not counted by Len or
+// WithMaxBuckets. Other names in the namespace are reserved: creating a bucket
+// with one fails with ErrSystemBucket.
+const SystemNamespace = "__datapool/"
+
+// The system buckets. Their values are brought up to date whenever they are
+// read.
+const (
+	// SystemStatsBucket holds the pool's PoolStats.
+	SystemStatsBucket = SystemNamespace + "stats"
+	// SystemBackendBucket holds the BackendStatus of the pool's backend,
+	// including its replay cursor, or nil without one.
+	SystemBackendBucket = SystemNamespace + "backend"
+)
+
+// ErrSystemBucket is wrapped by the errors reported for writes to buckets in
+// the SystemNamespace. It is an ErrSealed.
+var ErrSystemBucket = newKindError(ErrSealed, "datapool: system buckets are read-only")
+
+// PoolStats is the value of SystemStatsBucket.
+type PoolStats struct {
+	// Buckets is the number of buckets outside the system namespace.
+	Buckets          int    `json:"buckets"`
+	Corruptions      uint64 `json:"corruptions"`
+	WatchOverflows   uint64 `json:"watch_overflows"`
+	SchemaMismatches uint64 `json:"schema_mismatches"`
+	// MemoryUsage is the pool's MemoryUsage.
+	MemoryUsage int64 `json:"memory_usage"`
+	// PinnedBuckets is the number of pinned buckets (see Bucket.Pin).
+	PinnedBuckets int `json:"pinned_buckets"`
+}
+
+// systemValues computes the value of each system bucket.
+var systemValues = map[string]func(p *DataPool) any{
+	SystemStatsBucket: func(p *DataPool) any {
+		return PoolStats{
+			Buckets:          p.Len(),
+			Corruptions:      p.Corruptions(),
+			WatchOverflows:   p.WatchOverflows(),
+			SchemaMismatches: p.SchemaMismatches(),
+			MemoryUsage:      p.MemoryUsage(),
+			PinnedBuckets:    p.PinnedBuckets(),
+		}
+	},
+	SystemBackendBucket: func(p *DataPool) any {
+		if p.opts.backend == nil {
+			return nil
+		}
+		return p.BackendStatus()
+	},
+}
+
+// isSystem reports whether name is one of the system buckets.
+func isSystem(name string) bool {
+	_, ok := systemValues[name]
+	return ok
+}
+
+// isReserved reports whether name is in the SystemNamespace, whether or not
+// it is a system bucket.
+func isReserved(name string) bool {
+	return strings.HasPrefix(name, SystemNamespace)
+}
+
+// checkReserved returns the error creating the named bucket fails with if
+// name is in the SystemNamespace but is not a system bucket.
+func checkReserved(name string) error {
+	if isReserved(name) && !isSystem(name) {
+		return fmt.Errorf("%w: %q is reserved", ErrSystemBucket, name)
+	}
+	return nil
+}
+
+// checkWritable returns the error a user write to the named bucket fails
+// with: the pool is closed, its NameRules reject the name, or it is a system
+// bucket.
+func (p *DataPool) checkWritable(name string) error {
+	if p.isClosed() {
+		return ErrPoolClosed
+	}
+	if err := p.ValidateName(name); err != nil {
+		return err
+	}
+	if isReserved(name) {
+		return fmt.Errorf("%w: %q", ErrSystemBucket, name)
+	}
+	return nil
+}
+
+// rejectSystem reports a user write to a system bucket, and whether b is one.
+func (p *DataPool) rejectSystem(b *bucket) bool {
+	if !b.system {
+		return false
+	}
+	p.reportError(b.name(), fmt.Errorf("%w: %q", ErrSystemBucket, b.name()))
+	return true
+}
+
+// refreshSystem stores the current value of a system bucket if it changed.
+// Like other writes it reaches watchers and put callbacks, but not the
+// backend or the writer.
+func (p *DataPool) refreshSystem(b *bucket) {
+	value := systemValues[b.name()]
+	if value == nil {
+		return
+	}
+	v := value(p)
+
+	b.guard.Lock()
+	if b.removed || (b.timestamp != 0 && reflect.DeepEqual(b.value, v)) {
+		b.guard.Unlock()
+		return
+	}
+	u := Update{Bucket: b.name(), Value: v, Timestamp: p.stamp()}
+	u.Version = b.store(v, u.Timestamp)
+	watchers := b.watchers
+	b.guard.Unlock()
+
+	p.deliverUpdate(b, watchers, u)
+	p.firePut(b.name(), u.Value, u.Timestamp)
+	p.triggerDerived(b.name())
+}
+
+// Clear removes every bucket outside the SystemNamespace and returns how many
+// it removed. Handles to them read as empty afterwards, their watchers are
+// closed, and eviction callbacks are not called. Buckets not restored yet from
+// a snapshot file (see WithSnapshotFallback) no longer are.
+func (p *DataPool) Clear() int {
+	if s := p.opts.snapshot; s != nil {
+		s.discard("")
+	}
+	cleared := 0
+	for _, b := range p.all() {
+		if b.system {
+			continue
+		}
+		if _, ok := p.remove(b); ok {
+			if p.wal != nil {
+				p.logRemove(b.name())
+			}
+			p.logEvent(LogRemove, b.name())
+			cleared++
+		}
+	}
+	return cleared
+}
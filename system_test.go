@@ -0,0 +1,154 @@
+package datapool
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystemStats(t *testing.T) {
+	pool := NewDataPool()
+	users := pool.Bucket("users/1")
+	users.Put("Alice")
+
+	stats := pool.Bucket(SystemStatsBucket)
+	val, ts, fresh := stats.Get(0)
+	assert.Equal(t, PoolStats{Buckets: 1}, val)
+	assert.NotZero(t, ts)
+	assert.True(t, fresh)
+	assert.Equal(t, 1, pool.Len(), "System buckets are not counted")
+
+	// Reads bring the value up to date, and only store a new one on change.
+	_, again, _ := stats.Get(0)
+	assert.Equal(t, ts, again)
+	pool.Bucket("users/2")
+	val, newer, _ := stats.Get(ts)
+	assert.Equal(t, PoolStats{Buckets: 2}, val)
+	assert.Greater(t, newer, ts)
+
+	results := pool.GetMany([]string{SystemStatsBucket}, 0)
+	assert.Equal(t, PoolStats{Buckets: 2}, results[SystemStatsBucket].Value)
+
+	var names []string
+	for _, info := range pool.Inspect() {
+		names = append(names, info.Name)
+	}
+	assert.Contains(t, names, SystemStatsBucket, "System buckets show up in Inspect once used")
+}
+
+func TestSystemBackend(t *testing.T) {
+	pool := NewDataPool()
+	backend := pool.Bucket(SystemBackendBucket)
+	val, _, _ := backend.Get(0)
+	assert.Nil(t, val, "Pools without a backend have no backend status")
+
+	pool = NewDataPool(WithBackend(&MemoryBackend{}), WithOfflineQueue(10))
+	backend = pool.Bucket(SystemBackendBucket)
+	val, _, _ = backend.Get(0)
+	assert.Equal(t, BackendStatus{}, val)
+}
+
+func TestSystemBucketsAreReadOnly(t *testing.T) {
+	var errs []error
+	pool := NewDataPool(WithErrorHandler(func(_ string, err error) {
+		errs = append(errs, err)
+	}))
+	stats := pool.Bucket(SystemStatsBucket)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	assert.Zero(t, stats.Put("forged"))
+	assert.Zero(t, stats.Update(func(any) any { return "forged" }))
+	assert.Zero(t, stats.PutBytes([]byte("forged")))
+	_, err := stats.PutConsistent(ctx, "forged", ConsistencyDefault)
+	assert.NoError(t, err)
+	assert.Zero(t, pool.PutMany(map[string]any{SystemStatsBucket: "forged"})[SystemStatsBucket])
+	require.Len(t, errs, 5)
+	for _, err := range errs {
+		assert.ErrorIs(t, err, ErrSystemBucket)
+	}
+
+	err = pool.Update(func(tx *Tx) error {
+		tx.Put(SystemStatsBucket, "forged")
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrSystemBucket)
+	_, err = pool.Derive(SystemStatsBucket, []string{"a"}, func([]any) (any, error) { return nil, nil })
+	assert.ErrorIs(t, err, ErrSystemBucket)
+	assert.ErrorIs(t, <-pool.ScheduleRefresh(SystemStatsBucket, func() (any, error) { return "forged", nil }), ErrSystemBucket)
+
+	val, _, _ := stats.Get(0)
+	assert.Equal(t, PoolStats{}, val)
+
+	// Names in the namespace that the pool does not use are reserved.
+	other := pool.Bucket(SystemNamespace + "other")
+	other.Put("forged")
+	val, _, _ = other.Get(0)
+	assert.Nil(t, val)
+}
+
+func TestReservedNames(t *testing.T) {
+	var errs []error
+	pool := NewDataPool(WithMaxBuckets(2), WithErrorHandler(func(_ string, err error) {
+		errs = append(errs, err)
+	}))
+	a := pool.Bucket("a")
+	a.Put(1)
+	for i := range 100 {
+		pool.Bucket(fmt.Sprintf("%sb%d", SystemNamespace, i))
+	}
+	assert.Len(t, errs, 100)
+	assert.ErrorIs(t, errs[0], ErrSystemBucket)
+	assert.ErrorContains(t, errs[0], `"__datapool/b0" is reserved`)
+	assert.Equal(t, 1, pool.Len())
+	assert.Len(t, pool.all(), 1, "Reserved names create no buckets")
+	assert.NotNil(t, pool.find("a"))
+}
+
+func TestClear(t *testing.T) {
+	var evicted []string
+	pool := NewDataPool(WithMaxBuckets(2), WithEvictionCallback(func(name string, _ any) {
+		evicted = append(evicted, name)
+	}))
+	a := pool.Bucket("a")
+	a.Put(1)
+	stats := pool.Bucket(SystemStatsBucket)
+	stats.Get(0)
+	b := pool.Bucket("b")
+	b.Put(2)
+	assert.Empty(t, evicted, "System buckets do not count towards the cap")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := a.Watch(ctx)
+
+	assert.Equal(t, 2, pool.Clear())
+	assert.Zero(t, pool.Len())
+	assertClosed(t, updates)
+	val, _, _ := a.Get(0)
+	assert.Nil(t, val)
+	assert.Empty(t, evicted)
+
+	val, _, _ = stats.Get(0)
+	assert.Equal(t, PoolStats{}, val, "Clear keeps system buckets")
+	assert.Equal(t, 1, len(pool.Inspect()))
+}
+
+func TestSystemBucketsSurviveMemoryPressure(t *testing.T) {
+	pool := NewDataPool(WithMemoryPressure(PressureConfig{
+		Pressure: func() float64 { return 0 },
+		Fraction: 1,
+	}))
+	users := pool.Bucket("users/1")
+	users.Put("Alice")
+	stats := pool.Bucket(SystemStatsBucket)
+	stats.Get(0)
+
+	assert.Equal(t, 1, pool.RelieveMemoryPressure())
+	val, ts, _ := stats.Get(0)
+	assert.Equal(t, PoolStats{Buckets: 1}, val)
+	assert.NotZero(t, ts)
+}
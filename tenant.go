@@ -0,0 +1,321 @@
+package datapool
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// TenantNamespace is the namespace of the buckets of tenants (see
+// DataPool.Tenant): the bucket name of a tenant is stored in the pool as
+// TenantNamespace + id + "/" + name.
+const TenantNamespace = "tenants/"
+
+// ErrQuotaExceeded is the error of a bucket created or a value stored beyond
+// its tenant's quota (see TenantQuota). It is an ErrTooLarge.
+var ErrQuotaExceeded = newKindError(ErrTooLarge, "datapool: tenant quota exceeded")
+
+// ErrTenantDropped is returned for buckets created through a tenant, or in
+// its namespace, while or after it is dropped. It is an ErrNotFound.
+var ErrTenantDropped = newKindError(ErrNotFound, "datapool: tenant dropped")
+
+// TenantQuota limits the resources of a tenant (see Tenant.SetQuota). The zero
+// TenantQuota sets no limit.
+type TenantQuota struct {
+	// MaxBuckets is the most buckets the tenant may hold. Creating more fails
+	// with ErrQuotaExceeded instead of evicting the tenant's other buckets.
+	// Zero or less means no limit.
+	MaxBuckets int
+
+	// MaxMemory is the most bytes the values of the tenant's buckets may
+	// hold, as measured by the pool's Sizer. Writes that would exceed it are
+	// rejected with ErrQuotaExceeded. Zero or less means no limit.
+	MaxMemory int64
+}
+
+// WithTenantQuota sets the quota of every new tenant, which Tenant.SetQuota
+// overrides.
+func WithTenantQuota(q TenantQuota) Option {
+	return func(o *options) {
+		o.tenantQuota = q
+	}
+}
+
+// Tenant is a partition of a DataPool with its own bucket namespace and
+// quota, created by DataPool.Tenant. Its buckets are buckets of the pool, so
+// they share its options, persistence and backend; names given to a Tenant
+// are relative to it, so one tenant cannot reach the buckets of another.
+// Tenant implements Pool.
+type Tenant struct {
+	pool *DataPool
+	t    *tenant
+}
+
+var _ Pool = (*Tenant)(nil)
+
+// tenant is the state of a tenant shared by its handles and buckets.
+type tenant struct {
+	id     string
+	prefix string
+	used   atomic.Int64
+
+	mu      sync.Mutex
+	quota   TenantQuota
+	buckets map[*bucket]struct{}
+	dropped bool
+}
+
+// Tenant returns the tenant id, creating it with the pool's tenant quota
+// (see WithTenantQuota) if it does not exist. Buckets created in the tenant
+// are named TenantNamespace + id + "/" + name in the pool, which is where
+// the pool's other operations find them. If id is empty or contains a "/",
+// it passes the error to the error handler and returns a Tenant whose
+// Bucket returns the zero Bucket.
+func (p *DataPool) Tenant(id string) *Tenant {
+	if id == "" || strings.Contains(id, "/") {
+		p.reportError(TenantNamespace+id, fmt.Errorf("datapool: tenant %q: invalid id", id))
+		return &Tenant{pool: p}
+	}
+	return &Tenant{pool: p, t: p.tenant(id)}
+}
+
+// tenant returns the named tenant, creating it if needed.
+func (p *DataPool) tenant(id string) *tenant {
+	p.lazyInit()
+	p.tenantsMu.Lock()
+	defer p.tenantsMu.Unlock()
+
+	if t := p.tenants[id]; t != nil {
+		return t
+	}
+	if p.tenants == nil {
+		p.tenants = make(map[string]*tenant)
+	}
+	t := &tenant{
+		id:      id,
+		prefix:  TenantNamespace + id + "/",
+		quota:   p.opts.tenantQuota,
+		buckets: make(map[*bucket]struct{}),
+	}
+	p.tenants[id] = t
+	return t
+}
+
+// tenantID returns the id of the tenant the named bucket belongs to, if any.
+func tenantID(name string) (string, bool) {
+	rest, ok := strings.CutPrefix(name, TenantNamespace)
+	if !ok {
+		return "", false
+	}
+	id, _, ok := strings.Cut(rest, "/")
+	return id, ok && id != ""
+}
+
+// checkSameTenant returns an error if a and b belong to different tenants,
+// or one to a tenant and the other to none.
+func checkSameTenant(a, b string) error {
+	ida, _ := tenantID(a)
+	idb, _ := tenantID(b)
+	if ida != idb {
+		return fmt.Errorf("%q and %q belong to different tenants", a, b)
+	}
+	return nil
+}
+
+// ID returns the tenant's id, or "" for a Tenant with an invalid one.
+func (t *Tenant) ID() string {
+	if t.t == nil {
+		return ""
+	}
+	return t.t.id
+}
+
+// Bucket is DataPool.Bucket for the tenant's bucket name. Creating a bucket
+// beyond the tenant's MaxBuckets fails with ErrQuotaExceeded, and creating
+// one once the tenant was dropped with ErrTenantDropped; either error is
+// passed to the error handler and the zero Bucket returned.
+func (t *Tenant) Bucket(name string) Bucket {
+	if t.t == nil {
+		return Bucket{}
+	}
+	full := t.t.prefix + name
+	if t.Dropped() {
+		t.pool.reportError(full, fmt.Errorf("%w: %q", ErrTenantDropped, t.t.id))
+		return Bucket{}
+	}
+	return t.pool.Bucket(full)
+}
+
+// Handle returns a handle to the tenant's named bucket, creating it if
+// needed, like Bucket.
+func (t *Tenant) Handle(name string) Handle {
+	b := t.Bucket(name)
+	return &b
+}
+
+// Quota returns the tenant's quota.
+func (t *Tenant) Quota() TenantQuota {
+	if t.t == nil {
+		return TenantQuota{}
+	}
+	t.t.mu.Lock()
+	defer t.t.mu.Unlock()
+
+	return t.t.quota
+}
+
+// SetQuota sets the tenant's quota. Buckets and values the tenant already
+// holds beyond it are kept; only subsequent creations and writes are
+// rejected.
+func (t *Tenant) SetQuota(q TenantQuota) {
+	if t.t == nil {
+		return
+	}
+	t.t.mu.Lock()
+	defer t.t.mu.Unlock()
+
+	t.t.quota = q
+}
+
+// Len returns the number of buckets of the tenant.
+func (t *Tenant) Len() int {
+	if t.t == nil {
+		return 0
+	}
+	t.t.mu.Lock()
+	defer t.t.mu.Unlock()
+
+	return len(t.t.buckets)
+}
+
+// MemoryUsage returns the approximate number of bytes held by the values of
+// the tenant's buckets.
+func (t *Tenant) MemoryUsage() int64 {
+	if t.t == nil {
+		return 0
+	}
+	return t.t.used.Load()
+}
+
+// Dropped reports whether the tenant was dropped.
+func (t *Tenant) Dropped() bool {
+	if t.t == nil {
+		return false
+	}
+	t.t.mu.Lock()
+	defer t.t.mu.Unlock()
+
+	return t.t.dropped
+}
+
+// Drop removes the tenant and every bucket of it, as Clear does for the
+// whole pool, and returns how many buckets it removed. No bucket can be
+// created in the tenant while it is dropped, so none survives. The Tenant
+// and handles to its buckets are invalid afterwards; DataPool.Tenant
+// returns a new, empty tenant of the same id.
+func (t *Tenant) Drop() int {
+	if t.t == nil {
+		return 0
+	}
+	p := t.pool
+	p.tenantsMu.Lock()
+	if p.tenants[t.t.id] == t.t {
+		delete(p.tenants, t.t.id)
+	}
+	p.tenantsMu.Unlock()
+
+	t.t.mu.Lock()
+	if t.t.dropped {
+		t.t.mu.Unlock()
+		return 0
+	}
+	t.t.dropped = true
+	buckets := make([]*bucket, 0, len(t.t.buckets))
+	for b := range t.t.buckets {
+		buckets = append(buckets, b)
+	}
+	t.t.mu.Unlock()
+
+	if s := p.opts.snapshot; s != nil {
+		s.discard(t.t.prefix)
+	}
+	removed := 0
+	for _, b := range buckets {
+		name := b.name()
+		if _, ok := p.remove(b); ok {
+			if p.wal != nil {
+				p.logRemove(name)
+			}
+			p.logEvent(LogRemove, name)
+			removed++
+		}
+	}
+	return removed
+}
+
+// admit adds b to the tenant, or returns the error of a dropped tenant or
+// of one at its MaxBuckets.
+func (t *tenant) admit(b *bucket) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.dropped {
+		return fmt.Errorf("%w: %q", ErrTenantDropped, t.id)
+	}
+	if limit := t.quota.MaxBuckets; limit > 0 && len(t.buckets) >= limit {
+		return fmt.Errorf("%w: tenant %q: %d buckets", ErrQuotaExceeded, t.id, limit)
+	}
+	t.buckets[b] = struct{}{}
+	return nil
+}
+
+// release removes b from the tenant.
+func (t *tenant) release(b *bucket) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.buckets, b)
+}
+
+// checkQuota returns an error if storing value in the bucket would take its
+// tenant beyond its MaxMemory. Otherwise it reserves the bytes value adds, so
+// that concurrent writes to the tenant's other buckets cannot take them too;
+// storing the value takes the reservation over, and the caller must call
+// unreserve if it does not store it. It must be called with b.guard held for
+// writing.
+func (b *bucket) checkQuota(value any) error {
+	t := b.tenant
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	limit := t.quota.MaxMemory
+	t.mu.Unlock()
+	if limit <= 0 || value == nil {
+		return nil
+	}
+	delta := int64(b.sizer()(value)) - b.size - b.reserved
+	if delta <= 0 {
+		return nil
+	}
+	for {
+		used := t.used.Load()
+		if used+delta > limit {
+			return fmt.Errorf("%w: tenant %q: %d bytes of %d", ErrQuotaExceeded, t.id, used+delta, limit)
+		}
+		if t.used.CompareAndSwap(used, used+delta) {
+			b.reserved += delta
+			return nil
+		}
+	}
+}
+
+// unreserve releases the bytes reserved by checkQuota for a value that was
+// not stored. It must be called with b.guard held for writing.
+func (b *bucket) unreserve() {
+	if b.reserved != 0 {
+		b.tenant.used.Add(-b.reserved)
+		b.reserved = 0
+	}
+}
@@ -0,0 +1,173 @@
+package datapool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenant(t *testing.T) {
+	pool := NewDataPool()
+	acme, beta := pool.Tenant("acme"), pool.Tenant("beta")
+	a := acme.Bucket("config")
+	a.Put("acme")
+	b := beta.Bucket("config")
+	b.Put("beta")
+
+	value, _, _ := a.Get(0)
+	assert.Equal(t, "acme", value, "Tenants have their own namespace")
+	assert.Equal(t, "tenants/acme/config", a.Name())
+	value, _, _ = pool.Handle("tenants/beta/config").Get(0)
+	assert.Equal(t, "beta", value, "The pool reaches every tenant")
+	assert.Equal(t, 1, acme.Len())
+	assert.Same(t, acme.t, pool.Tenant("acme").t)
+
+	assert.Error(t, pool.Rename("tenants/acme/config", "tenants/beta/other"), "Buckets cannot move between tenants")
+	assert.Error(t, pool.Alias("tenants/beta/alias", "tenants/acme/config"))
+	assert.Error(t, pool.Alias("alias", "tenants/acme/config"))
+	require.NoError(t, pool.Rename("tenants/acme/config", "tenants/acme/settings"))
+}
+
+func TestTenantInvalidID(t *testing.T) {
+	var reported []error
+	pool := NewDataPool(WithErrorHandler(func(_ string, err error) { reported = append(reported, err) }))
+	for _, id := range []string{"", "a/b"} {
+		tn := pool.Tenant(id)
+		b := tn.Bucket("x")
+		assert.False(t, b.Valid())
+	}
+	assert.Len(t, reported, 2)
+	assert.Zero(t, pool.Len())
+}
+
+func TestTenantQuota(t *testing.T) {
+	var reported []error
+	pool := NewDataPool(
+		WithErrorHandler(func(_ string, err error) { reported = append(reported, err) }),
+		WithSizer(func(v any) int { return len(v.(string)) }),
+		WithTenantQuota(TenantQuota{MaxBuckets: 2}),
+	)
+	acme := pool.Tenant("acme")
+	assert.Equal(t, TenantQuota{MaxBuckets: 2}, acme.Quota())
+	acme.Bucket("a")
+	acme.Bucket("b")
+	c := acme.Bucket("c")
+	assert.False(t, c.Valid())
+	require.Len(t, reported, 1)
+	assert.ErrorIs(t, reported[0], ErrQuotaExceeded)
+	assert.ErrorIs(t, reported[0], ErrTooLarge)
+	assert.Equal(t, 2, pool.Len(), "Quotas reject buckets instead of evicting")
+
+	acme.SetQuota(TenantQuota{MaxMemory: 10})
+	b := acme.Bucket("a")
+	_, err := b.PutE("12345678")
+	require.NoError(t, err)
+	_, err = b.PutE("1234567890")
+	require.NoError(t, err, "Replacing a value only counts the difference")
+	other := acme.Bucket("b")
+	_, err = other.PutE("1")
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.Equal(t, int64(10), acme.MemoryUsage())
+	assert.Equal(t, int64(10), pool.MemoryUsage())
+
+	b.Put("1")
+	_, err = other.PutE("123456789")
+	assert.NoError(t, err)
+	pool.Tenant("beta").Handle("a").Put("not counted against acme")
+	assert.Equal(t, int64(10), acme.MemoryUsage())
+}
+
+func TestTenantDrop(t *testing.T) {
+	pool := NewDataPool()
+	acme := pool.Tenant("acme")
+	a := acme.Bucket("a")
+	a.Put(1)
+	acme.Handle("b").Put(2)
+	other := pool.Tenant("beta").Bucket("a")
+	other.Put(3)
+
+	assert.Equal(t, 2, acme.Drop())
+	assert.True(t, acme.Dropped())
+	assert.False(t, a.Valid())
+	assert.True(t, other.Valid(), "Other tenants are left alone")
+	assert.Equal(t, 1, pool.Len())
+	c := acme.Bucket("c")
+	assert.False(t, c.Valid(), "A dropped tenant stays dropped")
+	assert.Zero(t, acme.Drop())
+
+	fresh := pool.Tenant("acme")
+	assert.Zero(t, fresh.Len())
+	value, _, _ := fresh.Handle("a").Get(0)
+	assert.Nil(t, value, "The id names a new tenant")
+}
+
+func TestTenantDropConcurrent(t *testing.T) {
+	pool := NewDataPool()
+	acme := pool.Tenant("acme")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				acme.Handle(string(rune('a' + j%26))).Put(j)
+			}
+		}()
+	}
+	acme.Drop()
+	wg.Wait()
+
+	assert.Zero(t, acme.Len())
+	for _, b := range pool.all() {
+		assert.NotSame(t, acme.t, b.tenant, "No bucket survives a drop")
+	}
+}
+
+func TestTenantQuotaRefreshAndUpdate(t *testing.T) {
+	pool := NewDataPool(WithTenantQuota(TenantQuota{MaxBuckets: 1}))
+	acme := pool.Tenant("acme")
+	acme.Handle("a").Put(1)
+
+	r := pool.NewRefresher("tenants/acme/b", func() (any, error) { return 2, nil }, time.Hour)
+	assert.ErrorIs(t, r.Refresh(context.Background()), ErrQuotaExceeded, "Refreshes fail on buckets beyond the quota")
+	assert.ErrorIs(t, <-pool.ScheduleRefresh("tenants/acme/b", func() (any, error) { return 2, nil }), ErrQuotaExceeded)
+
+	err := pool.Update(func(tx *Tx) error {
+		tx.Put("tenants/acme/a", 3)
+		tx.Put("tenants/acme/b", 4)
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	value, _, _ := acme.Handle("a").Get(0)
+	assert.Equal(t, 1, value, "Nothing is written by a transaction failing the quota")
+	assert.Equal(t, 1, acme.Len())
+}
+
+func TestTenantQuotaConcurrent(t *testing.T) {
+	pool := NewDataPool(
+		WithSizer(func(v any) int { return len(v.(string)) }),
+		WithTenantQuota(TenantQuota{MaxMemory: 50}),
+	)
+	acme := pool.Tenant("acme")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			acme.Handle(string(rune('a' + i))).Put("0123456789")
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(50), acme.MemoryUsage(), "Concurrent writes cannot all pass the quota")
+	stored := 0
+	for i := 0; i < 20; i++ {
+		if value, _, _ := acme.Handle(string(rune('a' + i))).Get(0); value != nil {
+			stored++
+		}
+	}
+	assert.Equal(t, 5, stored)
+}
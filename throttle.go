@@ -0,0 +1,162 @@
+package datapool
+
+import (
+	"sync"
+	"time"
+)
+
+// ThrottleOption configures the write throttling of a bucket (see
+// Bucket.SetThrottle).
+type ThrottleOption func(*throttle)
+
+// WithMinPutInterval stores at most one Put every d: a Put arriving sooner
+// after the last stored one is held back until the interval has passed.
+func WithMinPutInterval(d time.Duration) ThrottleOption {
+	return func(t *throttle) {
+		t.minInterval = max(d, 0)
+	}
+}
+
+// WithDebounce holds every Put back until no other Put has arrived for d, so
+// a burst of Puts is stored once it is over.
+func WithDebounce(d time.Duration) ThrottleOption {
+	return func(t *throttle) {
+		t.debounce = max(d, 0)
+	}
+}
+
+// throttle holds back the Puts of a bucket. Only the latest held value is
+// kept; it is stored by a timer once the throttle allows.
+type throttle struct {
+	minInterval time.Duration
+	debounce    time.Duration
+
+	mu      sync.Mutex
+	last    time.Time
+	pending bool
+	value   any
+	// version is the bucket's version when value was Put, to drop it if
+	// another write stored a later value in the meantime.
+	version uint64
+	timer   *time.Timer
+	// timerID identifies the current timer, so that a timer stopped too
+	// late to keep it from firing does nothing.
+	timerID uint64
+}
+
+// SetThrottle throttles Put for high-frequency producers such as sensor
+// feeds, so their subscribers and timestamps are not thrashed: Puts the
+// options hold back are coalesced, the latest one winning, and stored later
+// by a timer. Put returns a zero timestamp for a value it holds back, and
+// Stats counts the values never stored as CoalescedPuts. A held value is
+// dropped if another write, which is never throttled, stores a value after
+// it. Intervals are measured in real time, not on the pool's clock.
+//
+// With both options, a burst is stored once it is over, and at most once
+// every minimum interval. SetThrottle without options turns throttling off,
+// storing any held value at once.
+func (b *Bucket) SetThrottle(opts ...ThrottleOption) {
+	bk := b.resolve("set throttle")
+	if bk == nil {
+		return
+	}
+
+	var t *throttle
+	if len(opts) > 0 {
+		t = &throttle{}
+		for _, opt := range opts {
+			opt(t)
+		}
+	}
+	if old := bk.throttle.Swap(t); old != nil {
+		b.pool.flushThrottle(bk, old, 0)
+	}
+}
+
+// Flush stores the value held back by the bucket's throttle at once, if there
+// is one, and returns its timestamp, or zero if there was none.
+func (b *Bucket) Flush() int64 {
+	bk := b.resolve("flush")
+	if bk == nil {
+		return 0
+	}
+	if t := bk.throttle.Load(); t != nil {
+		return b.pool.flushThrottle(bk, t, 0)
+	}
+	return 0
+}
+
+// throttledPut is put through t: it stores value at once if t allows, and
+// returns its timestamp, or holds it back and returns zero.
+func (p *DataPool) throttledPut(b *bucket, t *throttle, value any) int64 {
+	t.mu.Lock()
+	now := time.Now()
+	wait := t.minInterval - now.Sub(t.last)
+	if t.debounce == 0 && !t.pending && wait <= 0 {
+		t.last = now
+		t.mu.Unlock()
+		return p.put(b, value)
+	}
+
+	if t.pending {
+		b.stats.coalescedPuts.Add(1)
+	}
+	b.guard.RLock()
+	t.version = b.version
+	b.guard.RUnlock()
+	t.value = value
+	t.pending = true
+
+	switch {
+	case t.debounce > 0:
+		p.scheduleFlush(b, t, max(t.debounce, wait))
+	case t.timer == nil:
+		p.scheduleFlush(b, t, wait)
+	}
+	t.mu.Unlock()
+	return 0
+}
+
+// scheduleFlush replaces t's timer with one flushing it after d. It must be
+// called with t.mu held.
+func (p *DataPool) scheduleFlush(b *bucket, t *throttle, d time.Duration) {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	t.timerID++
+	id := t.timerID
+	t.timer = time.AfterFunc(d, func() { p.flushThrottle(b, t, id) })
+}
+
+// flushThrottle stores the value held back by t, unless a later write
+// superseded it, and returns its timestamp. timerID is the timer calling it,
+// zero if none.
+func (p *DataPool) flushThrottle(b *bucket, t *throttle, timerID uint64) int64 {
+	t.mu.Lock()
+	if timerID != 0 && timerID != t.timerID {
+		t.mu.Unlock()
+		return 0
+	}
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+		t.timerID++
+	}
+	if !t.pending {
+		t.mu.Unlock()
+		return 0
+	}
+	value, version := t.value, t.version
+	t.value, t.pending = nil, false
+	t.last = time.Now()
+	t.mu.Unlock()
+
+	b.guard.RLock()
+	superseded := b.version != version
+	b.guard.RUnlock()
+	if superseded {
+		b.stats.coalescedPuts.Add(1)
+		return 0
+	}
+	return p.put(b, value)
+}
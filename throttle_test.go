@@ -0,0 +1,94 @@
+package datapool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottleMinPutInterval(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("sensor")
+	b.SetThrottle(WithMinPutInterval(50 * time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := b.Watch(ctx)
+
+	assert.NotZero(t, b.Put(1), "The first Put is stored at once")
+	assert.Equal(t, 1, (<-updates).Value)
+	for i := 2; i <= 4; i++ {
+		assert.Zero(t, b.Put(i), "Later Puts are held back")
+	}
+
+	select {
+	case u := <-updates:
+		assert.Equal(t, 4, u.Value, "The latest value wins")
+	case <-time.After(5 * time.Second):
+		t.Fatal("The held value was not stored")
+	}
+	assert.Equal(t, uint64(2), b.Stats().CoalescedPuts)
+	assert.Equal(t, uint64(2), b.Stats().Writes)
+}
+
+func TestThrottleDebounce(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("sensor")
+	b.SetThrottle(WithDebounce(20 * time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := b.Watch(ctx)
+
+	for i := 1; i <= 5; i++ {
+		assert.Zero(t, b.Put(i))
+	}
+	select {
+	case u := <-updates:
+		assert.Equal(t, 5, u.Value)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The burst was not stored")
+	}
+	assert.Equal(t, uint64(4), b.Stats().CoalescedPuts)
+
+	select {
+	case u := <-updates:
+		t.Fatalf("Unexpected update %v", u)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestThrottleFlush(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("sensor")
+	b.SetThrottle(WithDebounce(time.Hour))
+	assert.Zero(t, b.Flush(), "Nothing to flush")
+
+	b.Put(1)
+	b.Put(2)
+	ts := b.Flush()
+	require.NotZero(t, ts)
+	value, got, _ := b.Get(0)
+	assert.Equal(t, 2, value)
+	assert.Equal(t, ts, got)
+
+	b.Put(3)
+	b.SetThrottle()
+	value, _, _ = b.Get(0)
+	assert.Equal(t, 3, value, "Turning throttling off stores the held value")
+	assert.NotZero(t, b.Put(4), "Puts are no longer throttled")
+}
+
+func TestThrottleSupersededByOtherWrites(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("sensor")
+	b.SetThrottle(WithDebounce(time.Hour))
+
+	b.Put("held")
+	pool.PutMany(map[string]any{"sensor": "direct"})
+	assert.Zero(t, b.Flush(), "A later write supersedes the held value")
+	value, _, _ := b.Get(0)
+	assert.Equal(t, "direct", value)
+	assert.Equal(t, uint64(1), b.Stats().CoalescedPuts)
+}
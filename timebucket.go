@@ -0,0 +1,185 @@
+package datapool
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// partitionLayout formats the start of a partition in its bucket's name.
+const partitionLayout = "20060102T150405Z"
+
+// defaultRetention is how many partitions a TimeBucket keeps, the current one
+// included, unless told otherwise with SetRetention.
+const defaultRetention = 2
+
+// TimeBucket is a family of buckets partitioning values by the time they are
+// Put, for rolling caches of recent events. It is created by
+// DataPool.TimeBucket.
+//
+// Each partition covers one period, starting at a multiple of the period
+// since the Unix epoch, and is a bucket named after the family and the start
+// of the period in UTC, such as "metrics/20261014T130000Z". Put stores
+// values in the current partition; every value of a partition expires at the
+// same time, once the partition falls out of the retention (see
+// SetRetention).
+type TimeBucket struct {
+	pool   *DataPool
+	name   string
+	period time.Duration
+	retain atomic.Int64
+}
+
+// TimeBucket returns a family of buckets partitioning values by period, as
+// measured by the pool's clock. Periods are whole seconds: shorter ones are
+// raised to a second, and others rounded down.
+func (p *DataPool) TimeBucket(name string, period time.Duration) *TimeBucket {
+	tb := &TimeBucket{pool: p, name: name, period: max(period.Truncate(time.Second), time.Second)}
+	tb.retain.Store(defaultRetention)
+	return tb
+}
+
+// Name returns the name the family's partitions are named after.
+func (tb *TimeBucket) Name() string {
+	return tb.name
+}
+
+// Period returns the time covered by each partition.
+func (tb *TimeBucket) Period() time.Duration {
+	return tb.period
+}
+
+// SetRetention sets how many partitions are kept, the current one included.
+// It applies to values Put afterwards; the default is 2, keeping the current
+// and the previous partition. Values below one are raised to one.
+func (tb *TimeBucket) SetRetention(n int) {
+	tb.retain.Store(int64(max(n, 1)))
+}
+
+// Retention returns how many partitions are kept.
+func (tb *TimeBucket) Retention() int {
+	return int(tb.retain.Load())
+}
+
+// start returns the start of the partition covering t, in Unix nanoseconds.
+func (tb *TimeBucket) start(t int64) int64 {
+	period := int64(tb.period)
+	start := t - t%period
+	if t%period < 0 {
+		start -= period
+	}
+	return start
+}
+
+// oldest returns the start of the oldest partition within the retention.
+func (tb *TimeBucket) oldest() int64 {
+	return tb.start(tb.pool.now()) - int64(tb.period)*(tb.retain.Load()-1)
+}
+
+// partitionName returns the name of the partition starting at start.
+func (tb *TimeBucket) partitionName(start int64) string {
+	return tb.name + "/" + time.Unix(0, start).UTC().Format(partitionLayout)
+}
+
+// At returns the partition covering t, creating its bucket if needed.
+func (tb *TimeBucket) At(t time.Time) Bucket {
+	return tb.pool.Bucket(tb.partitionName(tb.start(t.UnixNano())))
+}
+
+// Current returns the partition covering the present time.
+func (tb *TimeBucket) Current() Bucket {
+	return tb.pool.Bucket(tb.partitionName(tb.start(tb.pool.now())))
+}
+
+// Put stores value in the current partition and returns its timestamp, or 0
+// if the value was not stored. The value expires with its partition, whatever
+// the bucket's TTL.
+func (tb *TimeBucket) Put(value any) int64 {
+	p := tb.pool
+	start := tb.start(p.now())
+	name := tb.partitionName(start)
+	b, err := p.bucket(name)
+	if err != nil {
+		p.reportError(name, err)
+		return 0
+	}
+	expiresAt := start + int64(tb.period)*tb.retain.Load()
+	return p.write(b, value, expiresAt, nil)
+}
+
+// Partitions returns the names of the family's partitions within the
+// retention, oldest first. Partitions that have expired but were not removed
+// by Expire yet are left out.
+func (tb *TimeBucket) Partitions() []string {
+	var names []string
+	for _, b := range tb.partitions(tb.oldest(), false) {
+		names = append(names, b.name())
+	}
+	return names
+}
+
+// Get reads every partition within the retention at a single consistent point
+// (see GetMany), reporting for each whether its value is newer than since.
+func (tb *TimeBucket) Get(since int64) map[string]Result {
+	return tb.pool.GetMany(tb.Partitions(), since)
+}
+
+// Expire removes the partitions that fell out of the retention from the pool
+// and returns how many it removed. Their values expire on their own, but the
+// buckets are only removed by Expire, which callbacks registered with
+// OnExpire are called for if they still held a value. Pinned partitions are
+// kept.
+func (tb *TimeBucket) Expire() int {
+	p := tb.pool
+	removed := 0
+	for _, b := range tb.partitions(tb.oldest(), true) {
+		if b.pinned.Load() {
+			continue
+		}
+		b.guard.RLock()
+		held := !b.removed && b.timestamp != 0
+		b.guard.RUnlock()
+		value, ok := p.remove(b)
+		if !ok {
+			continue
+		}
+		if p.wal != nil {
+			p.logRemove(b.name())
+		}
+		if held {
+			p.fireExpire(b.name(), value)
+		}
+		removed++
+	}
+	return removed
+}
+
+// partitions returns the family's partitions starting at oldest or later, or
+// before it if expired is true, oldest first.
+func (tb *TimeBucket) partitions(oldest int64, expired bool) []*bucket {
+	var buckets []*bucket
+	for _, b := range tb.pool.all() {
+		if start, ok := tb.partitionStart(b.name()); ok && (start < oldest) == expired {
+			buckets = append(buckets, b)
+		}
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].name() < buckets[j].name()
+	})
+	return buckets
+}
+
+// partitionStart returns the start of the named partition in Unix
+// nanoseconds, and false if name is not a partition of the family.
+func (tb *TimeBucket) partitionStart(name string) (int64, bool) {
+	suffix, ok := strings.CutPrefix(name, tb.name+"/")
+	if !ok {
+		return 0, false
+	}
+	t, err := time.Parse(partitionLayout, suffix)
+	if err != nil {
+		return 0, false
+	}
+	return t.UnixNano(), true
+}
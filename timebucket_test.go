@@ -0,0 +1,100 @@
+package datapool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeBucket(t *testing.T) {
+	clock := NewManualClock(time.Date(2026, 10, 14, 13, 20, 0, 0, time.UTC))
+	pool := NewDataPool(WithClock(clock))
+	metrics := pool.TimeBucket("metrics", time.Hour)
+	assert.Equal(t, "metrics", metrics.Name())
+	assert.Equal(t, time.Hour, metrics.Period())
+	assert.Equal(t, 2, metrics.Retention())
+
+	ts := metrics.Put(1)
+	require.NotZero(t, ts)
+	current := metrics.Current()
+	assert.Equal(t, "metrics/20261014T130000Z", current.Name())
+	value, got, _ := current.Get(0)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, ts, got)
+
+	clock.Advance(time.Hour)
+	metrics.Put(2)
+	assert.Equal(t, []string{"metrics/20261014T130000Z", "metrics/20261014T140000Z"}, metrics.Partitions())
+
+	results := metrics.Get(0)
+	require.Len(t, results, 2)
+	assert.Equal(t, 1, results["metrics/20261014T130000Z"].Value)
+	assert.Equal(t, 2, results["metrics/20261014T140000Z"].Value)
+
+	previous := metrics.At(clock.Now().Add(-time.Hour))
+	assert.Equal(t, "metrics/20261014T130000Z", previous.Name())
+}
+
+func TestTimeBucketExpiresPartitions(t *testing.T) {
+	clock := NewManualClock(time.Date(2026, 10, 14, 13, 0, 0, 0, time.UTC))
+	pool := NewDataPool(WithClock(clock))
+	var expired []string
+	pool.OnExpire(func(name string, _ any) { expired = append(expired, name) })
+
+	events := pool.TimeBucket("events", time.Minute)
+	first := events.Current()
+	first.SetTTL(time.Hour)
+	events.Put("a")
+	clock.Advance(30 * time.Second)
+	events.Put("b")
+
+	clock.Advance(time.Minute)
+	value, _, _ := first.Get(0)
+	assert.Equal(t, "b", value, "The previous partition is retained")
+
+	clock.Advance(time.Minute)
+	value, _, _ = first.Get(0)
+	assert.Nil(t, value, "Values expire with their partition, whatever the TTL")
+	assert.Empty(t, events.Partitions())
+
+	assert.Equal(t, 1, events.Expire())
+	assert.Equal(t, []string{"events/20261014T130000Z"}, expired)
+	assert.Zero(t, pool.Len(), "Expired partitions are removed")
+	assert.Zero(t, events.Expire())
+}
+
+func TestTimeBucketRetention(t *testing.T) {
+	clock := NewManualClock(time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC))
+	pool := NewDataPool(WithClock(clock))
+	days := pool.TimeBucket("days", 24*time.Hour)
+	days.SetRetention(3)
+
+	for i := 0; i < 5; i++ {
+		days.Put(i)
+		clock.Advance(24 * time.Hour)
+	}
+	clock.Advance(-time.Hour)
+	assert.Equal(t, []string{"days/20261016T000000Z", "days/20261017T000000Z", "days/20261018T000000Z"}, days.Partitions())
+	assert.Equal(t, 2, days.Expire())
+
+	days.SetRetention(0)
+	assert.Equal(t, 1, days.Retention())
+}
+
+func TestTimeBucketPeriod(t *testing.T) {
+	pool := NewDataPool()
+	assert.Equal(t, time.Second, pool.TimeBucket("a", time.Millisecond).Period())
+	assert.Equal(t, 90*time.Second, pool.TimeBucket("b", 90*time.Second+time.Millisecond).Period())
+}
+
+func TestTimeBucketInvalidName(t *testing.T) {
+	var reported error
+	pool := NewDataPool(
+		WithNameRules(NameRules{MaxLength: 10}),
+		WithErrorHandler(func(_ string, err error) { reported = err }),
+	)
+	assert.Zero(t, pool.TimeBucket("metrics", time.Hour).Put(1))
+	assert.ErrorIs(t, reported, ErrInvalidName)
+}
@@ -0,0 +1,122 @@
+package datapool
+
+import "context"
+
+// Tracer starts the spans the pool wraps its calls to loaders, writers and
+// the backend in (see WithTracer). It is a narrow interface so the package
+// needs no tracing dependency; an OpenTelemetry tracer is adapted in a few
+// lines, as the README shows.
+type Tracer interface {
+	// Start starts a span named name, a child of the span in ctx if any, and
+	// returns it along with a context holding it.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// SetAttributes sets attributes of the span.
+	SetAttributes(attrs ...Attribute)
+	// RecordError records err as the span's error.
+	RecordError(err error)
+	// End ends the span.
+	End()
+}
+
+// Attribute is an attribute of a Span. Value is a string, a bool or an
+// int64.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// The names of the spans the pool starts.
+const (
+	SpanLoad       = "datapool.load"
+	SpanWrite      = "datapool.write"
+	SpanBackendGet = "datapool.backend.get"
+	SpanBackendPut = "datapool.backend.put"
+)
+
+// The keys of the attributes of the spans the pool starts. Every span has
+// AttrBucket. Spans of loads and backend reads have AttrHit, whether a value
+// was found, and spans of a call with a value, found or written, have
+// AttrSize, its approximate size in bytes. Spans of backend calls have
+// AttrConsistency.
+const (
+	AttrBucket      = "datapool.bucket"
+	AttrHit         = "datapool.hit"
+	AttrSize        = "datapool.size"
+	AttrConsistency = "datapool.consistency"
+)
+
+// WithTracer wraps the pool's calls to loaders (see WithLoader), writers (see
+// WithWriter) and the backend (see WithBackend) in spans started by tracer,
+// so cache misses, write-through and backend round trips show up in
+// distributed traces. Calls to the backend are passed the context holding
+// their span, for the backend's own spans to nest in; loaders and writers,
+// which take no context, run after their span starts.
+func WithTracer(tracer Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// startSpan starts a span for a call about the named bucket, if the pool has
+// a tracer, and returns the context to make the call with and the span, nil
+// without a tracer.
+func (p *DataPool) startSpan(ctx context.Context, name, bucket string) (context.Context, *span) {
+	t := p.opts.tracer
+	if t == nil {
+		return ctx, nil
+	}
+	ctx, s := t.Start(ctx, name)
+	s.SetAttributes(Attribute{Key: AttrBucket, Value: bucket})
+	return ctx, &span{pool: p, span: s}
+}
+
+// span wraps a Span, doing nothing if nil.
+type span struct {
+	pool *DataPool
+	span Span
+}
+
+// consistency sets the span's AttrConsistency.
+func (s *span) consistency(level Consistency) {
+	if s != nil {
+		s.span.SetAttributes(Attribute{Key: AttrConsistency, Value: level.String()})
+	}
+}
+
+// hit sets the span's AttrHit, and AttrSize to the size of value if found.
+func (s *span) hit(found bool, value any) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(Attribute{Key: AttrHit, Value: found})
+	if found {
+		s.size(value)
+	}
+}
+
+// size sets the span's AttrSize to the size of value.
+func (s *span) size(value any) {
+	if s == nil {
+		return
+	}
+	size := sizeOf
+	if m := s.pool.mem; m != nil {
+		size = m.size
+	}
+	s.span.SetAttributes(Attribute{Key: AttrSize, Value: int64(size(value))})
+}
+
+// end records err, if not nil, and ends the span.
+func (s *span) end(err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		s.span.RecordError(err)
+	}
+	s.span.End()
+}
@@ -0,0 +1,116 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTracer records the spans it starts.
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+type recordedSpan struct {
+	name   string
+	parent bool
+	attrs  map[string]any
+	err    error
+	ended  bool
+}
+
+type spanKey struct{}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	s := &recordedSpan{name: name, parent: ctx.Value(spanKey{}) != nil, attrs: make(map[string]any)}
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	t.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// named returns the spans named name.
+func (t *recordingTracer) named(name string) []*recordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var spans []*recordedSpan
+	for _, s := range t.spans {
+		if s.name == name {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...Attribute) {
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *recordedSpan) RecordError(err error) { s.err = err }
+func (s *recordedSpan) End()                  { s.ended = true }
+
+func TestTracerLoader(t *testing.T) {
+	tracer := &recordingTracer{}
+	failed := errors.New("source down")
+	pool := NewDataPool(WithTracer(tracer), WithLoader(func(name string) (any, error) {
+		switch name {
+		case "config":
+			return "value", nil
+		case "broken":
+			return nil, failed
+		}
+		return nil, nil
+	}))
+	pool.Handle("config").Get(0)
+	pool.Handle("missing").Get(0)
+	pool.Handle("broken").Get(0)
+
+	spans := tracer.named(SpanLoad)
+	require.Len(t, spans, 3)
+	assert.Equal(t, map[string]any{AttrBucket: "config", AttrHit: true, AttrSize: int64(sizeOf("value"))}, spans[0].attrs)
+	assert.True(t, spans[0].ended)
+	assert.Equal(t, map[string]any{AttrBucket: "missing", AttrHit: false}, spans[1].attrs)
+	assert.Equal(t, failed, spans[2].err)
+	assert.Equal(t, false, spans[2].attrs[AttrHit])
+}
+
+func TestTracerWriter(t *testing.T) {
+	tracer := &recordingTracer{}
+	pool := NewDataPool(WithTracer(tracer), WithWriter(func(string, any) error { return nil }))
+	pool.Handle("config").Put([]byte("abcd"))
+
+	spans := tracer.named(SpanWrite)
+	require.Len(t, spans, 1)
+	assert.Equal(t, "config", spans[0].attrs[AttrBucket])
+	assert.Equal(t, int64(sizeOf([]byte("abcd"))), spans[0].attrs[AttrSize])
+	assert.True(t, spans[0].ended)
+}
+
+func TestTracerBackend(t *testing.T) {
+	backend := &MemoryBackend{}
+	writer := NewDataPool(WithBackend(backend))
+	writer.Handle("config").Put("value")
+
+	tracer := &recordingTracer{}
+	pool := NewDataPool(WithTracer(tracer), WithBackend(backend), WithSizer(func(any) int { return 42 }))
+	pool.Handle("config").Get(0)
+	pool.Handle("missing").Get(0)
+	pool.Handle("other").Put(1)
+
+	gets := tracer.named(SpanBackendGet)
+	require.Len(t, gets, 2)
+	assert.Equal(t, map[string]any{AttrBucket: "config", AttrHit: true, AttrSize: int64(42), AttrConsistency: "default"}, gets[0].attrs)
+	assert.Equal(t, false, gets[1].attrs[AttrHit])
+
+	puts := tracer.named(SpanBackendPut)
+	require.Len(t, puts, 1)
+	assert.Equal(t, map[string]any{AttrBucket: "other", AttrSize: int64(42), AttrConsistency: "default"}, puts[0].attrs)
+	assert.True(t, puts[0].ended)
+}
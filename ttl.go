@@ -0,0 +1,89 @@
+package datapool
+
+import "time"
+
+// SetTTL makes values stored in the bucket expire ttl after they are Put.
+// An expired value reads as an empty bucket. The TTL applies to subsequent
+// Puts; a ttl of zero or less disables expiration.
+func (b *Bucket) SetTTL(ttl time.Duration) {
+	bk := b.resolve("set ttl")
+	if bk == nil {
+		return
+	}
+
+	bk.guard.Lock()
+	defer bk.guard.Unlock()
+
+	bk.ttl = max(ttl, 0)
+}
+
+// TTL returns the bucket's time to live, zero if its values do not expire.
+func (b *Bucket) TTL() time.Duration {
+	bk := b.resolve("ttl")
+	if bk == nil {
+		return 0
+	}
+
+	bk.guard.RLock()
+	defer bk.guard.RUnlock()
+
+	return bk.ttl
+}
+
+// Expire drops every expired value from the pool and returns how many were
+// dropped. Expired values are never returned by Get, so calling Expire is
+// only needed to release their memory early, or to have callbacks registered
+// with OnExpire called. It only visits the buckets due to expire, soonest
+// first (see NextExpiry).
+func (p *DataPool) Expire() int {
+	expired := 0
+	for _, b := range p.expiries.due(p.now()) {
+		b.guard.Lock()
+		dropped := !b.removed && b.timestamp != 0 && b.expiredAt(p)
+		var value any
+		if dropped {
+			value = b.current()
+			b.drop()
+			expired++
+		}
+		b.guard.Unlock()
+
+		if dropped {
+			p.fireExpire(b.name(), value)
+		}
+	}
+	return expired
+}
+
+// drop empties the bucket, keeping its value in its history. It must be
+// called with b.guard held for writing.
+func (b *bucket) drop() {
+	b.record()
+	b.value = nil
+	b.cachedErr = nil
+	b.releaseShared()
+	b.account(nil)
+	b.forgetRead()
+	b.timestamp = 0
+	b.expiresAt = 0
+	b.reschedule()
+	b.provenance = nil
+	b.schema = 0
+}
+
+// expiredAt reports whether the bucket's value has expired according to the
+// pool's clock. It must be called with b.guard held.
+func (b *bucket) expiredAt(p *DataPool) bool {
+	exp := b.expiry()
+	return exp != 0 && p.now() >= exp
+}
+
+// expiry returns the time the bucket's value expires at, zero if it does not,
+// as it does not while the bucket is pinned. It must be called with b.guard
+// held.
+func (b *bucket) expiry() int64 {
+	if b.pinned.Load() {
+		return 0
+	}
+	return b.expiresAt
+}
@@ -0,0 +1,75 @@
+package datapool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTLExpiry(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	bucket := pool.Bucket("session")
+	bucket.SetTTL(time.Minute)
+	assert.Equal(t, time.Minute, bucket.TTL())
+
+	ts := bucket.Put("token")
+	clock.Advance(59 * time.Second)
+	val, ts2, fresh := bucket.Get(0)
+	assert.Equal(t, "token", val)
+	assert.Equal(t, ts, ts2)
+	assert.True(t, fresh)
+
+	clock.Advance(time.Second)
+	val, ts2, fresh = bucket.Get(0)
+	assert.Nil(t, val, "Expired value reads as an empty bucket")
+	assert.Equal(t, int64(0), ts2)
+	assert.False(t, fresh)
+
+	// A new Put restarts the TTL.
+	bucket.Put("token2")
+	val, _, _ = bucket.Get(0)
+	assert.Equal(t, "token2", val)
+}
+
+func TestTTLAppliesToLaterPuts(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	bucket := pool.Bucket("config")
+
+	bucket.Put("forever")
+	bucket.SetTTL(time.Second)
+	clock.Advance(time.Hour)
+	val, _, _ := bucket.Get(0)
+	assert.Equal(t, "forever", val, "Values stored before SetTTL do not expire")
+
+	bucket.SetTTL(0)
+	bucket.Put("still forever")
+	clock.Advance(time.Hour)
+	val, _, _ = bucket.Get(0)
+	assert.Equal(t, "still forever", val)
+}
+
+func TestExpire(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	short := pool.Bucket("short")
+	short.SetTTL(time.Second)
+	short.Put("a")
+	long := pool.Bucket("long")
+	long.SetTTL(time.Hour)
+	long.Put("b")
+
+	assert.Equal(t, 0, pool.Expire())
+	clock.Advance(time.Minute)
+	assert.Equal(t, 1, pool.Expire())
+	assert.Equal(t, 0, pool.Expire(), "Expired values are only dropped once")
+
+	assert.Nil(t, short.b.value, "Expire releases the value")
+	assert.Equal(t, 2, pool.Len(), "Expire keeps the bucket")
+
+	infos := pool.Inspect()
+	assert.Equal(t, int64(0), infos[0].Timestamp)
+	assert.Equal(t, "b", infos[1].Value)
+}
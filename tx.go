@@ -0,0 +1,271 @@
+package datapool
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// ErrConflict is returned by Update when its transaction kept conflicting
+// with concurrent writes and ran out of attempts. It is an ErrStale.
+var ErrConflict = newKindError(ErrStale, "datapool: transaction conflict")
+
+// maxTxAttempts bounds how many times Update runs a conflicting transaction.
+const maxTxAttempts = 10
+
+// Tx is a transaction reading and writing several buckets, passed to the
+// function given to DataPool.Update. A Tx must not be used after that
+// function has returned.
+type Tx struct {
+	pool   *DataPool
+	reads  map[string]txRead
+	writes map[string]any
+	order  []string
+	done   bool
+	err    error
+}
+
+// txRead records what a transaction saw of a bucket. b is nil for buckets
+// that did not exist.
+type txRead struct {
+	b     *bucket
+	value any
+	ts    int64
+	// cached is set for a bucket holding an error stored with PutError,
+	// which Get reads as empty while the commit still checks ts.
+	cached bool
+}
+
+// Update runs fn in a transaction. The values fn Puts become visible
+// together, under a single timestamp, once fn returns nil, and only if none
+// of the buckets fn read were written in the meantime. On such a conflict fn
+// runs again, up to a bounded number of attempts after which Update returns
+// ErrConflict. If fn returns an error, or a bucket it writes cannot be
+// created, as beyond its tenant's quota, nothing is written and Update
+// returns that error.
+//
+// Since fn may run more than once, it should have no side effects outside the
+// transaction.
+func (p *DataPool) Update(fn func(tx *Tx) error) error {
+	for attempt := 0; attempt < maxTxAttempts; attempt++ {
+		tx := &Tx{
+			pool:   p,
+			reads:  make(map[string]txRead),
+			writes: make(map[string]any),
+		}
+
+		err := fn(tx)
+		tx.done = true
+		if err == nil {
+			err = tx.err
+		}
+		if err != nil {
+			return err
+		}
+		ok, err := tx.commit()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return ErrConflict
+}
+
+// Get returns the value and timestamp of the named bucket as seen by the
+// transaction. A bucket the transaction wrote returns the written value with
+// timestamp 0, as its timestamp is only assigned at commit; a bucket read
+// before returns the same value again. Buckets that do not exist, or hold an
+// error stored with PutError, read as empty and are not created.
+func (tx *Tx) Get(name string) (any, int64) {
+	if !tx.usable("get") {
+		return nil, 0
+	}
+	if value, ok := tx.writes[name]; ok {
+		return value, 0
+	}
+	if r, ok := tx.reads[name]; ok {
+		return r.get()
+	}
+
+	var r txRead
+	if b := tx.pool.find(name); b != nil {
+		b.guard.RLock()
+		if !b.removed {
+			r.b = b
+			r.value, r.ts, _ = b.read(tx.pool, 0)
+			r.cached = b.cachedErr != nil
+		}
+		b.guard.RUnlock()
+		if b.copyValues.Load() {
+			r.value = copyValue(r.value)
+		}
+	}
+	tx.reads[name] = r
+	return r.get()
+}
+
+// get returns the value and timestamp Get reports for r.
+func (r txRead) get() (any, int64) {
+	if r.cached {
+		return nil, 0
+	}
+	return r.value, r.ts
+}
+
+// Put stages value to be stored in the named bucket when the transaction
+// commits, creating the bucket if needed. The bucket's transformer, validator
+// (see Bucket.SetValidator), registered type and tenant quota are applied on
+// commit, with the bucket locked; Get returns the staged value as given. If
+// the pool's NameRules reject name, it is in the SystemNamespace, or the
+// value is rejected on commit, the transaction fails: Update writes nothing
+// and returns the error.
+func (tx *Tx) Put(name string, value any) {
+	if !tx.usable("put") {
+		return
+	}
+	err := tx.pool.checkWritable(name)
+	if b := tx.pool.find(name); err == nil && b != nil {
+		b.guard.RLock()
+		err = b.checkClaim(tx.pool.now(), 0)
+		b.guard.RUnlock()
+	}
+	if err != nil {
+		if tx.err == nil {
+			tx.err = err
+		}
+		return
+	}
+	if _, ok := tx.writes[name]; !ok {
+		tx.order = append(tx.order, name)
+	}
+	tx.writes[name] = value
+}
+
+func (tx *Tx) usable(op string) bool {
+	if tx.done {
+		tx.pool.violation("tx %s: transaction used after Update returned", op)
+		return false
+	}
+	return true
+}
+
+// commit locks every bucket the transaction touched, checks that the buckets
+// it read are unchanged, prepares its writes and stores them. It returns false
+// on a conflict, and the error of a bucket it cannot create, as beyond its
+// tenant's quota, or of a value the bucket rejects; in either case nothing is
+// written.
+func (tx *Tx) commit() (bool, error) {
+	p := tx.pool
+
+	written := make([]*bucket, 0, len(tx.order))
+	values := make([]any, 0, len(tx.order))
+	buckets := make([]*bucket, 0, len(tx.reads)+len(tx.order))
+	for _, name := range tx.order {
+		b, err := p.bucket(name)
+		if err != nil {
+			return false, fmt.Errorf("datapool: tx put %q: %w", name, err)
+		}
+		if i := slices.Index(written, b); i >= 0 {
+			// A bucket written by its name and an alias keeps the later Put.
+			values[i] = tx.writes[name]
+			continue
+		}
+		written = append(written, b)
+		values = append(values, tx.writes[name])
+		buckets = append(buckets, b)
+	}
+	for name, r := range tx.reads {
+		if r.b == nil {
+			// The bucket did not exist; if it does now, it must still be empty.
+			r.b = p.find(name)
+		}
+		if r.b != nil {
+			buckets = append(buckets, r.b)
+		}
+	}
+	buckets = lockOrder(buckets)
+
+	end := p.beginCommit()
+	for _, b := range buckets {
+		b.guard.Lock()
+	}
+	ok := tx.validate(buckets)
+	now := p.now()
+	for _, b := range written {
+		// A bucket claimed since it was staged fails the commit, so the
+		// retry's Put reports the claim.
+		ok = ok && !b.removed && b.checkClaim(now, 0) == nil
+	}
+	var err error
+	for i, b := range written {
+		if !ok {
+			break
+		}
+		if values[i], err = b.prepare(values[i]); err != nil {
+			ok = false
+		}
+	}
+
+	var ts int64
+	watchers := make([][]*watcher, len(written))
+	versions := make([]uint64, len(written))
+	if ok && len(written) > 0 {
+		ts = p.stamp()
+		for i, b := range written {
+			versions[i] = b.store(values[i], ts)
+			watchers[i] = b.watchers
+		}
+	}
+	for _, b := range buckets {
+		b.unreserve()
+		b.guard.Unlock()
+	}
+	end()
+	if err != nil {
+		return false, err
+	}
+	if !ok || len(written) == 0 {
+		return ok, nil
+	}
+
+	for i, b := range written {
+		p.notifyPut(context.Background(), b, watchers[i], Update{Bucket: b.name(), Value: values[i], Timestamp: ts, Version: versions[i]}, ConsistencyDefault)
+	}
+	p.checkMemoryPressure(ts)
+	return true, nil
+}
+
+// validate reports whether every bucket in the read set is as the
+// transaction saw it. locked holds the buckets locked for the commit.
+func (tx *Tx) validate(locked []*bucket) bool {
+	for name, r := range tx.reads {
+		b := tx.pool.find(name)
+		if b == nil {
+			if r.b != nil {
+				return false
+			}
+			continue
+		}
+		if r.b != nil && r.b != b {
+			return false
+		}
+		if !isLocked(locked, b) {
+			// Created after the commit picked its locks.
+			return false
+		}
+
+		if b.removed || b.readTimestamp(tx.pool) != r.ts {
+			return false
+		}
+	}
+	return true
+}
+
+// isLocked reports whether b is among buckets, which are in lock order.
+func isLocked(buckets []*bucket, b *bucket) bool {
+	i := sort.Search(len(buckets), func(i int) bool { return buckets[i].id >= b.id })
+	return i < len(buckets) && buckets[i] == b
+}
@@ -0,0 +1,225 @@
+package datapool
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdate(t *testing.T) {
+	pool := NewDataPool()
+	from := pool.Bucket("from")
+	from.Put(10)
+
+	err := pool.Update(func(tx *Tx) error {
+		balance, _ := tx.Get("from")
+		tx.Put("from", balance.(int)-3)
+		tx.Put("to", 3)
+
+		val, ts := tx.Get("to")
+		assert.Equal(t, 3, val, "Transactions see their own writes")
+		assert.Zero(t, ts)
+		return nil
+	})
+	require.NoError(t, err)
+
+	results := pool.GetMany([]string{"from", "to"}, 0)
+	assert.Equal(t, 7, results["from"].Value)
+	assert.Equal(t, 3, results["to"].Value)
+	assert.Equal(t, results["from"].Timestamp, results["to"].Timestamp, "One timestamp for the whole transaction")
+}
+
+func TestUpdateError(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("a")
+	ts := b.Put(1)
+
+	errAbort := errors.New("abort")
+	err := pool.Update(func(tx *Tx) error {
+		tx.Put("a", 2)
+		tx.Put("b", 2)
+		return errAbort
+	})
+	assert.Same(t, errAbort, err)
+
+	val, got, _ := b.Get(0)
+	assert.Equal(t, 1, val)
+	assert.Equal(t, ts, got)
+	assert.Equal(t, 1, pool.Len(), "Aborted transactions create no buckets")
+}
+
+func TestUpdateRejectsNewBuckets(t *testing.T) {
+	pool := NewDataPool()
+	require.NoError(t, pool.RegisterType("n/*", reflect.TypeFor[float64]()))
+	err := pool.Update(func(tx *Tx) error {
+		tx.Put("n/x", "str")
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrInvalidValue, "Buckets created by the commit check their values")
+	value, _, _ := pool.Handle("n/x").Get(0)
+	assert.Nil(t, value)
+
+	y := pool.Bucket("n/y")
+	y.SetTransformer(func(v any) any { return v.(float64) * 2 })
+	require.NoError(t, pool.Update(func(tx *Tx) error {
+		tx.Put("n/y", 1.5)
+		return nil
+	}))
+	value, _, _ = pool.Handle("n/y").Get(0)
+	assert.Equal(t, 3.0, value, "Transformers run once")
+}
+
+func TestUpdateReadOnly(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("a")
+	ts := b.Put(1)
+
+	err := pool.Update(func(tx *Tx) error {
+		val, got := tx.Get("a")
+		assert.Equal(t, 1, val)
+		assert.Equal(t, ts, got)
+
+		val, got = tx.Get("missing")
+		assert.Nil(t, val)
+		assert.Zero(t, got)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, pool.Len(), "Reads do not create buckets")
+}
+
+func TestUpdateRetriesOnConflict(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("counter")
+	b.Put(0)
+
+	attempts := 0
+	err := pool.Update(func(tx *Tx) error {
+		attempts++
+		val, _ := tx.Get("counter")
+		if attempts == 1 {
+			// A concurrent writer lands between the read and the commit.
+			b.Put(100)
+		}
+		tx.Put("counter", val.(int)+1)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+
+	val, _, _ := b.Get(0)
+	assert.Equal(t, 101, val, "The retry saw the concurrent write")
+}
+
+func TestUpdateConflictOnCreatedBucket(t *testing.T) {
+	pool := NewDataPool()
+
+	attempts := 0
+	err := pool.Update(func(tx *Tx) error {
+		attempts++
+		if val, _ := tx.Get("lock"); val != nil {
+			return nil
+		}
+		if attempts == 1 {
+			other := pool.Bucket("lock")
+			other.Put("other")
+		}
+		tx.Put("lock", "mine")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+
+	b := pool.Bucket("lock")
+	val, _, _ := b.Get(0)
+	assert.Equal(t, "other", val)
+}
+
+func TestUpdateGivesUp(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("hot")
+
+	attempts := 0
+	err := pool.Update(func(tx *Tx) error {
+		attempts++
+		tx.Get("hot")
+		b.Put(attempts)
+		tx.Put("hot", "tx")
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, maxTxAttempts, attempts)
+
+	val, _, _ := b.Get(0)
+	assert.Equal(t, maxTxAttempts, val)
+}
+
+func TestUpdateTxAfterReturn(t *testing.T) {
+	pool := NewDataPool()
+
+	var leaked *Tx
+	require.NoError(t, pool.Update(func(tx *Tx) error {
+		leaked = tx
+		return nil
+	}))
+
+	leaked.Put("a", 1)
+	assert.Equal(t, uint64(1), pool.Corruptions())
+	assert.Equal(t, 0, pool.Len())
+
+	strict := NewDataPool(WithPanicPolicy(PanicOnViolation))
+	require.NoError(t, strict.Update(func(tx *Tx) error {
+		leaked = tx
+		return nil
+	}))
+	assert.Panics(t, func() { leaked.Get("a") })
+}
+
+func TestUpdateConcurrentIncrements(t *testing.T) {
+	pool := NewDataPool()
+	pool.PutMany(map[string]any{"a": 0, "b": 0})
+
+	const workers, increments = 4, 50
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				for {
+					err := pool.Update(func(tx *Tx) error {
+						a, _ := tx.Get("a")
+						b, _ := tx.Get("b")
+						tx.Put("a", a.(int)+1)
+						tx.Put("b", b.(int)-1)
+						return nil
+					})
+					if !errors.Is(err, ErrConflict) {
+						assert.NoError(t, err)
+						break
+					}
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			r := pool.GetMany([]string{"a", "b"}, 0)
+			assert.Zero(t, r["a"].Value.(int)+r["b"].Value.(int), "Readers never see half a transaction")
+		}
+	}()
+
+	wg.Wait()
+	<-done
+
+	r := pool.GetMany([]string{"a", "b"}, 0)
+	assert.Equal(t, workers*increments, r["a"].Value)
+	assert.Equal(t, -workers*increments, r["b"].Value)
+}
@@ -0,0 +1,120 @@
+package datapool
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"slices"
+)
+
+// RegisterType declares typ the type of the values of the buckets whose name
+// matches pattern, in the syntax of path.Match as for WatchMatch, replacing
+// the type registered for pattern before, or removing it if typ is nil. A
+// value written to such a bucket must then be of type typ, or implement it if
+// typ is an interface type; nil values are allowed. Other values are rejected
+// as a validator would reject them (see Bucket.SetValidator), by the same
+// writes, with an error wrapping both ErrInvalidValue and ErrTypeMismatch, so
+// a team writing float64 into a bucket another team reads as int learns it at
+// the write rather than at the read. Values already stored are not checked.
+//
+// A bucket whose name matches several patterns has the type of the pattern
+// registered first; Bucket.Type reports it. RegisterType fails if pattern is
+// malformed.
+//
+//	pool.RegisterType("prices/*", reflect.TypeFor[float64]())
+func (p *DataPool) RegisterType(pattern string, typ reflect.Type) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("datapool: register type %q: %w", pattern, err)
+	}
+
+	p.typesMu.Lock()
+	defer p.typesMu.Unlock()
+	next := &typeRegistry{}
+	if reg := p.types.Load(); reg != nil {
+		next.rules = slices.Clone(reg.rules)
+	}
+	i := slices.IndexFunc(next.rules, func(r typeRule) bool { return r.pattern == pattern })
+	switch {
+	case typ == nil && i >= 0:
+		next.rules = slices.Delete(next.rules, i, i+1)
+	case typ == nil:
+		return nil
+	case i >= 0:
+		next.rules[i].typ = typ
+	default:
+		next.rules = append(next.rules, typeRule{pattern: pattern, typ: typ})
+	}
+	p.types.Store(next)
+	return nil
+}
+
+// Type returns the type registered for the bucket's values with
+// RegisterType, or nil if there is none.
+func (b *Bucket) Type() reflect.Type {
+	bk := b.resolve("type")
+	if bk == nil {
+		return nil
+	}
+	return bk.valueType()
+}
+
+// typeRegistry holds the types registered with RegisterType. It is replaced,
+// never changed, when a type is registered.
+type typeRegistry struct {
+	rules []typeRule
+}
+
+type typeRule struct {
+	pattern string
+	typ     reflect.Type
+}
+
+// lookup returns the type of the values of the named bucket, or nil.
+func (r *typeRegistry) lookup(name string) reflect.Type {
+	for _, rule := range r.rules {
+		if ok, _ := path.Match(rule.pattern, name); ok {
+			return rule.typ
+		}
+	}
+	return nil
+}
+
+// typeBinding caches the type a registry gives a bucket under a name, so
+// writes match the patterns once per registration or rename.
+type typeBinding struct {
+	reg  *typeRegistry
+	name string
+	typ  reflect.Type
+}
+
+// valueType returns the type registered for the bucket's values, or nil.
+func (b *bucket) valueType() reflect.Type {
+	if b.types == nil {
+		return nil
+	}
+	reg := b.types.Load()
+	if reg == nil {
+		return nil
+	}
+	name := b.name()
+	if c := b.typeBinding.Load(); c != nil && c.reg == reg && c.name == name {
+		return c.typ
+	}
+	typ := reg.lookup(name)
+	b.typeBinding.Store(&typeBinding{reg: reg, name: name, typ: typ})
+	return typ
+}
+
+// checkType returns an error wrapping ErrTypeMismatch if value is not of the
+// type registered for the bucket's values.
+func (b *bucket) checkType(value any) error {
+	typ := b.valueType()
+	if typ == nil || value == nil {
+		return nil
+	}
+	got := reflect.TypeOf(value)
+	if got == typ || typ.Kind() == reflect.Interface && got.Implements(typ) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s, not %s", ErrTypeMismatch, got, typ)
+}
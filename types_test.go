@@ -0,0 +1,62 @@
+package datapool
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterType(t *testing.T) {
+	var reported error
+	pool := NewDataPool(WithErrorHandler(func(_ string, err error) { reported = err }))
+	require.NoError(t, pool.RegisterType("prices/*", reflect.TypeFor[float64]()))
+
+	eur := pool.Bucket("prices/eur")
+	assert.Equal(t, reflect.TypeFor[float64](), eur.Type())
+	other := pool.Bucket("other")
+	assert.Nil(t, other.Type())
+
+	_, err := eur.PutE(3)
+	assert.ErrorIs(t, err, ErrInvalidValue)
+	assert.ErrorIs(t, err, ErrTypeMismatch)
+	assert.Contains(t, err.Error(), "int, not float64")
+
+	assert.Zero(t, eur.Put(3))
+	assert.ErrorIs(t, reported, ErrTypeMismatch, "Put reports the error")
+	value, _, _ := eur.Get(0)
+	assert.Nil(t, value, "Values of another type are not stored")
+
+	assert.NotZero(t, eur.Put(1.5))
+	assert.NotZero(t, eur.Put(nil), "nil values are allowed")
+	assert.NotZero(t, other.Put(3), "Other buckets are not checked")
+
+	ts := pool.PutMany(map[string]any{"prices/usd": "1.5", "prices/gbp": 1.2})
+	assert.Zero(t, ts["prices/usd"], "Batches are checked")
+	assert.NotZero(t, ts["prices/gbp"])
+
+	require.NoError(t, pool.RegisterType("prices/*", nil))
+	assert.Nil(t, eur.Type())
+	assert.NotZero(t, eur.Put(3), "Removed types are not checked")
+}
+
+func TestRegisterTypeInterface(t *testing.T) {
+	pool := NewDataPool()
+	require.NoError(t, pool.RegisterType("timeouts/*", reflect.TypeFor[fmt.Stringer]()))
+	require.NoError(t, pool.RegisterType("*/*", reflect.TypeFor[string]()))
+
+	b := pool.Bucket("timeouts/read")
+	assert.Equal(t, reflect.TypeFor[fmt.Stringer](), b.Type(), "The first pattern registered wins")
+	_, err := b.PutE(time.Second)
+	assert.NoError(t, err, "Values may implement interface types")
+	_, err = b.PutE("1s")
+	assert.ErrorIs(t, err, ErrTypeMismatch)
+
+	require.NoError(t, pool.Rename("timeouts/read", "names/read"))
+	assert.Equal(t, reflect.TypeFor[string](), b.Type(), "Renamed buckets have the type of their new name")
+
+	assert.Error(t, pool.RegisterType("[", reflect.TypeFor[int]()))
+}
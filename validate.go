@@ -0,0 +1,96 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidValue is wrapped, along with the validator's own error, by the
+// errors of writes rejected by a bucket's validator (see SetValidator).
+var ErrInvalidValue = errors.New("datapool: invalid value")
+
+// Validator checks a value written to a bucket, returning an error to reject
+// it (see Bucket.SetValidator).
+type Validator func(value any) error
+
+// Transformer returns the value to store for a value written to a bucket, for
+// normalizing values before storage (see Bucket.SetTransformer).
+type Transformer func(value any) any
+
+// SetValidator sets the validator checking every value written to the
+// bucket, or removes it if validate is nil. Rejected values are not stored:
+// PutE and PutConsistent return the error, and other writes report it to the
+// error handler and store nothing, as they do for invalid names.
+//
+// Validators and transformers apply to the values written to the bucket in
+// this pool: Put and its variants, PutMany, Swap and Update, transactions,
+// loaders, imports and Merge. Values received from a backend or replayed from
+// a write-ahead log were checked where they were written, and PutBytes
+// buffers are stored as they are. Both run with the bucket locked, so they
+// must be quick and must not use the bucket.
+func (b *Bucket) SetValidator(validate Validator) {
+	bk := b.resolve("set validator")
+	if bk == nil {
+		return
+	}
+
+	bk.guard.Lock()
+	defer bk.guard.Unlock()
+
+	bk.validate = validate
+}
+
+// SetTransformer sets the transformer applied to every value written to the
+// bucket before it is validated and stored, or removes it if transform is
+// nil. See SetValidator for the writes it applies to.
+func (b *Bucket) SetTransformer(transform Transformer) {
+	bk := b.resolve("set transformer")
+	if bk == nil {
+		return
+	}
+
+	bk.guard.Lock()
+	defer bk.guard.Unlock()
+
+	bk.transform = transform
+}
+
+// PutE is Put returning the error of a value it does not store, and the
+// timestamp zero: the error of the bucket's validator, which wraps
+// ErrInvalidValue, ErrSystemBucket for system buckets, and ErrBucketNotFound
+// if the handle does not refer to a bucket or the bucket was removed.
+func (b *Bucket) PutE(value any) (int64, error) {
+	bk, err := b.lookup("put")
+	if err != nil {
+		return 0, err
+	}
+	if bk.system {
+		return 0, fmt.Errorf("%w: %q", ErrSystemBucket, bk.name())
+	}
+	u, err := b.pool.writeAt(context.Background(), bk, Update{Value: value}, 0, ConsistencyDefault)
+	if err == nil && u.Timestamp == 0 {
+		err = bk.checkRemoved()
+	}
+	return u.Timestamp, err
+}
+
+// prepare returns the value to store for value, transformed, or the error
+// rejecting it. It must be called with b.guard held.
+func (b *bucket) prepare(value any) (any, error) {
+	if b.transform != nil {
+		value = b.transform(value)
+	}
+	if err := b.checkType(value); err != nil {
+		return nil, fmt.Errorf("%w: bucket %q: %w", ErrInvalidValue, b.name(), err)
+	}
+	if b.validate != nil {
+		if err := b.validate(value); err != nil {
+			return nil, fmt.Errorf("%w: bucket %q: %w", ErrInvalidValue, b.name(), err)
+		}
+	}
+	if err := b.checkQuota(value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,123 @@
+package datapool
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errNotLower = errors.New("not lower case")
+
+// lowerCase rejects anything but lower-case strings.
+func lowerCase(value any) error {
+	s, ok := value.(string)
+	if !ok || s != strings.ToLower(s) {
+		return errNotLower
+	}
+	return nil
+}
+
+func TestValidator(t *testing.T) {
+	var reported []error
+	pool := NewDataPool(WithErrorHandler(func(name string, err error) { reported = append(reported, err) }))
+	b := pool.Bucket("color")
+	b.SetValidator(lowerCase)
+
+	ts, err := b.PutE("red")
+	require.NoError(t, err)
+	assert.NotZero(t, ts)
+
+	ts, err = b.PutE("Blue")
+	assert.ErrorIs(t, err, ErrInvalidValue)
+	assert.ErrorIs(t, err, errNotLower)
+	assert.Zero(t, ts)
+	assert.Empty(t, reported, "PutE returns the error instead of reporting it")
+
+	assert.Zero(t, b.Put("Green"))
+	require.Len(t, reported, 1)
+	assert.ErrorIs(t, reported[0], errNotLower)
+	value, _, _ := b.Get(0)
+	assert.Equal(t, "red", value, "Rejected values are not stored")
+
+	b.SetValidator(nil)
+	assert.NotZero(t, b.Put("Green"))
+}
+
+func TestTransformer(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("color")
+	b.SetTransformer(func(value any) any {
+		if s, ok := value.(string); ok {
+			return strings.ToLower(strings.TrimSpace(s))
+		}
+		return value
+	})
+	b.SetValidator(lowerCase)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := b.Watch(ctx)
+
+	_, err := b.PutE("  Blue ")
+	require.NoError(t, err, "Values are transformed before they are validated")
+	value, _, _ := b.Get(0)
+	assert.Equal(t, "blue", value)
+	assert.Equal(t, "blue", (<-updates).Value)
+
+	_, err = b.PutE(42)
+	assert.ErrorIs(t, err, errNotLower)
+}
+
+func TestValidatorWrites(t *testing.T) {
+	var reported []string
+	pool := NewDataPool(WithErrorHandler(func(name string, err error) {
+		if errors.Is(err, ErrInvalidValue) {
+			reported = append(reported, name)
+		}
+	}))
+	b := pool.Bucket("color")
+	b.SetValidator(lowerCase)
+	b.Put("red")
+
+	timestamps := pool.PutMany(map[string]any{"color": "Red", "other": "Other"})
+	assert.Zero(t, timestamps["color"])
+	assert.NotZero(t, timestamps["other"], "Other values of the batch are stored")
+
+	assert.Zero(t, b.Update(func(any) any { return "Red" }))
+	old, oldTs := b.Swap("Red")
+	assert.Nil(t, old)
+	assert.Zero(t, oldTs)
+	assert.Zero(t, b.PutSchema("Red", 2))
+	_, err := b.PutConsistent(context.Background(), "Red", ConsistencyLeader)
+	assert.ErrorIs(t, err, ErrInvalidValue)
+
+	err = pool.Update(func(tx *Tx) error {
+		tx.Put("color", "Red")
+		return nil
+	})
+	assert.ErrorIs(t, err, errNotLower)
+
+	other := NewDataPool()
+	other.Handle("color").Put("Red")
+	merged, err := pool.Merge(other, MergeOverwrite)
+	require.NoError(t, err)
+	assert.Zero(t, merged)
+
+	value, _, _ := b.Get(0)
+	assert.Equal(t, "red", value)
+	assert.Equal(t, []string{"color", "color", "color", "color", "color"}, reported)
+}
+
+func TestValidatorLoader(t *testing.T) {
+	pool := NewDataPool(WithLoader(func(name string) (any, error) { return "  Loaded", nil }))
+	b := pool.Bucket("color")
+	b.SetTransformer(func(value any) any { return strings.ToLower(strings.TrimSpace(value.(string))) })
+
+	value, ts, _ := b.Get(0)
+	assert.Equal(t, "loaded", value, "Loaded values are transformed too")
+	assert.NotZero(t, ts)
+}
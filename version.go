@@ -0,0 +1,123 @@
+package datapool
+
+import "context"
+
+// Version returns the version of the bucket's value: a count of the values
+// stored in the bucket, increasing with every one of them, or zero if none
+// was. Unlike timestamps, versions do not depend on clocks: a value with a
+// higher version than another of the same bucket was stored after it. Values
+// received from a backend keep their version if it is ahead of the bucket's,
+// so a value written after another was read is versioned after it, whichever
+// pools wrote them.
+//
+// Versions outlive values: a bucket whose value expired or was evicted keeps
+// counting from its last version. A bucket removed from the pool and created
+// again starts over.
+func (b *Bucket) Version() uint64 {
+	bk := b.resolve("version")
+	if bk == nil {
+		return 0
+	}
+
+	bk.guard.RLock()
+	defer bk.guard.RUnlock()
+
+	return bk.version
+}
+
+// PutVersioned is Put returning the stored value's version along with its
+// timestamp, both zero if the value was not stored.
+func (b *Bucket) PutVersioned(value any) (int64, uint64) {
+	bk := b.resolve("put versioned")
+	if bk == nil {
+		return 0, 0
+	}
+	u, err := b.pool.writeAt(context.Background(), bk, Update{Value: value}, 0, ConsistencyDefault)
+	if err != nil {
+		b.pool.reportError(bk.name(), err)
+	}
+	return u.Timestamp, u.Version
+}
+
+// GetVersioned is Get comparing versions instead of timestamps: it returns
+// the bucket's value, its version, and whether that is higher than
+// sinceVersion. Unlike Get, GetVersioned does not consult the backend or the
+// loader.
+func (b *Bucket) GetVersioned(sinceVersion uint64) (any, uint64, bool) {
+	bk := b.resolve("get versioned")
+	if bk == nil {
+		return nil, sinceVersion, false
+	}
+
+	bk.guard.RLock()
+	defer bk.guard.RUnlock()
+
+	if bk.removed {
+		return nil, sinceVersion, false
+	}
+	value, ts, _ := bk.read(b.pool, 0)
+	if bk.copyValues.Load() {
+		value = copyValue(value)
+	}
+	fresh := ts != 0 && bk.version > sinceVersion && !bk.frozen
+	return value, bk.version, fresh
+}
+
+// WatchSince is Watch starting from a version: the channel first receives the
+// bucket's value if its version is higher than sinceVersion, then every later
+// value, none of them twice. A consumer that saves the Version of the last
+// Update it handled can resume from it. As with Watch, a receiver that falls
+// behind by more than the watch buffer loses the oldest pending updates.
+func (b *Bucket) WatchSince(ctx context.Context, sinceVersion uint64) <-chan Update {
+	// Watch before reading, so a Put in between is not missed.
+	updates := b.Watch(ctx)
+	out := make(chan Update, b.watchBuffer())
+
+	var current Update
+	if bk := b.resolve("watch since"); bk != nil {
+		bk.guard.RLock()
+		value, ts, _ := bk.read(b.pool, 0)
+		current = Update{
+			Bucket:     bk.name(),
+			Value:      value,
+			Timestamp:  ts,
+			Provenance: bk.provenance,
+			Schema:     bk.schema,
+			Version:    bk.version,
+		}
+		bk.guard.RUnlock()
+		if ts == 0 {
+			// Nothing to send first.
+			current.Version = 0
+		}
+		if bk.copyValues.Load() {
+			current.Value = copyValue(current.Value)
+		}
+	}
+
+	go func() {
+		defer close(out)
+		last := sinceVersion
+		send := func(u Update) bool {
+			if u.Version <= last {
+				return true
+			}
+			select {
+			case out <- u:
+				last = u.Version
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if !send(current) {
+			return
+		}
+		for u := range updates {
+			if !send(u) {
+				return
+			}
+		}
+	}()
+	return out
+}
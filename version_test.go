@@ -0,0 +1,128 @@
+package datapool
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersion(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("config")
+	assert.Zero(t, b.Version())
+
+	_, v1 := b.PutVersioned("a")
+	assert.Equal(t, uint64(1), v1)
+	b.Put("b")
+	assert.Equal(t, uint64(2), b.Version())
+	b.Update(func(any) any { return "c" })
+	pool.PutMany(map[string]any{"config": "d"})
+	ts, v5 := b.PutVersioned("e")
+	assert.Equal(t, uint64(5), v5, "Every write counts")
+
+	value, version, fresh := b.GetVersioned(v1)
+	assert.Equal(t, "e", value)
+	assert.Equal(t, v5, version)
+	assert.True(t, fresh)
+	_, _, fresh = b.GetVersioned(v5)
+	assert.False(t, fresh)
+
+	_, got, _ := b.Get(0)
+	assert.Equal(t, ts, got)
+}
+
+func TestVersionOutlivesValues(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	pool := NewDataPool(WithClock(clock))
+	b := pool.Bucket("session")
+	b.SetTTL(time.Minute)
+	b.Put("token")
+	clock.Advance(2 * time.Minute)
+
+	value, version, fresh := b.GetVersioned(0)
+	assert.Nil(t, value)
+	assert.Equal(t, uint64(1), version)
+	assert.False(t, fresh, "Expired values are never fresh")
+
+	_, version = b.PutVersioned("new")
+	assert.Equal(t, uint64(2), version)
+
+	pool.Clear()
+	b = pool.Bucket("session")
+	_, version = b.PutVersioned("again")
+	assert.Equal(t, uint64(1), version, "Removed buckets start over")
+}
+
+func TestVersionWatch(t *testing.T) {
+	pool := NewDataPool()
+	b := pool.Bucket("feed")
+	b.Put("a")
+	_, v2 := b.PutVersioned("b")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := b.Watch(ctx)
+	since := b.WatchSince(ctx, 1)
+
+	_, v3 := b.PutVersioned("c")
+	u := <-updates
+	assert.Equal(t, v3, u.Version, "Updates carry versions")
+
+	u = <-since
+	assert.Equal(t, "b", u.Value, "The current value comes first")
+	assert.Equal(t, v2, u.Version)
+	u = <-since
+	assert.Equal(t, "c", u.Value)
+	assert.Equal(t, v3, u.Version)
+
+	caughtUp := b.WatchSince(ctx, v3)
+	b.Put("d")
+	u = <-caughtUp
+	assert.Equal(t, "d", u.Value, "Values up to sinceVersion are skipped")
+
+	cancel()
+	_, open := <-caughtUp
+	for open {
+		_, open = <-caughtUp
+	}
+}
+
+func TestVersionBackend(t *testing.T) {
+	backend := &MemoryBackend{}
+	a := NewDataPool(WithBackend(backend), WithPeerName("a"))
+	b := NewDataPool(WithBackend(backend), WithPeerName("b"))
+
+	ab := a.Bucket("config")
+	for i := 0; i < 5; i++ {
+		ab.Put(i)
+	}
+
+	bb := b.Bucket("config")
+	value, _, _ := bb.Get(0)
+	require.Equal(t, 4, value)
+	assert.Equal(t, uint64(5), bb.Version(), "Values read from the backend keep their version")
+
+	_, version := bb.PutVersioned("from b")
+	assert.Equal(t, uint64(6), version, "Later writes are versioned after what was read")
+}
+
+func TestVersionWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+	w := openWAL(t, path)
+	pool := NewDataPool(WithWAL(w))
+	b := pool.Bucket("config")
+	b.Put(1)
+	require.NoError(t, pool.CompactWAL())
+	b.Put(2)
+	require.NoError(t, w.Close())
+
+	restored := NewDataPool(WithWAL(openWAL(t, path)))
+	rb := restored.Bucket("config")
+	assert.Equal(t, uint64(2), rb.Version(), "Versions are replayed")
+	_, version := rb.PutVersioned(3)
+	assert.Equal(t, uint64(3), version)
+}
@@ -0,0 +1,804 @@
+package datapool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// walMagic starts every write-ahead log file, followed by the length and name
+// of the codec its values are encoded with.
+const walMagic = "DPWAL1"
+
+// defaultWALCompaction is how many records are appended to a log between two
+// automatic compactions when WithWALCompaction is not used.
+const defaultWALCompaction = 10000
+
+// Record operations.
+const (
+	walPut        = 1 // a value, without a version
+	walDelete     = 2
+	walPutVersion = 3 // a value and its version
+	walChunk      = 4 // a chunk of the value of the record before it
+)
+
+// How a record's value is stored.
+const (
+	walValue   = 0 // encoded with the log's codec
+	walNil     = 1
+	walBytes   = 2 // a []byte, as is
+	walEncoded = 3 // the data of an Encoded of the log's codec, as is
+
+	// walChunked flags the kind of a record whose value follows it in
+	// walChunk records (see WithWALChunkSize).
+	walChunked = 0x80
+
+	// walSealed flags the kind of a value encrypted with the log's cipher
+	// (see WithWALCipher).
+	walSealed = 0x40
+)
+
+// ErrCorruptWAL is returned by OpenWAL for a log whose contents fail their
+// checksums before its last record, unless it repairs them (see
+// WithWALRepair). A damaged last record is the trace of an interrupted write
+// and is dropped instead.
+var ErrCorruptWAL = errors.New("datapool: corrupt wal")
+
+// WAL is an append-only write-ahead log making a pool's values survive
+// restarts (see WithWAL). Every value Put is appended to the log before Put
+// returns; a pool created with the log replays it, and the log is compacted
+// now and then by rewriting it with the pool's current values only.
+//
+// Values are encoded with the log's codec and decoded into an any, so they
+// come back as the types the codec decodes into, like float64 and
+// map[string]any for JSON. []byte values and Encoded values of the log's
+// codec (see PutEncoded) are logged as they are and come back unchanged.
+type WAL struct {
+	path         string
+	codec        Codec
+	sync         bool
+	compactEvery int
+	chunkSize    int
+	repair       WALRepair
+	cipher       Cipher
+
+	mu        sync.Mutex
+	f         *os.File
+	size      int64
+	records   int
+	compacted int
+	pool      *DataPool
+	replay    []walRecord
+	report    WALReport
+	// reseal is set when a value was read in the clear or sealed with an
+	// earlier key than the cipher's current one.
+	reseal bool
+
+	compacting atomic.Bool
+}
+
+// WALOption configures a WAL opened with OpenWAL.
+type WALOption func(*WAL)
+
+// WithWALCodec sets the codec values are logged with. The default is
+// JSONCodec. A log must be reopened with the codec it was written with.
+func WithWALCodec(codec Codec) WALOption {
+	return func(w *WAL) {
+		w.codec = codec
+	}
+}
+
+// WithWALSync sets whether every record is flushed to stable storage before
+// Put returns, which it is by default. Without it, records written before a
+// process crash survive, but not those written shortly before a power loss.
+func WithWALSync(sync bool) WALOption {
+	return func(w *WAL) {
+		w.sync = sync
+	}
+}
+
+// WithWALCompaction makes the log compact itself in the background once n
+// records have been appended since it was last compacted. The default is
+// 10000; zero or less disables automatic compaction, leaving it to
+// CompactWAL.
+func WithWALCompaction(n int) WALOption {
+	return func(w *WAL) {
+		w.compactEvery = max(n, 0)
+	}
+}
+
+// WithWALChunkSize sets the size above which values are logged in chunks of
+// n bytes, each with its own checksum, so a large value is not written as one
+// huge record, in the log as in its compactions. The default is
+// DefaultChunkSize.
+func WithWALChunkSize(n int) WALOption {
+	return func(w *WAL) {
+		if n > 0 {
+			w.chunkSize = n
+		}
+	}
+}
+
+// WithWALCipher encrypts the values in the log, and in its compactions, with
+// cipher, such as one from NewAESGCMCipher; bucket names, timestamps and
+// expiration times are not encrypted. A log holding values in the clear or
+// sealed with an earlier key of the cipher is rewritten with the current key
+// when it is opened, so keys can be rotated, and existing logs encrypted, by
+// reopening them with the new cipher.
+func WithWALCipher(cipher Cipher) WALOption {
+	return func(w *WAL) {
+		w.cipher = cipher
+	}
+}
+
+type walRecord struct {
+	op        byte
+	name      string
+	timestamp int64
+	expiresAt int64
+	version   uint64
+	kind      byte
+	data      []byte
+	value     any
+}
+
+// OpenWAL opens the write-ahead log at path, creating it if it does not
+// exist, and reads the values to replay into the pool it is passed to with
+// WithWAL. Reading the log checks every record (see Report). A damaged last
+// record, left by a write that was interrupted, is cut off; damage anywhere
+// else fails with ErrCorruptWAL, unless WithWALRepair allows repairing it.
+func OpenWAL(path string, opts ...WALOption) (*WAL, error) {
+	w := &WAL{path: path, codec: JSONCodec, sync: true, compactEvery: defaultWALCompaction, chunkSize: DefaultChunkSize}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("datapool: open wal: %w", err)
+	}
+	if f, err = w.load(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("datapool: open wal %s: %w", path, err)
+	}
+	w.f = f
+	return w, nil
+}
+
+// load reads the log from f and returns the file to append to, positioned at
+// its end: f, or the log rewritten without the damage repaired.
+func (w *WAL) load(f *os.File) (*os.File, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return f, err
+	}
+	if info.Size() == 0 {
+		header := w.header()
+		if _, err := f.Write(header); err != nil {
+			return f, err
+		}
+		w.size = int64(len(header))
+		if err := syncDir(w.path); err != nil {
+			return f, err
+		}
+		return f, w.syncFile(f)
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return f, err
+	}
+	if err := w.checkHeader(data); err != nil {
+		return f, err
+	}
+	latest := make(map[string]walRecord)
+	end, err := w.scan(data, latest, &w.report)
+	if err != nil {
+		return f, err
+	}
+	w.size = end
+	w.records = w.report.Records
+	if w.report.Torn && w.report.Corrupt == 0 {
+		// Drop the remains of the interrupted write, so the next record is
+		// appended after the last complete one.
+		if err := f.Truncate(w.size); err != nil {
+			return f, err
+		}
+	}
+	if err := w.decodeLatest(latest, &w.report); err != nil {
+		return f, err
+	}
+	if w.report.Corrupt > 0 && w.repair == WALRestoreSnapshot {
+		w.restore(latest)
+	}
+
+	for _, rec := range latest {
+		if rec.op == walDelete {
+			continue
+		}
+		w.replay = append(w.replay, rec)
+	}
+	sort.Slice(w.replay, func(i, j int) bool {
+		return w.replay[i].timestamp < w.replay[j].timestamp
+	})
+	w.report.Values = len(w.replay)
+	if w.reseal = w.reseal && w.cipher != nil; w.reseal {
+		for i := range w.replay {
+			if err := w.resealRecord(&w.replay[i]); err != nil {
+				return f, fmt.Errorf("reseal %q: %w", w.replay[i].name, err)
+			}
+		}
+	}
+
+	if w.report.Corrupt > 0 || w.reseal {
+		rewritten, err := w.rewrite()
+		if err != nil {
+			if w.report.Corrupt == 0 {
+				return f, fmt.Errorf("reseal: %w", err)
+			}
+			return f, fmt.Errorf("repair: %w", err)
+		}
+		f.Close()
+		f = rewritten
+		w.report.Repaired = w.report.Corrupt > 0
+		w.report.Resealed = w.reseal
+	} else if _, err := f.Seek(w.size, io.SeekStart); err != nil {
+		return f, err
+	}
+	for i := range w.replay {
+		w.replay[i].data = nil
+	}
+	w.compacted = w.records
+	return f, nil
+}
+
+func (w *WAL) header() []byte {
+	name := w.codec.Name()
+	return append(append([]byte(walMagic), byte(len(name))), name...)
+}
+
+func (w *WAL) checkHeader(data []byte) error {
+	if len(data) < len(walMagic)+1 || string(data[:len(walMagic)]) != walMagic {
+		return fmt.Errorf("%w: bad header", ErrCorruptWAL)
+	}
+	n := int(data[len(walMagic)])
+	data = data[len(walMagic)+1:]
+	if len(data) < n {
+		return fmt.Errorf("%w: bad header", ErrCorruptWAL)
+	}
+	if name := string(data[:n]); name != w.codec.Name() {
+		return fmt.Errorf("written with codec %q, not %q", name, w.codec.Name())
+	}
+	return nil
+}
+
+// errTornRecord marks a last record cut short or damaged by an interrupted
+// write.
+var errTornRecord = errors.New("torn record")
+
+// parseWALRecord parses the record at the start of buf, with the chunks of
+// its value if it is chunked, and returns it with its length in the file. A
+// record running past the end of buf, or damaged and last, is reported as
+// errTornRecord.
+func parseWALRecord(buf []byte) (walRecord, int64, error) {
+	body, n, err := parseWALFrame(buf)
+	if err != nil {
+		return walRecord{}, 0, err
+	}
+	rec, err := decodeWALRecord(body)
+	if err != nil {
+		return walRecord{}, 0, err
+	}
+	if rec.kind&walChunked == 0 {
+		return rec, n, nil
+	}
+
+	rec.kind &^= walChunked
+	var data []byte
+	for seq := uint64(0); ; seq++ {
+		body, m, err := parseWALFrame(buf[n:])
+		if err != nil {
+			return walRecord{}, 0, err
+		}
+		if len(body) < 2 || body[0] != walChunk {
+			return walRecord{}, 0, fmt.Errorf("%w: missing chunk %d of %q", ErrCorruptWAL, seq, rec.name)
+		}
+		got, k := binary.Uvarint(body[2:])
+		if k <= 0 || got != seq {
+			return walRecord{}, 0, fmt.Errorf("%w: missing chunk %d of %q", ErrCorruptWAL, seq, rec.name)
+		}
+		data = append(data, body[2+k:]...)
+		n += m
+		if body[1] != 0 {
+			break
+		}
+	}
+	rec.data = data
+	return rec, n, nil
+}
+
+// parseWALFrame checks the frame at the start of buf and returns its body and
+// length in the file.
+func parseWALFrame(buf []byte) ([]byte, int64, error) {
+	if len(buf) < 8 {
+		return nil, 0, errTornRecord
+	}
+	length := binary.LittleEndian.Uint32(buf[:4])
+	if uint64(length) > uint64(len(buf)-8) {
+		return nil, 0, errTornRecord
+	}
+	body := buf[8 : 8+int(length)]
+	if crc32.Checksum(body, castagnoli) != binary.LittleEndian.Uint32(buf[4:8]) {
+		if 8+len(body) == len(buf) {
+			return nil, 0, errTornRecord
+		}
+		return nil, 0, fmt.Errorf("%w: record fails its checksum", ErrCorruptWAL)
+	}
+	return body, int64(8 + len(body)), nil
+}
+
+func decodeWALRecord(body []byte) (walRecord, error) {
+	bad := fmt.Errorf("%w: malformed record", ErrCorruptWAL)
+	if len(body) < 2 {
+		return walRecord{}, bad
+	}
+	rec := walRecord{op: body[0], kind: body[1]}
+	body = body[2:]
+
+	nameLen, n := binary.Uvarint(body)
+	if n <= 0 || uint64(len(body)-n) < nameLen {
+		return walRecord{}, bad
+	}
+	rec.name = string(body[n : n+int(nameLen)])
+	body = body[n+int(nameLen):]
+
+	if rec.timestamp, n = binary.Varint(body); n <= 0 {
+		return walRecord{}, bad
+	}
+	body = body[n:]
+	if rec.expiresAt, n = binary.Varint(body); n <= 0 {
+		return walRecord{}, bad
+	}
+	body = body[n:]
+	switch rec.op {
+	case walPut, walDelete:
+	case walChunk:
+		return walRecord{}, fmt.Errorf("%w: chunk outside a record", ErrCorruptWAL)
+	case walPutVersion:
+		if rec.version, n = binary.Uvarint(body); n <= 0 {
+			return walRecord{}, bad
+		}
+		body = body[n:]
+	default:
+		return walRecord{}, fmt.Errorf("%w: unknown operation %d", ErrCorruptWAL, rec.op)
+	}
+	rec.data = body
+	return rec, nil
+}
+
+// encode returns the framed record: its length, its CRC-32C and the record
+// itself, holding rec's value encoded, followed by the chunks of the value if
+// it is larger than the chunk size.
+func (w *WAL) encode(rec walRecord) ([]byte, error) {
+	kind, data, err := w.encodeValue(rec)
+	if err != nil {
+		return nil, fmt.Errorf("datapool: wal: encode %s value of %q: %w", w.codec.Name(), rec.name, err)
+	}
+	if len(data) <= w.chunkSize {
+		return frameWALRecord(rec, kind, data), nil
+	}
+	var buf bytes.Buffer
+	w.writeRecord(&buf, rec, kind, data)
+	return buf.Bytes(), nil
+}
+
+// writeRecord writes the framed record holding data, a value of the given
+// kind, to dst, and returns its length. Data larger than the chunk size is
+// written after the record in walChunk records, split by a ChunkWriter.
+func (w *WAL) writeRecord(dst io.Writer, rec walRecord, kind byte, data []byte) (int64, error) {
+	if len(data) <= w.chunkSize {
+		frame := frameWALRecord(rec, kind, data)
+		_, err := dst.Write(frame)
+		return int64(len(frame)), err
+	}
+
+	head := frameWALRecord(rec, kind|walChunked, nil)
+	if _, err := dst.Write(head); err != nil {
+		return 0, err
+	}
+	size := int64(len(head))
+	cw := NewChunkWriter(w.chunkSize, func(c Chunk) error {
+		frame := frameWALChunk(c)
+		size += int64(len(frame))
+		_, err := dst.Write(frame)
+		return err
+	})
+	cw.Write(data)
+	return size, cw.Close()
+}
+
+// frameWALChunk returns the framed walChunk record holding c. The frame's
+// checksum covers the chunk's.
+func frameWALChunk(c Chunk) []byte {
+	frame := make([]byte, 8, 8+2+binary.MaxVarintLen64+len(c.Data))
+	last := byte(0)
+	if c.Last {
+		last = 1
+	}
+	frame = append(frame, walChunk, last)
+	frame = binary.AppendUvarint(frame, uint64(c.Seq))
+	frame = append(frame, c.Data...)
+
+	body := frame[8:]
+	binary.LittleEndian.PutUint32(frame[:4], uint32(len(body)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(body, castagnoli))
+	return frame
+}
+
+// frameWALRecord returns the framed record holding data, a value of the given
+// kind.
+func frameWALRecord(rec walRecord, kind byte, data []byte) []byte {
+	frame := make([]byte, 8, 8+2+4*binary.MaxVarintLen64+len(rec.name)+len(data))
+	frame = append(frame, rec.op, kind)
+	frame = binary.AppendUvarint(frame, uint64(len(rec.name)))
+	frame = append(frame, rec.name...)
+	frame = binary.AppendVarint(frame, rec.timestamp)
+	frame = binary.AppendVarint(frame, rec.expiresAt)
+	if rec.op == walPutVersion {
+		frame = binary.AppendUvarint(frame, rec.version)
+	}
+	frame = append(frame, data...)
+
+	body := frame[8:]
+	binary.LittleEndian.PutUint32(frame[:4], uint32(len(body)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(body, castagnoli))
+	return frame
+}
+
+// encodeValue returns the kind and encoding of rec's value.
+func (w *WAL) encodeValue(rec walRecord) (byte, []byte, error) {
+	switch v := rec.value.(type) {
+	case nil:
+		return walNil, nil, nil
+	case []byte:
+		return w.seal(rec, walBytes, v)
+	case Encoded:
+		if v.Codec.Name() == w.codec.Name() {
+			return w.seal(rec, walEncoded, v.Data)
+		}
+	}
+	data, err := w.codec.Marshal(rec.value)
+	if err != nil {
+		return walValue, nil, err
+	}
+	return w.seal(rec, walValue, data)
+}
+
+// seal returns data, the value of rec of the given kind, encrypted with the
+// log's cipher, if it has one, along with its kind flagged as sealed.
+func (w *WAL) seal(rec walRecord, kind byte, data []byte) (byte, []byte, error) {
+	if w.cipher == nil || kind == walNil {
+		return kind, data, nil
+	}
+	sealed, err := w.cipher.Seal(data, sealedHeader(rec))
+	if err != nil {
+		return kind, nil, err
+	}
+	return kind | walSealed, sealed, nil
+}
+
+// sealedHeader returns the fields of rec's header a cipher authenticates
+// along with its value, its timestamp and bucket name, so that a sealed value
+// cannot be passed off as another bucket's, or as an older or newer one.
+func sealedHeader(rec walRecord) []byte {
+	aad := binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(rec.name)), uint64(rec.timestamp))
+	return append(aad, rec.name...)
+}
+
+// resealRecord encodes the value of rec, decoded from the log, again, with
+// the cipher's current key.
+func (w *WAL) resealRecord(rec *walRecord) error {
+	kind, data, err := w.encodeValue(*rec)
+	if err != nil {
+		return err
+	}
+	rec.kind, rec.data = kind, data
+	return nil
+}
+
+// decodeValue returns the value of rec, decoded from its kind and data.
+func (w *WAL) decodeValue(rec walRecord) (any, error) {
+	kind, data := rec.kind, rec.data
+	if kind&walSealed != 0 {
+		if w.cipher == nil {
+			return nil, fmt.Errorf("%w: the log has no cipher", ErrUnknownKey)
+		}
+		plaintext, current, err := w.cipher.Open(data, sealedHeader(rec))
+		if err != nil {
+			return nil, err
+		}
+		kind, data = kind&^walSealed, plaintext
+		w.reseal = w.reseal || !current
+	} else if kind != walNil {
+		w.reseal = true
+	}
+	switch kind {
+	case walNil:
+		return nil, nil
+	case walBytes:
+		return bytes.Clone(data), nil
+	case walEncoded:
+		return Encoded{Codec: w.codec, Data: bytes.Clone(data)}, nil
+	case walValue:
+		var v any
+		err := w.codec.Unmarshal(data, &v)
+		return v, err
+	}
+	return nil, fmt.Errorf("%w: unknown value kind %d", ErrCorruptWAL, kind)
+}
+
+// append writes a framed record at the end of the log and reports whether
+// enough records were appended since the last compaction for the next one to
+// start. A failed write is cut off again, so it does not leave a damaged
+// record before later ones.
+func (w *WAL) append(frame []byte) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return false, withKind(fmt.Errorf("datapool: wal: %w", os.ErrClosed), ErrClosed)
+	}
+	if _, err := w.f.Write(frame); err != nil {
+		w.f.Truncate(w.size)
+		w.f.Seek(w.size, io.SeekStart)
+		return false, fmt.Errorf("datapool: wal: %w", err)
+	}
+	w.size += int64(len(frame))
+	w.records++
+	if err := w.syncFile(w.f); err != nil {
+		return false, fmt.Errorf("datapool: wal: %w", err)
+	}
+	return w.compactEvery > 0 && w.records-w.compacted >= w.compactEvery, nil
+}
+
+func (w *WAL) syncFile(f *os.File) error {
+	if !w.sync {
+		return nil
+	}
+	return f.Sync()
+}
+
+// compact rewrites the log with the current values of p's buckets. The new
+// log is written next to the old one and renamed over it once complete, so a
+// crash leaves one or the other.
+func (w *WAL) compact(p *DataPool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return withKind(fmt.Errorf("datapool: compact wal: %w", os.ErrClosed), ErrClosed)
+	}
+	tmp := w.path + ".compact"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("datapool: compact wal: %w", err)
+	}
+	size, records, err := w.writeSnapshot(f, p)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil && w.repair == WALRestoreSnapshot {
+		w.keepPrevious(p)
+	}
+	if err == nil {
+		err = os.Rename(tmp, w.path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("datapool: compact wal: %w", err)
+	}
+	if err := syncDir(w.path); err != nil {
+		p.reportError("", fmt.Errorf("datapool: compact wal: %w", err))
+	}
+
+	w.f.Close()
+	w.f = f
+	w.size = size
+	w.records = records
+	w.compacted = records
+	return nil
+}
+
+// writeSnapshot writes a log holding the current values of p's buckets to f
+// and returns its size and number of records.
+func (w *WAL) writeSnapshot(f *os.File, p *DataPool) (int64, int, error) {
+	bw := bufio.NewWriter(f)
+	header := w.header()
+	bw.Write(header)
+	size := int64(len(header))
+
+	records := 0
+	for _, b := range p.all() {
+		if b.system {
+			continue
+		}
+		b.guard.RLock()
+		value, ts := b.readValue(p)
+		rec := walRecord{op: walPutVersion, name: b.name(), timestamp: ts, expiresAt: b.expiresAt, version: b.version, value: value}
+		b.guard.RUnlock()
+		if ts == 0 {
+			continue
+		}
+
+		kind, data, err := w.encodeValue(rec)
+		if err != nil {
+			// The value was logged when it was Put, so it only fails to
+			// encode here if it changed since, which the pool cannot help.
+			p.reportError(b.name(), fmt.Errorf("datapool: wal: encode %s value of %q: %w", w.codec.Name(), rec.name, err))
+			continue
+		}
+		n, _ := w.writeRecord(bw, rec, kind, data)
+		size += n
+		records++
+	}
+	return size, records, bw.Flush()
+}
+
+// Close waits for a compaction in progress, syncs the log to disk and closes
+// it. Values Put afterwards are no longer logged; their Puts report errors to
+// the pool's error handler.
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Sync()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	w.f = nil
+	return err
+}
+
+// syncDir flushes the directory holding path, making a file created or
+// renamed in it durable.
+func syncDir(path string) error {
+	d, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	// Some platforms cannot sync directories; the file itself is synced
+	// either way.
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) && !errors.Is(err, errors.ErrUnsupported) {
+		return err
+	}
+	return nil
+}
+
+// WithWAL makes the pool log every value Put to w, and replays the values
+// logged before into the pool as it is created, with their timestamps and
+// expiration times; values that have expired since are skipped. A WAL serves
+// one pool only: passing it to another pool reports an error to the error
+// handler and leaves that pool without a log.
+//
+// Only values Put into the pool are logged: values applied from a backend
+// (see SyncBackend) already live there, and buckets dropped by eviction, TTLs
+// or memory pressure come back on replay, like after their last Put. Buckets
+// removed by Clear do not.
+func WithWAL(w *WAL) Option {
+	return func(o *options) {
+		o.wal = w
+	}
+}
+
+// attachWAL replays w into the pool and starts logging to it.
+func (p *DataPool) attachWAL(w *WAL) {
+	w.mu.Lock()
+	if w.pool != nil {
+		w.mu.Unlock()
+		p.reportError("", errors.New("datapool: wal is already used by another pool"))
+		return
+	}
+	w.pool = p
+	replay := w.replay
+	w.replay = nil
+	w.mu.Unlock()
+
+	now := p.now()
+	for _, rec := range replay {
+		if rec.expiresAt != 0 && rec.expiresAt <= now {
+			continue
+		}
+		b, err := p.bucket(rec.name)
+		if err != nil {
+			p.reportError(rec.name, fmt.Errorf("datapool: replay wal: %w", err))
+			continue
+		}
+		b.guard.Lock()
+		p.observe(rec.timestamp)
+		b.store(rec.value, rec.timestamp)
+		if rec.expiresAt != 0 {
+			b.expiresAt = rec.expiresAt
+			b.reschedule()
+		}
+		// Records of older logs have no version, leaving the one store set.
+		b.version = max(b.version, rec.version)
+		b.guard.Unlock()
+	}
+	p.wal = w
+	p.checkMemoryPressure(now)
+}
+
+// logPut appends the value u stored in b to the log, unless a later write has
+// replaced it already, in which case that write is logged instead.
+func (p *DataPool) logPut(b *bucket, u Update) {
+	b.guard.RLock()
+	current := !b.removed && b.timestamp == u.Timestamp
+	rec := walRecord{op: walPutVersion, name: b.name(), timestamp: u.Timestamp, expiresAt: b.expiresAt, version: u.Version, value: u.Value}
+	b.guard.RUnlock()
+	if !current {
+		return
+	}
+	p.logRecord(rec)
+}
+
+// logRemove appends the removal of the named bucket to the log.
+func (p *DataPool) logRemove(name string) {
+	p.logRecord(walRecord{op: walDelete, name: name, timestamp: p.stamp()})
+}
+
+func (p *DataPool) logRecord(rec walRecord) {
+	w := p.wal
+	frame, err := w.encode(rec)
+	if err != nil {
+		p.reportError(rec.name, err)
+		return
+	}
+	due, err := w.append(frame)
+	if err != nil {
+		p.reportError(rec.name, err)
+		return
+	}
+
+	if due && w.compacting.CompareAndSwap(false, true) {
+		started := p.goBackground(func() {
+			defer w.compacting.Store(false)
+			if err := w.compact(p); err != nil && !errors.Is(err, os.ErrClosed) {
+				p.reportError("", err)
+			}
+		})
+		if !started {
+			w.compacting.Store(false)
+		}
+	}
+}
+
+// CompactWAL rewrites the pool's write-ahead log (see WithWAL) with the
+// current values of its buckets only, dropping the records of values since
+// replaced, and returns nil at once if the pool has no log. Logs are
+// compacted automatically too; see WithWALCompaction.
+func (p *DataPool) CompactWAL() error {
+	if p.wal == nil {
+		return nil
+	}
+	return p.wal.compact(p)
+}
+
+// HasWAL reports whether the pool logs its writes to a write-ahead log (see
+// WithWAL).
+func (p *DataPool) HasWAL() bool {
+	return p.wal != nil
+}
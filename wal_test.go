@@ -0,0 +1,359 @@
+package datapool
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openWAL opens a log at path, closing it when the test ends.
+func openWAL(t *testing.T, path string, opts ...WALOption) *WAL {
+	t.Helper()
+	w, err := OpenWAL(path, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { w.Close() })
+	return w
+}
+
+func TestWALReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+
+	w := openWAL(t, path)
+	pool := NewDataPool(WithWAL(w))
+	config := pool.Bucket("config")
+	blob := pool.Bucket("blob")
+	config.Put(map[string]any{"retries": 3})
+	ts := config.Put(map[string]any{"retries": 5})
+	blob.PutBytes([]byte{0, 1, 2})
+	enc := pool.Bucket("encoded")
+	encoded, err := enc.PutEncoded([]string{"a", "b"})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	restored := NewDataPool(WithWAL(openWAL(t, path)))
+	value, got, _ := restored.Handle("config").Get(0)
+	assert.Equal(t, ts, got, "Values keep their timestamps")
+	assert.Equal(t, map[string]any{"retries": 5.0}, value, "JSON values come back as JSON types")
+
+	value, _, _ = restored.Handle("blob").Get(0)
+	assert.Equal(t, []byte{0, 1, 2}, value, "Byte slices come back as they are")
+
+	var list []string
+	enc = restored.Bucket("encoded")
+	got, err = enc.GetDecoded(&list)
+	require.NoError(t, err)
+	assert.Equal(t, encoded, got)
+	assert.Equal(t, []string{"a", "b"}, list)
+
+	assert.Greater(t, restored.Handle("other").Put(1), ts, "Later writes are stamped after replayed ones")
+}
+
+func TestWALReplaySkipsExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+	clock := NewManualClock(time.Unix(1000, 0))
+
+	w := openWAL(t, path)
+	pool := NewDataPool(WithWAL(w), WithClock(clock))
+	short := pool.Bucket("short")
+	short.SetTTL(time.Minute)
+	short.Put("a")
+	long := pool.Bucket("long")
+	long.SetTTL(time.Hour)
+	long.Put("b")
+	require.NoError(t, w.Close())
+
+	clock.Advance(10 * time.Minute)
+	restored := NewDataPool(WithWAL(openWAL(t, path)), WithClock(clock))
+	assert.Equal(t, 1, restored.Len(), "Expired values are not replayed")
+
+	value, _, _ := restored.Handle("long").Get(0)
+	assert.Equal(t, "b", value)
+	clock.Advance(time.Hour)
+	value, _, _ = restored.Handle("long").Get(0)
+	assert.Nil(t, value, "Replayed values keep their expiration time")
+}
+
+func TestWALClear(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+
+	w := openWAL(t, path)
+	pool := NewDataPool(WithWAL(w))
+	pool.Handle("a").Put(1)
+	pool.Handle("b").Put(2)
+	pool.Clear()
+	pool.Handle("b").Put(3)
+	require.NoError(t, w.Close())
+
+	restored := NewDataPool(WithWAL(openWAL(t, path)))
+	assert.Equal(t, 1, restored.Len(), "Cleared buckets are not replayed")
+	value, _, _ := restored.Handle("b").Get(0)
+	assert.Equal(t, 3.0, value)
+}
+
+func TestWALTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+
+	w := openWAL(t, path)
+	pool := NewDataPool(WithWAL(w))
+	pool.Handle("a").Put("kept")
+	pool.Handle("b").Put("torn")
+	require.NoError(t, w.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-2))
+
+	w = openWAL(t, path)
+	restored := NewDataPool(WithWAL(w))
+	value, _, _ := restored.Handle("a").Get(0)
+	assert.Equal(t, "kept", value)
+	assert.Equal(t, 1, restored.Len(), "The torn record is dropped")
+
+	restored.Handle("c").Put("after")
+	require.NoError(t, w.Close())
+	again := NewDataPool(WithWAL(openWAL(t, path)))
+	value, _, _ = again.Handle("c").Get(0)
+	assert.Equal(t, "after", value, "Records are appended after the last complete one")
+}
+
+func TestWALCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+
+	w := openWAL(t, path)
+	pool := NewDataPool(WithWAL(w))
+	pool.Handle("a").Put("first")
+	pool.Handle("b").Put("second")
+	require.NoError(t, w.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	// Flip a byte of the first record's value.
+	first := len(walMagic) + 1 + len("json") + 8
+	data[first+10] ^= 0xFF
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	_, err = OpenWAL(path)
+	assert.ErrorIs(t, err, ErrCorruptWAL)
+
+	_, err = OpenWAL(path, WithWALCodec(GobCodec))
+	assert.ErrorContains(t, err, `written with codec "json"`)
+}
+
+func TestWALCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+
+	w := openWAL(t, path, WithWALCompaction(0), WithWALSync(false))
+	pool := NewDataPool(WithWAL(w))
+	b := pool.Bucket("counter")
+	for i := 0; i < 100; i++ {
+		b.Put(i)
+	}
+	pool.Handle("other").Put("x")
+	before, err := os.Stat(path)
+	require.NoError(t, err)
+
+	require.NoError(t, pool.CompactWAL())
+	after, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Less(t, after.Size(), before.Size()/10, "Replaced values are dropped")
+
+	b.Put(100)
+	require.NoError(t, w.Close())
+	restored := NewDataPool(WithWAL(openWAL(t, path)))
+	value, _, _ := restored.Handle("counter").Get(0)
+	assert.Equal(t, 100.0, value)
+	value, _, _ = restored.Handle("other").Get(0)
+	assert.Equal(t, "x", value)
+}
+
+func TestWALChunks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+	large := strings.Repeat("0123456789", 10)
+
+	w := openWAL(t, path, WithWALChunkSize(16))
+	pool := NewDataPool(WithWAL(w))
+	pool.Handle("large").Put(large)
+	pool.Handle("blob").Put([]byte(large))
+	pool.Handle("small").Put("s")
+	require.NoError(t, w.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	frames := 0
+	for off := len(walMagic) + 1 + len("json"); off < len(data); frames++ {
+		body, n, err := parseWALFrame(data[off:])
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(body), 16+4+binary.MaxVarintLen64, "No record holds more than a chunk")
+		off += int(n)
+	}
+	assert.Equal(t, 1+7+1+7+1, frames, "Values larger than a chunk are split")
+
+	w = openWAL(t, path, WithWALChunkSize(16))
+	assert.Equal(t, WALReport{Records: 3, Values: 3}, w.Report())
+	restored := NewDataPool(WithWAL(w))
+	value, _, _ := restored.Handle("large").Get(0)
+	assert.Equal(t, large, value)
+	value, _, _ = restored.Handle("blob").Get(0)
+	assert.Equal(t, []byte(large), value)
+
+	require.NoError(t, restored.CompactWAL())
+	require.NoError(t, w.Close())
+	offsets := walRecordOffsets(t, path)
+	require.Len(t, offsets, 3, "Compactions write chunks too")
+	again := NewDataPool(WithWAL(openWAL(t, path)))
+	value, _, _ = again.Handle("large").Get(0)
+	assert.Equal(t, large, value, "Chunked logs read with any chunk size")
+}
+
+func TestWALDamagedChunks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+	w := openWAL(t, path, WithWALChunkSize(16))
+	pool := NewDataPool(WithWAL(w))
+	pool.Handle("a").Put("a-value")
+	pool.Handle("large").Put(strings.Repeat("x", 100))
+	pool.Handle("b").Put("b-value")
+	require.NoError(t, w.Close())
+	offsets := walRecordOffsets(t, path)
+	require.Len(t, offsets, 3)
+
+	// Damage a chunk in the middle of the large value.
+	damageWAL(t, path, offsets[1]+(offsets[2]-offsets[1])/2)
+	_, err := OpenWAL(path)
+	assert.ErrorIs(t, err, ErrCorruptWAL)
+
+	w = openWAL(t, path, WithWALRepair(WALDropCorrupt))
+	assert.Equal(t, 1, w.Report().Corrupt)
+	restored := NewDataPool(WithWAL(w))
+	value, ts, _ := restored.Handle("large").Get(0)
+	assert.Nil(t, value)
+	assert.Zero(t, ts)
+	value, _, _ = restored.Handle("b").Get(0)
+	assert.Equal(t, "b-value", value, "Records after the damaged chunk are kept")
+	require.NoError(t, w.Close())
+
+	// A value cut short in its chunks is the trace of an interrupted write.
+	path = filepath.Join(t.TempDir(), "pool.wal")
+	w = openWAL(t, path, WithWALChunkSize(16))
+	pool = NewDataPool(WithWAL(w))
+	pool.Handle("a").Put("a-value")
+	pool.Handle("large").Put(strings.Repeat("x", 100))
+	require.NoError(t, w.Close())
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-30))
+
+	w = openWAL(t, path)
+	assert.Equal(t, WALReport{Records: 1, Values: 1, Torn: true}, w.Report())
+}
+
+func TestWALAutomaticCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+
+	w := openWAL(t, path, WithWALCompaction(10), WithWALSync(false))
+	pool := NewDataPool(WithWAL(w))
+	b := pool.Bucket("counter")
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				b.Put(i)
+			}
+		}()
+	}
+	wg.Wait()
+	last := b.Put("last")
+
+	assert.Eventually(t, func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return w.records < 4*50+1
+	}, time.Second, time.Millisecond, "The log is compacted in the background")
+
+	require.NoError(t, w.Close())
+	restored := NewDataPool(WithWAL(openWAL(t, path)))
+	value, ts, _ := restored.Handle("counter").Get(0)
+	assert.Equal(t, "last", value)
+	assert.Equal(t, last, ts)
+}
+
+func TestWALOnePool(t *testing.T) {
+	w := openWAL(t, filepath.Join(t.TempDir(), "pool.wal"))
+	NewDataPool(WithWAL(w))
+
+	var reported error
+	other := NewDataPool(WithWAL(w), WithErrorHandler(func(_ string, err error) { reported = err }))
+	assert.ErrorContains(t, reported, "already used")
+	assert.NoError(t, other.CompactWAL(), "The other pool has no log")
+}
+
+func TestWALClosed(t *testing.T) {
+	w := openWAL(t, filepath.Join(t.TempDir(), "pool.wal"))
+	var reported error
+	pool := NewDataPool(WithWAL(w), WithErrorHandler(func(_ string, err error) { reported = err }))
+	require.NoError(t, w.Close())
+
+	b := pool.Bucket("a")
+	assert.NotZero(t, b.Put(1), "The value is stored in memory")
+	assert.ErrorIs(t, reported, os.ErrClosed)
+	assert.ErrorIs(t, reported, ErrClosed)
+}
+
+func TestWALCipher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+	oldKey := []byte("0123456789abcdef")
+	newKey := []byte("fedcba9876543210")
+	old, err := NewAESGCMCipher(oldKey)
+	require.NoError(t, err)
+
+	// A log written in the clear is encrypted when opened with a cipher.
+	w := openWAL(t, path)
+	NewDataPool(WithWAL(w)).Handle("secrets/api").Put("hunter2")
+	require.NoError(t, w.Close())
+
+	w = openWAL(t, path, WithWALCipher(old))
+	pool := NewDataPool(WithWAL(w))
+	assert.True(t, w.Report().Resealed)
+	db := pool.Bucket("secrets/db")
+	db.PutBytes([]byte("s3cr3t"))
+	require.NoError(t, w.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "secrets/api", "Bucket names are not encrypted")
+	assert.NotContains(t, string(data), "hunter2")
+	assert.NotContains(t, string(data), "s3cr3t")
+
+	_, err = OpenWAL(path)
+	assert.ErrorIs(t, err, ErrUnknownKey, "An encrypted log needs its cipher")
+	_, err = OpenWAL(path, WithWALRepair(WALDropCorrupt))
+	assert.ErrorIs(t, err, ErrUnknownKey, "Values of a missing key are not dropped as damage")
+
+	// Rotating the key reseals the values with the new one.
+	rotated, err := NewAESGCMCipher(newKey, oldKey)
+	require.NoError(t, err)
+	w = openWAL(t, path, WithWALCipher(rotated))
+	pool = NewDataPool(WithWAL(w))
+	assert.True(t, w.Report().Resealed)
+	assert.False(t, w.Report().Repaired)
+	require.NoError(t, w.Close())
+
+	current, err := NewAESGCMCipher(newKey)
+	require.NoError(t, err)
+	w = openWAL(t, path, WithWALCipher(current))
+	pool = NewDataPool(WithWAL(w))
+	assert.False(t, w.Report().Resealed, "Values sealed with the current key are left as they are")
+	value, _, _ := pool.Handle("secrets/api").Get(0)
+	assert.Equal(t, "hunter2", value)
+	value, _, _ = pool.Handle("secrets/db").Get(0)
+	assert.Equal(t, []byte("s3cr3t"), value)
+}
@@ -0,0 +1,275 @@
+package datapool
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// WALRepair is what OpenWAL does with a log damaged before its last record
+// (see WithWALRepair).
+type WALRepair int
+
+const (
+	// WALFailCorrupt fails OpenWAL with ErrCorruptWAL, leaving the log as it
+	// is for inspection.
+	WALFailCorrupt WALRepair = iota
+	// WALDropCorrupt drops the damaged records and the values that fail to
+	// decode, keeps every intact record around them, and rewrites the log
+	// without them. Buckets whose last value was dropped come back with an
+	// older one, or not at all.
+	WALDropCorrupt
+	// WALRestoreSnapshot is WALDropCorrupt restoring the values it cannot
+	// read from the log as it was before its last compaction, which
+	// compaction keeps next to the log, in a file named after it with a
+	// ".prev" suffix. Restored values may be older than the lost ones, and
+	// buckets whose removal was lost come back.
+	WALRestoreSnapshot
+)
+
+var walRepairNames = [...]string{
+	WALFailCorrupt:     "fail-corrupt",
+	WALDropCorrupt:     "drop-corrupt",
+	WALRestoreSnapshot: "restore-snapshot",
+}
+
+func (r WALRepair) String() string {
+	if r < 0 || int(r) >= len(walRepairNames) {
+		return fmt.Sprintf("WALRepair(%d)", int(r))
+	}
+	return walRepairNames[r]
+}
+
+// WithWALRepair sets how OpenWAL handles a damaged log. The default is
+// WALFailCorrupt.
+func WithWALRepair(repair WALRepair) WALOption {
+	return func(w *WAL) {
+		w.repair = repair
+	}
+}
+
+// WALReport is the result of the integrity scan of a log opened with
+// OpenWAL.
+type WALReport struct {
+	// Records is the number of intact records read.
+	Records int
+	// Values is the number of values to replay.
+	Values int
+	// Corrupt is the number of damaged records, runs of damaged records
+	// counting once, and values that failed to decode.
+	Corrupt int
+	// CorruptBytes is the size of the damaged records.
+	CorruptBytes int64
+	// Torn reports whether the last record was cut short by an interrupted
+	// write, and cut off.
+	Torn bool
+	// OutOfOrder is the number of records older than an earlier record of
+	// their bucket, which concurrent writes to a bucket can leave. The newest
+	// record wins regardless of their order.
+	OutOfOrder int
+	// Orphaned is the number of removals of buckets the log holds no value
+	// for, such as buckets created but never written before Clear.
+	Orphaned int
+	// Restored is the number of values restored from the previous log (see
+	// WALRestoreSnapshot).
+	Restored int
+	// Repaired reports whether the log was rewritten without the damage.
+	Repaired bool
+	// Resealed reports whether the log was rewritten to encrypt its values
+	// with the current key of its cipher (see WithWALCipher).
+	Resealed bool
+}
+
+// String summarizes the report on one line.
+func (r WALReport) String() string {
+	parts := []string{fmt.Sprintf("%d records, %d values", r.Records, r.Values)}
+	if r.Corrupt > 0 {
+		parts = append(parts, fmt.Sprintf("%d corrupt (%d bytes) dropped", r.Corrupt, r.CorruptBytes))
+	}
+	if r.Restored > 0 {
+		parts = append(parts, fmt.Sprintf("%d restored from the previous log", r.Restored))
+	}
+	if r.Torn {
+		parts = append(parts, "torn last record cut off")
+	}
+	if r.OutOfOrder > 0 {
+		parts = append(parts, fmt.Sprintf("%d out of order", r.OutOfOrder))
+	}
+	if r.Orphaned > 0 {
+		parts = append(parts, fmt.Sprintf("%d orphaned removals", r.Orphaned))
+	}
+	if r.Repaired {
+		parts = append(parts, "log rewritten")
+	}
+	if r.Resealed {
+		parts = append(parts, "values resealed with the current key")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Report returns the result of the integrity scan run when the log was
+// opened: what it held, what was damaged and how it was repaired.
+func (w *WAL) Report() WALReport {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.report
+}
+
+// scan reads the records of a log held in data into latest, keeping the
+// newest record of every bucket, counts what it finds in r, and returns the
+// offset after the last intact record. Unless the log is repaired, damage
+// before the last record fails with ErrCorruptWAL.
+func (w *WAL) scan(data []byte, latest map[string]walRecord, r *WALReport) (int64, error) {
+	off := len(w.header())
+	end := off
+	seen := make(map[string]int64)
+	for off < len(data) {
+		rec, n, err := parseWALRecord(data[off:])
+		if err == nil {
+			r.Records++
+			if last, ok := seen[rec.name]; ok && rec.timestamp < last {
+				r.OutOfOrder++
+			} else if !ok && rec.op == walDelete {
+				r.Orphaned++
+			}
+			seen[rec.name] = max(seen[rec.name], rec.timestamp)
+			if prev, ok := latest[rec.name]; !ok || rec.timestamp > prev.timestamp {
+				latest[rec.name] = rec
+			}
+			off += int(n)
+			end = off
+			continue
+		}
+
+		// A record that seems torn is damaged if intact ones follow it.
+		next := len(data)
+		if w.repair != WALFailCorrupt {
+			next = resyncWAL(data, off+1)
+		}
+		if errors.Is(err, errTornRecord) && next == len(data) {
+			r.Torn = true
+			break
+		}
+		if w.repair == WALFailCorrupt {
+			return 0, fmt.Errorf("%w at offset %d", err, off)
+		}
+		r.Corrupt++
+		r.CorruptBytes += int64(next - off)
+		off = next
+	}
+	return int64(end), nil
+}
+
+// resyncWAL returns the offset of the first intact record at or after from,
+// or the end of data if there is none.
+func resyncWAL(data []byte, from int) int {
+	for i := from; i+8 <= len(data); i++ {
+		if _, _, err := parseWALRecord(data[i:]); err == nil {
+			return i
+		}
+	}
+	return len(data)
+}
+
+// decodeLatest decodes the values of the records in latest. Values that fail
+// to decode fail the log, or are dropped and counted in r if it is repaired;
+// values sealed with a key the log's cipher lacks always fail it, since they
+// are not damaged.
+func (w *WAL) decodeLatest(latest map[string]walRecord, r *WALReport) error {
+	for name, rec := range latest {
+		if rec.op == walDelete {
+			continue
+		}
+		value, err := w.decodeValue(rec)
+		if err != nil {
+			if w.repair == WALFailCorrupt || errors.Is(err, ErrUnknownKey) {
+				return fmt.Errorf("bucket %q: decode %s value: %w", name, w.codec.Name(), err)
+			}
+			delete(latest, name)
+			r.Corrupt++
+			r.CorruptBytes += int64(len(rec.data))
+			continue
+		}
+		rec.value = value
+		latest[name] = rec
+	}
+	return nil
+}
+
+// restore adds the records of the previous log that are newer than those
+// left in latest. A previous log that is missing or unreadable restores
+// nothing; it is read with damage dropped.
+func (w *WAL) restore(latest map[string]walRecord) {
+	data, err := os.ReadFile(w.path + ".prev")
+	if err != nil || w.checkHeader(data) != nil {
+		return
+	}
+	var r WALReport
+	prev := make(map[string]walRecord)
+	if _, err := w.scan(data, prev, &r); err != nil {
+		return
+	}
+	w.decodeLatest(prev, &r)
+	for name, rec := range prev {
+		if cur, ok := latest[name]; ok && cur.timestamp >= rec.timestamp {
+			continue
+		}
+		latest[name] = rec
+		if rec.op != walDelete {
+			w.report.Restored++
+		}
+	}
+}
+
+// rewrite replaces the log with one holding the records to replay only, and
+// returns it positioned at its end. The new log is written next to the old
+// one and renamed over it once complete, like a compaction.
+func (w *WAL) rewrite() (*os.File, error) {
+	tmp := w.path + ".repair"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	bw := bufio.NewWriter(f)
+	header := w.header()
+	bw.Write(header)
+	size := int64(len(header))
+	for _, rec := range w.replay {
+		n, _ := w.writeRecord(bw, rec, rec.kind, rec.data)
+		size += n
+	}
+	err = bw.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp, w.path)
+	}
+	if err == nil {
+		err = syncDir(w.path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return nil, err
+	}
+	w.size = size
+	w.records = len(w.replay)
+	return f, nil
+}
+
+// keepPrevious links the log as it is before a compaction to its ".prev"
+// file, for WALRestoreSnapshot. It must be called with w.mu held.
+func (w *WAL) keepPrevious(p *DataPool) {
+	prev := w.path + ".prev"
+	if err := os.Remove(prev); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		p.reportError("", fmt.Errorf("datapool: compact wal: keep previous log: %w", err))
+		return
+	}
+	if err := os.Link(w.path, prev); err != nil {
+		p.reportError("", fmt.Errorf("datapool: compact wal: keep previous log: %w", err))
+	}
+}
@@ -0,0 +1,177 @@
+package datapool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// walRecordOffsets returns the offsets of the records of the log at path.
+func walRecordOffsets(t *testing.T, path string) []int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var offsets []int
+	for off := len(walMagic) + 1 + len("json"); off < len(data); {
+		_, n, err := parseWALRecord(data[off:])
+		require.NoError(t, err)
+		offsets = append(offsets, off)
+		off += int(n)
+	}
+	return offsets
+}
+
+// damageWAL flips a byte of the log at path.
+func damageWAL(t *testing.T, path string, off int) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[off] ^= 0xFF
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+}
+
+// writeWAL logs a value to each named bucket and returns the log's path.
+func writeWAL(t *testing.T, names ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pool.wal")
+	w := openWAL(t, path)
+	pool := NewDataPool(WithWAL(w))
+	for _, name := range names {
+		pool.Handle(name).Put(name + "-value")
+	}
+	require.NoError(t, w.Close())
+	return path
+}
+
+func TestWALReport(t *testing.T) {
+	path := writeWAL(t, "a", "b")
+
+	w := openWAL(t, path)
+	assert.Equal(t, WALReport{Records: 2, Values: 2}, w.Report())
+	assert.Equal(t, "2 records, 2 values", w.Report().String())
+
+	// A record older than the bucket's last one, as concurrent Puts can log.
+	frame, err := w.encode(walRecord{op: walPutVersion, name: "a", timestamp: 1, value: "older"})
+	require.NoError(t, err)
+	_, err = w.append(frame)
+	require.NoError(t, err)
+	pool := NewDataPool(WithWAL(w))
+	pool.Bucket("empty")
+	pool.Clear()
+	require.NoError(t, w.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write(make([]byte, 5)) // An interrupted write.
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	w = openWAL(t, path)
+	r := w.Report()
+	assert.Equal(t, WALReport{Records: 6, Torn: true, OutOfOrder: 1, Orphaned: 1}, r)
+	assert.Equal(t, "6 records, 0 values, torn last record cut off, 1 out of order, 1 orphaned removals", r.String())
+	after, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), after.Size())
+}
+
+func TestWALDropCorrupt(t *testing.T) {
+	path := writeWAL(t, "a", "b", "c")
+	offsets := walRecordOffsets(t, path)
+	damageWAL(t, path, offsets[1]+10)
+
+	_, err := OpenWAL(path)
+	assert.ErrorIs(t, err, ErrCorruptWAL)
+	assert.ErrorContains(t, err, "record fails its checksum at offset")
+
+	w := openWAL(t, path, WithWALRepair(WALDropCorrupt))
+	r := w.Report()
+	assert.Equal(t, 2, r.Records)
+	assert.Equal(t, 2, r.Values)
+	assert.Equal(t, 1, r.Corrupt)
+	assert.Equal(t, int64(offsets[2]-offsets[1]), r.CorruptBytes)
+	assert.True(t, r.Repaired)
+
+	pool := NewDataPool(WithWAL(w))
+	value, _, _ := pool.Handle("a").Get(0)
+	assert.Equal(t, "a-value", value)
+	value, _, _ = pool.Handle("c").Get(0)
+	assert.Equal(t, "c-value", value, "Records after the damage are kept")
+	value, _, _ = pool.Handle("b").Get(0)
+	assert.Nil(t, value)
+
+	pool.Handle("d").Put("d-value")
+	require.NoError(t, w.Close())
+	w = openWAL(t, path)
+	assert.Equal(t, WALReport{Records: 3, Values: 3}, w.Report(), "The log was rewritten without the damage")
+}
+
+func TestWALDropCorruptLength(t *testing.T) {
+	path := writeWAL(t, "a", "b", "c")
+	offsets := walRecordOffsets(t, path)
+	// A length running past the end of the log looks like a torn record.
+	damageWAL(t, path, offsets[1]+3)
+
+	w := openWAL(t, path, WithWALRepair(WALDropCorrupt))
+	r := w.Report()
+	assert.False(t, r.Torn)
+	assert.Equal(t, 1, r.Corrupt)
+	pool := NewDataPool(WithWAL(w))
+	value, _, _ := pool.Handle("c").Get(0)
+	assert.Equal(t, "c-value", value)
+}
+
+func TestWALDropUndecodable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+	w := openWAL(t, path)
+	frame := frameWALRecord(walRecord{op: walPutVersion, name: "a", timestamp: 1, version: 1}, walValue, []byte("{not json"))
+	_, err := w.append(frame)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	_, err = OpenWAL(path)
+	assert.ErrorContains(t, err, `bucket "a": decode json value`)
+
+	w = openWAL(t, path, WithWALRepair(WALDropCorrupt))
+	assert.Equal(t, WALReport{Records: 1, Corrupt: 1, CorruptBytes: 9, Repaired: true}, w.Report())
+}
+
+func TestWALRestoreSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.wal")
+	w := openWAL(t, path, WithWALRepair(WALRestoreSnapshot), WithWALCompaction(0))
+	pool := NewDataPool(WithWAL(w))
+	pool.Handle("a").Put("a1")
+	pool.Handle("b").Put("b1")
+	require.NoError(t, pool.CompactWAL())
+	pool.Handle("b").Put("b2")
+	pool.Handle("c").Put("c1")
+	require.NoError(t, w.Close())
+	_, err := os.Stat(path + ".prev")
+	require.NoError(t, err, "Compaction keeps the previous log")
+
+	// Damage the compacted records of a and b.
+	offsets := walRecordOffsets(t, path)
+	require.Len(t, offsets, 4)
+	damageWAL(t, path, offsets[0]+10)
+	damageWAL(t, path, offsets[1]+10)
+
+	w = openWAL(t, path, WithWALRepair(WALRestoreSnapshot))
+	r := w.Report()
+	assert.Equal(t, 1, r.Corrupt, "Adjacent damaged records count once")
+	assert.Equal(t, 1, r.Restored, "b has a newer intact record")
+	assert.Equal(t, int64(offsets[2]-offsets[0]), r.CorruptBytes)
+	assert.True(t, r.Repaired)
+	assert.Equal(t, fmt.Sprintf("2 records, 3 values, 1 corrupt (%d bytes) dropped, 1 restored from the previous log, log rewritten", r.CorruptBytes), r.String())
+
+	restored := NewDataPool(WithWAL(w))
+	for name, want := range map[string]string{"a": "a1", "b": "b2", "c": "c1"} {
+		value, _, _ := restored.Handle(name).Get(0)
+		assert.Equal(t, want, value, name)
+	}
+}
@@ -0,0 +1,230 @@
+package datapool
+
+import (
+	"context"
+	"sync"
+)
+
+// ErrRemoved is returned by GetWait when the bucket is evicted or removed
+// from the pool while waiting, and wrapped by the errors of operations on
+// handles to a removed bucket (see Bucket.Valid). It is an ErrNotFound.
+var ErrRemoved = newKindError(ErrNotFound, "datapool: bucket removed")
+
+// Update describes a value stored in a bucket.
+type Update struct {
+	Bucket    string
+	Value     any
+	Timestamp int64
+	// Provenance is the value's provenance chain (see Bucket.Provenance), or
+	// nil for a value stored with a plain Put.
+	Provenance []Source
+	// Schema is the value's schema version (see PutSchema), zero if it has
+	// none.
+	Schema int
+	// Version is the value's version in its bucket (see Bucket.Version).
+	Version uint64
+	// Fence is the fencing token the value was stored with (see PutFenced),
+	// zero if it was not fenced.
+	Fence uint64
+
+	// claim is the claim a write is made through (see Bucket.Claim), zero
+	// for none. It is cleared once checked.
+	claim uint64
+}
+
+// Watch returns a channel receiving an Update for every subsequent Put to the
+// bucket, in timestamp order. The channel is closed when ctx is done or the
+// bucket is evicted. A receiver that falls behind by more than the watch
+// buffer (see WithWatchBuffer) loses the oldest pending updates, so it always
+// catches up with the latest value.
+func (b *Bucket) Watch(ctx context.Context) <-chan Update {
+	return b.watch(ctx, &watcher{ch: make(chan Update, b.watchBuffer())})
+}
+
+// watch registers w as a watcher of the bucket until ctx is done, and
+// returns its channel.
+func (b *Bucket) watch(ctx context.Context, w *watcher) <-chan Update {
+	bk := b.resolve("watch")
+	if bk == nil {
+		w.close()
+		return w.ch
+	}
+
+	bk.guard.Lock()
+	// Close closes the watchers of every bucket after marking the pool
+	// closed, so checking under the lock leaves none open.
+	if bk.removed || b.pool.isClosed() {
+		bk.guard.Unlock()
+		w.close()
+		return w.ch
+	}
+	if w.filter != nil {
+		w.filter.prev = bk.current()
+	}
+	// Watcher lists are copy-on-write so puts can deliver without the lock.
+	bk.watchers = append(bk.watchers[:len(bk.watchers):len(bk.watchers)], w)
+	bk.guard.Unlock()
+
+	stop := context.AfterFunc(ctx, func() {
+		bk.unwatch(w)
+		w.close()
+	})
+	w.mu.Lock()
+	w.stop = stop
+	w.mu.Unlock()
+	return w.ch
+}
+
+// GetWait returns the bucket's value and timestamp once a value newer than
+// sinceTimestamp is stored, at once if there already is one. It returns
+// ctx.Err() if ctx is done first, and ErrRemoved if the bucket is removed
+// from the pool. Consumers can follow a producer by passing the timestamp
+// returned by the previous call.
+func (b *Bucket) GetWait(ctx context.Context, sinceTimestamp int64) (any, int64, error) {
+	bk := b.resolve("get wait")
+	if bk == nil {
+		return nil, 0, ErrRemoved
+	}
+
+	// Watch before reading, so a Put in between is not missed.
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	updates := b.Watch(wctx)
+
+	if value, ts, fresh := b.pool.get(bk, sinceTimestamp, ConsistencyDefault); fresh {
+		return value, ts, nil
+	}
+	for {
+		select {
+		case u, ok := <-updates:
+			if !ok {
+				if err := ctx.Err(); err != nil {
+					return nil, 0, err
+				}
+				return nil, 0, ErrRemoved
+			}
+			if u.Timestamp > sinceTimestamp {
+				return u.Value, u.Timestamp, nil
+			}
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+	}
+}
+
+func (b *Bucket) watchBuffer() int {
+	if b.pool == nil {
+		return 1
+	}
+	return b.pool.opts.watchBuffer
+}
+
+// WatchOverflows returns how many updates were dropped because a watcher fell
+// too far behind.
+func (p *DataPool) WatchOverflows() uint64 {
+	return p.watchOverflows.Load()
+}
+
+type watcher struct {
+	mu     sync.Mutex
+	ch     chan Update
+	last   int64
+	closed bool
+	stop   func() bool
+	// filter, if not nil, selects the updates delivered (see WatchChanges).
+	filter *watchFilter
+}
+
+// deliver queues u unless the watcher is closed or already delivered a newer
+// update. It reports whether an older pending update was dropped for room.
+func (w *watcher) deliver(u Update) bool {
+	return w.deliverValue(u, u.Value)
+}
+
+// deliverValue is deliver of u, a copy of whose value is value when the
+// bucket copies values, for the watcher's filter to compare value instead of
+// the copy its receiver gets.
+func (w *watcher) deliverValue(u Update, value any) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed || u.Timestamp <= w.last {
+		return false
+	}
+	w.last = u.Timestamp
+	if w.filter != nil && !w.filter.admit(value) {
+		return false
+	}
+
+	select {
+	case w.ch <- u:
+		return false
+	default:
+	}
+
+	// Only deliverers send, and they hold w.mu, so after taking one pending
+	// update out there is room for u.
+	select {
+	case <-w.ch:
+	default:
+	}
+	w.ch <- u
+	return true
+}
+
+func (w *watcher) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.closed {
+		w.closed = true
+		close(w.ch)
+	}
+}
+
+// unwatch removes w from the bucket's watchers.
+func (b *bucket) unwatch(w *watcher) {
+	b.guard.Lock()
+	defer b.guard.Unlock()
+
+	watchers := make([]*watcher, 0, len(b.watchers))
+	for _, other := range b.watchers {
+		if other != w {
+			watchers = append(watchers, other)
+		}
+	}
+	b.watchers = watchers
+}
+
+// deliverUpdate sends an update to the watchers of b, which must have been
+// read from the bucket under its lock together with the update itself, and
+// to the name watchers matching it. Each watcher gets its own copy of the
+// value if the bucket copies values.
+func (p *DataPool) deliverUpdate(b *bucket, watchers []*watcher, u Update) {
+	copies := b.copyValues.Load()
+	value := u.Value
+	for _, w := range watchers {
+		if copies {
+			u.Value = copyValue(value)
+		}
+		if w.deliverValue(u, value) {
+			p.watchOverflow(b)
+		}
+	}
+	u.Value = value
+	p.deliverMatching(b, u)
+}
+
+// closeWatchers closes the channels of all watchers after their bucket was
+// removed from the pool.
+func closeWatchers(watchers []*watcher) {
+	for _, w := range watchers {
+		w.mu.Lock()
+		stop := w.stop
+		w.mu.Unlock()
+		if stop != nil {
+			stop()
+		}
+		w.close()
+	}
+}
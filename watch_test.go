@@ -0,0 +1,173 @@
+package datapool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, ch <-chan Update) Update {
+	t.Helper()
+	select {
+	case u, ok := <-ch:
+		require.True(t, ok, "Watch channel closed unexpectedly")
+		return u
+	case <-time.After(time.Second):
+		require.FailNow(t, "Timed out waiting for an update")
+		return Update{}
+	}
+}
+
+func assertClosed(t *testing.T, ch <-chan Update) {
+	t.Helper()
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok, "Watch channel should be closed")
+	case <-time.After(time.Second):
+		assert.Fail(t, "Timed out waiting for the watch channel to close")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	pool := NewDataPool()
+	bucket := pool.Bucket("config")
+	bucket.Put("before")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := bucket.Watch(ctx)
+
+	ts1 := bucket.Put("v1")
+	ts2 := pool.PutMany(map[string]any{"config": "v2", "other": 1})["config"]
+
+	assert.Equal(t, Update{Bucket: "config", Value: "v1", Timestamp: ts1, Version: 2}, receive(t, updates))
+	assert.Equal(t, Update{Bucket: "config", Value: "v2", Timestamp: ts2, Version: 3}, receive(t, updates))
+
+	cancel()
+	assertClosed(t, updates)
+	assert.Eventually(t, func() bool {
+		bucket.b.guard.RLock()
+		defer bucket.b.guard.RUnlock()
+		return len(bucket.b.watchers) == 0
+	}, time.Second, time.Millisecond, "Cancelled watcher is unregistered")
+}
+
+func TestWatchOverflowKeepsLatest(t *testing.T) {
+	pool := NewDataPool(WithWatchBuffer(2))
+	bucket := pool.Bucket("sensor")
+	updates := bucket.Watch(context.Background())
+
+	for i := 1; i <= 5; i++ {
+		bucket.Put(i)
+	}
+
+	assert.Equal(t, 4, receive(t, updates).Value)
+	assert.Equal(t, 5, receive(t, updates).Value)
+	assert.Equal(t, uint64(3), pool.WatchOverflows())
+}
+
+func TestWatchClosedOnEviction(t *testing.T) {
+	pool := NewDataPool(WithMaxBuckets(1))
+	bucket := pool.Bucket("old")
+	updates := bucket.Watch(context.Background())
+
+	pool.Bucket("new")
+	assertClosed(t, updates)
+
+	late := bucket.Watch(context.Background())
+	assertClosed(t, late)
+}
+
+func TestWatchInvalidHandle(t *testing.T) {
+	var zero Bucket
+	assertClosed(t, zero.Watch(context.Background()))
+}
+
+func TestWatcherIgnoresOlderUpdates(t *testing.T) {
+	w := &watcher{ch: make(chan Update, 4)}
+
+	w.deliver(Update{Value: "new", Timestamp: 2})
+	w.deliver(Update{Value: "old", Timestamp: 1})
+	w.close()
+	w.deliver(Update{Value: "after close", Timestamp: 3})
+
+	var got []any
+	for u := range w.ch {
+		got = append(got, u.Value)
+	}
+	assert.Equal(t, []any{"new"}, got)
+}
+
+func TestGetWait(t *testing.T) {
+	pool := NewDataPool()
+	bucket := pool.Bucket("feed")
+	ts1 := bucket.Put("v1")
+
+	val, ts, err := bucket.GetWait(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", val, "Values newer than the timestamp return at once")
+	assert.Equal(t, ts1, ts)
+
+	type result struct {
+		value any
+		ts    int64
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		val, ts, err := bucket.GetWait(context.Background(), ts1)
+		done <- result{val, ts, err}
+	}()
+
+	select {
+	case r := <-done:
+		require.FailNow(t, "GetWait returned early", "%v", r)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	ts2 := bucket.Put("v2")
+	select {
+	case r := <-done:
+		require.NoError(t, r.err)
+		assert.Equal(t, "v2", r.value)
+		assert.Equal(t, ts2, r.ts)
+	case <-time.After(time.Second):
+		require.FailNow(t, "GetWait did not return after a Put")
+	}
+}
+
+func TestGetWaitCancelled(t *testing.T) {
+	pool := NewDataPool()
+	bucket := pool.Bucket("feed")
+	ts := bucket.Put("v1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err := bucket.GetWait(ctx, ts)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestGetWaitRemoved(t *testing.T) {
+	pool := NewDataPool(WithMaxBuckets(1))
+	bucket := pool.Bucket("old")
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := bucket.GetWait(context.Background(), 0)
+		done <- err
+	}()
+	require.Eventually(t, func() bool {
+		bucket.b.guard.RLock()
+		defer bucket.b.guard.RUnlock()
+		return len(bucket.b.watchers) == 1
+	}, time.Second, time.Millisecond)
+
+	pool.Bucket("new")
+	assert.ErrorIs(t, <-done, ErrRemoved)
+
+	var zero Bucket
+	_, _, err := zero.GetWait(context.Background(), 0)
+	assert.ErrorIs(t, err, ErrRemoved)
+}
@@ -0,0 +1,83 @@
+package datapool
+
+import (
+	"context"
+	"math"
+	"reflect"
+)
+
+// WatchOption selects the updates delivered by WatchChanges.
+type WatchOption func(*watchFilter)
+
+// WithPredicate delivers only the updates for which keep returns true. keep
+// is passed the value of the last update delivered, or the bucket's value
+// when watching began, and the new one. It is called by the goroutine
+// storing the value, before Put returns, so it must be quick and must not
+// use the pool.
+func WithPredicate(keep func(old, new any) bool) WatchOption {
+	return func(f *watchFilter) {
+		f.predicates = append(f.predicates, keep)
+	}
+}
+
+// WithMinChange delivers only the updates of numeric values, of any integer
+// or floating-point type, differing by at least delta from the last one
+// delivered, or from the bucket's value when watching began. Gradual drifts
+// are delivered once they add up to delta. Updates of values that are not
+// numbers, or follow one, are always delivered.
+func WithMinChange(delta float64) WatchOption {
+	return WithPredicate(func(old, new any) bool {
+		a, okA := toFloat(old)
+		b, okB := toFloat(new)
+		return !okA || !okB || math.Abs(b-a) >= delta
+	})
+}
+
+// WatchChanges is Watch delivering only the updates selected by opts, which
+// are evaluated when each value is Put, so that subscribers interested in
+// meaningful changes are not woken up by every write. Updates must pass
+// every option to be delivered. Without options it is Watch.
+func (b *Bucket) WatchChanges(ctx context.Context, opts ...WatchOption) <-chan Update {
+	w := &watcher{ch: make(chan Update, b.watchBuffer())}
+	if len(opts) > 0 {
+		w.filter = &watchFilter{}
+		for _, opt := range opts {
+			opt(w.filter)
+		}
+	}
+	return b.watch(ctx, w)
+}
+
+// watchFilter holds the options of WatchChanges and the value they compare
+// new ones to.
+type watchFilter struct {
+	predicates []func(old, new any) bool
+	prev       any
+}
+
+// admit reports whether an update to value is delivered, making value the
+// one compared to next if it is. It must be called with the watcher's lock
+// held.
+func (f *watchFilter) admit(value any) bool {
+	for _, keep := range f.predicates {
+		if !keep(f.prev, value) {
+			return false
+		}
+	}
+	f.prev = value
+	return true
+}
+
+// toFloat returns v as a float64 if it is a number.
+func toFloat(v any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,76 @@
+package datapool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// drain returns the values of the updates pending on ch.
+func drain(ch <-chan Update) []any {
+	var values []any
+	for {
+		select {
+		case u := <-ch:
+			values = append(values, u.Value)
+		default:
+			return values
+		}
+	}
+}
+
+func TestWatchMinChange(t *testing.T) {
+	pool := NewDataPool(WithWatchBuffer(16))
+	b := pool.Bucket("prices/EURUSD")
+	b.Put(1.10)
+	updates := b.WatchChanges(context.Background(), WithMinChange(0.01))
+
+	for _, v := range []float64{1.101, 1.105, 1.111, 1.112, 1.09} {
+		b.Put(v)
+	}
+	b.Put(2)
+	b.Put("suspended")
+	b.Put(3)
+	assert.Equal(t, []any{1.111, 1.09, 2, "suspended", 3}, drain(updates), "Small changes add up until they pass the threshold")
+}
+
+func TestWatchPredicate(t *testing.T) {
+	pool := NewDataPool(WithWatchBuffer(16))
+	b := pool.Bucket("status")
+	var olds []any
+	updates := b.WatchChanges(context.Background(),
+		WithPredicate(func(old, new any) bool {
+			olds = append(olds, old)
+			return old != new
+		}),
+		WithPredicate(func(_, new any) bool { return new != "ignored" }),
+	)
+
+	for _, v := range []string{"up", "up", "down", "ignored", "down", "up"} {
+		b.Put(v)
+	}
+	assert.Equal(t, []any{"up", "down", "up"}, drain(updates))
+	assert.Equal(t, []any{nil, "up", "up", "down", "down", "down"}, olds, "Values compare to the last one delivered")
+
+	all := b.WatchChanges(context.Background())
+	b.Put("up")
+	assert.Equal(t, []any{"up"}, drain(all), "Without options every update is delivered")
+}
+
+func TestWatchPredicateCopies(t *testing.T) {
+	pool := NewDataPool(WithWatchBuffer(16))
+	b := pool.Bucket("list")
+	b.SetCopyValues(true)
+	updates := b.WatchChanges(context.Background(), WithPredicate(func(old, new any) bool {
+		return !reflect.DeepEqual(old, new)
+	}))
+
+	b.Put([]int{1})
+	u := <-updates
+	u.Value.([]int)[0] = 2
+	b.Put([]int{1})
+	b.Put([]int{1, 2})
+	assert.Equal(t, []any{[]int{1, 2}}, drain(updates), "Receivers changing their copies do not change what is compared")
+}